@@ -2,14 +2,33 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/victoralfred/um_sys/internal/adapters/database"
 	"github.com/victoralfred/um_sys/internal/config"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/billing"
+	"github.com/victoralfred/um_sys/internal/domain/federation"
+	"github.com/victoralfred/um_sys/internal/domain/mail"
+	"github.com/victoralfred/um_sys/internal/domain/ratelimit"
+	"github.com/victoralfred/um_sys/internal/domain/session"
 	"github.com/victoralfred/um_sys/internal/handlers"
+	"github.com/victoralfred/um_sys/internal/infrastructure/geoip"
+	mailImpl "github.com/victoralfred/um_sys/internal/infrastructure/mail"
+	"github.com/victoralfred/um_sys/internal/infrastructure/oidc"
+	"github.com/victoralfred/um_sys/internal/infrastructure/postgres"
+	"github.com/victoralfred/um_sys/internal/infrastructure/pwned"
+	redisImpl "github.com/victoralfred/um_sys/internal/infrastructure/redis"
+	samlImpl "github.com/victoralfred/um_sys/internal/infrastructure/saml"
+	webauthnImpl "github.com/victoralfred/um_sys/internal/infrastructure/webauthn"
 	"github.com/victoralfred/um_sys/internal/middleware"
 	"github.com/victoralfred/um_sys/internal/repositories"
 	"github.com/victoralfred/um_sys/internal/server"
@@ -62,43 +81,619 @@ func main() {
 		logger.Fatal("Failed to run migrations", zap.Error(err))
 	}
 
+	// Redis is optional; features that need it are disabled without it
+	var redisClient *redis.Client
+	if redisAddr := getEnv("REDIS_ADDR", ""); redisAddr != "" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     redisAddr,
+			Password: getEnv("REDIS_PASSWORD", ""),
+			DB:       getIntEnv("REDIS_DB", 0),
+		})
+		defer func() {
+			_ = redisClient.Close()
+		}()
+
+		if err := redisClient.Ping(ctx).Err(); err != nil {
+			logger.Fatal("Failed to connect to Redis", zap.Error(err))
+		}
+		logger.Info("Connected to Redis successfully")
+	}
+
 	// Initialize repositories
 	userRepo := repositories.NewUserRepository(dbPool)
 
 	// Initialize services
 	userService := services.NewUserService(userRepo)
 
+	jwtSecret := getEnv("JWT_SECRET", "your-secret-key-change-this-in-production-min-32-chars!!")
+
 	tokenService := services.NewTokenService(
-		getEnv("JWT_SECRET", "your-secret-key-change-this-in-production-min-32-chars!!"),
+		jwtSecret,
 		"umanager",
 		15*time.Minute, // Access token expiry
 		7*24*time.Hour, // Refresh token expiry
 		userRepo,
 	)
+	tokenService.SetRoleProvider(postgres.NewUserRoleRepository(dbPool))
+
+	// Revoked tokens and refresh token families must be shared by every instance
+	if redisClient != nil {
+		tokenService.SetTokenStore(redisImpl.NewTokenStore(redisClient))
+		logger.Info("Using Redis token store")
+	} else {
+		tokenStore := postgres.NewTokenStore(dbPool)
+		tokenService.SetTokenStore(tokenStore)
+		// Every authenticated request checks PostgreSQL for revocations unless a cache
+		// is configured. Cached lookups are per instance, so a token revoked through
+		// another instance keeps working here for up to the TTL; only opt in when a
+		// single instance serves requests or that delay is acceptable.
+		if ttl := getDurationEnv("TOKEN_REVOCATION_CACHE_TTL", 0); ttl > 0 {
+			tokenService.SetRevocationCacheTTL(ttl)
+		}
+		logger.Info("Using PostgreSQL token store")
+
+		// Redis expires entries itself; PostgreSQL rows are swept periodically
+		go func() {
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
 
-	// Initialize security components
-	passwordHasher := security.NewPasswordHasher()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := tokenStore.DeleteExpired(ctx); err != nil {
+						logger.Warn("Failed to delete expired tokens", zap.Error(err))
+					}
+				}
+			}
+		}()
+	}
+
+	// Token signing configuration
+	jwtConfig := config.JWTConfig{
+		SigningAlgorithm:    getEnv("JWT_SIGNING_ALG", string(auth.SigningAlgorithmHS256)),
+		PrivateKeyFile:      getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		RetiringKeyFiles:    getListEnv("JWT_RETIRING_KEY_FILES", nil),
+		KeyRetirementPeriod: 7 * 24 * time.Hour, // Must cover the refresh token expiry
+	}
+
+	// Use asymmetric signing so other services can verify tokens via JWKS
+	if jwtConfig.SigningAlgorithm != string(auth.SigningAlgorithmHS256) {
+		keyRing, err := newKeyRing(jwtConfig)
+		if err != nil {
+			logger.Fatal("Failed to initialize signing keys", zap.Error(err))
+		}
+		tokenService.SetKeyRing(keyRing)
+
+		logger.Info("Asymmetric token signing enabled",
+			zap.String("algorithm", string(keyRing.Algorithm())),
+			zap.String("private_key_file", jwtConfig.PrivateKeyFile),
+			zap.Strings("retiring_key_files", jwtConfig.RetiringKeyFiles),
+		)
+	}
+
+	// Initialize security components. New password hashes use Argon2id; bcrypt
+	// hashes are still verified and upgraded as their owners sign in.
+	passwordHasher := security.NewHasherRegistry(
+		security.NewPasswordHasher(),
+		security.NewBcryptHasher(getIntEnv("BCRYPT_COST", 10)),
+	)
 	passwordValidator := security.NewPasswordValidator(&security.PasswordPolicy{
 		MinLength:           8,
 		RequireUppercase:    1,
 		RequireLowercase:    1,
 		RequireNumbers:      1,
 		RequireSpecialChars: 0,
+		ProhibitCommonWords: true,
 		MinEntropy:          30,
 	})
 
+	// Breached-password corpora, consulted in order: local file, filter, remote API
+	breachConfig := config.BreachedPasswordConfig{
+		RangeFile:   getEnv("BREACHED_PASSWORDS_FILE", ""),
+		BloomFilter: getEnv("BREACHED_PASSWORDS_FILTER", ""),
+		APIURL:      getEnv("BREACHED_PASSWORDS_API_URL", ""),
+		APITimeout:  getDurationEnv("BREACHED_PASSWORDS_API_TIMEOUT", 2*time.Second),
+	}
+
+	var breachSources []security.BreachSource
+	if breachConfig.RangeFile != "" {
+		rangeFile, err := pwned.OpenRangeFile(breachConfig.RangeFile)
+		if err != nil {
+			logger.Fatal("Failed to open breached password file", zap.Error(err))
+		}
+		defer func() { _ = rangeFile.Close() }()
+		breachSources = append(breachSources, rangeFile)
+	}
+	if breachConfig.BloomFilter != "" {
+		filter, err := pwned.LoadBloomFilter(breachConfig.BloomFilter)
+		if err != nil {
+			logger.Fatal("Failed to load breached password filter", zap.Error(err))
+		}
+		breachSources = append(breachSources, filter)
+	}
+	if breachConfig.APIURL != "" {
+		client := pwned.NewClient(breachConfig.APIURL, breachConfig.APITimeout)
+		breachSources = append(breachSources, security.NewKAnonymitySource(client))
+	}
+	if len(breachSources) > 0 {
+		passwordValidator.SetBreachChecker(security.NewBreachChecker(breachSources...))
+	} else {
+		logger.Warn("No breached password corpus configured; only a built-in list of common passwords is rejected")
+	}
+
+	// Outgoing email configuration
+	mailConfig := config.MailConfig{
+		Driver:       getEnv("MAIL_DRIVER", "file"),
+		From:         getEnv("MAIL_FROM", "no-reply@umanager.local"),
+		Dir:          getEnv("MAIL_DIR", "./tmp/mail"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getIntEnv("SMTP_PORT", 25),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+	}
+
+	mailSender, err := newMailSender(mailConfig)
+	if err != nil {
+		logger.Fatal("Failed to initialize mail sender", zap.Error(err))
+	}
+
+	authConfig := services.DefaultAuthConfig()
+	authConfig.PasswordResetURL = getEnv("PASSWORD_RESET_URL", authConfig.PasswordResetURL)
+	authConfig.PasswordResetExpiry = getDurationEnv("PASSWORD_RESET_EXPIRY", authConfig.PasswordResetExpiry)
+
+	// Passwordless sign-in links
+	magicLinkConfig := config.MagicLinkConfig{
+		Enabled: getBoolEnv("MAGIC_LINK_ENABLED", false),
+		URL:     getEnv("MAGIC_LINK_URL", authConfig.MagicLinkURL),
+		Expiry:  getDurationEnv("MAGIC_LINK_EXPIRY", authConfig.MagicLinkExpiry),
+	}
+	authConfig.MagicLinkURL = magicLinkConfig.URL
+	authConfig.MagicLinkExpiry = magicLinkConfig.Expiry
+
+	authService := services.NewAuthService(
+		userRepo,
+		tokenService,
+		passwordHasher,
+		passwordValidator,
+		mailSender,
+		authConfig,
+	)
+	authService.SetLogger(logger)
+
+	// Email verification policy
+	emailVerificationConfig := config.EmailVerificationConfig{
+		RequireForLogin:       getBoolEnv("EMAIL_VERIFICATION_REQUIRED_FOR_LOGIN", false),
+		RestrictedPermissions: getListEnv("EMAIL_VERIFICATION_RESTRICTED_PERMISSIONS", []string{"billing:write"}),
+		TokenExpiry:           getDurationEnv("EMAIL_VERIFICATION_EXPIRY", 24*time.Hour),
+	}
+
+	verificationConfig := services.DefaultEmailVerificationConfig()
+	verificationConfig.VerifyURL = getEnv("EMAIL_VERIFICATION_URL", verificationConfig.VerifyURL)
+	verificationConfig.TokenExpiry = emailVerificationConfig.TokenExpiry
+	verificationConfig.Policy = auth.EmailVerificationPolicy{
+		RequireForLogin:       emailVerificationConfig.RequireForLogin,
+		RestrictedPermissions: emailVerificationConfig.RestrictedPermissions,
+	}
+
+	// Resends are only throttled when Redis is available
+	var rateLimiter ratelimit.RateLimiter
+	if redisClient != nil {
+		rateLimiter = redisImpl.NewRateLimiter(redisClient)
+	}
+
+	emailVerificationService := services.NewEmailVerificationService(
+		userRepo,
+		mailSender,
+		rateLimiter,
+		getEnv("EMAIL_VERIFICATION_SECRET", jwtSecret),
+		verificationConfig,
+	)
+	authService.SetEmailVerificationService(emailVerificationService)
+
+	// Progressive lockout and sign-in throttling
+	lockoutDefaults := services.DefaultLockoutConfig()
+	lockoutConfig := config.LockoutConfig{
+		AccountMaxFailures:  getIntEnv("LOCKOUT_ACCOUNT_MAX_FAILURES", lockoutDefaults.AccountMaxFailures),
+		AccountLockout:      getDurationEnv("LOCKOUT_ACCOUNT_DURATION", lockoutDefaults.AccountLockout),
+		MaxAccountLockout:   getDurationEnv("LOCKOUT_ACCOUNT_MAX_DURATION", lockoutDefaults.MaxAccountLockout),
+		IPMaxFailures:       getIntEnv("LOCKOUT_IP_MAX_FAILURES", lockoutDefaults.IPMaxFailures),
+		IPWindow:            getDurationEnv("LOCKOUT_IP_WINDOW", lockoutDefaults.IPWindow),
+		MaxIPWindow:         getDurationEnv("LOCKOUT_IP_MAX_WINDOW", lockoutDefaults.MaxIPWindow),
+		StuffingMaxAccounts: getIntEnv("LOCKOUT_STUFFING_MAX_ACCOUNTS", lockoutDefaults.StuffingMaxAccounts),
+		StuffingWindow:      getDurationEnv("LOCKOUT_STUFFING_WINDOW", lockoutDefaults.StuffingWindow),
+		StuffingBlock:       getDurationEnv("LOCKOUT_STUFFING_BLOCK", lockoutDefaults.StuffingBlock),
+	}
+
+	lockoutService := services.NewLockoutService(userRepo, services.LockoutConfig{
+		AccountMaxFailures:  lockoutConfig.AccountMaxFailures,
+		AccountLockout:      lockoutConfig.AccountLockout,
+		MaxAccountLockout:   lockoutConfig.MaxAccountLockout,
+		IPMaxFailures:       lockoutConfig.IPMaxFailures,
+		IPWindow:            lockoutConfig.IPWindow,
+		MaxIPWindow:         lockoutConfig.MaxIPWindow,
+		StrikeMemory:        lockoutDefaults.StrikeMemory,
+		StuffingMaxAccounts: lockoutConfig.StuffingMaxAccounts,
+		StuffingWindow:      lockoutConfig.StuffingWindow,
+		StuffingBlock:       lockoutConfig.StuffingBlock,
+	})
+	// Addresses are only throttled when Redis is available
+	if rateLimiter != nil {
+		lockoutService.SetRateLimiter(rateLimiter)
+	}
+	authService.SetLockoutService(lockoutService)
+
+	// Password history and maximum password age
+	passwordPolicyDefaults := services.DefaultPasswordPolicyConfig()
+	passwordPolicyConfig := config.PasswordPolicyConfig{
+		HistoryDepth:      getIntEnv("PASSWORD_HISTORY_DEPTH", passwordPolicyDefaults.HistoryDepth),
+		MaxAge:            getDurationEnv("PASSWORD_MAX_AGE", passwordPolicyDefaults.MaxAge),
+		WarningPeriod:     getDurationEnv("PASSWORD_EXPIRY_WARNING", passwordPolicyDefaults.WarningPeriod),
+		ChangePasswordURL: getEnv("PASSWORD_CHANGE_URL", passwordPolicyDefaults.ChangePasswordURL),
+	}
+	passwordPolicyService := services.NewPasswordPolicyService(
+		postgres.NewPasswordHistoryRepository(dbPool),
+		passwordHasher,
+		services.PasswordPolicyConfig{
+			HistoryDepth:      passwordPolicyConfig.HistoryDepth,
+			MaxAge:            passwordPolicyConfig.MaxAge,
+			WarningPeriod:     passwordPolicyConfig.WarningPeriod,
+			WarningInterval:   passwordPolicyDefaults.WarningInterval,
+			ChangePasswordURL: passwordPolicyConfig.ChangePasswordURL,
+		},
+	)
+	passwordPolicyService.SetExpiryWarnings(postgres.NewPasswordExpiryRepository(dbPool), mailSender)
+	passwordPolicyService.SetLogger(logger)
+	passwordPolicyService.StartExpiryWarnings(ctx)
+	authService.SetPasswordPolicyService(passwordPolicyService)
+
+	// Risk-based sign-in
+	riskDefaults := services.DefaultRiskConfig()
+	riskConfig := config.RiskConfig{
+		Enabled:         getBoolEnv("RISK_ENABLED", true),
+		GeoIPCityDB:     getEnv("GEOIP_CITY_DB", ""),
+		GeoIPASNDB:      getEnv("GEOIP_ASN_DB", ""),
+		StepUpThreshold: getIntEnv("RISK_STEP_UP_THRESHOLD", riskDefaults.StepUpThreshold),
+		BlockThreshold:  getIntEnv("RISK_BLOCK_THRESHOLD", riskDefaults.BlockThreshold),
+	}
+
+	// GeoIP data places risky sign-ins and active sessions on the map
+	locator, err := geoip.NewLocator(riskConfig.GeoIPCityDB, riskConfig.GeoIPASNDB)
+	if err != nil {
+		logger.Fatal("Failed to open GeoIP databases", zap.Error(err))
+	}
+
+	if riskConfig.Enabled {
+		riskRules := riskDefaults
+		riskRules.StepUpThreshold = riskConfig.StepUpThreshold
+		riskRules.BlockThreshold = riskConfig.BlockThreshold
+
+		riskService := services.NewRiskService(postgres.NewLoginEventRepository(dbPool), riskRules)
+		riskService.SetMailSender(mailSender)
+		riskService.SetLogger(logger)
+
+		// Without GeoIP data only the device and failed attempt signals apply
+		riskService.SetLocator(locator)
+
+		authService.SetRiskService(riskService)
+	}
+
+	// Devices that skip MFA once a second factor has been passed on them
+	trustedDeviceDefaults := services.DefaultTrustedDeviceConfig()
+	trustedDeviceConfig := config.TrustedDeviceConfig{
+		TrustDays:         getIntEnv("TRUSTED_DEVICE_DAYS", int(trustedDeviceDefaults.TrustDuration/(24*time.Hour))),
+		MaxDevicesPerUser: getIntEnv("TRUSTED_DEVICE_MAX_PER_USER", trustedDeviceDefaults.MaxDevicesPerUser),
+	}
+
+	var trustedDeviceService *services.TrustedDeviceService
+	if trustedDeviceConfig.TrustDays > 0 {
+		trustedDeviceService = services.NewTrustedDeviceService(
+			postgres.NewTrustedDeviceRepository(dbPool),
+			getEnv("TRUSTED_DEVICE_SECRET", jwtSecret),
+			services.TrustedDeviceConfig{
+				TrustDuration:     time.Duration(trustedDeviceConfig.TrustDays) * 24 * time.Hour,
+				MaxDevicesPerUser: trustedDeviceConfig.MaxDevicesPerUser,
+			},
+		)
+		authService.SetTrustedDeviceService(trustedDeviceService)
+	}
+
+	// Second factors users have set up; password sign-ins accept TOTP and backup
+	// codes, and security keys through /auth/login/security-key. SMS and email
+	// codes are not supported.
+	mfaRepo := postgres.NewMFARepository(dbPool)
+	mfaService := services.NewMFAService(
+		mfaRepo,
+		userRepo,
+		&services.DefaultTOTPProvider{},
+		nil,
+		nil,
+		&services.DefaultCodeGenerator{},
+		nil,
+		services.NewRegistryPasswordHasher(passwordHasher),
+	)
+	mfaService.SetTrustedDeviceService(trustedDeviceService)
+	authService.SetMFAService(mfaService)
+
+	// Session policy: idle timeouts and lifetimes are carried in tokens, while
+	// concurrent session caps need the session store
+	sessionConfig := config.SessionConfig{
+		IdleTimeout:   getDurationEnv("SESSION_IDLE_TIMEOUT", 0),
+		SlidingExpiry: getDurationEnv("SESSION_SLIDING_EXPIRY", 0),
+		MaxLifetime:   getDurationEnv("SESSION_MAX_LIFETIME", 0),
+		MaxConcurrent: getIntEnv("SESSION_MAX_CONCURRENT", 0),
+		OnLimit:       getEnv("SESSION_ON_LIMIT", string(session.LimitEvictOldest)),
+		PoliciesFile:  getEnv("SESSION_POLICIES_FILE", ""),
+		PoliciesJSON:  getEnv("SESSION_POLICIES", ""),
+	}
+
+	sessionPolicyConfig, err := loadSessionPolicies(sessionConfig)
+	if err != nil {
+		logger.Fatal("Failed to load session policies", zap.Error(err))
+	}
+	subscriptionPlans := postgres.NewSubscriptionPlanRepository(dbPool)
+	sessionPolicies := services.NewSessionPolicies(sessionPolicyConfig)
+	sessionPolicies.SetPlanProvider(subscriptionPlans)
+	tokenService.SetSessionPolicies(sessionPolicies)
+
+	// Server-side sessions are kept in Redis, so they are only tracked when it is available
+	var sessionService *services.SessionService
+	if redisClient != nil {
+		sessionService = services.NewSessionService(redisImpl.NewSessionRepositoryWithClient(redisClient))
+		sessionService.SetTokenService(tokenService)
+		sessionService.SetSessionPolicies(sessionPolicies)
+		sessionService.SetLocator(locator)
+		tokenService.SetSessionService(sessionService)
+		authService.SetSessionService(sessionService)
+	}
+
+	// Audit log; alert rules are not configured, so entries are only stored
+	auditService := services.NewAuditService(postgres.NewAuditLogRepository(dbPool), nil, nil, nil)
+	if sessionService != nil {
+		sessionService.SetAuditService(auditService)
+	}
+
+	// Admin impersonation
+	impersonationDefaults := services.DefaultImpersonationConfig()
+	impersonationConfig := config.ImpersonationConfig{
+		TokenTTL: getDurationEnv("IMPERSONATION_TOKEN_TTL", impersonationDefaults.TokenTTL),
+	}
+	impersonationService := services.NewImpersonationService(userRepo, tokenService, services.ImpersonationConfig{
+		TokenTTL: impersonationConfig.TokenTTL,
+	})
+	impersonationService.SetAuditService(auditService)
+
+	// OpenID Connect provider
+	oidcConfig := config.OIDCConfig{
+		Issuer:                  strings.TrimSuffix(getEnv("OIDC_ISSUER", "http://localhost:8080/v1"), "/"),
+		LoginURL:                getEnv("OIDC_LOGIN_URL", "http://localhost:8080/login"),
+		AuthorizationCodeExpiry: getDurationEnv("OIDC_AUTHORIZATION_CODE_EXPIRY", time.Minute),
+		IntrospectionCacheTTL:   getDurationEnv("OIDC_INTROSPECTION_CACHE_TTL", 5*time.Second),
+	}
+
+	oauthService := services.NewOAuthService(
+		postgres.NewOAuthClientRepository(dbPool),
+		postgres.NewOAuthCodeRepository(dbPool),
+		postgres.NewOAuthConsentRepository(dbPool),
+		userRepo,
+		tokenService,
+		services.OAuthConfig{
+			Issuer:                  oidcConfig.Issuer,
+			LoginURL:                oidcConfig.LoginURL,
+			AuthorizationCodeExpiry: oidcConfig.AuthorizationCodeExpiry,
+			IntrospectionCacheTTL:   oidcConfig.IntrospectionCacheTTL,
+		},
+	)
+
+	// Sign-in through upstream identity providers
+	federationConfig := config.FederationConfig{
+		ProvidersFile:   getEnv("FEDERATION_PROVIDERS_FILE", ""),
+		ProvidersJSON:   getEnv("FEDERATION_PROVIDERS", ""),
+		CallbackBaseURL: strings.TrimSuffix(getEnv("FEDERATION_CALLBACK_BASE_URL", "http://localhost:8080/v1/auth/federated"), "/"),
+		StateExpiry:     getDurationEnv("FEDERATION_STATE_EXPIRY", services.DefaultFederationConfig().StateExpiry),
+	}
+
+	federationService := services.NewFederationService(
+		userRepo,
+		postgres.NewFederatedIdentityRepository(dbPool),
+		tokenService,
+		getEnv("FEDERATION_STATE_SECRET", jwtSecret),
+		services.FederationConfig{StateExpiry: federationConfig.StateExpiry},
+	)
+	federationService.SetEmailVerificationService(emailVerificationService)
+
+	providerConfigs, err := loadFederationProviders(federationConfig)
+	if err != nil {
+		logger.Fatal("Failed to load identity providers", zap.Error(err))
+	}
+	for _, providerConfig := range providerConfigs {
+		// An unreachable provider should not keep the server from starting
+		provider, err := oidc.NewProvider(ctx, providerConfig, nil)
+		if err != nil {
+			logger.Warn("Skipping identity provider", zap.String("provider", providerConfig.ID), zap.Error(err))
+			continue
+		}
+		federationService.RegisterProvider(providerConfig, provider)
+		logger.Info("Registered identity provider", zap.String("provider", providerConfig.ID))
+	}
+
+	// Sign-in through organizations' SAML identity providers. Without an RBAC role
+	// repository, connections' default and group-mapped roles are not granted.
+	samlDefaults := services.DefaultSAMLConfig()
+	samlConfig := config.SAMLConfig{
+		BaseURL:       strings.TrimSuffix(getEnv("SAML_BASE_URL", "http://localhost:8080"), "/"),
+		RequestExpiry: getDurationEnv("SAML_REQUEST_EXPIRY", samlDefaults.RequestExpiry),
+		ClockSkew:     getDurationEnv("SAML_CLOCK_SKEW", 0),
+	}
+	samlService := services.NewSAMLService(
+		postgres.NewSAMLConnectionRepository(dbPool),
+		postgres.NewSAMLAssertionCache(dbPool),
+		samlImpl.NewProtocol(samlConfig.ClockSkew),
+		federationService,
+		userRepo,
+		getEnv("SAML_STATE_SECRET", jwtSecret),
+		services.SAMLConfig{
+			BaseURL:       samlConfig.BaseURL,
+			RequestExpiry: samlConfig.RequestExpiry,
+		},
+	)
+
+	// SCIM provisioning by organizations' identity providers. Without an RBAC role
+	// repository, groups are kept but grant no roles.
+	scimConfig := config.SCIMConfig{
+		BaseURL:    strings.TrimSuffix(getEnv("SCIM_BASE_URL", samlConfig.BaseURL), "/"),
+		MaxResults: getIntEnv("SCIM_MAX_RESULTS", services.DefaultSCIMConfig().MaxResults),
+	}
+	scimService := services.NewSCIMService(
+		postgres.NewSCIMTokenRepository(dbPool),
+		postgres.NewSCIMAccountRepository(dbPool),
+		postgres.NewSCIMGroupRepository(dbPool),
+		userRepo,
+		tokenService,
+		services.SCIMConfig{
+			BaseURL:    scimConfig.BaseURL,
+			MaxResults: scimConfig.MaxResults,
+		},
+	)
+	scimService.SetSessionService(sessionService)
+
+	// API keys
+	apiKeyConfig := config.APIKeyConfig{
+		MaxKeysPerOwner: getIntEnv("API_KEY_MAX_PER_OWNER", services.DefaultAPIKeyConfig().MaxKeysPerOwner),
+		MaxLifetime:     getDurationEnv("API_KEY_MAX_LIFETIME", 0),
+	}
+
+	apiKeyService := services.NewAPIKeyService(
+		postgres.NewAPIKeyRepository(dbPool),
+		userRepo,
+		services.APIKeyConfig{
+			MaxKeysPerOwner: apiKeyConfig.MaxKeysPerOwner,
+			MaxLifetime:     apiKeyConfig.MaxLifetime,
+		},
+	)
+
+	// Service accounts authenticate with the client_credentials grant
+	serviceAccountService := services.NewServiceAccountService(
+		postgres.NewServiceAccountRepository(dbPool),
+		tokenService,
+	)
+	oauthService.SetServiceAccountService(serviceAccountService)
+
+	// Organizations; tokens carry the caller's role in their active organization
+	organizationMemberships := postgres.NewOrganizationMembershipRepository(dbPool)
+	tokenService.SetOrganizationMemberships(organizationMemberships)
+	// Organization owners and admins manage keys owned by their organization
+	apiKeyService.SetMembershipRepository(organizationMemberships)
+	organizationService := services.NewOrganizationService(
+		postgres.NewOrganizationRepository(dbPool),
+		organizationMemberships,
+		userRepo,
+		tokenService,
+	)
+	organizationService.SetAuditService(auditService)
+	// Seats are limited by the team member limit of the organization's subscription plan,
+	// and pending invitations hold seats until they are accepted, revoked or expire
+	organizationInvitations := postgres.NewOrganizationInvitationRepository(dbPool)
+	organizationService.SetPlanProvider(subscriptionPlans)
+	organizationService.SetInvitationRepository(organizationInvitations)
+
+	// Organization invitations; accepting one may create the invited account
+	invitationConfig := services.DefaultInvitationConfig()
+	invitationConfig.AcceptURL = getEnv("INVITATION_ACCEPT_URL", invitationConfig.AcceptURL)
+	invitationConfig.TokenExpiry = getDurationEnv("INVITATION_EXPIRY", invitationConfig.TokenExpiry)
+	invitationService := services.NewInvitationService(
+		organizationInvitations,
+		organizationService,
+		userRepo,
+		mailSender,
+		getEnv("INVITATION_SECRET", jwtSecret),
+		invitationConfig,
+	)
+	invitationService.SetAuthService(authService)
+	invitationService.SetAuditService(auditService)
+
+	// Security keys and passkeys
+	webAuthnDefaults := services.DefaultWebAuthnConfig()
+	webAuthnConfig := config.WebAuthnConfig{
+		RPID:    getEnv("WEBAUTHN_RP_ID", webAuthnDefaults.RPID),
+		RPName:  getEnv("WEBAUTHN_RP_NAME", webAuthnDefaults.RPName),
+		Origins: getListEnv("WEBAUTHN_ORIGINS", []string{"http://localhost:8080"}),
+		Timeout: getDurationEnv("WEBAUTHN_TIMEOUT", webAuthnDefaults.Timeout),
+	}
+
+	webAuthnService := services.NewWebAuthnService(
+		postgres.NewWebAuthnCredentialRepository(dbPool),
+		postgres.NewWebAuthnCeremonyRepository(dbPool),
+		webauthnImpl.NewVerifier(webAuthnConfig.RPID, webAuthnConfig.Origins),
+		userRepo,
+		services.WebAuthnConfig{
+			RPID:    webAuthnConfig.RPID,
+			RPName:  webAuthnConfig.RPName,
+			Timeout: webAuthnConfig.Timeout,
+		},
+	)
+	webAuthnService.SetMFARepository(mfaRepo)
+	authService.SetWebAuthnService(webAuthnService)
+
+	if magicLinkConfig.Enabled {
+		authService.SetMagicLinkRepository(postgres.NewMagicLinkRepository(dbPool))
+	}
+
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(
 		userService,
 		tokenService,
+		authService,
 		passwordHasher,
 		passwordValidator,
 		logger,
 	)
+	authHandler.SetEmailVerificationService(emailVerificationService)
+	authHandler.SetLockoutService(lockoutService)
+	authHandler.SetTrustedDeviceService(trustedDeviceService)
+	authHandler.SetSessionService(sessionService)
 	docsHandler := handlers.NewDocsHandler()
+	jwksHandler := handlers.NewJWKSHandler(tokenService)
+	passwordHandler := handlers.NewPasswordHandler(authService, logger)
+	emailVerificationHandler := handlers.NewEmailVerificationHandler(emailVerificationService, logger)
+	oauthHandler := handlers.NewOAuthHandler(oauthService, logger)
+	federationHandler := handlers.NewFederationHandler(federationService, tokenService, federationConfig.StateExpiry, logger)
+	samlHandler := handlers.NewSAMLHandler(samlService, tokenService, samlConfig.RequestExpiry, logger)
+	scimHandler := handlers.NewSCIMHandler(scimService, logger)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService, logger)
+	serviceAccountHandler := handlers.NewServiceAccountHandler(serviceAccountService, logger)
+	webAuthnHandler := handlers.NewWebAuthnHandler(webAuthnService, authService, tokenService, logger)
+	adminUserHandler := handlers.NewAdminUserHandler(lockoutService, logger)
+	impersonationHandler := handlers.NewImpersonationHandler(impersonationService, logger)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService, logger)
+	invitationHandler := handlers.NewInvitationHandler(invitationService, logger)
+	passwordHashHandler := handlers.NewPasswordHashHandler(services.NewPasswordHashService(userRepo, passwordHasher), logger)
+	federationHandler.SetSessionService(sessionService)
+	samlHandler.SetSessionService(sessionService)
+	webAuthnHandler.SetSessionService(sessionService)
+
+	var magicLinkHandler *handlers.MagicLinkHandler
+	if magicLinkConfig.Enabled {
+		magicLinkHandler = handlers.NewMagicLinkHandler(authService, tokenService, magicLinkConfig.Expiry, logger)
+		magicLinkHandler.SetTrustedDeviceService(trustedDeviceService)
+		magicLinkHandler.SetSessionService(sessionService)
+	}
+
+	var trustedDeviceHandler *handlers.TrustedDeviceHandler
+	if trustedDeviceService != nil {
+		trustedDeviceHandler = handlers.NewTrustedDeviceHandler(trustedDeviceService, logger)
+	}
+
+	var sessionHandler *handlers.SessionHandler
+	if sessionService != nil {
+		sessionHandler = handlers.NewSessionHandler(sessionService, logger)
+	}
 
 	// Create middleware adapters
 	tokenMiddleware := middleware.NewTokenServiceAdapter(tokenService)
+	tokenMiddleware.SetEmailVerificationPolicy(emailVerificationService.Policy())
+	tokenMiddleware.SetAPIKeyService(apiKeyService)
 	rbacMiddleware := middleware.NewSimpleRBACService()
 
 	// Server configuration
@@ -113,18 +708,56 @@ func main() {
 		RateLimit: config.RateLimitConfig{
 			Global: 100,
 		},
-		DocsURL:       "http://localhost:8080/docs",
-		SupportEmail:  "support@umanager.local",
-		StatusPageURL: "http://localhost:8080/status",
+		JWT:               jwtConfig,
+		Mail:              mailConfig,
+		EmailVerification: emailVerificationConfig,
+		OIDC:              oidcConfig,
+		Federation:        federationConfig,
+		APIKeys:           apiKeyConfig,
+		WebAuthn:          webAuthnConfig,
+		MagicLink:         magicLinkConfig,
+		Lockout:           lockoutConfig,
+		Risk:              riskConfig,
+		TrustedDevices:    trustedDeviceConfig,
+		Sessions:          sessionConfig,
+		Impersonation:     impersonationConfig,
+		SAML:              samlConfig,
+		SCIM:              scimConfig,
+		BreachedPasswords: breachConfig,
+		PasswordPolicy:    passwordPolicyConfig,
+		DocsURL:           "http://localhost:8080/docs",
+		SupportEmail:      "support@umanager.local",
+		StatusPageURL:     "http://localhost:8080/status",
 	}
 
 	// Initialize server with services
 	serverServices := &server.Services{
-		UserService:  userService,
-		TokenService: tokenMiddleware,
-		RBACService:  rbacMiddleware,
-		AuthHandler:  authHandler,
-		DocsHandler:  docsHandler,
+		UserService:              userService,
+		TokenService:             tokenMiddleware,
+		RBACService:              rbacMiddleware,
+		AuditService:             auditService,
+		SCIMTokens:               scimService,
+		AuthHandler:              authHandler,
+		DocsHandler:              docsHandler,
+		JWKSHandler:              jwksHandler,
+		PasswordHandler:          passwordHandler,
+		EmailVerificationHandler: emailVerificationHandler,
+		OAuthHandler:             oauthHandler,
+		FederationHandler:        federationHandler,
+		SAMLHandler:              samlHandler,
+		SCIMHandler:              scimHandler,
+		APIKeyHandler:            apiKeyHandler,
+		ServiceAccountHandler:    serviceAccountHandler,
+		WebAuthnHandler:          webAuthnHandler,
+		MFAService:               mfaService,
+		MagicLinkHandler:         magicLinkHandler,
+		TrustedDeviceHandler:     trustedDeviceHandler,
+		SessionHandler:           sessionHandler,
+		AdminUserHandler:         adminUserHandler,
+		ImpersonationHandler:     impersonationHandler,
+		PasswordHashHandler:      passwordHashHandler,
+		OrganizationHandler:      organizationHandler,
+		InvitationHandler:        invitationHandler,
 	}
 
 	// Create and setup server
@@ -140,9 +773,31 @@ func main() {
 	fmt.Println("\nPublic endpoints:")
 	fmt.Println("  POST   /v1/auth/register    - Register new user")
 	fmt.Println("  POST   /v1/auth/login       - Login user")
+	fmt.Println("  POST   /v1/auth/login/security-key - Start the security key step of a login")
 	fmt.Println("  POST   /v1/auth/refresh     - Refresh tokens")
+	fmt.Println("  POST   /v1/auth/password/forgot - Request password reset")
+	fmt.Println("  POST   /v1/auth/password/reset  - Reset password with token")
+	fmt.Println("  POST   /v1/auth/password/expired - Replace an expired password")
+	fmt.Println("  POST   /v1/auth/email/verify    - Verify email address")
 	fmt.Println("  GET    /v1/health           - Health check")
 	fmt.Println("  GET    /v1/info             - API info")
+	fmt.Println("  GET    /v1/.well-known/jwks.json - Token verification keys")
+	fmt.Println("  GET    /v1/.well-known/openid-configuration - OpenID Connect discovery")
+	fmt.Println("  GET    /v1/oauth/authorize      - OAuth authorization endpoint")
+	fmt.Println("  POST   /v1/oauth/token          - OAuth token endpoint (client_credentials for service accounts)")
+	fmt.Println("  POST   /v1/oauth/introspect     - OAuth token introspection (RFC 7662)")
+	fmt.Println("  POST   /v1/oauth/revoke         - OAuth token revocation (RFC 7009)")
+	fmt.Println("  GET    /v1/auth/federated/providers - List identity providers")
+	fmt.Println("  GET    /v1/auth/federated/:provider - Sign in with an identity provider")
+	fmt.Println("  GET    /v1/auth/saml/:orgId/login - Sign in with an organization's SAML identity provider")
+	fmt.Println("  GET    /v1/auth/saml/:orgId/metadata - SAML service provider metadata")
+	fmt.Println("  POST   /v1/auth/passkey/options - Start a passkey sign-in")
+	fmt.Println("  POST   /v1/auth/passkey/login   - Sign in with a passkey")
+	fmt.Println("  POST   /v1/auth/magic-link      - Email a sign-in link (MAGIC_LINK_ENABLED)")
+	fmt.Println("  POST   /v1/auth/magic-link/verify - Sign in with a link")
+	fmt.Println("\nSCIM 2.0 provisioning (Authorization: Bearer <provisioning token>):")
+	fmt.Println("  GET    /scim/v2/Users       - List, filter and manage provisioned users")
+	fmt.Println("  GET    /scim/v2/Groups      - List, filter and manage provisioned groups")
 	fmt.Println("\nAPI Documentation:")
 	fmt.Println("  GET    /v1/docs             - Interactive Swagger UI")
 	fmt.Println("  GET    /v1/docs/            - Documentation index")
@@ -151,6 +806,28 @@ func main() {
 	fmt.Println("\nProtected endpoints (require authentication):")
 	fmt.Println("  GET    /v1/users/me         - Get current user")
 	fmt.Println("  POST   /v1/auth/logout      - Logout user")
+	fmt.Println("  GET    /v1/auth/sessions    - List active sessions (REDIS_ADDR)")
+	fmt.Println("  DELETE /v1/auth/sessions    - Sign out everywhere else")
+	fmt.Println("  POST   /v1/auth/email/resend - Resend verification email")
+	fmt.Println("  GET    /v1/oauth/userinfo   - OpenID Connect user info")
+	fmt.Println("  GET    /v1/users/me/identities - List linked identities")
+	fmt.Println("  GET    /v1/users/me/api-keys - List API keys (send keys as 'Authorization: ApiKey <key>')")
+	fmt.Println("  GET    /v1/mfa/webauthn/credentials - List security keys and passkeys")
+	fmt.Println("  GET    /v1/mfa/trusted-devices - List devices that skip MFA")
+	fmt.Println("  GET    /v1/organizations    - List your organizations")
+	fmt.Println("  POST   /v1/organizations/:orgId/members - Add a member to an organization")
+	fmt.Println("  POST   /v1/organizations/:orgId/invitations - Invite someone to an organization by email")
+	fmt.Println("  POST   /v1/organizations/:orgId/api-keys - Create an API key owned by an organization")
+	fmt.Println("  POST   /v1/invitations/accept - Accept an organization invitation")
+	fmt.Println("  POST   /v1/invitations/register - Create an account by accepting an organization invitation")
+	fmt.Println("  POST   /v1/auth/organization - Switch the organization your tokens act in")
+	fmt.Println("  GET    /v1/admin/service-accounts - Manage service accounts (admin)")
+	fmt.Println("  POST   /v1/admin/users/:userId/activate - Unlock and reactivate a user (admin)")
+	fmt.Println("  POST   /v1/admin/users/:userId/impersonate - Act as a user (admin, audited)")
+	fmt.Println("  PUT    /v1/admin/organizations/:orgId/saml - Configure an organization's SAML connection (admin)")
+	fmt.Println("  POST   /v1/admin/organizations/:orgId/scim/tokens - Issue a SCIM provisioning token (admin)")
+	fmt.Println("  GET    /v1/admin/security/password-hashes - Count accounts per hash algorithm (admin)")
+	fmt.Println("  DELETE /v1/auth/impersonation - Stop impersonating")
 	fmt.Println("\n===========================================")
 	fmt.Println("\nExample requests:")
 	fmt.Println("\n1. Register a new user:")
@@ -188,47 +865,247 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func runMigrations(ctx context.Context, db *pgxpool.Pool) error {
-	// Create users table if not exists
-	query := `
-	CREATE TABLE IF NOT EXISTS users (
-		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-		email VARCHAR(255) UNIQUE NOT NULL,
-		username VARCHAR(100) UNIQUE NOT NULL,
-		password_hash VARCHAR(255) NOT NULL,
-		first_name VARCHAR(100),
-		last_name VARCHAR(100),
-		phone_number VARCHAR(20),
-		is_active BOOLEAN NOT NULL DEFAULT true,
-		is_verified BOOLEAN NOT NULL DEFAULT false,
-		verified_at TIMESTAMP,
-		last_login_at TIMESTAMP,
-		failed_login_attempts INT NOT NULL DEFAULT 0,
-		locked_until TIMESTAMP,
-		mfa_enabled BOOLEAN NOT NULL DEFAULT false,
-		mfa_secret VARCHAR(255),
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		deleted_at TIMESTAMP
-	)`
-
-	_, err := db.Exec(ctx, query)
-	if err != nil {
-		return fmt.Errorf("failed to create users table: %w", err)
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getListEnv(key string, defaultValue []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadFederationProviders reads the upstream identity provider list from a file or
+// inline JSON. A provider's client secret may be given directly or, to keep it out
+// of the file, as the name of an environment variable holding it.
+func loadFederationProviders(cfg config.FederationConfig) ([]federation.Provider, error) {
+	data := []byte(cfg.ProvidersJSON)
+	if cfg.ProvidersFile != "" {
+		var err error
+		if data, err = os.ReadFile(cfg.ProvidersFile); err != nil {
+			return nil, fmt.Errorf("failed to read providers file: %w", err)
+		}
 	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var entries []struct {
+		federation.Provider
+		ClientSecret    string `json:"client_secret"`
+		ClientSecretEnv string `json:"client_secret_env"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse providers: %w", err)
+	}
+
+	providers := make([]federation.Provider, 0, len(entries))
+	for _, entry := range entries {
+		provider := entry.Provider
+		provider.ClientSecret = entry.ClientSecret
+		if entry.ClientSecretEnv != "" {
+			provider.ClientSecret = os.Getenv(entry.ClientSecretEnv)
+		}
+		if provider.ID == "" {
+			return nil, fmt.Errorf("identity provider without id")
+		}
+		if provider.Name == "" {
+			provider.Name = provider.ID
+		}
+		if provider.RedirectURL == "" {
+			provider.RedirectURL = cfg.CallbackBaseURL + "/" + provider.ID + "/callback"
+		}
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+// loadSessionPolicies builds the session policy from the default limits and the
+// per-plan and per-role overrides read from a file or inline JSON
+func loadSessionPolicies(cfg config.SessionConfig) (services.SessionPolicyConfig, error) {
+	policies := services.SessionPolicyConfig{
+		Default: session.Policy{
+			IdleTimeout:   cfg.IdleTimeout,
+			SlidingExpiry: cfg.SlidingExpiry,
+			MaxLifetime:   cfg.MaxLifetime,
+			MaxConcurrent: cfg.MaxConcurrent,
+			OnLimit:       session.LimitAction(cfg.OnLimit),
+		},
+	}
+
+	data := []byte(cfg.PoliciesJSON)
+	if cfg.PoliciesFile != "" {
+		var err error
+		if data, err = os.ReadFile(cfg.PoliciesFile); err != nil {
+			return policies, fmt.Errorf("failed to read session policies file: %w", err)
+		}
+	}
+
+	type entry struct {
+		IdleTimeout   string `json:"idle_timeout"`
+		SlidingExpiry string `json:"sliding_expiry"`
+		MaxLifetime   string `json:"max_lifetime"`
+		MaxConcurrent int    `json:"max_concurrent"`
+		OnLimit       string `json:"on_limit"`
+	}
+	var overrides struct {
+		Plans map[string]entry `json:"plans"`
+		Roles map[string]entry `json:"roles"`
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			return policies, fmt.Errorf("failed to parse session policies: %w", err)
+		}
+	}
+
+	toPolicy := func(name string, e entry) (session.Policy, error) {
+		policy := session.Policy{MaxConcurrent: e.MaxConcurrent, OnLimit: session.LimitAction(e.OnLimit)}
+		for _, d := range []struct {
+			value  string
+			target *time.Duration
+		}{
+			{e.IdleTimeout, &policy.IdleTimeout},
+			{e.SlidingExpiry, &policy.SlidingExpiry},
+			{e.MaxLifetime, &policy.MaxLifetime},
+		} {
+			if d.value == "" {
+				continue
+			}
+			parsed, err := time.ParseDuration(d.value)
+			if err != nil {
+				return policy, fmt.Errorf("invalid duration in session policy %q: %w", name, err)
+			}
+			*d.target = parsed
+		}
+		return policy, validateSessionPolicy(name, policy)
+	}
+
+	if err := validateSessionPolicy("default", policies.Default); err != nil {
+		return policies, err
+	}
+
+	policies.Plans = make(map[billing.PlanType]session.Policy, len(overrides.Plans))
+	for plan, e := range overrides.Plans {
+		policy, err := toPolicy("plan "+plan, e)
+		if err != nil {
+			return policies, err
+		}
+		policies.Plans[billing.PlanType(plan)] = policy
+	}
+
+	policies.Roles = make(map[string]session.Policy, len(overrides.Roles))
+	for role, e := range overrides.Roles {
+		policy, err := toPolicy("role "+role, e)
+		if err != nil {
+			return policies, err
+		}
+		policies.Roles[role] = policy
+	}
+
+	return policies, nil
+}
 
-	// Create indexes
-	indexes := []string{
-		"CREATE INDEX IF NOT EXISTS idx_users_email ON users(email) WHERE deleted_at IS NULL",
-		"CREATE INDEX IF NOT EXISTS idx_users_username ON users(username) WHERE deleted_at IS NULL",
-		"CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at)",
+func validateSessionPolicy(name string, policy session.Policy) error {
+	switch policy.OnLimit {
+	case "", session.LimitEvictOldest, session.LimitReject:
+		return nil
+	default:
+		return fmt.Errorf("session policy %q: on_limit must be %q or %q", name, session.LimitEvictOldest, session.LimitReject)
 	}
+}
 
-	for _, idx := range indexes {
-		if _, err := db.Exec(ctx, idx); err != nil {
-			return fmt.Errorf("failed to create index: %w", err)
+// newMailSender builds the configured mail sender
+func newMailSender(cfg config.MailConfig) (mail.Sender, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return mailImpl.NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	case "file":
+		return mailImpl.NewFileSender(cfg.Dir, cfg.From)
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.Driver)
+	}
+}
+
+// newKeyRing builds the signing key ring from PEM files. A generated key would
+// differ on every replica, so tokens signed by one would fail on the others.
+//
+// To rotate, point JWT_PRIVATE_KEY_FILE at the new key and list the old one in
+// JWT_RETIRING_KEY_FILES, so tokens it signed keep verifying; drop it from the
+// list once the longest-lived of those tokens has expired.
+func newKeyRing(cfg config.JWTConfig) (*services.KeyRing, error) {
+	if cfg.PrivateKeyFile == "" {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required when JWT_SIGNING_ALG is %s", cfg.SigningAlgorithm)
+	}
+
+	key, err := readSigningKey(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	retiring := make([]*auth.SigningKey, 0, len(cfg.RetiringKeyFiles))
+	for _, file := range cfg.RetiringKeyFiles {
+		old, err := readSigningKey(file)
+		if err != nil {
+			return nil, err
 		}
+		retiring = append(retiring, old)
+	}
+
+	return services.NewKeyRingFromKey(key, retiring, cfg.KeyRetirementPeriod)
+}
+
+func readSigningKey(file string) (*auth.SigningKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file %s: %w", file, err)
+	}
+
+	key, err := services.ParseSigningKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key file %s: %w", file, err)
+	}
+
+	return key, nil
+}
+
+func runMigrations(ctx context.Context, db *pgxpool.Pool) error {
+	runner := database.NewMigrationRunner(database.NewPostgresDB(db), getEnv("MIGRATIONS_DIR", "migrations"))
+	if err := runner.Validate(); err != nil {
+		return err
 	}
 
-	return nil
+	return runner.Up(ctx)
 }
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -33,6 +34,13 @@ type DB interface {
 	Ping(ctx context.Context) error
 	QueryRow(ctx context.Context, query string, args ...interface{}) Row
 	Exec(ctx context.Context, query string, args ...interface{}) error
+	WithTx(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// Tx interface for statements run inside a transaction
+type Tx interface {
+	QueryRow(ctx context.Context, query string, args ...interface{}) Row
+	Exec(ctx context.Context, query string, args ...interface{}) error
 }
 
 // Row interface for database row operations
@@ -67,6 +75,34 @@ func (p *postgresDB) Exec(ctx context.Context, query string, args ...interface{}
 	return err
 }
 
+// WithTx runs fn in a transaction, committing if it returns nil and rolling back otherwise
+func (p *postgresDB) WithTx(ctx context.Context, fn func(tx Tx) error) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		return fn(&postgresTx{tx: tx})
+	})
+}
+
+// postgresTx implements Tx interface
+type postgresTx struct {
+	tx pgx.Tx
+}
+
+// QueryRow executes a query that returns at most one row
+func (t *postgresTx) QueryRow(ctx context.Context, query string, args ...interface{}) Row {
+	return t.tx.QueryRow(ctx, query, args...)
+}
+
+// Exec executes a query without returning any rows
+func (t *postgresTx) Exec(ctx context.Context, query string, args ...interface{}) error {
+	_, err := t.tx.Exec(ctx, query, args...)
+	return err
+}
+
+// NewPostgresDB wraps an existing connection pool; closing the result closes the pool
+func NewPostgresDB(pool *pgxpool.Pool) DB {
+	return &postgresDB{pool: pool}
+}
+
 // NewPostgresConnection creates a new PostgreSQL connection
 func NewPostgresConnection(config Config) (DB, error) {
 	// Validate configuration
@@ -162,20 +198,15 @@ func (m *MigrationRunner) Validate() error {
 	return nil
 }
 
-// Up runs all pending migrations
-func (m *MigrationRunner) Up(ctx context.Context) error {
-	// Create schema_migrations table if not exists
-	query := `
-		CREATE TABLE IF NOT EXISTS schema_migrations (
-			version BIGINT PRIMARY KEY,
-			dirty BOOLEAN NOT NULL DEFAULT FALSE
-		)
-	`
-
-	if err := m.db.Exec(ctx, query); err != nil {
-		return fmt.Errorf("failed to create schema_migrations table: %w", err)
-	}
+// migrationLockID is the advisory lock key held while migrations run
+const migrationLockID int64 = 4207163527
 
+// Up runs all pending migrations. Progress is kept the way the migrate CLI
+// keeps it, as a single schema_migrations row holding the current version, so
+// the Makefile targets and the server can be used on the same database. The
+// whole run is one transaction holding an advisory lock, so instances starting
+// together apply each migration once and a failed run leaves nothing behind.
+func (m *MigrationRunner) Up(ctx context.Context) error {
 	// Read migration files
 	files, err := os.ReadDir(m.migrationsDir)
 	if err != nil {
@@ -191,49 +222,77 @@ func (m *MigrationRunner) Up(ctx context.Context) error {
 	}
 	sort.Strings(upMigrations)
 
-	// Apply each migration
-	for _, fileName := range upMigrations {
-		// Extract version from filename (e.g., "001_create_users_table.up.sql" -> 1)
-		parts := strings.Split(fileName, "_")
-		if len(parts) < 2 {
-			continue
+	return m.db.WithTx(ctx, func(tx Tx) error {
+		// Wait for any other instance migrating the same database
+		if err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+			return fmt.Errorf("failed to lock migrations: %w", err)
 		}
 
-		version, err := strconv.ParseInt(parts[0], 10, 64)
-		if err != nil {
-			return fmt.Errorf("failed to parse version from %s: %w", fileName, err)
+		// Create schema_migrations table if not exists
+		query := `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version BIGINT PRIMARY KEY,
+				dirty BOOLEAN NOT NULL DEFAULT FALSE
+			)
+		`
+
+		if err := tx.Exec(ctx, query); err != nil {
+			return fmt.Errorf("failed to create schema_migrations table: %w", err)
 		}
 
-		// Check if migration already applied
-		var exists bool
-		err = m.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&exists)
+		var current int64
+		var dirty bool
+		err := tx.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0), COALESCE(BOOL_OR(dirty), FALSE) FROM schema_migrations").Scan(&current, &dirty)
 		if err != nil {
-			return fmt.Errorf("failed to check migration version %d: %w", version, err)
+			return fmt.Errorf("failed to read migration version: %w", err)
 		}
-
-		if exists {
-			continue // Skip already applied migration
+		if dirty {
+			return fmt.Errorf("database is dirty at migration %d; fix it and force the version before migrating", current)
 		}
 
-		// Read migration file
-		filePath := filepath.Join(m.migrationsDir, fileName)
-		content, err := os.ReadFile(filePath)
-		if err != nil {
-			return fmt.Errorf("failed to read migration file %s: %w", fileName, err)
+		applied := current
+		for _, fileName := range upMigrations {
+			// Extract version from filename (e.g., "001_create_users_table.up.sql" -> 1)
+			parts := strings.Split(fileName, "_")
+			if len(parts) < 2 {
+				continue
+			}
+
+			version, err := strconv.ParseInt(parts[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse version from %s: %w", fileName, err)
+			}
+
+			if version <= current {
+				continue // Skip already applied migration
+			}
+
+			// Read migration file
+			content, err := os.ReadFile(filepath.Join(m.migrationsDir, fileName))
+			if err != nil {
+				return fmt.Errorf("failed to read migration file %s: %w", fileName, err)
+			}
+
+			// Apply migration
+			if err := tx.Exec(ctx, string(content)); err != nil {
+				return fmt.Errorf("failed to apply migration %s: %w", fileName, err)
+			}
+			applied = version
 		}
 
-		// Apply migration
-		if err := m.db.Exec(ctx, string(content)); err != nil {
-			return fmt.Errorf("failed to apply migration %s: %w", fileName, err)
+		if applied == current {
+			return nil
 		}
 
-		// Record migration
-		if err := m.db.Exec(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)", version, false); err != nil {
-			return fmt.Errorf("failed to record migration %d: %w", version, err)
+		// Record the new version
+		if err := tx.Exec(ctx, "DELETE FROM schema_migrations"); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", applied, err)
 		}
-	}
-
-	return nil
+		if err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, FALSE)", applied); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", applied, err)
+		}
+		return nil
+	})
 }
 
 // Down rolls back migrations
@@ -409,26 +409,70 @@ func (r *UserRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID, logi
 	return nil
 }
 
-// IncrementFailedLoginAttempts increments failed login attempts
-func (r *UserRepository) IncrementFailedLoginAttempts(ctx context.Context, id uuid.UUID) error {
+// IncrementFailedLoginAttempts increments failed login attempts and returns the new count.
+// Whether the account is then locked is decided by the lockout policy.
+func (r *UserRepository) IncrementFailedLoginAttempts(ctx context.Context, id uuid.UUID) (int, error) {
 	if id == uuid.Nil {
-		return user.ErrInvalidUserID
+		return 0, user.ErrInvalidUserID
 	}
 
 	query := `
 		UPDATE users 
 		SET 
 			failed_login_attempts = failed_login_attempts + 1,
-			locked_until = CASE 
-				WHEN failed_login_attempts >= 4 THEN NOW() + INTERVAL '15 minutes'
-				ELSE locked_until
-			END,
 			updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING failed_login_attempts`
+
+	var attempts int
+	err := r.db.QueryRow(ctx, query, id).Scan(&attempts)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, user.ErrUserNotFound
+		}
+		return 0, fmt.Errorf("failed to increment failed login attempts: %w", err)
+	}
+
+	return attempts, nil
+}
+
+// Lock locks a user out of signing in until the given time
+func (r *UserRepository) Lock(ctx context.Context, id uuid.UUID, until time.Time) error {
+	if id == uuid.Nil {
+		return user.ErrInvalidUserID
+	}
+
+	query := `
+		UPDATE users 
+		SET locked_until = $2, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := r.db.Exec(ctx, query, id, until)
+	if err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return user.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Unlock clears a lockout and the failed login attempts that led to it
+func (r *UserRepository) Unlock(ctx context.Context, id uuid.UUID) error {
+	if id == uuid.Nil {
+		return user.ErrInvalidUserID
+	}
+
+	query := `
+		UPDATE users 
+		SET failed_login_attempts = 0, locked_until = NULL, updated_at = NOW()
 		WHERE id = $1 AND deleted_at IS NULL`
 
 	result, err := r.db.Exec(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("failed to increment failed login attempts: %w", err)
+		return fmt.Errorf("failed to unlock user: %w", err)
 	}
 
 	if result.RowsAffected() == 0 {
@@ -18,6 +18,57 @@ type Config struct {
 	// Rate limiting
 	RateLimit RateLimitConfig
 
+	// Token signing
+	JWT JWTConfig
+
+	// Outgoing email
+	Mail MailConfig
+
+	// Email verification policy
+	EmailVerification EmailVerificationConfig
+
+	// OpenID Connect provider
+	OIDC OIDCConfig
+
+	// Sign-in through upstream identity providers
+	Federation FederationConfig
+
+	// Long-lived keys for scripts and CI
+	APIKeys APIKeyConfig
+
+	// Security keys and passkeys
+	WebAuthn WebAuthnConfig
+
+	// Passwordless sign-in links sent by email
+	MagicLink MagicLinkConfig
+
+	// Account lockout and sign-in throttling
+	Lockout LockoutConfig
+
+	// Risk-based sign-in
+	Risk RiskConfig
+
+	// Devices that skip MFA
+	TrustedDevices TrustedDeviceConfig
+
+	// Idle timeouts, lifetimes and concurrent session caps
+	Sessions SessionConfig
+
+	// Admins acting as users
+	Impersonation ImpersonationConfig
+
+	// Enterprise sign-in through organizations' SAML identity providers
+	SAML SAMLConfig
+
+	// Provisioning of users and groups by organizations' identity providers
+	SCIM SCIMConfig
+
+	// Rejection of passwords seen in data breaches
+	BreachedPasswords BreachedPasswordConfig
+
+	// Password reuse and rotation
+	PasswordPolicy PasswordPolicyConfig
+
 	// API info
 	DocsURL       string
 	SupportEmail  string
@@ -44,6 +95,143 @@ type RateLimitConfig struct {
 	PerUser int
 }
 
+// JWTConfig holds token signing configuration
+type JWTConfig struct {
+	SigningAlgorithm    string        // HS256, RS256, ES256 or EdDSA
+	PrivateKeyFile      string        // PEM key shared by every replica; required unless HS256
+	RetiringKeyFiles    []string      // PEM keys replaced by PrivateKeyFile that still verify tokens
+	KeyRetirementPeriod time.Duration // How long rotated keys keep verifying tokens
+}
+
+// MailConfig holds outgoing email configuration
+type MailConfig struct {
+	Driver       string // "file" writes messages to Dir; "smtp" delivers them
+	From         string
+	Dir          string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// EmailVerificationConfig holds the policy applied to unverified accounts
+type EmailVerificationConfig struct {
+	RequireForLogin       bool
+	RestrictedPermissions []string // Withheld until the email is verified
+	TokenExpiry           time.Duration
+}
+
+// OIDCConfig holds OpenID Connect provider configuration
+type OIDCConfig struct {
+	Issuer                  string // Public base URL; the discovery document is served below it
+	LoginURL                string // Sign-in page that resumes authorization requests
+	AuthorizationCodeExpiry time.Duration
+	IntrospectionCacheTTL   time.Duration // How long verified token claims are reused by introspection; 0 disables the cache
+}
+
+// FederationConfig holds upstream identity provider configuration
+type FederationConfig struct {
+	ProvidersFile   string        // JSON array of providers; takes precedence over ProvidersJSON
+	ProvidersJSON   string        // JSON array of providers, inline
+	CallbackBaseURL string        // Provider callback URLs are CallbackBaseURL/{id}/callback
+	StateExpiry     time.Duration // How long a sign-in at a provider may take
+}
+
+// APIKeyConfig holds API key limits
+type APIKeyConfig struct {
+	MaxKeysPerOwner int           // Zero means unlimited
+	MaxLifetime     time.Duration // Zero allows keys that never expire
+}
+
+// WebAuthnConfig holds WebAuthn relying party configuration
+type WebAuthnConfig struct {
+	RPID    string   // Domain credentials are scoped to
+	RPName  string   // Name shown by authenticators
+	Origins []string // Pages allowed to run ceremonies, e.g. https://app.example.com
+	Timeout time.Duration
+}
+
+// MagicLinkConfig holds passwordless sign-in link configuration
+type MagicLinkConfig struct {
+	Enabled bool          // Sign-in links are opt-in
+	URL     string        // Page that redeems links; the token is appended as ?token=
+	Expiry  time.Duration // How long a link stays valid
+}
+
+// LockoutConfig holds the account lockout and sign-in throttling policy
+type LockoutConfig struct {
+	AccountMaxFailures  int           // Failed sign-ins before an account is locked; zero disables locking
+	AccountLockout      time.Duration // First lockout; doubles with every further lock
+	MaxAccountLockout   time.Duration
+	IPMaxFailures       int           // Failed sign-ins per address per window; needs Redis
+	IPWindow            time.Duration // Also the first block; doubles with every further block
+	MaxIPWindow         time.Duration
+	StuffingMaxAccounts int // Different accounts an address may fail against per window
+	StuffingWindow      time.Duration
+	StuffingBlock       time.Duration // How long a credential stuffing address is blocked
+}
+
+// RiskConfig holds the risk-based sign-in policy
+type RiskConfig struct {
+	Enabled         bool
+	GeoIPCityDB     string // MaxMind City database; enables travel checks
+	GeoIPASNDB      string // MaxMind ASN database; lets a known network cover new addresses
+	StepUpThreshold int    // Score at which a second factor is required
+	BlockThreshold  int    // Score at which the sign-in is refused
+}
+
+// TrustedDeviceConfig holds the "remember this device" policy
+type TrustedDeviceConfig struct {
+	TrustDays         int // How long a remembered device skips MFA; zero disables remembering
+	MaxDevicesPerUser int // Zero means unlimited
+}
+
+// SessionConfig holds the default session policy. Policies by billing plan and
+// by role override it; see services.SessionPolicies for how they combine.
+type SessionConfig struct {
+	IdleTimeout   time.Duration // Zero disables idle timeouts
+	SlidingExpiry time.Duration // How long a session survives without a token refresh
+	MaxLifetime   time.Duration // Zero means sessions last as long as they keep refreshing
+	MaxConcurrent int           // Zero means unlimited
+	OnLimit       string        // "evict_oldest" or "reject"
+	PoliciesFile  string        // JSON {"plans": {...}, "roles": {...}}; takes precedence over PoliciesJSON
+	PoliciesJSON  string        // The same, inline
+}
+
+// ImpersonationConfig holds the admin impersonation settings
+type ImpersonationConfig struct {
+	TokenTTL time.Duration // Impersonation tokens cannot be refreshed, so this caps each impersonation
+}
+
+// SAMLConfig holds SAML service provider settings
+type SAMLConfig struct {
+	BaseURL       string        // Public API URL; entity IDs and ACS URLs are BaseURL/v1/auth/saml/{orgId}/...
+	RequestExpiry time.Duration // How long an SP-initiated sign-in at the identity provider may take
+	ClockSkew     time.Duration // Clock difference tolerated when checking assertion validity windows
+}
+
+// SCIMConfig holds SCIM provisioning settings
+type SCIMConfig struct {
+	BaseURL    string // Public API URL; resource locations are BaseURL/scim/v2/...
+	MaxResults int    // Largest page a list request returns
+}
+
+// BreachedPasswordConfig holds the pwned-password corpora new passwords are checked against
+type BreachedPasswordConfig struct {
+	RangeFile   string        // HASH:COUNT file ordered by SHA-1 hash, searched on disk
+	BloomFilter string        // Compact filter built from such a file; has no counts
+	APIURL      string        // k-anonymity range API; only hash prefixes are sent
+	APITimeout  time.Duration // How long a range request may take before the check is skipped
+}
+
+// PasswordPolicyConfig holds the password reuse and rotation policy
+type PasswordPolicyConfig struct {
+	HistoryDepth      int           // Previous passwords that cannot be reused; 0 disables the check
+	MaxAge            time.Duration // Passwords older than this must be changed at the next sign-in; 0 disables expiry
+	WarningPeriod     time.Duration // How long before expiry users are warned by email
+	ChangePasswordURL string        // Page linked from expiry warnings
+}
+
 // MetricsConfig holds metrics configuration
 type MetricsConfig struct {
 	Enabled bool
@@ -0,0 +1,29 @@
+package apikey
+
+import "errors"
+
+var (
+	// ErrAPIKeyNotFound is returned when an API key does not exist
+	ErrAPIKeyNotFound = errors.New("api key not found")
+
+	// ErrInvalidAPIKey is returned when a presented key is unknown, malformed, revoked or expired
+	ErrInvalidAPIKey = errors.New("invalid api key")
+
+	// ErrIPNotAllowed is returned when a key is used from an address outside its allowlist
+	ErrIPNotAllowed = errors.New("api key is not allowed from this address")
+
+	// ErrInvalidScope is returned when a scope is not a resource:action permission
+	ErrInvalidScope = errors.New("invalid api key scope")
+
+	// ErrScopeNotGrantable is returned when a key would carry a permission its creator does not hold
+	ErrScopeNotGrantable = errors.New("cannot grant a scope you do not hold")
+
+	// ErrInvalidAllowedIP is returned when an allowlist entry is not an IP address or CIDR range
+	ErrInvalidAllowedIP = errors.New("invalid ip allowlist entry")
+
+	// ErrInvalidExpiry is returned when a key's expiry is in the past or beyond the maximum lifetime
+	ErrInvalidExpiry = errors.New("invalid api key expiry")
+
+	// ErrTooManyKeys is returned when an owner already has the maximum number of active keys
+	ErrTooManyKeys = errors.New("api key limit reached")
+)
@@ -0,0 +1,32 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Repository defines the interface for API key persistence
+type Repository interface {
+	// Create stores a new API key
+	Create(ctx context.Context, key *APIKey) error
+
+	// GetByID retrieves an API key by ID
+	GetByID(ctx context.Context, id uuid.UUID) (*APIKey, error)
+
+	// GetByHash retrieves an API key by the hash of its secret
+	GetByHash(ctx context.Context, keyHash string) (*APIKey, error)
+
+	// ListByOwner returns every key belonging to an owner, newest first
+	ListByOwner(ctx context.Context, ownerType OwnerType, ownerID uuid.UUID) ([]*APIKey, error)
+
+	// CountActiveByOwner counts an owner's keys that are neither revoked nor expired
+	CountActiveByOwner(ctx context.Context, ownerType OwnerType, ownerID uuid.UUID) (int, error)
+
+	// Revoke marks a key as revoked
+	Revoke(ctx context.Context, id uuid.UUID, revokedAt time.Time) error
+
+	// UpdateLastUsed records when and from where a key was last used
+	UpdateLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time, ip string) error
+}
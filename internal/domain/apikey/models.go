@@ -0,0 +1,114 @@
+package apikey
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/rbac"
+)
+
+// OwnerType identifies what kind of principal owns an API key
+type OwnerType string
+
+const (
+	OwnerUser         OwnerType = "user"
+	OwnerOrganization OwnerType = "organization"
+)
+
+// APIKey is a long-lived credential for scripts and CI.
+// Only a hash of the secret is stored; Prefix is kept so users can tell keys apart.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	OwnerType  OwnerType  `json:"owner_type"`
+	OwnerID    uuid.UUID  `json:"owner_id"`
+	CreatedBy  uuid.UUID  `json:"created_by"` // The user the key acts as
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`                // Permissions as resource:action pairs
+	AllowedIPs []string   `json:"allowed_ips,omitempty"` // IP addresses or CIDR ranges; empty allows any
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IsExpired checks if the key has passed its expiry
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
+
+// IsActive checks if the key can still be used
+func (k *APIKey) IsActive() bool {
+	return k.RevokedAt == nil && !k.IsExpired()
+}
+
+// AllowsIP checks if the key may be used from the given address
+func (k *APIKey) AllowsIP(ip string) bool {
+	if len(k.AllowedIPs) == 0 {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, entry := range k.AllowedIPs {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope checks if the key carries a permission
+func (k *APIKey) HasScope(permission string) bool {
+	for _, s := range k.Scopes {
+		if s == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateRequest represents a request to create an API key
+type CreateRequest struct {
+	Name       string     `json:"name" binding:"required,max=100"`
+	Scopes     []string   `json:"scopes" binding:"required,min=1"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// Scope returns the scope string for a permission
+func Scope(p *rbac.Permission) string {
+	return p.Resource + ":" + p.Action
+}
+
+// ParseScope splits a scope into its permission resource and action
+func ParseScope(scope string) (resource, action string, err error) {
+	resource, action, ok := strings.Cut(scope, ":")
+	if !ok || resource == "" || action == "" || strings.ContainsAny(scope, " \t") {
+		return "", "", ErrInvalidScope
+	}
+	return resource, action, nil
+}
+
+// ValidateAllowedIP checks that an allowlist entry is an IP address or CIDR range
+func ValidateAllowedIP(entry string) error {
+	if net.ParseIP(entry) != nil {
+		return nil
+	}
+	if _, _, err := net.ParseCIDR(entry); err == nil {
+		return nil
+	}
+	return ErrInvalidAllowedIP
+}
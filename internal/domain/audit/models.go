@@ -18,6 +18,11 @@ const (
 	EventTypeUserPasswordChanged EventType = "user.password_changed"
 	EventTypeUserMFAEnabled      EventType = "user.mfa_enabled"
 	EventTypeUserMFADisabled     EventType = "user.mfa_disabled"
+	EventTypeUserLocked          EventType = "user.locked"
+	EventTypeUserUnlocked        EventType = "user.unlocked"
+	EventTypeUserDeviceTrusted   EventType = "user.device_trusted"
+	EventTypeUserDeviceRevoked   EventType = "user.device_revoked"
+	EventTypeUserSessionRevoked  EventType = "user.session_revoked"
 
 	EventTypeRoleCreated  EventType = "role.created"
 	EventTypeRoleUpdated  EventType = "role.updated"
@@ -37,9 +42,31 @@ const (
 	EventTypePaymentFailed    EventType = "payment.failed"
 	EventTypePaymentRefunded  EventType = "payment.refunded"
 
-	EventTypeSecurityAlert EventType = "security.alert"
-	EventTypeAccessDenied  EventType = "access.denied"
-	EventTypeRateLimited   EventType = "rate.limited"
+	EventTypeServiceAccountCreated       EventType = "service_account.created"
+	EventTypeServiceAccountUpdated       EventType = "service_account.updated"
+	EventTypeServiceAccountDeleted       EventType = "service_account.deleted"
+	EventTypeServiceAccountSecretRotated EventType = "service_account.secret_rotated"
+	EventTypeServiceAccountTokenIssued   EventType = "service_account.token_issued"
+
+	EventTypeOrganizationCreated            EventType = "organization.created"
+	EventTypeOrganizationUpdated            EventType = "organization.updated"
+	EventTypeOrganizationDeleted            EventType = "organization.deleted"
+	EventTypeOrganizationMemberAdded        EventType = "organization.member_added"
+	EventTypeOrganizationMemberRoleChanged  EventType = "organization.member_role_changed"
+	EventTypeOrganizationMemberRemoved      EventType = "organization.member_removed"
+	EventTypeOrganizationInvitationSent     EventType = "organization.invitation_sent"
+	EventTypeOrganizationInvitationResent   EventType = "organization.invitation_resent"
+	EventTypeOrganizationInvitationRevoked  EventType = "organization.invitation_revoked"
+	EventTypeOrganizationInvitationAccepted EventType = "organization.invitation_accepted"
+
+	EventTypeImpersonationStarted EventType = "impersonation.started"
+	EventTypeImpersonationEnded   EventType = "impersonation.ended"
+	EventTypeImpersonatedRequest  EventType = "impersonation.request"
+
+	EventTypeSecurityAlert     EventType = "security.alert"
+	EventTypeLoginRiskAssessed EventType = "security.login_risk_assessed"
+	EventTypeAccessDenied      EventType = "access.denied"
+	EventTypeRateLimited       EventType = "rate.limited"
 )
 
 type Severity string
@@ -51,6 +78,20 @@ const (
 	SeverityCritical Severity = "critical"
 )
 
+// ActorType identifies whether an action was performed by a human or a machine
+type ActorType string
+
+const (
+	ActorTypeUser           ActorType = "user"
+	ActorTypeServiceAccount ActorType = "service_account"
+)
+
+// Actor is the principal performing an action
+type Actor struct {
+	ID   uuid.UUID
+	Type ActorType
+}
+
 type LogEntry struct {
 	ID          uuid.UUID              `json:"id"`
 	Timestamp   time.Time              `json:"timestamp"`
@@ -58,6 +99,7 @@ type LogEntry struct {
 	Severity    Severity               `json:"severity"`
 	UserID      *uuid.UUID             `json:"user_id,omitempty"`
 	ActorID     *uuid.UUID             `json:"actor_id,omitempty"`
+	ActorType   ActorType              `json:"actor_type,omitempty"`
 	EntityType  string                 `json:"entity_type"`
 	EntityID    string                 `json:"entity_id"`
 	Action      string                 `json:"action"`
@@ -81,6 +123,7 @@ type Changes struct {
 type LogFilter struct {
 	UserID     *uuid.UUID
 	ActorID    *uuid.UUID
+	ActorType  ActorType
 	EventTypes []EventType
 	Severities []Severity
 	EntityType string
@@ -119,6 +162,7 @@ type CreateLogRequest struct {
 	Severity    Severity               `json:"severity"`
 	UserID      *uuid.UUID             `json:"user_id,omitempty"`
 	ActorID     *uuid.UUID             `json:"actor_id,omitempty"`
+	ActorType   ActorType              `json:"actor_type,omitempty"`
 	EntityType  string                 `json:"entity_type"`
 	EntityID    string                 `json:"entity_id"`
 	Action      string                 `json:"action"`
@@ -12,6 +12,12 @@ var (
 	// ErrAccountLocked is returned when account is locked
 	ErrAccountLocked = errors.New("account is locked")
 
+	// ErrLoginThrottled is returned when a client has made too many failed sign-in attempts
+	ErrLoginThrottled = errors.New("too many failed sign-in attempts")
+
+	// ErrLoginBlocked is returned when a sign-in looks too risky to allow
+	ErrLoginBlocked = errors.New("sign-in blocked as unusually risky")
+
 	// ErrTokenExpired is returned when token has expired
 	ErrTokenExpired = errors.New("token has expired")
 
@@ -35,4 +41,55 @@ var (
 
 	// ErrInvalidMFACode is returned when MFA code is invalid
 	ErrInvalidMFACode = errors.New("invalid MFA code")
+
+	// ErrSigningKeyNotFound is returned when a token references an unknown key ID
+	ErrSigningKeyNotFound = errors.New("signing key not found")
+
+	// ErrNoActiveSigningKey is returned when the key ring has no active key
+	ErrNoActiveSigningKey = errors.New("no active signing key")
+
+	// ErrUnsupportedAlgorithm is returned when a signing algorithm is not supported
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+
+	// ErrTokenFamilyNotFound is returned when a refresh token family does not exist
+	ErrTokenFamilyNotFound = errors.New("token family not found")
+
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+
+	// ErrInvalidResetToken is returned when a password reset token is unknown, used or expired
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+	// ErrEmailNotVerified is returned when the email verification policy blocks an unverified account
+	ErrEmailNotVerified = errors.New("email address is not verified")
+
+	// ErrEmailAlreadyVerified is returned when verifying an address that is already verified
+	ErrEmailAlreadyVerified = errors.New("email address is already verified")
+
+	// ErrInvalidVerificationToken is returned when an email verification link is malformed, forged or expired
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+	// ErrInvalidMagicLink is returned when a sign-in link is unknown, used, expired or opened in another browser
+	ErrInvalidMagicLink = errors.New("invalid or expired sign-in link")
+
+	// ErrMagicLinkDisabled is returned when sign-in links are not enabled
+	ErrMagicLinkDisabled = errors.New("sign-in links are disabled")
+
+	// ErrVerificationThrottled is returned when verification emails are requested too often
+	ErrVerificationThrottled = errors.New("too many verification emails requested")
+
+	// ErrImpersonationNotAllowed is returned when an admin tries to impersonate themselves
+	ErrImpersonationNotAllowed = errors.New("impersonation is not allowed")
+
+	// ErrNotImpersonating is returned when ending impersonation with a token that is not an impersonation token
+	ErrNotImpersonating = errors.New("token is not an impersonation token")
+
+	// ErrPasswordReused is returned when a new password matches one the account used recently
+	ErrPasswordReused = errors.New("password was used recently")
+
+	// ErrPasswordExpired is returned when a password has outlived the maximum password age
+	ErrPasswordExpired = errors.New("password has expired")
+
+	// ErrPasswordNotExpired is returned when changing an expired password that has not expired
+	ErrPasswordNotExpired = errors.New("password has not expired")
 )
@@ -75,4 +75,62 @@ type TokenStore interface {
 
 	// DeleteAllForUser removes all tokens for a user
 	DeleteAllForUser(ctx context.Context, userID uuid.UUID) error
+
+	// SaveFamily creates or replaces a refresh token family
+	SaveFamily(ctx context.Context, family *TokenFamily) error
+
+	// GetFamily retrieves a refresh token family
+	GetFamily(ctx context.Context, familyID string) (*TokenFamily, error)
+
+	// RotateFamily atomically replaces the family's current refresh token ID.
+	// It returns ErrRefreshTokenReused if currentTokenID is no longer the latest token.
+	RotateFamily(ctx context.Context, familyID, currentTokenID, nextTokenID string, expiresAt time.Time) error
+
+	// RevokeFamily marks a refresh token family as revoked
+	RevokeFamily(ctx context.Context, familyID string) error
+
+	// RevokeUserFamilies marks every refresh token family of a user as revoked
+	RevokeUserFamilies(ctx context.Context, userID uuid.UUID) error
+}
+
+// MagicLinkRepository defines the interface for sign-in link persistence
+type MagicLinkRepository interface {
+	// Create stores a new link
+	Create(ctx context.Context, link *MagicLink) error
+
+	// GetByTokenHash retrieves a link by the hash of its token
+	GetByTokenHash(ctx context.Context, tokenHash string) (*MagicLink, error)
+
+	// Consume deletes a link, returning ErrInvalidMagicLink if it was already used
+	Consume(ctx context.Context, id uuid.UUID) error
+
+	// DeleteByUser deletes all outstanding links of a user
+	DeleteByUser(ctx context.Context, userID uuid.UUID) error
+
+	// DeleteExpired deletes links that can no longer be used
+	DeleteExpired(ctx context.Context) error
+}
+
+// PasswordHistoryRepository defines the interface for the hashes of previous passwords
+type PasswordHistoryRepository interface {
+	// Add stores the hash of a password the account no longer uses
+	Add(ctx context.Context, entry *PasswordHistoryEntry) error
+
+	// ListRecent returns up to limit of the account's previous hashes, newest first
+	ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]*PasswordHistoryEntry, error)
+
+	// Prune deletes all but the keep newest hashes of the account
+	Prune(ctx context.Context, userID uuid.UUID, keep int) error
+}
+
+// PasswordExpiryRepository finds accounts to warn about expiring passwords
+type PasswordExpiryRepository interface {
+	// ClaimUnwarned records a warning for up to limit active accounts whose password was
+	// set between from and to and who have not been warned about that password yet, and
+	// returns them. Concurrent callers never claim the same account.
+	ClaimUnwarned(ctx context.Context, from, to time.Time, limit int) ([]*PasswordExpiryNotice, error)
+
+	// ReleaseWarning forgets the warning claimed for the password set at setAt, so the
+	// account is claimed again by a later call
+	ReleaseWarning(ctx context.Context, userID uuid.UUID, setAt time.Time) error
 }
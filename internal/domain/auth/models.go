@@ -1,9 +1,13 @@
 package auth
 
 import (
+	"crypto"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+	"github.com/victoralfred/um_sys/internal/domain/webauthn"
 )
 
 // TokenType represents the type of token
@@ -14,6 +18,32 @@ const (
 	RefreshToken TokenType = "refresh"
 )
 
+// PrincipalType identifies the kind of principal a token was issued to
+type PrincipalType string
+
+const (
+	PrincipalUser           PrincipalType = "user"            // A human signing in interactively
+	PrincipalServiceAccount PrincipalType = "service_account" // A non-interactive machine client
+)
+
+// SigningAlgorithm represents a JWS algorithm used to sign tokens
+type SigningAlgorithm string
+
+const (
+	SigningAlgorithmHS256 SigningAlgorithm = "HS256"
+	SigningAlgorithmRS256 SigningAlgorithm = "RS256"
+	SigningAlgorithmES256 SigningAlgorithm = "ES256"
+	SigningAlgorithmEdDSA SigningAlgorithm = "EdDSA"
+)
+
+// KeyStatus represents the lifecycle state of a signing key
+type KeyStatus string
+
+const (
+	KeyStatusActive   KeyStatus = "active"   // Used to sign new tokens
+	KeyStatusRetiring KeyStatus = "retiring" // Only used to verify previously issued tokens
+)
+
 // TokenPair represents an access and refresh token pair
 type TokenPair struct {
 	AccessToken  string    `json:"access_token"`
@@ -21,29 +51,127 @@ type TokenPair struct {
 	TokenType    string    `json:"token_type"`
 	ExpiresIn    int       `json:"expires_in"`
 	ExpiresAt    time.Time `json:"expires_at"`
+	FamilyID     string    `json:"-"` // Refresh token family, used to bind the pair to a session
+
+	// RefreshExpiresAt is when the refresh token, and so the session, expires
+	RefreshExpiresAt time.Time `json:"-"`
+
+	// TrustedDeviceToken is set when the sign-in asked to remember the device;
+	// the handler stores it in a cookie
+	TrustedDeviceToken string `json:"-"`
 }
 
 // Claims represents JWT claims
 type Claims struct {
-	UserID    uuid.UUID `json:"user_id"`
-	Email     string    `json:"email"`
-	Username  string    `json:"username"`
-	Roles     []string  `json:"roles"`
-	TokenType TokenType `json:"token_type"`
-	ExpiresAt time.Time `json:"exp"`
-	IssuedAt  time.Time `json:"iat"`
-	NotBefore time.Time `json:"nbf"`
-	Subject   string    `json:"sub"`
-	Issuer    string    `json:"iss"`
-	Audience  []string  `json:"aud"`
-	JTI       string    `json:"jti"` // JWT ID for token revocation
+	UserID        uuid.UUID `json:"user_id"`
+	Email         string    `json:"email"`
+	Username      string    `json:"username"`
+	Roles         []string  `json:"roles"`
+	EmailVerified bool      `json:"email_verified"` // Lets middleware apply the verification policy without a user lookup
+	TokenType     TokenType `json:"token_type"`
+	ExpiresAt     time.Time `json:"exp"`
+	IssuedAt      time.Time `json:"iat"`
+	NotBefore     time.Time `json:"nbf"`
+	Subject       string    `json:"sub"`
+	Issuer        string    `json:"iss"`
+	Audience      []string  `json:"aud"`
+	JTI           string    `json:"jti"`           // JWT ID for token revocation
+	FamilyID      string    `json:"fid,omitempty"` // Refresh token family the token was issued from
+	ClientID      string    `json:"azp,omitempty"` // OAuth client the token was issued to, empty for first-party logins
+	Scopes        []string  `json:"scope,omitempty"`
+	// PrincipalType tells human users and service accounts apart; UserID holds the service account ID for the latter
+	PrincipalType PrincipalType `json:"principal_type"`
+	// SessionExpiresAt and IdleTimeout carry the session policy, so middleware can enforce it without a lookup
+	SessionExpiresAt time.Time     `json:"sexp,omitempty"`
+	IdleTimeout      time.Duration `json:"idle,omitempty"`
+	// ActorID is set on impersonation tokens to the admin acting as the user in UserID
+	ActorID *uuid.UUID `json:"act,omitempty"`
+	// OrganizationID is the organization the user is acting in and OrganizationRole
+	// their role there; both are empty when acting with their personal account
+	OrganizationID   *uuid.UUID `json:"org_id,omitempty"`
+	OrganizationRole string     `json:"org_role,omitempty"`
+}
+
+// IDTokenParams holds the request-specific values of an OpenID Connect ID token
+type IDTokenParams struct {
+	Issuer      string                 // Defaults to the token service issuer when empty
+	ClientID    string                 // Audience of the ID token
+	Nonce       string                 // Echoed from the authorization request
+	AuthTime    time.Time              // When the user authenticated
+	AccessToken string                 // Issued alongside the ID token; used to compute at_hash
+	Claims      map[string]interface{} // Additional user claims released by the granted scopes
+}
+
+// TokenFamily tracks the chain of refresh tokens issued from a single login.
+// Only the most recently issued refresh token may be redeemed; presenting an
+// older one means the token was stolen and replayed.
+type TokenFamily struct {
+	ID         string     `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	SessionID  string     `json:"session_id,omitempty"`
+	CurrentJTI string     `json:"current_jti"`
+	ClientID   string     `json:"client_id,omitempty"` // OAuth client the family was issued to
+	Scopes     []string   `json:"scopes,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	// OrganizationID is the active organization, carried over to every rotated token
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+}
+
+// IsRevoked checks if the family has been revoked
+func (f *TokenFamily) IsRevoked() bool {
+	return f.RevokedAt != nil
+}
+
+// EmailVerificationPolicy controls what accounts with an unverified email may do
+type EmailVerificationPolicy struct {
+	// RequireForLogin blocks sign-in until the email is verified
+	RequireForLogin bool
+
+	// RestrictedPermissions are withheld from unverified accounts, e.g. "billing:write"
+	RestrictedPermissions []string
+}
+
+// Allows checks if an account with the given verification state may use a permission
+func (p *EmailVerificationPolicy) Allows(emailVerified bool, permission string) bool {
+	if p == nil || emailVerified {
+		return true
+	}
+	for _, restricted := range p.RestrictedPermissions {
+		if restricted == permission {
+			return false
+		}
+	}
+	return true
 }
 
 // LoginRequest represents a login request
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required_without=Username,email"`
-	Username string `json:"username" binding:"required_without=Email"`
-	Password string `json:"password" binding:"required,min=8"`
+	Email    string `json:"email" binding:"required_without_all=Username Passkey,omitempty,email"`
+	Username string `json:"username" binding:"required_without_all=Email Passkey"`
+	Password string `json:"password" binding:"required_without=Passkey,omitempty,min=8"`
+
+	// Passkey signs in with a passkey instead of an identifier and password
+	Passkey *webauthn.FinishLoginRequest `json:"passkey,omitempty"`
+
+	// MFAMethod names the second factor answered by MFACode or, for security keys,
+	// by MFAAssertion, the answer to a challenge from BeginSecurityKeyChallenge
+	MFAMethod    mfa.Method                   `json:"mfa_method,omitempty" binding:"required_with=MFACode MFAAssertion"`
+	MFACode      string                       `json:"mfa_code,omitempty"`
+	MFAAssertion *webauthn.FinishLoginRequest `json:"mfa_assertion,omitempty"`
+
+	// RememberDevice asks to skip MFA on this device in future; DeviceToken is the
+	// trusted device cookie, which the handler sets
+	RememberDevice bool   `json:"remember_device,omitempty"`
+	DeviceToken    string `json:"-"`
+
+	// IPAddress and UserAgent describe the client; the handler sets them for
+	// sign-in throttling and risk scoring
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
 }
 
 // RegisterRequest represents a registration request
@@ -71,8 +199,123 @@ type PasswordResetConfirm struct {
 	NewPassword string `json:"new_password" binding:"required,min=8"`
 }
 
+// MagicLink is a one-time sign-in link sent by email. It only works in the browser
+// that requested it, which holds the nonce; only hashes of the token and nonce are stored.
+type MagicLink struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	TokenHash string    `json:"-"`
+	NonceHash string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IsExpired checks if the link has expired
+func (l *MagicLink) IsExpired() bool {
+	return time.Now().After(l.ExpiresAt)
+}
+
+// PasswordHistoryEntry is the hash of a password an account used before
+type PasswordHistoryEntry struct {
+	ID           uuid.UUID `json:"id"`
+	UserID       uuid.UUID `json:"user_id"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PasswordExpiryNotice identifies an account whose password expires soon
+type PasswordExpiryNotice struct {
+	UserID   uuid.UUID
+	Email    string
+	Username string
+	SetAt    time.Time // When the current password was set
+}
+
+// ExpiredPasswordChangeRequest replaces an expired password. The user has no
+// session yet, so it carries everything a sign-in would.
+type ExpiredPasswordChangeRequest struct {
+	LoginRequest
+	NewPassword string `json:"new_password" binding:"required,min=8,max=128"`
+}
+
+// MagicLinkRequest represents a request for a sign-in link
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// MagicLinkRedeemRequest represents a sign-in with a magic link
+type MagicLinkRedeemRequest struct {
+	Token string `json:"token" binding:"required"`
+
+	// MFAMethod and MFACode are the second factor for accounts with MFA enabled
+	MFAMethod mfa.Method `json:"mfa_method,omitempty" binding:"required_with=MFACode"`
+	MFACode   string     `json:"mfa_code,omitempty" binding:"required_with=MFAMethod"`
+
+	// The remaining fields work as for LoginRequest
+	RememberDevice bool   `json:"remember_device,omitempty"`
+	DeviceToken    string `json:"-"`
+	IPAddress      string `json:"-"`
+	UserAgent      string `json:"-"`
+}
+
 // ChangePasswordRequest represents a password change request
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
 	NewPassword string `json:"new_password" binding:"required,min=8"`
 }
+
+// SigningKey represents an asymmetric key pair used to sign and verify tokens
+type SigningKey struct {
+	ID         string           `json:"kid"`
+	Algorithm  SigningAlgorithm `json:"alg"`
+	Status     KeyStatus        `json:"status"`
+	PrivateKey crypto.Signer    `json:"-"`
+	PublicKey  crypto.PublicKey `json:"-"`
+	CreatedAt  time.Time        `json:"created_at"`
+	RetiresAt  *time.Time       `json:"retires_at,omitempty"`
+}
+
+// JWK represents a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // EC / OKP curve
+	X   string `json:"x,omitempty"`   // EC / OKP x coordinate
+	Y   string `json:"y,omitempty"`   // EC y coordinate
+}
+
+// JWKS represents a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// ImpersonationRequest represents an admin starting to act as another user
+type ImpersonationRequest struct {
+	// Reason is recorded in the audit log, e.g. a support ticket reference
+	Reason string `json:"reason" binding:"required,max=500"`
+
+	ActorID   uuid.UUID `json:"-"`
+	UserID    uuid.UUID `json:"-"`
+	IPAddress string    `json:"-"`
+	UserAgent string    `json:"-"`
+}
+
+// PasswordHashReport counts accounts by the algorithm and cost of their password hash
+type PasswordHashReport struct {
+	Total      int64               `json:"total"`
+	Outdated   int64               `json:"outdated"`
+	Preferred  string              `json:"preferred"`
+	Algorithms []PasswordHashCount `json:"algorithms"`
+}
+
+// PasswordHashCount is the number of accounts whose hash uses one algorithm and cost
+type PasswordHashCount struct {
+	Algorithm  string `json:"algorithm"`
+	Parameters string `json:"parameters,omitempty"`
+	Current    bool   `json:"current"`
+	Count      int64  `json:"count"`
+}
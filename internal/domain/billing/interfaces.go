@@ -46,6 +46,12 @@ type SubscriptionRepository interface {
 	// GetActiveByUserID retrieves the active subscription for a user
 	GetActiveByUserID(ctx context.Context, userID uuid.UUID) (*Subscription, error)
 
+	// GetByOrganizationID retrieves subscriptions for an organization
+	GetByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]*Subscription, error)
+
+	// GetActiveByOrganizationID retrieves the active subscription for an organization
+	GetActiveByOrganizationID(ctx context.Context, organizationID uuid.UUID) (*Subscription, error)
+
 	// Update updates a subscription
 	Update(ctx context.Context, subscription *Subscription) error
 
@@ -171,6 +177,8 @@ type BillingService interface {
 	GetSubscription(ctx context.Context, subscriptionID uuid.UUID) (*Subscription, error)
 	GetUserSubscriptions(ctx context.Context, userID uuid.UUID) ([]*Subscription, error)
 	GetCurrentSubscription(ctx context.Context, userID uuid.UUID) (*Subscription, error)
+	GetOrganizationSubscriptions(ctx context.Context, organizationID uuid.UUID) ([]*Subscription, error)
+	GetCurrentOrganizationSubscription(ctx context.Context, organizationID uuid.UUID) (*Subscription, error)
 
 	// Plan management
 	CreatePlan(ctx context.Context, plan *Plan) error
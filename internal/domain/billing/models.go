@@ -104,6 +104,10 @@ type Subscription struct {
 	Metadata             map[string]string  `json:"metadata,omitempty"`
 	CreatedAt            time.Time          `json:"created_at"`
 	UpdatedAt            time.Time          `json:"updated_at"`
+
+	// OrganizationID is set when the subscription belongs to an organization
+	// rather than to UserID, who then only manages it
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
 }
 
 // Payment represents a payment transaction
@@ -219,6 +223,9 @@ type CreateSubscriptionRequest struct {
 	CouponCode      string            `json:"coupon_code,omitempty"`
 	TrialDays       int               `json:"trial_days,omitempty"`
 	Metadata        map[string]string `json:"metadata,omitempty"`
+
+	// OrganizationID subscribes an organization instead of the user
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
 }
 
 // UpdateSubscriptionRequest represents a request to update a subscription
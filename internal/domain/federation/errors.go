@@ -0,0 +1,35 @@
+package federation
+
+import "errors"
+
+var (
+	// ErrProviderNotFound is returned when an identity provider is not configured
+	ErrProviderNotFound = errors.New("identity provider not found")
+
+	// ErrInvalidState is returned when the authorization state is missing, forged or expired
+	ErrInvalidState = errors.New("invalid or expired authorization state")
+
+	// ErrUpstreamAuthFailed is returned when the upstream provider rejects or fails the authorization
+	ErrUpstreamAuthFailed = errors.New("upstream authentication failed")
+
+	// ErrInvalidIDToken is returned when an upstream ID token fails verification
+	ErrInvalidIDToken = errors.New("invalid upstream id token")
+
+	// ErrIdentityNotFound is returned when a linked identity does not exist
+	ErrIdentityNotFound = errors.New("linked identity not found")
+
+	// ErrIdentityAlreadyLinked is returned when an external identity belongs to another account
+	ErrIdentityAlreadyLinked = errors.New("identity is already linked to another account")
+
+	// ErrEmailNotVerified is returned when an unverified upstream email matches an existing account
+	ErrEmailNotVerified = errors.New("upstream email address is not verified")
+
+	// ErrEmailMissing is returned when an account must be created but the provider released no email address
+	ErrEmailMissing = errors.New("upstream identity has no email address")
+
+	// ErrAccountExists is returned when an account with the upstream email exists but cannot be linked automatically
+	ErrAccountExists = errors.New("an account with this email already exists; sign in and link the identity from your profile")
+
+	// ErrSignupDisabled is returned when an unknown identity signs in through a provider without signup
+	ErrSignupDisabled = errors.New("signup is disabled for this identity provider")
+)
@@ -0,0 +1,35 @@
+package federation
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdentityProvider defines the interface for talking to an upstream OpenID Connect provider
+type IdentityProvider interface {
+	// AuthCodeURL returns the URL that starts an authorization code flow at the provider
+	AuthCodeURL(state, nonce, codeChallenge string) string
+
+	// Exchange redeems an authorization code and returns the claims of the verified ID token
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*ExternalClaims, error)
+}
+
+// IdentityRepository defines the interface for linked identity persistence
+type IdentityRepository interface {
+	// Create links a new external identity
+	Create(ctx context.Context, identity *Identity) error
+
+	// GetByProviderSubject retrieves the identity with the given subject at a provider
+	GetByProviderSubject(ctx context.Context, providerID, subject string) (*Identity, error)
+
+	// ListByUser returns every identity linked to a user
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*Identity, error)
+
+	// Delete unlinks an identity from a user
+	Delete(ctx context.Context, userID, identityID uuid.UUID) error
+
+	// UpdateLastLogin records a sign-in through an identity
+	UpdateLastLogin(ctx context.Context, identityID uuid.UUID, loginTime time.Time) error
+}
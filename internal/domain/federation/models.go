@@ -0,0 +1,69 @@
+package federation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+)
+
+// Provider describes an upstream OpenID Connect identity provider
+type Provider struct {
+	ID           string   `json:"id"` // URL-safe slug, e.g. "acme-okta"
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"-"`
+	Scopes       []string `json:"scopes,omitempty"`
+	RedirectURL  string   `json:"redirect_url,omitempty"` // Our callback URL as registered at the provider
+
+	// AllowSignup creates an account the first time an unknown identity signs in
+	AllowSignup bool `json:"allow_signup"`
+
+	// LinkByEmail attaches a first sign-in to the existing account with the same
+	// email address, provided the provider reports the address as verified
+	LinkByEmail bool `json:"link_by_email"`
+}
+
+// ExternalClaims are the verified claims about a user from an upstream ID token
+type ExternalClaims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name,omitempty"`
+	GivenName         string `json:"given_name,omitempty"`
+	FamilyName        string `json:"family_name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+}
+
+// Identity links a local user to an account at an upstream provider
+type Identity struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	ProviderID  string     `json:"provider_id"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email,omitempty"`
+	LinkedAt    time.Time  `json:"linked_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// AuthorizationState is carried through the round trip to the upstream provider
+type AuthorizationState struct {
+	ProviderID   string     `json:"pid"`
+	State        string     `json:"st"`
+	Nonce        string     `json:"n"`
+	CodeVerifier string     `json:"cv"`
+	LinkUserID   *uuid.UUID `json:"lu,omitempty"` // Set when a signed-in user is linking another identity
+	ExpiresAt    time.Time  `json:"exp"`
+}
+
+// Result is the outcome of a completed upstream authorization
+type Result struct {
+	User      *user.User
+	Identity  *Identity
+	TokenPair *auth.TokenPair // Nil when an identity was linked to an already signed-in user
+	Created   bool            // A new account was created for the identity
+	Linked    bool            // The identity was newly linked to an account
+}
@@ -0,0 +1,11 @@
+package mail
+
+import "errors"
+
+var (
+	// ErrNoRecipients is returned when a message has no recipients
+	ErrNoRecipients = errors.New("message has no recipients")
+
+	// ErrInvalidAddress is returned when a recipient address is malformed
+	ErrInvalidAddress = errors.New("invalid email address")
+)
@@ -0,0 +1,9 @@
+package mail
+
+import "context"
+
+// Sender defines the interface for delivering email
+type Sender interface {
+	// Send delivers a message to its recipients
+	Send(ctx context.Context, msg *Message) error
+}
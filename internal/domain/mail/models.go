@@ -0,0 +1,9 @@
+package mail
+
+// Message represents an outgoing email
+type Message struct {
+	To       []string `json:"to"`
+	Subject  string   `json:"subject"`
+	TextBody string   `json:"text_body"`
+	HTMLBody string   `json:"html_body,omitempty"`
+}
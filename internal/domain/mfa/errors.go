@@ -71,4 +71,10 @@ var (
 
 	// ErrSettingsNotFound is returned when MFA settings are not found
 	ErrSettingsNotFound = errors.New("MFA settings not found")
+
+	// ErrTrustedDeviceNotFound is returned when a trusted device does not exist
+	ErrTrustedDeviceNotFound = errors.New("trusted device not found")
+
+	// ErrDeviceNotTrusted is returned when a device token is invalid, expired or revoked
+	ErrDeviceNotTrusted = errors.New("device is not trusted")
 )
@@ -49,6 +49,30 @@ type Repository interface {
 	GetAuditLogs(ctx context.Context, userID uuid.UUID, limit int) ([]*AuditLog, error)
 }
 
+// TrustedDeviceRepository defines the interface for trusted device persistence
+type TrustedDeviceRepository interface {
+	// Create stores a newly trusted device
+	Create(ctx context.Context, device *TrustedDevice) error
+
+	// GetByID retrieves a trusted device
+	GetByID(ctx context.Context, id uuid.UUID) (*TrustedDevice, error)
+
+	// ListByUser returns every device a user has trusted, newest first
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*TrustedDevice, error)
+
+	// UpdateLastUsed records a sign-in from the device
+	UpdateLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+
+	// Delete revokes one of a user's devices
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+
+	// DeleteByUser revokes every device a user has trusted
+	DeleteByUser(ctx context.Context, userID uuid.UUID) error
+
+	// DeleteExpired removes devices whose trust has lapsed
+	DeleteExpired(ctx context.Context) error
+}
+
 // Service defines the interface for MFA operations
 type Service interface {
 	// SetupMFA initiates MFA setup for a user
@@ -14,6 +14,7 @@ const (
 	MethodSMS        Method = "sms"         // SMS verification
 	MethodEmail      Method = "email"       // Email verification
 	MethodBackupCode Method = "backup_code" // Backup codes
+	MethodWebAuthn   Method = "webauthn"    // Security keys and passkeys
 )
 
 // Status represents the MFA status
@@ -114,6 +115,23 @@ type Challenge struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// TrustedDevice is a browser the user chose to remember after passing a second factor.
+// Sign-ins from it skip MFA until it expires or is revoked.
+type TrustedDevice struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	IPAddress  string     `json:"ip_address"` // Where the device was trusted from
+	UserAgent  string     `json:"user_agent"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IsExpired reports whether the device is no longer trusted
+func (d *TrustedDevice) IsExpired() bool {
+	return time.Now().After(d.ExpiresAt)
+}
+
 // AuditLog represents an MFA audit log entry
 type AuditLog struct {
 	ID        uuid.UUID `json:"id"`
@@ -0,0 +1,50 @@
+package oauth
+
+import "errors"
+
+var (
+	// ErrClientNotFound is returned when a client ID is not registered
+	ErrClientNotFound = errors.New("oauth client not found")
+
+	// ErrInvalidClient is returned when client authentication fails
+	ErrInvalidClient = errors.New("invalid client credentials")
+
+	// ErrInvalidRedirectURI is returned when a redirect URI is malformed or not registered for the client
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+
+	// ErrUnsupportedResponseType is returned for any response type other than "code"
+	ErrUnsupportedResponseType = errors.New("unsupported response type")
+
+	// ErrUnsupportedGrantType is returned when the token endpoint receives an unknown grant type
+	ErrUnsupportedGrantType = errors.New("unsupported grant type")
+
+	// ErrInvalidScope is returned when a requested scope is unknown or not allowed for the client
+	ErrInvalidScope = errors.New("invalid scope")
+
+	// ErrPKCERequired is returned when an authorization request has no S256 code challenge
+	ErrPKCERequired = errors.New("code_challenge with method S256 is required")
+
+	// ErrUnauthorizedClient is returned when a client presents a token that was issued to another client
+	ErrUnauthorizedClient = errors.New("token was not issued to this client")
+
+	// ErrInvalidGrant is returned when an authorization code or refresh token cannot be redeemed
+	ErrInvalidGrant = errors.New("invalid grant")
+
+	// ErrConsentRequired is returned when the user has not yet granted the requested scopes
+	ErrConsentRequired = errors.New("user consent required")
+
+	// ErrAccessDenied is returned when the user declines the authorization request
+	ErrAccessDenied = errors.New("access denied by user")
+
+	// ErrInsufficientScope is returned when an access token lacks the scope an endpoint requires
+	ErrInsufficientScope = errors.New("insufficient scope")
+
+	// ErrAuthorizationCodeNotFound is returned when an authorization code does not exist
+	ErrAuthorizationCodeNotFound = errors.New("authorization code not found")
+
+	// ErrAuthorizationCodeUsed is returned when an authorization code has already been redeemed
+	ErrAuthorizationCodeUsed = errors.New("authorization code already used")
+
+	// ErrConsentNotFound is returned when a user has not granted consent to a client
+	ErrConsentNotFound = errors.New("consent not found")
+)
@@ -0,0 +1,50 @@
+package oauth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// ClientRepository defines the interface for OAuth client persistence
+type ClientRepository interface {
+	// Create registers a new client
+	Create(ctx context.Context, client *Client) error
+
+	// GetByID retrieves a client by its client ID
+	GetByID(ctx context.Context, clientID string) (*Client, error)
+
+	// List returns all registered clients
+	List(ctx context.Context) ([]*Client, error)
+
+	// Delete removes a client
+	Delete(ctx context.Context, clientID string) error
+}
+
+// AuthorizationCodeRepository defines the interface for authorization code persistence
+type AuthorizationCodeRepository interface {
+	// Create stores a newly issued authorization code
+	Create(ctx context.Context, code *AuthorizationCode) error
+
+	// Consume atomically marks an unused code as used and returns it.
+	// If the code was already redeemed it returns the code together with ErrAuthorizationCodeUsed.
+	Consume(ctx context.Context, codeHash string) (*AuthorizationCode, error)
+
+	// SetFamily records the token family issued when a code was redeemed
+	SetFamily(ctx context.Context, codeHash, familyID string) error
+}
+
+// ConsentRepository defines the interface for consent record persistence
+type ConsentRepository interface {
+	// Get retrieves the consent a user has granted to a client
+	Get(ctx context.Context, userID uuid.UUID, clientID string) (*Consent, error)
+
+	// Save creates or replaces a consent record
+	Save(ctx context.Context, consent *Consent) error
+
+	// ListByUser returns every consent a user has granted
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*Consent, error)
+
+	// Delete removes the consent a user has granted to a client
+	Delete(ctx context.Context, userID uuid.UUID, clientID string) error
+}
@@ -0,0 +1,224 @@
+package oauth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Standard OpenID Connect scopes
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access"
+)
+
+// SupportedScopes lists every scope a client may be allowed to request
+var SupportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess}
+
+// Grant types accepted by the token endpoint
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials" // Only available to service accounts
+)
+
+const (
+	// ResponseTypeCode is the only response type supported; OAuth 2.1 drops the implicit flow
+	ResponseTypeCode = "code"
+
+	// CodeChallengeMethodS256 is the only PKCE method supported; "plain" is not accepted
+	CodeChallengeMethodS256 = "S256"
+)
+
+// Client represents a registered OAuth client (relying party)
+type Client struct {
+	ID            string    `json:"client_id"`
+	Name          string    `json:"name"`
+	SecretHash    string    `json:"-"`
+	RedirectURIs  []string  `json:"redirect_uris"`
+	AllowedScopes []string  `json:"allowed_scopes"`
+	Public        bool      `json:"public"`         // Public clients cannot keep a secret and rely on PKCE alone
+	FirstParty    bool      `json:"first_party"`    // First-party clients are trusted and skip the consent prompt
+	CanIntrospect bool      `json:"can_introspect"` // Resource servers may introspect tokens issued to any client
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// HasRedirectURI checks if uri exactly matches a registered redirect URI
+func (c *Client) HasRedirectURI(uri string) bool {
+	for _, registered := range c.RedirectURIs {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsScopes checks if the client may request every one of the given scopes
+func (c *Client) AllowsScopes(scopes []string) bool {
+	return containsAll(c.AllowedScopes, scopes)
+}
+
+// RegisterClientRequest represents a client registration request
+type RegisterClientRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	RedirectURIs  []string `json:"redirect_uris" binding:"required,min=1"`
+	AllowedScopes []string `json:"allowed_scopes"`
+	Public        bool     `json:"public"`
+	FirstParty    bool     `json:"first_party"`
+	CanIntrospect bool     `json:"can_introspect"`
+}
+
+// AuthorizationRequest represents a request to the authorization endpoint
+type AuthorizationRequest struct {
+	ClientID            string `json:"client_id" form:"client_id"`
+	RedirectURI         string `json:"redirect_uri" form:"redirect_uri"`
+	ResponseType        string `json:"response_type" form:"response_type"`
+	Scope               string `json:"scope" form:"scope"`
+	State               string `json:"state" form:"state"`
+	Nonce               string `json:"nonce" form:"nonce"`
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method"`
+}
+
+// Scopes returns the requested scopes
+func (r *AuthorizationRequest) Scopes() []string {
+	return ParseScope(r.Scope)
+}
+
+// AuthorizationCode represents an issued authorization code.
+// Only a hash of the code is stored.
+type AuthorizationCode struct {
+	CodeHash            string     `json:"-"`
+	ClientID            string     `json:"client_id"`
+	UserID              uuid.UUID  `json:"user_id"`
+	RedirectURI         string     `json:"redirect_uri"`
+	Scopes              []string   `json:"scopes"`
+	Nonce               string     `json:"nonce,omitempty"`
+	CodeChallenge       string     `json:"code_challenge"`
+	CodeChallengeMethod string     `json:"code_challenge_method"`
+	AuthTime            time.Time  `json:"auth_time"`
+	FamilyID            string     `json:"family_id,omitempty"` // Token family issued when the code was redeemed
+	ExpiresAt           time.Time  `json:"expires_at"`
+	UsedAt              *time.Time `json:"used_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// IsExpired checks if the code can no longer be redeemed
+func (c *AuthorizationCode) IsExpired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
+
+// Consent records the scopes a user has granted to a client
+type Consent struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ClientID  string    `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	GrantedAt time.Time `json:"granted_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Covers checks if the consent already grants every one of the given scopes
+func (c *Consent) Covers(scopes []string) bool {
+	return containsAll(c.Scopes, scopes)
+}
+
+// TokenRequest represents a request to the token endpoint
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	ClientID     string
+	ClientSecret string
+}
+
+// TokenResponse represents a successful token endpoint response (RFC 6749 section 5.1)
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// Token type hints accepted by the introspection and revocation endpoints (RFC 7009 section 2.1)
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// IntrospectionResponse describes a token to a resource server (RFC 7662 section 2.2).
+// Only Active is set for tokens that are invalid, expired or revoked.
+type IntrospectionResponse struct {
+	Active        bool     `json:"active"`
+	Scope         string   `json:"scope,omitempty"`
+	ClientID      string   `json:"client_id,omitempty"`
+	Username      string   `json:"username,omitempty"`
+	TokenType     string   `json:"token_type,omitempty"`
+	ExpiresAt     int64    `json:"exp,omitempty"`
+	IssuedAt      int64    `json:"iat,omitempty"`
+	NotBefore     int64    `json:"nbf,omitempty"`
+	Subject       string   `json:"sub,omitempty"`
+	Audience      []string `json:"aud,omitempty"`
+	Issuer        string   `json:"iss,omitempty"`
+	JTI           string   `json:"jti,omitempty"`
+	PrincipalType string   `json:"principal_type,omitempty"`
+}
+
+// DiscoveryDocument represents OpenID Provider metadata (OpenID Connect Discovery 1.0)
+type DiscoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// ParseScope splits a space-delimited scope parameter, dropping duplicates
+func ParseScope(scope string) []string {
+	var scopes []string
+	seen := make(map[string]bool)
+	for _, s := range strings.Fields(scope) {
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// HasScope checks if scopes contains scope
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAll(granted, requested []string) bool {
+	for _, s := range requested {
+		if !HasScope(granted, s) {
+			return false
+		}
+	}
+	return true
+}
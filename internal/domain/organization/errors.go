@@ -0,0 +1,50 @@
+package organization
+
+import "errors"
+
+var (
+	// ErrOrganizationNotFound is returned when an organization does not exist
+	ErrOrganizationNotFound = errors.New("organization not found")
+
+	// ErrSlugTaken is returned when another organization already uses a slug
+	ErrSlugTaken = errors.New("organization slug already taken")
+
+	// ErrInvalidSlug is returned when a slug is not lowercase letters, digits and hyphens
+	ErrInvalidSlug = errors.New("invalid organization slug")
+
+	// ErrNotMember is returned when a user does not belong to an organization
+	ErrNotMember = errors.New("user is not a member of the organization")
+
+	// ErrAlreadyMember is returned when adding a user who already belongs to an organization
+	ErrAlreadyMember = errors.New("user is already a member of the organization")
+
+	// ErrInvalidRole is returned when a role is not one of the organization roles
+	ErrInvalidRole = errors.New("invalid organization role")
+
+	// ErrInsufficientRole is returned when a member's role does not allow an action
+	ErrInsufficientRole = errors.New("organization role does not allow this action")
+
+	// ErrLastOwner is returned when an action would leave an organization without an owner
+	ErrLastOwner = errors.New("organization must keep at least one owner")
+
+	// ErrInvitationNotFound is returned when an invitation does not exist
+	ErrInvitationNotFound = errors.New("invitation not found")
+
+	// ErrInvalidInvitation is returned when an invitation token is malformed, expired or no longer open
+	ErrInvalidInvitation = errors.New("invalid or expired invitation")
+
+	// ErrInvitationNotPending is returned when resending or revoking an accepted or revoked invitation
+	ErrInvitationNotPending = errors.New("invitation is no longer pending")
+
+	// ErrInvitationPending is returned when inviting an address that already has an open invitation
+	ErrInvitationPending = errors.New("an invitation to this address is already pending")
+
+	// ErrInvitationEmailMismatch is returned when accepting an invitation sent to another address
+	ErrInvitationEmailMismatch = errors.New("invitation was sent to a different email address")
+
+	// ErrInvitationRequiresSignIn is returned when creating an account for an address that already has one
+	ErrInvitationRequiresSignIn = errors.New("an account with this email exists; sign in to accept the invitation")
+
+	// ErrSeatLimitReached is returned when an organization's plan has no seats left
+	ErrSeatLimitReached = errors.New("organization has no seats left on its plan")
+)
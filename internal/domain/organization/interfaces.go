@@ -0,0 +1,86 @@
+package organization
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Repository defines the interface for organization persistence
+type Repository interface {
+	// Create stores a new organization
+	Create(ctx context.Context, org *Organization) error
+
+	// GetByID retrieves an organization by ID
+	GetByID(ctx context.Context, id uuid.UUID) (*Organization, error)
+
+	// Update saves an organization's name and slug
+	Update(ctx context.Context, org *Organization) error
+
+	// Delete removes an organization and its memberships
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// MembershipRepository defines the interface for organization membership persistence
+type MembershipRepository interface {
+	// Add stores a new membership
+	Add(ctx context.Context, membership *Membership) error
+
+	// AddWithinSeatLimit stores a new membership, or returns ErrSeatLimitReached if the
+	// organization's members, and its open invitations when countInvitations is set,
+	// already take seatLimit seats. Seat-limited writes to one organization are made
+	// one at a time, so concurrent ones cannot overfill it. Zero means no limit.
+	AddWithinSeatLimit(ctx context.Context, membership *Membership, seatLimit int, countInvitations bool) error
+
+	// Get retrieves a user's membership of an organization
+	Get(ctx context.Context, organizationID, userID uuid.UUID) (*Membership, error)
+
+	// ListByOrganization returns an organization's memberships, oldest first
+	ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*Membership, error)
+
+	// ListByUser returns a user's memberships with their organizations loaded
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*Membership, error)
+
+	// UpdateRole changes a member's role
+	UpdateRole(ctx context.Context, organizationID, userID uuid.UUID, role Role) error
+
+	// Remove deletes a membership
+	Remove(ctx context.Context, organizationID, userID uuid.UUID) error
+
+	// CountByRole counts an organization's members holding a role
+	CountByRole(ctx context.Context, organizationID uuid.UUID, role Role) (int, error)
+}
+
+// InvitationRepository defines the interface for organization invitation persistence
+type InvitationRepository interface {
+	// Create stores a new invitation
+	Create(ctx context.Context, invitation *Invitation) error
+
+	// CreateWithinSeatLimit stores a new invitation, or returns ErrSeatLimitReached if the
+	// organization's members and open invitations already take seatLimit seats. Like
+	// MembershipRepository.AddWithinSeatLimit, it is serialized per organization.
+	CreateWithinSeatLimit(ctx context.Context, invitation *Invitation, seatLimit int) error
+
+	// GetByID retrieves an invitation by ID
+	GetByID(ctx context.Context, id uuid.UUID) (*Invitation, error)
+
+	// GetPendingByEmail retrieves the pending invitation of an address to an organization
+	GetPendingByEmail(ctx context.Context, organizationID uuid.UUID, email string) (*Invitation, error)
+
+	// ListPending returns an organization's pending invitations, newest first
+	ListPending(ctx context.Context, organizationID uuid.UUID) ([]*Invitation, error)
+
+	// CountOpen counts an organization's pending invitations that have not expired at now
+	CountOpen(ctx context.Context, organizationID uuid.UUID, now time.Time) (int, error)
+
+	// Update saves an invitation's role, status, expiry and acceptance
+	Update(ctx context.Context, invitation *Invitation) error
+
+	// UpdateWithinSeatLimit saves an invitation that is to hold a seat again, like
+	// CreateWithinSeatLimit; the invitation itself counts only if open at UpdatedAt
+	UpdateWithinSeatLimit(ctx context.Context, invitation *Invitation, seatLimit int) error
+
+	// Delete removes an invitation
+	Delete(ctx context.Context, id uuid.UUID) error
+}
@@ -0,0 +1,206 @@
+package organization
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Role is a member's role within one organization. Unlike RBAC roles, it only
+// grants permissions inside that organization.
+type Role string
+
+const (
+	RoleOwner   Role = "owner"
+	RoleAdmin   Role = "admin"
+	RoleBilling Role = "billing"
+	RoleMember  Role = "member"
+)
+
+// Organization-scoped permissions, as resource:action pairs
+const (
+	PermissionOrganizationRead   = "organization:read"
+	PermissionOrganizationUpdate = "organization:update"
+	PermissionOrganizationDelete = "organization:delete"
+	PermissionMembersRead        = "members:read"
+	PermissionMembersManage      = "members:manage"
+	PermissionBillingView        = "billing:view"
+	PermissionBillingManage      = "billing:manage"
+	PermissionAPIKeysManage      = "api_keys:manage"
+)
+
+// rolePermissions lists what each role may do within its organization
+var rolePermissions = map[Role][]string{
+	RoleOwner: {
+		PermissionOrganizationRead, PermissionOrganizationUpdate, PermissionOrganizationDelete,
+		PermissionMembersRead, PermissionMembersManage,
+		PermissionBillingView, PermissionBillingManage,
+		PermissionAPIKeysManage,
+	},
+	RoleAdmin: {
+		PermissionOrganizationRead, PermissionOrganizationUpdate,
+		PermissionMembersRead, PermissionMembersManage,
+		PermissionBillingView,
+		PermissionAPIKeysManage,
+	},
+	RoleBilling: {
+		PermissionOrganizationRead,
+		PermissionMembersRead,
+		PermissionBillingView, PermissionBillingManage,
+	},
+	RoleMember: {
+		PermissionOrganizationRead,
+		PermissionMembersRead,
+	},
+}
+
+// IsValid checks if the role is one of the organization roles
+func (r Role) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Permissions returns the permissions the role grants within its organization
+func (r Role) Permissions() []string {
+	return rolePermissions[r]
+}
+
+// HasPermission checks if the role grants a permission
+func (r Role) HasPermission(permission string) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Includes checks if the role grants every permission of another, so that a
+// member holding it may grant, change or remove the other role without
+// handing out permissions they lack themselves
+func (r Role) Includes(other Role) bool {
+	for _, p := range rolePermissions[other] {
+		if !r.HasPermission(p) {
+			return false
+		}
+	}
+	return r.IsValid()
+}
+
+// Organization is a tenant: a company or team whose members share its
+// subscription, roles and identity provider settings
+type Organization struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	CreatedBy uuid.UUID `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Membership is a user's place in an organization
+type Membership struct {
+	OrganizationID uuid.UUID `json:"organization_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Role           Role      `json:"role"`
+	AddedBy        uuid.UUID `json:"added_by"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	// Organization is loaded when listing a user's memberships
+	Organization *Organization `json:"organization,omitempty"`
+}
+
+// CreateRequest represents a request to create an organization
+type CreateRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Slug string `json:"slug" binding:"required,max=63"`
+}
+
+// UpdateRequest represents a request to update an organization
+type UpdateRequest struct {
+	Name *string `json:"name" binding:"omitempty,max=100"`
+	Slug *string `json:"slug" binding:"omitempty,max=63"`
+}
+
+// AddMemberRequest represents a request to add a user to an organization
+type AddMemberRequest struct {
+	UserID uuid.UUID `json:"user_id" binding:"required"`
+	Role   Role      `json:"role" binding:"required,oneof=owner admin billing member"`
+}
+
+// UpdateMemberRequest represents a request to change a member's role
+type UpdateMemberRequest struct {
+	Role Role `json:"role" binding:"required,oneof=owner admin billing member"`
+}
+
+// SwitchRequest selects the organization the caller's tokens act in; omitting
+// the organization switches back to the personal account
+type SwitchRequest struct {
+	OrganizationID *uuid.UUID `json:"organization_id"`
+}
+
+// InvitationStatus is the state of an invitation. A pending invitation past its
+// expiry can no longer be accepted but may be resent.
+type InvitationStatus string
+
+const (
+	InvitationStatusPending  InvitationStatus = "pending"
+	InvitationStatusAccepted InvitationStatus = "accepted"
+	InvitationStatusRevoked  InvitationStatus = "revoked"
+	InvitationStatusExpired  InvitationStatus = "expired"
+)
+
+// Invitation offers an email address a pre-assigned role in an organization
+type Invitation struct {
+	ID             uuid.UUID        `json:"id"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+	Email          string           `json:"email"`
+	Role           Role             `json:"role"`
+	Status         InvitationStatus `json:"status"`
+	InvitedBy      uuid.UUID        `json:"invited_by"`
+	ExpiresAt      time.Time        `json:"expires_at"`
+	AcceptedBy     *uuid.UUID       `json:"accepted_by,omitempty"`
+	AcceptedAt     *time.Time       `json:"accepted_at,omitempty"`
+	RevokedAt      *time.Time       `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// IsOpen checks if the invitation can still be accepted
+func (i *Invitation) IsOpen(now time.Time) bool {
+	return i.Status == InvitationStatusPending && now.Before(i.ExpiresAt)
+}
+
+// InviteRequest represents a request to invite someone to an organization
+type InviteRequest struct {
+	Email string `json:"email" binding:"required,email,max=255"`
+	Role  Role   `json:"role" binding:"required,oneof=owner admin billing member"`
+}
+
+// AcceptInvitationRequest represents a request to accept an invitation while
+// signed in
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// AcceptInvitationWithRegistrationRequest represents a request to accept an
+// invitation by creating an account for the invited address
+type AcceptInvitationWithRegistrationRequest struct {
+	Token     string `json:"token" binding:"required"`
+	Username  string `json:"username" binding:"required,min=3,max=50,alphanum"`
+	Password  string `json:"password" binding:"required,min=8"`
+	FirstName string `json:"first_name" binding:"required,min=1,max=100"`
+	LastName  string `json:"last_name" binding:"required,min=1,max=100"`
+}
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidateSlug checks that a slug can be used in URLs
+func ValidateSlug(slug string) error {
+	if len(slug) < 2 || len(slug) > 63 || !slugPattern.MatchString(slug) {
+		return ErrInvalidSlug
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package organization
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRole_Includes(t *testing.T) {
+	tests := []struct {
+		role, other Role
+		want        bool
+	}{
+		{RoleOwner, RoleOwner, true},
+		{RoleOwner, RoleBilling, true},
+		{RoleAdmin, RoleMember, true},
+		{RoleAdmin, RoleAdmin, true},
+		// Admins may not hand out billing:manage, which they lack
+		{RoleAdmin, RoleBilling, false},
+		{RoleAdmin, RoleOwner, false},
+		{RoleBilling, RoleAdmin, false},
+		{RoleMember, RoleMember, true},
+		{Role("guest"), Role("guest"), false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.role.Includes(tt.other), "%s includes %s", tt.role, tt.other)
+	}
+}
+
+func TestValidateSlug(t *testing.T) {
+	for _, slug := range []string{"acme", "acme-corp", "a1"} {
+		assert.NoError(t, ValidateSlug(slug), slug)
+	}
+	for _, slug := range []string{"a", "Acme", "acme corp", "-acme", "acme--corp", "acme-"} {
+		assert.ErrorIs(t, ValidateSlug(slug), ErrInvalidSlug, slug)
+	}
+}
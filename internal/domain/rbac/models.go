@@ -74,6 +74,23 @@ type AccessRequest struct {
 	Context  map[string]interface{} `json:"context,omitempty"`
 }
 
+// ContextOrganizationID is the AccessRequest context key that scopes a check to
+// one organization; the user's role there decides it instead of their global roles
+const ContextOrganizationID = "organization_id"
+
+// OrganizationID returns the organization the request is scoped to, given as a
+// UUID or its string form
+func (r *AccessRequest) OrganizationID() (uuid.UUID, bool) {
+	switch v := r.Context[ContextOrganizationID].(type) {
+	case uuid.UUID:
+		return v, v != uuid.Nil
+	case string:
+		id, err := uuid.Parse(v)
+		return id, err == nil && id != uuid.Nil
+	}
+	return uuid.Nil, false
+}
+
 // AccessResponse represents the response to an access check
 type AccessResponse struct {
 	Allowed      bool     `json:"allowed"`
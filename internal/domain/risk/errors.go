@@ -0,0 +1,8 @@
+package risk
+
+import "errors"
+
+var (
+	// ErrLocationNotFound is returned when the GeoIP data has nothing for an address
+	ErrLocationNotFound = errors.New("location not found")
+)
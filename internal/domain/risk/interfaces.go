@@ -0,0 +1,22 @@
+package risk
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// LoginHistoryRepository defines the interface for sign-in history persistence
+type LoginHistoryRepository interface {
+	// Create records a successful sign-in
+	Create(ctx context.Context, event *LoginEvent) error
+
+	// ListRecent returns a user's most recent sign-ins, newest first
+	ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]*LoginEvent, error)
+}
+
+// Locator resolves IP addresses to locations from offline GeoIP data
+type Locator interface {
+	// Locate returns where an address is registered, or ErrLocationNotFound
+	Locate(ip string) (*Location, error)
+}
@@ -0,0 +1,87 @@
+package risk
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Decision is what the risk engine does with a sign-in
+type Decision string
+
+const (
+	// DecisionAllow lets the sign-in through
+	DecisionAllow Decision = "allow"
+
+	// DecisionStepUp requires a second factor before the sign-in completes
+	DecisionStepUp Decision = "step_up"
+
+	// DecisionBlock refuses the sign-in
+	DecisionBlock Decision = "block"
+)
+
+// Signal names a reason a sign-in looks risky
+type Signal string
+
+const (
+	SignalNewDevice        Signal = "new_device"
+	SignalNewNetwork       Signal = "new_network"
+	SignalImpossibleTravel Signal = "impossible_travel"
+	SignalFailedAttempts   Signal = "failed_attempts"
+)
+
+// Location is where an IP address is registered, as far as the GeoIP data knows.
+// Fields the data does not cover are left empty.
+type Location struct {
+	Country      string   `json:"country,omitempty"` // ISO 3166-1 alpha-2 code
+	City         string   `json:"city,omitempty"`
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+	ASN          uint32   `json:"asn,omitempty"`
+	Organization string   `json:"organization,omitempty"` // Owner of the autonomous system
+}
+
+// HasCoordinates reports whether the location can be placed on a map
+func (l *Location) HasCoordinates() bool {
+	return l != nil && l.Latitude != nil && l.Longitude != nil
+}
+
+// LoginEvent is a successful sign-in, kept so later sign-ins can be compared with it
+type LoginEvent struct {
+	ID                uuid.UUID `json:"id"`
+	UserID            uuid.UUID `json:"user_id"`
+	DeviceFingerprint string    `json:"device_fingerprint"`
+	IPAddress         string    `json:"ip_address"`
+	UserAgent         string    `json:"user_agent"`
+	Location          Location  `json:"location"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// Assessment is the risk engine's verdict on a sign-in
+type Assessment struct {
+	Score    int      `json:"score"`
+	Decision Decision `json:"decision"`
+	Signals  []Signal `json:"signals,omitempty"`
+
+	// The sign-in being assessed, recorded as a LoginEvent if it succeeds
+	DeviceFingerprint string    `json:"device_fingerprint"`
+	IPAddress         string    `json:"ip_address"`
+	UserAgent         string    `json:"user_agent"`
+	Location          *Location `json:"location,omitempty"`
+}
+
+// AddSignal records a signal and the points it adds to the score
+func (a *Assessment) AddSignal(signal Signal, score int) {
+	a.Signals = append(a.Signals, signal)
+	a.Score += score
+}
+
+// HasSignal reports whether the assessment found the signal
+func (a *Assessment) HasSignal(signal Signal) bool {
+	for _, s := range a.Signals {
+		if s == signal {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package saml
+
+import "errors"
+
+var (
+	// ErrConnectionNotFound is returned when an organization has no SAML connection
+	ErrConnectionNotFound = errors.New("SAML connection not found")
+
+	// ErrInvalidMetadata is returned when uploaded IdP metadata cannot be used
+	ErrInvalidMetadata = errors.New("invalid SAML identity provider metadata")
+
+	// ErrInvalidResponse is returned when a SAML response is malformed or fails validation
+	ErrInvalidResponse = errors.New("invalid SAML response")
+
+	// ErrInvalidSignature is returned when a SAML response is unsigned or its signature does not verify
+	ErrInvalidSignature = errors.New("invalid SAML signature")
+
+	// ErrAssertionExpired is returned when an assertion is used outside its validity window
+	ErrAssertionExpired = errors.New("SAML assertion is not valid at this time")
+
+	// ErrAudienceMismatch is returned when an assertion is addressed to another service provider
+	ErrAudienceMismatch = errors.New("SAML assertion is not intended for this service provider")
+
+	// ErrRequestMismatch is returned when a response does not answer the request the browser started
+	ErrRequestMismatch = errors.New("SAML response does not match the sign-in request")
+
+	// ErrUnsolicitedResponse is returned when IdP-initiated sign-in is disabled for a connection
+	ErrUnsolicitedResponse = errors.New("IdP-initiated sign-in is not enabled for this connection")
+
+	// ErrAssertionReplayed is returned when an assertion has already been used to sign in
+	ErrAssertionReplayed = errors.New("SAML assertion has already been used")
+
+	// ErrAuthenticationFailed is returned when the identity provider reports a failed sign-in
+	ErrAuthenticationFailed = errors.New("identity provider did not authenticate the user")
+)
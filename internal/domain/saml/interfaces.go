@@ -0,0 +1,44 @@
+package saml
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConnectionRepository defines the interface for SAML connection persistence
+type ConnectionRepository interface {
+	// Create stores a new connection
+	Create(ctx context.Context, connection *Connection) error
+
+	// GetByOrganization retrieves an organization's connection
+	GetByOrganization(ctx context.Context, organizationID uuid.UUID) (*Connection, error)
+
+	// Update replaces an organization's connection settings
+	Update(ctx context.Context, connection *Connection) error
+
+	// Delete removes an organization's connection
+	Delete(ctx context.Context, organizationID uuid.UUID) error
+}
+
+// AssertionCache remembers the assertions that have been used to sign in
+type AssertionCache interface {
+	// Use records an assertion until it expires, returning ErrAssertionReplayed if it was already recorded
+	Use(ctx context.Context, issuer, assertionID string, expiresAt time.Time) error
+}
+
+// Protocol defines the SAML 2.0 messages exchanged with identity providers
+type Protocol interface {
+	// ParseMetadata reads an identity provider's entity descriptor
+	ParseMetadata(data []byte) (*IdPMetadata, error)
+
+	// Metadata returns the service provider's entity descriptor for the identity provider to import
+	Metadata(sp ServiceProvider) ([]byte, error)
+
+	// NewAuthnRequest creates a sign-in request to a connection's identity provider
+	NewAuthnRequest(sp ServiceProvider, connection *Connection, relayState string) (*AuthnRequest, error)
+
+	// VerifyResponse checks the signature and conditions of a base64 encoded response and returns its assertion
+	VerifyResponse(encoded string, expect *Expectations) (*Assertion, error)
+}
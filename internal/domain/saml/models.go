@@ -0,0 +1,128 @@
+package saml
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Connection is an organization's SAML 2.0 identity provider
+type Connection struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+
+	// IdPEntityID, SSOURL and Certificates are read from the uploaded IdP metadata
+	IdPEntityID  string   `json:"idp_entity_id"`
+	SSOURL       string   `json:"sso_url"`
+	Certificates []string `json:"certificates"` // Base64 DER signing certificates
+	Metadata     string   `json:"-"`            // The metadata document as uploaded
+
+	AttributeMapping AttributeMapping `json:"attribute_mapping"`
+
+	// RoleMapping grants local roles to users whose groups attribute holds a value,
+	// e.g. {"Engineering": ["developer"]}
+	RoleMapping map[string][]string `json:"role_mapping,omitempty"`
+
+	// DefaultRoles are granted to every user provisioned through the connection
+	DefaultRoles []string `json:"default_roles,omitempty"`
+
+	// AllowSignup creates an account the first time an unknown user signs in (just-in-time provisioning)
+	AllowSignup bool `json:"allow_signup"`
+
+	// AllowIdPInitiated accepts responses the identity provider sends without a request from us
+	AllowIdPInitiated bool `json:"allow_idp_initiated"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConnectionRequest configures an organization's connection from its IdP metadata
+type ConnectionRequest struct {
+	Metadata          string              `json:"metadata" binding:"required"`
+	AttributeMapping  *AttributeMapping   `json:"attribute_mapping,omitempty"` // Nil uses DefaultAttributeMapping
+	RoleMapping       map[string][]string `json:"role_mapping,omitempty"`
+	DefaultRoles      []string            `json:"default_roles,omitempty"`
+	AllowSignup       bool                `json:"allow_signup"`
+	AllowIdPInitiated bool                `json:"allow_idp_initiated"`
+}
+
+// AttributeMapping names the assertion attributes that hold user profile fields.
+// An empty name leaves the field alone; the email falls back to the NameID.
+type AttributeMapping struct {
+	Email       string `json:"email,omitempty"`
+	FirstName   string `json:"first_name,omitempty"`
+	LastName    string `json:"last_name,omitempty"`
+	Username    string `json:"username,omitempty"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+	Groups      string `json:"groups,omitempty"`
+}
+
+// DefaultAttributeMapping returns the attribute names most identity providers release by default
+func DefaultAttributeMapping() AttributeMapping {
+	return AttributeMapping{
+		Email:     "email",
+		FirstName: "firstName",
+		LastName:  "lastName",
+		Groups:    "groups",
+	}
+}
+
+// IdPMetadata is the part of an identity provider's metadata a service provider needs
+type IdPMetadata struct {
+	EntityID     string
+	SSOURL       string
+	Certificates []string
+}
+
+// ServiceProvider identifies this application to one organization's identity provider
+type ServiceProvider struct {
+	EntityID string
+	ACSURL   string // Assertion consumer service, where the IdP posts responses
+}
+
+// AuthnRequest is a sign-in request sent to an identity provider
+type AuthnRequest struct {
+	ID  string
+	URL string // Identity provider URL carrying the request with the HTTP-Redirect binding
+}
+
+// Expectations are what a response must match to be accepted
+type Expectations struct {
+	ServiceProvider ServiceProvider
+	IdPEntityID     string
+	Certificates    []string
+
+	// InResponseTo is the ID of the request the browser started; empty for IdP-initiated sign-in
+	InResponseTo string
+
+	Now time.Time
+}
+
+// Assertion is the verified content of a SAML assertion
+type Assertion struct {
+	ID           string
+	Issuer       string
+	NameID       string
+	NameIDFormat string
+	SessionIndex string
+	InResponseTo string
+	Attributes   map[string][]string
+	AuthnInstant time.Time
+	NotOnOrAfter time.Time // When the assertion stops being accepted, allowing for clock skew
+}
+
+// Attribute returns the first value of an attribute, or "" if it is absent
+func (a *Assertion) Attribute(name string) string {
+	if name == "" || len(a.Attributes[name]) == 0 {
+		return ""
+	}
+	return a.Attributes[name][0]
+}
+
+// RequestState is carried through the identity provider round trip of an SP-initiated sign-in
+type RequestState struct {
+	OrganizationID uuid.UUID `json:"oid"`
+	RequestID      string    `json:"rid"`
+	RelayState     string    `json:"rs"`
+	ExpiresAt      time.Time `json:"exp"`
+}
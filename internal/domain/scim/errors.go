@@ -0,0 +1,38 @@
+package scim
+
+import "errors"
+
+var (
+	// ErrTokenNotFound is returned when a provisioning token does not exist
+	ErrTokenNotFound = errors.New("scim token not found")
+
+	// ErrInvalidToken is returned when a presented provisioning token is unknown or revoked
+	ErrInvalidToken = errors.New("invalid scim token")
+
+	// ErrResourceNotFound is returned when a user or group is not provisioned in the organization
+	ErrResourceNotFound = errors.New("scim resource not found")
+
+	// ErrInvalidFilter is returned when a filter does not follow the SCIM filter grammar
+	ErrInvalidFilter = errors.New("invalid scim filter")
+
+	// ErrInvalidPath is returned when a PATCH path is malformed or names an unsupported attribute
+	ErrInvalidPath = errors.New("invalid scim path")
+
+	// ErrNoTarget is returned when a PATCH path matches nothing it can operate on
+	ErrNoTarget = errors.New("scim path matched no values")
+
+	// ErrInvalidValue is returned when a required attribute is missing or a value has the wrong type
+	ErrInvalidValue = errors.New("invalid scim attribute value")
+
+	// ErrInvalidSyntax is returned when a request body is not a valid SCIM message
+	ErrInvalidSyntax = errors.New("invalid scim request")
+
+	// ErrMutability is returned when a request tries to change a read-only attribute
+	ErrMutability = errors.New("scim attribute is read-only")
+
+	// ErrUniqueness is returned when a userName, email or group name is already taken
+	ErrUniqueness = errors.New("scim resource already exists")
+
+	// ErrPreconditionFailed is returned when an If-Match version no longer matches the resource
+	ErrPreconditionFailed = errors.New("scim resource has changed")
+)
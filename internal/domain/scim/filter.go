@@ -0,0 +1,613 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxFilterLength bounds the filters and paths a client may send
+const maxFilterLength = 4096
+
+// Filter is a parsed filter expression (RFC 7644 section 3.4.2.2)
+type Filter interface {
+	// Matches reports whether a resource in its generic JSON form satisfies the filter
+	Matches(resource map[string]interface{}) bool
+}
+
+// AttributePath names an attribute and optionally one of its sub-attributes.
+// Attributes of extension schemas are addressed with the schema URI as the
+// attribute, which is how they are nested in the JSON form.
+type AttributePath struct {
+	Attribute    string
+	SubAttribute string
+}
+
+// Path is the target of a PATCH operation: an attribute path, optionally narrowed
+// to the entries of a multi-valued attribute that match a filter, as in
+// `emails[type eq "work"].value`
+type Path struct {
+	AttributePath
+	Filter Filter
+}
+
+// coreSchemas are the schemas whose URI may prefix attribute paths of the core resources
+var coreSchemas = []string{SchemaUser, SchemaGroup}
+
+// ParseAttributePath parses an attribute path such as "name.givenName" or
+// "urn:ietf:params:scim:schemas:core:2.0:User:userName"
+func ParseAttributePath(s string) (AttributePath, error) {
+	if strings.HasPrefix(strings.ToLower(s), "urn:") {
+		i := strings.LastIndex(s, ":")
+		schema, rest := s[:i], s[i+1:]
+		if rest == "" {
+			return AttributePath{}, fmt.Errorf("%w: %q", ErrInvalidPath, s)
+		}
+		if !isCoreSchema(schema) {
+			return AttributePath{Attribute: schema, SubAttribute: rest}, nil
+		}
+		s = rest
+	}
+
+	attribute, sub, hasSub := strings.Cut(s, ".")
+	if !validName(attribute) || (hasSub && !validName(sub)) {
+		return AttributePath{}, fmt.Errorf("%w: %q", ErrInvalidPath, s)
+	}
+	return AttributePath{Attribute: attribute, SubAttribute: sub}, nil
+}
+
+// ParsePath parses the path of a PATCH operation (RFC 7644 section 3.5.2)
+func ParsePath(s string) (*Path, error) {
+	if s == "" || len(s) > maxFilterLength {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPath, s)
+	}
+
+	open := strings.IndexByte(s, '[')
+	if open < 0 {
+		attributePath, err := ParseAttributePath(s)
+		if err != nil {
+			return nil, err
+		}
+		return &Path{AttributePath: attributePath}, nil
+	}
+
+	closing := strings.LastIndexByte(s, ']')
+	if closing < open {
+		return nil, fmt.Errorf("%w: unbalanced brackets in %q", ErrInvalidPath, s)
+	}
+	attributePath, err := ParseAttributePath(s[:open])
+	if err != nil {
+		return nil, err
+	}
+	if attributePath.SubAttribute != "" {
+		return nil, fmt.Errorf("%w: filters apply to multi-valued attributes, not %q", ErrInvalidPath, s[:open])
+	}
+	filter, err := ParseFilter(s[open+1 : closing])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPath, err)
+	}
+
+	if rest := s[closing+1:]; rest != "" {
+		sub, ok := strings.CutPrefix(rest, ".")
+		if !ok || !validName(sub) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPath, s)
+		}
+		attributePath.SubAttribute = sub
+	}
+
+	return &Path{AttributePath: attributePath, Filter: filter}, nil
+}
+
+// ParseFilter parses a filter expression such as `userName eq "jane@acme.com"` or
+// `emails[type eq "work" and value co "@acme.com"] or not (active eq true)`
+func ParseFilter(s string) (Filter, error) {
+	if strings.TrimSpace(s) == "" || len(s) > maxFilterLength {
+		return nil, fmt.Errorf("%w: filter is empty or too long", ErrInvalidFilter)
+	}
+
+	tokens, err := tokenizeFilter(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidFilter, p.tokens[p.pos].text)
+	}
+	return filter, nil
+}
+
+func isCoreSchema(schema string) bool {
+	for _, core := range coreSchemas {
+		if strings.EqualFold(schema, core) {
+			return true
+		}
+	}
+	return false
+}
+
+// validName checks an attribute name against ATTRNAME (RFC 7644 section 3.10)
+func validName(name string) bool {
+	if name == "$ref" {
+		return true
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '-' || r == '_'):
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// lookup finds an attribute by name; SCIM attribute names are case-insensitive
+func lookup(m map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	for key, v := range m {
+		if strings.EqualFold(key, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// values returns the values a path selects from a resource. Each entry of a
+// multi-valued attribute contributes a value; without a sub-attribute, entries
+// of complex multi-valued attributes contribute their "value" sub-attribute.
+func (p AttributePath) values(resource map[string]interface{}) []interface{} {
+	v, ok := lookup(resource, p.Attribute)
+	if !ok || v == nil {
+		return nil
+	}
+
+	var out []interface{}
+	collect := func(item interface{}, multiValued bool) {
+		m, complex := item.(map[string]interface{})
+		switch {
+		case p.SubAttribute != "":
+			if complex {
+				if value, ok := lookup(m, p.SubAttribute); ok && value != nil {
+					out = append(out, value)
+				}
+			}
+		case complex && multiValued:
+			if value, ok := lookup(m, "value"); ok && value != nil {
+				out = append(out, value)
+			}
+		default:
+			out = append(out, item)
+		}
+	}
+
+	if items, ok := v.([]interface{}); ok {
+		for _, item := range items {
+			collect(item, true)
+		}
+	} else {
+		collect(v, false)
+	}
+	return out
+}
+
+// logicalFilter combines two filters with "and" or "or"
+type logicalFilter struct {
+	and         bool
+	left, right Filter
+}
+
+func (f *logicalFilter) Matches(resource map[string]interface{}) bool {
+	if f.and {
+		return f.left.Matches(resource) && f.right.Matches(resource)
+	}
+	return f.left.Matches(resource) || f.right.Matches(resource)
+}
+
+// notFilter negates a filter
+type notFilter struct {
+	filter Filter
+}
+
+func (f *notFilter) Matches(resource map[string]interface{}) bool {
+	return !f.filter.Matches(resource)
+}
+
+// presentFilter matches attributes with a non-empty value
+type presentFilter struct {
+	path AttributePath
+}
+
+func (f *presentFilter) Matches(resource map[string]interface{}) bool {
+	for _, v := range f.path.values(resource) {
+		switch v := v.(type) {
+		case string:
+			if v != "" {
+				return true
+			}
+		case []interface{}:
+			if len(v) > 0 {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// compareFilter compares an attribute with a literal value
+type compareFilter struct {
+	path      AttributePath
+	op        string
+	value     interface{} // string, float64, bool or nil
+	caseExact bool
+}
+
+func (f *compareFilter) Matches(resource map[string]interface{}) bool {
+	values := f.path.values(resource)
+
+	if f.op == "ne" {
+		return !(&compareFilter{path: f.path, op: "eq", value: f.value, caseExact: f.caseExact}).Matches(resource)
+	}
+	if f.value == nil {
+		// "eq null" matches attributes without a value
+		return len(values) == 0
+	}
+
+	for _, actual := range values {
+		if compareValues(f.op, actual, f.value, f.caseExact) {
+			return true
+		}
+	}
+	return false
+}
+
+// valuePathFilter matches resources with a multi-valued attribute entry that satisfies a filter
+type valuePathFilter struct {
+	path   AttributePath
+	filter Filter
+}
+
+func (f *valuePathFilter) Matches(resource map[string]interface{}) bool {
+	v, ok := lookup(resource, f.path.Attribute)
+	if !ok {
+		return false
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		items = []interface{}{v}
+	}
+
+	for _, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			entry = map[string]interface{}{"value": item}
+		}
+		if f.filter.Matches(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValues applies a comparison operator. Strings compare case-insensitively
+// unless caseExact, and ordering comparisons between timestamps compare the times.
+func compareValues(op string, actual, expected interface{}, caseExact bool) bool {
+	switch expected := expected.(type) {
+	case string:
+		actual, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		if isOrdering(op) {
+			if a, err := time.Parse(time.RFC3339Nano, actual); err == nil {
+				if e, err := time.Parse(time.RFC3339Nano, expected); err == nil {
+					return compareOrder(op, a.Compare(e))
+				}
+			}
+		}
+		if !caseExact {
+			actual, expected = strings.ToLower(actual), strings.ToLower(expected)
+		}
+		switch op {
+		case "eq":
+			return actual == expected
+		case "co":
+			return strings.Contains(actual, expected)
+		case "sw":
+			return strings.HasPrefix(actual, expected)
+		case "ew":
+			return strings.HasSuffix(actual, expected)
+		default:
+			return compareOrder(op, strings.Compare(actual, expected))
+		}
+	case float64:
+		actual, ok := actual.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case actual < expected:
+			return compareOrder(op, -1)
+		case actual > expected:
+			return compareOrder(op, 1)
+		default:
+			return compareOrder(op, 0)
+		}
+	case bool:
+		actual, ok := actual.(bool)
+		return ok && op == "eq" && actual == expected
+	}
+	return false
+}
+
+func isOrdering(op string) bool {
+	return op == "gt" || op == "ge" || op == "lt" || op == "le"
+}
+
+// compareOrder interprets the result of a three-way comparison for an operator
+func compareOrder(op string, cmp int) bool {
+	switch op {
+	case "eq":
+		return cmp == 0
+	case "gt":
+		return cmp > 0
+	case "ge":
+		return cmp >= 0
+	case "lt":
+		return cmp < 0
+	case "le":
+		return cmp <= 0
+	}
+	return false
+}
+
+type filterTokenKind int
+
+const (
+	tokenWord filterTokenKind = iota
+	tokenString
+	tokenOpenParen
+	tokenCloseParen
+	tokenOpenBracket
+	tokenCloseBracket
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+}
+
+// tokenizeFilter splits a filter into words, string literals, parentheses and brackets
+func tokenizeFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case ' ', '\t', '\r', '\n':
+			i++
+		case '(':
+			tokens = append(tokens, filterToken{kind: tokenOpenParen, text: "("})
+			i++
+		case ')':
+			tokens = append(tokens, filterToken{kind: tokenCloseParen, text: ")"})
+			i++
+		case '[':
+			tokens = append(tokens, filterToken{kind: tokenOpenBracket, text: "["})
+			i++
+		case ']':
+			tokens = append(tokens, filterToken{kind: tokenCloseBracket, text: "]"})
+			i++
+		case '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrInvalidFilter)
+			}
+			// String literals use JSON syntax and escapes
+			var value string
+			if err := json.Unmarshal([]byte(s[i:j+1]), &value); err != nil {
+				return nil, fmt.Errorf("%w: invalid string %s", ErrInvalidFilter, s[i:j+1])
+			}
+			tokens = append(tokens, filterToken{kind: tokenString, text: value})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\r\n()[]\"", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: tokenWord, text: s[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser is a recursive descent parser for the filter grammar, where
+// "not" binds tighter than "and", which binds tighter than "or"
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek(kind filterTokenKind) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == kind
+}
+
+func (p *filterParser) next() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok, true
+}
+
+func (p *filterParser) expect(kind filterTokenKind, text string) error {
+	if !p.peek(kind) {
+		return fmt.Errorf("%w: expected %q", ErrInvalidFilter, text)
+	}
+	p.pos++
+	return nil
+}
+
+// keyword consumes a logical operator word
+func (p *filterParser) keyword(word string) bool {
+	if p.peek(tokenWord) && strings.EqualFold(p.tokens[p.pos].text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (Filter, error) {
+	negate := false
+	// "not" is only an operator before a parenthesis; otherwise it could be an attribute name
+	if p.peek(tokenWord) && strings.EqualFold(p.tokens[p.pos].text, "not") &&
+		p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == tokenOpenParen {
+		p.pos++
+		negate = true
+	}
+
+	if p.peek(tokenOpenParen) {
+		p.pos++
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenCloseParen, ")"); err != nil {
+			return nil, err
+		}
+		if negate {
+			return &notFilter{filter: filter}, nil
+		}
+		return filter, nil
+	}
+
+	return p.parseAttributeExpression()
+}
+
+func (p *filterParser) parseAttributeExpression() (Filter, error) {
+	tok, ok := p.next()
+	if !ok || tok.kind != tokenWord {
+		return nil, fmt.Errorf("%w: expected an attribute", ErrInvalidFilter)
+	}
+	path, err := ParseAttributePath(tok.text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid attribute %q", ErrInvalidFilter, tok.text)
+	}
+
+	if p.peek(tokenOpenBracket) {
+		p.pos++
+		if path.SubAttribute != "" {
+			return nil, fmt.Errorf("%w: filters apply to multi-valued attributes, not %q", ErrInvalidFilter, tok.text)
+		}
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenCloseBracket, "]"); err != nil {
+			return nil, err
+		}
+		return &valuePathFilter{path: path, filter: filter}, nil
+	}
+
+	opTok, ok := p.next()
+	if !ok || opTok.kind != tokenWord {
+		return nil, fmt.Errorf("%w: expected an operator after %q", ErrInvalidFilter, tok.text)
+	}
+	op := strings.ToLower(opTok.text)
+	if op == "pr" {
+		return &presentFilter{path: path}, nil
+	}
+	switch op {
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidFilter, opTok.text)
+	}
+
+	valueTok, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("%w: expected a value after %q", ErrInvalidFilter, opTok.text)
+	}
+	var value interface{}
+	switch {
+	case valueTok.kind == tokenString:
+		value = valueTok.text
+	case valueTok.kind == tokenWord && strings.EqualFold(valueTok.text, "true"):
+		value = true
+	case valueTok.kind == tokenWord && strings.EqualFold(valueTok.text, "false"):
+		value = false
+	case valueTok.kind == tokenWord && strings.EqualFold(valueTok.text, "null"):
+		value = nil
+	case valueTok.kind == tokenWord:
+		number, err := strconv.ParseFloat(valueTok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid value %q", ErrInvalidFilter, valueTok.text)
+		}
+		value = number
+	default:
+		return nil, fmt.Errorf("%w: expected a value after %q", ErrInvalidFilter, opTok.text)
+	}
+
+	// Only strings support substring matching, and booleans and null only equality
+	switch value.(type) {
+	case string:
+	case float64:
+		if op == "co" || op == "sw" || op == "ew" {
+			return nil, fmt.Errorf("%w: %q does not apply to numbers", ErrInvalidFilter, op)
+		}
+	default:
+		if op != "eq" && op != "ne" {
+			return nil, fmt.Errorf("%w: %q does not apply to %v", ErrInvalidFilter, op, valueTok.text)
+		}
+	}
+
+	// Identifiers are compared exactly; other strings ignore case
+	caseExact := path.SubAttribute == "" &&
+		(strings.EqualFold(path.Attribute, "id") || strings.EqualFold(path.Attribute, "externalId"))
+
+	return &compareFilter{path: path, op: op, value: value, caseExact: caseExact}, nil
+}
@@ -0,0 +1,111 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testUser(t *testing.T) map[string]interface{} {
+	t.Helper()
+
+	active := Flag(true)
+	resource, err := ToMap(&UserResource{
+		Schemas:    []string{SchemaUser},
+		ID:         "2819c223-7f76-453a-919d-413861904646",
+		ExternalID: "00u1abcd",
+		UserName:   "Jane@Acme.com",
+		Name:       &Name{GivenName: "Jane", FamilyName: "Doe"},
+		Emails: []MultiValue{
+			{Value: "jane@acme.com", Type: "work", Primary: true},
+			{Value: "jane@example.org", Type: "home"},
+		},
+		Active: &active,
+		Meta:   &Meta{ResourceType: "User", Version: `W/"1"`},
+	})
+	require.NoError(t, err)
+	resource["meta"].(map[string]interface{})["lastModified"] = "2024-05-13T04:42:34Z"
+	return resource
+}
+
+func TestParseFilter(t *testing.T) {
+	resource := testUser(t)
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`userName eq "jane@acme.com"`, true},
+		{`USERNAME Eq "JANE@ACME.COM"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "jane@acme.com"`, true},
+		{`userName ne "jane@acme.com"`, false},
+		{`externalId eq "00U1ABCD"`, false},
+		{`externalId eq "00u1abcd"`, true},
+		{`name.givenName sw "ja" and name.familyName ew "oe"`, true},
+		{`name.givenName co "zz" or active eq true`, true},
+		{`not (active eq true)`, false},
+		{`emails co "example.org"`, true},
+		{`emails[type eq "work" and value co "@acme.com"]`, true},
+		{`emails[type eq "work" and value co "@example.org"]`, false},
+		{`emails.type eq "home"`, true},
+		{`title pr`, false},
+		{`name pr and emails pr`, true},
+		{`title eq null`, true},
+		{`meta.lastModified gt "2024-01-01T00:00:00Z"`, true},
+		{`meta.lastModified lt "2024-01-01T00:00:00+00:00"`, false},
+		{`(userName eq "x" or userName eq "jane@acme.com") and not (emails[type eq "other"])`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			filter, err := ParseFilter(tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, filter.Matches(resource))
+		})
+	}
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName equals "x"`,
+		`userName eq "unterminated`,
+		`(userName eq "x"`,
+		`emails[type eq "work"`,
+		`active gt true`,
+		`userName co 3`,
+		`userName eq "x" extra`,
+		`1userName eq "x"`,
+	} {
+		t.Run(filter, func(t *testing.T) {
+			_, err := ParseFilter(filter)
+			assert.ErrorIs(t, err, ErrInvalidFilter)
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	path, err := ParsePath(`emails[type eq "work"].value`)
+	require.NoError(t, err)
+	assert.Equal(t, "emails", path.Attribute)
+	assert.Equal(t, "value", path.SubAttribute)
+	assert.NotNil(t, path.Filter)
+
+	path, err = ParsePath("urn:ietf:params:scim:schemas:core:2.0:User:name.familyName")
+	require.NoError(t, err)
+	assert.Equal(t, AttributePath{Attribute: "name", SubAttribute: "familyName"}, path.AttributePath)
+
+	// Extension attributes are nested under their schema
+	path, err = ParsePath("urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department")
+	require.NoError(t, err)
+	assert.Equal(t, "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User", path.Attribute)
+	assert.Equal(t, "department", path.SubAttribute)
+
+	for _, invalid := range []string{"", "name..givenName", "emails[type eq]", "emails]type eq \"x\"[", "name.givenName[value eq \"x\"]"} {
+		_, err := ParsePath(invalid)
+		assert.ErrorIs(t, err, ErrInvalidPath, invalid)
+	}
+}
@@ -0,0 +1,65 @@
+package scim
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenRepository defines the interface for provisioning token persistence
+type TokenRepository interface {
+	// Create stores a new token
+	Create(ctx context.Context, token *Token) error
+
+	// GetByHash retrieves a token by the hash of its secret
+	GetByHash(ctx context.Context, tokenHash string) (*Token, error)
+
+	// ListByOrganization returns an organization's tokens, newest first
+	ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*Token, error)
+
+	// Revoke marks one of an organization's tokens as revoked
+	Revoke(ctx context.Context, organizationID, id uuid.UUID, revokedAt time.Time) error
+
+	// UpdateLastUsed records when a token was last used
+	UpdateLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+}
+
+// AccountRepository defines the interface for persisting which users an organization provisioned
+type AccountRepository interface {
+	// Create links a user to an organization
+	Create(ctx context.Context, account *Account) error
+
+	// Get retrieves an organization's link to a user
+	Get(ctx context.Context, organizationID, userID uuid.UUID) (*Account, error)
+
+	// GetByUserName retrieves an organization's account by SCIM userName, ignoring case
+	GetByUserName(ctx context.Context, organizationID uuid.UUID, userName string) (*Account, error)
+
+	// ListByOrganization returns every account an organization provisioned, including deprovisioned ones
+	ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*Account, error)
+
+	// Update replaces an account's userName, externalId and deprovisioning state
+	Update(ctx context.Context, account *Account) error
+}
+
+// GroupRepository defines the interface for SCIM group persistence
+type GroupRepository interface {
+	// Create stores a new group with its members
+	Create(ctx context.Context, group *Group) error
+
+	// GetByID retrieves one of an organization's groups
+	GetByID(ctx context.Context, organizationID, id uuid.UUID) (*Group, error)
+
+	// ListByOrganization returns an organization's groups
+	ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*Group, error)
+
+	// ListByMember returns the organization's groups a user belongs to
+	ListByMember(ctx context.Context, organizationID, userID uuid.UUID) ([]*Group, error)
+
+	// Update replaces a group's attributes and members
+	Update(ctx context.Context, group *Group) error
+
+	// Delete removes one of an organization's groups
+	Delete(ctx context.Context, organizationID, id uuid.UUID) error
+}
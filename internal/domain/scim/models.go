@@ -0,0 +1,290 @@
+package scim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema URIs of the SCIM 2.0 core resources and messages (RFC 7643, RFC 7644)
+const (
+	SchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// Token is a bearer token an organization's identity provider provisions users with.
+// Only a hash of the secret is stored; Prefix is kept so admins can tell tokens apart.
+type Token struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	Name           string     `json:"name"`
+	Prefix         string     `json:"prefix"`
+	TokenHash      string     `json:"-"`
+	CreatedBy      uuid.UUID  `json:"created_by"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// TokenRequest represents a request to create a provisioning token
+type TokenRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// Account links a user to the organization that provisioned it. The SCIM userName
+// is kept here because identity providers usually send an email address, which
+// local usernames cannot hold.
+type Account struct {
+	OrganizationID uuid.UUID `json:"organization_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	UserName       string    `json:"user_name"`
+	ExternalID     string    `json:"external_id,omitempty"`
+
+	// DeprovisionedAt is set when the identity provider deletes the user; the
+	// account is suspended and hidden from SCIM until provisioned again
+	DeprovisionedAt *time.Time `json:"deprovisioned_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsDeprovisioned checks if the identity provider has deleted the user
+func (a *Account) IsDeprovisioned() bool {
+	return a.DeprovisionedAt != nil
+}
+
+// Group is a group pushed by an organization's identity provider. Each group is
+// backed by its own RBAC role, which is granted to the group's members.
+type Group struct {
+	ID             uuid.UUID   `json:"id"`
+	OrganizationID uuid.UUID   `json:"organization_id"`
+	RoleID         uuid.UUID   `json:"role_id"` // uuid.Nil until a role repository is configured
+	DisplayName    string      `json:"display_name"`
+	ExternalID     string      `json:"external_id,omitempty"`
+	Members        []uuid.UUID `json:"members"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+// HasMember checks if a user belongs to the group
+func (g *Group) HasMember(userID uuid.UUID) bool {
+	for _, member := range g.Members {
+		if member == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleName is the name of the RBAC role backing the group. It is derived from the
+// group ID so that organizations cannot collide with each other or with system roles.
+func (g *Group) RoleName() string {
+	return "scim:" + g.ID.String()
+}
+
+// Meta holds resource metadata (RFC 7643 section 3.1)
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+	Version      string    `json:"version,omitempty"`
+}
+
+// Name is the components of a user's name
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// MultiValue is an entry of a multi-valued attribute such as emails or phoneNumbers
+type MultiValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Display string `json:"display,omitempty"`
+	Primary Flag   `json:"primary,omitempty"`
+}
+
+// Reference points at another resource, e.g. a group member or a user's group
+type Reference struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// Flag is a boolean that also accepts the "True" and "False" strings Azure AD
+// sends in PATCH values
+type Flag bool
+
+// UnmarshalJSON decodes a JSON boolean or a string holding one
+func (f *Flag) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*f = Flag(b)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("%w: expected a boolean", ErrInvalidValue)
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("%w: expected a boolean, got %q", ErrInvalidValue, s)
+	}
+	*f = Flag(b)
+	return nil
+}
+
+// UserResource is the SCIM representation of a user (RFC 7643 section 4.1).
+// Passwords are not accepted; provisioned users sign in through their identity provider.
+type UserResource struct {
+	Schemas      []string     `json:"schemas"`
+	ID           string       `json:"id,omitempty"`
+	ExternalID   string       `json:"externalId,omitempty"`
+	UserName     string       `json:"userName"`
+	Name         *Name        `json:"name,omitempty"`
+	DisplayName  string       `json:"displayName,omitempty"`
+	Emails       []MultiValue `json:"emails,omitempty"`
+	PhoneNumbers []MultiValue `json:"phoneNumbers,omitempty"`
+	Active       *Flag        `json:"active,omitempty"`
+	Groups       []Reference  `json:"groups,omitempty"` // Read-only; membership is managed through groups
+	Meta         *Meta        `json:"meta,omitempty"`
+}
+
+// PrimaryEmail returns the primary email address, or the first one if none is marked primary
+func (r *UserResource) PrimaryEmail() string {
+	for _, email := range r.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(r.Emails) > 0 {
+		return r.Emails[0].Value
+	}
+	return ""
+}
+
+// PrimaryPhoneNumber returns the primary phone number, or the first one if none is marked primary
+func (r *UserResource) PrimaryPhoneNumber() string {
+	for _, phone := range r.PhoneNumbers {
+		if phone.Primary {
+			return phone.Value
+		}
+	}
+	if len(r.PhoneNumbers) > 0 {
+		return r.PhoneNumbers[0].Value
+	}
+	return ""
+}
+
+// IsActive reports the active attribute, which defaults to true when omitted
+func (r *UserResource) IsActive() bool {
+	return r.Active == nil || bool(*r.Active)
+}
+
+// GroupResource is the SCIM representation of a group (RFC 7643 section 4.2)
+type GroupResource struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []Reference `json:"members,omitempty"`
+	Meta        *Meta       `json:"meta,omitempty"`
+}
+
+// ListQuery holds the query parameters of a list request (RFC 7644 section 3.4.2)
+type ListQuery struct {
+	Filter     string
+	StartIndex int // 1-based
+	Count      int
+}
+
+// ListResponse is a page of resources (RFC 7644 section 3.4.2)
+type ListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// PatchRequest is a PATCH message (RFC 7644 section 3.5.2)
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is a single add, replace or remove operation
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// ErrorResponse is the error body SCIM clients expect (RFC 7644 section 3.12)
+type ErrorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// NewErrorResponse creates an error body for an HTTP status
+func NewErrorResponse(status int, scimType, detail string) *ErrorResponse {
+	return &ErrorResponse{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	}
+}
+
+// Version computes a weak entity tag from a resource's content, excluding its
+// metadata, so that it changes whenever any attribute does
+func Version(resource interface{}) (string, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode resource: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`, nil
+}
+
+// ToMap converts a resource to its generic JSON form, which filters and PATCH operations work on
+func ToMap(resource interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode resource: %w", err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode resource: %w", err)
+	}
+	return m, nil
+}
+
+// FromMap converts the generic JSON form of a resource back into a resource
+func FromMap(m map[string]interface{}, resource interface{}) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidValue, err)
+	}
+	if err := json.Unmarshal(data, resource); err != nil {
+		if errors.Is(err, ErrInvalidValue) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidValue, err)
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package scim
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// readOnlyAttributes are assigned by the service provider and cannot be patched
+var readOnlyAttributes = []string{"id", "meta"}
+
+// ApplyPatch applies the operations of a PATCH request, in order, to a resource in
+// its generic JSON form (RFC 7644 section 3.5.2). The caller decodes the result
+// back into the resource, which drops attributes the resource does not support.
+func ApplyPatch(resource map[string]interface{}, request *PatchRequest) error {
+	if !hasSchema(request.Schemas, SchemaPatchOp) {
+		return fmt.Errorf("%w: PATCH requests must use the %s schema", ErrInvalidSyntax, SchemaPatchOp)
+	}
+	if len(request.Operations) == 0 {
+		return fmt.Errorf("%w: no operations", ErrInvalidSyntax)
+	}
+
+	for _, operation := range request.Operations {
+		if err := applyOperation(resource, operation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyOperation(resource map[string]interface{}, operation PatchOperation) error {
+	// Azure AD capitalizes operation names
+	op := strings.ToLower(operation.Op)
+	switch op {
+	case "add", "replace", "remove":
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrInvalidSyntax, operation.Op)
+	}
+
+	if operation.Path == "" {
+		if op == "remove" {
+			return fmt.Errorf("%w: remove requires a path", ErrNoTarget)
+		}
+		values, ok := operation.Value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: the value of an operation without a path must be an object", ErrInvalidValue)
+		}
+		// Keys may themselves be paths; Azure AD sends {"name.givenName": "Jane"}
+		for key, value := range values {
+			if err := applyOperation(resource, PatchOperation{Op: op, Path: key, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path, err := ParsePath(operation.Path)
+	if err != nil {
+		return err
+	}
+	for _, attribute := range readOnlyAttributes {
+		if strings.EqualFold(path.Attribute, attribute) {
+			return fmt.Errorf("%w: %s", ErrMutability, attribute)
+		}
+	}
+
+	if op == "remove" {
+		return removeValue(resource, path, operation.Value)
+	}
+	if operation.Value == nil {
+		return fmt.Errorf("%w: %s requires a value", ErrInvalidValue, op)
+	}
+	return setValue(resource, path, operation.Value, op == "add")
+}
+
+// setValue adds or replaces the value at a path. Complex values are merged into
+// existing ones; adding to a multi-valued attribute appends entries not already present.
+func setValue(resource map[string]interface{}, path *Path, value interface{}, add bool) error {
+	key := keyFor(resource, path.Attribute)
+
+	if path.Filter != nil {
+		items, _ := resource[key].([]interface{})
+		matched := false
+		for i, item := range items {
+			entry, ok := item.(map[string]interface{})
+			if !ok || !path.Filter.Matches(entry) {
+				continue
+			}
+			matched = true
+			if path.SubAttribute != "" {
+				entry[keyFor(entry, path.SubAttribute)] = value
+				continue
+			}
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%w: %s entries are objects", ErrInvalidValue, path.Attribute)
+			}
+			if add {
+				merge(entry, object)
+			} else {
+				items[i] = object
+			}
+		}
+
+		if !matched {
+			// Clients set e.g. `emails[type eq "work"].value` expecting the entry to be created
+			entry := entryFromFilter(path.Filter)
+			if entry == nil {
+				return fmt.Errorf("%w: %s", ErrNoTarget, path.Attribute)
+			}
+			if path.SubAttribute != "" {
+				entry[path.SubAttribute] = value
+			} else if object, ok := value.(map[string]interface{}); ok {
+				merge(entry, object)
+			} else {
+				return fmt.Errorf("%w: %s entries are objects", ErrInvalidValue, path.Attribute)
+			}
+			items = append(items, entry)
+		}
+		resource[key] = items
+		return nil
+	}
+
+	current := resource[key]
+
+	if path.SubAttribute != "" {
+		switch current := current.(type) {
+		case map[string]interface{}:
+			current[keyFor(current, path.SubAttribute)] = value
+		case nil:
+			resource[key] = map[string]interface{}{path.SubAttribute: value}
+		default:
+			return fmt.Errorf("%w: %s has no sub-attributes", ErrInvalidPath, path.Attribute)
+		}
+		return nil
+	}
+
+	switch current := current.(type) {
+	case map[string]interface{}:
+		if object, ok := value.(map[string]interface{}); ok {
+			merge(current, object)
+			return nil
+		}
+	case []interface{}:
+		if add {
+			additions, ok := value.([]interface{})
+			if !ok {
+				additions = []interface{}{value}
+			}
+			for _, addition := range additions {
+				if !containsEntry(current, addition) {
+					current = append(current, addition)
+				}
+			}
+			resource[key] = current
+			return nil
+		}
+	}
+
+	resource[key] = value
+	return nil
+}
+
+// removeValue removes the value at a path. A value given with a path to a
+// multi-valued attribute removes just those entries, as Azure AD does for members.
+func removeValue(resource map[string]interface{}, path *Path, value interface{}) error {
+	key := keyFor(resource, path.Attribute)
+	current, exists := resource[key]
+	if !exists {
+		return nil
+	}
+
+	if path.Filter != nil {
+		items, _ := current.([]interface{})
+		kept := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			entry, ok := item.(map[string]interface{})
+			if ok && path.Filter.Matches(entry) {
+				if path.SubAttribute != "" {
+					delete(entry, keyFor(entry, path.SubAttribute))
+					kept = append(kept, entry)
+				}
+				continue
+			}
+			kept = append(kept, item)
+		}
+		setOrDelete(resource, key, kept)
+		return nil
+	}
+
+	if path.SubAttribute != "" {
+		if entry, ok := current.(map[string]interface{}); ok {
+			delete(entry, keyFor(entry, path.SubAttribute))
+		}
+		return nil
+	}
+
+	if items, ok := current.([]interface{}); ok && value != nil {
+		removals, ok := value.([]interface{})
+		if !ok {
+			removals = []interface{}{value}
+		}
+		kept := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			if !containsEntry(removals, item) {
+				kept = append(kept, item)
+			}
+		}
+		setOrDelete(resource, key, kept)
+		return nil
+	}
+
+	delete(resource, key)
+	return nil
+}
+
+// entryFromFilter builds the multi-valued attribute entry an equality filter describes
+func entryFromFilter(filter Filter) map[string]interface{} {
+	compare, ok := filter.(*compareFilter)
+	if !ok || compare.op != "eq" || compare.path.SubAttribute != "" || compare.value == nil {
+		return nil
+	}
+	return map[string]interface{}{compare.path.Attribute: compare.value}
+}
+
+// containsEntry checks if a multi-valued attribute holds an entry; complex entries
+// are the same if their "value" sub-attributes are
+func containsEntry(items []interface{}, entry interface{}) bool {
+	for _, item := range items {
+		if sameEntry(item, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameEntry(a, b interface{}) bool {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if aok && bok {
+		av, aHas := lookup(am, "value")
+		bv, bHas := lookup(bm, "value")
+		if aHas && bHas {
+			return reflect.DeepEqual(av, bv)
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// merge copies sub-attributes into a complex value, matching names case-insensitively
+func merge(target, source map[string]interface{}) {
+	for key, value := range source {
+		target[keyFor(target, key)] = value
+	}
+}
+
+// keyFor returns the key an attribute is stored under, which may differ in case from name
+func keyFor(m map[string]interface{}, name string) string {
+	if _, ok := m[name]; ok {
+		return name
+	}
+	for key := range m {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
+
+func setOrDelete(m map[string]interface{}, key string, items []interface{}) {
+	if len(items) == 0 {
+		delete(m, key)
+		return
+	}
+	m[key] = items
+}
+
+func hasSchema(schemas []string, schema string) bool {
+	for _, s := range schemas {
+		if strings.EqualFold(s, schema) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package scim
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// patch decodes a PATCH body and applies it to the test user
+func patch(t *testing.T, body string) (*UserResource, error) {
+	t.Helper()
+
+	var request PatchRequest
+	require.NoError(t, json.Unmarshal([]byte(body), &request))
+
+	resource := testUser(t)
+	if err := ApplyPatch(resource, &request); err != nil {
+		return nil, err
+	}
+
+	var result UserResource
+	if err := FromMap(resource, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func TestApplyPatch(t *testing.T) {
+	t.Run("replaces attributes and sub-attributes by path", func(t *testing.T) {
+		result, err := patch(t, `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [
+				{"op": "replace", "path": "name.familyName", "value": "Smith"},
+				{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "jane.smith@acme.com"}
+			]
+		}`)
+		require.NoError(t, err)
+		assert.Equal(t, "Smith", result.Name.FamilyName)
+		assert.Equal(t, "Jane", result.Name.GivenName)
+		assert.Equal(t, "jane.smith@acme.com", result.PrimaryEmail())
+		assert.Len(t, result.Emails, 2)
+	})
+
+	t.Run("accepts Azure AD style operations without a path", func(t *testing.T) {
+		result, err := patch(t, `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [
+				{"op": "Replace", "value": {"active": "False", "name.givenName": "Janet"}}
+			]
+		}`)
+		require.NoError(t, err)
+		assert.False(t, result.IsActive())
+		assert.Equal(t, "Janet", result.Name.GivenName)
+	})
+
+	t.Run("creates entries described by an equality filter", func(t *testing.T) {
+		result, err := patch(t, `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [
+				{"op": "add", "path": "phoneNumbers[type eq \"mobile\"].value", "value": "+15550100"}
+			]
+		}`)
+		require.NoError(t, err)
+		require.Len(t, result.PhoneNumbers, 1)
+		assert.Equal(t, MultiValue{Value: "+15550100", Type: "mobile"}, result.PhoneNumbers[0])
+	})
+
+	t.Run("adds to and removes from multi-valued attributes", func(t *testing.T) {
+		result, err := patch(t, `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [
+				{"op": "add", "path": "emails", "value": [{"value": "jane@acme.com"}, {"value": "jd@acme.com", "type": "other"}]},
+				{"op": "remove", "path": "emails[type eq \"home\"]"},
+				{"op": "remove", "path": "emails", "value": [{"value": "JD@acme.com"}, {"value": "jd@acme.com"}]}
+			]
+		}`)
+		require.NoError(t, err)
+		require.Len(t, result.Emails, 1)
+		assert.Equal(t, "jane@acme.com", result.Emails[0].Value)
+	})
+
+	t.Run("ignores attributes the resource does not support", func(t *testing.T) {
+		result, err := patch(t, `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [
+				{"op": "add", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", "value": "R&D"},
+				{"op": "remove", "path": "title"}
+			]
+		}`)
+		require.NoError(t, err)
+		assert.Equal(t, "jane@acme.com", result.PrimaryEmail())
+	})
+
+	t.Run("rejects invalid operations", func(t *testing.T) {
+		tests := []struct {
+			body string
+			want error
+		}{
+			{`{"schemas": [], "Operations": [{"op": "remove", "path": "title"}]}`, ErrInvalidSyntax},
+			{`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "move", "path": "title"}]}`, ErrInvalidSyntax},
+			{`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "remove"}]}`, ErrNoTarget},
+			{`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "id", "value": "x"}]}`, ErrMutability},
+			{`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "emails[type co \"z\"].value", "value": "x"}]}`, ErrNoTarget},
+			{`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "userName.first", "value": "x"}]}`, ErrInvalidPath},
+		}
+		for _, tt := range tests {
+			_, err := patch(t, tt.body)
+			assert.ErrorIs(t, err, tt.want, tt.body)
+		}
+
+		_, err := patch(t, `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [{"op": "replace", "path": "active", "value": "maybe"}]
+		}`)
+		assert.ErrorIs(t, err, ErrInvalidValue)
+	})
+}
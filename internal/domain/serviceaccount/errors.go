@@ -0,0 +1,23 @@
+package serviceaccount
+
+import "errors"
+
+var (
+	// ErrServiceAccountNotFound is returned when a service account does not exist
+	ErrServiceAccountNotFound = errors.New("service account not found")
+
+	// ErrServiceAccountExists is returned when a service account name is already taken
+	ErrServiceAccountExists = errors.New("service account already exists")
+
+	// ErrInvalidCredentials is returned when a client ID and secret do not match an active service account
+	ErrInvalidCredentials = errors.New("invalid service account credentials")
+
+	// ErrInvalidRole is returned when a role name is empty or malformed
+	ErrInvalidRole = errors.New("invalid role")
+
+	// ErrRoleAlreadyAssigned is returned when a service account already has a role
+	ErrRoleAlreadyAssigned = errors.New("role already assigned to service account")
+
+	// ErrRoleNotAssigned is returned when removing a role the service account does not have
+	ErrRoleNotAssigned = errors.New("role not assigned to service account")
+)
@@ -0,0 +1,39 @@
+package serviceaccount
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Repository defines the interface for service account persistence.
+// Accounts are returned with their roles loaded.
+type Repository interface {
+	// Create stores a new service account without roles
+	Create(ctx context.Context, account *ServiceAccount) error
+
+	// GetByID retrieves a service account by ID
+	GetByID(ctx context.Context, id uuid.UUID) (*ServiceAccount, error)
+
+	// GetByClientID retrieves a service account by its client ID
+	GetByClientID(ctx context.Context, clientID string) (*ServiceAccount, error)
+
+	// List returns every service account ordered by name
+	List(ctx context.Context) ([]*ServiceAccount, error)
+
+	// Update saves the name, description, status and secret of a service account
+	Update(ctx context.Context, account *ServiceAccount) error
+
+	// Delete removes a service account and its role assignments
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// AssignRole grants a role to a service account
+	AssignRole(ctx context.Context, assignment *RoleAssignment) error
+
+	// RemoveRole withdraws a role from a service account
+	RemoveRole(ctx context.Context, id uuid.UUID, role string) error
+
+	// UpdateLastAuthenticated records when a service account last obtained a token
+	UpdateLastAuthenticated(ctx context.Context, id uuid.UUID, at time.Time) error
+}
@@ -0,0 +1,74 @@
+package serviceaccount
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status represents whether a service account may obtain tokens
+type Status string
+
+const (
+	StatusActive   Status = "active"
+	StatusDisabled Status = "disabled"
+)
+
+// ServiceAccount is a non-interactive principal used by workers and other services.
+// It authenticates with the client_credentials grant; only a hash of its secret is stored.
+type ServiceAccount struct {
+	ID                  uuid.UUID  `json:"id"`
+	Name                string     `json:"name"`
+	Description         string     `json:"description,omitempty"`
+	ClientID            string     `json:"client_id"`
+	SecretHash          string     `json:"-"`
+	Roles               []string   `json:"roles"`
+	Status              Status     `json:"status"`
+	CreatedBy           uuid.UUID  `json:"created_by"`
+	LastAuthenticatedAt *time.Time `json:"last_authenticated_at,omitempty"`
+	SecretRotatedAt     time.Time  `json:"secret_rotated_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// IsActive checks if the account may obtain tokens
+func (a *ServiceAccount) IsActive() bool {
+	return a.Status == StatusActive
+}
+
+// HasRole checks if the account has been assigned a role
+func (a *ServiceAccount) HasRole(role string) bool {
+	for _, r := range a.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleAssignment records a role granted to a service account
+type RoleAssignment struct {
+	ServiceAccountID uuid.UUID `json:"service_account_id"`
+	Role             string    `json:"role"`
+	GrantedBy        uuid.UUID `json:"granted_by"`
+	GrantedAt        time.Time `json:"granted_at"`
+}
+
+// CreateRequest represents a request to create a service account
+type CreateRequest struct {
+	Name        string   `json:"name" binding:"required,max=100"`
+	Description string   `json:"description" binding:"max=500"`
+	Roles       []string `json:"roles"`
+}
+
+// UpdateRequest represents a request to update a service account
+type UpdateRequest struct {
+	Name        *string `json:"name" binding:"omitempty,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=500"`
+	Status      *Status `json:"status" binding:"omitempty,oneof=active disabled"`
+}
+
+// AssignRoleRequest represents a request to grant a role to a service account
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required,max=100"`
+}
@@ -0,0 +1,17 @@
+package session
+
+import "errors"
+
+var (
+	// ErrSessionNotFound is returned when a session does not exist or has been removed
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrSessionLimitReached is returned when a sign-in would exceed the concurrent session cap
+	ErrSessionLimitReached = errors.New("concurrent session limit reached")
+
+	// ErrSessionIdle is returned when a session has gone unused past its idle timeout
+	ErrSessionIdle = errors.New("session idle timeout exceeded")
+
+	// ErrSessionExpired is returned when a session has outlived its maximum lifetime
+	ErrSessionExpired = errors.New("session has expired")
+)
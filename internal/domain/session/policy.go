@@ -0,0 +1,81 @@
+package session
+
+import "time"
+
+// LimitAction is what happens to a sign-in that would exceed the concurrent session cap
+type LimitAction string
+
+const (
+	// LimitEvictOldest signs the user's oldest sessions out to make room
+	LimitEvictOldest LimitAction = "evict_oldest"
+
+	// LimitReject refuses the new sign-in
+	LimitReject LimitAction = "reject"
+)
+
+// Policy controls how long sessions last and how many a user may hold at once.
+// Zero values mean no limit.
+type Policy struct {
+	// IdleTimeout ends a session that has gone this long without being used
+	IdleTimeout time.Duration `json:"idle_timeout,omitempty"`
+
+	// SlidingExpiry is how long a session survives without its tokens being
+	// refreshed; each refresh extends it, up to MaxLifetime
+	SlidingExpiry time.Duration `json:"sliding_expiry,omitempty"`
+
+	// MaxLifetime ends a session this long after sign-in, however active it is
+	MaxLifetime time.Duration `json:"max_lifetime,omitempty"`
+
+	// MaxConcurrent caps the sessions a user may hold at once
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+
+	// OnLimit is what a sign-in over MaxConcurrent does; defaults to LimitEvictOldest
+	OnLimit LimitAction `json:"on_limit,omitempty"`
+}
+
+// IsZero reports whether the policy sets no limits
+func (p Policy) IsZero() bool {
+	return p.IdleTimeout == 0 && p.SlidingExpiry == 0 && p.MaxLifetime == 0 && p.MaxConcurrent == 0
+}
+
+// Override returns the policy with every limit o sets replacing its own
+func (p Policy) Override(o Policy) Policy {
+	if o.IdleTimeout > 0 {
+		p.IdleTimeout = o.IdleTimeout
+	}
+	if o.SlidingExpiry > 0 {
+		p.SlidingExpiry = o.SlidingExpiry
+	}
+	if o.MaxLifetime > 0 {
+		p.MaxLifetime = o.MaxLifetime
+	}
+	if o.MaxConcurrent > 0 {
+		p.MaxConcurrent = o.MaxConcurrent
+	}
+	if o.OnLimit != "" {
+		p.OnLimit = o.OnLimit
+	}
+	return p
+}
+
+// Tighten returns the stricter of the two policies, limit by limit
+func (p Policy) Tighten(o Policy) Policy {
+	p.IdleTimeout = stricterDuration(p.IdleTimeout, o.IdleTimeout)
+	p.SlidingExpiry = stricterDuration(p.SlidingExpiry, o.SlidingExpiry)
+	p.MaxLifetime = stricterDuration(p.MaxLifetime, o.MaxLifetime)
+	if o.MaxConcurrent > 0 && (p.MaxConcurrent == 0 || o.MaxConcurrent < p.MaxConcurrent) {
+		p.MaxConcurrent = o.MaxConcurrent
+	}
+	if o.OnLimit == LimitReject || p.OnLimit == "" {
+		p.OnLimit = o.OnLimit
+	}
+	return p
+}
+
+// stricterDuration returns the shorter of two limits, where zero is no limit
+func stricterDuration(a, b time.Duration) time.Duration {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
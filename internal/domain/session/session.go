@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/risk"
 )
 
 // Session represents an active user session
@@ -17,6 +19,9 @@ type Session struct {
 	LastActivity time.Time `json:"last_activity"`
 	ExpiresAt    time.Time `json:"expires_at"`
 	IsActive     bool      `json:"is_active"`
+
+	// Location is where the session was started from, if the address could be located
+	Location *risk.Location `json:"location,omitempty"`
 }
 
 // IsExpired checks if the session has expired
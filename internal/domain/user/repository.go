@@ -33,8 +33,14 @@ type Repository interface {
 	// UpdateLastLogin updates the last login timestamp
 	UpdateLastLogin(ctx context.Context, id uuid.UUID, loginTime time.Time) error
 
-	// IncrementFailedLoginAttempts increments failed login attempts
-	IncrementFailedLoginAttempts(ctx context.Context, id uuid.UUID) error
+	// IncrementFailedLoginAttempts increments failed login attempts and returns the new count
+	IncrementFailedLoginAttempts(ctx context.Context, id uuid.UUID) (int, error)
+
+	// Lock locks a user out of signing in until the given time
+	Lock(ctx context.Context, id uuid.UUID, until time.Time) error
+
+	// Unlock clears a lockout and the failed login attempts that led to it
+	Unlock(ctx context.Context, id uuid.UUID) error
 
 	// UpdateMFA updates MFA settings for a user
 	UpdateMFA(ctx context.Context, id uuid.UUID, enabled bool, secret string, backupCodes []string) error
@@ -45,3 +51,10 @@ type Repository interface {
 	// ExistsByUsername checks if a user exists with the given username
 	ExistsByUsername(ctx context.Context, username string) (bool, error)
 }
+
+// PasswordHashReader walks stored password hashes, e.g. to report which
+// algorithms they were produced with
+type PasswordHashReader interface {
+	// EachPasswordHash calls fn with the password hash of every account
+	EachPasswordHash(ctx context.Context, fn func(hash string) error) error
+}
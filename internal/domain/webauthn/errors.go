@@ -0,0 +1,36 @@
+package webauthn
+
+import "errors"
+
+var (
+	// ErrCredentialNotFound is returned when a credential does not exist
+	ErrCredentialNotFound = errors.New("WebAuthn credential not found")
+
+	// ErrCredentialExists is returned when an authenticator's credential is already registered
+	ErrCredentialExists = errors.New("WebAuthn credential already registered")
+
+	// ErrInvalidCredentialName is returned when a credential name is empty
+	ErrInvalidCredentialName = errors.New("credential name is required")
+
+	// ErrCeremonyNotFound is returned when a ceremony is unknown or has already been completed
+	ErrCeremonyNotFound = errors.New("WebAuthn ceremony not found")
+
+	// ErrCeremonyExpired is returned when a ceremony is completed after its timeout
+	ErrCeremonyExpired = errors.New("WebAuthn ceremony has expired")
+
+	// ErrVerificationFailed is returned when an authenticator response does not verify
+	ErrVerificationFailed = errors.New("WebAuthn verification failed")
+
+	// ErrUnsupportedAlgorithm is returned when a credential key uses an algorithm that is not accepted
+	ErrUnsupportedAlgorithm = errors.New("unsupported credential algorithm")
+
+	// ErrUnsupportedAttestation is returned when an attestation statement format is not supported
+	ErrUnsupportedAttestation = errors.New("unsupported attestation format")
+
+	// ErrUserVerificationRequired is returned when the authenticator did not verify the user
+	ErrUserVerificationRequired = errors.New("user verification required")
+
+	// ErrCloneDetected is returned when a signature counter does not advance, which suggests
+	// the authenticator's key has been copied
+	ErrCloneDetected = errors.New("authenticator signature counter did not increase")
+)
@@ -0,0 +1,50 @@
+package webauthn
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CredentialRepository defines the interface for WebAuthn credential persistence
+type CredentialRepository interface {
+	// Create stores a new credential
+	Create(ctx context.Context, credential *Credential) error
+
+	// GetByCredentialID retrieves a credential by the ID the authenticator assigned to it
+	GetByCredentialID(ctx context.Context, credentialID []byte) (*Credential, error)
+
+	// ListByUser returns every credential registered by a user
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*Credential, error)
+
+	// Rename changes the name of a user's credential
+	Rename(ctx context.Context, userID, id uuid.UUID, name string) error
+
+	// UpdateSignCount records a successful assertion and the authenticator's new counter
+	UpdateSignCount(ctx context.Context, id uuid.UUID, signCount uint32, usedAt time.Time) error
+
+	// Delete removes a user's credential
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+}
+
+// CeremonyRepository defines the interface for pending ceremony persistence
+type CeremonyRepository interface {
+	// Create stores a new ceremony
+	Create(ctx context.Context, ceremony *Ceremony) error
+
+	// Consume retrieves and deletes a ceremony so its challenge cannot be answered twice
+	Consume(ctx context.Context, id uuid.UUID) (*Ceremony, error)
+
+	// DeleteExpired removes ceremonies that were never completed
+	DeleteExpired(ctx context.Context) error
+}
+
+// Verifier checks authenticator responses against the relying party's ID and origins
+type Verifier interface {
+	// VerifyRegistration validates a create() response for a ceremony and returns the new credential
+	VerifyRegistration(ceremony *Ceremony, response *RegistrationResponse) (*AttestedCredential, error)
+
+	// VerifyAssertion validates a get() response for a ceremony against a stored credential
+	VerifyAssertion(ceremony *Ceremony, credential *Credential, response *AssertionResponse) (*AssertionResult, error)
+}
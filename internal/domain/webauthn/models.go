@@ -0,0 +1,240 @@
+package webauthn
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// COSE algorithm identifiers accepted for credential keys
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// Values used in the ceremony options sent to the browser
+const (
+	PublicKeyCredentialType = "public-key"
+
+	UserVerificationRequired  = "required"
+	UserVerificationPreferred = "preferred"
+
+	ResidentKeyPreferred = "preferred"
+
+	AttestationNone = "none"
+)
+
+// CeremonyType identifies which WebAuthn ceremony a challenge belongs to
+type CeremonyType string
+
+const (
+	CeremonyRegistration   CeremonyType = "registration"
+	CeremonyAuthentication CeremonyType = "authentication"
+)
+
+// Base64URL is binary data carried as unpadded base64url in JSON, as browsers encode it
+type Base64URL []byte
+
+// MarshalJSON encodes the bytes as unpadded base64url
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON decodes base64url with or without padding
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Credential is a registered authenticator key. Only the public key is stored.
+type Credential struct {
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	Name           string     `json:"name"`
+	CredentialID   Base64URL  `json:"credential_id"`
+	PublicKey      []byte     `json:"-"` // COSE_Key encoding
+	Algorithm      int64      `json:"algorithm"`
+	SignCount      uint32     `json:"sign_count"`
+	AAGUID         Base64URL  `json:"aaguid,omitempty"`
+	Transports     []string   `json:"transports,omitempty"`
+	BackupEligible bool       `json:"backup_eligible"` // Synced passkeys may exist on several devices
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Ceremony is the server side of a registration or authentication ceremony.
+// It is single-use; UserID is nil for passwordless logins where the user is not yet known.
+type Ceremony struct {
+	ID               uuid.UUID    `json:"id"`
+	Type             CeremonyType `json:"type"`
+	UserID           *uuid.UUID   `json:"user_id,omitempty"`
+	Challenge        []byte       `json:"-"`
+	CredentialName   string       `json:"credential_name,omitempty"`   // Name for the credential being registered
+	UserVerification string       `json:"user_verification,omitempty"` // Requirement sent with the options
+	ExpiresAt        time.Time    `json:"expires_at"`
+	CreatedAt        time.Time    `json:"created_at"`
+}
+
+// IsExpired checks if the ceremony has timed out
+func (c *Ceremony) IsExpired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
+
+// RequiresUserVerification checks if the authenticator must have verified the user
+func (c *Ceremony) RequiresUserVerification() bool {
+	return c.UserVerification == UserVerificationRequired
+}
+
+// AttestedCredential is a credential extracted from a verified registration response
+type AttestedCredential struct {
+	CredentialID   []byte
+	PublicKey      []byte
+	Algorithm      int64
+	SignCount      uint32
+	AAGUID         []byte
+	UserVerified   bool
+	BackupEligible bool
+}
+
+// AssertionResult is what a verified authentication response reports
+type AssertionResult struct {
+	SignCount    uint32
+	UserVerified bool
+}
+
+// RelyingPartyEntity identifies this service to authenticators
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity identifies the account a credential is created for
+type UserEntity struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+// CredentialParameter is an acceptable credential type and algorithm
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// CredentialDescriptor refers to an existing credential
+type CredentialDescriptor struct {
+	Type       string    `json:"type"`
+	ID         Base64URL `json:"id"`
+	Transports []string  `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelection states requirements on the authenticator
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey,omitempty"`
+	UserVerification string `json:"userVerification,omitempty"`
+}
+
+// CreationOptions are the options for navigator.credentials.create()
+type CreationOptions struct {
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              Base64URL              `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"` // Milliseconds
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the options for navigator.credentials.get()
+type RequestOptions struct {
+	Challenge        Base64URL              `json:"challenge"`
+	Timeout          int64                  `json:"timeout"` // Milliseconds
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RegistrationOptions starts a registration ceremony
+type RegistrationOptions struct {
+	CeremonyID uuid.UUID        `json:"ceremony_id"`
+	PublicKey  *CreationOptions `json:"publicKey"`
+}
+
+// LoginOptions starts an authentication ceremony
+type LoginOptions struct {
+	CeremonyID uuid.UUID       `json:"ceremony_id"`
+	PublicKey  *RequestOptions `json:"publicKey"`
+}
+
+// AttestationResponse is the authenticator's reply to a create() call
+type AttestationResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON" binding:"required"`
+	AttestationObject Base64URL `json:"attestationObject" binding:"required"`
+	Transports        []string  `json:"transports,omitempty"`
+}
+
+// RegistrationResponse is the PublicKeyCredential returned by create()
+type RegistrationResponse struct {
+	ID       string              `json:"id"`
+	RawID    Base64URL           `json:"rawId" binding:"required"`
+	Type     string              `json:"type"`
+	Response AttestationResponse `json:"response"`
+}
+
+// AuthenticatorAssertionResponse is the authenticator's reply to a get() call
+type AuthenticatorAssertionResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData Base64URL `json:"authenticatorData" binding:"required"`
+	Signature         Base64URL `json:"signature" binding:"required"`
+	UserHandle        Base64URL `json:"userHandle,omitempty"`
+}
+
+// AssertionResponse is the PublicKeyCredential returned by get()
+type AssertionResponse struct {
+	ID       string                         `json:"id"`
+	RawID    Base64URL                      `json:"rawId" binding:"required"`
+	Type     string                         `json:"type"`
+	Response AuthenticatorAssertionResponse `json:"response"`
+}
+
+// BeginRegistrationRequest represents a request to register a new credential
+type BeginRegistrationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// FinishRegistrationRequest completes a registration ceremony
+type FinishRegistrationRequest struct {
+	CeremonyID uuid.UUID            `json:"ceremony_id" binding:"required"`
+	Credential RegistrationResponse `json:"credential"`
+}
+
+// FinishLoginRequest completes an authentication ceremony
+type FinishLoginRequest struct {
+	CeremonyID uuid.UUID         `json:"ceremony_id" binding:"required"`
+	Credential AssertionResponse `json:"credential"`
+}
+
+// RenameCredentialRequest represents a request to rename a credential
+type RenameCredentialRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// Constants for ceremony configuration
+const (
+	ChallengeLength = 32
+	CeremonyTimeout = 5 * time.Minute
+)
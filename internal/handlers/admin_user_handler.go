@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// AdminUserHandler handles user administration endpoints
+type AdminUserHandler struct {
+	lockoutService *services.LockoutService
+	logger         *zap.Logger
+}
+
+// NewAdminUserHandler creates a new user administration handler
+func NewAdminUserHandler(lockoutService *services.LockoutService, logger *zap.Logger) *AdminUserHandler {
+	return &AdminUserHandler{
+		lockoutService: lockoutService,
+		logger:         logger,
+	}
+}
+
+// AdminUserResponse represents a user administration response
+type AdminUserResponse struct {
+	Success bool           `json:"success"`
+	Data    *AdminUserData `json:"data,omitempty"`
+	Error   *ErrorResponse `json:"error,omitempty"`
+}
+
+type AdminUserData struct {
+	UserID string      `json:"user_id"`
+	Status user.Status `json:"status"`
+}
+
+// Activate lifts a lockout and reactivates a locked or suspended account
+func (h *AdminUserHandler) Activate(c *gin.Context) {
+	actor, ok := requireActor(c)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, AdminUserResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_USER_ID",
+				Message: "Invalid user ID format",
+			},
+		})
+		return
+	}
+
+	u, err := h.lockoutService.Unlock(c.Request.Context(), userID, actor)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, AdminUserResponse{
+				Success: false,
+				Error: &ErrorResponse{
+					Code:    "USER_NOT_FOUND",
+					Message: "User not found",
+				},
+			})
+			return
+		}
+
+		h.logger.Error("Failed to activate user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, AdminUserResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to activate user",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, AdminUserResponse{
+		Success: true,
+		Data: &AdminUserData{
+			UserID: u.ID.String(),
+			Status: u.Status,
+		},
+	})
+}
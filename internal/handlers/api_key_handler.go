@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/apikey"
+	"github.com/victoralfred/um_sys/internal/domain/organization"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// APIKeyHandler handles API key management endpoints
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+	logger        *zap.Logger
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService, logger *zap.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+		logger:        logger,
+	}
+}
+
+// APIKeyResponse represents an API key management response
+type APIKeyResponse struct {
+	Success bool           `json:"success"`
+	Data    *APIKeyData    `json:"data,omitempty"`
+	Error   *ErrorResponse `json:"error,omitempty"`
+}
+
+type APIKeyData struct {
+	Key  *apikey.APIKey   `json:"key,omitempty"`
+	Keys []*apikey.APIKey `json:"keys,omitempty"`
+	// Secret is only returned when the key is created
+	Secret string `json:"secret,omitempty"`
+}
+
+// CreateKey issues an API key for the current user
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	h.createKey(c, func(req *apikey.CreateRequest) (*apikey.APIKey, string, error) {
+		return h.apiKeyService.CreateKey(
+			c.Request.Context(),
+			apikey.OwnerUser,
+			userID,
+			userID,
+			req,
+			c.GetStringSlice("permissions"),
+		)
+	})
+}
+
+// CreateOrganizationKey issues an API key owned by an organization
+func (h *APIKeyHandler) CreateOrganizationKey(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	h.createKey(c, func(req *apikey.CreateRequest) (*apikey.APIKey, string, error) {
+		return h.apiKeyService.CreateOrganizationKey(
+			c.Request.Context(),
+			orgID,
+			userID,
+			req,
+			c.GetStringSlice("permissions"),
+		)
+	})
+}
+
+// ListKeys lists the current user's API keys
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	h.listKeys(c, apikey.OwnerUser, userID)
+}
+
+// RevokeKey revokes one of the current user's API keys
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	keyID, ok := parseKeyID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(c.Request.Context(), apikey.OwnerUser, userID, keyID); err != nil {
+		h.apiKeyError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListOrganizationKeys lists an organization's API keys
+func (h *APIKeyHandler) ListOrganizationKeys(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKeyService.ListOrganizationKeys(c.Request.Context(), orgID, userID)
+	if err != nil {
+		h.apiKeyError(c, err)
+		return
+	}
+
+	h.respondKeys(c, keys)
+}
+
+// RevokeOrganizationKey revokes one of an organization's API keys
+func (h *APIKeyHandler) RevokeOrganizationKey(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	keyID, ok := parseKeyID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeyService.RevokeOrganizationKey(c.Request.Context(), orgID, userID, keyID); err != nil {
+		h.apiKeyError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AdminListKeys lists the API keys of any owner
+func (h *APIKeyHandler) AdminListKeys(c *gin.Context) {
+	ownerType := apikey.OwnerType(c.DefaultQuery("owner_type", string(apikey.OwnerUser)))
+	if ownerType != apikey.OwnerUser && ownerType != apikey.OwnerOrganization {
+		c.JSON(http.StatusBadRequest, APIKeyResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "owner_type must be user or organization",
+			},
+		})
+		return
+	}
+
+	ownerID, err := uuid.Parse(c.Query("owner_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIKeyResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "owner_id is required",
+			},
+		})
+		return
+	}
+
+	h.listKeys(c, ownerType, ownerID)
+}
+
+// AdminRevokeKey revokes any API key
+func (h *APIKeyHandler) AdminRevokeKey(c *gin.Context) {
+	keyID, ok := parseKeyID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeyService.RevokeAnyKey(c.Request.Context(), keyID); err != nil {
+		h.apiKeyError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// createKey binds a create request and responds with the key made by create
+func (h *APIKeyHandler) createKey(c *gin.Context, create func(req *apikey.CreateRequest) (*apikey.APIKey, string, error)) {
+	// A leaked key must not be able to mint longer-lived or broader keys
+	if _, viaKey := c.Get("api_key_id"); viaKey {
+		c.JSON(http.StatusForbidden, APIKeyResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "API_KEY_NOT_ALLOWED",
+				Message: "API keys cannot be used to create API keys",
+			},
+		})
+		return
+	}
+
+	var req apikey.CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIKeyResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	key, secret, err := create(&req)
+	if err != nil {
+		h.apiKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIKeyResponse{
+		Success: true,
+		Data:    &APIKeyData{Key: key, Secret: secret},
+	})
+}
+
+func (h *APIKeyHandler) listKeys(c *gin.Context, ownerType apikey.OwnerType, ownerID uuid.UUID) {
+	keys, err := h.apiKeyService.ListKeys(c.Request.Context(), ownerType, ownerID)
+	if err != nil {
+		h.apiKeyError(c, err)
+		return
+	}
+
+	h.respondKeys(c, keys)
+}
+
+func (h *APIKeyHandler) respondKeys(c *gin.Context, keys []*apikey.APIKey) {
+	if keys == nil {
+		keys = []*apikey.APIKey{}
+	}
+
+	c.JSON(http.StatusOK, APIKeyResponse{
+		Success: true,
+		Data:    &APIKeyData{Keys: keys},
+	})
+}
+
+func parseKeyID(c *gin.Context) (uuid.UUID, bool) {
+	keyID, err := uuid.Parse(c.Param("keyId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIKeyResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_KEY_ID",
+				Message: "Invalid API key ID format",
+			},
+		})
+		return uuid.Nil, false
+	}
+	return keyID, true
+}
+
+// apiKeyError maps API key errors to responses
+func (h *APIKeyHandler) apiKeyError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "API key operation failed"
+
+	switch {
+	case errors.Is(err, apikey.ErrAPIKeyNotFound):
+		status, code, message = http.StatusNotFound, "API_KEY_NOT_FOUND", "API key not found"
+	case errors.Is(err, apikey.ErrInvalidScope):
+		status, code, message = http.StatusBadRequest, "INVALID_SCOPE", "Scopes must be resource:action permissions"
+	case errors.Is(err, apikey.ErrScopeNotGrantable):
+		status, code, message = http.StatusForbidden, "SCOPE_NOT_GRANTABLE", "You cannot grant a permission you do not hold"
+	case errors.Is(err, apikey.ErrInvalidAllowedIP):
+		status, code, message = http.StatusBadRequest, "INVALID_ALLOWED_IP", "IP allowlist entries must be IP addresses or CIDR ranges"
+	case errors.Is(err, apikey.ErrInvalidExpiry):
+		status, code, message = http.StatusBadRequest, "INVALID_EXPIRY", "Expiry must be in the future and within the maximum key lifetime"
+	case errors.Is(err, apikey.ErrTooManyKeys):
+		status, code, message = http.StatusConflict, "API_KEY_LIMIT_REACHED", "The maximum number of active API keys has been reached"
+	case errors.Is(err, organization.ErrOrganizationNotFound):
+		status, code, message = http.StatusNotFound, "ORGANIZATION_NOT_FOUND", "Organization not found"
+	case errors.Is(err, organization.ErrInsufficientRole):
+		status, code, message = http.StatusForbidden, "INSUFFICIENT_ROLE", "Your role in the organization does not allow this"
+	default:
+		h.logger.Error("API key operation failed", zap.Error(err))
+	}
+
+	c.JSON(status, APIKeyResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+			Details: detailsFor(status, err),
+		},
+	})
+}
+
+// detailsFor exposes the error text for client errors only
+func detailsFor(status int, err error) string {
+	if status >= http.StatusInternalServerError {
+		return ""
+	}
+	return err.Error()
+}
@@ -1,13 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+	"github.com/victoralfred/um_sys/internal/domain/session"
 	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/domain/webauthn"
 	"github.com/victoralfred/um_sys/internal/services"
 	"github.com/victoralfred/um_sys/pkg/security"
 	"go.uber.org/zap"
@@ -17,8 +22,13 @@ import (
 type AuthHandler struct {
 	userService       *services.UserService
 	tokenService      *services.TokenService
-	passwordHasher    *security.PasswordHasher
+	passwordHasher    security.Hasher
 	passwordValidator *security.PasswordValidator
+	sessionService    *services.SessionService
+	emailVerification *services.EmailVerificationService
+	lockout           *services.LockoutService
+	authService       *services.AuthService
+	trustedDevices    *services.TrustedDeviceService
 	logger            *zap.Logger
 }
 
@@ -26,19 +36,41 @@ type AuthHandler struct {
 func NewAuthHandler(
 	userService *services.UserService,
 	tokenService *services.TokenService,
-	passwordHasher *security.PasswordHasher,
+	authService *services.AuthService,
+	passwordHasher security.Hasher,
 	passwordValidator *security.PasswordValidator,
 	logger *zap.Logger,
 ) *AuthHandler {
 	return &AuthHandler{
 		userService:       userService,
 		tokenService:      tokenService,
+		authService:       authService,
 		passwordHasher:    passwordHasher,
 		passwordValidator: passwordValidator,
 		logger:            logger,
 	}
 }
 
+// SetSessionService enables server-side sessions for logins
+func (h *AuthHandler) SetSessionService(sessionService *services.SessionService) {
+	h.sessionService = sessionService
+}
+
+// SetEmailVerificationService enables verification emails and the verification login policy
+func (h *AuthHandler) SetEmailVerificationService(emailVerification *services.EmailVerificationService) {
+	h.emailVerification = emailVerification
+}
+
+// SetLockoutService enables the progressive lockout and sign-in throttling policy
+func (h *AuthHandler) SetLockoutService(lockout *services.LockoutService) {
+	h.lockout = lockout
+}
+
+// SetTrustedDeviceService lets browsers be remembered so their sign-ins skip MFA
+func (h *AuthHandler) SetTrustedDeviceService(trustedDevices *services.TrustedDeviceService) {
+	h.trustedDevices = trustedDevices
+}
+
 // RegisterRequest represents registration request
 type RegisterRequest struct {
 	Email     string `json:"email" binding:"required,email"`
@@ -77,7 +109,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// Validate password strength
-	validationResult, err := h.passwordValidator.Validate(req.Password, req.Username, req.Email)
+	validationResult, err := h.passwordValidator.Validate(c.Request.Context(), req.Password, req.Username, req.Email)
 	if err != nil || !validationResult.IsValid {
 		c.JSON(http.StatusBadRequest, RegisterResponse{
 			Success: false,
@@ -155,7 +187,12 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// TODO: Send verification email
+	// Registration succeeds even if the email is lost; the user can request a resend
+	if h.emailVerification != nil {
+		if err := h.emailVerification.SendVerification(c.Request.Context(), newUser); err != nil {
+			h.logger.Warn("Failed to send verification email", zap.Error(err))
+		}
+	}
 
 	c.JSON(http.StatusCreated, RegisterResponse{
 		Success: true,
@@ -172,6 +209,15 @@ type LoginRequest struct {
 	Email    string `json:"email" binding:"required_without=Username"`
 	Username string `json:"username" binding:"required_without=Email"`
 	Password string `json:"password" binding:"required"`
+
+	// MFAMethod and MFACode answer a second-factor challenge; security keys answer
+	// the challenge from /auth/login/security-key with MFAAssertion instead
+	MFAMethod    mfa.Method                   `json:"mfa_method,omitempty" binding:"required_with=MFACode MFAAssertion"`
+	MFACode      string                       `json:"mfa_code,omitempty"`
+	MFAAssertion *webauthn.FinishLoginRequest `json:"mfa_assertion,omitempty"`
+
+	// RememberDevice skips MFA on this browser in future
+	RememberDevice bool `json:"remember_device,omitempty"`
 }
 
 // LoginResponse represents login response
@@ -198,7 +244,7 @@ type UserInfo struct {
 	LastName  string `json:"last_name"`
 }
 
-// Login handles user login
+// Login handles user login through the auth service, which applies the sign-in policies
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -213,98 +259,192 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Find user by email or username
-	var foundUser *user.User
-	var err error
+	tokenPair, u, err := h.authService.Login(c.Request.Context(), &auth.LoginRequest{
+		Email:          req.Email,
+		Username:       req.Username,
+		Password:       req.Password,
+		MFAMethod:      req.MFAMethod,
+		MFACode:        req.MFACode,
+		MFAAssertion:   req.MFAAssertion,
+		RememberDevice: req.RememberDevice,
+		DeviceToken:    trustedDeviceToken(c),
+		IPAddress:      c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+	})
+	if err != nil {
+		h.loginError(c, err)
+		return
+	}
+
+	rememberDevice(c, h.trustedDevices, tokenPair.TrustedDeviceToken)
 
-	if req.Email != "" {
-		foundUser, err = h.userService.GetByEmail(c.Request.Context(), strings.ToLower(req.Email))
-	} else {
-		foundUser, err = h.userService.GetByUsername(c.Request.Context(), req.Username)
+	if h.sessionService != nil {
+		if !startSession(c, h.sessionService, h.tokenService, h.logger, u, tokenPair) {
+			return
+		}
 	}
 
-	if err != nil || foundUser == nil {
-		c.JSON(http.StatusUnauthorized, LoginResponse{
-			Success: false,
-			Error: &ErrorResponse{
-				Code:    "INVALID_CREDENTIALS",
-				Message: "Invalid email/username or password",
+	c.JSON(http.StatusOK, LoginResponse{
+		Success: true,
+		Data: &LoginResponseData{
+			AccessToken:  tokenPair.AccessToken,
+			RefreshToken: tokenPair.RefreshToken,
+			TokenType:    tokenPair.TokenType,
+			ExpiresIn:    tokenPair.ExpiresIn,
+			ExpiresAt:    tokenPair.ExpiresAt,
+			User: &UserInfo{
+				ID:        u.ID.String(),
+				Email:     u.Email,
+				Username:  u.Username,
+				FirstName: u.FirstName,
+				LastName:  u.LastName,
 			},
-		})
-		return
+		},
+	})
+}
+
+// startSession records a session for a login. The session's token ID is the
+// refresh token family, so ending one ends the other. It returns false, having
+// revoked the tokens and written the response, if the user's session policy
+// refuses another session.
+func startSession(
+	c *gin.Context,
+	sessionService *services.SessionService,
+	tokenService *services.TokenService,
+	logger *zap.Logger,
+	u *user.User,
+	tokenPair *auth.TokenPair,
+) bool {
+	expiresIn := tokenService.RefreshTokenExpiry()
+	if !tokenPair.RefreshExpiresAt.IsZero() {
+		expiresIn = time.Until(tokenPair.RefreshExpiresAt)
 	}
 
-	// Check if account is locked
-	if foundUser.LockedUntil != nil && foundUser.LockedUntil.After(time.Now()) {
-		c.JSON(http.StatusUnauthorized, LoginResponse{
-			Success: false,
-			Error: &ErrorResponse{
-				Code:    "ACCOUNT_LOCKED",
-				Message: "Account is temporarily locked due to multiple failed login attempts",
-			},
-		})
-		return
+	sess, err := sessionService.StartSession(
+		c.Request.Context(),
+		u,
+		tokenPair.FamilyID,
+		c.ClientIP(),
+		c.Request.UserAgent(),
+		expiresIn,
+	)
+	if err != nil {
+		if errors.Is(err, session.ErrSessionLimitReached) {
+			if err := tokenService.RevokeFamily(c.Request.Context(), tokenPair.FamilyID); err != nil {
+				logger.Error("Failed to revoke tokens of refused session", zap.Error(err))
+			}
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "SESSION_LIMIT_REACHED",
+					"message": "Too many active sessions; sign out of another device and try again",
+				},
+			})
+			return false
+		}
+
+		logger.Warn("Failed to create session", zap.Error(err))
+		return true
 	}
 
-	// Verify password
-	if !h.passwordHasher.VerifyPassword(req.Password, foundUser.PasswordHash) {
-		// Increment failed login attempts
-		_ = h.userService.IncrementFailedLoginAttempts(c.Request.Context(), foundUser.ID)
+	if err := tokenService.BindSession(c.Request.Context(), tokenPair.FamilyID, sess.ID); err != nil {
+		logger.Warn("Failed to bind session to token family", zap.Error(err))
+	}
+	return true
+}
 
-		c.JSON(http.StatusUnauthorized, LoginResponse{
-			Success: false,
-			Error: &ErrorResponse{
-				Code:    "INVALID_CREDENTIALS",
-				Message: "Invalid email/username or password",
-			},
-		})
-		return
+// sessionEndedError describes a refresh refused because the session policy ended the session
+func sessionEndedError(err error) (code, message string, ok bool) {
+	switch {
+	case errors.Is(err, session.ErrSessionIdle):
+		return "SESSION_IDLE_TIMEOUT", "Session timed out after inactivity; please sign in again", true
+	case errors.Is(err, session.ErrSessionExpired):
+		return "SESSION_EXPIRED", "Session has reached its maximum lifetime; please sign in again", true
+	default:
+		return "", "", false
 	}
+}
+
+// SecurityKeyRequest represents a request for the security key step of a sign-in
+type SecurityKeyRequest struct {
+	Email    string `json:"email" binding:"required_without=Username"`
+	Username string `json:"username" binding:"required_without=Email"`
+	Password string `json:"password" binding:"required"`
+}
 
-	// Check if account is active
-	if foundUser.Status != user.StatusActive {
-		c.JSON(http.StatusUnauthorized, LoginResponse{
+// BeginSecurityKeyLogin returns the options for the security key step of a password
+// sign-in; the assertion is then sent to Login with the same credentials
+func (h *AuthHandler) BeginSecurityKeyLogin(c *gin.Context) {
+	var req SecurityKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, WebAuthnResponse{
 			Success: false,
 			Error: &ErrorResponse{
-				Code:    "ACCOUNT_INACTIVE",
-				Message: "Account is not active",
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
 			},
 		})
 		return
 	}
 
-	// Generate tokens
-	tokenPair, err := h.tokenService.GenerateTokenPair(c.Request.Context(), foundUser)
+	options, err := h.authService.BeginSecurityKeyChallenge(c.Request.Context(), &auth.LoginRequest{
+		Email:     req.Email,
+		Username:  req.Username,
+		Password:  req.Password,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
 	if err != nil {
-		h.logger.Error("Failed to generate tokens", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, LoginResponse{
-			Success: false,
-			Error: &ErrorResponse{
-				Code:    "TOKEN_GENERATION_FAILED",
-				Message: "Failed to generate authentication tokens",
-			},
-		})
+		h.loginError(c, err)
 		return
 	}
 
-	// Update last login
-	_ = h.userService.UpdateLastLogin(c.Request.Context(), foundUser.ID, time.Now())
-
-	c.JSON(http.StatusOK, LoginResponse{
+	c.JSON(http.StatusOK, WebAuthnResponse{
 		Success: true,
-		Data: &LoginResponseData{
-			AccessToken:  tokenPair.AccessToken,
-			RefreshToken: tokenPair.RefreshToken,
-			TokenType:    tokenPair.TokenType,
-			ExpiresIn:    tokenPair.ExpiresIn,
-			ExpiresAt:    tokenPair.ExpiresAt,
-			User: &UserInfo{
-				ID:        foundUser.ID.String(),
-				Email:     foundUser.Email,
-				Username:  foundUser.Username,
-				FirstName: foundUser.FirstName,
-				LastName:  foundUser.LastName,
-			},
+		Data:    &WebAuthnData{LoginOptions: options},
+	})
+}
+
+// loginError maps sign-in errors from the auth service to responses
+func (h *AuthHandler) loginError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "Sign-in failed"
+
+	switch {
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		status, code, message = http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid email/username or password"
+	case errors.Is(err, auth.ErrLoginThrottled):
+		if h.lockout != nil {
+			if result, _ := h.lockout.CheckIP(c.Request.Context(), c.ClientIP()); result != nil {
+				setRateLimitHeaders(c, result)
+			}
+		}
+		status, code, message = http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS", "Too many failed sign-in attempts. Please try again later"
+	case errors.Is(err, auth.ErrLoginBlocked):
+		status, code, message = http.StatusForbidden, "LOGIN_BLOCKED", "This sign-in looks unusual and has been blocked"
+	case errors.Is(err, auth.ErrMFARequired):
+		status, code, message = http.StatusUnauthorized, "MFA_REQUIRED", "Enter a code from your second factor to finish signing in"
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		status, code, message = http.StatusUnauthorized, "INVALID_MFA_CODE", "The second factor code is invalid"
+	case errors.Is(err, mfa.ErrMethodNotConfigured):
+		status, code, message = http.StatusBadRequest, "METHOD_NOT_CONFIGURED", "No security key is registered for this account"
+	case errors.Is(err, auth.ErrAccountLocked):
+		status, code, message = http.StatusUnauthorized, "ACCOUNT_LOCKED", "Account is temporarily locked due to multiple failed login attempts"
+	case errors.Is(err, auth.ErrAccountInactive):
+		status, code, message = http.StatusUnauthorized, "ACCOUNT_INACTIVE", "Account is not active"
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		status, code, message = http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Please verify your email address before signing in"
+	case errors.Is(err, auth.ErrPasswordExpired):
+		status, code, message = http.StatusForbidden, "PASSWORD_EXPIRED", "Your password has expired. Choose a new one to sign in"
+	default:
+		h.logger.Error("Sign-in failed", zap.Error(err))
+	}
+
+	c.JSON(status, LoginResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
 		},
 	})
 }
@@ -349,23 +489,31 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 		return
 	}
 
+	data := gin.H{
+		"user": gin.H{
+			"id":             currentUser.ID.String(),
+			"email":          currentUser.Email,
+			"username":       currentUser.Username,
+			"first_name":     currentUser.FirstName,
+			"last_name":      currentUser.LastName,
+			"phone_number":   currentUser.PhoneNumber,
+			"email_verified": currentUser.EmailVerified,
+			"mfa_enabled":    currentUser.MFAEnabled,
+			"status":         currentUser.Status,
+			"created_at":     currentUser.CreatedAt,
+			"updated_at":     currentUser.UpdatedAt,
+		},
+		// Lets the UI show a banner while an admin is acting as the user
+		"impersonated": false,
+	}
+	if actorID := c.GetString("actor_id"); actorID != "" {
+		data["impersonated"] = true
+		data["impersonator_id"] = actorID
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data": gin.H{
-			"user": gin.H{
-				"id":             currentUser.ID.String(),
-				"email":          currentUser.Email,
-				"username":       currentUser.Username,
-				"first_name":     currentUser.FirstName,
-				"last_name":      currentUser.LastName,
-				"phone_number":   currentUser.PhoneNumber,
-				"email_verified": currentUser.EmailVerified,
-				"mfa_enabled":    currentUser.MFAEnabled,
-				"status":         currentUser.Status,
-				"created_at":     currentUser.CreatedAt,
-				"updated_at":     currentUser.UpdatedAt,
-			},
-		},
+		"data":    data,
 	})
 }
 
@@ -408,6 +556,26 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	tokenPair, err := h.tokenService.RefreshTokens(c.Request.Context(), req.RefreshToken)
 	if err != nil {
 		h.logger.Error("Failed to refresh tokens", zap.Error(err))
+		if errors.Is(err, auth.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, RefreshResponse{
+				Success: false,
+				Error: &ErrorResponse{
+					Code:    "REFRESH_TOKEN_REUSED",
+					Message: "Refresh token has already been used; please sign in again",
+				},
+			})
+			return
+		}
+		if code, message, ok := sessionEndedError(err); ok {
+			c.JSON(http.StatusUnauthorized, RefreshResponse{
+				Success: false,
+				Error: &ErrorResponse{
+					Code:    code,
+					Message: message,
+				},
+			})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, RefreshResponse{
 			Success: false,
 			Error: &ErrorResponse{
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/ratelimit"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// EmailVerificationHandler handles email verification endpoints
+type EmailVerificationHandler struct {
+	verificationService *services.EmailVerificationService
+	logger              *zap.Logger
+}
+
+// NewEmailVerificationHandler creates a new email verification handler
+func NewEmailVerificationHandler(verificationService *services.EmailVerificationService, logger *zap.Logger) *EmailVerificationHandler {
+	return &EmailVerificationHandler{
+		verificationService: verificationService,
+		logger:              logger,
+	}
+}
+
+// VerifyEmailRequest represents an email verification request
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// EmailVerificationResponse represents an email verification endpoint response
+type EmailVerificationResponse struct {
+	Success bool                   `json:"success"`
+	Data    *EmailVerificationData `json:"data,omitempty"`
+	Error   *ErrorResponse         `json:"error,omitempty"`
+}
+
+type EmailVerificationData struct {
+	Message    string     `json:"message"`
+	Email      string     `json:"email,omitempty"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+}
+
+// VerifyEmail redeems a verification link
+func (h *EmailVerificationHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, EmailVerificationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	u, err := h.verificationService.VerifyEmail(c.Request.Context(), req.Token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, EmailVerificationResponse{
+				Success: false,
+				Error: &ErrorResponse{
+					Code:    "INVALID_VERIFICATION_TOKEN",
+					Message: "Verification link is invalid or has expired",
+				},
+			})
+			return
+		}
+
+		h.logger.Error("Failed to verify email", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, EmailVerificationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to verify email address",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, EmailVerificationResponse{
+		Success: true,
+		Data: &EmailVerificationData{
+			Message:    "Email address verified",
+			Email:      u.Email,
+			VerifiedAt: u.EmailVerifiedAt,
+		},
+	})
+}
+
+// ResendVerification sends a new verification link to the current user
+func (h *EmailVerificationHandler) ResendVerification(c *gin.Context) {
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, EmailVerificationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "UNAUTHORIZED",
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, EmailVerificationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_USER_ID",
+				Message: "Invalid user ID",
+			},
+		})
+		return
+	}
+
+	result, err := h.verificationService.ResendVerification(c.Request.Context(), userID)
+	if result != nil {
+		setRateLimitHeaders(c, result)
+	}
+
+	switch {
+	case err == nil:
+		c.JSON(http.StatusAccepted, EmailVerificationResponse{
+			Success: true,
+			Data: &EmailVerificationData{
+				Message: "Verification email sent",
+			},
+		})
+	case errors.Is(err, auth.ErrEmailAlreadyVerified):
+		c.JSON(http.StatusConflict, EmailVerificationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "EMAIL_ALREADY_VERIFIED",
+				Message: "Email address is already verified",
+			},
+		})
+	case errors.Is(err, auth.ErrVerificationThrottled):
+		c.JSON(http.StatusTooManyRequests, EmailVerificationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "RATE_LIMIT_EXCEEDED",
+				Message: "Too many verification emails requested. Please try again later",
+			},
+		})
+	default:
+		h.logger.Error("Failed to resend verification email", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, EmailVerificationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to send verification email",
+			},
+		})
+	}
+}
+
+// setRateLimitHeaders exposes a rate limit result to the client
+func setRateLimitHeaders(c *gin.Context, result *ratelimit.RateLimitResult) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(result.ResetTime.Unix(), 10))
+	if !result.Allowed {
+		c.Header("Retry-After", strconv.Itoa(int(result.RetryAfter.Seconds())))
+	}
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/federation"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+const (
+	// federationStateCookie holds the signed authorization state during the upstream round trip
+	federationStateCookie = "federation_state"
+
+	// federationCookiePath limits the state cookie to the federated callback routes
+	federationCookiePath = "/v1/auth/federated"
+)
+
+// FederationHandler handles sign-in through upstream identity providers
+type FederationHandler struct {
+	federationService *services.FederationService
+	tokenService      *services.TokenService
+	sessionService    *services.SessionService
+	stateExpiry       time.Duration
+	logger            *zap.Logger
+}
+
+// NewFederationHandler creates a new federation handler
+func NewFederationHandler(
+	federationService *services.FederationService,
+	tokenService *services.TokenService,
+	stateExpiry time.Duration,
+	logger *zap.Logger,
+) *FederationHandler {
+	return &FederationHandler{
+		federationService: federationService,
+		tokenService:      tokenService,
+		stateExpiry:       stateExpiry,
+		logger:            logger,
+	}
+}
+
+// SetSessionService enables server-side sessions for federated logins
+func (h *FederationHandler) SetSessionService(sessionService *services.SessionService) {
+	h.sessionService = sessionService
+}
+
+// FederationResponse represents a federated login response
+type FederationResponse struct {
+	Success bool            `json:"success"`
+	Data    *FederationData `json:"data,omitempty"`
+	Error   *ErrorResponse  `json:"error,omitempty"`
+}
+
+type FederationData struct {
+	AccessToken      string                 `json:"access_token,omitempty"`
+	RefreshToken     string                 `json:"refresh_token,omitempty"`
+	TokenType        string                 `json:"token_type,omitempty"`
+	ExpiresIn        int                    `json:"expires_in,omitempty"`
+	ExpiresAt        *time.Time             `json:"expires_at,omitempty"`
+	User             *UserInfo              `json:"user,omitempty"`
+	Identity         *federation.Identity   `json:"identity,omitempty"`
+	Identities       []*federation.Identity `json:"identities,omitempty"`
+	Providers        []*ProviderInfo        `json:"providers,omitempty"`
+	AuthorizationURL string                 `json:"authorization_url,omitempty"`
+	Created          bool                   `json:"created,omitempty"`
+	Linked           bool                   `json:"linked,omitempty"`
+}
+
+// ProviderInfo describes an upstream identity provider to the sign-in UI
+type ProviderInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ListProviders lists the identity providers users can sign in with
+func (h *FederationHandler) ListProviders(c *gin.Context) {
+	providers := []*ProviderInfo{}
+	for _, p := range h.federationService.Providers() {
+		providers = append(providers, &ProviderInfo{ID: p.ID, Name: p.Name})
+	}
+
+	c.JSON(http.StatusOK, FederationResponse{
+		Success: true,
+		Data:    &FederationData{Providers: providers},
+	})
+}
+
+// StartLogin redirects the browser to the upstream provider's sign-in page
+func (h *FederationHandler) StartLogin(c *gin.Context) {
+	authURL, stateToken, err := h.federationService.BeginAuthorization(c.Param("provider"), nil)
+	if err != nil {
+		h.federationError(c, err)
+		return
+	}
+
+	h.setStateCookie(c, stateToken, int(h.stateExpiry.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes a sign-in or identity link when the upstream provider redirects back
+func (h *FederationHandler) Callback(c *gin.Context) {
+	stateToken, _ := c.Cookie(federationStateCookie)
+	// The state is single-use whatever the outcome
+	h.setStateCookie(c, "", -1)
+
+	if upstreamErr := c.Query("error"); upstreamErr != "" {
+		c.JSON(http.StatusUnauthorized, FederationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "UPSTREAM_AUTH_FAILED",
+				Message: "The identity provider did not authorize the sign-in",
+				Details: upstreamErr,
+			},
+		})
+		return
+	}
+
+	result, err := h.federationService.CompleteAuthorization(
+		c.Request.Context(),
+		c.Param("provider"),
+		stateToken,
+		c.Query("state"),
+		c.Query("code"),
+	)
+	if err != nil {
+		h.federationError(c, err)
+		return
+	}
+
+	data := &FederationData{
+		User: &UserInfo{
+			ID:        result.User.ID.String(),
+			Email:     result.User.Email,
+			Username:  result.User.Username,
+			FirstName: result.User.FirstName,
+			LastName:  result.User.LastName,
+		},
+		Identity: result.Identity,
+		Created:  result.Created,
+		Linked:   result.Linked,
+	}
+
+	if tokenPair := result.TokenPair; tokenPair != nil {
+		if h.sessionService != nil {
+			if !startSession(c, h.sessionService, h.tokenService, h.logger, result.User, tokenPair) {
+				return
+			}
+		}
+
+		data.AccessToken = tokenPair.AccessToken
+		data.RefreshToken = tokenPair.RefreshToken
+		data.TokenType = tokenPair.TokenType
+		data.ExpiresIn = tokenPair.ExpiresIn
+		data.ExpiresAt = &tokenPair.ExpiresAt
+	}
+
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+
+	c.JSON(status, FederationResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// ListIdentities lists the external identities linked to the current user
+func (h *FederationHandler) ListIdentities(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	identities, err := h.federationService.ListIdentities(c.Request.Context(), userID)
+	if err != nil {
+		h.federationError(c, err)
+		return
+	}
+
+	if identities == nil {
+		identities = []*federation.Identity{}
+	}
+
+	c.JSON(http.StatusOK, FederationResponse{
+		Success: true,
+		Data:    &FederationData{Identities: identities},
+	})
+}
+
+// LinkIdentity starts linking an identity at a provider to the current user.
+// The client navigates the browser to the returned authorization URL.
+func (h *FederationHandler) LinkIdentity(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	authURL, stateToken, err := h.federationService.BeginAuthorization(c.Param("provider"), &userID)
+	if err != nil {
+		h.federationError(c, err)
+		return
+	}
+
+	h.setStateCookie(c, stateToken, int(h.stateExpiry.Seconds()))
+	c.JSON(http.StatusOK, FederationResponse{
+		Success: true,
+		Data:    &FederationData{AuthorizationURL: authURL},
+	})
+}
+
+// UnlinkIdentity removes an external identity from the current user
+func (h *FederationHandler) UnlinkIdentity(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	identityID, err := uuid.Parse(c.Param("identityId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, FederationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_IDENTITY_ID",
+				Message: "Invalid identity ID format",
+			},
+		})
+		return
+	}
+
+	if err := h.federationService.UnlinkIdentity(c.Request.Context(), userID, identityID); err != nil {
+		h.federationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// setStateCookie sets (or with a negative maxAge, clears) the authorization state cookie.
+// SameSite=Lax lets the cookie accompany the top-level redirect back from the provider.
+func (h *FederationHandler) setStateCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(federationStateCookie, value, maxAge, federationCookiePath, "", secure, true)
+}
+
+// federationError maps federation errors to responses
+func (h *FederationHandler) federationError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "Federated sign-in failed"
+
+	switch {
+	case errors.Is(err, federation.ErrProviderNotFound):
+		status, code, message = http.StatusNotFound, "PROVIDER_NOT_FOUND", "Identity provider not found"
+	case errors.Is(err, federation.ErrIdentityNotFound):
+		status, code, message = http.StatusNotFound, "IDENTITY_NOT_FOUND", "Linked identity not found"
+	case errors.Is(err, federation.ErrInvalidState):
+		status, code, message = http.StatusBadRequest, "INVALID_STATE", "The sign-in request is invalid or has expired; please start again"
+	case errors.Is(err, federation.ErrUpstreamAuthFailed), errors.Is(err, federation.ErrInvalidIDToken):
+		status, code, message = http.StatusUnauthorized, "UPSTREAM_AUTH_FAILED", "The identity provider sign-in could not be verified"
+	case errors.Is(err, federation.ErrIdentityAlreadyLinked):
+		status, code, message = http.StatusConflict, "IDENTITY_ALREADY_LINKED", "This identity is already linked to another account"
+	case errors.Is(err, federation.ErrAccountExists):
+		status, code, message = http.StatusConflict, "ACCOUNT_EXISTS", "An account with this email already exists; sign in and link the identity from your profile"
+	case errors.Is(err, federation.ErrEmailNotVerified):
+		status, code, message = http.StatusForbidden, "EMAIL_NOT_VERIFIED", "The identity provider has not verified this email address"
+	case errors.Is(err, federation.ErrEmailMissing):
+		status, code, message = http.StatusForbidden, "EMAIL_REQUIRED", "The identity provider did not share an email address"
+	case errors.Is(err, federation.ErrSignupDisabled):
+		status, code, message = http.StatusForbidden, "SIGNUP_DISABLED", "No account is linked to this identity"
+	case errors.Is(err, auth.ErrAccountLocked):
+		status, code, message = http.StatusUnauthorized, "ACCOUNT_LOCKED", "Account is temporarily locked due to multiple failed login attempts"
+	case errors.Is(err, auth.ErrAccountInactive):
+		status, code, message = http.StatusUnauthorized, "ACCOUNT_INACTIVE", "Account is not active"
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		status, code, message = http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Please verify your email address before signing in"
+	default:
+		h.logger.Error("Federated sign-in failed", zap.Error(err))
+	}
+
+	c.JSON(status, FederationResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
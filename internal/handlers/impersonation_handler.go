@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// ImpersonationHandler lets admins act as a user to see what they see
+type ImpersonationHandler struct {
+	impersonationService *services.ImpersonationService
+	logger               *zap.Logger
+}
+
+// NewImpersonationHandler creates a new impersonation handler
+func NewImpersonationHandler(impersonationService *services.ImpersonationService, logger *zap.Logger) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		impersonationService: impersonationService,
+		logger:               logger,
+	}
+}
+
+// ImpersonationResponse represents an impersonation response
+type ImpersonationResponse struct {
+	Success bool               `json:"success"`
+	Data    *ImpersonationData `json:"data,omitempty"`
+	Error   *ErrorResponse     `json:"error,omitempty"`
+}
+
+type ImpersonationData struct {
+	AccessToken    string    `json:"access_token"`
+	TokenType      string    `json:"token_type"`
+	ExpiresIn      int       `json:"expires_in"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	User           *UserInfo `json:"user"`
+	ImpersonatorID string    `json:"impersonator_id"`
+}
+
+// Start issues a short-lived token for the current admin to act as a user.
+// The token cannot be refreshed and is refused on sensitive endpoints.
+func (h *ImpersonationHandler) Start(c *gin.Context) {
+	actorID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ImpersonationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_USER_ID",
+				Message: "Invalid user ID format",
+			},
+		})
+		return
+	}
+
+	var req auth.ImpersonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ImpersonationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "A reason for impersonating the user is required",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+	req.ActorID = actorID
+	req.UserID = userID
+	req.IPAddress = c.ClientIP()
+	req.UserAgent = c.Request.UserAgent()
+
+	tokenPair, u, err := h.impersonationService.Start(c.Request.Context(), &req)
+	if err != nil {
+		h.impersonationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ImpersonationResponse{
+		Success: true,
+		Data: &ImpersonationData{
+			AccessToken: tokenPair.AccessToken,
+			TokenType:   tokenPair.TokenType,
+			ExpiresIn:   tokenPair.ExpiresIn,
+			ExpiresAt:   tokenPair.ExpiresAt,
+			User: &UserInfo{
+				ID:        u.ID.String(),
+				Email:     u.Email,
+				Username:  u.Username,
+				FirstName: u.FirstName,
+				LastName:  u.LastName,
+			},
+			ImpersonatorID: actorID.String(),
+		},
+	})
+}
+
+// End revokes the impersonation token the request was made with
+func (h *ImpersonationHandler) End(c *gin.Context) {
+	err := h.impersonationService.End(c.Request.Context(), c.GetString("token"), c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		h.impersonationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// impersonationError maps impersonation errors to responses
+func (h *ImpersonationHandler) impersonationError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "Impersonation failed"
+
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		status, code, message = http.StatusNotFound, "USER_NOT_FOUND", "User not found"
+	case errors.Is(err, auth.ErrImpersonationNotAllowed):
+		status, code, message = http.StatusBadRequest, "IMPERSONATION_NOT_ALLOWED", "You cannot impersonate yourself"
+	case errors.Is(err, auth.ErrAccountInactive):
+		status, code, message = http.StatusConflict, "ACCOUNT_INACTIVE", "Only active accounts can be impersonated"
+	case errors.Is(err, auth.ErrNotImpersonating):
+		status, code, message = http.StatusBadRequest, "NOT_IMPERSONATING", "The request is not authenticated with an impersonation token"
+	default:
+		h.logger.Error("Impersonation failed", zap.Error(err))
+	}
+
+	c.JSON(status, ImpersonationResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/organization"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// InvitationHandler handles organization invitation endpoints
+type InvitationHandler struct {
+	invitationService *services.InvitationService
+	logger            *zap.Logger
+}
+
+// NewInvitationHandler creates a new invitation handler
+func NewInvitationHandler(invitationService *services.InvitationService, logger *zap.Logger) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+		logger:            logger,
+	}
+}
+
+// InvitationResponse represents an invitation response
+type InvitationResponse struct {
+	Success bool            `json:"success"`
+	Data    *InvitationData `json:"data,omitempty"`
+	Error   *ErrorResponse  `json:"error,omitempty"`
+}
+
+type InvitationData struct {
+	Invitation  *organization.Invitation   `json:"invitation,omitempty"`
+	Invitations []*organization.Invitation `json:"invitations,omitempty"`
+	// Membership and User are returned when an invitation is accepted
+	Membership *organization.Membership `json:"membership,omitempty"`
+	User       *UserInfo                `json:"user,omitempty"`
+}
+
+// Invite emails an invitation to join an organization
+func (h *InvitationHandler) Invite(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	var req organization.InviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	invitation, err := h.invitationService.Invite(c.Request.Context(), orgID, &req, userID)
+	if err != nil {
+		h.invitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, InvitationResponse{
+		Success: true,
+		Data:    &InvitationData{Invitation: invitation},
+	})
+}
+
+// List lists an organization's pending invitations
+func (h *InvitationHandler) List(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.invitationService.List(c.Request.Context(), orgID, userID)
+	if err != nil {
+		h.invitationError(c, err)
+		return
+	}
+
+	if invitations == nil {
+		invitations = []*organization.Invitation{}
+	}
+
+	c.JSON(http.StatusOK, InvitationResponse{
+		Success: true,
+		Data:    &InvitationData{Invitations: invitations},
+	})
+}
+
+// Resend emails a new link for a pending invitation
+func (h *InvitationHandler) Resend(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	invitationID, ok := parseInvitationID(c)
+	if !ok {
+		return
+	}
+
+	invitation, err := h.invitationService.Resend(c.Request.Context(), orgID, invitationID, userID)
+	if err != nil {
+		h.invitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, InvitationResponse{
+		Success: true,
+		Data:    &InvitationData{Invitation: invitation},
+	})
+}
+
+// Revoke cancels a pending invitation
+func (h *InvitationHandler) Revoke(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	invitationID, ok := parseInvitationID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.invitationService.Revoke(c.Request.Context(), orgID, invitationID, userID); err != nil {
+		h.invitationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Accept adds the caller to the organization of an invitation sent to their address
+func (h *InvitationHandler) Accept(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req organization.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	membership, err := h.invitationService.Accept(c.Request.Context(), req.Token, userID)
+	if err != nil {
+		h.invitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, InvitationResponse{
+		Success: true,
+		Data:    &InvitationData{Membership: membership},
+	})
+}
+
+// AcceptWithRegistration creates an account for the invited address and adds
+// it to the organization; the new user then signs in as usual
+func (h *InvitationHandler) AcceptWithRegistration(c *gin.Context) {
+	var req organization.AcceptInvitationWithRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	membership, u, err := h.invitationService.AcceptWithRegistration(c.Request.Context(), &req)
+	if err != nil {
+		h.invitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, InvitationResponse{
+		Success: true,
+		Data: &InvitationData{
+			Membership: membership,
+			User: &UserInfo{
+				ID:        u.ID.String(),
+				Email:     u.Email,
+				Username:  u.Username,
+				FirstName: u.FirstName,
+				LastName:  u.LastName,
+			},
+		},
+	})
+}
+
+func parseInvitationID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("invitationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, InvitationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_INVITATION_ID",
+				Message: "Invalid invitation ID format",
+			},
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *InvitationHandler) validationError(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, InvitationResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    "VALIDATION_ERROR",
+			Message: "Invalid request data",
+			Details: err.Error(),
+		},
+	})
+}
+
+// invitationError maps invitation errors to responses
+func (h *InvitationHandler) invitationError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "Invitation operation failed"
+
+	switch {
+	case errors.Is(err, auth.ErrPasswordTooWeak), errors.Is(err, auth.ErrPasswordReused):
+		newPasswordError(c, err)
+		return
+	case errors.Is(err, organization.ErrInvitationNotFound):
+		status, code, message = http.StatusNotFound, "INVITATION_NOT_FOUND", "Invitation not found"
+	case errors.Is(err, organization.ErrInvalidInvitation):
+		status, code, message = http.StatusBadRequest, "INVALID_INVITATION", "The invitation link is invalid or has expired"
+	case errors.Is(err, organization.ErrInvitationNotPending):
+		status, code, message = http.StatusConflict, "INVITATION_NOT_PENDING", "The invitation has already been accepted or revoked"
+	case errors.Is(err, organization.ErrInvitationPending):
+		status, code, message = http.StatusConflict, "INVITATION_PENDING", "An invitation to this address is already pending; resend it instead"
+	case errors.Is(err, organization.ErrInvitationEmailMismatch):
+		status, code, message = http.StatusForbidden, "INVITATION_EMAIL_MISMATCH", "The invitation was sent to a different email address"
+	case errors.Is(err, organization.ErrInvitationRequiresSignIn):
+		status, code, message = http.StatusConflict, "SIGN_IN_TO_ACCEPT", "An account with this email exists; sign in to accept the invitation"
+	case errors.Is(err, organization.ErrSeatLimitReached):
+		status, code, message = http.StatusForbidden, "SEAT_LIMIT_REACHED", "The organization has no seats left on its plan"
+	case errors.Is(err, organization.ErrOrganizationNotFound):
+		status, code, message = http.StatusNotFound, "ORGANIZATION_NOT_FOUND", "Organization not found"
+	case errors.Is(err, organization.ErrAlreadyMember):
+		status, code, message = http.StatusConflict, "ALREADY_A_MEMBER", "The user is already a member of the organization"
+	case errors.Is(err, organization.ErrInvalidRole):
+		status, code, message = http.StatusBadRequest, "INVALID_ROLE", "Role must be owner, admin, billing or member"
+	case errors.Is(err, organization.ErrInsufficientRole):
+		status, code, message = http.StatusForbidden, "INSUFFICIENT_ROLE", "Your role in the organization does not allow this"
+	case errors.Is(err, auth.ErrUsernameAlreadyExists):
+		status, code, message = http.StatusConflict, "USERNAME_EXISTS", "Username is already taken"
+	case errors.Is(err, auth.ErrAccountInactive):
+		status, code, message = http.StatusForbidden, "ACCOUNT_INACTIVE", "Account is inactive"
+	default:
+		h.logger.Error("Invitation operation failed", zap.Error(err))
+	}
+
+	c.JSON(status, InvitationResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+			Details: detailsFor(status, err),
+		},
+	})
+}
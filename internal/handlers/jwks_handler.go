@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// JWKSHandler publishes the public keys used to verify issued tokens
+type JWKSHandler struct {
+	tokenService *services.TokenService
+}
+
+// NewJWKSHandler creates a new JWKS handler
+func NewJWKSHandler(tokenService *services.TokenService) *JWKSHandler {
+	return &JWKSHandler{
+		tokenService: tokenService,
+	}
+}
+
+// GetJWKS returns the JSON Web Key Set for token verification.
+// The response is a bare RFC 7517 document so standard JWT libraries can consume it.
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	// Keep the cache short so verifiers pick up rotated keys well before the old key retires
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.tokenService.JWKS())
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+const (
+	// magicLinkNonceCookie binds a sign-in link to the browser that requested it
+	magicLinkNonceCookie = "magic_link_nonce"
+
+	// magicLinkCookiePath limits the nonce cookie to the sign-in link routes
+	magicLinkCookiePath = "/v1/auth/magic-link"
+)
+
+// MagicLinkHandler handles passwordless sign-in with links sent by email
+type MagicLinkHandler struct {
+	authService    *services.AuthService
+	tokenService   *services.TokenService
+	sessionService *services.SessionService
+	trustedDevices *services.TrustedDeviceService
+	linkExpiry     time.Duration
+	logger         *zap.Logger
+}
+
+// NewMagicLinkHandler creates a new magic link handler
+func NewMagicLinkHandler(
+	authService *services.AuthService,
+	tokenService *services.TokenService,
+	linkExpiry time.Duration,
+	logger *zap.Logger,
+) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		authService:  authService,
+		tokenService: tokenService,
+		linkExpiry:   linkExpiry,
+		logger:       logger,
+	}
+}
+
+// SetSessionService enables server-side sessions for magic link logins
+func (h *MagicLinkHandler) SetSessionService(sessionService *services.SessionService) {
+	h.sessionService = sessionService
+}
+
+// SetTrustedDeviceService lets browsers be remembered so their sign-ins skip MFA
+func (h *MagicLinkHandler) SetTrustedDeviceService(trustedDevices *services.TrustedDeviceService) {
+	h.trustedDevices = trustedDevices
+}
+
+// MagicLinkResponse represents a sign-in link request response
+type MagicLinkResponse struct {
+	Success bool           `json:"success"`
+	Data    *MagicLinkData `json:"data,omitempty"`
+	Error   *ErrorResponse `json:"error,omitempty"`
+}
+
+type MagicLinkData struct {
+	Message string `json:"message"`
+}
+
+// RequestLink emails a sign-in link and binds it to this browser with a nonce cookie.
+// The response is identical whether or not the email belongs to an account.
+func (h *MagicLinkHandler) RequestLink(c *gin.Context) {
+	var req auth.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, MagicLinkResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	// Failures are logged but never surfaced, since that would reveal the account exists
+	nonce, err := h.authService.RequestMagicLink(c.Request.Context(), req.Email)
+	if err != nil {
+		h.logger.Error("Failed to process sign-in link request", zap.Error(err))
+	} else {
+		h.setNonceCookie(c, nonce, int(h.linkExpiry.Seconds()))
+	}
+
+	c.JSON(http.StatusAccepted, MagicLinkResponse{
+		Success: true,
+		Data: &MagicLinkData{
+			Message: "If an account exists for that email, a sign-in link has been sent. Open it in this browser.",
+		},
+	})
+}
+
+// RedeemLink signs in with a link's token from the browser that requested it
+func (h *MagicLinkHandler) RedeemLink(c *gin.Context) {
+	var req auth.MagicLinkRedeemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, LoginResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	nonce, _ := c.Cookie(magicLinkNonceCookie)
+	req.DeviceToken = trustedDeviceToken(c)
+	req.IPAddress = c.ClientIP()
+	req.UserAgent = c.Request.UserAgent()
+
+	tokenPair, u, err := h.authService.RedeemMagicLink(c.Request.Context(), &req, nonce)
+	if err != nil {
+		h.redeemError(c, err)
+		return
+	}
+
+	// The link is spent, so the nonce has nothing left to protect
+	h.setNonceCookie(c, "", -1)
+	rememberDevice(c, h.trustedDevices, tokenPair.TrustedDeviceToken)
+
+	if h.sessionService != nil {
+		if !startSession(c, h.sessionService, h.tokenService, h.logger, u, tokenPair) {
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Success: true,
+		Data: &LoginResponseData{
+			AccessToken:  tokenPair.AccessToken,
+			RefreshToken: tokenPair.RefreshToken,
+			TokenType:    tokenPair.TokenType,
+			ExpiresIn:    tokenPair.ExpiresIn,
+			ExpiresAt:    tokenPair.ExpiresAt,
+			User: &UserInfo{
+				ID:        u.ID.String(),
+				Email:     u.Email,
+				Username:  u.Username,
+				FirstName: u.FirstName,
+				LastName:  u.LastName,
+			},
+		},
+	})
+}
+
+// setNonceCookie sets (or with a negative maxAge, clears) the nonce cookie.
+// SameSite=Strict keeps it from being sent on requests started by other sites.
+func (h *MagicLinkHandler) setNonceCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(magicLinkNonceCookie, value, maxAge, magicLinkCookiePath, "", secure, true)
+}
+
+// redeemError maps sign-in link errors to responses
+func (h *MagicLinkHandler) redeemError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "Sign-in failed"
+
+	switch {
+	case errors.Is(err, auth.ErrInvalidMagicLink):
+		status, code, message = http.StatusBadRequest, "INVALID_MAGIC_LINK", "Sign-in link is invalid or has expired, or was requested from another browser"
+	case errors.Is(err, auth.ErrMagicLinkDisabled):
+		status, code, message = http.StatusNotFound, "MAGIC_LINK_DISABLED", "Sign-in links are not enabled"
+	case errors.Is(err, auth.ErrMFARequired):
+		status, code, message = http.StatusUnauthorized, "MFA_REQUIRED", "Enter a code from your second factor to finish signing in"
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		status, code, message = http.StatusUnauthorized, "INVALID_MFA_CODE", "The second factor code is invalid"
+	case errors.Is(err, auth.ErrAccountLocked):
+		status, code, message = http.StatusUnauthorized, "ACCOUNT_LOCKED", "Account is temporarily locked due to multiple failed login attempts"
+	case errors.Is(err, auth.ErrAccountInactive):
+		status, code, message = http.StatusUnauthorized, "ACCOUNT_INACTIVE", "Account is not active"
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		status, code, message = http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Please verify your email address before signing in"
+	default:
+		h.logger.Error("Failed to redeem sign-in link", zap.Error(err))
+	}
+
+	c.JSON(status, LoginResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
@@ -0,0 +1,586 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/oauth"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// Authorization decisions submitted from the consent screen
+const (
+	consentDecisionAllow = "allow"
+	consentDecisionDeny  = "deny"
+)
+
+// OAuthHandler handles the OpenID Connect provider endpoints
+type OAuthHandler struct {
+	oauthService *services.OAuthService
+	logger       *zap.Logger
+}
+
+// NewOAuthHandler creates a new OAuth handler
+func NewOAuthHandler(oauthService *services.OAuthService, logger *zap.Logger) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService: oauthService,
+		logger:       logger,
+	}
+}
+
+// OAuthErrorResponse represents an OAuth 2.0 error response (RFC 6749 section 5.2).
+// Protocol endpoints use this format so standard client libraries can read it.
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// AuthorizeRequest represents an authorization request submitted by the signed-in user
+type AuthorizeRequest struct {
+	oauth.AuthorizationRequest
+	Decision string `json:"decision" binding:"omitempty,oneof=allow deny"`
+}
+
+// AuthorizeResponse represents the authorization endpoint response for the sign-in UI
+type AuthorizeResponse struct {
+	Success bool           `json:"success"`
+	Data    *AuthorizeData `json:"data,omitempty"`
+	Error   *ErrorResponse `json:"error,omitempty"`
+}
+
+type AuthorizeData struct {
+	RedirectTo      string        `json:"redirect_to,omitempty"`
+	ConsentRequired bool          `json:"consent_required,omitempty"`
+	Client          *OAuthAppData `json:"client,omitempty"`
+	Scopes          []string      `json:"scopes,omitempty"`
+}
+
+// OAuthAppData describes a client to the user being asked for consent
+type OAuthAppData struct {
+	ClientID string `json:"client_id"`
+	Name     string `json:"name"`
+}
+
+// OAuthClientResponse represents a client management response
+type OAuthClientResponse struct {
+	Success bool             `json:"success"`
+	Data    *OAuthClientData `json:"data,omitempty"`
+	Error   *ErrorResponse   `json:"error,omitempty"`
+}
+
+type OAuthClientData struct {
+	Client       *oauth.Client   `json:"client,omitempty"`
+	ClientSecret string          `json:"client_secret,omitempty"`
+	Clients      []*oauth.Client `json:"clients,omitempty"`
+}
+
+// ConsentResponse represents a consent management response
+type ConsentResponse struct {
+	Success bool           `json:"success"`
+	Data    *ConsentData   `json:"data,omitempty"`
+	Error   *ErrorResponse `json:"error,omitempty"`
+}
+
+type ConsentData struct {
+	Consents []*oauth.Consent `json:"consents"`
+}
+
+// Discovery returns the OpenID Provider metadata document
+func (h *OAuthHandler) Discovery(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, h.oauthService.Discovery())
+}
+
+// StartAuthorization is the authorization endpoint relying parties send the browser to.
+// It validates the request and hands it to the sign-in UI, which completes it through Authorize.
+func (h *OAuthHandler) StartAuthorization(c *gin.Context) {
+	var req oauth.AuthorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{
+			Error:            "invalid_request",
+			ErrorDescription: err.Error(),
+		})
+		return
+	}
+
+	if _, _, err := h.oauthService.ValidateAuthorizationRequest(c.Request.Context(), &req); err != nil {
+		h.authorizationError(c, &req, err)
+		return
+	}
+
+	loginURL := h.oauthService.LoginURL(c.Request.URL.RequestURI())
+	if loginURL == "" {
+		c.JSON(http.StatusUnauthorized, OAuthErrorResponse{
+			Error:            "login_required",
+			ErrorDescription: "Sign in and submit the authorization request with an access token",
+		})
+		return
+	}
+
+	c.Redirect(http.StatusFound, loginURL)
+}
+
+// Authorize completes an authorization request for the signed-in user. It returns the
+// redirect back to the client, or asks the UI to collect consent first.
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req AuthorizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, AuthorizeResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	authReq := &req.AuthorizationRequest
+
+	var err error
+	switch req.Decision {
+	case consentDecisionDeny:
+		// Validate first so a denial is never redirected to an unregistered URI
+		if _, _, err = h.oauthService.ValidateAuthorizationRequest(ctx, authReq); err == nil {
+			err = oauth.ErrAccessDenied
+		}
+	case consentDecisionAllow:
+		err = h.oauthService.GrantConsent(ctx, userID, authReq)
+	}
+
+	redirectTo := ""
+	if err == nil {
+		redirectTo, err = h.oauthService.Authorize(ctx, userID, authReq)
+	}
+
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, AuthorizeResponse{
+			Success: true,
+			Data:    &AuthorizeData{RedirectTo: redirectTo},
+		})
+	case errors.Is(err, oauth.ErrConsentRequired):
+		client, scopes, err := h.oauthService.ValidateAuthorizationRequest(ctx, authReq)
+		if err != nil {
+			h.internalError(c, "Failed to load client for consent", err)
+			return
+		}
+		c.JSON(http.StatusOK, AuthorizeResponse{
+			Success: true,
+			Data: &AuthorizeData{
+				ConsentRequired: true,
+				Client:          &OAuthAppData{ClientID: client.ID, Name: client.Name},
+				Scopes:          scopes,
+			},
+		})
+	case errors.Is(err, oauth.ErrClientNotFound):
+		c.JSON(http.StatusBadRequest, AuthorizeResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_CLIENT",
+				Message: "Unknown client",
+			},
+		})
+	case errors.Is(err, oauth.ErrInvalidRedirectURI):
+		c.JSON(http.StatusBadRequest, AuthorizeResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_REDIRECT_URI",
+				Message: "Redirect URI is not registered for this client",
+			},
+		})
+	case oauthErrorCode(err) != "server_error":
+		// Protocol errors go back to the client through the redirect URI
+		c.JSON(http.StatusOK, AuthorizeResponse{
+			Success: true,
+			Data: &AuthorizeData{
+				RedirectTo: services.AuthorizationRedirect(authReq, oauthErrorParams(err)),
+			},
+		})
+	default:
+		h.internalError(c, "Failed to authorize client", err)
+	}
+}
+
+// Token is the token endpoint. It accepts form-encoded requests and authenticates
+// confidential clients with HTTP Basic or client_secret_post.
+func (h *OAuthHandler) Token(c *gin.Context) {
+	// Token responses must never be cached (RFC 6749 section 5.1)
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	req := &oauth.TokenRequest{
+		GrantType:    c.PostForm("grant_type"),
+		Code:         c.PostForm("code"),
+		RedirectURI:  c.PostForm("redirect_uri"),
+		CodeVerifier: c.PostForm("code_verifier"),
+		RefreshToken: c.PostForm("refresh_token"),
+		Scope:        c.PostForm("scope"),
+	}
+
+	var basicAuth bool
+	req.ClientID, req.ClientSecret, basicAuth = clientCredentials(c)
+
+	resp, err := h.oauthService.Exchange(c.Request.Context(), req)
+	if err != nil {
+		h.protocolError(c, "Failed to exchange token", err, basicAuth)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Introspect is the token introspection endpoint (RFC 7662) for resource servers
+// that cannot validate tokens locally or need to see revocations as they happen
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{
+			Error:            "invalid_request",
+			ErrorDescription: "token is required",
+		})
+		return
+	}
+
+	clientID, clientSecret, basicAuth := clientCredentials(c)
+
+	resp, err := h.oauthService.Introspect(c.Request.Context(), clientID, clientSecret, token, c.PostForm("token_type_hint"))
+	if err != nil {
+		h.protocolError(c, "Failed to introspect token", err, basicAuth)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Revoke is the token revocation endpoint (RFC 7009). It answers 200 with an empty
+// body whether or not the token was still valid.
+func (h *OAuthHandler) Revoke(c *gin.Context) {
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{
+			Error:            "invalid_request",
+			ErrorDescription: "token is required",
+		})
+		return
+	}
+
+	clientID, clientSecret, basicAuth := clientCredentials(c)
+
+	if err := h.oauthService.Revoke(c.Request.Context(), clientID, clientSecret, token, c.PostForm("token_type_hint")); err != nil {
+		h.protocolError(c, "Failed to revoke token", err, basicAuth)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// UserInfo returns claims about the user the access token was issued for.
+// Tokens from a direct login carry no scopes and see every standard claim, like /users/me.
+func (h *OAuthHandler) UserInfo(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	scopes := oauth.SupportedScopes
+	if _, isClientToken := c.Get("client_id"); isClientToken {
+		scopes = c.GetStringSlice("scopes")
+	}
+
+	claims, err := h.oauthService.UserInfo(c.Request.Context(), userID, scopes)
+	if err != nil {
+		if errors.Is(err, oauth.ErrInsufficientScope) {
+			c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+			c.JSON(http.StatusForbidden, OAuthErrorResponse{
+				Error:            "insufficient_scope",
+				ErrorDescription: "The access token was not granted the openid scope",
+			})
+			return
+		}
+
+		h.logger.Error("Failed to load user info", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, OAuthErrorResponse{Error: "server_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, claims)
+}
+
+// ListConsents returns the clients the current user has granted access to
+func (h *OAuthHandler) ListConsents(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	consents, err := h.oauthService.ListConsents(c.Request.Context(), userID)
+	if err != nil {
+		h.internalError(c, "Failed to list consents", err)
+		return
+	}
+
+	if consents == nil {
+		consents = []*oauth.Consent{}
+	}
+
+	c.JSON(http.StatusOK, ConsentResponse{
+		Success: true,
+		Data:    &ConsentData{Consents: consents},
+	})
+}
+
+// RevokeConsent withdraws the current user's consent for a client
+func (h *OAuthHandler) RevokeConsent(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	err := h.oauthService.RevokeConsent(c.Request.Context(), userID, c.Param("clientId"))
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, oauth.ErrConsentNotFound):
+		c.JSON(http.StatusNotFound, ConsentResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "CONSENT_NOT_FOUND",
+				Message: "No consent has been granted to this client",
+			},
+		})
+	default:
+		h.internalError(c, "Failed to revoke consent", err)
+	}
+}
+
+// RegisterClient registers a new OAuth client. The client secret is only returned once.
+func (h *OAuthHandler) RegisterClient(c *gin.Context) {
+	var req oauth.RegisterClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, OAuthClientResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	client, secret, err := h.oauthService.RegisterClient(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, oauth.ErrInvalidRedirectURI) || errors.Is(err, oauth.ErrInvalidScope) {
+			c.JSON(http.StatusBadRequest, OAuthClientResponse{
+				Success: false,
+				Error: &ErrorResponse{
+					Code:    "VALIDATION_ERROR",
+					Message: "Invalid client configuration",
+					Details: err.Error(),
+				},
+			})
+			return
+		}
+
+		h.internalError(c, "Failed to register client", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, OAuthClientResponse{
+		Success: true,
+		Data: &OAuthClientData{
+			Client:       client,
+			ClientSecret: secret,
+		},
+	})
+}
+
+// ListClients returns all registered OAuth clients
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	clients, err := h.oauthService.ListClients(c.Request.Context())
+	if err != nil {
+		h.internalError(c, "Failed to list clients", err)
+		return
+	}
+
+	if clients == nil {
+		clients = []*oauth.Client{}
+	}
+
+	c.JSON(http.StatusOK, OAuthClientResponse{
+		Success: true,
+		Data:    &OAuthClientData{Clients: clients},
+	})
+}
+
+// DeleteClient removes an OAuth client
+func (h *OAuthHandler) DeleteClient(c *gin.Context) {
+	err := h.oauthService.DeleteClient(c.Request.Context(), c.Param("clientId"))
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, oauth.ErrClientNotFound):
+		c.JSON(http.StatusNotFound, OAuthClientResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "CLIENT_NOT_FOUND",
+				Message: "OAuth client not found",
+			},
+		})
+	default:
+		h.internalError(c, "Failed to delete client", err)
+	}
+}
+
+// protocolError writes an OAuth 2.0 error response for a token endpoint failure
+func (h *OAuthHandler) protocolError(c *gin.Context, message string, err error, basicAuth bool) {
+	code := oauthErrorCode(err)
+	status := http.StatusBadRequest
+	switch code {
+	case "invalid_client":
+		status = http.StatusUnauthorized
+		if basicAuth {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+	case "server_error":
+		h.logger.Error(message, zap.Error(err))
+		status = http.StatusInternalServerError
+	}
+
+	c.JSON(status, OAuthErrorResponse{
+		Error:            code,
+		ErrorDescription: oauthErrorDescription(err),
+	})
+}
+
+// authorizationError reports an invalid authorization request. Errors that leave the
+// redirect URI untrusted are shown directly; the rest are redirected to the client.
+func (h *OAuthHandler) authorizationError(c *gin.Context, req *oauth.AuthorizationRequest, err error) {
+	code := oauthErrorCode(err)
+
+	switch {
+	case errors.Is(err, oauth.ErrClientNotFound), errors.Is(err, oauth.ErrInvalidRedirectURI):
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{
+			Error:            code,
+			ErrorDescription: oauthErrorDescription(err),
+		})
+	case code == "server_error":
+		h.logger.Error("Failed to validate authorization request", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, OAuthErrorResponse{Error: code})
+	default:
+		c.Redirect(http.StatusFound, services.AuthorizationRedirect(req, oauthErrorParams(err)))
+	}
+}
+
+func (h *OAuthHandler) internalError(c *gin.Context, message string, err error) {
+	h.logger.Error(message, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"success": false,
+		"error": gin.H{
+			"code":    "INTERNAL_ERROR",
+			"message": message,
+		},
+	})
+}
+
+// requireUserID reads the authenticated user's ID, responding 401 if there is none
+func requireUserID(c *gin.Context) (uuid.UUID, bool) {
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(userIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_USER_ID",
+				"message": "Invalid user ID format",
+			},
+		})
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// clientCredentials reads client credentials from HTTP Basic authentication, falling
+// back to the client_id and client_secret form parameters
+func clientCredentials(c *gin.Context) (clientID, clientSecret string, basicAuth bool) {
+	// Basic credentials are form-encoded before being base64 encoded (RFC 6749 section 2.3.1)
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(id)
+		clientSecret, _ = url.QueryUnescape(secret)
+		return clientID, clientSecret, true
+	}
+	return c.PostForm("client_id"), c.PostForm("client_secret"), false
+}
+
+// oauthErrorCode maps a service error to its OAuth 2.0 error code
+func oauthErrorCode(err error) string {
+	switch {
+	case errors.Is(err, oauth.ErrClientNotFound), errors.Is(err, oauth.ErrInvalidClient):
+		return "invalid_client"
+	case errors.Is(err, oauth.ErrInvalidRedirectURI), errors.Is(err, oauth.ErrPKCERequired):
+		return "invalid_request"
+	case errors.Is(err, oauth.ErrUnsupportedResponseType):
+		return "unsupported_response_type"
+	case errors.Is(err, oauth.ErrUnsupportedGrantType):
+		return "unsupported_grant_type"
+	case errors.Is(err, oauth.ErrInvalidScope):
+		return "invalid_scope"
+	case errors.Is(err, oauth.ErrInvalidGrant):
+		return "invalid_grant"
+	case errors.Is(err, oauth.ErrUnauthorizedClient):
+		return "unauthorized_client"
+	case errors.Is(err, oauth.ErrConsentRequired):
+		return "consent_required"
+	case errors.Is(err, oauth.ErrAccessDenied):
+		return "access_denied"
+	case errors.Is(err, oauth.ErrInsufficientScope):
+		return "insufficient_scope"
+	default:
+		return "server_error"
+	}
+}
+
+// oauthErrorDescription describes a protocol error without leaking internal details
+func oauthErrorDescription(err error) string {
+	if oauthErrorCode(err) == "server_error" {
+		return ""
+	}
+	return err.Error()
+}
+
+// oauthErrorParams builds the redirect parameters reporting an error to the client
+func oauthErrorParams(err error) url.Values {
+	params := url.Values{"error": {oauthErrorCode(err)}}
+	if description := oauthErrorDescription(err); description != "" {
+		params.Set("error_description", description)
+	}
+	return params
+}
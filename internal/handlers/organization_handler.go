@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/organization"
+	"github.com/victoralfred/um_sys/internal/domain/session"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// OrganizationHandler handles organization and membership endpoints
+type OrganizationHandler struct {
+	organizationService *services.OrganizationService
+	logger              *zap.Logger
+}
+
+// NewOrganizationHandler creates a new organization handler
+func NewOrganizationHandler(organizationService *services.OrganizationService, logger *zap.Logger) *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationService: organizationService,
+		logger:              logger,
+	}
+}
+
+// OrganizationResponse represents an organization response
+type OrganizationResponse struct {
+	Success bool              `json:"success"`
+	Data    *OrganizationData `json:"data,omitempty"`
+	Error   *ErrorResponse    `json:"error,omitempty"`
+}
+
+type OrganizationData struct {
+	Organization *organization.Organization `json:"organization,omitempty"`
+	Membership   *organization.Membership   `json:"membership,omitempty"`
+	// Memberships lists the caller's organizations, or an organization's members
+	Memberships []*organization.Membership `json:"memberships,omitempty"`
+	// Tokens are returned when switching organization
+	Tokens *RefreshResponseData `json:"tokens,omitempty"`
+}
+
+// Create creates an organization owned by the caller
+func (h *OrganizationHandler) Create(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req organization.CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	org, err := h.organizationService.Create(c.Request.Context(), &req, userID)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, OrganizationResponse{
+		Success: true,
+		Data:    &OrganizationData{Organization: org},
+	})
+}
+
+// List lists the organizations the caller belongs to
+func (h *OrganizationHandler) List(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	memberships, err := h.organizationService.ListForUser(c.Request.Context(), userID)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	if memberships == nil {
+		memberships = []*organization.Membership{}
+	}
+
+	c.JSON(http.StatusOK, OrganizationResponse{
+		Success: true,
+		Data:    &OrganizationData{Memberships: memberships},
+	})
+}
+
+// Get returns an organization
+func (h *OrganizationHandler) Get(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	org, err := h.organizationService.Get(c.Request.Context(), orgID, userID)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, OrganizationResponse{
+		Success: true,
+		Data:    &OrganizationData{Organization: org},
+	})
+}
+
+// Update changes an organization's name or slug
+func (h *OrganizationHandler) Update(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	var req organization.UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	org, err := h.organizationService.Update(c.Request.Context(), orgID, &req, userID)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, OrganizationResponse{
+		Success: true,
+		Data:    &OrganizationData{Organization: org},
+	})
+}
+
+// Delete deletes an organization
+func (h *OrganizationHandler) Delete(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.organizationService.Delete(c.Request.Context(), orgID, userID); err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListMembers lists an organization's members
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	memberships, err := h.organizationService.ListMembers(c.Request.Context(), orgID, userID)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	if memberships == nil {
+		memberships = []*organization.Membership{}
+	}
+
+	c.JSON(http.StatusOK, OrganizationResponse{
+		Success: true,
+		Data:    &OrganizationData{Memberships: memberships},
+	})
+}
+
+// AddMember adds an existing user to an organization
+func (h *OrganizationHandler) AddMember(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	var req organization.AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	membership, err := h.organizationService.AddMember(c.Request.Context(), orgID, &req, userID)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, OrganizationResponse{
+		Success: true,
+		Data:    &OrganizationData{Membership: membership},
+	})
+}
+
+// UpdateMember changes a member's role
+func (h *OrganizationHandler) UpdateMember(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	memberID, ok := parseMemberID(c)
+	if !ok {
+		return
+	}
+
+	var req organization.UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	membership, err := h.organizationService.UpdateMemberRole(c.Request.Context(), orgID, memberID, req.Role, userID)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, OrganizationResponse{
+		Success: true,
+		Data:    &OrganizationData{Membership: membership},
+	})
+}
+
+// RemoveMember removes a member from an organization; members may remove themselves to leave
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	memberID, ok := parseMemberID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.organizationService.RemoveMember(c.Request.Context(), orgID, memberID, userID); err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Switch issues tokens acting in another organization without signing in again.
+// The previous refresh token stops working; the session continues with the new one.
+func (h *OrganizationHandler) Switch(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	// Only session tokens can be switched; an API key must not mint a token pair
+	familyID := c.GetString("family_id")
+	if familyID == "" || c.GetString("api_key_id") != "" {
+		c.JSON(http.StatusBadRequest, OrganizationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "NO_CURRENT_SESSION",
+				Message: "The request is not authenticated with a session token",
+			},
+		})
+		return
+	}
+
+	var req organization.SwitchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	tokenPair, err := h.organizationService.Switch(c.Request.Context(), userID, familyID, req.OrganizationID)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, OrganizationResponse{
+		Success: true,
+		Data: &OrganizationData{
+			Tokens: &RefreshResponseData{
+				AccessToken:  tokenPair.AccessToken,
+				RefreshToken: tokenPair.RefreshToken,
+				TokenType:    tokenPair.TokenType,
+				ExpiresIn:    tokenPair.ExpiresIn,
+				ExpiresAt:    tokenPair.ExpiresAt,
+			},
+		},
+	})
+}
+
+func parseOrganizationID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("orgId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, OrganizationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_ORGANIZATION_ID",
+				Message: "Invalid organization ID format",
+			},
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func parseMemberID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, OrganizationResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_USER_ID",
+				Message: "Invalid user ID format",
+			},
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *OrganizationHandler) validationError(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, OrganizationResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    "VALIDATION_ERROR",
+			Message: "Invalid request data",
+			Details: err.Error(),
+		},
+	})
+}
+
+// organizationError maps organization errors to responses
+func (h *OrganizationHandler) organizationError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "Organization operation failed"
+
+	switch {
+	case errors.Is(err, organization.ErrOrganizationNotFound):
+		status, code, message = http.StatusNotFound, "ORGANIZATION_NOT_FOUND", "Organization not found"
+	case errors.Is(err, organization.ErrSlugTaken):
+		status, code, message = http.StatusConflict, "SLUG_TAKEN", "An organization with this slug already exists"
+	case errors.Is(err, organization.ErrInvalidSlug):
+		status, code, message = http.StatusBadRequest, "INVALID_SLUG", "Slugs must be 2 to 63 lowercase letters, digits and single hyphens"
+	case errors.Is(err, organization.ErrNotMember):
+		status, code, message = http.StatusNotFound, "NOT_A_MEMBER", "The user is not a member of the organization"
+	case errors.Is(err, organization.ErrAlreadyMember):
+		status, code, message = http.StatusConflict, "ALREADY_A_MEMBER", "The user is already a member of the organization"
+	case errors.Is(err, organization.ErrInvalidRole):
+		status, code, message = http.StatusBadRequest, "INVALID_ROLE", "Role must be owner, admin, billing or member"
+	case errors.Is(err, organization.ErrInsufficientRole):
+		status, code, message = http.StatusForbidden, "INSUFFICIENT_ROLE", "Your role in the organization does not allow this"
+	case errors.Is(err, organization.ErrSeatLimitReached):
+		status, code, message = http.StatusForbidden, "SEAT_LIMIT_REACHED", "The organization has no seats left on its plan"
+	case errors.Is(err, organization.ErrLastOwner):
+		status, code, message = http.StatusConflict, "LAST_OWNER", "The organization must keep at least one owner"
+	case errors.Is(err, user.ErrUserNotFound):
+		status, code, message = http.StatusNotFound, "USER_NOT_FOUND", "User not found"
+	case errors.Is(err, auth.ErrAccountInactive):
+		status, code, message = http.StatusForbidden, "ACCOUNT_INACTIVE", "Account is inactive"
+	case errors.Is(err, auth.ErrInvalidToken):
+		status, code, message = http.StatusUnauthorized, "INVALID_SESSION", "The session has ended; please sign in again"
+	case errors.Is(err, auth.ErrRefreshTokenReused):
+		status, code, message = http.StatusConflict, "SESSION_REFRESHED", "The session was refreshed concurrently; refresh and try again"
+	case errors.Is(err, session.ErrSessionIdle), errors.Is(err, session.ErrSessionExpired):
+		code, message, _ = sessionEndedError(err)
+		status = http.StatusUnauthorized
+	default:
+		h.logger.Error("Organization operation failed", zap.Error(err))
+	}
+
+	c.JSON(status, OrganizationResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+			Details: detailsFor(status, err),
+		},
+	})
+}
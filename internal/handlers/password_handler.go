@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// PasswordHandler handles password recovery endpoints
+type PasswordHandler struct {
+	authService *services.AuthService
+	logger      *zap.Logger
+}
+
+// NewPasswordHandler creates a new password handler
+func NewPasswordHandler(authService *services.AuthService, logger *zap.Logger) *PasswordHandler {
+	return &PasswordHandler{
+		authService: authService,
+		logger:      logger,
+	}
+}
+
+// ForgotPasswordRequest represents a request for a password reset link
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents a password reset confirmation
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=128"`
+}
+
+// ChangeExpiredPasswordRequest replaces an expired password; it carries the same
+// credentials as a sign-in
+type ChangeExpiredPasswordRequest struct {
+	Email       string     `json:"email" binding:"required_without=Username"`
+	Username    string     `json:"username" binding:"required_without=Email"`
+	Password    string     `json:"password" binding:"required"`
+	NewPassword string     `json:"new_password" binding:"required,min=8,max=128"`
+	MFAMethod   mfa.Method `json:"mfa_method,omitempty" binding:"required_with=MFACode"`
+	MFACode     string     `json:"mfa_code,omitempty" binding:"required_with=MFAMethod"`
+}
+
+// PasswordResponse represents a password endpoint response
+type PasswordResponse struct {
+	Success bool                 `json:"success"`
+	Data    *PasswordMessageData `json:"data,omitempty"`
+	Error   *ErrorResponse       `json:"error,omitempty"`
+}
+
+type PasswordMessageData struct {
+	Message string `json:"message"`
+}
+
+// ForgotPassword sends a password reset link.
+// The response is identical whether or not the email belongs to an account.
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, PasswordResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	// Failures are logged but never surfaced, since that would reveal the account exists
+	if err := h.authService.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		h.logger.Error("Failed to process password reset request", zap.Error(err))
+	}
+
+	c.JSON(http.StatusAccepted, PasswordResponse{
+		Success: true,
+		Data: &PasswordMessageData{
+			Message: "If an account exists for that email, a password reset link has been sent.",
+		},
+	})
+}
+
+// ResetPassword sets a new password using a reset token
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, PasswordResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	err := h.authService.ResetPassword(c.Request.Context(), req.Token, req.NewPassword)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, PasswordResponse{
+			Success: true,
+			Data: &PasswordMessageData{
+				Message: "Password has been reset. Please sign in with your new password.",
+			},
+		})
+	case errors.Is(err, auth.ErrInvalidResetToken):
+		c.JSON(http.StatusBadRequest, PasswordResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_RESET_TOKEN",
+				Message: "Password reset link is invalid or has expired",
+			},
+		})
+	case errors.Is(err, auth.ErrPasswordTooWeak), errors.Is(err, auth.ErrPasswordReused):
+		newPasswordError(c, err)
+	default:
+		h.logger.Error("Failed to reset password", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, PasswordResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to reset password",
+			},
+		})
+	}
+}
+
+// ChangeExpiredPassword replaces a password that has outlived the maximum password
+// age. Sign-ins are refused with PASSWORD_EXPIRED until this succeeds.
+func (h *PasswordHandler) ChangeExpiredPassword(c *gin.Context) {
+	var req ChangeExpiredPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, PasswordResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	err := h.authService.ChangeExpiredPassword(c.Request.Context(), &auth.ExpiredPasswordChangeRequest{
+		LoginRequest: auth.LoginRequest{
+			Email:       req.Email,
+			Username:    req.Username,
+			Password:    req.Password,
+			MFAMethod:   req.MFAMethod,
+			MFACode:     req.MFACode,
+			DeviceToken: trustedDeviceToken(c),
+			IPAddress:   c.ClientIP(),
+			UserAgent:   c.Request.UserAgent(),
+		},
+		NewPassword: req.NewPassword,
+	})
+
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to change password"
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, PasswordResponse{
+			Success: true,
+			Data: &PasswordMessageData{
+				Message: "Password has been changed. Please sign in with your new password.",
+			},
+		})
+		return
+	case errors.Is(err, auth.ErrPasswordTooWeak), errors.Is(err, auth.ErrPasswordReused):
+		newPasswordError(c, err)
+		return
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		status, code, message = http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid email/username or password"
+	case errors.Is(err, auth.ErrLoginThrottled):
+		status, code, message = http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS", "Too many failed sign-in attempts. Please try again later"
+	case errors.Is(err, auth.ErrLoginBlocked):
+		status, code, message = http.StatusForbidden, "LOGIN_BLOCKED", "This sign-in looks unusual and has been blocked"
+	case errors.Is(err, auth.ErrMFARequired):
+		status, code, message = http.StatusUnauthorized, "MFA_REQUIRED", "Enter a code from your second factor to change your password"
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		status, code, message = http.StatusUnauthorized, "INVALID_MFA_CODE", "The second factor code is invalid"
+	case errors.Is(err, auth.ErrAccountLocked):
+		status, code, message = http.StatusUnauthorized, "ACCOUNT_LOCKED", "Account is temporarily locked due to multiple failed login attempts"
+	case errors.Is(err, auth.ErrAccountInactive):
+		status, code, message = http.StatusUnauthorized, "ACCOUNT_INACTIVE", "Account is not active"
+	case errors.Is(err, auth.ErrPasswordNotExpired):
+		status, code, message = http.StatusConflict, "PASSWORD_NOT_EXPIRED", "Password has not expired; change it from your account settings"
+	default:
+		h.logger.Error("Failed to change expired password", zap.Error(err))
+	}
+
+	c.JSON(status, PasswordResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+// newPasswordError responds to a new password the policy refuses
+func newPasswordError(c *gin.Context, err error) {
+	if errors.Is(err, auth.ErrPasswordReused) {
+		c.JSON(http.StatusBadRequest, PasswordResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "PASSWORD_REUSED",
+				Message: "Password was used recently; choose a different one",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, PasswordResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    "WEAK_PASSWORD",
+			Message: "Password does not meet security requirements",
+			Details: strings.TrimPrefix(err.Error(), auth.ErrPasswordTooWeak.Error()+": "),
+		},
+	})
+}
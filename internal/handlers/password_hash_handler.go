@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// PasswordHashHandler handles password hash administration endpoints
+type PasswordHashHandler struct {
+	hashService *services.PasswordHashService
+	logger      *zap.Logger
+}
+
+// NewPasswordHashHandler creates a new password hash handler
+func NewPasswordHashHandler(hashService *services.PasswordHashService, logger *zap.Logger) *PasswordHashHandler {
+	return &PasswordHashHandler{
+		hashService: hashService,
+		logger:      logger,
+	}
+}
+
+// PasswordHashReportResponse represents a password hash report response
+type PasswordHashReportResponse struct {
+	Success bool                     `json:"success"`
+	Data    *auth.PasswordHashReport `json:"data,omitempty"`
+	Error   *ErrorResponse           `json:"error,omitempty"`
+}
+
+// Report shows how many accounts are on each hash algorithm and cost
+func (h *PasswordHashHandler) Report(c *gin.Context) {
+	report, err := h.hashService.Report(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to build password hash report", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, PasswordHashReportResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to build password hash report",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, PasswordHashReportResponse{
+		Success: true,
+		Data:    report,
+	})
+}
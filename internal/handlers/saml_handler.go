@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/federation"
+	"github.com/victoralfred/um_sys/internal/domain/rbac"
+	"github.com/victoralfred/um_sys/internal/domain/saml"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+const (
+	// samlStateCookie holds the signed request state during the identity provider round trip
+	samlStateCookie = "saml_state"
+
+	// samlCookiePath limits the state cookie to the SAML routes
+	samlCookiePath = "/v1/auth/saml"
+)
+
+// SAMLHandler handles enterprise sign-in through organizations' SAML identity providers
+type SAMLHandler struct {
+	samlService    *services.SAMLService
+	tokenService   *services.TokenService
+	sessionService *services.SessionService
+	requestExpiry  time.Duration
+	logger         *zap.Logger
+}
+
+// NewSAMLHandler creates a new SAML handler
+func NewSAMLHandler(
+	samlService *services.SAMLService,
+	tokenService *services.TokenService,
+	requestExpiry time.Duration,
+	logger *zap.Logger,
+) *SAMLHandler {
+	return &SAMLHandler{
+		samlService:   samlService,
+		tokenService:  tokenService,
+		requestExpiry: requestExpiry,
+		logger:        logger,
+	}
+}
+
+// SetSessionService enables server-side sessions for SAML logins
+func (h *SAMLHandler) SetSessionService(sessionService *services.SessionService) {
+	h.sessionService = sessionService
+}
+
+// SAMLConnectionResponse represents a SAML connection response
+type SAMLConnectionResponse struct {
+	Success bool             `json:"success"`
+	Data    *saml.Connection `json:"data,omitempty"`
+	Error   *ErrorResponse   `json:"error,omitempty"`
+}
+
+// Metadata serves the service provider metadata an organization imports into its identity provider
+func (h *SAMLHandler) Metadata(c *gin.Context) {
+	organizationID, ok := h.organizationID(c)
+	if !ok {
+		return
+	}
+
+	metadata, err := h.samlService.Metadata(organizationID)
+	if err != nil {
+		h.samlError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// StartLogin redirects the browser to the organization's identity provider
+func (h *SAMLHandler) StartLogin(c *gin.Context) {
+	organizationID, ok := h.organizationID(c)
+	if !ok {
+		return
+	}
+
+	redirectURL, stateToken, err := h.samlService.BeginLogin(c.Request.Context(), organizationID)
+	if err != nil {
+		h.samlError(c, err)
+		return
+	}
+
+	h.setStateCookie(c, stateToken, int(h.requestExpiry.Seconds()))
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// ConsumeAssertion is the assertion consumer service the identity provider posts responses to
+func (h *SAMLHandler) ConsumeAssertion(c *gin.Context) {
+	organizationID, ok := h.organizationID(c)
+	if !ok {
+		return
+	}
+
+	stateToken, _ := c.Cookie(samlStateCookie)
+	// The state is single-use whatever the outcome
+	h.setStateCookie(c, "", -1)
+
+	result, err := h.samlService.CompleteLogin(
+		c.Request.Context(),
+		organizationID,
+		c.PostForm("SAMLResponse"),
+		c.PostForm("RelayState"),
+		stateToken,
+	)
+	if err != nil {
+		h.samlError(c, err)
+		return
+	}
+
+	tokenPair := result.TokenPair
+	if h.sessionService != nil {
+		if !startSession(c, h.sessionService, h.tokenService, h.logger, result.User, tokenPair) {
+			return
+		}
+	}
+
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+
+	c.JSON(status, FederationResponse{
+		Success: true,
+		Data: &FederationData{
+			AccessToken:  tokenPair.AccessToken,
+			RefreshToken: tokenPair.RefreshToken,
+			TokenType:    tokenPair.TokenType,
+			ExpiresIn:    tokenPair.ExpiresIn,
+			ExpiresAt:    &tokenPair.ExpiresAt,
+			User: &UserInfo{
+				ID:        result.User.ID.String(),
+				Email:     result.User.Email,
+				Username:  result.User.Username,
+				FirstName: result.User.FirstName,
+				LastName:  result.User.LastName,
+			},
+			Identity: result.Identity,
+			Created:  result.Created,
+			Linked:   result.Linked,
+		},
+	})
+}
+
+// GetConnection returns an organization's SAML connection
+func (h *SAMLHandler) GetConnection(c *gin.Context) {
+	organizationID, ok := h.organizationID(c)
+	if !ok {
+		return
+	}
+
+	connection, err := h.samlService.GetConnection(c.Request.Context(), organizationID)
+	if err != nil {
+		h.samlError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SAMLConnectionResponse{
+		Success: true,
+		Data:    connection,
+	})
+}
+
+// ConfigureConnection creates or replaces an organization's SAML connection from uploaded IdP metadata
+func (h *SAMLHandler) ConfigureConnection(c *gin.Context) {
+	organizationID, ok := h.organizationID(c)
+	if !ok {
+		return
+	}
+
+	var req saml.ConnectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, SAMLConnectionResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "The identity provider metadata is required",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	connection, err := h.samlService.ConfigureConnection(c.Request.Context(), organizationID, &req)
+	if err != nil {
+		h.samlError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SAMLConnectionResponse{
+		Success: true,
+		Data:    connection,
+	})
+}
+
+// DeleteConnection removes an organization's SAML connection
+func (h *SAMLHandler) DeleteConnection(c *gin.Context) {
+	organizationID, ok := h.organizationID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.samlService.DeleteConnection(c.Request.Context(), organizationID); err != nil {
+		h.samlError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// organizationID parses the organization from the path, writing the error response if it is invalid
+func (h *SAMLHandler) organizationID(c *gin.Context) (uuid.UUID, bool) {
+	organizationID, err := uuid.Parse(c.Param("orgId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, SAMLConnectionResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_ORGANIZATION_ID",
+				Message: "Invalid organization ID format",
+			},
+		})
+		return uuid.Nil, false
+	}
+	return organizationID, true
+}
+
+// setStateCookie sets (or with a negative maxAge, clears) the request state cookie.
+// The identity provider posts the response cross-site, which only SameSite=None cookies
+// accompany; browsers accept those over HTTPS alone, so plain HTTP falls back to Lax and
+// IdP-initiated handling.
+func (h *SAMLHandler) setStateCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	if secure {
+		c.SetSameSite(http.SameSiteNoneMode)
+	} else {
+		c.SetSameSite(http.SameSiteLaxMode)
+	}
+	c.SetCookie(samlStateCookie, value, maxAge, samlCookiePath, "", secure, true)
+}
+
+// samlError maps SAML errors to responses
+func (h *SAMLHandler) samlError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "SAML sign-in failed"
+
+	switch {
+	case errors.Is(err, saml.ErrConnectionNotFound):
+		status, code, message = http.StatusNotFound, "SAML_CONNECTION_NOT_FOUND", "The organization has no SAML connection"
+	case errors.Is(err, saml.ErrInvalidMetadata):
+		status, code, message = http.StatusBadRequest, "INVALID_METADATA", err.Error()
+	case errors.Is(err, rbac.ErrRoleNotFound):
+		status, code, message = http.StatusBadRequest, "ROLE_NOT_FOUND", err.Error()
+	case errors.Is(err, saml.ErrInvalidResponse), errors.Is(err, saml.ErrInvalidSignature),
+		errors.Is(err, saml.ErrAssertionExpired), errors.Is(err, saml.ErrAudienceMismatch),
+		errors.Is(err, saml.ErrRequestMismatch), errors.Is(err, saml.ErrAssertionReplayed):
+		// The reason is logged for the organization's admins rather than shown to the browser
+		h.logger.Warn("Rejected SAML response", zap.Error(err))
+		status, code, message = http.StatusUnauthorized, "INVALID_SAML_RESPONSE", "The identity provider sign-in could not be verified"
+	case errors.Is(err, saml.ErrUnsolicitedResponse):
+		status, code, message = http.StatusForbidden, "IDP_INITIATED_DISABLED", "Sign-in must be started from this application"
+	case errors.Is(err, saml.ErrAuthenticationFailed):
+		status, code, message = http.StatusUnauthorized, "UPSTREAM_AUTH_FAILED", "The identity provider did not authorize the sign-in"
+	case errors.Is(err, federation.ErrAccountExists):
+		status, code, message = http.StatusConflict, "ACCOUNT_EXISTS", "An account with this email already exists"
+	case errors.Is(err, federation.ErrEmailMissing):
+		status, code, message = http.StatusForbidden, "EMAIL_REQUIRED", "The identity provider did not share an email address"
+	case errors.Is(err, federation.ErrSignupDisabled):
+		status, code, message = http.StatusForbidden, "SIGNUP_DISABLED", "No account is linked to this identity"
+	case errors.Is(err, auth.ErrAccountLocked):
+		status, code, message = http.StatusUnauthorized, "ACCOUNT_LOCKED", "Account is temporarily locked due to multiple failed login attempts"
+	case errors.Is(err, auth.ErrAccountInactive):
+		status, code, message = http.StatusUnauthorized, "ACCOUNT_INACTIVE", "Account is not active"
+	default:
+		h.logger.Error("SAML sign-in failed", zap.Error(err))
+	}
+
+	c.JSON(status, SAMLConnectionResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
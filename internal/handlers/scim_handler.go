@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/scim"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// scimContentType is the media type of SCIM requests and responses (RFC 7644 section 3.1)
+const scimContentType = "application/scim+json"
+
+// SCIMHandler handles SCIM 2.0 provisioning requests from organizations' identity
+// providers, and the admin endpoints that manage their provisioning tokens
+type SCIMHandler struct {
+	scimService *services.SCIMService
+	logger      *zap.Logger
+}
+
+// NewSCIMHandler creates a new SCIM handler
+func NewSCIMHandler(scimService *services.SCIMService, logger *zap.Logger) *SCIMHandler {
+	return &SCIMHandler{
+		scimService: scimService,
+		logger:      logger,
+	}
+}
+
+// SCIMTokenResponse represents a provisioning token management response
+type SCIMTokenResponse struct {
+	Success bool           `json:"success"`
+	Data    *SCIMTokenData `json:"data,omitempty"`
+	Error   *ErrorResponse `json:"error,omitempty"`
+}
+
+type SCIMTokenData struct {
+	Token  *scim.Token   `json:"token,omitempty"`
+	Tokens []*scim.Token `json:"tokens,omitempty"`
+	// Secret is only returned when the token is created
+	Secret string `json:"secret,omitempty"`
+}
+
+// CreateToken issues a provisioning token for an organization
+func (h *SCIMHandler) CreateToken(c *gin.Context) {
+	adminID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	organizationID, ok := h.organizationID(c)
+	if !ok {
+		return
+	}
+
+	var req scim.TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, SCIMTokenResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	token, secret, err := h.scimService.CreateToken(c.Request.Context(), organizationID, adminID, &req)
+	if err != nil {
+		h.tokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, SCIMTokenResponse{
+		Success: true,
+		Data:    &SCIMTokenData{Token: token, Secret: secret},
+	})
+}
+
+// ListTokens lists an organization's provisioning tokens
+func (h *SCIMHandler) ListTokens(c *gin.Context) {
+	organizationID, ok := h.organizationID(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.scimService.ListTokens(c.Request.Context(), organizationID)
+	if err != nil {
+		h.tokenError(c, err)
+		return
+	}
+	if tokens == nil {
+		tokens = []*scim.Token{}
+	}
+
+	c.JSON(http.StatusOK, SCIMTokenResponse{
+		Success: true,
+		Data:    &SCIMTokenData{Tokens: tokens},
+	})
+}
+
+// RevokeToken revokes one of an organization's provisioning tokens
+func (h *SCIMHandler) RevokeToken(c *gin.Context) {
+	organizationID, ok := h.organizationID(c)
+	if !ok {
+		return
+	}
+
+	tokenID, err := uuid.Parse(c.Param("tokenId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, SCIMTokenResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_TOKEN_ID",
+				Message: "Invalid token ID format",
+			},
+		})
+		return
+	}
+
+	if err := h.scimService.RevokeToken(c.Request.Context(), organizationID, tokenID); err != nil {
+		h.tokenError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListUsers handles GET /scim/v2/Users
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	query, ok := h.listQuery(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.scimService.ListUsers(c.Request.Context(), h.scimOrganizationID(c), query)
+	if err != nil {
+		h.scimError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, response)
+}
+
+// GetUser handles GET /scim/v2/Users/:id
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	userID, ok := h.resourceID(c)
+	if !ok {
+		return
+	}
+
+	resource, err := h.scimService.GetUser(c.Request.Context(), h.scimOrganizationID(c), userID)
+	if err != nil {
+		h.scimError(c, err)
+		return
+	}
+	h.respondResource(c, http.StatusOK, resource, resource.Meta)
+}
+
+// CreateUser handles POST /scim/v2/Users
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	var body scim.UserResource
+	if !h.bind(c, &body) {
+		return
+	}
+
+	resource, err := h.scimService.CreateUser(c.Request.Context(), h.scimOrganizationID(c), &body)
+	if err != nil {
+		h.scimError(c, err)
+		return
+	}
+	h.respondResource(c, http.StatusCreated, resource, resource.Meta)
+}
+
+// ReplaceUser handles PUT /scim/v2/Users/:id
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	userID, ok := h.resourceID(c)
+	if !ok {
+		return
+	}
+	var body scim.UserResource
+	if !h.bind(c, &body) {
+		return
+	}
+
+	resource, err := h.scimService.ReplaceUser(c.Request.Context(), h.scimOrganizationID(c), userID, &body, c.GetHeader("If-Match"))
+	if err != nil {
+		h.scimError(c, err)
+		return
+	}
+	h.respondResource(c, http.StatusOK, resource, resource.Meta)
+}
+
+// PatchUser handles PATCH /scim/v2/Users/:id
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	userID, ok := h.resourceID(c)
+	if !ok {
+		return
+	}
+	var body scim.PatchRequest
+	if !h.bind(c, &body) {
+		return
+	}
+
+	resource, err := h.scimService.PatchUser(c.Request.Context(), h.scimOrganizationID(c), userID, &body, c.GetHeader("If-Match"))
+	if err != nil {
+		h.scimError(c, err)
+		return
+	}
+	h.respondResource(c, http.StatusOK, resource, resource.Meta)
+}
+
+// DeleteUser handles DELETE /scim/v2/Users/:id by deprovisioning the user
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	userID, ok := h.resourceID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.scimService.DeleteUser(c.Request.Context(), h.scimOrganizationID(c), userID, c.GetHeader("If-Match")); err != nil {
+		h.scimError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListGroups handles GET /scim/v2/Groups
+func (h *SCIMHandler) ListGroups(c *gin.Context) {
+	query, ok := h.listQuery(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.scimService.ListGroups(c.Request.Context(), h.scimOrganizationID(c), query)
+	if err != nil {
+		h.scimError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, response)
+}
+
+// GetGroup handles GET /scim/v2/Groups/:id
+func (h *SCIMHandler) GetGroup(c *gin.Context) {
+	groupID, ok := h.resourceID(c)
+	if !ok {
+		return
+	}
+
+	resource, err := h.scimService.GetGroup(c.Request.Context(), h.scimOrganizationID(c), groupID)
+	if err != nil {
+		h.scimError(c, err)
+		return
+	}
+	h.respondResource(c, http.StatusOK, resource, resource.Meta)
+}
+
+// CreateGroup handles POST /scim/v2/Groups
+func (h *SCIMHandler) CreateGroup(c *gin.Context) {
+	var body scim.GroupResource
+	if !h.bind(c, &body) {
+		return
+	}
+
+	resource, err := h.scimService.CreateGroup(c.Request.Context(), h.scimOrganizationID(c), &body)
+	if err != nil {
+		h.scimError(c, err)
+		return
+	}
+	h.respondResource(c, http.StatusCreated, resource, resource.Meta)
+}
+
+// ReplaceGroup handles PUT /scim/v2/Groups/:id
+func (h *SCIMHandler) ReplaceGroup(c *gin.Context) {
+	groupID, ok := h.resourceID(c)
+	if !ok {
+		return
+	}
+	var body scim.GroupResource
+	if !h.bind(c, &body) {
+		return
+	}
+
+	resource, err := h.scimService.ReplaceGroup(c.Request.Context(), h.scimOrganizationID(c), groupID, &body, c.GetHeader("If-Match"))
+	if err != nil {
+		h.scimError(c, err)
+		return
+	}
+	h.respondResource(c, http.StatusOK, resource, resource.Meta)
+}
+
+// PatchGroup handles PATCH /scim/v2/Groups/:id
+func (h *SCIMHandler) PatchGroup(c *gin.Context) {
+	groupID, ok := h.resourceID(c)
+	if !ok {
+		return
+	}
+	var body scim.PatchRequest
+	if !h.bind(c, &body) {
+		return
+	}
+
+	resource, err := h.scimService.PatchGroup(c.Request.Context(), h.scimOrganizationID(c), groupID, &body, c.GetHeader("If-Match"))
+	if err != nil {
+		h.scimError(c, err)
+		return
+	}
+	h.respondResource(c, http.StatusOK, resource, resource.Meta)
+}
+
+// DeleteGroup handles DELETE /scim/v2/Groups/:id
+func (h *SCIMHandler) DeleteGroup(c *gin.Context) {
+	groupID, ok := h.resourceID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.scimService.DeleteGroup(c.Request.Context(), h.scimOrganizationID(c), groupID, c.GetHeader("If-Match")); err != nil {
+		h.scimError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// organizationID parses the organization from an admin route, writing the error response if it is invalid
+func (h *SCIMHandler) organizationID(c *gin.Context) (uuid.UUID, bool) {
+	organizationID, err := uuid.Parse(c.Param("orgId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, SCIMTokenResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_ORGANIZATION_ID",
+				Message: "Invalid organization ID format",
+			},
+		})
+		return uuid.Nil, false
+	}
+	return organizationID, true
+}
+
+// scimOrganizationID returns the organization the SCIM token authenticated
+func (h *SCIMHandler) scimOrganizationID(c *gin.Context) uuid.UUID {
+	organizationID, _ := uuid.Parse(c.GetString("scim_organization_id"))
+	return organizationID
+}
+
+// resourceID parses a resource ID from the path. IDs that cannot exist are reported as not found.
+func (h *SCIMHandler) resourceID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.scimError(c, scim.ErrResourceNotFound)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// listQuery parses the filter, startIndex and count query parameters
+func (h *SCIMHandler) listQuery(c *gin.Context) (*scim.ListQuery, bool) {
+	startIndex, err := intQuery(c, "startIndex")
+	if err != nil {
+		h.scimError(c, err)
+		return nil, false
+	}
+	count, err := intQuery(c, "count")
+	if err != nil {
+		h.scimError(c, err)
+		return nil, false
+	}
+
+	return &scim.ListQuery{
+		Filter:     c.Query("filter"),
+		StartIndex: startIndex,
+		Count:      count,
+	}, true
+}
+
+func intQuery(c *gin.Context, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be an integer", scim.ErrInvalidValue, name)
+	}
+	return n, nil
+}
+
+// bind decodes a SCIM request body, writing the error response if it is malformed
+func (h *SCIMHandler) bind(c *gin.Context, body interface{}) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(body); err != nil {
+		if !errors.Is(err, scim.ErrInvalidValue) {
+			err = fmt.Errorf("%w: %v", scim.ErrInvalidSyntax, err)
+		}
+		h.scimError(c, err)
+		return false
+	}
+	return true
+}
+
+// respondResource writes a resource with its location and version. A GET whose
+// If-None-Match lists the current version gets 304 Not Modified.
+func (h *SCIMHandler) respondResource(c *gin.Context, status int, resource interface{}, meta *scim.Meta) {
+	c.Header("ETag", meta.Version)
+	if status == http.StatusCreated {
+		c.Header("Location", meta.Location)
+	}
+	if c.Request.Method == http.MethodGet && c.GetHeader("If-None-Match") == meta.Version {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	h.respond(c, status, resource)
+}
+
+func (h *SCIMHandler) respond(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", scimContentType)
+	c.JSON(status, body)
+}
+
+// scimError maps SCIM errors to the error responses SCIM clients expect (RFC 7644 section 3.12)
+func (h *SCIMHandler) scimError(c *gin.Context, err error) {
+	status, scimType, detail := http.StatusBadRequest, "", err.Error()
+
+	switch {
+	case errors.Is(err, scim.ErrResourceNotFound):
+		status, detail = http.StatusNotFound, "Resource not found"
+	case errors.Is(err, scim.ErrInvalidFilter):
+		scimType = "invalidFilter"
+	case errors.Is(err, scim.ErrInvalidPath):
+		scimType = "invalidPath"
+	case errors.Is(err, scim.ErrNoTarget):
+		scimType = "noTarget"
+	case errors.Is(err, scim.ErrInvalidValue):
+		scimType = "invalidValue"
+	case errors.Is(err, scim.ErrInvalidSyntax):
+		scimType = "invalidSyntax"
+	case errors.Is(err, scim.ErrMutability):
+		scimType = "mutability"
+	case errors.Is(err, scim.ErrUniqueness), errors.Is(err, auth.ErrUsernameAlreadyExists):
+		status, scimType = http.StatusConflict, "uniqueness"
+	case errors.Is(err, scim.ErrPreconditionFailed):
+		status, detail = http.StatusPreconditionFailed, "The resource has changed"
+	default:
+		h.logger.Error("SCIM request failed", zap.Error(err))
+		status, detail = http.StatusInternalServerError, "Internal server error"
+	}
+
+	h.respond(c, status, scim.NewErrorResponse(status, scimType, detail))
+}
+
+// tokenError maps provisioning token errors to responses
+func (h *SCIMHandler) tokenError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "SCIM token operation failed"
+
+	switch {
+	case errors.Is(err, scim.ErrTokenNotFound):
+		status, code, message = http.StatusNotFound, "SCIM_TOKEN_NOT_FOUND", "SCIM token not found"
+	default:
+		h.logger.Error("SCIM token operation failed", zap.Error(err))
+	}
+
+	c.JSON(status, SCIMTokenResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/serviceaccount"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// ServiceAccountHandler handles service account administration endpoints
+type ServiceAccountHandler struct {
+	serviceAccountService *services.ServiceAccountService
+	logger                *zap.Logger
+}
+
+// NewServiceAccountHandler creates a new service account handler
+func NewServiceAccountHandler(serviceAccountService *services.ServiceAccountService, logger *zap.Logger) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		serviceAccountService: serviceAccountService,
+		logger:                logger,
+	}
+}
+
+// ServiceAccountResponse represents a service account administration response
+type ServiceAccountResponse struct {
+	Success bool                `json:"success"`
+	Data    *ServiceAccountData `json:"data,omitempty"`
+	Error   *ErrorResponse      `json:"error,omitempty"`
+}
+
+type ServiceAccountData struct {
+	ServiceAccount  *serviceaccount.ServiceAccount   `json:"service_account,omitempty"`
+	ServiceAccounts []*serviceaccount.ServiceAccount `json:"service_accounts,omitempty"`
+	Roles           []string                         `json:"roles,omitempty"`
+	// ClientSecret is only returned when the account is created or its secret rotated
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// Create creates a service account
+func (h *ServiceAccountHandler) Create(c *gin.Context) {
+	actor, ok := requireActor(c)
+	if !ok {
+		return
+	}
+
+	var req serviceaccount.CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	account, secret, err := h.serviceAccountService.Create(c.Request.Context(), &req, actor)
+	if err != nil {
+		h.serviceAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ServiceAccountResponse{
+		Success: true,
+		Data:    &ServiceAccountData{ServiceAccount: account, ClientSecret: secret},
+	})
+}
+
+// List lists every service account
+func (h *ServiceAccountHandler) List(c *gin.Context) {
+	accounts, err := h.serviceAccountService.List(c.Request.Context())
+	if err != nil {
+		h.serviceAccountError(c, err)
+		return
+	}
+
+	if accounts == nil {
+		accounts = []*serviceaccount.ServiceAccount{}
+	}
+
+	c.JSON(http.StatusOK, ServiceAccountResponse{
+		Success: true,
+		Data:    &ServiceAccountData{ServiceAccounts: accounts},
+	})
+}
+
+// Get returns a service account
+func (h *ServiceAccountHandler) Get(c *gin.Context) {
+	id, ok := parseServiceAccountID(c)
+	if !ok {
+		return
+	}
+
+	account, err := h.serviceAccountService.Get(c.Request.Context(), id)
+	if err != nil {
+		h.serviceAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ServiceAccountResponse{
+		Success: true,
+		Data:    &ServiceAccountData{ServiceAccount: account},
+	})
+}
+
+// Update changes a service account's name, description or status
+func (h *ServiceAccountHandler) Update(c *gin.Context) {
+	actor, ok := requireActor(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseServiceAccountID(c)
+	if !ok {
+		return
+	}
+
+	var req serviceaccount.UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	account, err := h.serviceAccountService.Update(c.Request.Context(), id, &req, actor)
+	if err != nil {
+		h.serviceAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ServiceAccountResponse{
+		Success: true,
+		Data:    &ServiceAccountData{ServiceAccount: account},
+	})
+}
+
+// Delete removes a service account
+func (h *ServiceAccountHandler) Delete(c *gin.Context) {
+	actor, ok := requireActor(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseServiceAccountID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.serviceAccountService.Delete(c.Request.Context(), id, actor); err != nil {
+		h.serviceAccountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RotateSecret issues a new client secret, invalidating the previous one
+func (h *ServiceAccountHandler) RotateSecret(c *gin.Context) {
+	actor, ok := requireActor(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseServiceAccountID(c)
+	if !ok {
+		return
+	}
+
+	secret, err := h.serviceAccountService.RotateSecret(c.Request.Context(), id, actor)
+	if err != nil {
+		h.serviceAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ServiceAccountResponse{
+		Success: true,
+		Data:    &ServiceAccountData{ClientSecret: secret},
+	})
+}
+
+// ListRoles lists the roles assigned to a service account
+func (h *ServiceAccountHandler) ListRoles(c *gin.Context) {
+	id, ok := parseServiceAccountID(c)
+	if !ok {
+		return
+	}
+
+	roles, err := h.serviceAccountService.GetRoles(c.Request.Context(), id)
+	if err != nil {
+		h.serviceAccountError(c, err)
+		return
+	}
+
+	if roles == nil {
+		roles = []string{}
+	}
+
+	c.JSON(http.StatusOK, ServiceAccountResponse{
+		Success: true,
+		Data:    &ServiceAccountData{Roles: roles},
+	})
+}
+
+// AssignRole grants a role to a service account
+func (h *ServiceAccountHandler) AssignRole(c *gin.Context) {
+	actor, ok := requireActor(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseServiceAccountID(c)
+	if !ok {
+		return
+	}
+
+	var req serviceaccount.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	if err := h.serviceAccountService.AssignRole(c.Request.Context(), id, req.Role, actor); err != nil {
+		h.serviceAccountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveRole withdraws a role from a service account
+func (h *ServiceAccountHandler) RemoveRole(c *gin.Context) {
+	actor, ok := requireActor(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseServiceAccountID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.serviceAccountService.RemoveRole(c.Request.Context(), id, c.Param("role"), actor); err != nil {
+		h.serviceAccountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// requireActor returns the authenticated principal for audit records
+func requireActor(c *gin.Context) (audit.Actor, bool) {
+	id, ok := requireUserID(c)
+	if !ok {
+		return audit.Actor{}, false
+	}
+
+	actorType := audit.ActorTypeUser
+	if c.GetString("principal_type") == string(auth.PrincipalServiceAccount) {
+		actorType = audit.ActorTypeServiceAccount
+	}
+
+	return audit.Actor{ID: id, Type: actorType}, true
+}
+
+func parseServiceAccountID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("accountId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ServiceAccountResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_SERVICE_ACCOUNT_ID",
+				Message: "Invalid service account ID format",
+			},
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *ServiceAccountHandler) validationError(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, ServiceAccountResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    "VALIDATION_ERROR",
+			Message: "Invalid request data",
+			Details: err.Error(),
+		},
+	})
+}
+
+// serviceAccountError maps service account errors to responses
+func (h *ServiceAccountHandler) serviceAccountError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "Service account operation failed"
+
+	switch {
+	case errors.Is(err, serviceaccount.ErrServiceAccountNotFound):
+		status, code, message = http.StatusNotFound, "SERVICE_ACCOUNT_NOT_FOUND", "Service account not found"
+	case errors.Is(err, serviceaccount.ErrServiceAccountExists):
+		status, code, message = http.StatusConflict, "SERVICE_ACCOUNT_EXISTS", "A service account with this name already exists"
+	case errors.Is(err, serviceaccount.ErrInvalidRole):
+		status, code, message = http.StatusBadRequest, "INVALID_ROLE", "Role names must be non-empty and contain no whitespace"
+	case errors.Is(err, serviceaccount.ErrRoleAlreadyAssigned):
+		status, code, message = http.StatusConflict, "ROLE_ALREADY_ASSIGNED", "The service account already has this role"
+	case errors.Is(err, serviceaccount.ErrRoleNotAssigned):
+		status, code, message = http.StatusNotFound, "ROLE_NOT_ASSIGNED", "The service account does not have this role"
+	default:
+		h.logger.Error("Service account operation failed", zap.Error(err))
+	}
+
+	c.JSON(status, ServiceAccountResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+			Details: detailsFor(status, err),
+		},
+	})
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/risk"
+	"github.com/victoralfred/um_sys/internal/domain/session"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// SessionHandler lets users see where they are signed in and sign sessions out
+type SessionHandler struct {
+	sessionService *services.SessionService
+	logger         *zap.Logger
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(sessionService *services.SessionService, logger *zap.Logger) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+		logger:         logger,
+	}
+}
+
+// SessionResponse represents a session response
+type SessionResponse struct {
+	Success bool           `json:"success"`
+	Data    *SessionData   `json:"data,omitempty"`
+	Error   *ErrorResponse `json:"error,omitempty"`
+}
+
+type SessionData struct {
+	Sessions []*SessionInfo `json:"sessions"`
+}
+
+// SessionInfo describes an active session to its user
+type SessionInfo struct {
+	ID           string         `json:"id"`
+	IPAddress    string         `json:"ip_address"`
+	Device       string         `json:"device"`
+	Location     *risk.Location `json:"location,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	LastActivity time.Time      `json:"last_activity"`
+	ExpiresAt    time.Time      `json:"expires_at"`
+	Current      bool           `json:"current"`
+}
+
+// ListSessions lists the current user's active sessions, most recently used first
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionService.GetUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		h.sessionError(c, err)
+		return
+	}
+
+	familyID := c.GetString("family_id")
+	infos := make([]*SessionInfo, 0, len(sessions))
+	for _, sess := range sessions {
+		infos = append(infos, &SessionInfo{
+			ID:           sess.ID,
+			IPAddress:    sess.IPAddress,
+			Device:       sess.UserAgent,
+			Location:     sess.Location,
+			CreatedAt:    sess.CreatedAt,
+			LastActivity: sess.LastActivity,
+			ExpiresAt:    sess.ExpiresAt,
+			Current:      familyID != "" && sess.TokenID == familyID,
+		})
+	}
+
+	c.JSON(http.StatusOK, SessionResponse{
+		Success: true,
+		Data:    &SessionData{Sessions: infos},
+	})
+}
+
+// RevokeSession signs one of the current user's sessions out and revokes its tokens
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(c.Request.Context(), userID, c.Param("sessionId")); err != nil {
+		h.sessionError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeOtherSessions signs the current user out everywhere except this session
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	// Without a session to keep, this would sign the caller out as well
+	familyID := c.GetString("family_id")
+	if familyID == "" {
+		c.JSON(http.StatusBadRequest, SessionResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "NO_CURRENT_SESSION",
+				Message: "The request is not authenticated with a session token",
+			},
+		})
+		return
+	}
+
+	if _, err := h.sessionService.RevokeOtherSessions(c.Request.Context(), userID, familyID); err != nil {
+		h.sessionError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SessionHandler) sessionError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "Session operation failed"
+
+	switch {
+	case errors.Is(err, session.ErrSessionNotFound):
+		status, code, message = http.StatusNotFound, "SESSION_NOT_FOUND", "Session not found"
+	default:
+		h.logger.Error("Session operation failed", zap.Error(err))
+	}
+
+	c.JSON(status, SessionResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
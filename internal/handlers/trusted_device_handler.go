@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+const (
+	// trustedDeviceCookie holds the token that lets a remembered browser skip MFA
+	trustedDeviceCookie = "trusted_device"
+
+	// trustedDeviceCookiePath limits the cookie to the sign-in routes
+	trustedDeviceCookiePath = "/v1/auth"
+)
+
+// TrustedDeviceHandler lets users see and revoke the devices they chose to remember
+type TrustedDeviceHandler struct {
+	trustedDevices *services.TrustedDeviceService
+	logger         *zap.Logger
+}
+
+// NewTrustedDeviceHandler creates a new trusted device handler
+func NewTrustedDeviceHandler(trustedDevices *services.TrustedDeviceService, logger *zap.Logger) *TrustedDeviceHandler {
+	return &TrustedDeviceHandler{
+		trustedDevices: trustedDevices,
+		logger:         logger,
+	}
+}
+
+// TrustedDeviceResponse represents a trusted device response
+type TrustedDeviceResponse struct {
+	Success bool               `json:"success"`
+	Data    *TrustedDeviceData `json:"data,omitempty"`
+	Error   *ErrorResponse     `json:"error,omitempty"`
+}
+
+type TrustedDeviceData struct {
+	Devices []*mfa.TrustedDevice `json:"devices"`
+}
+
+// ListDevices lists the current user's trusted devices
+func (h *TrustedDeviceHandler) ListDevices(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	devices, err := h.trustedDevices.List(c.Request.Context(), userID)
+	if err != nil {
+		h.deviceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TrustedDeviceResponse{
+		Success: true,
+		Data:    &TrustedDeviceData{Devices: devices},
+	})
+}
+
+// RevokeDevice stops one of the current user's devices from skipping MFA
+func (h *TrustedDeviceHandler) RevokeDevice(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("deviceId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, TrustedDeviceResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_DEVICE_ID",
+				Message: "Invalid device ID format",
+			},
+		})
+		return
+	}
+
+	if err := h.trustedDevices.Revoke(c.Request.Context(), userID, id); err != nil {
+		h.deviceError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeAllDevices stops every device the current user trusts from skipping MFA
+func (h *TrustedDeviceHandler) RevokeAllDevices(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.trustedDevices.RevokeAll(c.Request.Context(), userID, "revoked by user"); err != nil {
+		h.deviceError(c, err)
+		return
+	}
+
+	setTrustedDeviceCookie(c, "", -1)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TrustedDeviceHandler) deviceError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "Trusted device operation failed"
+
+	switch {
+	case errors.Is(err, mfa.ErrTrustedDeviceNotFound):
+		status, code, message = http.StatusNotFound, "DEVICE_NOT_FOUND", "Trusted device not found"
+	default:
+		h.logger.Error("Trusted device operation failed", zap.Error(err))
+	}
+
+	c.JSON(status, TrustedDeviceResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+// rememberDevice stores the token of a device a sign-in asked to remember
+func rememberDevice(c *gin.Context, trustedDevices *services.TrustedDeviceService, token string) {
+	if trustedDevices == nil || token == "" {
+		return
+	}
+	setTrustedDeviceCookie(c, token, int(trustedDevices.TrustDuration().Seconds()))
+}
+
+// trustedDeviceToken returns the trusted device token the browser sent, if any
+func trustedDeviceToken(c *gin.Context) string {
+	token, _ := c.Cookie(trustedDeviceCookie)
+	return token
+}
+
+// setTrustedDeviceCookie sets (or with a negative maxAge, clears) the trusted device cookie
+func setTrustedDeviceCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(trustedDeviceCookie, value, maxAge, trustedDeviceCookiePath, "", secure, true)
+}
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+	"github.com/victoralfred/um_sys/internal/domain/webauthn"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// WebAuthnHandler handles security key and passkey endpoints
+type WebAuthnHandler struct {
+	webauthnService *services.WebAuthnService
+	authService     *services.AuthService
+	tokenService    *services.TokenService
+	sessionService  *services.SessionService
+	logger          *zap.Logger
+}
+
+// NewWebAuthnHandler creates a new WebAuthn handler
+func NewWebAuthnHandler(
+	webauthnService *services.WebAuthnService,
+	authService *services.AuthService,
+	tokenService *services.TokenService,
+	logger *zap.Logger,
+) *WebAuthnHandler {
+	return &WebAuthnHandler{
+		webauthnService: webauthnService,
+		authService:     authService,
+		tokenService:    tokenService,
+		logger:          logger,
+	}
+}
+
+// SetSessionService enables server-side sessions for passkey logins
+func (h *WebAuthnHandler) SetSessionService(sessionService *services.SessionService) {
+	h.sessionService = sessionService
+}
+
+// WebAuthnResponse represents a WebAuthn response
+type WebAuthnResponse struct {
+	Success bool           `json:"success"`
+	Data    *WebAuthnData  `json:"data,omitempty"`
+	Error   *ErrorResponse `json:"error,omitempty"`
+}
+
+type WebAuthnData struct {
+	// Ceremony options; their publicKey member is passed to navigator.credentials
+	RegistrationOptions *webauthn.RegistrationOptions `json:"registration_options,omitempty"`
+	LoginOptions        *webauthn.LoginOptions        `json:"login_options,omitempty"`
+
+	Credential   *webauthn.Credential   `json:"credential,omitempty"`
+	Credentials  []*webauthn.Credential `json:"credentials,omitempty"`
+	Verification *mfa.VerifyResponse    `json:"verification,omitempty"`
+}
+
+// BeginRegistration returns the options for registering a new credential
+func (h *WebAuthnHandler) BeginRegistration(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req webauthn.BeginRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	options, err := h.webauthnService.BeginRegistration(c.Request.Context(), userID, req.Name)
+	if err != nil {
+		h.webauthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebAuthnResponse{
+		Success: true,
+		Data:    &WebAuthnData{RegistrationOptions: options},
+	})
+}
+
+// FinishRegistration verifies the authenticator's response and stores the credential
+func (h *WebAuthnHandler) FinishRegistration(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req webauthn.FinishRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	credential, err := h.webauthnService.FinishRegistration(c.Request.Context(), userID, &req)
+	if err != nil {
+		h.webauthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, WebAuthnResponse{
+		Success: true,
+		Data:    &WebAuthnData{Credential: credential},
+	})
+}
+
+// ListCredentials lists the current user's security keys and passkeys
+func (h *WebAuthnHandler) ListCredentials(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	credentials, err := h.webauthnService.ListCredentials(c.Request.Context(), userID)
+	if err != nil {
+		h.webauthnError(c, err)
+		return
+	}
+
+	if credentials == nil {
+		credentials = []*webauthn.Credential{}
+	}
+
+	c.JSON(http.StatusOK, WebAuthnResponse{
+		Success: true,
+		Data:    &WebAuthnData{Credentials: credentials},
+	})
+}
+
+// RenameCredential renames one of the current user's credentials
+func (h *WebAuthnHandler) RenameCredential(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseCredentialID(c)
+	if !ok {
+		return
+	}
+
+	var req webauthn.RenameCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	if err := h.webauthnService.RenameCredential(c.Request.Context(), userID, id, req.Name); err != nil {
+		h.webauthnError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteCredential removes one of the current user's credentials
+func (h *WebAuthnHandler) DeleteCredential(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseCredentialID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.webauthnService.DeleteCredential(c.Request.Context(), userID, id); err != nil {
+		h.webauthnError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// BeginChallenge returns the options for a second-factor check with the user's credentials
+func (h *WebAuthnHandler) BeginChallenge(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	options, err := h.webauthnService.BeginChallenge(c.Request.Context(), userID)
+	if err != nil {
+		h.webauthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebAuthnResponse{
+		Success: true,
+		Data:    &WebAuthnData{LoginOptions: options},
+	})
+}
+
+// VerifyChallenge checks the authenticator's answer to a second-factor challenge
+func (h *WebAuthnHandler) VerifyChallenge(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req webauthn.FinishLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	result, err := h.webauthnService.VerifyChallenge(c.Request.Context(), userID, &req)
+	if err != nil {
+		h.webauthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebAuthnResponse{
+		Success: true,
+		Data:    &WebAuthnData{Verification: result},
+	})
+}
+
+// BeginPasskeyLogin returns the options for a passwordless login
+func (h *WebAuthnHandler) BeginPasskeyLogin(c *gin.Context) {
+	options, err := h.webauthnService.BeginLogin(c.Request.Context())
+	if err != nil {
+		h.webauthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebAuthnResponse{
+		Success: true,
+		Data:    &WebAuthnData{LoginOptions: options},
+	})
+}
+
+// PasskeyLogin signs in with a passkey assertion
+func (h *WebAuthnHandler) PasskeyLogin(c *gin.Context) {
+	var req webauthn.FinishLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.validationError(c, err)
+		return
+	}
+
+	tokenPair, u, err := h.authService.Login(c.Request.Context(), &auth.LoginRequest{
+		Passkey:   &req,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		h.loginError(c, err)
+		return
+	}
+
+	if h.sessionService != nil {
+		if !startSession(c, h.sessionService, h.tokenService, h.logger, u, tokenPair) {
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Success: true,
+		Data: &LoginResponseData{
+			AccessToken:  tokenPair.AccessToken,
+			RefreshToken: tokenPair.RefreshToken,
+			TokenType:    tokenPair.TokenType,
+			ExpiresIn:    tokenPair.ExpiresIn,
+			ExpiresAt:    tokenPair.ExpiresAt,
+			User: &UserInfo{
+				ID:        u.ID.String(),
+				Email:     u.Email,
+				Username:  u.Username,
+				FirstName: u.FirstName,
+				LastName:  u.LastName,
+			},
+		},
+	})
+}
+
+func parseCredentialID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("credentialId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, WebAuthnResponse{
+			Success: false,
+			Error: &ErrorResponse{
+				Code:    "INVALID_CREDENTIAL_ID",
+				Message: "Invalid credential ID format",
+			},
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *WebAuthnHandler) validationError(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, WebAuthnResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    "VALIDATION_ERROR",
+			Message: "Invalid request data",
+			Details: err.Error(),
+		},
+	})
+}
+
+// webauthnError maps credential and ceremony errors to responses
+func (h *WebAuthnHandler) webauthnError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "WebAuthn operation failed"
+
+	switch {
+	case errors.Is(err, webauthn.ErrCredentialNotFound):
+		status, code, message = http.StatusNotFound, "CREDENTIAL_NOT_FOUND", "Credential not found"
+	case errors.Is(err, webauthn.ErrCredentialExists):
+		status, code, message = http.StatusConflict, "CREDENTIAL_EXISTS", "This authenticator is already registered"
+	case errors.Is(err, webauthn.ErrInvalidCredentialName):
+		status, code, message = http.StatusBadRequest, "INVALID_CREDENTIAL_NAME", "Credential name is required"
+	case errors.Is(err, webauthn.ErrCeremonyNotFound), errors.Is(err, webauthn.ErrCeremonyExpired):
+		status, code, message = http.StatusBadRequest, "INVALID_CEREMONY", "The request is invalid or has expired; please start again"
+	case errors.Is(err, webauthn.ErrUnsupportedAlgorithm), errors.Is(err, webauthn.ErrUnsupportedAttestation):
+		status, code, message = http.StatusBadRequest, "UNSUPPORTED_AUTHENTICATOR", "This authenticator is not supported"
+	case errors.Is(err, webauthn.ErrUserVerificationRequired):
+		status, code, message = http.StatusUnauthorized, "USER_VERIFICATION_REQUIRED", "The authenticator must verify your identity"
+	case errors.Is(err, webauthn.ErrCloneDetected):
+		status, code, message = http.StatusUnauthorized, "CREDENTIAL_CLONED", "This credential may have been copied; register a new one"
+	case errors.Is(err, webauthn.ErrVerificationFailed):
+		status, code, message = http.StatusUnauthorized, "VERIFICATION_FAILED", "The authenticator response could not be verified"
+	case errors.Is(err, mfa.ErrMethodNotConfigured):
+		status, code, message = http.StatusNotFound, "METHOD_NOT_CONFIGURED", "No security keys or passkeys are registered"
+	default:
+		h.logger.Error("WebAuthn operation failed", zap.Error(err))
+	}
+
+	c.JSON(status, WebAuthnResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+			Details: detailsFor(status, err),
+		},
+	})
+}
+
+// loginError maps passkey login errors to responses without revealing why an assertion failed
+func (h *WebAuthnHandler) loginError(c *gin.Context, err error) {
+	status, code, message := http.StatusInternalServerError, "INTERNAL_ERROR", "Passkey sign-in failed"
+
+	switch {
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		status, code, message = http.StatusUnauthorized, "INVALID_CREDENTIALS", "The passkey could not be verified"
+	case errors.Is(err, auth.ErrAccountLocked):
+		status, code, message = http.StatusUnauthorized, "ACCOUNT_LOCKED", "Account is temporarily locked due to multiple failed login attempts"
+	case errors.Is(err, auth.ErrAccountInactive):
+		status, code, message = http.StatusUnauthorized, "ACCOUNT_INACTIVE", "Account is not active"
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		status, code, message = http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Please verify your email address before signing in"
+	case errors.Is(err, auth.ErrLoginBlocked):
+		status, code, message = http.StatusForbidden, "LOGIN_BLOCKED", "This sign-in looks unusual and has been blocked"
+	case errors.Is(err, auth.ErrLoginThrottled):
+		status, code, message = http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS", "Too many failed sign-in attempts. Please try again later"
+	default:
+		h.logger.Error("Passkey sign-in failed", zap.Error(err))
+	}
+
+	c.JSON(status, LoginResponse{
+		Success: false,
+		Error: &ErrorResponse{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
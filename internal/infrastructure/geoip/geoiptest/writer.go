@@ -0,0 +1,234 @@
+// Package geoiptest builds small MaxMind DB files for tests.
+package geoiptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+)
+
+// Network maps every address in a CIDR block to a record. Records may hold
+// maps with string keys, slices, strings, float64, bool and unsigned integers.
+type Network struct {
+	CIDR   string
+	Record map[string]interface{}
+}
+
+// Database describes a MaxMind DB file. Networks must not overlap.
+type Database struct {
+	Type       string // e.g. "GeoLite2-City"
+	IPVersion  int    // 4 or 6; IPv4 networks go under ::/96 in IPv6 databases
+	RecordSize int    // 24, 28 or 32
+	Networks   []Network
+}
+
+// empty and data mark records that do not point at another node
+const (
+	empty = -1
+	data  = -2
+)
+
+type node struct {
+	records [2]int // node index, empty, or data-k for the k-th network
+}
+
+// Bytes encodes the database
+func (d *Database) Bytes() ([]byte, error) {
+	nodes := []*node{{records: [2]int{empty, empty}}}
+
+	var section bytes.Buffer
+	offsets := make([]int, len(d.Networks))
+
+	for k, network := range d.Networks {
+		bits, prefix, err := d.networkBits(network.CIDR)
+		if err != nil {
+			return nil, err
+		}
+
+		offsets[k] = section.Len()
+		if err := encode(&section, network.Record); err != nil {
+			return nil, err
+		}
+
+		current := 0
+		for i := 0; i < prefix; i++ {
+			bit := bits[i]
+			if i == prefix-1 {
+				nodes[current].records[bit] = data - k
+				break
+			}
+			next := nodes[current].records[bit]
+			if next < 0 {
+				if next != empty {
+					return nil, fmt.Errorf("geoiptest: network %s overlaps another", network.CIDR)
+				}
+				nodes = append(nodes, &node{records: [2]int{empty, empty}})
+				next = len(nodes) - 1
+				nodes[current].records[bit] = next
+			}
+			current = next
+		}
+	}
+
+	nodeCount := len(nodes)
+	resolve := func(record int) uint32 {
+		switch {
+		case record >= 0:
+			return uint32(record)
+		case record == empty:
+			return uint32(nodeCount)
+		default:
+			return uint32(nodeCount + 16 + offsets[data-record])
+		}
+	}
+
+	var out bytes.Buffer
+	for _, n := range nodes {
+		left, right := resolve(n.records[0]), resolve(n.records[1])
+		switch d.RecordSize {
+		case 24:
+			out.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			out.Write([]byte{
+				byte(left >> 16), byte(left >> 8), byte(left),
+				byte(left>>20)&0xf0 | byte(right>>24)&0x0f,
+				byte(right >> 16), byte(right >> 8), byte(right),
+			})
+		case 32:
+			_ = binary.Write(&out, binary.BigEndian, [2]uint32{left, right})
+		default:
+			return nil, fmt.Errorf("geoiptest: unsupported record size %d", d.RecordSize)
+		}
+	}
+
+	out.Write(make([]byte, 16))
+	out.Write(section.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	if err := encode(&out, map[string]interface{}{
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(d.RecordSize),
+		"ip_version":                  uint16(d.IPVersion),
+		"database_type":               d.Type,
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(0),
+		"languages":                   []interface{}{"en"},
+	}); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// networkBits returns the address bits of a CIDR block in the database's tree and the prefix length
+func (d *Database) networkBits(cidr string) ([]int, int, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ones, _ := ipNet.Mask.Size()
+	ip := ipNet.IP
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		if d.IPVersion == 6 {
+			ip = append(make(net.IP, 12), ip4...)
+			ones += 96
+		}
+	} else if d.IPVersion == 4 {
+		return nil, 0, fmt.Errorf("geoiptest: IPv6 network %s in an IPv4 database", cidr)
+	}
+
+	bits := make([]int, len(ip)*8)
+	for i := range bits {
+		bits[i] = int(ip[i/8]>>(7-uint(i%8))) & 1
+	}
+	return bits, ones, nil
+}
+
+func encode(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		writeControl(buf, 7, len(v))
+		for _, key := range keys {
+			if err := encode(buf, key); err != nil {
+				return err
+			}
+			if err := encode(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		writeControl(buf, 11, len(v))
+		for _, item := range v {
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+	case string:
+		writeControl(buf, 2, len(v))
+		buf.WriteString(v)
+	case float64:
+		writeControl(buf, 3, 8)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		writeControl(buf, 14, size)
+	case uint16:
+		writeUint(buf, 5, uint64(v))
+	case uint32:
+		writeUint(buf, 6, uint64(v))
+	case uint64:
+		writeUint(buf, 9, v)
+	default:
+		return fmt.Errorf("geoiptest: unsupported value %T", value)
+	}
+	return nil
+}
+
+func writeUint(buf *bytes.Buffer, kind int, v uint64) {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	writeControl(buf, kind, len(b))
+	buf.Write(b)
+}
+
+// writeControl writes a field's type and size
+func writeControl(buf *bytes.Buffer, kind, size int) {
+	var sizeBits byte
+	var extra []byte
+	switch {
+	case size < 29:
+		sizeBits = byte(size)
+	case size < 285:
+		sizeBits, extra = 29, []byte{byte(size - 29)}
+	case size < 65821:
+		n := size - 285
+		sizeBits, extra = 30, []byte{byte(n >> 8), byte(n)}
+	default:
+		n := size - 65821
+		sizeBits, extra = 31, []byte{byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+
+	if kind > 7 {
+		buf.WriteByte(sizeBits)
+		buf.WriteByte(byte(kind - 7))
+	} else {
+		buf.WriteByte(byte(kind)<<5 | sizeBits)
+	}
+	buf.Write(extra)
+}
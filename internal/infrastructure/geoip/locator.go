@@ -0,0 +1,103 @@
+// Package geoip resolves IP addresses to locations using MaxMind DB files,
+// such as the GeoLite2 City and ASN databases, read entirely from disk.
+package geoip
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/victoralfred/um_sys/internal/domain/risk"
+)
+
+// Locator implements risk.Locator with a City (or Country) database for where an
+// address is and an ASN database for who owns its network. Either may be omitted.
+type Locator struct {
+	city *database
+	asn  *database
+}
+
+// NewLocator loads the databases at the given paths; an empty path skips that database
+func NewLocator(cityPath, asnPath string) (*Locator, error) {
+	l := &Locator{}
+
+	if cityPath != "" {
+		db, err := openDatabase(cityPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load city database: %w", err)
+		}
+		l.city = db
+	}
+
+	if asnPath != "" {
+		db, err := openDatabase(asnPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ASN database: %w", err)
+		}
+		l.asn = db
+	}
+
+	return l, nil
+}
+
+// Locate returns what the databases know about an address
+func (l *Locator) Locate(ip string) (*risk.Location, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, risk.ErrLocationNotFound
+	}
+
+	location := &risk.Location{}
+	found := false
+
+	if l.city != nil {
+		record, ok, err := l.city.lookup(addr)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = true
+			location.Country, _ = field(record, "country", "iso_code").(string)
+			location.City, _ = field(record, "city", "names", "en").(string)
+			location.Latitude = floatField(record, "location", "latitude")
+			location.Longitude = floatField(record, "location", "longitude")
+		}
+	}
+
+	if l.asn != nil {
+		record, ok, err := l.asn.lookup(addr)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = true
+			asn, _ := field(record, "autonomous_system_number").(uint64)
+			location.ASN = uint32(asn)
+			location.Organization, _ = field(record, "autonomous_system_organization").(string)
+		}
+	}
+
+	if !found {
+		return nil, risk.ErrLocationNotFound
+	}
+	return location, nil
+}
+
+// field follows a path of map keys through a record
+func field(record interface{}, path ...string) interface{} {
+	for _, key := range path {
+		m, ok := record.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		record = m[key]
+	}
+	return record
+}
+
+func floatField(record interface{}, path ...string) *float64 {
+	v, ok := field(record, path...).(float64)
+	if !ok {
+		return nil
+	}
+	return &v
+}
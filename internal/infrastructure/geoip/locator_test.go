@@ -0,0 +1,146 @@
+package geoip_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/risk"
+	"github.com/victoralfred/um_sys/internal/infrastructure/geoip"
+	"github.com/victoralfred/um_sys/internal/infrastructure/geoip/geoiptest"
+)
+
+func cityRecord(country, city string, lat, lon float64) map[string]interface{} {
+	return map[string]interface{}{
+		"country": map[string]interface{}{"iso_code": country},
+		"city": map[string]interface{}{
+			"names": map[string]interface{}{"en": city, "de": city},
+		},
+		"location": map[string]interface{}{
+			"latitude":        lat,
+			"longitude":       lon,
+			"accuracy_radius": uint16(20),
+		},
+	}
+}
+
+func asnRecord(asn uint32, org string) map[string]interface{} {
+	return map[string]interface{}{
+		"autonomous_system_number":       asn,
+		"autonomous_system_organization": org,
+	}
+}
+
+func writeDatabase(t *testing.T, db *geoiptest.Database) string {
+	t.Helper()
+
+	data, err := db.Bytes()
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), db.Type+".mmdb")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestLocator(t *testing.T) {
+	for _, recordSize := range []int{24, 28, 32} {
+		for _, ipVersion := range []int{4, 6} {
+			t.Run(fmt.Sprintf("IPv%d database with %d-bit records", ipVersion, recordSize), func(t *testing.T) {
+				testLocator(t, ipVersion, recordSize)
+			})
+		}
+	}
+}
+
+func testLocator(t *testing.T, ipVersion, recordSize int) {
+	networks := []geoiptest.Network{
+		{CIDR: "81.2.69.0/24", Record: cityRecord("GB", "London", 51.5142, -0.0931)},
+		{CIDR: "175.16.199.0/24", Record: cityRecord("CN", "Changchun", 43.88, 125.3228)},
+	}
+	if ipVersion == 6 {
+		networks = append(networks, geoiptest.Network{
+			CIDR: "2001:db8::/32", Record: cityRecord("US", "Boston", 42.3601, -71.0589),
+		})
+	}
+
+	cityPath := writeDatabase(t, &geoiptest.Database{
+		Type: "GeoLite2-City", IPVersion: ipVersion, RecordSize: recordSize, Networks: networks,
+	})
+	asnPath := writeDatabase(t, &geoiptest.Database{
+		Type: "GeoLite2-ASN", IPVersion: ipVersion, RecordSize: recordSize,
+		Networks: []geoiptest.Network{
+			{CIDR: "81.2.64.0/20", Record: asnRecord(20712, "Andrews & Arnold Ltd")},
+			{CIDR: "1.128.0.0/11", Record: asnRecord(1221, "Telstra Pty Ltd")},
+		},
+	})
+
+	locator, err := geoip.NewLocator(cityPath, asnPath)
+	require.NoError(t, err)
+
+	t.Run("combines city and network data", func(t *testing.T) {
+		location, err := locator.Locate("81.2.69.160")
+		require.NoError(t, err)
+		assert.Equal(t, "GB", location.Country)
+		assert.Equal(t, "London", location.City)
+		require.True(t, location.HasCoordinates())
+		assert.InDelta(t, 51.5142, *location.Latitude, 1e-9)
+		assert.InDelta(t, -0.0931, *location.Longitude, 1e-9)
+		assert.Equal(t, uint32(20712), location.ASN)
+		assert.Equal(t, "Andrews & Arnold Ltd", location.Organization)
+	})
+
+	t.Run("returns what either database knows", func(t *testing.T) {
+		location, err := locator.Locate("175.16.199.1")
+		require.NoError(t, err)
+		assert.Equal(t, "Changchun", location.City)
+		assert.Zero(t, location.ASN)
+
+		location, err = locator.Locate("1.128.0.1")
+		require.NoError(t, err)
+		assert.Empty(t, location.Country)
+		assert.False(t, location.HasCoordinates())
+		assert.Equal(t, uint32(1221), location.ASN)
+	})
+
+	t.Run("reports unknown addresses", func(t *testing.T) {
+		for _, ip := range []string{"10.0.0.1", "81.2.80.1", "not an address"} {
+			_, err := locator.Locate(ip)
+			assert.ErrorIs(t, err, risk.ErrLocationNotFound, ip)
+		}
+	})
+
+	t.Run("looks up IPv6 addresses", func(t *testing.T) {
+		location, err := locator.Locate("2001:db8::1")
+		if ipVersion == 4 {
+			assert.ErrorIs(t, err, risk.ErrLocationNotFound)
+			return
+		}
+		require.NoError(t, err)
+		assert.Equal(t, "Boston", location.City)
+	})
+}
+
+func TestNewLocator(t *testing.T) {
+	t.Run("works without any database", func(t *testing.T) {
+		locator, err := geoip.NewLocator("", "")
+		require.NoError(t, err)
+
+		_, err = locator.Locate("81.2.69.160")
+		assert.ErrorIs(t, err, risk.ErrLocationNotFound)
+	})
+
+	t.Run("rejects files that are not databases", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "city.mmdb")
+		require.NoError(t, os.WriteFile(path, []byte("not a database"), 0o600))
+
+		_, err := geoip.NewLocator(path, "")
+		assert.Error(t, err)
+
+		_, err = geoip.NewLocator(filepath.Join(t.TempDir(), "missing.mmdb"), "")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,348 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+)
+
+// metadataMarker precedes the metadata map at the end of a MaxMind DB file
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the run of zero bytes between the search tree and the data section
+const dataSectionSeparator = 16
+
+// maxDecodeDepth bounds nesting and pointer chains so a corrupt file cannot exhaust the stack
+const maxDecodeDepth = 32
+
+// Data section field types
+const (
+	typeExtended = 0
+	typePointer  = 1
+	typeString   = 2
+	typeDouble   = 3
+	typeBytes    = 4
+	typeUint16   = 5
+	typeUint32   = 6
+	typeMap      = 7
+	typeInt32    = 8
+	typeUint64   = 9
+	typeUint128  = 10
+	typeArray    = 11
+	typeBoolean  = 14
+	typeFloat    = 15
+)
+
+var errTruncated = errors.New("geoip: unexpected end of data")
+
+// database is a MaxMind DB file held in memory. Records decode to generic values:
+// maps to map[string]interface{}, arrays to []interface{}, unsigned integers to
+// uint64 (or *big.Int past 64 bits), int32 to int32, doubles to float64 and
+// floats to float32.
+type database struct {
+	tree         []byte
+	data         []byte
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	ipv4Start    uint
+	databaseType string
+}
+
+func openDatabase(path string) (*database, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+	return parseDatabase(buf)
+}
+
+func parseDatabase(buf []byte) (*database, error) {
+	markerAt := bytes.LastIndex(buf, metadataMarker)
+	if markerAt < 0 {
+		return nil, errors.New("geoip: not a MaxMind DB file")
+	}
+
+	metadata := buf[markerAt+len(metadataMarker):]
+	value, _, err := decodeValue(metadata, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("geoip: invalid metadata: %w", err)
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("geoip: invalid metadata")
+	}
+
+	db := &database{
+		nodeCount:  uint(uintField(fields, "node_count")),
+		recordSize: uint(uintField(fields, "record_size")),
+		ipVersion:  uint(uintField(fields, "ip_version")),
+	}
+	db.databaseType, _ = fields["database_type"].(string)
+
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("geoip: unsupported record size %d", db.recordSize)
+	}
+	if db.ipVersion != 4 && db.ipVersion != 6 {
+		return nil, fmt.Errorf("geoip: unsupported IP version %d", db.ipVersion)
+	}
+
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(markerAt) {
+		return nil, errors.New("geoip: search tree is larger than the file")
+	}
+	db.tree = buf[:treeSize]
+	db.data = buf[treeSize+dataSectionSeparator : markerAt]
+
+	// IPv4 addresses live under ::/96 in IPv6 databases
+	if db.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < db.nodeCount; i++ {
+			node = db.readRecord(node, 0)
+		}
+		db.ipv4Start = node
+	}
+
+	return db, nil
+}
+
+// lookup returns the record for the network containing ip, or false if there is none
+func (db *database) lookup(ip net.IP) (interface{}, bool, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	} else if ip = ip.To16(); ip == nil || db.ipVersion == 4 {
+		return nil, false, nil
+	}
+
+	node := uint(0)
+	if len(ip) == net.IPv4len && db.ipVersion == 6 {
+		node = db.ipv4Start
+	}
+
+	for i := 0; i < len(ip)*8 && node < db.nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i&7))) & 1
+		node = db.readRecord(node, bit)
+	}
+
+	if node == db.nodeCount {
+		return nil, false, nil
+	}
+	if node < db.nodeCount {
+		return nil, false, errors.New("geoip: search tree is deeper than the address")
+	}
+
+	offset := node - db.nodeCount - dataSectionSeparator
+	if offset >= uint(len(db.data)) {
+		return nil, false, errors.New("geoip: record points outside the data section")
+	}
+
+	value, _, err := decodeValue(db.data, offset, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// readRecord returns the left (bit 0) or right (bit 1) record of a node
+func (db *database) readRecord(node, bit uint) uint {
+	switch db.recordSize {
+	case 24:
+		b := db.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := db.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(db.tree[node*8+bit*4:]))
+	}
+}
+
+// decodeValue decodes the field at offset and returns it with the offset of the next field
+func decodeValue(data []byte, offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errors.New("geoip: data nested too deep")
+	}
+	if offset >= uint(len(data)) {
+		return nil, 0, errTruncated
+	}
+
+	ctrl := data[offset]
+	offset++
+	kind := uint(ctrl >> 5)
+
+	if kind == typePointer {
+		target, next, err := decodePointer(data, ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := decodeValue(data, target, depth+1)
+		return value, next, err
+	}
+
+	if kind == typeExtended {
+		if offset >= uint(len(data)) {
+			return nil, 0, errTruncated
+		}
+		kind = 7 + uint(data[offset])
+		offset++
+	}
+
+	size, offset, err := decodeSize(data, ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch kind {
+	case typeMap:
+		return decodeMap(data, size, offset, depth)
+	case typeArray:
+		return decodeArray(data, size, offset, depth)
+	case typeBoolean:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(data)) {
+		return nil, 0, errTruncated
+	}
+	payload := data[offset : offset+size]
+	next := offset + size
+
+	switch kind {
+	case typeString:
+		return string(payload), next, nil
+	case typeBytes:
+		return append([]byte(nil), payload...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("geoip: invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(payload)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("geoip: invalid float size %d", size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(payload)), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("geoip: invalid integer size %d", size)
+		}
+		return decodeUint(payload), next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("geoip: invalid integer size %d", size)
+		}
+		return int32(decodeUint(payload)), next, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("geoip: invalid integer size %d", size)
+		}
+		if size <= 8 {
+			return decodeUint(payload), next, nil
+		}
+		return new(big.Int).SetBytes(payload), next, nil
+	default:
+		return nil, 0, fmt.Errorf("geoip: unsupported data type %d", kind)
+	}
+}
+
+func decodeMap(data []byte, size, offset uint, depth int) (interface{}, uint, error) {
+	m := make(map[string]interface{}, min(size, 64))
+	for i := uint(0); i < size; i++ {
+		key, next, err := decodeValue(data, offset, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, 0, errors.New("geoip: map key is not a string")
+		}
+
+		value, next, err := decodeValue(data, next, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		m[name] = value
+		offset = next
+	}
+	return m, offset, nil
+}
+
+func decodeArray(data []byte, size, offset uint, depth int) (interface{}, uint, error) {
+	values := make([]interface{}, 0, min(size, 64))
+	for i := uint(0); i < size; i++ {
+		value, next, err := decodeValue(data, offset, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		values = append(values, value)
+		offset = next
+	}
+	return values, offset, nil
+}
+
+// decodeSize reads a field's payload size, which may spill into up to three more bytes
+func decodeSize(data []byte, ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > uint(len(data)) {
+		return 0, 0, errTruncated
+	}
+	extra := uint(decodeUint(data[offset : offset+n]))
+
+	switch size {
+	case 29:
+		size = 29 + extra
+	case 30:
+		size = 285 + extra
+	default:
+		size = 65821 + extra
+	}
+	return size, offset + n, nil
+}
+
+// decodePointer returns the data section offset a pointer refers to and the offset after it
+func decodePointer(data []byte, ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3)&0x3 + 1
+	if offset+n > uint(len(data)) {
+		return 0, 0, errTruncated
+	}
+	value := uint(decodeUint(data[offset : offset+n]))
+	high := uint(ctrl & 0x7)
+
+	switch n {
+	case 1:
+		value |= high << 8
+	case 2:
+		value = (value | high<<16) + 2048
+	case 3:
+		value = (value | high<<24) + 526336
+	}
+	return value, offset + n, nil
+}
+
+func decodeUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func uintField(fields map[string]interface{}, name string) uint64 {
+	v, _ := fields[name].(uint64)
+	return v
+}
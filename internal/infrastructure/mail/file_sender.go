@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	domainMail "github.com/victoralfred/um_sys/internal/domain/mail"
+)
+
+// FileSender implements mail.Sender by writing each message to a .eml file.
+// It stands in for a real mail server in development and tests.
+type FileSender struct {
+	dir  string
+	from string
+	seq  atomic.Uint64
+}
+
+// NewFileSender creates a sender that writes messages into dir
+func NewFileSender(dir, from string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	return &FileSender{
+		dir:  dir,
+		from: from,
+	}, nil
+}
+
+// Send writes the message to a new file in the sender's directory
+func (s *FileSender) Send(ctx context.Context, msg *domainMail.Message) error {
+	if err := validateMessage(msg); err != nil {
+		return err
+	}
+
+	data, err := formatMessage(s.from, msg)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%06d.eml", time.Now().UnixNano(), s.seq.Add(1))
+	if err := os.WriteFile(filepath.Join(s.dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return nil
+}
+
+// Dir returns the directory messages are written to
+func (s *FileSender) Dir() string {
+	return s.dir
+}
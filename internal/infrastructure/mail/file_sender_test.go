@@ -0,0 +1,82 @@
+package mail_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	domainMail "github.com/victoralfred/um_sys/internal/domain/mail"
+	mailImpl "github.com/victoralfred/um_sys/internal/infrastructure/mail"
+)
+
+func TestFileSender_Send(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("writes plain text message", func(t *testing.T) {
+		sender, err := mailImpl.NewFileSender(t.TempDir(), "noreply@example.com")
+		require.NoError(t, err)
+
+		err = sender.Send(ctx, &domainMail.Message{
+			To:       []string{"user@example.com"},
+			Subject:  "Hello",
+			TextBody: "Plain body",
+		})
+		require.NoError(t, err)
+
+		files, err := filepath.Glob(filepath.Join(sender.Dir(), "*.eml"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+
+		data, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "From: noreply@example.com\r\n")
+		assert.Contains(t, string(data), "To: user@example.com\r\n")
+		assert.Contains(t, string(data), "Subject: Hello\r\n")
+		assert.Contains(t, string(data), "Plain body")
+	})
+
+	t.Run("writes multipart message when html is set", func(t *testing.T) {
+		sender, err := mailImpl.NewFileSender(t.TempDir(), "noreply@example.com")
+		require.NoError(t, err)
+
+		err = sender.Send(ctx, &domainMail.Message{
+			To:       []string{"user@example.com"},
+			Subject:  "Hello",
+			TextBody: "Plain body",
+			HTMLBody: "<p>HTML body</p>",
+		})
+		require.NoError(t, err)
+
+		files, err := filepath.Glob(filepath.Join(sender.Dir(), "*.eml"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+
+		data, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "multipart/alternative")
+		assert.Contains(t, string(data), "<p>HTML body</p>")
+	})
+
+	t.Run("rejects messages without recipients", func(t *testing.T) {
+		sender, err := mailImpl.NewFileSender(t.TempDir(), "noreply@example.com")
+		require.NoError(t, err)
+
+		err = sender.Send(ctx, &domainMail.Message{Subject: "Hello"})
+		assert.ErrorIs(t, err, domainMail.ErrNoRecipients)
+	})
+
+	t.Run("rejects header injection in recipients", func(t *testing.T) {
+		sender, err := mailImpl.NewFileSender(t.TempDir(), "noreply@example.com")
+		require.NoError(t, err)
+
+		err = sender.Send(ctx, &domainMail.Message{
+			To:      []string{"user@example.com\r\nBcc: attacker@example.com"},
+			Subject: "Hello",
+		})
+		assert.ErrorIs(t, err, domainMail.ErrInvalidAddress)
+	})
+}
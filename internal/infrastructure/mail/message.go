@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	domainMail "github.com/victoralfred/um_sys/internal/domain/mail"
+)
+
+// validateMessage checks that a message can be delivered
+func validateMessage(msg *domainMail.Message) error {
+	if msg == nil || len(msg.To) == 0 {
+		return domainMail.ErrNoRecipients
+	}
+
+	for _, to := range msg.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("%w: %s", domainMail.ErrInvalidAddress, to)
+		}
+		// Header injection guard; ParseAddress accepts some folded input
+		if strings.ContainsAny(to, "\r\n") {
+			return fmt.Errorf("%w: %s", domainMail.ErrInvalidAddress, to)
+		}
+	}
+
+	return nil
+}
+
+// formatMessage renders a message as an RFC 5322 document
+func formatMessage(from string, msg *domainMail.Message) ([]byte, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", sanitizeHeader(msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTMLBody == "" {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		buf.WriteString(msg.TextBody)
+		return buf.Bytes(), nil
+	}
+
+	boundary, err := newBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&buf, "--%s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n", boundary, msg.TextBody)
+	fmt.Fprintf(&buf, "--%s\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n", boundary, msg.HTMLBody)
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
+
+// sanitizeHeader strips line breaks so a header value cannot inject new headers
+func sanitizeHeader(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// newBoundary generates a random MIME boundary
+func newBoundary() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate boundary: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
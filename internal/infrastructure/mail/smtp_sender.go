@@ -0,0 +1,54 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+
+	domainMail "github.com/victoralfred/um_sys/internal/domain/mail"
+)
+
+// SMTPSender implements mail.Sender over SMTP
+type SMTPSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPSender creates a new SMTP sender. Authentication is skipped when username is empty.
+func NewSMTPSender(host string, port int, username, password, from string) *SMTPSender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPSender{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		from: from,
+		auth: auth,
+	}
+}
+
+// Send delivers the message through the SMTP server
+func (s *SMTPSender) Send(ctx context.Context, msg *domainMail.Message) error {
+	if err := validateMessage(msg); err != nil {
+		return err
+	}
+
+	data, err := formatMessage(s.from, msg)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(s.addr, s.auth, s.from, msg.To, data); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+
+	return nil
+}
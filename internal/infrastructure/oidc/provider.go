@@ -0,0 +1,286 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/federation"
+	"github.com/victoralfred/um_sys/internal/domain/oauth"
+)
+
+const (
+	// keyRefreshInterval limits how often an unknown kid triggers a JWKS refetch
+	keyRefreshInterval = 30 * time.Second
+
+	// maxResponseSize caps the body read from any provider endpoint
+	maxResponseSize = 1 << 20
+)
+
+// defaultScopes are requested when a provider does not configure its own
+var defaultScopes = []string{oauth.ScopeOpenID, oauth.ScopeEmail, oauth.ScopeProfile}
+
+// Provider implements federation.IdentityProvider for an upstream OpenID Connect
+// provider, using the authorization code flow with PKCE and verifying ID tokens
+// against the provider's published keys.
+type Provider struct {
+	config     federation.Provider
+	httpClient *http.Client
+	metadata   oauth.DiscoveryDocument
+
+	mu            sync.RWMutex
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// NewProvider creates a provider from its configuration, fetching the discovery document
+func NewProvider(ctx context.Context, config federation.Provider, httpClient *http.Client) (*Provider, error) {
+	if config.Issuer == "" || config.ClientID == "" {
+		return nil, fmt.Errorf("provider %q: issuer and client_id are required", config.ID)
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	p := &Provider{
+		config:     config,
+		httpClient: httpClient,
+	}
+
+	discoveryURL := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, discoveryURL, &p.metadata); err != nil {
+		return nil, fmt.Errorf("provider %q: failed to fetch discovery document: %w", config.ID, err)
+	}
+
+	// The issuer in the metadata must match the configured one exactly (OpenID Connect Discovery 1.0 section 4.3)
+	if p.metadata.Issuer != config.Issuer {
+		return nil, fmt.Errorf("provider %q: discovery issuer %q does not match configured issuer", config.ID, p.metadata.Issuer)
+	}
+	if p.metadata.AuthorizationEndpoint == "" || p.metadata.TokenEndpoint == "" || p.metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("provider %q: discovery document is missing required endpoints", config.ID)
+	}
+
+	return p, nil
+}
+
+// Config returns the provider configuration
+func (p *Provider) Config() federation.Provider {
+	return p.config
+}
+
+// AuthCodeURL returns the URL that starts an authorization code flow at the provider
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+
+	params := url.Values{}
+	params.Set("response_type", oauth.ResponseTypeCode)
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.config.RedirectURL)
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", oauth.CodeChallengeMethodS256)
+
+	separator := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.metadata.AuthorizationEndpoint + separator + params.Encode()
+}
+
+// Exchange redeems an authorization code and returns the claims of the verified ID token
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*federation.ExternalClaims, error) {
+	form := url.Values{}
+	form.Set("grant_type", oauth.GrantTypeAuthorizationCode)
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// client_secret_basic requires the credentials to be form-encoded first (RFC 6749 section 2.3.1)
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", federation.ErrUpstreamAuthFailed, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", federation.ErrUpstreamAuthFailed, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("%w: %s: %s", federation.ErrUpstreamAuthFailed, errResp.Error, errResp.Description)
+		}
+		return nil, fmt.Errorf("%w: token endpoint returned status %d", federation.ErrUpstreamAuthFailed, resp.StatusCode)
+	}
+
+	var tokenResp oauth.TokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("%w: malformed token response", federation.ErrUpstreamAuthFailed)
+	}
+	if tokenResp.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no id_token", federation.ErrInvalidIDToken)
+	}
+
+	return p.verifyIDToken(ctx, tokenResp.IDToken, nonce)
+}
+
+// verifyIDToken validates an ID token per OpenID Connect Core 1.0 section 3.1.3.7
+func (p *Provider) verifyIDToken(ctx context.Context, rawToken, nonce string) (*federation.ExternalClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.publicKey(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{
+			string(auth.SigningAlgorithmRS256),
+			string(auth.SigningAlgorithmES256),
+			string(auth.SigningAlgorithmEdDSA),
+		}),
+		jwt.WithIssuer(p.metadata.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", federation.ErrInvalidIDToken, err)
+	}
+
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", federation.ErrInvalidIDToken)
+	}
+
+	// With several audiences the token must name us as the authorized party
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.config.ClientID {
+			return nil, fmt.Errorf("%w: azp does not match client_id", federation.ErrInvalidIDToken)
+		}
+	}
+
+	external := &federation.ExternalClaims{
+		Subject:           stringClaim(claims, "sub"),
+		Email:             stringClaim(claims, "email"),
+		EmailVerified:     boolClaim(claims, "email_verified"),
+		Name:              stringClaim(claims, "name"),
+		GivenName:         stringClaim(claims, "given_name"),
+		FamilyName:        stringClaim(claims, "family_name"),
+		PreferredUsername: stringClaim(claims, "preferred_username"),
+	}
+	if external.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", federation.ErrInvalidIDToken)
+	}
+
+	return external, nil
+}
+
+// publicKey returns the provider key with the given kid, refetching the key set
+// when the kid is unknown so that key rotation at the provider is picked up
+func (p *Provider) publicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.RLock()
+	key, ok := p.lookupKey(kid)
+	stale := time.Since(p.keysFetchedAt) > keyRefreshInterval
+	p.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set auth.JWKS
+	if err := p.getJSON(ctx, p.metadata.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch provider keys: %w", err)
+	}
+
+	p.mu.Lock()
+	p.keys = parseJWKS(&set)
+	p.keysFetchedAt = time.Now()
+	key, ok = p.lookupKey(kid)
+	p.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookupKey finds a key by kid; a token without a kid is accepted only when
+// the provider publishes a single key. Callers must hold p.mu.
+func (p *Provider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" {
+		if len(p.keys) == 1 {
+			for _, key := range p.keys {
+				return key, true
+			}
+		}
+		return nil, false
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, endpoint)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+// boolClaim reads a boolean claim, tolerating providers that send "true" as a string
+func boolClaim(claims jwt.MapClaims, name string) bool {
+	switch v := claims[name].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,121 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/federation"
+	"github.com/victoralfred/um_sys/internal/infrastructure/oidc"
+	"github.com/victoralfred/um_sys/internal/infrastructure/oidc/oidctest"
+)
+
+const (
+	testCallbackURL  = "http://localhost:8080/v1/auth/federated/stub/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func newTestProvider(t *testing.T, idp *oidctest.Server, secret string) *oidc.Provider {
+	t.Helper()
+
+	provider, err := oidc.NewProvider(context.Background(), federation.Provider{
+		ID:           "stub",
+		Issuer:       idp.Issuer(),
+		ClientID:     idp.ClientID,
+		ClientSecret: secret,
+		RedirectURL:  testCallbackURL,
+	}, nil)
+	require.NoError(t, err)
+	return provider
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	ctx := context.Background()
+
+	idp, err := oidctest.NewServer("um-sys", "stub-secret")
+	require.NoError(t, err)
+	defer idp.Close()
+
+	idp.SetUser(federation.ExternalClaims{
+		Subject:       "upstream-123",
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		Name:          "Jane Doe",
+	})
+
+	authorize := func(t *testing.T, provider *oidc.Provider, nonce string) string {
+		callback, err := idp.Authorize(provider.AuthCodeURL("state-1", nonce, codeChallenge(testCodeVerifier)))
+		require.NoError(t, err)
+		assert.Equal(t, "state-1", callback.Query().Get("state"))
+		return callback.Query().Get("code")
+	}
+
+	t.Run("verifies the id token and returns its claims", func(t *testing.T) {
+		provider := newTestProvider(t, idp, "stub-secret")
+		code := authorize(t, provider, "nonce-1")
+
+		claims, err := provider.Exchange(ctx, code, testCodeVerifier, "nonce-1")
+		require.NoError(t, err)
+		assert.Equal(t, "upstream-123", claims.Subject)
+		assert.Equal(t, "jane@example.com", claims.Email)
+		assert.True(t, claims.EmailVerified)
+		assert.Equal(t, "Jane Doe", claims.Name)
+	})
+
+	t.Run("rejects a nonce mismatch", func(t *testing.T) {
+		provider := newTestProvider(t, idp, "stub-secret")
+		code := authorize(t, provider, "nonce-1")
+
+		_, err := provider.Exchange(ctx, code, testCodeVerifier, "other-nonce")
+		assert.ErrorIs(t, err, federation.ErrInvalidIDToken)
+	})
+
+	t.Run("fails with the wrong code verifier", func(t *testing.T) {
+		provider := newTestProvider(t, idp, "stub-secret")
+		code := authorize(t, provider, "nonce-1")
+
+		_, err := provider.Exchange(ctx, code, "wrong-verifier-wrong-verifier-wrong-verifier", "nonce-1")
+		assert.ErrorIs(t, err, federation.ErrUpstreamAuthFailed)
+	})
+
+	t.Run("fails with the wrong client secret", func(t *testing.T) {
+		provider := newTestProvider(t, idp, "wrong-secret")
+		code := authorize(t, provider, "nonce-1")
+
+		_, err := provider.Exchange(ctx, code, testCodeVerifier, "nonce-1")
+		assert.ErrorIs(t, err, federation.ErrUpstreamAuthFailed)
+	})
+
+	t.Run("rejects a replayed code", func(t *testing.T) {
+		provider := newTestProvider(t, idp, "stub-secret")
+		code := authorize(t, provider, "nonce-1")
+
+		_, err := provider.Exchange(ctx, code, testCodeVerifier, "nonce-1")
+		require.NoError(t, err)
+
+		_, err = provider.Exchange(ctx, code, testCodeVerifier, "nonce-1")
+		assert.ErrorIs(t, err, federation.ErrUpstreamAuthFailed)
+	})
+}
+
+func TestNewProvider_IssuerMismatch(t *testing.T) {
+	idp, err := oidctest.NewServer("um-sys", "stub-secret")
+	require.NoError(t, err)
+	defer idp.Close()
+
+	_, err = oidc.NewProvider(context.Background(), federation.Provider{
+		ID:       "stub",
+		Issuer:   idp.Issuer() + "/",
+		ClientID: idp.ClientID,
+	}, nil)
+	assert.Error(t, err)
+}
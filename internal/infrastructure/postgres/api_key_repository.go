@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/apikey"
+)
+
+const apiKeyColumns = `
+	id, owner_type, owner_id, created_by, name, prefix, key_hash, scopes, allowed_ips,
+	expires_at, last_used_at, COALESCE(last_used_ip, ''), revoked_at, created_at
+`
+
+// APIKeyRepository implements apikey.Repository
+type APIKeyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAPIKeyRepository(db *pgxpool.Pool) *APIKeyRepository {
+	return &APIKeyRepository{
+		db: db,
+	}
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, key *apikey.APIKey) error {
+	query := `
+		INSERT INTO api_keys (
+			id, owner_type, owner_id, created_by, name, prefix, key_hash, scopes, allowed_ips,
+			expires_at, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		)
+	`
+
+	allowedIPs := key.AllowedIPs
+	if allowedIPs == nil {
+		allowedIPs = []string{}
+	}
+
+	_, err := r.db.Exec(ctx, query,
+		key.ID,
+		key.OwnerType,
+		key.OwnerID,
+		key.CreatedBy,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		key.Scopes,
+		allowedIPs,
+		key.ExpiresAt,
+		key.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*apikey.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+
+	key, err := r.scanAPIKey(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, apikey.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (r *APIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*apikey.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+
+	key, err := r.scanAPIKey(r.db.QueryRow(ctx, query, keyHash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, apikey.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (r *APIKeyRepository) ListByOwner(ctx context.Context, ownerType apikey.OwnerType, ownerID uuid.UUID) ([]*apikey.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE owner_type = $1 AND owner_id = $2
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, ownerType, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*apikey.APIKey
+	for rows.Next() {
+		key, err := r.scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *APIKeyRepository) CountActiveByOwner(ctx context.Context, ownerType apikey.OwnerType, ownerID uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM api_keys
+		WHERE owner_type = $1 AND owner_id = $2
+		  AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > NOW())
+	`
+
+	var count int
+	if err := r.db.QueryRow(ctx, query, ownerType, ownerID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count api keys: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *APIKeyRepository) Revoke(ctx context.Context, id uuid.UUID, revokedAt time.Time) error {
+	result, err := r.db.Exec(ctx,
+		"UPDATE api_keys SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL",
+		id, revokedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return apikey.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepository) UpdateLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time, ip string) error {
+	_, err := r.db.Exec(ctx,
+		"UPDATE api_keys SET last_used_at = $2, last_used_ip = $3 WHERE id = $1",
+		id, usedAt, ip,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update api key usage: %w", err)
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepository) scanAPIKey(row pgx.Row) (*apikey.APIKey, error) {
+	var key apikey.APIKey
+	err := row.Scan(
+		&key.ID,
+		&key.OwnerType,
+		&key.OwnerID,
+		&key.CreatedBy,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&key.Scopes,
+		&key.AllowedIPs,
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.LastUsedIP,
+		&key.RevokedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
@@ -29,9 +29,9 @@ func (r *AuditLogRepository) Create(ctx context.Context, entry *audit.LogEntry)
 		INSERT INTO audit_logs (
 			id, timestamp, event_type, severity, user_id, actor_id, entity_type, 
 			entity_id, action, description, ip_address, user_agent, metadata, 
-			changes, request_id, session_id, trace_id, created_at
+			changes, request_id, session_id, trace_id, created_at, actor_type
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19
 		)
 	`
 
@@ -60,6 +60,11 @@ func (r *AuditLogRepository) Create(ctx context.Context, entry *audit.LogEntry)
 		ipAddress = entry.IPAddress
 	}
 
+	var actorType interface{}
+	if entry.ActorType != "" {
+		actorType = string(entry.ActorType)
+	}
+
 	_, err = r.db.Exec(ctx, query,
 		entry.ID,
 		entry.Timestamp,
@@ -79,6 +84,7 @@ func (r *AuditLogRepository) Create(ctx context.Context, entry *audit.LogEntry)
 		entry.SessionID,
 		entry.TraceID,
 		entry.CreatedAt,
+		actorType,
 	)
 
 	if err != nil {
@@ -92,7 +98,7 @@ func (r *AuditLogRepository) GetByID(ctx context.Context, id uuid.UUID) (*audit.
 	query := `
 		SELECT id, timestamp, event_type, severity, user_id, actor_id, entity_type,
 			   entity_id, action, description, ip_address, user_agent, metadata,
-			   changes, request_id, session_id, trace_id, created_at
+			   changes, request_id, session_id, trace_id, created_at, actor_type
 		FROM audit_logs
 		WHERE id = $1
 	`
@@ -116,7 +122,7 @@ func (r *AuditLogRepository) List(ctx context.Context, filter audit.LogFilter) (
 	dataQuery := fmt.Sprintf(`
 		SELECT id, timestamp, event_type, severity, user_id, actor_id, entity_type,
 			   entity_id, action, description, ip_address, user_agent, metadata,
-			   changes, request_id, session_id, trace_id, created_at
+			   changes, request_id, session_id, trace_id, created_at, actor_type
 		FROM audit_logs %s
 		ORDER BY timestamp DESC
 		LIMIT $%d OFFSET $%d
@@ -353,7 +359,7 @@ func (r *AuditLogRepository) scanLogEntry(scanner interface {
 	var entry audit.LogEntry
 	var eventType, severity string
 	var metadataJSON, changesJSON []byte
-	var ipAddress, userAgent, description, requestID, sessionID, traceID sql.NullString
+	var ipAddress, userAgent, description, requestID, sessionID, traceID, actorType sql.NullString
 
 	err := scanner.Scan(
 		&entry.ID,
@@ -374,6 +380,7 @@ func (r *AuditLogRepository) scanLogEntry(scanner interface {
 		&sessionID,
 		&traceID,
 		&entry.CreatedAt,
+		&actorType,
 	)
 
 	if err != nil {
@@ -405,6 +412,9 @@ func (r *AuditLogRepository) scanLogEntry(scanner interface {
 	if traceID.Valid {
 		entry.TraceID = traceID.String
 	}
+	if actorType.Valid {
+		entry.ActorType = audit.ActorType(actorType.String)
+	}
 
 	if metadataJSON != nil {
 		if err := json.Unmarshal(metadataJSON, &entry.Metadata); err != nil {
@@ -438,6 +448,12 @@ func (r *AuditLogRepository) buildWhereClause(filter audit.LogFilter) (string, [
 		args = append(args, *filter.ActorID)
 	}
 
+	if filter.ActorType != "" {
+		argCount++
+		conditions = append(conditions, fmt.Sprintf("actor_type = $%d", argCount))
+		args = append(args, string(filter.ActorType))
+	}
+
 	if len(filter.EventTypes) > 0 {
 		argCount++
 		eventTypeStrs := make([]string, len(filter.EventTypes))
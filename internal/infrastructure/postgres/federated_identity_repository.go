@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/federation"
+)
+
+// FederatedIdentityRepository implements federation.IdentityRepository
+type FederatedIdentityRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFederatedIdentityRepository(db *pgxpool.Pool) *FederatedIdentityRepository {
+	return &FederatedIdentityRepository{
+		db: db,
+	}
+}
+
+func (r *FederatedIdentityRepository) Create(ctx context.Context, identity *federation.Identity) error {
+	query := `
+		INSERT INTO federated_identities (
+			id, user_id, provider_id, subject, email, linked_at, last_login_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7
+		)
+		ON CONFLICT (provider_id, subject) DO NOTHING
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		identity.ID,
+		identity.UserID,
+		identity.ProviderID,
+		identity.Subject,
+		identity.Email,
+		identity.LinkedAt,
+		identity.LastLoginAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create federated identity: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return federation.ErrIdentityAlreadyLinked
+	}
+
+	return nil
+}
+
+func (r *FederatedIdentityRepository) GetByProviderSubject(ctx context.Context, providerID, subject string) (*federation.Identity, error) {
+	query := `
+		SELECT id, user_id, provider_id, subject, COALESCE(email, ''), linked_at, last_login_at
+		FROM federated_identities
+		WHERE provider_id = $1 AND subject = $2
+	`
+
+	identity, err := r.scanIdentity(r.db.QueryRow(ctx, query, providerID, subject))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, federation.ErrIdentityNotFound
+		}
+		return nil, fmt.Errorf("failed to get federated identity: %w", err)
+	}
+
+	return identity, nil
+}
+
+func (r *FederatedIdentityRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*federation.Identity, error) {
+	query := `
+		SELECT id, user_id, provider_id, subject, COALESCE(email, ''), linked_at, last_login_at
+		FROM federated_identities
+		WHERE user_id = $1
+		ORDER BY linked_at
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list federated identities: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []*federation.Identity
+	for rows.Next() {
+		identity, err := r.scanIdentity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan federated identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
+
+func (r *FederatedIdentityRepository) Delete(ctx context.Context, userID, identityID uuid.UUID) error {
+	result, err := r.db.Exec(ctx,
+		"DELETE FROM federated_identities WHERE id = $1 AND user_id = $2",
+		identityID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete federated identity: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return federation.ErrIdentityNotFound
+	}
+
+	return nil
+}
+
+func (r *FederatedIdentityRepository) UpdateLastLogin(ctx context.Context, identityID uuid.UUID, loginTime time.Time) error {
+	_, err := r.db.Exec(ctx,
+		"UPDATE federated_identities SET last_login_at = $2 WHERE id = $1",
+		identityID, loginTime,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update federated identity: %w", err)
+	}
+
+	return nil
+}
+
+func (r *FederatedIdentityRepository) scanIdentity(row pgx.Row) (*federation.Identity, error) {
+	var identity federation.Identity
+	err := row.Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.ProviderID,
+		&identity.Subject,
+		&identity.Email,
+		&identity.LinkedAt,
+		&identity.LastLoginAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
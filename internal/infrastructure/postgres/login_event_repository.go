@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/risk"
+)
+
+// LoginEventRepository implements risk.LoginHistoryRepository
+type LoginEventRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewLoginEventRepository(db *pgxpool.Pool) *LoginEventRepository {
+	return &LoginEventRepository{
+		db: db,
+	}
+}
+
+func (r *LoginEventRepository) Create(ctx context.Context, event *risk.LoginEvent) error {
+	query := `
+		INSERT INTO login_events (
+			id, user_id, device_fingerprint, ip_address, user_agent,
+			country, city, latitude, longitude, asn, organization, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+		)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		event.ID,
+		event.UserID,
+		event.DeviceFingerprint,
+		event.IPAddress,
+		event.UserAgent,
+		event.Location.Country,
+		event.Location.City,
+		event.Location.Latitude,
+		event.Location.Longitude,
+		int64(event.Location.ASN),
+		event.Location.Organization,
+		event.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create login event: %w", err)
+	}
+
+	return nil
+}
+
+func (r *LoginEventRepository) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]*risk.LoginEvent, error) {
+	query := `
+		SELECT id, user_id, device_fingerprint, ip_address, user_agent,
+			country, city, latitude, longitude, asn, organization, created_at
+		FROM login_events
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*risk.LoginEvent
+	for rows.Next() {
+		var event risk.LoginEvent
+		var asn int64
+		if err := rows.Scan(
+			&event.ID,
+			&event.UserID,
+			&event.DeviceFingerprint,
+			&event.IPAddress,
+			&event.UserAgent,
+			&event.Location.Country,
+			&event.Location.City,
+			&event.Location.Latitude,
+			&event.Location.Longitude,
+			&asn,
+			&event.Location.Organization,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan login event: %w", err)
+		}
+		event.Location.ASN = uint32(asn)
+		events = append(events, &event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list login events: %w", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+)
+
+// MagicLinkRepository implements auth.MagicLinkRepository
+type MagicLinkRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewMagicLinkRepository(db *pgxpool.Pool) *MagicLinkRepository {
+	return &MagicLinkRepository{
+		db: db,
+	}
+}
+
+func (r *MagicLinkRepository) Create(ctx context.Context, link *auth.MagicLink) error {
+	query := `
+		INSERT INTO magic_links (id, user_id, token_hash, nonce_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		link.ID,
+		link.UserID,
+		link.TokenHash,
+		link.NonceHash,
+		link.ExpiresAt,
+		link.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create sign-in link: %w", err)
+	}
+
+	return nil
+}
+
+func (r *MagicLinkRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*auth.MagicLink, error) {
+	query := `
+		SELECT id, user_id, token_hash, nonce_hash, expires_at, created_at
+		FROM magic_links
+		WHERE token_hash = $1
+	`
+
+	var link auth.MagicLink
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(
+		&link.ID,
+		&link.UserID,
+		&link.TokenHash,
+		&link.NonceHash,
+		&link.ExpiresAt,
+		&link.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, auth.ErrInvalidMagicLink
+		}
+		return nil, fmt.Errorf("failed to get sign-in link: %w", err)
+	}
+
+	return &link, nil
+}
+
+func (r *MagicLinkRepository) Consume(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Exec(ctx, "DELETE FROM magic_links WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to consume sign-in link: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return auth.ErrInvalidMagicLink
+	}
+
+	return nil
+}
+
+func (r *MagicLinkRepository) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM magic_links WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete sign-in links: %w", err)
+	}
+
+	return nil
+}
+
+func (r *MagicLinkRepository) DeleteExpired(ctx context.Context) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM magic_links WHERE expires_at < NOW()")
+	if err != nil {
+		return fmt.Errorf("failed to delete expired sign-in links: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,324 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+)
+
+// MFARepository implements mfa.Repository
+type MFARepository struct {
+	db *pgxpool.Pool
+}
+
+func NewMFARepository(db *pgxpool.Pool) *MFARepository {
+	return &MFARepository{
+		db: db,
+	}
+}
+
+func (r *MFARepository) GetSettings(ctx context.Context, userID uuid.UUID) (*mfa.Settings, error) {
+	query := `
+		SELECT user_id, enabled, methods, primary_method, totp_secret, phone_number, email,
+			last_used_at, created_at, updated_at
+		FROM mfa_settings
+		WHERE user_id = $1
+	`
+
+	var settings mfa.Settings
+	var methods []string
+	var primaryMethod string
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&settings.UserID,
+		&settings.Enabled,
+		&methods,
+		&primaryMethod,
+		&settings.TOTPSecret,
+		&settings.PhoneNumber,
+		&settings.Email,
+		&settings.LastUsedAt,
+		&settings.CreatedAt,
+		&settings.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, mfa.ErrSettingsNotFound
+		}
+		return nil, fmt.Errorf("failed to get MFA settings: %w", err)
+	}
+
+	settings.PrimaryMethod = mfa.Method(primaryMethod)
+	settings.Methods = make([]mfa.Method, len(methods))
+	for i, m := range methods {
+		settings.Methods[i] = mfa.Method(m)
+	}
+
+	return &settings, nil
+}
+
+func (r *MFARepository) SaveSettings(ctx context.Context, settings *mfa.Settings) error {
+	query := `
+		INSERT INTO mfa_settings (
+			user_id, enabled, methods, primary_method, totp_secret, phone_number, email,
+			last_used_at, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (user_id) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			methods = EXCLUDED.methods,
+			primary_method = EXCLUDED.primary_method,
+			totp_secret = EXCLUDED.totp_secret,
+			phone_number = EXCLUDED.phone_number,
+			email = EXCLUDED.email,
+			last_used_at = EXCLUDED.last_used_at,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	methods := make([]string, len(settings.Methods))
+	for i, m := range settings.Methods {
+		methods[i] = string(m)
+	}
+
+	_, err := r.db.Exec(ctx, query,
+		settings.UserID,
+		settings.Enabled,
+		methods,
+		string(settings.PrimaryMethod),
+		settings.TOTPSecret,
+		settings.PhoneNumber,
+		settings.Email,
+		settings.LastUsedAt,
+		settings.CreatedAt,
+		settings.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save MFA settings: %w", err)
+	}
+
+	return nil
+}
+
+func (r *MFARepository) DeleteSettings(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM mfa_settings WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete MFA settings: %w", err)
+	}
+
+	return nil
+}
+
+func (r *MFARepository) SaveChallenge(ctx context.Context, challenge *mfa.Challenge) error {
+	query := `
+		INSERT INTO mfa_challenges (id, user_id, method, code, attempts, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		challenge.ID,
+		challenge.UserID,
+		string(challenge.Method),
+		challenge.Code,
+		challenge.Attempts,
+		challenge.ExpiresAt,
+		challenge.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save MFA challenge: %w", err)
+	}
+
+	return nil
+}
+
+func (r *MFARepository) GetChallenge(ctx context.Context, id uuid.UUID) (*mfa.Challenge, error) {
+	query := `
+		SELECT id, user_id, method, code, attempts, expires_at, created_at
+		FROM mfa_challenges
+		WHERE id = $1
+	`
+
+	var challenge mfa.Challenge
+	var method string
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&challenge.ID,
+		&challenge.UserID,
+		&method,
+		&challenge.Code,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&challenge.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, mfa.ErrChallengeNotFound
+		}
+		return nil, fmt.Errorf("failed to get MFA challenge: %w", err)
+	}
+	challenge.Method = mfa.Method(method)
+
+	return &challenge, nil
+}
+
+func (r *MFARepository) DeleteChallenge(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM mfa_challenges WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete MFA challenge: %w", err)
+	}
+
+	return nil
+}
+
+func (r *MFARepository) IncrementChallengeAttempts(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Exec(ctx, "UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to update MFA challenge: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return mfa.ErrChallengeNotFound
+	}
+
+	return nil
+}
+
+func (r *MFARepository) SaveBackupCode(ctx context.Context, userID uuid.UUID, code *mfa.BackupCode) error {
+	query := `
+		INSERT INTO mfa_backup_codes (user_id, code, used, used_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := r.db.Exec(ctx, query, userID, code.Code, code.Used, code.UsedAt, code.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save backup code: %w", err)
+	}
+
+	return nil
+}
+
+func (r *MFARepository) GetBackupCodes(ctx context.Context, userID uuid.UUID) ([]*mfa.BackupCode, error) {
+	query := `
+		SELECT code, used, used_at, created_at
+		FROM mfa_backup_codes
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup codes: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []*mfa.BackupCode
+	for rows.Next() {
+		var code mfa.BackupCode
+		if err := rows.Scan(&code.Code, &code.Used, &code.UsedAt, &code.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan backup code: %w", err)
+		}
+		codes = append(codes, &code)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list backup codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// MarkBackupCodeUsed spends an unused code; a code that was already spent is reported as such
+func (r *MFARepository) MarkBackupCodeUsed(ctx context.Context, userID uuid.UUID, code string) error {
+	query := `
+		UPDATE mfa_backup_codes
+		SET used = true, used_at = NOW()
+		WHERE user_id = $1 AND code = $2 AND NOT used
+	`
+
+	result, err := r.db.Exec(ctx, query, userID, code)
+	if err != nil {
+		return fmt.Errorf("failed to mark backup code used: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return mfa.ErrBackupCodeAlreadyUsed
+	}
+
+	return nil
+}
+
+func (r *MFARepository) DeleteBackupCodes(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM mfa_backup_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete backup codes: %w", err)
+	}
+
+	return nil
+}
+
+func (r *MFARepository) LogAudit(ctx context.Context, log *mfa.AuditLog) error {
+	query := `
+		INSERT INTO mfa_audit_logs (id, user_id, action, method, success, ip_address, user_agent, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		log.ID,
+		log.UserID,
+		log.Action,
+		string(log.Method),
+		log.Success,
+		log.IP,
+		log.UserAgent,
+		log.Details,
+		log.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to log MFA audit event: %w", err)
+	}
+
+	return nil
+}
+
+func (r *MFARepository) GetAuditLogs(ctx context.Context, userID uuid.UUID, limit int) ([]*mfa.AuditLog, error) {
+	query := `
+		SELECT id, user_id, action, method, success, ip_address, user_agent, details, created_at
+		FROM mfa_audit_logs
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MFA audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*mfa.AuditLog
+	for rows.Next() {
+		var log mfa.AuditLog
+		var method string
+		if err := rows.Scan(
+			&log.ID,
+			&log.UserID,
+			&log.Action,
+			&method,
+			&log.Success,
+			&log.IP,
+			&log.UserAgent,
+			&log.Details,
+			&log.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan MFA audit log: %w", err)
+		}
+		log.Method = mfa.Method(method)
+		logs = append(logs, &log)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list MFA audit logs: %w", err)
+	}
+
+	return logs, nil
+}
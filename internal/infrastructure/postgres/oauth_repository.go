@@ -0,0 +1,357 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/oauth"
+)
+
+// OAuthClientRepository implements oauth.ClientRepository
+type OAuthClientRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOAuthClientRepository(db *pgxpool.Pool) *OAuthClientRepository {
+	return &OAuthClientRepository{
+		db: db,
+	}
+}
+
+func (r *OAuthClientRepository) Create(ctx context.Context, client *oauth.Client) error {
+	query := `
+		INSERT INTO oauth_clients (
+			id, name, secret_hash, redirect_uris, allowed_scopes, public, first_party, can_introspect,
+			created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+		)
+	`
+
+	var secretHash interface{}
+	if client.SecretHash != "" {
+		secretHash = client.SecretHash
+	}
+
+	_, err := r.db.Exec(ctx, query,
+		client.ID,
+		client.Name,
+		secretHash,
+		client.RedirectURIs,
+		client.AllowedScopes,
+		client.Public,
+		client.FirstParty,
+		client.CanIntrospect,
+		client.CreatedAt,
+		client.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create oauth client: %w", err)
+	}
+
+	return nil
+}
+
+func (r *OAuthClientRepository) GetByID(ctx context.Context, clientID string) (*oauth.Client, error) {
+	query := `
+		SELECT id, name, COALESCE(secret_hash, ''), redirect_uris, allowed_scopes,
+			   public, first_party, can_introspect, created_at, updated_at
+		FROM oauth_clients
+		WHERE id = $1
+	`
+
+	client, err := r.scanClient(r.db.QueryRow(ctx, query, clientID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, oauth.ErrClientNotFound
+		}
+		return nil, fmt.Errorf("failed to get oauth client: %w", err)
+	}
+
+	return client, nil
+}
+
+func (r *OAuthClientRepository) List(ctx context.Context) ([]*oauth.Client, error) {
+	query := `
+		SELECT id, name, COALESCE(secret_hash, ''), redirect_uris, allowed_scopes,
+			   public, first_party, can_introspect, created_at, updated_at
+		FROM oauth_clients
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list oauth clients: %w", err)
+	}
+	defer rows.Close()
+
+	var clients []*oauth.Client
+	for rows.Next() {
+		client, err := r.scanClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan oauth client: %w", err)
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, rows.Err()
+}
+
+func (r *OAuthClientRepository) Delete(ctx context.Context, clientID string) error {
+	result, err := r.db.Exec(ctx, "DELETE FROM oauth_clients WHERE id = $1", clientID)
+	if err != nil {
+		return fmt.Errorf("failed to delete oauth client: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return oauth.ErrClientNotFound
+	}
+
+	return nil
+}
+
+func (r *OAuthClientRepository) scanClient(row pgx.Row) (*oauth.Client, error) {
+	var client oauth.Client
+	err := row.Scan(
+		&client.ID,
+		&client.Name,
+		&client.SecretHash,
+		&client.RedirectURIs,
+		&client.AllowedScopes,
+		&client.Public,
+		&client.FirstParty,
+		&client.CanIntrospect,
+		&client.CreatedAt,
+		&client.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// OAuthCodeRepository implements oauth.AuthorizationCodeRepository
+type OAuthCodeRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOAuthCodeRepository(db *pgxpool.Pool) *OAuthCodeRepository {
+	return &OAuthCodeRepository{
+		db: db,
+	}
+}
+
+func (r *OAuthCodeRepository) Create(ctx context.Context, code *oauth.AuthorizationCode) error {
+	query := `
+		INSERT INTO oauth_authorization_codes (
+			code_hash, client_id, user_id, redirect_uri, scopes, nonce, code_challenge,
+			code_challenge_method, auth_time, expires_at, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		code.Scopes,
+		code.Nonce,
+		code.CodeChallenge,
+		code.CodeChallengeMethod,
+		code.AuthTime,
+		code.ExpiresAt,
+		code.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create authorization code: %w", err)
+	}
+
+	return nil
+}
+
+func (r *OAuthCodeRepository) Consume(ctx context.Context, codeHash string) (*oauth.AuthorizationCode, error) {
+	// The conditional update makes redemption single-use even under concurrent requests
+	query := `
+		UPDATE oauth_authorization_codes
+		SET used_at = NOW()
+		WHERE code_hash = $1 AND used_at IS NULL
+		RETURNING code_hash, client_id, user_id, redirect_uri, scopes, COALESCE(nonce, ''),
+			code_challenge, code_challenge_method, auth_time, COALESCE(family_id, ''),
+			expires_at, used_at, created_at
+	`
+
+	code, err := r.scanCode(r.db.QueryRow(ctx, query, codeHash))
+	if err == nil {
+		return code, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+
+	// Distinguish a replayed code from an unknown one
+	query = `
+		SELECT code_hash, client_id, user_id, redirect_uri, scopes, COALESCE(nonce, ''),
+			code_challenge, code_challenge_method, auth_time, COALESCE(family_id, ''),
+			expires_at, used_at, created_at
+		FROM oauth_authorization_codes
+		WHERE code_hash = $1
+	`
+
+	code, err = r.scanCode(r.db.QueryRow(ctx, query, codeHash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, oauth.ErrAuthorizationCodeNotFound
+		}
+		return nil, fmt.Errorf("failed to get authorization code: %w", err)
+	}
+
+	return code, oauth.ErrAuthorizationCodeUsed
+}
+
+func (r *OAuthCodeRepository) SetFamily(ctx context.Context, codeHash, familyID string) error {
+	query := `UPDATE oauth_authorization_codes SET family_id = $2 WHERE code_hash = $1`
+
+	result, err := r.db.Exec(ctx, query, codeHash, familyID)
+	if err != nil {
+		return fmt.Errorf("failed to set authorization code family: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return oauth.ErrAuthorizationCodeNotFound
+	}
+
+	return nil
+}
+
+func (r *OAuthCodeRepository) scanCode(row pgx.Row) (*oauth.AuthorizationCode, error) {
+	var code oauth.AuthorizationCode
+	err := row.Scan(
+		&code.CodeHash,
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		&code.Scopes,
+		&code.Nonce,
+		&code.CodeChallenge,
+		&code.CodeChallengeMethod,
+		&code.AuthTime,
+		&code.FamilyID,
+		&code.ExpiresAt,
+		&code.UsedAt,
+		&code.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// OAuthConsentRepository implements oauth.ConsentRepository
+type OAuthConsentRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOAuthConsentRepository(db *pgxpool.Pool) *OAuthConsentRepository {
+	return &OAuthConsentRepository{
+		db: db,
+	}
+}
+
+func (r *OAuthConsentRepository) Get(ctx context.Context, userID uuid.UUID, clientID string) (*oauth.Consent, error) {
+	query := `
+		SELECT user_id, client_id, scopes, granted_at, updated_at
+		FROM oauth_consents
+		WHERE user_id = $1 AND client_id = $2
+	`
+
+	consent, err := r.scanConsent(r.db.QueryRow(ctx, query, userID, clientID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, oauth.ErrConsentNotFound
+		}
+		return nil, fmt.Errorf("failed to get consent: %w", err)
+	}
+
+	return consent, nil
+}
+
+func (r *OAuthConsentRepository) Save(ctx context.Context, consent *oauth.Consent) error {
+	query := `
+		INSERT INTO oauth_consents (user_id, client_id, scopes, granted_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, client_id)
+		DO UPDATE SET scopes = EXCLUDED.scopes, updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		consent.UserID,
+		consent.ClientID,
+		consent.Scopes,
+		consent.GrantedAt,
+		consent.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save consent: %w", err)
+	}
+
+	return nil
+}
+
+func (r *OAuthConsentRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*oauth.Consent, error) {
+	query := `
+		SELECT user_id, client_id, scopes, granted_at, updated_at
+		FROM oauth_consents
+		WHERE user_id = $1
+		ORDER BY granted_at
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consents: %w", err)
+	}
+	defer rows.Close()
+
+	var consents []*oauth.Consent
+	for rows.Next() {
+		consent, err := r.scanConsent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan consent: %w", err)
+		}
+		consents = append(consents, consent)
+	}
+
+	return consents, rows.Err()
+}
+
+func (r *OAuthConsentRepository) Delete(ctx context.Context, userID uuid.UUID, clientID string) error {
+	result, err := r.db.Exec(ctx, "DELETE FROM oauth_consents WHERE user_id = $1 AND client_id = $2", userID, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to delete consent: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return oauth.ErrConsentNotFound
+	}
+
+	return nil
+}
+
+func (r *OAuthConsentRepository) scanConsent(row pgx.Row) (*oauth.Consent, error) {
+	var consent oauth.Consent
+	err := row.Scan(
+		&consent.UserID,
+		&consent.ClientID,
+		&consent.Scopes,
+		&consent.GrantedAt,
+		&consent.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
@@ -0,0 +1,570 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/organization"
+)
+
+// OrganizationRepository implements organization.Repository
+type OrganizationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOrganizationRepository(db *pgxpool.Pool) *OrganizationRepository {
+	return &OrganizationRepository{
+		db: db,
+	}
+}
+
+func (r *OrganizationRepository) Create(ctx context.Context, org *organization.Organization) error {
+	query := `
+		INSERT INTO organizations (id, name, slug, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (slug) DO NOTHING
+	`
+
+	result, err := r.db.Exec(ctx, query, org.ID, org.Name, org.Slug, org.CreatedBy, org.CreatedAt, org.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return organization.ErrSlugTaken
+	}
+
+	return nil
+}
+
+func (r *OrganizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*organization.Organization, error) {
+	query := `
+		SELECT id, name, slug, created_by, created_at, updated_at
+		FROM organizations
+		WHERE id = $1
+	`
+
+	org := &organization.Organization{}
+	var createdBy *uuid.UUID
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&org.ID, &org.Name, &org.Slug, &createdBy, &org.CreatedAt, &org.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, organization.ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	// The creator is cleared if their account is deleted
+	if createdBy != nil {
+		org.CreatedBy = *createdBy
+	}
+
+	return org, nil
+}
+
+func (r *OrganizationRepository) Update(ctx context.Context, org *organization.Organization) error {
+	query := `
+		UPDATE organizations
+		SET name = $2, slug = $3, updated_at = $4
+		WHERE id = $1
+	`
+
+	result, err := r.db.Exec(ctx, query, org.ID, org.Name, org.Slug, org.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return organization.ErrSlugTaken
+		}
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return organization.ErrOrganizationNotFound
+	}
+
+	return nil
+}
+
+func (r *OrganizationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Exec(ctx, "DELETE FROM organizations WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return organization.ErrOrganizationNotFound
+	}
+
+	return nil
+}
+
+// OrganizationMembershipRepository implements organization.MembershipRepository
+type OrganizationMembershipRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOrganizationMembershipRepository(db *pgxpool.Pool) *OrganizationMembershipRepository {
+	return &OrganizationMembershipRepository{
+		db: db,
+	}
+}
+
+func (r *OrganizationMembershipRepository) Add(ctx context.Context, membership *organization.Membership) error {
+	return addMembership(ctx, r.db, membership)
+}
+
+func (r *OrganizationMembershipRepository) AddWithinSeatLimit(
+	ctx context.Context,
+	membership *organization.Membership,
+	seatLimit int,
+	countInvitations bool,
+) error {
+	if seatLimit <= 0 {
+		return r.Add(ctx, membership)
+	}
+
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if err := reserveSeat(ctx, tx, membership.OrganizationID, seatLimit, countInvitations, membership.CreatedAt); err != nil {
+			return err
+		}
+		return addMembership(ctx, tx, membership)
+	})
+}
+
+func (r *OrganizationMembershipRepository) Get(ctx context.Context, organizationID, userID uuid.UUID) (*organization.Membership, error) {
+	query := `
+		SELECT organization_id, user_id, role, added_by, created_at, updated_at
+		FROM organization_memberships
+		WHERE organization_id = $1 AND user_id = $2
+	`
+
+	membership, err := scanMembership(r.db.QueryRow(ctx, query, organizationID, userID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, organization.ErrNotMember
+		}
+		return nil, fmt.Errorf("failed to get organization member: %w", err)
+	}
+
+	return membership, nil
+}
+
+func (r *OrganizationMembershipRepository) ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*organization.Membership, error) {
+	query := `
+		SELECT organization_id, user_id, role, added_by, created_at, updated_at
+		FROM organization_memberships
+		WHERE organization_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization members: %w", err)
+	}
+	defer rows.Close()
+
+	var memberships []*organization.Membership
+	for rows.Next() {
+		membership, err := scanMembership(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization member: %w", err)
+		}
+		memberships = append(memberships, membership)
+	}
+
+	return memberships, rows.Err()
+}
+
+func (r *OrganizationMembershipRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*organization.Membership, error) {
+	query := `
+		SELECT m.organization_id, m.user_id, m.role, m.added_by, m.created_at, m.updated_at,
+			o.name, o.slug, o.created_by, o.created_at, o.updated_at
+		FROM organization_memberships m
+		JOIN organizations o ON o.id = m.organization_id
+		WHERE m.user_id = $1
+		ORDER BY o.name
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	defer rows.Close()
+
+	var memberships []*organization.Membership
+	for rows.Next() {
+		membership := &organization.Membership{}
+		org := &organization.Organization{}
+		var addedBy, createdBy *uuid.UUID
+		if err := rows.Scan(
+			&membership.OrganizationID, &membership.UserID, &membership.Role, &addedBy,
+			&membership.CreatedAt, &membership.UpdatedAt,
+			&org.Name, &org.Slug, &createdBy, &org.CreatedAt, &org.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+
+		if addedBy != nil {
+			membership.AddedBy = *addedBy
+		}
+		if createdBy != nil {
+			org.CreatedBy = *createdBy
+		}
+		org.ID = membership.OrganizationID
+		membership.Organization = org
+		memberships = append(memberships, membership)
+	}
+
+	return memberships, rows.Err()
+}
+
+func (r *OrganizationMembershipRepository) UpdateRole(ctx context.Context, organizationID, userID uuid.UUID, role organization.Role) error {
+	query := `
+		UPDATE organization_memberships
+		SET role = $3, updated_at = NOW()
+		WHERE organization_id = $1 AND user_id = $2
+	`
+
+	result, err := r.db.Exec(ctx, query, organizationID, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to update organization member: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return organization.ErrNotMember
+	}
+
+	return nil
+}
+
+func (r *OrganizationMembershipRepository) Remove(ctx context.Context, organizationID, userID uuid.UUID) error {
+	result, err := r.db.Exec(ctx,
+		"DELETE FROM organization_memberships WHERE organization_id = $1 AND user_id = $2",
+		organizationID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove organization member: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return organization.ErrNotMember
+	}
+
+	return nil
+}
+
+func (r *OrganizationMembershipRepository) CountByRole(ctx context.Context, organizationID uuid.UUID, role organization.Role) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx,
+		"SELECT COUNT(*) FROM organization_memberships WHERE organization_id = $1 AND role = $2",
+		organizationID, role,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count organization members: %w", err)
+	}
+
+	return count, nil
+}
+
+// scanMembership scans a membership row; the member who added it is cleared if
+// their account is deleted
+func scanMembership(row pgx.Row) (*organization.Membership, error) {
+	membership := &organization.Membership{}
+	var addedBy *uuid.UUID
+	if err := row.Scan(
+		&membership.OrganizationID, &membership.UserID, &membership.Role, &addedBy,
+		&membership.CreatedAt, &membership.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if addedBy != nil {
+		membership.AddedBy = *addedBy
+	}
+
+	return membership, nil
+}
+
+// OrganizationInvitationRepository implements organization.InvitationRepository
+type OrganizationInvitationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOrganizationInvitationRepository(db *pgxpool.Pool) *OrganizationInvitationRepository {
+	return &OrganizationInvitationRepository{
+		db: db,
+	}
+}
+
+const invitationColumns = `id, organization_id, email, role, status, invited_by, expires_at,
+	accepted_by, accepted_at, revoked_at, created_at, updated_at`
+
+func (r *OrganizationInvitationRepository) Create(ctx context.Context, invitation *organization.Invitation) error {
+	return createInvitation(ctx, r.db, invitation)
+}
+
+func (r *OrganizationInvitationRepository) CreateWithinSeatLimit(ctx context.Context, invitation *organization.Invitation, seatLimit int) error {
+	if seatLimit <= 0 {
+		return r.Create(ctx, invitation)
+	}
+
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if err := reserveSeat(ctx, tx, invitation.OrganizationID, seatLimit, true, invitation.CreatedAt); err != nil {
+			return err
+		}
+		return createInvitation(ctx, tx, invitation)
+	})
+}
+
+func (r *OrganizationInvitationRepository) GetByID(ctx context.Context, id uuid.UUID) (*organization.Invitation, error) {
+	query := `SELECT ` + invitationColumns + ` FROM organization_invitations WHERE id = $1`
+
+	invitation, err := scanInvitation(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, organization.ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+func (r *OrganizationInvitationRepository) GetPendingByEmail(ctx context.Context, organizationID uuid.UUID, email string) (*organization.Invitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM organization_invitations
+		WHERE organization_id = $1 AND LOWER(email) = LOWER($2) AND status = 'pending'
+	`
+
+	invitation, err := scanInvitation(r.db.QueryRow(ctx, query, organizationID, email))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, organization.ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+func (r *OrganizationInvitationRepository) ListPending(ctx context.Context, organizationID uuid.UUID) ([]*organization.Invitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM organization_invitations
+		WHERE organization_id = $1 AND status = 'pending'
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	defer rows.Close()
+
+	var invitations []*organization.Invitation
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+func (r *OrganizationInvitationRepository) CountOpen(ctx context.Context, organizationID uuid.UUID, now time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx,
+		"SELECT COUNT(*) FROM organization_invitations WHERE organization_id = $1 AND status = 'pending' AND expires_at > $2",
+		organizationID, now,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count invitations: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *OrganizationInvitationRepository) Update(ctx context.Context, invitation *organization.Invitation) error {
+	return updateInvitation(ctx, r.db, invitation)
+}
+
+func (r *OrganizationInvitationRepository) UpdateWithinSeatLimit(ctx context.Context, invitation *organization.Invitation, seatLimit int) error {
+	if seatLimit <= 0 {
+		return r.Update(ctx, invitation)
+	}
+
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if err := reserveSeat(ctx, tx, invitation.OrganizationID, seatLimit, true, invitation.UpdatedAt); err != nil {
+			return err
+		}
+		return updateInvitation(ctx, tx, invitation)
+	})
+}
+
+func (r *OrganizationInvitationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Exec(ctx, "DELETE FROM organization_invitations WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete invitation: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return organization.ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+// scanInvitation scans an invitation row; the inviter is cleared if their
+// account is deleted
+func scanInvitation(row pgx.Row) (*organization.Invitation, error) {
+	invitation := &organization.Invitation{}
+	var invitedBy *uuid.UUID
+	if err := row.Scan(
+		&invitation.ID, &invitation.OrganizationID, &invitation.Email, &invitation.Role, &invitation.Status,
+		&invitedBy, &invitation.ExpiresAt, &invitation.AcceptedBy, &invitation.AcceptedAt, &invitation.RevokedAt,
+		&invitation.CreatedAt, &invitation.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if invitedBy != nil {
+		invitation.InvitedBy = *invitedBy
+	}
+
+	return invitation, nil
+}
+
+// execer runs statements on the pool or within a transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+func addMembership(ctx context.Context, db execer, membership *organization.Membership) error {
+	query := `
+		INSERT INTO organization_memberships (organization_id, user_id, role, added_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (organization_id, user_id) DO NOTHING
+	`
+
+	result, err := db.Exec(ctx, query,
+		membership.OrganizationID,
+		membership.UserID,
+		membership.Role,
+		membership.AddedBy,
+		membership.CreatedAt,
+		membership.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add organization member: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return organization.ErrAlreadyMember
+	}
+
+	return nil
+}
+
+func createInvitation(ctx context.Context, db execer, invitation *organization.Invitation) error {
+	query := `
+		INSERT INTO organization_invitations (` + invitationColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+
+	_, err := db.Exec(ctx, query,
+		invitation.ID,
+		invitation.OrganizationID,
+		invitation.Email,
+		invitation.Role,
+		invitation.Status,
+		invitation.InvitedBy,
+		invitation.ExpiresAt,
+		invitation.AcceptedBy,
+		invitation.AcceptedAt,
+		invitation.RevokedAt,
+		invitation.CreatedAt,
+		invitation.UpdatedAt,
+	)
+	if err != nil {
+		// Only one invitation per address may be pending
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return organization.ErrInvitationPending
+		}
+		return fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	return nil
+}
+
+func updateInvitation(ctx context.Context, db execer, invitation *organization.Invitation) error {
+	query := `
+		UPDATE organization_invitations
+		SET role = $2, status = $3, expires_at = $4, accepted_by = $5, accepted_at = $6,
+			revoked_at = $7, updated_at = $8
+		WHERE id = $1
+	`
+
+	result, err := db.Exec(ctx, query,
+		invitation.ID,
+		invitation.Role,
+		invitation.Status,
+		invitation.ExpiresAt,
+		invitation.AcceptedBy,
+		invitation.AcceptedAt,
+		invitation.RevokedAt,
+		invitation.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update invitation: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return organization.ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+// reserveSeat locks the organization row, so seat-limited writes to one organization
+// happen one at a time, and returns ErrSeatLimitReached if its members, and its open
+// invitations when countInvitations is set, already take seatLimit seats
+func reserveSeat(ctx context.Context, tx pgx.Tx, organizationID uuid.UUID, seatLimit int, countInvitations bool, now time.Time) error {
+	var locked uuid.UUID
+	err := tx.QueryRow(ctx, "SELECT id FROM organizations WHERE id = $1 FOR UPDATE", organizationID).Scan(&locked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return organization.ErrOrganizationNotFound
+		}
+		return fmt.Errorf("failed to lock organization: %w", err)
+	}
+
+	var seats int
+	err = tx.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM organization_memberships WHERE organization_id = $1)
+			+ CASE WHEN $2 THEN (
+				SELECT COUNT(*) FROM organization_invitations
+				WHERE organization_id = $1 AND status = 'pending' AND expires_at > $3
+			) ELSE 0 END
+	`, organizationID, countInvitations, now).Scan(&seats)
+	if err != nil {
+		return fmt.Errorf("failed to count organization seats: %w", err)
+	}
+
+	if seats >= seatLimit {
+		return organization.ErrSeatLimitReached
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+)
+
+// PasswordHistoryRepository implements auth.PasswordHistoryRepository
+type PasswordHistoryRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPasswordHistoryRepository(db *pgxpool.Pool) *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{
+		db: db,
+	}
+}
+
+func (r *PasswordHistoryRepository) Add(ctx context.Context, entry *auth.PasswordHistoryEntry) error {
+	query := `
+		INSERT INTO password_history (id, user_id, password_hash, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := r.db.Exec(ctx, query, entry.ID, entry.UserID, entry.PasswordHash, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add password history: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PasswordHistoryRepository) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]*auth.PasswordHistoryEntry, error) {
+	query := `
+		SELECT id, user_id, password_hash, created_at
+		FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list password history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*auth.PasswordHistoryEntry
+	for rows.Next() {
+		entry := &auth.PasswordHistoryEntry{}
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.PasswordHash, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan password history: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (r *PasswordHistoryRepository) Prune(ctx context.Context, userID uuid.UUID, keep int) error {
+	query := `
+		DELETE FROM password_history
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history
+			WHERE user_id = $1
+			ORDER BY created_at DESC
+			LIMIT $2
+		)
+	`
+
+	if _, err := r.db.Exec(ctx, query, userID, keep); err != nil {
+		return fmt.Errorf("failed to prune password history: %w", err)
+	}
+
+	return nil
+}
+
+// PasswordExpiryRepository implements auth.PasswordExpiryRepository
+type PasswordExpiryRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPasswordExpiryRepository(db *pgxpool.Pool) *PasswordExpiryRepository {
+	return &PasswordExpiryRepository{
+		db: db,
+	}
+}
+
+func (r *PasswordExpiryRepository) ClaimUnwarned(ctx context.Context, from, to time.Time, limit int) ([]*auth.PasswordExpiryNotice, error) {
+	// Accounts that never changed their password have had it since they were created.
+	// An instance that loses the race for an account finds the winner's row on conflict,
+	// naming the same password, and leaves it out of RETURNING.
+	query := `
+		WITH due AS (
+			SELECT u.id, u.email, u.username, COALESCE(u.password_changed_at, u.created_at) AS set_at
+			FROM users u
+			LEFT JOIN password_expiry_warnings w
+				ON w.user_id = u.id AND w.password_set_at = COALESCE(u.password_changed_at, u.created_at)
+			WHERE u.deleted_at IS NULL
+				AND u.is_active = true
+				AND w.user_id IS NULL
+				AND COALESCE(u.password_changed_at, u.created_at) BETWEEN $1 AND $2
+			ORDER BY set_at
+			LIMIT $3
+		), claimed AS (
+			INSERT INTO password_expiry_warnings (user_id, password_set_at, warned_at)
+			SELECT id, set_at, NOW() FROM due
+			ON CONFLICT (user_id) DO UPDATE
+			SET password_set_at = EXCLUDED.password_set_at, warned_at = EXCLUDED.warned_at
+			WHERE password_expiry_warnings.password_set_at <> EXCLUDED.password_set_at
+			RETURNING user_id
+		)
+		SELECT due.id, due.email, due.username, due.set_at
+		FROM due
+		JOIN claimed ON claimed.user_id = due.id
+		ORDER BY due.set_at
+	`
+
+	rows, err := r.db.Query(ctx, query, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expiring passwords: %w", err)
+	}
+	defer rows.Close()
+
+	var notices []*auth.PasswordExpiryNotice
+	for rows.Next() {
+		notice := &auth.PasswordExpiryNotice{}
+		if err := rows.Scan(&notice.UserID, &notice.Email, &notice.Username, &notice.SetAt); err != nil {
+			return nil, fmt.Errorf("failed to scan expiring password: %w", err)
+		}
+		notices = append(notices, notice)
+	}
+
+	return notices, rows.Err()
+}
+
+func (r *PasswordExpiryRepository) ReleaseWarning(ctx context.Context, userID uuid.UUID, setAt time.Time) error {
+	query := `DELETE FROM password_expiry_warnings WHERE user_id = $1 AND password_set_at = $2`
+
+	if _, err := r.db.Exec(ctx, query, userID, setAt); err != nil {
+		return fmt.Errorf("failed to release password expiry warning: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/saml"
+)
+
+// SAMLConnectionRepository implements saml.ConnectionRepository
+type SAMLConnectionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSAMLConnectionRepository(db *pgxpool.Pool) *SAMLConnectionRepository {
+	return &SAMLConnectionRepository{
+		db: db,
+	}
+}
+
+func (r *SAMLConnectionRepository) Create(ctx context.Context, connection *saml.Connection) error {
+	attributeMapping, roleMapping, err := encodeMappings(connection)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO saml_connections (
+			id, organization_id, idp_entity_id, sso_url, certificates, metadata,
+			attribute_mapping, role_mapping, default_roles, allow_signup, allow_idp_initiated,
+			created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+		)
+	`
+
+	_, err = r.db.Exec(ctx, query,
+		connection.ID,
+		connection.OrganizationID,
+		connection.IdPEntityID,
+		connection.SSOURL,
+		connection.Certificates,
+		connection.Metadata,
+		attributeMapping,
+		roleMapping,
+		nonNilStrings(connection.DefaultRoles),
+		connection.AllowSignup,
+		connection.AllowIdPInitiated,
+		connection.CreatedAt,
+		connection.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create SAML connection: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SAMLConnectionRepository) GetByOrganization(ctx context.Context, organizationID uuid.UUID) (*saml.Connection, error) {
+	query := `
+		SELECT id, organization_id, idp_entity_id, sso_url, certificates, metadata,
+			attribute_mapping, role_mapping, default_roles, allow_signup, allow_idp_initiated,
+			created_at, updated_at
+		FROM saml_connections
+		WHERE organization_id = $1
+	`
+
+	var connection saml.Connection
+	var attributeMapping, roleMapping []byte
+	err := r.db.QueryRow(ctx, query, organizationID).Scan(
+		&connection.ID,
+		&connection.OrganizationID,
+		&connection.IdPEntityID,
+		&connection.SSOURL,
+		&connection.Certificates,
+		&connection.Metadata,
+		&attributeMapping,
+		&roleMapping,
+		&connection.DefaultRoles,
+		&connection.AllowSignup,
+		&connection.AllowIdPInitiated,
+		&connection.CreatedAt,
+		&connection.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, saml.ErrConnectionNotFound
+		}
+		return nil, fmt.Errorf("failed to get SAML connection: %w", err)
+	}
+
+	if err := json.Unmarshal(attributeMapping, &connection.AttributeMapping); err != nil {
+		return nil, fmt.Errorf("failed to decode attribute mapping: %w", err)
+	}
+	if err := json.Unmarshal(roleMapping, &connection.RoleMapping); err != nil {
+		return nil, fmt.Errorf("failed to decode role mapping: %w", err)
+	}
+
+	return &connection, nil
+}
+
+func (r *SAMLConnectionRepository) Update(ctx context.Context, connection *saml.Connection) error {
+	attributeMapping, roleMapping, err := encodeMappings(connection)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE saml_connections
+		SET idp_entity_id = $2, sso_url = $3, certificates = $4, metadata = $5,
+			attribute_mapping = $6, role_mapping = $7, default_roles = $8,
+			allow_signup = $9, allow_idp_initiated = $10, updated_at = $11
+		WHERE organization_id = $1
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		connection.OrganizationID,
+		connection.IdPEntityID,
+		connection.SSOURL,
+		connection.Certificates,
+		connection.Metadata,
+		attributeMapping,
+		roleMapping,
+		nonNilStrings(connection.DefaultRoles),
+		connection.AllowSignup,
+		connection.AllowIdPInitiated,
+		connection.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update SAML connection: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return saml.ErrConnectionNotFound
+	}
+
+	return nil
+}
+
+func (r *SAMLConnectionRepository) Delete(ctx context.Context, organizationID uuid.UUID) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM saml_connections WHERE organization_id = $1`, organizationID)
+	if err != nil {
+		return fmt.Errorf("failed to delete SAML connection: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return saml.ErrConnectionNotFound
+	}
+
+	return nil
+}
+
+func encodeMappings(connection *saml.Connection) ([]byte, []byte, error) {
+	attributeMapping, err := json.Marshal(connection.AttributeMapping)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode attribute mapping: %w", err)
+	}
+
+	roleMapping := connection.RoleMapping
+	if roleMapping == nil {
+		roleMapping = map[string][]string{}
+	}
+	roleMappingJSON, err := json.Marshal(roleMapping)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode role mapping: %w", err)
+	}
+
+	return attributeMapping, roleMappingJSON, nil
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// SAMLAssertionCache implements saml.AssertionCache
+type SAMLAssertionCache struct {
+	db *pgxpool.Pool
+}
+
+func NewSAMLAssertionCache(db *pgxpool.Pool) *SAMLAssertionCache {
+	return &SAMLAssertionCache{
+		db: db,
+	}
+}
+
+func (c *SAMLAssertionCache) Use(ctx context.Context, issuer, assertionID string, expiresAt time.Time) error {
+	// Expired assertions are rejected by their conditions, so they no longer need remembering
+	if _, err := c.db.Exec(ctx, `DELETE FROM saml_used_assertions WHERE expires_at < $1`, time.Now()); err != nil {
+		return fmt.Errorf("failed to clean up used assertions: %w", err)
+	}
+
+	query := `
+		INSERT INTO saml_used_assertions (issuer, assertion_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (issuer, assertion_id) DO NOTHING
+	`
+
+	result, err := c.db.Exec(ctx, query, issuer, assertionID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to record used assertion: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return saml.ErrAssertionReplayed
+	}
+
+	return nil
+}
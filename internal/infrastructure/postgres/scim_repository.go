@@ -0,0 +1,480 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/scim"
+)
+
+// SCIMTokenRepository implements scim.TokenRepository
+type SCIMTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSCIMTokenRepository(db *pgxpool.Pool) *SCIMTokenRepository {
+	return &SCIMTokenRepository{
+		db: db,
+	}
+}
+
+func (r *SCIMTokenRepository) Create(ctx context.Context, token *scim.Token) error {
+	query := `
+		INSERT INTO scim_tokens (id, organization_id, name, prefix, token_hash, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		token.ID,
+		token.OrganizationID,
+		token.Name,
+		token.Prefix,
+		token.TokenHash,
+		token.CreatedBy,
+		token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create SCIM token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SCIMTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*scim.Token, error) {
+	query := `
+		SELECT id, organization_id, name, prefix, token_hash, created_by, last_used_at, revoked_at, created_at
+		FROM scim_tokens
+		WHERE token_hash = $1
+	`
+
+	token, err := scanSCIMToken(r.db.QueryRow(ctx, query, tokenHash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, scim.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get SCIM token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (r *SCIMTokenRepository) ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*scim.Token, error) {
+	query := `
+		SELECT id, organization_id, name, prefix, token_hash, created_by, last_used_at, revoked_at, created_at
+		FROM scim_tokens
+		WHERE organization_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []*scim.Token{}
+	for rows.Next() {
+		token, err := scanSCIMToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan SCIM token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *SCIMTokenRepository) Revoke(ctx context.Context, organizationID, id uuid.UUID, revokedAt time.Time) error {
+	query := `
+		UPDATE scim_tokens
+		SET revoked_at = COALESCE(revoked_at, $3)
+		WHERE id = $1 AND organization_id = $2
+	`
+
+	result, err := r.db.Exec(ctx, query, id, organizationID, revokedAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke SCIM token: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return scim.ErrTokenNotFound
+	}
+
+	return nil
+}
+
+func (r *SCIMTokenRepository) UpdateLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	_, err := r.db.Exec(ctx, `UPDATE scim_tokens SET last_used_at = $2 WHERE id = $1`, id, usedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update SCIM token last used: %w", err)
+	}
+	return nil
+}
+
+func scanSCIMToken(row pgx.Row) (*scim.Token, error) {
+	var token scim.Token
+	err := row.Scan(
+		&token.ID,
+		&token.OrganizationID,
+		&token.Name,
+		&token.Prefix,
+		&token.TokenHash,
+		&token.CreatedBy,
+		&token.LastUsedAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// SCIMAccountRepository implements scim.AccountRepository
+type SCIMAccountRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSCIMAccountRepository(db *pgxpool.Pool) *SCIMAccountRepository {
+	return &SCIMAccountRepository{
+		db: db,
+	}
+}
+
+func (r *SCIMAccountRepository) Create(ctx context.Context, account *scim.Account) error {
+	query := `
+		INSERT INTO scim_accounts (
+			organization_id, user_id, user_name, external_id, deprovisioned_at, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7
+		)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		account.OrganizationID,
+		account.UserID,
+		account.UserName,
+		account.ExternalID,
+		account.DeprovisionedAt,
+		account.CreatedAt,
+		account.UpdatedAt,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return fmt.Errorf("%w: userName %s is already provisioned", scim.ErrUniqueness, account.UserName)
+		}
+		return fmt.Errorf("failed to create SCIM account: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SCIMAccountRepository) Get(ctx context.Context, organizationID, userID uuid.UUID) (*scim.Account, error) {
+	query := `
+		SELECT organization_id, user_id, user_name, external_id, deprovisioned_at, created_at, updated_at
+		FROM scim_accounts
+		WHERE organization_id = $1 AND user_id = $2
+	`
+
+	return r.get(ctx, query, organizationID, userID)
+}
+
+func (r *SCIMAccountRepository) GetByUserName(ctx context.Context, organizationID uuid.UUID, userName string) (*scim.Account, error) {
+	query := `
+		SELECT organization_id, user_id, user_name, external_id, deprovisioned_at, created_at, updated_at
+		FROM scim_accounts
+		WHERE organization_id = $1 AND LOWER(user_name) = LOWER($2)
+	`
+
+	return r.get(ctx, query, organizationID, userName)
+}
+
+func (r *SCIMAccountRepository) get(ctx context.Context, query string, args ...interface{}) (*scim.Account, error) {
+	account, err := scanSCIMAccount(r.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, scim.ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("failed to get SCIM account: %w", err)
+	}
+	return account, nil
+}
+
+func (r *SCIMAccountRepository) ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*scim.Account, error) {
+	query := `
+		SELECT organization_id, user_id, user_name, external_id, deprovisioned_at, created_at, updated_at
+		FROM scim_accounts
+		WHERE organization_id = $1
+		ORDER BY created_at, user_id
+	`
+
+	rows, err := r.db.Query(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM accounts: %w", err)
+	}
+	defer rows.Close()
+
+	accounts := []*scim.Account{}
+	for rows.Next() {
+		account, err := scanSCIMAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan SCIM account: %w", err)
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+func (r *SCIMAccountRepository) Update(ctx context.Context, account *scim.Account) error {
+	query := `
+		UPDATE scim_accounts
+		SET user_name = $3, external_id = $4, deprovisioned_at = $5, updated_at = $6
+		WHERE organization_id = $1 AND user_id = $2
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		account.OrganizationID,
+		account.UserID,
+		account.UserName,
+		account.ExternalID,
+		account.DeprovisionedAt,
+		account.UpdatedAt,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return fmt.Errorf("%w: userName %s is already provisioned", scim.ErrUniqueness, account.UserName)
+		}
+		return fmt.Errorf("failed to update SCIM account: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return scim.ErrResourceNotFound
+	}
+
+	return nil
+}
+
+func scanSCIMAccount(row pgx.Row) (*scim.Account, error) {
+	var account scim.Account
+	err := row.Scan(
+		&account.OrganizationID,
+		&account.UserID,
+		&account.UserName,
+		&account.ExternalID,
+		&account.DeprovisionedAt,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// SCIMGroupRepository implements scim.GroupRepository
+type SCIMGroupRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSCIMGroupRepository(db *pgxpool.Pool) *SCIMGroupRepository {
+	return &SCIMGroupRepository{
+		db: db,
+	}
+}
+
+// scimGroupSelect selects groups with their members aggregated into an array
+const scimGroupSelect = `
+	SELECT g.id, g.organization_id, g.role_id, g.display_name, g.external_id, g.created_at, g.updated_at,
+		COALESCE(array_agg(m.user_id::text) FILTER (WHERE m.user_id IS NOT NULL), '{}')
+	FROM scim_groups g
+	LEFT JOIN scim_group_members m ON m.group_id = g.id
+`
+
+func (r *SCIMGroupRepository) Create(ctx context.Context, group *scim.Group) error {
+	query := `
+		INSERT INTO scim_groups (id, organization_id, role_id, display_name, external_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, query,
+			group.ID,
+			group.OrganizationID,
+			nullableUUID(group.RoleID),
+			group.DisplayName,
+			group.ExternalID,
+			group.CreatedAt,
+			group.UpdatedAt,
+		)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+				return fmt.Errorf("%w: a group named %s already exists", scim.ErrUniqueness, group.DisplayName)
+			}
+			return fmt.Errorf("failed to create SCIM group: %w", err)
+		}
+
+		return insertSCIMGroupMembers(ctx, tx, group)
+	})
+}
+
+func (r *SCIMGroupRepository) GetByID(ctx context.Context, organizationID, id uuid.UUID) (*scim.Group, error) {
+	query := scimGroupSelect + `
+		WHERE g.organization_id = $1 AND g.id = $2
+		GROUP BY g.id
+	`
+
+	group, err := scanSCIMGroup(r.db.QueryRow(ctx, query, organizationID, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, scim.ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("failed to get SCIM group: %w", err)
+	}
+
+	return group, nil
+}
+
+func (r *SCIMGroupRepository) ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*scim.Group, error) {
+	query := scimGroupSelect + `
+		WHERE g.organization_id = $1
+		GROUP BY g.id
+		ORDER BY g.created_at, g.id
+	`
+
+	return r.list(ctx, query, organizationID)
+}
+
+func (r *SCIMGroupRepository) ListByMember(ctx context.Context, organizationID, userID uuid.UUID) ([]*scim.Group, error) {
+	query := scimGroupSelect + `
+		WHERE g.organization_id = $1
+			AND g.id IN (SELECT group_id FROM scim_group_members WHERE user_id = $2)
+		GROUP BY g.id
+		ORDER BY g.created_at, g.id
+	`
+
+	return r.list(ctx, query, organizationID, userID)
+}
+
+func (r *SCIMGroupRepository) list(ctx context.Context, query string, args ...interface{}) ([]*scim.Group, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []*scim.Group{}
+	for rows.Next() {
+		group, err := scanSCIMGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan SCIM group: %w", err)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
+
+func (r *SCIMGroupRepository) Update(ctx context.Context, group *scim.Group) error {
+	query := `
+		UPDATE scim_groups
+		SET display_name = $3, external_id = $4, updated_at = $5
+		WHERE organization_id = $1 AND id = $2
+	`
+
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, query,
+			group.OrganizationID,
+			group.ID,
+			group.DisplayName,
+			group.ExternalID,
+			group.UpdatedAt,
+		)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+				return fmt.Errorf("%w: a group named %s already exists", scim.ErrUniqueness, group.DisplayName)
+			}
+			return fmt.Errorf("failed to update SCIM group: %w", err)
+		}
+		if result.RowsAffected() == 0 {
+			return scim.ErrResourceNotFound
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM scim_group_members WHERE group_id = $1`, group.ID); err != nil {
+			return fmt.Errorf("failed to update SCIM group members: %w", err)
+		}
+		return insertSCIMGroupMembers(ctx, tx, group)
+	})
+}
+
+func (r *SCIMGroupRepository) Delete(ctx context.Context, organizationID, id uuid.UUID) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM scim_groups WHERE organization_id = $1 AND id = $2`, organizationID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete SCIM group: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return scim.ErrResourceNotFound
+	}
+
+	return nil
+}
+
+func insertSCIMGroupMembers(ctx context.Context, tx pgx.Tx, group *scim.Group) error {
+	for _, member := range group.Members {
+		_, err := tx.Exec(ctx, `INSERT INTO scim_group_members (group_id, user_id) VALUES ($1, $2)`, group.ID, member)
+		if err != nil {
+			return fmt.Errorf("failed to add SCIM group member: %w", err)
+		}
+	}
+	return nil
+}
+
+func scanSCIMGroup(row pgx.Row) (*scim.Group, error) {
+	var group scim.Group
+	var roleID *uuid.UUID
+	var members []string
+	err := row.Scan(
+		&group.ID,
+		&group.OrganizationID,
+		&roleID,
+		&group.DisplayName,
+		&group.ExternalID,
+		&group.CreatedAt,
+		&group.UpdatedAt,
+		&members,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if roleID != nil {
+		group.RoleID = *roleID
+	}
+	for _, member := range members {
+		userID, err := uuid.Parse(member)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SCIM group member: %w", err)
+		}
+		group.Members = append(group.Members, userID)
+	}
+	return &group, nil
+}
+
+// nullableUUID stores uuid.Nil as NULL
+func nullableUUID(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,237 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/serviceaccount"
+)
+
+// uniqueViolation is the PostgreSQL error code for a unique constraint violation
+const uniqueViolation = "23505"
+
+const serviceAccountColumns = `
+	sa.id, sa.name, COALESCE(sa.description, ''), sa.client_id, sa.secret_hash, sa.status,
+	sa.created_by, sa.last_authenticated_at, sa.secret_rotated_at, sa.created_at, sa.updated_at,
+	ARRAY(SELECT r.role FROM service_account_roles r WHERE r.service_account_id = sa.id ORDER BY r.role)
+`
+
+// ServiceAccountRepository implements serviceaccount.Repository
+type ServiceAccountRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewServiceAccountRepository(db *pgxpool.Pool) *ServiceAccountRepository {
+	return &ServiceAccountRepository{
+		db: db,
+	}
+}
+
+func (r *ServiceAccountRepository) Create(ctx context.Context, account *serviceaccount.ServiceAccount) error {
+	query := `
+		INSERT INTO service_accounts (
+			id, name, description, client_id, secret_hash, status, created_by,
+			secret_rotated_at, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+		)
+		ON CONFLICT (name) DO NOTHING
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		account.ID,
+		account.Name,
+		account.Description,
+		account.ClientID,
+		account.SecretHash,
+		account.Status,
+		account.CreatedBy,
+		account.SecretRotatedAt,
+		account.CreatedAt,
+		account.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create service account: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return serviceaccount.ErrServiceAccountExists
+	}
+
+	return nil
+}
+
+func (r *ServiceAccountRepository) GetByID(ctx context.Context, id uuid.UUID) (*serviceaccount.ServiceAccount, error) {
+	query := `SELECT ` + serviceAccountColumns + ` FROM service_accounts sa WHERE sa.id = $1`
+
+	account, err := r.scanServiceAccount(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, serviceaccount.ErrServiceAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to get service account: %w", err)
+	}
+
+	return account, nil
+}
+
+func (r *ServiceAccountRepository) GetByClientID(ctx context.Context, clientID string) (*serviceaccount.ServiceAccount, error) {
+	query := `SELECT ` + serviceAccountColumns + ` FROM service_accounts sa WHERE sa.client_id = $1`
+
+	account, err := r.scanServiceAccount(r.db.QueryRow(ctx, query, clientID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, serviceaccount.ErrServiceAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to get service account: %w", err)
+	}
+
+	return account, nil
+}
+
+func (r *ServiceAccountRepository) List(ctx context.Context) ([]*serviceaccount.ServiceAccount, error) {
+	query := `SELECT ` + serviceAccountColumns + ` FROM service_accounts sa ORDER BY sa.name`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []*serviceaccount.ServiceAccount
+	for rows.Next() {
+		account, err := r.scanServiceAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan service account: %w", err)
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+func (r *ServiceAccountRepository) Update(ctx context.Context, account *serviceaccount.ServiceAccount) error {
+	query := `
+		UPDATE service_accounts
+		SET name = $2, description = $3, status = $4, secret_hash = $5,
+		    secret_rotated_at = $6, updated_at = $7
+		WHERE id = $1
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		account.ID,
+		account.Name,
+		account.Description,
+		account.Status,
+		account.SecretHash,
+		account.SecretRotatedAt,
+		account.UpdatedAt,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return serviceaccount.ErrServiceAccountExists
+		}
+		return fmt.Errorf("failed to update service account: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return serviceaccount.ErrServiceAccountNotFound
+	}
+
+	return nil
+}
+
+func (r *ServiceAccountRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Exec(ctx, "DELETE FROM service_accounts WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete service account: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return serviceaccount.ErrServiceAccountNotFound
+	}
+
+	return nil
+}
+
+func (r *ServiceAccountRepository) AssignRole(ctx context.Context, assignment *serviceaccount.RoleAssignment) error {
+	query := `
+		INSERT INTO service_account_roles (service_account_id, role, granted_by, granted_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (service_account_id, role) DO NOTHING
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		assignment.ServiceAccountID,
+		assignment.Role,
+		assignment.GrantedBy,
+		assignment.GrantedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return serviceaccount.ErrRoleAlreadyAssigned
+	}
+
+	return nil
+}
+
+func (r *ServiceAccountRepository) RemoveRole(ctx context.Context, id uuid.UUID, role string) error {
+	result, err := r.db.Exec(ctx,
+		"DELETE FROM service_account_roles WHERE service_account_id = $1 AND role = $2",
+		id, role,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove role: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return serviceaccount.ErrRoleNotAssigned
+	}
+
+	return nil
+}
+
+func (r *ServiceAccountRepository) UpdateLastAuthenticated(ctx context.Context, id uuid.UUID, at time.Time) error {
+	_, err := r.db.Exec(ctx,
+		"UPDATE service_accounts SET last_authenticated_at = $2 WHERE id = $1",
+		id, at,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update service account usage: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ServiceAccountRepository) scanServiceAccount(row pgx.Row) (*serviceaccount.ServiceAccount, error) {
+	var account serviceaccount.ServiceAccount
+	err := row.Scan(
+		&account.ID,
+		&account.Name,
+		&account.Description,
+		&account.ClientID,
+		&account.SecretHash,
+		&account.Status,
+		&account.CreatedBy,
+		&account.LastAuthenticatedAt,
+		&account.SecretRotatedAt,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+		&account.Roles,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+	"github.com/victoralfred/um_sys/internal/domain/billing"
+)
+
+// SubscriptionPlanRepository looks up the plan of a user's or an organization's
+// current subscription, for the services that enforce per-plan limits
+type SubscriptionPlanRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSubscriptionPlanRepository(db *pgxpool.Pool) *SubscriptionPlanRepository {
+	return &SubscriptionPlanRepository{
+		db: db,
+	}
+}
+
+const currentPlanQuery = `
+	SELECT p.id, p.name, p.plan_type, COALESCE(p.description, ''), p.price_cents, p.currency,
+		p.billing_period, COALESCE(p.max_users, 0), COALESCE(p.max_team_members, 0),
+		p.is_active, p.created_at, p.updated_at
+	FROM subscriptions s
+	JOIN subscription_plans p ON p.id = s.plan_id
+	WHERE s.status IN ('active', 'trialing') AND `
+
+// CurrentPlan returns the plan of the user's own subscription, ignoring those of their organizations
+func (r *SubscriptionPlanRepository) CurrentPlan(ctx context.Context, userID uuid.UUID) (*billing.Plan, error) {
+	return r.currentPlan(ctx, "s.user_id = $1 AND s.organization_id IS NULL", userID)
+}
+
+// CurrentOrganizationPlan returns the plan of the organization's subscription
+func (r *SubscriptionPlanRepository) CurrentOrganizationPlan(ctx context.Context, organizationID uuid.UUID) (*billing.Plan, error) {
+	return r.currentPlan(ctx, "s.organization_id = $1", organizationID)
+}
+
+func (r *SubscriptionPlanRepository) currentPlan(ctx context.Context, owner string, id uuid.UUID) (*billing.Plan, error) {
+	query := currentPlanQuery + owner + `
+		ORDER BY s.current_period_start DESC
+		LIMIT 1
+	`
+
+	plan := &billing.Plan{}
+	var priceCents int64
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&plan.ID, &plan.Name, &plan.Type, &plan.Description, &priceCents, &plan.Currency,
+		&plan.BillingInterval, &plan.Limits.MaxUsers, &plan.Limits.MaxTeamMembers,
+		&plan.IsActive, &plan.CreatedAt, &plan.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, billing.ErrNoActiveSubscription
+		}
+		return nil, fmt.Errorf("failed to get current plan: %w", err)
+	}
+
+	plan.Price = decimal.New(priceCents, -2)
+
+	return plan, nil
+}
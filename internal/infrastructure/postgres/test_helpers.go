@@ -121,7 +121,8 @@ func runTestMigrations(ctx context.Context, pool *pgxpool.Pool) error {
 			event_type VARCHAR(100) NOT NULL,
 			severity VARCHAR(20) NOT NULL CHECK (severity IN ('info', 'warning', 'error', 'critical')),
 			user_id UUID REFERENCES users(id) ON DELETE SET NULL,
-			actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			actor_id UUID,
+			actor_type VARCHAR(20),
 			entity_type VARCHAR(100) NOT NULL,
 			entity_id VARCHAR(255) NOT NULL,
 			action VARCHAR(100) NOT NULL,
@@ -0,0 +1,213 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+)
+
+// TokenStore implements auth.TokenStore for deployments without Redis
+type TokenStore struct {
+	db *pgxpool.Pool
+}
+
+func NewTokenStore(db *pgxpool.Pool) *TokenStore {
+	return &TokenStore{
+		db: db,
+	}
+}
+
+func (s *TokenStore) Store(ctx context.Context, tokenID string, userID uuid.UUID, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO UPDATE SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)
+	`
+
+	_, err := s.db.Exec(ctx, query, tokenID, userID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to store token: %w", err)
+	}
+
+	return nil
+}
+
+func (s *TokenStore) Exists(ctx context.Context, tokenID string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expires_at > NOW())",
+		tokenID,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check token: %w", err)
+	}
+
+	return exists, nil
+}
+
+func (s *TokenStore) Delete(ctx context.Context, tokenID string) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM revoked_tokens WHERE jti = $1", tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+
+	return nil
+}
+
+func (s *TokenStore) DeleteAllForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM revoked_tokens WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user tokens: %w", err)
+	}
+
+	return nil
+}
+
+func (s *TokenStore) SaveFamily(ctx context.Context, family *auth.TokenFamily) error {
+	query := `
+		INSERT INTO token_families (
+			id, user_id, session_id, current_jti, client_id, scopes,
+			created_at, rotated_at, expires_at, revoked_at, organization_id
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		)
+		ON CONFLICT (id) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			session_id = EXCLUDED.session_id,
+			current_jti = EXCLUDED.current_jti,
+			client_id = EXCLUDED.client_id,
+			scopes = EXCLUDED.scopes,
+			created_at = EXCLUDED.created_at,
+			rotated_at = EXCLUDED.rotated_at,
+			expires_at = EXCLUDED.expires_at,
+			revoked_at = EXCLUDED.revoked_at,
+			organization_id = EXCLUDED.organization_id
+	`
+
+	scopes := family.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	_, err := s.db.Exec(ctx, query,
+		family.ID,
+		family.UserID,
+		family.SessionID,
+		family.CurrentJTI,
+		family.ClientID,
+		scopes,
+		family.CreatedAt,
+		family.RotatedAt,
+		family.ExpiresAt,
+		family.RevokedAt,
+		family.OrganizationID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save token family: %w", err)
+	}
+
+	return nil
+}
+
+func (s *TokenStore) GetFamily(ctx context.Context, familyID string) (*auth.TokenFamily, error) {
+	query := `
+		SELECT id, user_id, session_id, current_jti, client_id, scopes,
+			created_at, rotated_at, expires_at, revoked_at, organization_id
+		FROM token_families
+		WHERE id = $1
+	`
+
+	var family auth.TokenFamily
+	err := s.db.QueryRow(ctx, query, familyID).Scan(
+		&family.ID,
+		&family.UserID,
+		&family.SessionID,
+		&family.CurrentJTI,
+		&family.ClientID,
+		&family.Scopes,
+		&family.CreatedAt,
+		&family.RotatedAt,
+		&family.ExpiresAt,
+		&family.RevokedAt,
+		&family.OrganizationID,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, auth.ErrTokenFamilyNotFound
+		}
+		return nil, fmt.Errorf("failed to get token family: %w", err)
+	}
+
+	if len(family.Scopes) == 0 {
+		family.Scopes = nil
+	}
+
+	return &family, nil
+}
+
+func (s *TokenStore) RotateFamily(ctx context.Context, familyID, currentTokenID, nextTokenID string, expiresAt time.Time) error {
+	// Matching on the current token ID makes the swap atomic across replicas
+	result, err := s.db.Exec(ctx, `
+		UPDATE token_families
+		SET current_jti = $3, rotated_at = NOW(), expires_at = $4
+		WHERE id = $1 AND current_jti = $2
+	`, familyID, currentTokenID, nextTokenID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to rotate token family: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		if _, err := s.GetFamily(ctx, familyID); err != nil {
+			return err
+		}
+		return auth.ErrRefreshTokenReused
+	}
+
+	return nil
+}
+
+func (s *TokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	result, err := s.db.Exec(ctx,
+		"UPDATE token_families SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1",
+		familyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return auth.ErrTokenFamilyNotFound
+	}
+
+	return nil
+}
+
+func (s *TokenStore) RevokeUserFamilies(ctx context.Context, userID uuid.UUID) error {
+	_, err := s.db.Exec(ctx,
+		"UPDATE token_families SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke user token families: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes revoked tokens and families whose tokens can no longer be presented
+func (s *TokenStore) DeleteExpired(ctx context.Context) error {
+	if _, err := s.db.Exec(ctx, "DELETE FROM revoked_tokens WHERE expires_at < NOW()"); err != nil {
+		return fmt.Errorf("failed to delete expired tokens: %w", err)
+	}
+
+	if _, err := s.db.Exec(ctx, "DELETE FROM token_families WHERE expires_at < NOW()"); err != nil {
+		return fmt.Errorf("failed to delete expired token families: %w", err)
+	}
+
+	return nil
+}
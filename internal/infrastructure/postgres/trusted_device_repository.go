@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+)
+
+// TrustedDeviceRepository implements mfa.TrustedDeviceRepository
+type TrustedDeviceRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTrustedDeviceRepository(db *pgxpool.Pool) *TrustedDeviceRepository {
+	return &TrustedDeviceRepository{
+		db: db,
+	}
+}
+
+func (r *TrustedDeviceRepository) Create(ctx context.Context, device *mfa.TrustedDevice) error {
+	query := `
+		INSERT INTO trusted_devices (id, user_id, ip_address, user_agent, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		device.ID,
+		device.UserID,
+		device.IPAddress,
+		device.UserAgent,
+		device.ExpiresAt,
+		device.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create trusted device: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TrustedDeviceRepository) GetByID(ctx context.Context, id uuid.UUID) (*mfa.TrustedDevice, error) {
+	query := `
+		SELECT id, user_id, ip_address, user_agent, last_used_at, expires_at, created_at
+		FROM trusted_devices
+		WHERE id = $1
+	`
+
+	device, err := r.scanDevice(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, mfa.ErrTrustedDeviceNotFound
+		}
+		return nil, fmt.Errorf("failed to get trusted device: %w", err)
+	}
+
+	return device, nil
+}
+
+func (r *TrustedDeviceRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*mfa.TrustedDevice, error) {
+	query := `
+		SELECT id, user_id, ip_address, user_agent, last_used_at, expires_at, created_at
+		FROM trusted_devices
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trusted devices: %w", err)
+	}
+	defer rows.Close()
+
+	var devices []*mfa.TrustedDevice
+	for rows.Next() {
+		device, err := r.scanDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trusted device: %w", err)
+		}
+		devices = append(devices, device)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list trusted devices: %w", err)
+	}
+
+	return devices, nil
+}
+
+func (r *TrustedDeviceRepository) UpdateLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	_, err := r.db.Exec(ctx, "UPDATE trusted_devices SET last_used_at = $2 WHERE id = $1", id, usedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update trusted device: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TrustedDeviceRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	result, err := r.db.Exec(ctx, "DELETE FROM trusted_devices WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete trusted device: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return mfa.ErrTrustedDeviceNotFound
+	}
+
+	return nil
+}
+
+func (r *TrustedDeviceRepository) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM trusted_devices WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete trusted devices: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TrustedDeviceRepository) DeleteExpired(ctx context.Context) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM trusted_devices WHERE expires_at < NOW()")
+	if err != nil {
+		return fmt.Errorf("failed to delete expired trusted devices: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TrustedDeviceRepository) scanDevice(row pgx.Row) (*mfa.TrustedDevice, error) {
+	var device mfa.TrustedDevice
+	if err := row.Scan(
+		&device.ID,
+		&device.UserID,
+		&device.IPAddress,
+		&device.UserAgent,
+		&device.LastUsedAt,
+		&device.ExpiresAt,
+		&device.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
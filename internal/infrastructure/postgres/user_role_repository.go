@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserRoleRepository looks up the RBAC roles granted to users, for the
+// roles carried in their tokens
+type UserRoleRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUserRoleRepository(db *pgxpool.Pool) *UserRoleRepository {
+	return &UserRoleRepository{
+		db: db,
+	}
+}
+
+// UserRoleNames returns the names of the user's roles whose grants have not expired
+func (r *UserRoleRepository) UserRoleNames(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	query := `
+		SELECT r.name
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1 AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+		ORDER BY r.name
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user roles: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan user role: %w", err)
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
@@ -0,0 +1,254 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/victoralfred/um_sys/internal/domain/webauthn"
+)
+
+const webAuthnCredentialColumns = `
+	id, user_id, name, credential_id, public_key, algorithm, sign_count,
+	aaguid, transports, backup_eligible, last_used_at, created_at
+`
+
+// WebAuthnCredentialRepository implements webauthn.CredentialRepository
+type WebAuthnCredentialRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewWebAuthnCredentialRepository(db *pgxpool.Pool) *WebAuthnCredentialRepository {
+	return &WebAuthnCredentialRepository{
+		db: db,
+	}
+}
+
+func (r *WebAuthnCredentialRepository) Create(ctx context.Context, credential *webauthn.Credential) error {
+	query := `
+		INSERT INTO webauthn_credentials (
+			id, user_id, name, credential_id, public_key, algorithm, sign_count,
+			aaguid, transports, backup_eligible, last_used_at, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+		)
+		ON CONFLICT (credential_id) DO NOTHING
+	`
+
+	transports := credential.Transports
+	if transports == nil {
+		transports = []string{}
+	}
+
+	result, err := r.db.Exec(ctx, query,
+		credential.ID,
+		credential.UserID,
+		credential.Name,
+		[]byte(credential.CredentialID),
+		credential.PublicKey,
+		credential.Algorithm,
+		int64(credential.SignCount),
+		[]byte(credential.AAGUID),
+		transports,
+		credential.BackupEligible,
+		credential.LastUsedAt,
+		credential.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create WebAuthn credential: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return webauthn.ErrCredentialExists
+	}
+
+	return nil
+}
+
+func (r *WebAuthnCredentialRepository) GetByCredentialID(ctx context.Context, credentialID []byte) (*webauthn.Credential, error) {
+	query := `SELECT ` + webAuthnCredentialColumns + ` FROM webauthn_credentials WHERE credential_id = $1`
+
+	credential, err := r.scanCredential(r.db.QueryRow(ctx, query, credentialID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, webauthn.ErrCredentialNotFound
+		}
+		return nil, fmt.Errorf("failed to get WebAuthn credential: %w", err)
+	}
+
+	return credential, nil
+}
+
+func (r *WebAuthnCredentialRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*webauthn.Credential, error) {
+	query := `SELECT ` + webAuthnCredentialColumns + ` FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WebAuthn credentials: %w", err)
+	}
+	defer rows.Close()
+
+	var credentials []*webauthn.Credential
+	for rows.Next() {
+		credential, err := r.scanCredential(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan WebAuthn credential: %w", err)
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, rows.Err()
+}
+
+func (r *WebAuthnCredentialRepository) Rename(ctx context.Context, userID, id uuid.UUID, name string) error {
+	result, err := r.db.Exec(ctx,
+		"UPDATE webauthn_credentials SET name = $3 WHERE id = $1 AND user_id = $2",
+		id, userID, name,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to rename WebAuthn credential: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return webauthn.ErrCredentialNotFound
+	}
+
+	return nil
+}
+
+func (r *WebAuthnCredentialRepository) UpdateSignCount(ctx context.Context, id uuid.UUID, signCount uint32, usedAt time.Time) error {
+	_, err := r.db.Exec(ctx,
+		"UPDATE webauthn_credentials SET sign_count = $2, last_used_at = $3 WHERE id = $1",
+		id, int64(signCount), usedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update WebAuthn credential: %w", err)
+	}
+
+	return nil
+}
+
+func (r *WebAuthnCredentialRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	result, err := r.db.Exec(ctx,
+		"DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2",
+		id, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete WebAuthn credential: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return webauthn.ErrCredentialNotFound
+	}
+
+	return nil
+}
+
+func (r *WebAuthnCredentialRepository) scanCredential(row pgx.Row) (*webauthn.Credential, error) {
+	var credential webauthn.Credential
+	var credentialID, aaguid []byte
+	var signCount int64
+
+	err := row.Scan(
+		&credential.ID,
+		&credential.UserID,
+		&credential.Name,
+		&credentialID,
+		&credential.PublicKey,
+		&credential.Algorithm,
+		&signCount,
+		&aaguid,
+		&credential.Transports,
+		&credential.BackupEligible,
+		&credential.LastUsedAt,
+		&credential.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	credential.CredentialID = credentialID
+	credential.AAGUID = aaguid
+	credential.SignCount = uint32(signCount)
+
+	return &credential, nil
+}
+
+// WebAuthnCeremonyRepository implements webauthn.CeremonyRepository
+type WebAuthnCeremonyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewWebAuthnCeremonyRepository(db *pgxpool.Pool) *WebAuthnCeremonyRepository {
+	return &WebAuthnCeremonyRepository{
+		db: db,
+	}
+}
+
+func (r *WebAuthnCeremonyRepository) Create(ctx context.Context, ceremony *webauthn.Ceremony) error {
+	query := `
+		INSERT INTO webauthn_ceremonies (
+			id, type, user_id, challenge, credential_name, user_verification, expires_at, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8
+		)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		ceremony.ID,
+		ceremony.Type,
+		ceremony.UserID,
+		ceremony.Challenge,
+		ceremony.CredentialName,
+		ceremony.UserVerification,
+		ceremony.ExpiresAt,
+		ceremony.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create WebAuthn ceremony: %w", err)
+	}
+
+	return nil
+}
+
+func (r *WebAuthnCeremonyRepository) Consume(ctx context.Context, id uuid.UUID) (*webauthn.Ceremony, error) {
+	// Deleting as it is read makes each challenge single-use even under concurrent requests
+	query := `
+		DELETE FROM webauthn_ceremonies
+		WHERE id = $1
+		RETURNING id, type, user_id, challenge, COALESCE(credential_name, ''),
+			user_verification, expires_at, created_at
+	`
+
+	var ceremony webauthn.Ceremony
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&ceremony.ID,
+		&ceremony.Type,
+		&ceremony.UserID,
+		&ceremony.Challenge,
+		&ceremony.CredentialName,
+		&ceremony.UserVerification,
+		&ceremony.ExpiresAt,
+		&ceremony.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, webauthn.ErrCeremonyNotFound
+		}
+		return nil, fmt.Errorf("failed to consume WebAuthn ceremony: %w", err)
+	}
+
+	return &ceremony, nil
+}
+
+func (r *WebAuthnCeremonyRepository) DeleteExpired(ctx context.Context) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM webauthn_ceremonies WHERE expires_at < NOW()")
+	if err != nil {
+		return fmt.Errorf("failed to delete expired WebAuthn ceremonies: %w", err)
+	}
+
+	return nil
+}
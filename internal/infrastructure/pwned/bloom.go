@@ -0,0 +1,171 @@
+package pwned
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// bloomMagic starts every serialized filter
+const bloomMagic = "PWBF"
+
+// bloomHeader precedes the filter's bits in a serialized filter
+type bloomHeader struct {
+	Magic  [4]byte
+	Size   uint64
+	Hashes uint32
+}
+
+// ErrInvalidBloomFilter is returned when a filter file cannot be read
+var ErrInvalidBloomFilter = errors.New("invalid bloom filter")
+
+// BloomFilter implements security.BreachSource with a bloom filter of corpus
+// hashes. It holds the whole corpus in a fraction of the file's size, at the
+// cost of occasional false positives and no occurrence counts.
+type BloomFilter struct {
+	bits   []uint64
+	size   uint64 // number of bits
+	hashes uint32 // bits set per entry
+}
+
+// NewBloomFilter creates an empty filter sized for the expected number of
+// entries and false positive rate
+func NewBloomFilter(expected int, falsePositiveRate float64) *BloomFilter {
+	if expected < 1 {
+		expected = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.001
+	}
+
+	n := float64(expected)
+	size := uint64(math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint32(math.Max(1, math.Round(float64(size)/n*math.Ln2)))
+
+	return &BloomFilter{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+// BuildBloomFilter reads an ordered or unordered HASH:COUNT corpus into a new filter
+func BuildBloomFilter(corpus io.Reader, expected int, falsePositiveRate float64) (*BloomFilter, error) {
+	filter := NewBloomFilter(expected, falsePositiveRate)
+
+	scanner := bufio.NewScanner(corpus)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		encoded, _, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("malformed breach corpus: %w", err)
+		}
+
+		var hash [sha1.Size]byte
+		if n, err := hex.Decode(hash[:], []byte(encoded)); err != nil || n != sha1.Size {
+			return nil, fmt.Errorf("malformed breach corpus: invalid hash %q", encoded)
+		}
+		filter.Add(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breach corpus: %w", err)
+	}
+
+	return filter, nil
+}
+
+// LoadBloomFilter reads a filter written by WriteTo from disk
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bloom filter: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	return ReadBloomFilter(bufio.NewReader(file))
+}
+
+// ReadBloomFilter reads a filter written by WriteTo
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	var header bloomHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBloomFilter, err)
+	}
+	if string(header.Magic[:]) != bloomMagic || header.Size == 0 || header.Hashes == 0 {
+		return nil, ErrInvalidBloomFilter
+	}
+
+	filter := &BloomFilter{
+		bits:   make([]uint64, (header.Size+63)/64),
+		size:   header.Size,
+		hashes: header.Hashes,
+	}
+	if err := binary.Read(r, binary.LittleEndian, filter.bits); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBloomFilter, err)
+	}
+
+	return filter, nil
+}
+
+// WriteTo serializes the filter
+func (b *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := bloomHeader{Size: b.size, Hashes: b.hashes}
+	copy(header.Magic[:], bloomMagic)
+
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, b.bits); err != nil {
+		return int64(binary.Size(header)), err
+	}
+
+	return int64(binary.Size(header) + binary.Size(b.bits)), nil
+}
+
+// Add records a SHA-1 hash in the filter
+func (b *BloomFilter) Add(hash [sha1.Size]byte) {
+	b.each(hash, func(bit uint64) {
+		b.bits[bit/64] |= 1 << (bit % 64)
+	})
+}
+
+// Contains checks if the hash may have been added
+func (b *BloomFilter) Contains(hash [sha1.Size]byte) bool {
+	found := true
+	b.each(hash, func(bit uint64) {
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			found = false
+		}
+	})
+	return found
+}
+
+// Occurrences reports a filter hit as a single occurrence, since the filter keeps no counts
+func (b *BloomFilter) Occurrences(ctx context.Context, hash [sha1.Size]byte) (int, error) {
+	if b.Contains(hash) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// each calls fn with the bits of a hash. SHA-1 output is already uniform, so its
+// halves serve as the two base hashes of double hashing.
+func (b *BloomFilter) each(hash [sha1.Size]byte, fn func(bit uint64)) {
+	h1 := binary.BigEndian.Uint64(hash[0:8])
+	h2 := binary.BigEndian.Uint64(hash[8:16]) | 1
+
+	for i := uint64(0); i < uint64(b.hashes); i++ {
+		fn((h1 + i*h2) % b.size)
+	}
+}
@@ -0,0 +1,77 @@
+package pwned
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the Have I Been Pwned range API
+const DefaultAPIURL = "https://api.pwnedpasswords.com/range/"
+
+// Client implements security.RangeClient against a range API that answers
+// GET <base><prefix> with SUFFIX:COUNT lines, such as Have I Been Pwned's
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a range API client
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
+	return &Client{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Range fetches the suffixes of every breached hash that starts with prefix
+func (c *Client) Range(ctx context.Context, prefix string) (map[string]int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+prefix, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create range request: %w", err)
+	}
+	// Padding hides the real size of the range from anyone watching the traffic
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch range: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch range: unexpected status %d", resp.StatusCode)
+	}
+
+	suffixes := make(map[string]int)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+
+		suffix, count, err := parseLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("malformed range response: %w", err)
+		}
+		// Padding entries have a count of zero
+		if count > 0 {
+			suffixes[suffix] = count
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read range: %w", err)
+	}
+
+	return suffixes, nil
+}
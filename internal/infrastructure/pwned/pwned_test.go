@@ -0,0 +1,169 @@
+package pwned_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/infrastructure/pwned"
+	"github.com/victoralfred/um_sys/pkg/security"
+)
+
+var corpus = map[string]int{
+	"password":     9659365,
+	"123456":       37359195,
+	"qwerty":       10556095,
+	"letmein":      566238,
+	"Password1":    2413945,
+	"correcthorse": 12,
+}
+
+func hexHash(password string) string {
+	hash := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(hash[:]))
+}
+
+// corpusLines renders the corpus as ordered HASH:COUNT lines with CRLF endings
+func corpusLines() string {
+	lines := make([]string, 0, len(corpus))
+	for password, count := range corpus {
+		lines = append(lines, fmt.Sprintf("%s:%d\r\n", hexHash(password), count))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "")
+}
+
+func TestRangeFile(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords.txt")
+	require.NoError(t, os.WriteFile(path, []byte(corpusLines()), 0o600))
+
+	file, err := pwned.OpenRangeFile(path)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+
+	for password, want := range corpus {
+		count, err := file.Occurrences(ctx, sha1.Sum([]byte(password)))
+		require.NoError(t, err)
+		assert.Equal(t, want, count, password)
+	}
+
+	for _, password := range []string{"", "Un1qu3P@ssw0rd!", "passwords", "Password123!@#"} {
+		count, err := file.Occurrences(ctx, sha1.Sum([]byte(password)))
+		require.NoError(t, err)
+		assert.Zero(t, count, password)
+	}
+
+	t.Run("finds every line of a larger corpus", func(t *testing.T) {
+		lines := make([]string, 0, 2000)
+		for i := 0; i < 2000; i++ {
+			lines = append(lines, fmt.Sprintf("%s:%d\n", hexHash(fmt.Sprint(i)), i+1))
+		}
+		sort.Strings(lines)
+
+		path := filepath.Join(t.TempDir(), "large.txt")
+		require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "")), 0o600))
+
+		large, err := pwned.OpenRangeFile(path)
+		require.NoError(t, err)
+		defer func() { _ = large.Close() }()
+
+		for i := 0; i < 2000; i++ {
+			count, err := large.Occurrences(ctx, sha1.Sum([]byte(fmt.Sprint(i))))
+			require.NoError(t, err)
+			require.Equal(t, i+1, count)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := pwned.OpenRangeFile(filepath.Join(t.TempDir(), "missing.txt"))
+		assert.Error(t, err)
+	})
+}
+
+func TestBloomFilter(t *testing.T) {
+	ctx := context.Background()
+
+	filter, err := pwned.BuildBloomFilter(strings.NewReader(corpusLines()), len(corpus), 0.0001)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = filter.WriteTo(&buf)
+	require.NoError(t, err)
+
+	loaded, err := pwned.ReadBloomFilter(&buf)
+	require.NoError(t, err)
+
+	for password := range corpus {
+		count, err := loaded.Occurrences(ctx, sha1.Sum([]byte(password)))
+		require.NoError(t, err)
+		assert.Equal(t, 1, count, password)
+	}
+
+	misses := 0
+	for i := 0; i < 1000; i++ {
+		if loaded.Contains(sha1.Sum([]byte(fmt.Sprintf("not-in-corpus-%d", i)))) {
+			misses++
+		}
+	}
+	assert.LessOrEqual(t, misses, 5)
+
+	t.Run("rejects other files", func(t *testing.T) {
+		_, err := pwned.ReadBloomFilter(strings.NewReader(corpusLines()))
+		assert.ErrorIs(t, err, pwned.ErrInvalidBloomFilter)
+	})
+
+	t.Run("rejects malformed corpora", func(t *testing.T) {
+		_, err := pwned.BuildBloomFilter(strings.NewReader("ABC:1\n"), 10, 0.01)
+		assert.Error(t, err)
+	})
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	target := hexHash("password")
+
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		assert.Equal(t, "true", r.Header.Get("Add-Padding"))
+
+		if r.URL.Path == "/range/FFFFF" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%s:%d\r\n", target[5:], corpus["password"])
+		_, _ = fmt.Fprint(w, "0000000000000000000000000000000000A:0\r\n")
+	}))
+	defer server.Close()
+
+	source := security.NewKAnonymitySource(pwned.NewClient(server.URL+"/range", 0))
+
+	count, err := source.Occurrences(ctx, sha1.Sum([]byte("password")))
+	require.NoError(t, err)
+	assert.Equal(t, corpus["password"], count)
+
+	// Only the five-character prefix leaves the process
+	require.Len(t, requested, 1)
+	assert.Equal(t, "/range/"+target[:5], requested[0])
+
+	suffixes, err := pwned.NewClient(server.URL+"/range/", 0).Range(ctx, "ABCDE")
+	require.NoError(t, err)
+	assert.NotContains(t, suffixes, "0000000000000000000000000000000000A", "padding is dropped")
+
+	_, err = pwned.NewClient(server.URL+"/range/", 0).Range(ctx, "FFFFF")
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+// Package pwned checks passwords against pwned-password corpora: a local file of
+// SHA-1 hashes ordered by hash, a compact bloom filter built from one, or a
+// remote range API queried with k-anonymity.
+package pwned
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RangeFile implements security.BreachSource over a corpus file with one
+// HASH:COUNT line per password, ordered by hash, as published by Have I Been
+// Pwned. Lookups binary-search the file on disk, so it is never loaded.
+type RangeFile struct {
+	file *os.File
+	size int64
+}
+
+// OpenRangeFile opens an ordered corpus file
+func OpenRangeFile(path string) (*RangeFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breach corpus: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to stat breach corpus: %w", err)
+	}
+
+	return &RangeFile{file: file, size: info.Size()}, nil
+}
+
+// Close closes the corpus file
+func (f *RangeFile) Close() error {
+	return f.file.Close()
+}
+
+// Occurrences returns the count on the hash's line, or zero if it has none
+func (f *RangeFile) Occurrences(ctx context.Context, hash [sha1.Size]byte) (int, error) {
+	target := strings.ToUpper(hex.EncodeToString(hash[:]))
+
+	// Search byte offsets; each probe reads the first line starting at or after mid
+	lo, hi := int64(0), f.size
+	for lo < hi {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		mid := lo + (hi-lo)/2
+		start, err := f.lineStart(mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+
+		line, err := f.readLine(start)
+		if err != nil {
+			return 0, err
+		}
+
+		lineHash, count, err := parseLine(line)
+		if err != nil {
+			return 0, fmt.Errorf("malformed breach corpus at offset %d: %w", start, err)
+		}
+
+		switch strings.Compare(lineHash, target) {
+		case 0:
+			return count, nil
+		case -1:
+			lo = start + int64(len(line))
+		default:
+			hi = mid
+		}
+	}
+
+	return 0, nil
+}
+
+// lineStart returns the offset of the first line that starts at or after pos
+func (f *RangeFile) lineStart(pos int64) (int64, error) {
+	if pos == 0 {
+		return 0, nil
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(f.file, pos-1, f.size-pos+1))
+	skipped, err := reader.ReadString('\n')
+	if err == io.EOF {
+		return f.size, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read breach corpus: %w", err)
+	}
+
+	return pos - 1 + int64(len(skipped)), nil
+}
+
+// readLine returns the line at start, including its line ending
+func (f *RangeFile) readLine(start int64) (string, error) {
+	reader := bufio.NewReader(io.NewSectionReader(f.file, start, f.size-start))
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read breach corpus: %w", err)
+	}
+	return line, nil
+}
+
+// parseLine splits a HASH:COUNT line
+func parseLine(line string) (string, int, error) {
+	hash, count, found := strings.Cut(strings.TrimRight(line, "\r\n"), ":")
+	if !found {
+		return "", 0, fmt.Errorf("missing count")
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid count: %w", err)
+	}
+
+	return strings.ToUpper(hash), n, nil
+}
@@ -66,7 +66,8 @@ func (r *SessionRepository) Store(ctx context.Context, sess *session.Session) er
 
 	// Add session ID to user's session set
 	pipe.SAdd(ctx, userSessionsKey, sess.ID)
-	pipe.Expire(ctx, userSessionsKey, 24*time.Hour) // Keep user sessions index for 24h
+	// Keep the user's session index at least as long as this session
+	pipe.Expire(ctx, userSessionsKey, max(ttl, 24*time.Hour))
 
 	_, err = pipe.Exec(ctx)
 	if err != nil {
@@ -83,7 +84,7 @@ func (r *SessionRepository) GetByID(ctx context.Context, sessionID string) (*ses
 	data, err := r.client.Get(ctx, sessionKey).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, fmt.Errorf("session not found: %s", sessionID)
+			return nil, fmt.Errorf("%w: %s", session.ErrSessionNotFound, sessionID)
 		}
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
@@ -138,7 +139,7 @@ func (r *SessionRepository) Update(ctx context.Context, sess *session.Session) e
 	}
 
 	if exists == 0 {
-		return fmt.Errorf("session not found: %s", sess.ID)
+		return fmt.Errorf("%w: %s", session.ErrSessionNotFound, sess.ID)
 	}
 
 	// Store updated session
@@ -152,7 +153,7 @@ func (r *SessionRepository) Delete(ctx context.Context, sessionID string) error
 	// Get session to find user ID
 	sess, err := r.GetByID(ctx, sessionID)
 	if err != nil {
-		return fmt.Errorf("session not found: %s", sessionID)
+		return err
 	}
 
 	userSessionsKey := userSessionKeyPrefix + sess.UserID.String()
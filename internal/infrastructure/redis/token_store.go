@@ -0,0 +1,332 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+)
+
+const (
+	revokedTokenKeyPrefix      = "revoked_token:"
+	userRevokedTokensKeyPrefix = "user_revoked_tokens:"
+	tokenFamilyKeyPrefix       = "token_family:"
+	userTokenFamiliesKeyPrefix = "user_token_families:"
+)
+
+// rotateFamilyScript swaps a family's current refresh token ID only if the
+// presented one is still current, so two replicas cannot both rotate it.
+// Returns -1 if the family does not exist and 0 if the token was already rotated.
+var rotateFamilyScript = redis.NewScript(`
+	local key = KEYS[1]
+
+	if redis.call('EXISTS', key) == 0 then
+		return -1
+	end
+
+	if redis.call('HGET', key, 'current_jti') ~= ARGV[1] then
+		return 0
+	end
+
+	redis.call('HSET', key, 'current_jti', ARGV[2], 'rotated_at', ARGV[3], 'expires_at', ARGV[4])
+	redis.call('PEXPIREAT', key, ARGV[5])
+	return 1
+`)
+
+// revokeFamilyScript marks a family revoked, keeping the time of the first revocation.
+// Returns 0 if the family does not exist.
+var revokeFamilyScript = redis.NewScript(`
+	local key = KEYS[1]
+
+	if redis.call('EXISTS', key) == 0 then
+		return 0
+	end
+
+	redis.call('HSETNX', key, 'revoked_at', ARGV[1])
+	return 1
+`)
+
+// TokenStore implements auth.TokenStore using Redis. Revoked token IDs and
+// refresh token families expire with the tokens they describe, and per-user
+// sets index both so a user's tokens can be found without scanning.
+type TokenStore struct {
+	client *redis.Client
+}
+
+// NewTokenStore creates a new Redis token store
+func NewTokenStore(client *redis.Client) *TokenStore {
+	return &TokenStore{
+		client: client,
+	}
+}
+
+// Store records a revoked token ID until the token would have expired anyway
+func (s *TokenStore) Store(ctx context.Context, tokenID string, userID uuid.UUID, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, revokedTokenKeyPrefix+tokenID, userID.String(), ttl)
+
+	// Tokens revoked by ID alone have no owner to index them under
+	if userID != uuid.Nil {
+		userKey := userRevokedTokensKeyPrefix + userID.String()
+		pipe.SAdd(ctx, userKey, tokenID)
+		extendExpiry(ctx, pipe, userKey, expiresAt)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store token: %w", err)
+	}
+
+	return nil
+}
+
+// Exists checks if a token ID has been stored
+func (s *TokenStore) Exists(ctx context.Context, tokenID string) (bool, error) {
+	n, err := s.client.Exists(ctx, revokedTokenKeyPrefix+tokenID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// Delete removes a token ID
+func (s *TokenStore) Delete(ctx context.Context, tokenID string) error {
+	key := revokedTokenKeyPrefix + tokenID
+
+	owner, err := s.client.Get(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, key)
+	if owner != uuid.Nil.String() {
+		pipe.SRem(ctx, userRevokedTokensKeyPrefix+owner, tokenID)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAllForUser removes every token ID stored for a user
+func (s *TokenStore) DeleteAllForUser(ctx context.Context, userID uuid.UUID) error {
+	userKey := userRevokedTokensKeyPrefix + userID.String()
+
+	tokenIDs, err := s.client.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get user tokens: %w", err)
+	}
+
+	keys := []string{userKey}
+	for _, tokenID := range tokenIDs {
+		keys = append(keys, revokedTokenKeyPrefix+tokenID)
+	}
+
+	if err := s.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to delete user tokens: %w", err)
+	}
+
+	return nil
+}
+
+// SaveFamily creates or replaces a refresh token family
+func (s *TokenStore) SaveFamily(ctx context.Context, family *auth.TokenFamily) error {
+	key := tokenFamilyKeyPrefix + family.ID
+	userKey := userTokenFamiliesKeyPrefix + family.UserID.String()
+
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, familyFields(family))
+	pipe.PExpireAt(ctx, key, family.ExpiresAt)
+	pipe.SAdd(ctx, userKey, family.ID)
+	extendExpiry(ctx, pipe, userKey, family.ExpiresAt)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save token family: %w", err)
+	}
+
+	return nil
+}
+
+// GetFamily retrieves a refresh token family
+func (s *TokenStore) GetFamily(ctx context.Context, familyID string) (*auth.TokenFamily, error) {
+	fields, err := s.client.HGetAll(ctx, tokenFamilyKeyPrefix+familyID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token family: %w", err)
+	}
+
+	if len(fields) == 0 {
+		return nil, auth.ErrTokenFamilyNotFound
+	}
+
+	family, err := parseFamily(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token family: %w", err)
+	}
+
+	return family, nil
+}
+
+// RotateFamily atomically replaces the family's current refresh token ID
+func (s *TokenStore) RotateFamily(ctx context.Context, familyID, currentTokenID, nextTokenID string, expiresAt time.Time) error {
+	result, err := rotateFamilyScript.Run(ctx, s.client,
+		[]string{tokenFamilyKeyPrefix + familyID},
+		currentTokenID,
+		nextTokenID,
+		formatTime(time.Now()),
+		formatTime(expiresAt),
+		expiresAt.UnixMilli(),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("failed to rotate token family: %w", err)
+	}
+
+	switch result {
+	case -1:
+		return auth.ErrTokenFamilyNotFound
+	case 0:
+		return auth.ErrRefreshTokenReused
+	}
+
+	return nil
+}
+
+// RevokeFamily marks a refresh token family as revoked
+func (s *TokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	revoked, err := revokeFamilyScript.Run(ctx, s.client,
+		[]string{tokenFamilyKeyPrefix + familyID},
+		formatTime(time.Now()),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+
+	if revoked == 0 {
+		return auth.ErrTokenFamilyNotFound
+	}
+
+	return nil
+}
+
+// RevokeUserFamilies marks every refresh token family of a user as revoked
+func (s *TokenStore) RevokeUserFamilies(ctx context.Context, userID uuid.UUID) error {
+	userKey := userTokenFamiliesKeyPrefix + userID.String()
+
+	familyIDs, err := s.client.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get user token families: %w", err)
+	}
+
+	now := formatTime(time.Now())
+	for _, familyID := range familyIDs {
+		revoked, err := revokeFamilyScript.Run(ctx, s.client, []string{tokenFamilyKeyPrefix + familyID}, now).Int()
+		if err != nil {
+			return fmt.Errorf("failed to revoke token family: %w", err)
+		}
+
+		// Families that have expired are dropped from the index
+		if revoked == 0 {
+			s.client.SRem(ctx, userKey, familyID)
+		}
+	}
+
+	return nil
+}
+
+// extendExpiry pushes a per-user index's expiry out to cover an entry expiring at expiresAt.
+// GT leaves a later expiry alone; an index without one is given it.
+func extendExpiry(ctx context.Context, pipe redis.Pipeliner, key string, expiresAt time.Time) {
+	pipe.ExpireNX(ctx, key, time.Until(expiresAt))
+	pipe.ExpireGT(ctx, key, time.Until(expiresAt))
+}
+
+func familyFields(family *auth.TokenFamily) map[string]interface{} {
+	fields := map[string]interface{}{
+		"id":          family.ID,
+		"user_id":     family.UserID.String(),
+		"session_id":  family.SessionID,
+		"current_jti": family.CurrentJTI,
+		"client_id":   family.ClientID,
+		"scopes":      strings.Join(family.Scopes, " "),
+		"created_at":  formatTime(family.CreatedAt),
+		"expires_at":  formatTime(family.ExpiresAt),
+	}
+	if family.RotatedAt != nil {
+		fields["rotated_at"] = formatTime(*family.RotatedAt)
+	}
+	if family.RevokedAt != nil {
+		fields["revoked_at"] = formatTime(*family.RevokedAt)
+	}
+	if family.OrganizationID != nil {
+		fields["organization_id"] = family.OrganizationID.String()
+	}
+	return fields
+}
+
+func parseFamily(fields map[string]string) (*auth.TokenFamily, error) {
+	userID, err := uuid.Parse(fields["user_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	family := &auth.TokenFamily{
+		ID:         fields["id"],
+		UserID:     userID,
+		SessionID:  fields["session_id"],
+		CurrentJTI: fields["current_jti"],
+		ClientID:   fields["client_id"],
+		Scopes:     strings.Fields(fields["scopes"]),
+	}
+
+	if family.CreatedAt, err = time.Parse(time.RFC3339Nano, fields["created_at"]); err != nil {
+		return nil, err
+	}
+	if family.ExpiresAt, err = time.Parse(time.RFC3339Nano, fields["expires_at"]); err != nil {
+		return nil, err
+	}
+	if family.RotatedAt, err = parseOptionalTime(fields["rotated_at"]); err != nil {
+		return nil, err
+	}
+	if family.RevokedAt, err = parseOptionalTime(fields["revoked_at"]); err != nil {
+		return nil, err
+	}
+	if value := fields["organization_id"]; value != "" {
+		organizationID, err := uuid.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		family.OrganizationID = &organizationID
+	}
+
+	return family, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,188 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	redisImpl "github.com/victoralfred/um_sys/internal/infrastructure/redis"
+)
+
+func TestTokenStore_RevokedTokens(t *testing.T) {
+	store, client := setupTokenStore(t)
+	ctx := context.Background()
+
+	t.Run("stores a token until it expires", func(t *testing.T) {
+		tokenID := uuid.New().String()
+
+		require.NoError(t, store.Store(ctx, tokenID, uuid.New(), time.Now().Add(time.Hour)))
+
+		exists, err := store.Exists(ctx, tokenID)
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		ttl, err := client.TTL(ctx, "revoked_token:"+tokenID).Result()
+		require.NoError(t, err)
+		assert.True(t, ttl > 59*time.Minute && ttl <= time.Hour)
+	})
+
+	t.Run("skips tokens that have already expired", func(t *testing.T) {
+		tokenID := uuid.New().String()
+
+		require.NoError(t, store.Store(ctx, tokenID, uuid.New(), time.Now().Add(-time.Minute)))
+
+		exists, err := store.Exists(ctx, tokenID)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("deletes a token", func(t *testing.T) {
+		tokenID := uuid.New().String()
+		require.NoError(t, store.Store(ctx, tokenID, uuid.New(), time.Now().Add(time.Hour)))
+
+		require.NoError(t, store.Delete(ctx, tokenID))
+
+		exists, err := store.Exists(ctx, tokenID)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("deletes all tokens for a user", func(t *testing.T) {
+		userID := uuid.New()
+		otherTokenID := uuid.New().String()
+		tokenIDs := []string{uuid.New().String(), uuid.New().String()}
+		for _, tokenID := range tokenIDs {
+			require.NoError(t, store.Store(ctx, tokenID, userID, time.Now().Add(time.Hour)))
+		}
+		require.NoError(t, store.Store(ctx, otherTokenID, uuid.New(), time.Now().Add(time.Hour)))
+
+		require.NoError(t, store.DeleteAllForUser(ctx, userID))
+
+		for _, tokenID := range tokenIDs {
+			exists, err := store.Exists(ctx, tokenID)
+			require.NoError(t, err)
+			assert.False(t, exists)
+		}
+		exists, err := store.Exists(ctx, otherTokenID)
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+}
+
+func TestTokenStore_Families(t *testing.T) {
+	store, _ := setupTokenStore(t)
+	ctx := context.Background()
+
+	newFamily := func(userID uuid.UUID) *auth.TokenFamily {
+		return &auth.TokenFamily{
+			ID:         uuid.New().String(),
+			UserID:     userID,
+			CurrentJTI: uuid.New().String(),
+			ClientID:   "client-1",
+			Scopes:     []string{"openid", "offline_access"},
+			CreatedAt:  time.Now(),
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}
+	}
+
+	t.Run("saves and gets a family", func(t *testing.T) {
+		family := newFamily(uuid.New())
+		require.NoError(t, store.SaveFamily(ctx, family))
+
+		stored, err := store.GetFamily(ctx, family.ID)
+		require.NoError(t, err)
+		assert.Equal(t, family.UserID, stored.UserID)
+		assert.Equal(t, family.CurrentJTI, stored.CurrentJTI)
+		assert.Equal(t, family.Scopes, stored.Scopes)
+		assert.WithinDuration(t, family.ExpiresAt, stored.ExpiresAt, time.Millisecond)
+		assert.False(t, stored.IsRevoked())
+
+		_, err = store.GetFamily(ctx, uuid.New().String())
+		assert.ErrorIs(t, err, auth.ErrTokenFamilyNotFound)
+	})
+
+	t.Run("keeps the active organization", func(t *testing.T) {
+		family := newFamily(uuid.New())
+		organizationID := uuid.New()
+		family.OrganizationID = &organizationID
+		require.NoError(t, store.SaveFamily(ctx, family))
+
+		stored, err := store.GetFamily(ctx, family.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.OrganizationID)
+		assert.Equal(t, organizationID, *stored.OrganizationID)
+	})
+
+	t.Run("rotates only the current token", func(t *testing.T) {
+		family := newFamily(uuid.New())
+		require.NoError(t, store.SaveFamily(ctx, family))
+
+		next := uuid.New().String()
+		require.NoError(t, store.RotateFamily(ctx, family.ID, family.CurrentJTI, next, time.Now().Add(time.Hour)))
+
+		err := store.RotateFamily(ctx, family.ID, family.CurrentJTI, uuid.New().String(), time.Now().Add(time.Hour))
+		assert.ErrorIs(t, err, auth.ErrRefreshTokenReused)
+
+		stored, err := store.GetFamily(ctx, family.ID)
+		require.NoError(t, err)
+		assert.Equal(t, next, stored.CurrentJTI)
+		assert.NotNil(t, stored.RotatedAt)
+
+		err = store.RotateFamily(ctx, uuid.New().String(), family.CurrentJTI, next, time.Now().Add(time.Hour))
+		assert.ErrorIs(t, err, auth.ErrTokenFamilyNotFound)
+	})
+
+	t.Run("revokes a family", func(t *testing.T) {
+		family := newFamily(uuid.New())
+		require.NoError(t, store.SaveFamily(ctx, family))
+
+		require.NoError(t, store.RevokeFamily(ctx, family.ID))
+
+		stored, err := store.GetFamily(ctx, family.ID)
+		require.NoError(t, err)
+		assert.True(t, stored.IsRevoked())
+
+		assert.ErrorIs(t, store.RevokeFamily(ctx, uuid.New().String()), auth.ErrTokenFamilyNotFound)
+	})
+
+	t.Run("revokes every family of a user", func(t *testing.T) {
+		userID := uuid.New()
+		families := []*auth.TokenFamily{newFamily(userID), newFamily(userID)}
+		other := newFamily(uuid.New())
+		for _, family := range append(families, other) {
+			require.NoError(t, store.SaveFamily(ctx, family))
+		}
+
+		require.NoError(t, store.RevokeUserFamilies(ctx, userID))
+
+		for _, family := range families {
+			stored, err := store.GetFamily(ctx, family.ID)
+			require.NoError(t, err)
+			assert.True(t, stored.IsRevoked())
+		}
+		stored, err := store.GetFamily(ctx, other.ID)
+		require.NoError(t, err)
+		assert.False(t, stored.IsRevoked())
+	})
+}
+
+func setupTokenStore(t *testing.T) (*redisImpl.TokenStore, *redis.Client) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "localhost:6380",
+		DB:   2, // Use different DB for token store tests
+	})
+
+	// Clean up test data
+	t.Cleanup(func() {
+		ctx := context.Background()
+		client.FlushDB(ctx)
+		_ = client.Close()
+	})
+
+	return redisImpl.NewTokenStore(client), client
+}
@@ -0,0 +1,141 @@
+package saml
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// canonicalizer implements Exclusive XML Canonicalization 1.0 without comments
+// (https://www.w3.org/TR/xml-exc-c14n/). Comments never reach the element tree.
+type canonicalizer struct {
+	buf bytes.Buffer
+
+	// exclude is left out of the output; it is the enveloped signature being verified
+	exclude *element
+
+	// inclusive are the InclusiveNamespaces prefixes, rendered as inclusive canonicalization would
+	inclusive []string
+}
+
+// canonicalize returns the canonical form of the subtree rooted at e
+func canonicalize(e *element, exclude *element, inclusive []string) []byte {
+	c := &canonicalizer{exclude: exclude, inclusive: inclusive}
+	c.element(e, map[string]string{})
+	return c.buf.Bytes()
+}
+
+// element writes e given the namespaces already rendered by its output ancestors
+func (c *canonicalizer) element(e *element, rendered map[string]string) {
+	if e == c.exclude {
+		return
+	}
+
+	// An element renders the namespaces it visibly uses: its own prefix and those of its attributes
+	used := map[string]bool{e.prefix: true}
+	for _, a := range e.attrs {
+		if a.prefix != "" {
+			used[a.prefix] = true
+		}
+	}
+	for _, prefix := range c.inclusive {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		if e.declared(prefix) {
+			used[prefix] = true
+		}
+	}
+	delete(used, "xml")
+
+	var declarations []namespace
+	inScope := rendered
+	for prefix := range used {
+		uri, _ := e.lookup(prefix)
+		previous, ok := rendered[prefix]
+		if ok && previous == uri {
+			continue
+		}
+		// An empty default namespace only needs declaring to undo a rendered one
+		if !ok && prefix == "" && uri == "" {
+			continue
+		}
+		declarations = append(declarations, namespace{prefix: prefix, uri: uri})
+	}
+
+	if len(declarations) > 0 {
+		inScope = make(map[string]string, len(rendered)+len(declarations))
+		for prefix, uri := range rendered {
+			inScope[prefix] = uri
+		}
+		for _, ns := range declarations {
+			inScope[ns.prefix] = ns.uri
+		}
+		sort.Slice(declarations, func(i, j int) bool {
+			return declarations[i].prefix < declarations[j].prefix
+		})
+	}
+
+	attrs := append([]attribute(nil), e.attrs...)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].space != attrs[j].space {
+			return attrs[i].space < attrs[j].space
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	name := qualifiedName(e.prefix, e.local)
+	c.buf.WriteByte('<')
+	c.buf.WriteString(name)
+	for _, ns := range declarations {
+		if ns.prefix == "" {
+			c.buf.WriteString(` xmlns="`)
+		} else {
+			c.buf.WriteString(" xmlns:" + ns.prefix + `="`)
+		}
+		c.buf.WriteString(escapeAttr(ns.uri))
+		c.buf.WriteByte('"')
+	}
+	for _, a := range attrs {
+		c.buf.WriteString(" " + qualifiedName(a.prefix, a.local) + `="`)
+		c.buf.WriteString(escapeAttr(a.value))
+		c.buf.WriteByte('"')
+	}
+	c.buf.WriteByte('>')
+
+	for _, child := range e.children {
+		switch n := child.(type) {
+		case *element:
+			c.element(n, inScope)
+		case text:
+			c.buf.WriteString(escapeText(string(n)))
+		case procInst:
+			c.buf.WriteString("<?" + n.target)
+			if n.inst != "" {
+				c.buf.WriteString(" " + n.inst)
+			}
+			c.buf.WriteString("?>")
+		}
+	}
+
+	c.buf.WriteString("</" + name + ">")
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+
+var attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
@@ -0,0 +1,237 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// XML namespaces used by SAML 2.0 messages
+const (
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	nsDSig      = "http://www.w3.org/2000/09/xmldsig#"
+	nsExcC14N   = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	nsXML       = "http://www.w3.org/XML/1998/namespace"
+)
+
+// element is a node of the small document tree signatures are checked against.
+// encoding/xml resolves prefixes away while decoding into structs, but exclusive
+// canonicalization needs the prefixes and declarations exactly as they were written.
+type element struct {
+	prefix     string
+	local      string
+	space      string // Namespace URI the prefix resolves to
+	attrs      []attribute
+	namespaces []namespace // Declarations made on this element
+	children   []any       // *element, text or procInst
+	parent     *element
+}
+
+type attribute struct {
+	prefix string
+	local  string
+	space  string
+	value  string
+}
+
+type namespace struct {
+	prefix string // "" for the default namespace
+	uri    string
+}
+
+type text string
+
+type procInst struct {
+	target string
+	inst   string
+}
+
+// parseDocument reads an XML document into an element tree.
+// Document type declarations are refused so entity definitions never reach the tree.
+func parseDocument(data []byte) (*element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+
+	var root, current *element
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if current == nil && root != nil {
+				return nil, errors.New("document has more than one root element")
+			}
+			e, err := newElement(t, current)
+			if err != nil {
+				return nil, err
+			}
+			if current == nil {
+				root = e
+			} else {
+				current.children = append(current.children, e)
+			}
+			current = e
+
+		case xml.EndElement:
+			if current == nil || current.prefix != t.Name.Space || current.local != t.Name.Local {
+				return nil, fmt.Errorf("unexpected end element %q", t.Name.Local)
+			}
+			current = current.parent
+
+		case xml.CharData:
+			if current != nil {
+				current.children = append(current.children, text(t))
+			} else if len(bytes.TrimSpace(t)) > 0 {
+				return nil, errors.New("text outside the root element")
+			}
+
+		case xml.ProcInst:
+			if current != nil {
+				current.children = append(current.children, procInst{target: t.Target, inst: string(t.Inst)})
+			}
+
+		case xml.Directive:
+			return nil, errors.New("document type declarations are not allowed")
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("document is empty")
+	}
+	if current != nil {
+		return nil, fmt.Errorf("element %q is not closed", current.local)
+	}
+
+	return root, nil
+}
+
+func newElement(t xml.StartElement, parent *element) (*element, error) {
+	e := &element{
+		prefix: t.Name.Space,
+		local:  t.Name.Local,
+		parent: parent,
+	}
+
+	for _, a := range t.Attr {
+		switch {
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			e.namespaces = append(e.namespaces, namespace{uri: a.Value})
+		case a.Name.Space == "xmlns":
+			e.namespaces = append(e.namespaces, namespace{prefix: a.Name.Local, uri: a.Value})
+		default:
+			e.attrs = append(e.attrs, attribute{prefix: a.Name.Space, local: a.Name.Local, value: a.Value})
+		}
+	}
+
+	space, ok := e.lookup(e.prefix)
+	if !ok {
+		return nil, fmt.Errorf("undeclared namespace prefix %q", e.prefix)
+	}
+	e.space = space
+
+	seen := make(map[string]bool, len(e.attrs))
+	for i := range e.attrs {
+		a := &e.attrs[i]
+		// Unprefixed attributes are in no namespace, whatever the default namespace is
+		if a.prefix != "" {
+			if a.space, ok = e.lookup(a.prefix); !ok {
+				return nil, fmt.Errorf("undeclared namespace prefix %q", a.prefix)
+			}
+		}
+		key := a.space + " " + a.local
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate attribute %q", a.local)
+		}
+		seen[key] = true
+	}
+
+	return e, nil
+}
+
+// lookup resolves a namespace prefix in the scope of the element
+func (e *element) lookup(prefix string) (string, bool) {
+	for n := e; n != nil; n = n.parent {
+		for _, ns := range n.namespaces {
+			if ns.prefix == prefix {
+				return ns.uri, true
+			}
+		}
+	}
+
+	switch prefix {
+	case "":
+		return "", true
+	case "xml":
+		return nsXML, true
+	}
+	return "", false
+}
+
+// declared reports whether a prefix is bound by a declaration in scope of the element
+func (e *element) declared(prefix string) bool {
+	for n := e; n != nil; n = n.parent {
+		for _, ns := range n.namespaces {
+			if ns.prefix == prefix {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e *element) is(space, local string) bool {
+	return e.space == space && e.local == local
+}
+
+// child returns the first child element with the name
+func (e *element) child(space, local string) *element {
+	for _, c := range e.children {
+		if ce, ok := c.(*element); ok && ce.is(space, local) {
+			return ce
+		}
+	}
+	return nil
+}
+
+// childElements returns the child elements with the name
+func (e *element) childElements(space, local string) []*element {
+	var elements []*element
+	for _, c := range e.children {
+		if ce, ok := c.(*element); ok && ce.is(space, local) {
+			elements = append(elements, ce)
+		}
+	}
+	return elements
+}
+
+// attr returns the value of an unqualified attribute
+func (e *element) attr(local string) string {
+	for _, a := range e.attrs {
+		if a.space == "" && a.local == local {
+			return a.value
+		}
+	}
+	return ""
+}
+
+// text returns the element's character data, trimmed of surrounding whitespace
+func (e *element) text() string {
+	var b strings.Builder
+	for _, c := range e.children {
+		if t, ok := c.(text); ok {
+			b.WriteString(string(t))
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,191 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	// Register the digests signatures may use
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/victoralfred/um_sys/internal/domain/saml"
+)
+
+// XML Signature algorithms (https://www.w3.org/TR/xmldsig-core1/).
+// SHA-1 based algorithms are refused.
+const (
+	algExcC14N            = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	algEnvelopedSignature = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algRSASHA256          = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algRSASHA512          = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	algECDSASHA256        = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	algSHA256             = "http://www.w3.org/2001/04/xmlenc#sha256"
+	algSHA512             = "http://www.w3.org/2001/04/xmlenc#sha512"
+)
+
+// errUnsigned is returned by verifySignature when the element carries no signature
+var errUnsigned = errors.New("element is not signed")
+
+var digestMethods = map[string]crypto.Hash{
+	algSHA256: crypto.SHA256,
+	algSHA512: crypto.SHA512,
+}
+
+var signatureMethods = map[string]crypto.Hash{
+	algRSASHA256:   crypto.SHA256,
+	algRSASHA512:   crypto.SHA512,
+	algECDSASHA256: crypto.SHA256,
+}
+
+// verifySignature checks the enveloped signature of e with one of the trusted certificates.
+// The signature must be a child of e and reference e, and nothing else, by its ID, so the
+// element the caller goes on to read is exactly the element that was signed.
+func verifySignature(e *element, certificates []*x509.Certificate) error {
+	signatures := e.childElements(nsDSig, "Signature")
+	if len(signatures) == 0 {
+		return errUnsigned
+	}
+	if len(signatures) > 1 {
+		return fmt.Errorf("%w: element has more than one signature", saml.ErrInvalidSignature)
+	}
+	signature := signatures[0]
+
+	signedInfo := signature.child(nsDSig, "SignedInfo")
+	if signedInfo == nil {
+		return fmt.Errorf("%w: signature has no SignedInfo", saml.ErrInvalidSignature)
+	}
+
+	c14nMethod := signedInfo.child(nsDSig, "CanonicalizationMethod")
+	if c14nMethod == nil || c14nMethod.attr("Algorithm") != algExcC14N {
+		return fmt.Errorf("%w: unsupported canonicalization method", saml.ErrInvalidSignature)
+	}
+
+	signatureMethod := signedInfo.child(nsDSig, "SignatureMethod")
+	if signatureMethod == nil {
+		return fmt.Errorf("%w: signature has no SignatureMethod", saml.ErrInvalidSignature)
+	}
+	algorithm := signatureMethod.attr("Algorithm")
+	hash, ok := signatureMethods[algorithm]
+	if !ok {
+		return fmt.Errorf("%w: unsupported signature method %q", saml.ErrInvalidSignature, algorithm)
+	}
+
+	if err := verifyReference(e, signature, signedInfo); err != nil {
+		return err
+	}
+
+	signatureValue, err := decodeBase64(signature.child(nsDSig, "SignatureValue"))
+	if err != nil {
+		return fmt.Errorf("%w: malformed SignatureValue", saml.ErrInvalidSignature)
+	}
+
+	h := hash.New()
+	h.Write(canonicalize(signedInfo, nil, inclusivePrefixes(c14nMethod)))
+	digest := h.Sum(nil)
+
+	for _, certificate := range certificates {
+		if verifyWithKey(certificate.PublicKey, algorithm, hash, digest, signatureValue) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: signature does not match a trusted certificate", saml.ErrInvalidSignature)
+}
+
+// verifyReference checks that SignedInfo references e alone and that the digest of e matches
+func verifyReference(e, signature, signedInfo *element) error {
+	references := signedInfo.childElements(nsDSig, "Reference")
+	if len(references) != 1 {
+		return fmt.Errorf("%w: signature must have exactly one reference", saml.ErrInvalidSignature)
+	}
+	reference := references[0]
+
+	id := e.attr("ID")
+	if id == "" || reference.attr("URI") != "#"+id {
+		return fmt.Errorf("%w: signature does not reference the signed element", saml.ErrInvalidSignature)
+	}
+
+	var inclusive []string
+	canonicalized := false
+	if transforms := reference.child(nsDSig, "Transforms"); transforms != nil {
+		for _, transform := range transforms.childElements(nsDSig, "Transform") {
+			switch transform.attr("Algorithm") {
+			case algEnvelopedSignature:
+			case algExcC14N:
+				canonicalized = true
+				inclusive = inclusivePrefixes(transform)
+			default:
+				return fmt.Errorf("%w: unsupported transform %q", saml.ErrInvalidSignature, transform.attr("Algorithm"))
+			}
+		}
+	}
+	if !canonicalized {
+		return fmt.Errorf("%w: reference is not canonicalized with exclusive c14n", saml.ErrInvalidSignature)
+	}
+
+	digestMethod := reference.child(nsDSig, "DigestMethod")
+	if digestMethod == nil {
+		return fmt.Errorf("%w: reference has no DigestMethod", saml.ErrInvalidSignature)
+	}
+	hash, ok := digestMethods[digestMethod.attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("%w: unsupported digest method %q", saml.ErrInvalidSignature, digestMethod.attr("Algorithm"))
+	}
+
+	expected, err := decodeBase64(reference.child(nsDSig, "DigestValue"))
+	if err != nil {
+		return fmt.Errorf("%w: malformed DigestValue", saml.ErrInvalidSignature)
+	}
+
+	h := hash.New()
+	h.Write(canonicalize(e, signature, inclusive))
+	if subtle.ConstantTimeCompare(h.Sum(nil), expected) != 1 {
+		return fmt.Errorf("%w: digest does not match the signed content", saml.ErrInvalidSignature)
+	}
+
+	return nil
+}
+
+// inclusivePrefixes reads the PrefixList of an InclusiveNamespaces parameter
+func inclusivePrefixes(method *element) []string {
+	if params := method.child(nsExcC14N, "InclusiveNamespaces"); params != nil {
+		return strings.Fields(params.attr("PrefixList"))
+	}
+	return nil
+}
+
+func verifyWithKey(publicKey any, algorithm string, hash crypto.Hash, digest, signature []byte) bool {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if algorithm == algECDSASHA256 {
+			return false
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		if algorithm != algECDSASHA256 || len(signature)%2 != 0 {
+			return false
+		}
+		// XML Signature encodes ECDSA signatures as the concatenation r || s
+		half := len(signature) / 2
+		r := new(big.Int).SetBytes(signature[:half])
+		s := new(big.Int).SetBytes(signature[half:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// decodeBase64 decodes base64 element content, which may be wrapped over several lines
+func decodeBase64(e *element) ([]byte, error) {
+	if e == nil {
+		return nil, errors.New("element is missing")
+	}
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(e.text()), ""))
+}
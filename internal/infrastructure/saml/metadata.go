@@ -0,0 +1,116 @@
+package saml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/victoralfred/um_sys/internal/domain/saml"
+)
+
+// ParseMetadata reads the entity ID, HTTP-Redirect sign-in URL and signing
+// certificates from an identity provider's metadata document
+func (p *Protocol) ParseMetadata(data []byte) (*saml.IdPMetadata, error) {
+	root, err := parseDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", saml.ErrInvalidMetadata, err)
+	}
+
+	descriptor := root
+	if root.is(nsMetadata, "EntitiesDescriptor") {
+		var idps []*element
+		for _, entity := range root.childElements(nsMetadata, "EntityDescriptor") {
+			if entity.child(nsMetadata, "IDPSSODescriptor") != nil {
+				idps = append(idps, entity)
+			}
+		}
+		if len(idps) != 1 {
+			return nil, fmt.Errorf("%w: expected one identity provider, found %d", saml.ErrInvalidMetadata, len(idps))
+		}
+		descriptor = idps[0]
+	}
+	if !descriptor.is(nsMetadata, "EntityDescriptor") {
+		return nil, fmt.Errorf("%w: document is not an EntityDescriptor", saml.ErrInvalidMetadata)
+	}
+
+	metadata := &saml.IdPMetadata{EntityID: descriptor.attr("entityID")}
+	if metadata.EntityID == "" {
+		return nil, fmt.Errorf("%w: entityID is missing", saml.ErrInvalidMetadata)
+	}
+
+	idp := descriptor.child(nsMetadata, "IDPSSODescriptor")
+	if idp == nil || !strings.Contains(idp.attr("protocolSupportEnumeration"), nsProtocol) {
+		return nil, fmt.Errorf("%w: entity is not a SAML 2.0 identity provider", saml.ErrInvalidMetadata)
+	}
+
+	for _, sso := range idp.childElements(nsMetadata, "SingleSignOnService") {
+		if sso.attr("Binding") == bindingHTTPRedirect {
+			metadata.SSOURL = sso.attr("Location")
+			break
+		}
+	}
+	if metadata.SSOURL == "" {
+		return nil, fmt.Errorf("%w: no HTTP-Redirect SingleSignOnService", saml.ErrInvalidMetadata)
+	}
+
+	for _, key := range idp.childElements(nsMetadata, "KeyDescriptor") {
+		// Keys without a use are for both signing and encryption
+		if use := key.attr("use"); use != "" && use != "signing" {
+			continue
+		}
+		keyInfo := key.child(nsDSig, "KeyInfo")
+		if keyInfo == nil {
+			continue
+		}
+		for _, data := range keyInfo.childElements(nsDSig, "X509Data") {
+			for _, certificate := range data.childElements(nsDSig, "X509Certificate") {
+				metadata.Certificates = append(metadata.Certificates, strings.Join(strings.Fields(certificate.text()), ""))
+			}
+		}
+	}
+	if len(metadata.Certificates) == 0 {
+		return nil, fmt.Errorf("%w: no signing certificate", saml.ErrInvalidMetadata)
+	}
+	if _, err := parseCertificates(metadata.Certificates); err != nil {
+		return nil, fmt.Errorf("%w: invalid signing certificate: %v", saml.ErrInvalidMetadata, err)
+	}
+
+	return metadata, nil
+}
+
+// spEntityDescriptor is the service provider metadata document
+type spEntityDescriptor struct {
+	XMLName         xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityID        string   `xml:"entityID,attr"`
+	SPSSODescriptor struct {
+		AuthnRequestsSigned        bool   `xml:"AuthnRequestsSigned,attr"`
+		WantAssertionsSigned       bool   `xml:"WantAssertionsSigned,attr"`
+		ProtocolSupportEnumeration string `xml:"protocolSupportEnumeration,attr"`
+		NameIDFormat               string `xml:"NameIDFormat"`
+		AssertionConsumerService   struct {
+			Binding   string `xml:"Binding,attr"`
+			Location  string `xml:"Location,attr"`
+			Index     int    `xml:"index,attr"`
+			IsDefault bool   `xml:"isDefault,attr"`
+		} `xml:"AssertionConsumerService"`
+	} `xml:"SPSSODescriptor"`
+}
+
+// Metadata returns the service provider's entity descriptor. Requests are not
+// signed; responses must be, and are received with the HTTP-POST binding.
+func (p *Protocol) Metadata(sp saml.ServiceProvider) ([]byte, error) {
+	var descriptor spEntityDescriptor
+	descriptor.EntityID = sp.EntityID
+	descriptor.SPSSODescriptor.WantAssertionsSigned = true
+	descriptor.SPSSODescriptor.ProtocolSupportEnumeration = nsProtocol
+	descriptor.SPSSODescriptor.NameIDFormat = nameIDFormatEmail
+	descriptor.SPSSODescriptor.AssertionConsumerService.Binding = bindingHTTPPost
+	descriptor.SPSSODescriptor.AssertionConsumerService.Location = sp.ACSURL
+	descriptor.SPSSODescriptor.AssertionConsumerService.IsDefault = true
+
+	data, err := xml.MarshalIndent(descriptor, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
@@ -0,0 +1,71 @@
+// Package saml implements the SAML 2.0 Web Browser SSO profile for a service provider:
+// IdP metadata, AuthnRequests with the HTTP-Redirect binding and signed responses
+// received with the HTTP-POST binding.
+package saml
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SAML bindings, name ID formats and status codes
+const (
+	bindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	bindingHTTPPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	nameIDFormatEmail   = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	confirmationBearer  = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	statusSuccess       = "urn:oasis:names:tc:SAML:2.0:status:Success"
+)
+
+const (
+	// defaultClockSkew is the clock difference tolerated between us and an identity provider
+	defaultClockSkew = 2 * time.Minute
+
+	// maxResponseSize caps the size of a decoded response
+	maxResponseSize = 1 << 20
+)
+
+// Protocol implements saml.Protocol
+type Protocol struct {
+	clockSkew time.Duration
+}
+
+// NewProtocol creates a protocol that tolerates the given clock skew when checking
+// validity windows; zero uses a two minute default
+func NewProtocol(clockSkew time.Duration) *Protocol {
+	if clockSkew == 0 {
+		clockSkew = defaultClockSkew
+	}
+	return &Protocol{clockSkew: clockSkew}
+}
+
+// newID returns a random message ID; IDs must be valid XML names so they cannot start with a digit
+func newID() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate ID: %w", err)
+	}
+	return "_" + hex.EncodeToString(b), nil
+}
+
+// parseCertificates decodes base64 DER certificates
+func parseCertificates(encoded []string) ([]*x509.Certificate, error) {
+	certificates := make([]*x509.Certificate, 0, len(encoded))
+	for _, e := range encoded {
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(e), ""))
+		if err != nil {
+			return nil, err
+		}
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
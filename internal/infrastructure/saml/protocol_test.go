@@ -0,0 +1,261 @@
+package saml_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/saml"
+	samlImpl "github.com/victoralfred/um_sys/internal/infrastructure/saml"
+	"github.com/victoralfred/um_sys/internal/infrastructure/saml/samltest"
+)
+
+var testSP = saml.ServiceProvider{
+	EntityID: "https://app.example.com/v1/auth/saml/org/metadata",
+	ACSURL:   "https://app.example.com/v1/auth/saml/org/acs",
+}
+
+func newIdP(t *testing.T) *samltest.IdentityProvider {
+	t.Helper()
+	idp, err := samltest.NewIdentityProvider("https://idp.example.com")
+	require.NoError(t, err)
+	return idp
+}
+
+func expectations(idp *samltest.IdentityProvider, inResponseTo string) *saml.Expectations {
+	return &saml.Expectations{
+		ServiceProvider: testSP,
+		IdPEntityID:     idp.EntityID,
+		Certificates:    []string{idp.Certificate()},
+		InResponseTo:    inResponseTo,
+		Now:             time.Now(),
+	}
+}
+
+func TestProtocol_ParseMetadata(t *testing.T) {
+	protocol := samlImpl.NewProtocol(0)
+	idp := newIdP(t)
+
+	t.Run("reads the entity, redirect endpoint and signing certificate", func(t *testing.T) {
+		metadata, err := protocol.ParseMetadata(idp.Metadata())
+		require.NoError(t, err)
+		assert.Equal(t, idp.EntityID, metadata.EntityID)
+		assert.Equal(t, idp.SSOURL, metadata.SSOURL)
+		assert.Equal(t, []string{idp.Certificate()}, metadata.Certificates)
+	})
+
+	tests := []struct {
+		name     string
+		metadata string
+	}{
+		{"not XML", "certainly not metadata"},
+		{"document type declaration", `<!DOCTYPE x [<!ENTITY a "b">]><x/>`},
+		{"no signing certificate", strings.Replace(string(idp.Metadata()), idp.Certificate(), "", 1)},
+		{"no redirect binding", strings.Replace(string(idp.Metadata()), "bindings:HTTP-Redirect", "bindings:SOAP", 1)},
+	}
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			_, err := protocol.ParseMetadata([]byte(tt.metadata))
+			assert.ErrorIs(t, err, saml.ErrInvalidMetadata)
+		})
+	}
+}
+
+func TestProtocol_NewAuthnRequest(t *testing.T) {
+	protocol := samlImpl.NewProtocol(0)
+	idp := newIdP(t)
+
+	request, err := protocol.NewAuthnRequest(testSP, &saml.Connection{SSOURL: idp.SSOURL}, "relay-123")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(request.URL, idp.SSOURL+"?"))
+
+	received, err := idp.ReadAuthnRequest(request.URL)
+	require.NoError(t, err)
+	assert.Equal(t, request.ID, received.ID)
+	assert.Equal(t, testSP.ACSURL, received.ACSURL)
+	assert.Equal(t, testSP.EntityID, received.Issuer)
+	assert.Equal(t, "relay-123", received.RelayState)
+}
+
+func TestProtocol_VerifyResponse(t *testing.T) {
+	protocol := samlImpl.NewProtocol(0)
+	idp := newIdP(t)
+
+	options := func() samltest.ResponseOptions {
+		return samltest.ResponseOptions{
+			ServiceProvider: testSP,
+			NameID:          "jane@acme.com",
+			Attributes: map[string][]string{
+				"email":  {"jane@acme.com"},
+				"groups": {"Engineering", "R&D <West>"},
+			},
+		}
+	}
+
+	t.Run("accepts a signed assertion", func(t *testing.T) {
+		encoded, err := idp.Response(options())
+		require.NoError(t, err)
+
+		assertion, err := protocol.VerifyResponse(encoded, expectations(idp, ""))
+		require.NoError(t, err)
+		assert.Equal(t, "jane@acme.com", assertion.NameID)
+		assert.Equal(t, idp.EntityID, assertion.Issuer)
+		assert.Equal(t, []string{"Engineering", "R&D <West>"}, assertion.Attributes["groups"])
+		assert.Equal(t, "jane@acme.com", assertion.Attribute("email"))
+		assert.NotEmpty(t, assertion.ID)
+		assert.True(t, assertion.NotOnOrAfter.After(time.Now()))
+	})
+
+	t.Run("accepts a signed response around an unsigned assertion", func(t *testing.T) {
+		opts := options()
+		opts.UnsignedAssertion = true
+		opts.SignResponse = true
+		encoded, err := idp.Response(opts)
+		require.NoError(t, err)
+
+		_, err = protocol.VerifyResponse(encoded, expectations(idp, ""))
+		assert.NoError(t, err)
+	})
+
+	t.Run("accepts a signed response around a signed assertion", func(t *testing.T) {
+		opts := options()
+		opts.SignResponse = true
+		encoded, err := idp.Response(opts)
+		require.NoError(t, err)
+
+		_, err = protocol.VerifyResponse(encoded, expectations(idp, ""))
+		assert.NoError(t, err)
+	})
+
+	t.Run("accepts a response serialized differently from its canonical form", func(t *testing.T) {
+		document, err := idp.ResponseXML(options())
+		require.NoError(t, err)
+
+		// Redundant declarations, attribute order, empty element tags and quoting do not change the signed content
+		document = strings.Replace(document, "<saml:Subject>", `<saml:Subject xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">`, 1)
+		document = strings.Replace(document, "></saml:SubjectConfirmationData>", "/>", 1)
+		document = strings.Replace(document, `Method="urn:oasis:names:tc:SAML:2.0:cm:bearer"`, `Method='urn:oasis:names:tc:SAML:2.0:cm:bearer'`, 1)
+		start := strings.Index(document, "<saml:Conditions ")
+		end := start + strings.Index(document[start:], ">")
+		conditions := strings.Fields(document[start:end])
+		document = document[:start] + strings.Join([]string{conditions[0], conditions[2], conditions[1]}, " ") + document[end:]
+
+		_, err = protocol.VerifyResponse(samltest.Encode(document), expectations(idp, ""))
+		assert.NoError(t, err)
+	})
+
+	t.Run("matches SP-initiated responses to their request", func(t *testing.T) {
+		opts := options()
+		opts.InResponseTo = "_request-1"
+		encoded, err := idp.Response(opts)
+		require.NoError(t, err)
+
+		assertion, err := protocol.VerifyResponse(encoded, expectations(idp, "_request-1"))
+		require.NoError(t, err)
+		assert.Equal(t, "_request-1", assertion.InResponseTo)
+
+		_, err = protocol.VerifyResponse(encoded, expectations(idp, "_request-2"))
+		assert.ErrorIs(t, err, saml.ErrRequestMismatch)
+
+		// A response answering a request cannot be replayed as IdP-initiated, nor the other way round
+		_, err = protocol.VerifyResponse(encoded, expectations(idp, ""))
+		assert.ErrorIs(t, err, saml.ErrRequestMismatch)
+
+		unsolicited, err := idp.Response(options())
+		require.NoError(t, err)
+		_, err = protocol.VerifyResponse(unsolicited, expectations(idp, "_request-1"))
+		assert.ErrorIs(t, err, saml.ErrRequestMismatch)
+	})
+
+	t.Run("rejects tampered assertions", func(t *testing.T) {
+		document, err := idp.ResponseXML(options())
+		require.NoError(t, err)
+
+		tampered := strings.Replace(document, ">jane@acme.com</saml:NameID>", ">admin@acme.com</saml:NameID>", 1)
+		_, err = protocol.VerifyResponse(samltest.Encode(tampered), expectations(idp, ""))
+		assert.ErrorIs(t, err, saml.ErrInvalidSignature)
+	})
+
+	t.Run("rejects unsigned responses", func(t *testing.T) {
+		opts := options()
+		opts.UnsignedAssertion = true
+		encoded, err := idp.Response(opts)
+		require.NoError(t, err)
+
+		_, err = protocol.VerifyResponse(encoded, expectations(idp, ""))
+		assert.ErrorIs(t, err, saml.ErrInvalidSignature)
+	})
+
+	t.Run("rejects responses signed by another identity provider", func(t *testing.T) {
+		impostor, err := samltest.NewIdentityProvider(idp.EntityID)
+		require.NoError(t, err)
+		encoded, err := impostor.Response(options())
+		require.NoError(t, err)
+
+		_, err = protocol.VerifyResponse(encoded, expectations(idp, ""))
+		assert.ErrorIs(t, err, saml.ErrInvalidSignature)
+	})
+
+	t.Run("rejects a forged assertion wrapped around a signed one", func(t *testing.T) {
+		document, err := idp.ResponseXML(options())
+		require.NoError(t, err)
+
+		start := strings.Index(document, "<saml:Assertion ")
+		end := strings.Index(document, "</saml:Assertion>") + len("</saml:Assertion>")
+		signed := document[start:end]
+
+		// The forged assertion hides the signed one inside itself, keeping it intact
+		forged := strings.Replace(signed, ">jane@acme.com</saml:NameID>", ">admin@acme.com</saml:NameID>", 1)
+		forged = strings.Replace(forged, "<saml:Issuer>", "<saml:Advice>"+signed+"</saml:Advice><saml:Issuer>", 1)
+		wrapped := document[:start] + forged + document[end:]
+
+		_, err = protocol.VerifyResponse(samltest.Encode(wrapped), expectations(idp, ""))
+		assert.ErrorIs(t, err, saml.ErrInvalidSignature)
+
+		// So does a second assertion alongside the signed one
+		doubled := document[:start] + forged + signed + document[end:]
+		_, err = protocol.VerifyResponse(samltest.Encode(doubled), expectations(idp, ""))
+		assert.ErrorIs(t, err, saml.ErrInvalidResponse)
+	})
+
+	t.Run("rejects expired assertions", func(t *testing.T) {
+		opts := options()
+		opts.IssuedAt = time.Now().Add(-time.Hour)
+		encoded, err := idp.Response(opts)
+		require.NoError(t, err)
+
+		_, err = protocol.VerifyResponse(encoded, expectations(idp, ""))
+		assert.ErrorIs(t, err, saml.ErrAssertionExpired)
+	})
+
+	t.Run("rejects assertions for another service provider", func(t *testing.T) {
+		opts := options()
+		opts.ServiceProvider.EntityID = "https://other.example.com"
+		encoded, err := idp.Response(opts)
+		require.NoError(t, err)
+
+		_, err = protocol.VerifyResponse(encoded, expectations(idp, ""))
+		assert.ErrorIs(t, err, saml.ErrAudienceMismatch)
+
+		opts = options()
+		opts.ServiceProvider.ACSURL = "https://other.example.com/acs"
+		encoded, err = idp.Response(opts)
+		require.NoError(t, err)
+
+		_, err = protocol.VerifyResponse(encoded, expectations(idp, ""))
+		assert.ErrorIs(t, err, saml.ErrInvalidResponse)
+	})
+
+	t.Run("reports failed sign-ins", func(t *testing.T) {
+		opts := options()
+		opts.Status = samltest.StatusAuthnFailed
+		encoded, err := idp.Response(opts)
+		require.NoError(t, err)
+
+		_, err = protocol.VerifyResponse(encoded, expectations(idp, ""))
+		assert.ErrorIs(t, err, saml.ErrAuthenticationFailed)
+	})
+}
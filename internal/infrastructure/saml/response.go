@@ -0,0 +1,253 @@
+package saml
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/victoralfred/um_sys/internal/domain/saml"
+)
+
+// VerifyResponse checks a base64 encoded response received with the HTTP-POST binding
+// and returns its assertion. The response or its assertion must carry a valid signature
+// from one of the connection's certificates, and the assertion must meet the Web Browser
+// SSO profile rules (SAML profiles section 4.1.4.3): addressed to us, issued by the
+// expected identity provider, within its validity window and answering our request.
+func (p *Protocol) VerifyResponse(encoded string, expect *saml.Expectations) (*saml.Assertion, error) {
+	if base64.StdEncoding.DecodedLen(len(encoded)) > maxResponseSize {
+		return nil, fmt.Errorf("%w: response is too large", saml.ErrInvalidResponse)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: response is not base64 encoded", saml.ErrInvalidResponse)
+	}
+
+	response, err := parseDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", saml.ErrInvalidResponse, err)
+	}
+	if !response.is(nsProtocol, "Response") || response.attr("Version") != "2.0" {
+		return nil, fmt.Errorf("%w: document is not a SAML 2.0 Response", saml.ErrInvalidResponse)
+	}
+
+	// Identity providers often send failures unsigned and without an assertion
+	status := response.child(nsProtocol, "Status")
+	if status == nil {
+		return nil, fmt.Errorf("%w: response has no status", saml.ErrInvalidResponse)
+	}
+	if statusCode := status.child(nsProtocol, "StatusCode"); statusCode == nil || statusCode.attr("Value") != statusSuccess {
+		code := ""
+		if statusCode != nil {
+			code = statusCode.attr("Value")
+		}
+		return nil, fmt.Errorf("%w: status %q", saml.ErrAuthenticationFailed, code)
+	}
+
+	if response.child(nsAssertion, "EncryptedAssertion") != nil {
+		return nil, fmt.Errorf("%w: encrypted assertions are not supported", saml.ErrInvalidResponse)
+	}
+	assertions := response.childElements(nsAssertion, "Assertion")
+	if len(assertions) != 1 {
+		return nil, fmt.Errorf("%w: response must contain exactly one assertion", saml.ErrInvalidResponse)
+	}
+	assertion := assertions[0]
+
+	certificates, err := parseCertificates(expect.Certificates)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection certificate: %w", err)
+	}
+
+	// Either signature is enough as both cover the assertion, but one that is present must verify
+	responseErr := verifySignature(response, certificates)
+	if responseErr != nil && !errors.Is(responseErr, errUnsigned) {
+		return nil, responseErr
+	}
+	assertionErr := verifySignature(assertion, certificates)
+	if assertionErr != nil && !errors.Is(assertionErr, errUnsigned) {
+		return nil, assertionErr
+	}
+	if responseErr != nil && assertionErr != nil {
+		return nil, fmt.Errorf("%w: neither the response nor the assertion is signed", saml.ErrInvalidSignature)
+	}
+
+	if destination := response.attr("Destination"); destination != "" && destination != expect.ServiceProvider.ACSURL {
+		return nil, fmt.Errorf("%w: response is addressed to %q", saml.ErrInvalidResponse, destination)
+	}
+	if issuer := response.child(nsAssertion, "Issuer"); issuer != nil && issuer.text() != expect.IdPEntityID {
+		return nil, fmt.Errorf("%w: response issued by %q", saml.ErrInvalidResponse, issuer.text())
+	}
+	if response.attr("InResponseTo") != expect.InResponseTo {
+		return nil, saml.ErrRequestMismatch
+	}
+
+	return p.readAssertion(assertion, expect)
+}
+
+// readAssertion checks the conditions of a verified assertion and reads its content
+func (p *Protocol) readAssertion(assertion *element, expect *saml.Expectations) (*saml.Assertion, error) {
+	now := expect.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	result := &saml.Assertion{
+		ID:         assertion.attr("ID"),
+		Attributes: make(map[string][]string),
+	}
+	if result.ID == "" || assertion.attr("Version") != "2.0" {
+		return nil, fmt.Errorf("%w: assertion is not a SAML 2.0 assertion", saml.ErrInvalidResponse)
+	}
+
+	if issuer := assertion.child(nsAssertion, "Issuer"); issuer != nil {
+		result.Issuer = issuer.text()
+	}
+	if result.Issuer != expect.IdPEntityID {
+		return nil, fmt.Errorf("%w: assertion issued by %q", saml.ErrInvalidResponse, result.Issuer)
+	}
+
+	subject := assertion.child(nsAssertion, "Subject")
+	if subject == nil {
+		return nil, fmt.Errorf("%w: assertion has no subject", saml.ErrInvalidResponse)
+	}
+	if nameID := subject.child(nsAssertion, "NameID"); nameID != nil {
+		result.NameID = nameID.text()
+		result.NameIDFormat = nameID.attr("Format")
+	}
+	if result.NameID == "" {
+		return nil, fmt.Errorf("%w: assertion has no NameID", saml.ErrInvalidResponse)
+	}
+
+	notOnOrAfter, err := p.confirmBearer(subject, expect, now)
+	if err != nil {
+		return nil, err
+	}
+	result.NotOnOrAfter = notOnOrAfter
+	result.InResponseTo = expect.InResponseTo
+
+	conditions := assertion.child(nsAssertion, "Conditions")
+	if conditions == nil {
+		return nil, fmt.Errorf("%w: assertion has no conditions", saml.ErrInvalidResponse)
+	}
+	if value := conditions.attr("NotBefore"); value != "" {
+		notBefore, err := parseTime(value)
+		if err != nil {
+			return nil, err
+		}
+		if now.Add(p.clockSkew).Before(notBefore) {
+			return nil, fmt.Errorf("%w: assertion is not valid before %s", saml.ErrAssertionExpired, value)
+		}
+	}
+	if value := conditions.attr("NotOnOrAfter"); value != "" {
+		expiry, err := parseTime(value)
+		if err != nil {
+			return nil, err
+		}
+		if !now.Before(expiry.Add(p.clockSkew)) {
+			return nil, fmt.Errorf("%w: assertion expired at %s", saml.ErrAssertionExpired, value)
+		}
+		if expiry.Add(p.clockSkew).Before(result.NotOnOrAfter) {
+			result.NotOnOrAfter = expiry.Add(p.clockSkew)
+		}
+	}
+
+	// Every audience restriction must name us (SAML core section 2.5.1.4)
+	restrictions := conditions.childElements(nsAssertion, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return nil, fmt.Errorf("%w: assertion has no audience restriction", saml.ErrAudienceMismatch)
+	}
+	for _, restriction := range restrictions {
+		matched := false
+		for _, audience := range restriction.childElements(nsAssertion, "Audience") {
+			if audience.text() == expect.ServiceProvider.EntityID {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, saml.ErrAudienceMismatch
+		}
+	}
+
+	statement := assertion.child(nsAssertion, "AuthnStatement")
+	if statement == nil {
+		return nil, fmt.Errorf("%w: assertion has no AuthnStatement", saml.ErrInvalidResponse)
+	}
+	if result.AuthnInstant, err = parseTime(statement.attr("AuthnInstant")); err != nil {
+		return nil, err
+	}
+	result.SessionIndex = statement.attr("SessionIndex")
+	if value := statement.attr("SessionNotOnOrAfter"); value != "" {
+		sessionExpiry, err := parseTime(value)
+		if err != nil {
+			return nil, err
+		}
+		if !now.Before(sessionExpiry.Add(p.clockSkew)) {
+			return nil, fmt.Errorf("%w: identity provider session ended at %s", saml.ErrAssertionExpired, value)
+		}
+	}
+
+	for _, attributeStatement := range assertion.childElements(nsAssertion, "AttributeStatement") {
+		for _, attribute := range attributeStatement.childElements(nsAssertion, "Attribute") {
+			var values []string
+			for _, value := range attribute.childElements(nsAssertion, "AttributeValue") {
+				values = append(values, value.text())
+			}
+			name := attribute.attr("Name")
+			result.Attributes[name] = append(result.Attributes[name], values...)
+			// Mappings may also use the shorter friendly name
+			if friendly := attribute.attr("FriendlyName"); friendly != "" && friendly != name {
+				result.Attributes[friendly] = append(result.Attributes[friendly], values...)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// confirmBearer finds a bearer subject confirmation the assertion can be used under
+// and returns when it stops being accepted
+func (p *Protocol) confirmBearer(subject *element, expect *saml.Expectations, now time.Time) (time.Time, error) {
+	err := fmt.Errorf("%w: assertion has no bearer subject confirmation", saml.ErrInvalidResponse)
+
+	for _, confirmation := range subject.childElements(nsAssertion, "SubjectConfirmation") {
+		if confirmation.attr("Method") != confirmationBearer {
+			continue
+		}
+		data := confirmation.child(nsAssertion, "SubjectConfirmationData")
+		if data == nil {
+			continue
+		}
+
+		if data.attr("Recipient") != expect.ServiceProvider.ACSURL {
+			err = fmt.Errorf("%w: assertion is addressed to %q", saml.ErrInvalidResponse, data.attr("Recipient"))
+			continue
+		}
+		if data.attr("InResponseTo") != expect.InResponseTo {
+			err = saml.ErrRequestMismatch
+			continue
+		}
+		notOnOrAfter, parseErr := parseTime(data.attr("NotOnOrAfter"))
+		if parseErr != nil {
+			err = parseErr
+			continue
+		}
+		if !now.Before(notOnOrAfter.Add(p.clockSkew)) {
+			err = fmt.Errorf("%w: subject confirmation expired at %s", saml.ErrAssertionExpired, data.attr("NotOnOrAfter"))
+			continue
+		}
+
+		return notOnOrAfter.Add(p.clockSkew), nil
+	}
+
+	return time.Time{}, err
+}
+
+func parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid timestamp %q", saml.ErrInvalidResponse, value)
+	}
+	return t, nil
+}
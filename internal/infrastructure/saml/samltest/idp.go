@@ -0,0 +1,300 @@
+// Package samltest provides a SAML 2.0 identity provider for tests. It signs
+// responses with a locally generated key and self-signed certificate.
+package samltest
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/victoralfred/um_sys/internal/domain/saml"
+)
+
+const (
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsDSig      = "http://www.w3.org/2000/09/xmldsig#"
+	nsExcC14N   = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	nsXS        = "http://www.w3.org/2001/XMLSchema"
+	nsXSI       = "http://www.w3.org/2001/XMLSchema-instance"
+
+	// StatusSuccess and StatusAuthnFailed are response status codes
+	StatusSuccess     = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	StatusAuthnFailed = "urn:oasis:names:tc:SAML:2.0:status:AuthnFailed"
+)
+
+// IdentityProvider issues signed SAML responses
+type IdentityProvider struct {
+	EntityID string
+	SSOURL   string
+
+	key         *rsa.PrivateKey
+	certificate []byte
+}
+
+// NewIdentityProvider creates an identity provider with a fresh RSA key and self-signed certificate
+func NewIdentityProvider(entityID string) (*IdentityProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "samltest"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IdentityProvider{
+		EntityID:    entityID,
+		SSOURL:      strings.TrimSuffix(entityID, "/") + "/sso",
+		key:         key,
+		certificate: certificate,
+	}, nil
+}
+
+// Certificate returns the base64 DER signing certificate
+func (idp *IdentityProvider) Certificate() string {
+	return base64.StdEncoding.EncodeToString(idp.certificate)
+}
+
+// Metadata returns the identity provider's entity descriptor
+func (idp *IdentityProvider) Metadata() []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="` + escapeAttr(idp.EntityID) + `">
+  <md:IDPSSODescriptor WantAuthnRequestsSigned="false" protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo>
+        <ds:X509Data>
+          <ds:X509Certificate>` + idp.Certificate() + `</ds:X509Certificate>
+        </ds:X509Data>
+      </ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:NameIDFormat>urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress</md:NameIDFormat>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="` + escapeAttr(idp.SSOURL) + `"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="` + escapeAttr(idp.SSOURL) + `"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`)
+}
+
+// AuthnRequest is a sign-in request read from an HTTP-Redirect URL
+type AuthnRequest struct {
+	ID         string `xml:"ID,attr"`
+	ACSURL     string `xml:"AssertionConsumerServiceURL,attr"`
+	Issuer     string `xml:"Issuer"`
+	RelayState string `xml:"-"`
+}
+
+// ReadAuthnRequest decodes the request a service provider redirected the browser with
+func (idp *IdentityProvider) ReadAuthnRequest(redirectURL string) (*AuthnRequest, error) {
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return nil, err
+	}
+	deflated, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	if err != nil {
+		return nil, err
+	}
+
+	var request AuthnRequest
+	if err := xml.Unmarshal(data, &request); err != nil {
+		return nil, err
+	}
+	request.RelayState = u.Query().Get("RelayState")
+	return &request, nil
+}
+
+// ResponseOptions describe the response to issue
+type ResponseOptions struct {
+	ServiceProvider saml.ServiceProvider
+	InResponseTo    string // Empty for an IdP-initiated response
+	NameID          string
+	Attributes      map[string][]string
+
+	IssuedAt time.Time     // Defaults to now
+	Lifetime time.Duration // Defaults to five minutes
+
+	Status string // Defaults to StatusSuccess
+
+	// The assertion is signed unless UnsignedAssertion is set; SignResponse also signs the envelope
+	UnsignedAssertion bool
+	SignResponse      bool
+
+	AssertionID string // Defaults to a random ID
+}
+
+// Response returns a base64 encoded response, as posted to the assertion consumer service
+func (idp *IdentityProvider) Response(opts ResponseOptions) (string, error) {
+	document, err := idp.ResponseXML(opts)
+	if err != nil {
+		return "", err
+	}
+	return Encode(document), nil
+}
+
+// Encode base64 encodes a response document
+func Encode(document string) string {
+	return base64.StdEncoding.EncodeToString([]byte(document))
+}
+
+// ResponseXML returns the response document. The signed parts are written in their
+// exclusive canonical form so that the signatures do not depend on the canonicalization
+// under test.
+func (idp *IdentityProvider) ResponseXML(opts ResponseOptions) (string, error) {
+	issuedAt := opts.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = time.Now()
+	}
+	lifetime := opts.Lifetime
+	if lifetime == 0 {
+		lifetime = 5 * time.Minute
+	}
+	status := opts.Status
+	if status == "" {
+		status = StatusSuccess
+	}
+	assertionID := opts.AssertionID
+	if assertionID == "" {
+		assertionID = randomID()
+	}
+	responseID := randomID()
+
+	issueInstant := timestamp(issuedAt)
+	notBefore := timestamp(issuedAt.Add(-30 * time.Second))
+	notOnOrAfter := timestamp(issuedAt.Add(lifetime))
+
+	inResponseTo := ""
+	if opts.InResponseTo != "" {
+		inResponseTo = ` InResponseTo="` + escapeAttr(opts.InResponseTo) + `"`
+	}
+
+	var assertion strings.Builder
+	assertion.WriteString(`<saml:Assertion xmlns:saml="` + nsAssertion + `" xmlns:xs="` + nsXS + `" ID="` + assertionID + `" IssueInstant="` + issueInstant + `" Version="2.0">`)
+	assertion.WriteString(`<saml:Issuer>` + escapeText(idp.EntityID) + `</saml:Issuer>`)
+	assertion.WriteString(`<saml:Subject><saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">` + escapeText(opts.NameID) + `</saml:NameID>`)
+	assertion.WriteString(`<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer"><saml:SubjectConfirmationData` + inResponseTo + ` NotOnOrAfter="` + notOnOrAfter + `" Recipient="` + escapeAttr(opts.ServiceProvider.ACSURL) + `"></saml:SubjectConfirmationData></saml:SubjectConfirmation></saml:Subject>`)
+	assertion.WriteString(`<saml:Conditions NotBefore="` + notBefore + `" NotOnOrAfter="` + notOnOrAfter + `"><saml:AudienceRestriction><saml:Audience>` + escapeText(opts.ServiceProvider.EntityID) + `</saml:Audience></saml:AudienceRestriction></saml:Conditions>`)
+	assertion.WriteString(`<saml:AuthnStatement AuthnInstant="` + issueInstant + `" SessionIndex="` + randomID() + `"><saml:AuthnContext><saml:AuthnContextClassRef>urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport</saml:AuthnContextClassRef></saml:AuthnContext></saml:AuthnStatement>`)
+	if len(opts.Attributes) > 0 {
+		names := make([]string, 0, len(opts.Attributes))
+		for name := range opts.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		assertion.WriteString(`<saml:AttributeStatement>`)
+		for _, name := range names {
+			assertion.WriteString(`<saml:Attribute Name="` + escapeAttr(name) + `" NameFormat="urn:oasis:names:tc:SAML:2.0:attrname-format:basic">`)
+			for _, value := range opts.Attributes[name] {
+				assertion.WriteString(`<saml:AttributeValue xmlns:xsi="` + nsXSI + `" xsi:type="xs:string">` + escapeText(value) + `</saml:AttributeValue>`)
+			}
+			assertion.WriteString(`</saml:Attribute>`)
+		}
+		assertion.WriteString(`</saml:AttributeStatement>`)
+	}
+	assertion.WriteString(`</saml:Assertion>`)
+
+	signedAssertion := assertion.String()
+	if !opts.UnsignedAssertion {
+		var err error
+		if signedAssertion, err = idp.sign(signedAssertion, assertionID, "</saml:Issuer>"); err != nil {
+			return "", err
+		}
+	}
+
+	response := `<samlp:Response xmlns:samlp="` + nsProtocol + `" Destination="` + escapeAttr(opts.ServiceProvider.ACSURL) + `" ID="` + responseID + `"` + inResponseTo + ` IssueInstant="` + issueInstant + `" Version="2.0">` +
+		`<saml:Issuer xmlns:saml="` + nsAssertion + `">` + escapeText(idp.EntityID) + `</saml:Issuer>` +
+		`<samlp:Status><samlp:StatusCode Value="` + status + `"></samlp:StatusCode></samlp:Status>`
+	if status == StatusSuccess {
+		response += signedAssertion
+	}
+	response += `</samlp:Response>`
+
+	if opts.SignResponse {
+		var err error
+		if response, err = idp.sign(response, responseID, "</saml:Issuer>"); err != nil {
+			return "", err
+		}
+	}
+
+	return `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + response, nil
+}
+
+// sign inserts an enveloped signature over a canonical element after the first occurrence of marker
+func (idp *IdentityProvider) sign(canonical, id, marker string) (string, error) {
+	digest := sha256.Sum256([]byte(canonical))
+
+	references := `<ds:CanonicalizationMethod Algorithm="` + nsExcC14N + `"></ds:CanonicalizationMethod>` +
+		`<ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"></ds:SignatureMethod>` +
+		`<ds:Reference URI="#` + id + `"><ds:Transforms>` +
+		`<ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"></ds:Transform>` +
+		`<ds:Transform Algorithm="` + nsExcC14N + `"><ec:InclusiveNamespaces xmlns:ec="` + nsExcC14N + `" PrefixList="xs"></ec:InclusiveNamespaces></ds:Transform>` +
+		`</ds:Transforms><ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"></ds:DigestMethod>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest[:]) + `</ds:DigestValue></ds:Reference>`
+
+	// SignedInfo is canonicalized on its own, declaring the ds prefix its Signature parent declares in the document
+	hashed := sha256.Sum256([]byte(`<ds:SignedInfo xmlns:ds="` + nsDSig + `">` + references + `</ds:SignedInfo>`))
+	signatureValue, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", fmt.Errorf("samltest: failed to sign: %w", err)
+	}
+
+	signature := `<ds:Signature xmlns:ds="` + nsDSig + `"><ds:SignedInfo>` + references + `</ds:SignedInfo>` +
+		`<ds:SignatureValue>` + base64.StdEncoding.EncodeToString(signatureValue) + `</ds:SignatureValue>` +
+		`<ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + idp.Certificate() + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></ds:Signature>`
+
+	at := strings.Index(canonical, marker)
+	if at < 0 {
+		return "", fmt.Errorf("samltest: marker %q not found", marker)
+	}
+	at += len(marker)
+	return canonical[:at] + signature + canonical[at:], nil
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("_%x", b)
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// Text and attribute values are escaped as canonicalization would write them
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;")
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
@@ -0,0 +1,87 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Authenticator data flags (WebAuthn section 6.1)
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagAttestedData   = 0x40
+	flagExtensionData  = 0x80
+
+	rpIDHashLength    = 32
+	aaguidLength      = 16
+	authDataMinLength = rpIDHashLength + 1 + 4
+)
+
+// authenticatorData is the parsed authenticator data structure
+type authenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// Attested credential data, present during registration
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+func (d *authenticatorData) userPresent() bool    { return d.Flags&flagUserPresent != 0 }
+func (d *authenticatorData) userVerified() bool   { return d.Flags&flagUserVerified != 0 }
+func (d *authenticatorData) backupEligible() bool { return d.Flags&flagBackupEligible != 0 }
+func (d *authenticatorData) hasCredential() bool  { return d.Flags&flagAttestedData != 0 }
+
+// parseAuthenticatorData decodes authenticator data, including the attested credential when present
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < authDataMinLength {
+		return nil, errors.New("authenticator data is too short")
+	}
+
+	parsed := &authenticatorData{
+		RPIDHash:  data[:rpIDHashLength],
+		Flags:     data[rpIDHashLength],
+		SignCount: binary.BigEndian.Uint32(data[rpIDHashLength+1:]),
+	}
+	rest := data[authDataMinLength:]
+
+	if parsed.hasCredential() {
+		if len(rest) < aaguidLength+2 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		parsed.AAGUID = rest[:aaguidLength]
+		idLength := int(binary.BigEndian.Uint16(rest[aaguidLength:]))
+		rest = rest[aaguidLength+2:]
+
+		if idLength == 0 || len(rest) < idLength {
+			return nil, errors.New("malformed credential ID")
+		}
+		parsed.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		// The public key is a CBOR item whose length is only known by decoding it
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, err
+		}
+		parsed.PublicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+
+	if parsed.Flags&flagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = after
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("unexpected trailing authenticator data")
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,148 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/victoralfred/um_sys/internal/domain/webauthn"
+)
+
+// COSE key parameters (RFC 9053)
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+
+	coseEC2Curve = -1
+	coseEC2X     = -2
+	coseEC2Y     = -3
+
+	coseOKPCurve = -1
+	coseOKPX     = -2
+
+	coseRSAN = -1
+	coseRSAE = -2
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+
+	// minRSAKeyBits rejects RSA keys too short to be trusted
+	minRSAKeyBits = 2048
+)
+
+// parseCOSEKey decodes a COSE_Key into a public key and its algorithm
+func parseCOSEKey(data []byte) (crypto.PublicKey, int64, error) {
+	item, _, err := decodeCBOR(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return coseKeyFromMap(item)
+}
+
+func coseKeyFromMap(item interface{}) (crypto.PublicKey, int64, error) {
+	entries, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("COSE key is not a map")
+	}
+
+	kty, _ := lookupInt(entries, coseKeyType)
+	alg, ok := lookupInt(entries, coseAlgorithm)
+	if !ok {
+		return nil, 0, errors.New("COSE key has no algorithm")
+	}
+
+	switch {
+	case alg == webauthn.AlgES256 && kty == coseKeyTypeEC2:
+		crv, _ := lookupInt(entries, coseEC2Curve)
+		if crv != coseCurveP256 {
+			return nil, 0, fmt.Errorf("%w: curve %d", webauthn.ErrUnsupportedAlgorithm, crv)
+		}
+		x, xOK := lookupBytes(entries, coseEC2X)
+		y, yOK := lookupBytes(entries, coseEC2Y)
+		if !xOK || !yOK || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("malformed EC2 key")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, 0, errors.New("EC2 key is not on its curve")
+		}
+		return key, alg, nil
+
+	case alg == webauthn.AlgEdDSA && kty == coseKeyTypeOKP:
+		crv, _ := lookupInt(entries, coseOKPCurve)
+		x, ok := lookupBytes(entries, coseOKPX)
+		if crv != coseCurveEd25519 || !ok || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("malformed OKP key")
+		}
+		return ed25519.PublicKey(x), alg, nil
+
+	case alg == webauthn.AlgRS256 && kty == coseKeyTypeRSA:
+		n, nOK := lookupBytes(entries, coseRSAN)
+		e, eOK := lookupBytes(entries, coseRSAE)
+		if !nOK || !eOK || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("malformed RSA key")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, 0, errors.New("RSA key is too short")
+		}
+		return key, alg, nil
+
+	default:
+		return nil, 0, fmt.Errorf("%w: %d", webauthn.ErrUnsupportedAlgorithm, alg)
+	}
+}
+
+// verifySignature checks a WebAuthn signature made with the given COSE algorithm
+func verifySignature(key crypto.PublicKey, alg int64, message, signature []byte) error {
+	digest := sha256.Sum256(message)
+
+	switch alg {
+	case webauthn.AlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if ok && ecdsa.VerifyASN1(pub, digest[:], signature) {
+			return nil
+		}
+	case webauthn.AlgEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if ok && ed25519.Verify(pub, message, signature) {
+			return nil
+		}
+	case webauthn.AlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if ok && rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	default:
+		return fmt.Errorf("%w: %d", webauthn.ErrUnsupportedAlgorithm, alg)
+	}
+
+	return fmt.Errorf("%w: invalid signature", webauthn.ErrVerificationFailed)
+}
+
+func lookupInt(entries map[interface{}]interface{}, key int64) (int64, bool) {
+	value, ok := cborLookup(entries, key)
+	if !ok {
+		return 0, false
+	}
+	return cborInt(value)
+}
+
+func lookupBytes(entries map[interface{}]interface{}, key int64) ([]byte, bool) {
+	value, ok := cborLookup(entries, key)
+	if !ok {
+		return nil, false
+	}
+	b, ok := value.([]byte)
+	return b, ok
+}
@@ -0,0 +1,250 @@
+// Package webauthn verifies WebAuthn registration and authentication responses.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/victoralfred/um_sys/internal/domain/webauthn"
+)
+
+// Client data types (WebAuthn section 5.8.1)
+const (
+	clientDataTypeCreate = "webauthn.create"
+	clientDataTypeGet    = "webauthn.get"
+)
+
+// Attestation statement formats that can be verified
+const (
+	attestationFormatNone   = "none"
+	attestationFormatPacked = "packed"
+)
+
+// clientData is the JSON the browser signs over
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// Verifier implements webauthn.Verifier for one relying party
+type Verifier struct {
+	rpID     string
+	rpIDHash [32]byte
+	origins  []string
+}
+
+// NewVerifier creates a verifier for the relying party ID and the origins allowed to use it
+func NewVerifier(rpID string, origins []string) *Verifier {
+	return &Verifier{
+		rpID:     rpID,
+		rpIDHash: sha256.Sum256([]byte(rpID)),
+		origins:  origins,
+	}
+}
+
+// VerifyRegistration validates a create() response for a ceremony and returns the new credential.
+// Attestation is checked for consistency but not against a trust store; "none" and "packed"
+// statements are accepted.
+func (v *Verifier) VerifyRegistration(ceremony *webauthn.Ceremony, response *webauthn.RegistrationResponse) (*webauthn.AttestedCredential, error) {
+	if ceremony.Type != webauthn.CeremonyRegistration {
+		return nil, fmt.Errorf("%w: not a registration ceremony", webauthn.ErrVerificationFailed)
+	}
+
+	if err := v.verifyClientData(response.Response.ClientDataJSON, clientDataTypeCreate, ceremony.Challenge); err != nil {
+		return nil, err
+	}
+
+	item, _, err := decodeCBOR(response.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", webauthn.ErrVerificationFailed, err)
+	}
+	object, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed attestation object", webauthn.ErrVerificationFailed)
+	}
+	format, _ := object["fmt"].(string)
+	rawAuthData, _ := object["authData"].([]byte)
+	statement, _ := object["attStmt"].(map[interface{}]interface{})
+
+	authData, err := v.verifyAuthenticatorData(rawAuthData, ceremony)
+	if err != nil {
+		return nil, err
+	}
+	if !authData.hasCredential() {
+		return nil, fmt.Errorf("%w: no attested credential", webauthn.ErrVerificationFailed)
+	}
+	if !bytes.Equal(authData.CredentialID, response.RawID) {
+		return nil, fmt.Errorf("%w: credential ID mismatch", webauthn.ErrVerificationFailed)
+	}
+
+	publicKey, alg, err := parseCOSEKey(authData.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", webauthn.ErrVerificationFailed, err)
+	}
+
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+
+	switch format {
+	case attestationFormatNone:
+		if len(statement) != 0 {
+			return nil, fmt.Errorf("%w: none attestation with a statement", webauthn.ErrVerificationFailed)
+		}
+
+	case attestationFormatPacked:
+		if err := verifyPackedAttestation(statement, signed, publicKey, alg); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %q", webauthn.ErrUnsupportedAttestation, format)
+	}
+
+	return &webauthn.AttestedCredential{
+		CredentialID:   append([]byte(nil), authData.CredentialID...),
+		PublicKey:      append([]byte(nil), authData.PublicKey...),
+		Algorithm:      alg,
+		SignCount:      authData.SignCount,
+		AAGUID:         append([]byte(nil), authData.AAGUID...),
+		UserVerified:   authData.userVerified(),
+		BackupEligible: authData.backupEligible(),
+	}, nil
+}
+
+// VerifyAssertion validates a get() response for a ceremony against a stored credential.
+// Checking the signature counter is left to the caller, which owns the stored value.
+func (v *Verifier) VerifyAssertion(ceremony *webauthn.Ceremony, credential *webauthn.Credential, response *webauthn.AssertionResponse) (*webauthn.AssertionResult, error) {
+	if ceremony.Type != webauthn.CeremonyAuthentication {
+		return nil, fmt.Errorf("%w: not an authentication ceremony", webauthn.ErrVerificationFailed)
+	}
+
+	if !bytes.Equal(response.RawID, credential.CredentialID) {
+		return nil, fmt.Errorf("%w: credential ID mismatch", webauthn.ErrVerificationFailed)
+	}
+
+	// The user handle is the user's ID; when present it must belong to the credential
+	if handle := response.Response.UserHandle; len(handle) > 0 && !bytes.Equal(handle, credential.UserID[:]) {
+		return nil, fmt.Errorf("%w: user handle mismatch", webauthn.ErrVerificationFailed)
+	}
+
+	if err := v.verifyClientData(response.Response.ClientDataJSON, clientDataTypeGet, ceremony.Challenge); err != nil {
+		return nil, err
+	}
+
+	authData, err := v.verifyAuthenticatorData(response.Response.AuthenticatorData, ceremony)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, alg, err := parseCOSEKey(credential.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: stored key: %v", webauthn.ErrVerificationFailed, err)
+	}
+
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	signed := append(append([]byte{}, response.Response.AuthenticatorData...), clientDataHash[:]...)
+	if err := verifySignature(publicKey, alg, signed, response.Response.Signature); err != nil {
+		return nil, err
+	}
+
+	return &webauthn.AssertionResult{
+		SignCount:    authData.SignCount,
+		UserVerified: authData.userVerified(),
+	}, nil
+}
+
+// verifyClientData checks the ceremony type, challenge and origin the browser reported
+func (v *Verifier) verifyClientData(raw []byte, expectedType string, challenge []byte) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("%w: malformed client data", webauthn.ErrVerificationFailed)
+	}
+
+	if data.Type != expectedType {
+		return fmt.Errorf("%w: client data type %q", webauthn.ErrVerificationFailed, data.Type)
+	}
+
+	expected := base64.RawURLEncoding.EncodeToString(challenge)
+	if subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(expected)) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", webauthn.ErrVerificationFailed)
+	}
+
+	if data.CrossOrigin || !v.allowsOrigin(data.Origin) {
+		return fmt.Errorf("%w: origin %q not allowed", webauthn.ErrVerificationFailed, data.Origin)
+	}
+
+	return nil
+}
+
+// verifyAuthenticatorData checks the relying party hash and the user presence and verification flags
+func (v *Verifier) verifyAuthenticatorData(raw []byte, ceremony *webauthn.Ceremony) (*authenticatorData, error) {
+	authData, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", webauthn.ErrVerificationFailed, err)
+	}
+
+	if subtle.ConstantTimeCompare(authData.RPIDHash, v.rpIDHash[:]) != 1 {
+		return nil, fmt.Errorf("%w: relying party ID mismatch", webauthn.ErrVerificationFailed)
+	}
+
+	if !authData.userPresent() {
+		return nil, fmt.Errorf("%w: user not present", webauthn.ErrVerificationFailed)
+	}
+
+	if ceremony.RequiresUserVerification() && !authData.userVerified() {
+		return nil, webauthn.ErrUserVerificationRequired
+	}
+
+	return authData, nil
+}
+
+func (v *Verifier) allowsOrigin(origin string) bool {
+	for _, allowed := range v.origins {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyPackedAttestation checks a packed attestation signature. Self attestation is signed
+// by the credential key; full attestation by the first certificate in x5c.
+func verifyPackedAttestation(statement map[interface{}]interface{}, signed []byte, credentialKey interface{}, credentialAlg int64) error {
+	alg, ok := cborInt(statement["alg"])
+	if !ok {
+		return fmt.Errorf("%w: packed attestation without alg", webauthn.ErrVerificationFailed)
+	}
+	signature, ok := statement["sig"].([]byte)
+	if !ok {
+		return fmt.Errorf("%w: packed attestation without sig", webauthn.ErrVerificationFailed)
+	}
+
+	chain, hasChain := statement["x5c"].([]interface{})
+	if !hasChain {
+		if alg != credentialAlg {
+			return fmt.Errorf("%w: self attestation algorithm mismatch", webauthn.ErrVerificationFailed)
+		}
+		return verifySignature(credentialKey, alg, signed, signature)
+	}
+
+	if len(chain) == 0 {
+		return fmt.Errorf("%w: empty attestation certificate chain", webauthn.ErrVerificationFailed)
+	}
+	der, ok := chain[0].([]byte)
+	if !ok {
+		return fmt.Errorf("%w: malformed attestation certificate", webauthn.ErrVerificationFailed)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("%w: %v", webauthn.ErrVerificationFailed, err)
+	}
+
+	return verifySignature(cert.PublicKey, alg, signed, signature)
+}
@@ -0,0 +1,183 @@
+package webauthn_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/webauthn"
+	webauthnImpl "github.com/victoralfred/um_sys/internal/infrastructure/webauthn"
+	"github.com/victoralfred/um_sys/internal/infrastructure/webauthn/webauthntest"
+)
+
+const testOrigin = "https://app.example.com"
+
+func newCeremony(ceremonyType webauthn.CeremonyType, userID uuid.UUID, userVerification string) *webauthn.Ceremony {
+	return &webauthn.Ceremony{
+		ID:               uuid.New(),
+		Type:             ceremonyType,
+		UserID:           &userID,
+		Challenge:        []byte("0123456789abcdef0123456789abcdef"),
+		UserVerification: userVerification,
+		ExpiresAt:        time.Now().Add(webauthn.CeremonyTimeout),
+	}
+}
+
+func creationOptions(ceremony *webauthn.Ceremony) *webauthn.CreationOptions {
+	return &webauthn.CreationOptions{
+		RP:        webauthn.RelyingPartyEntity{ID: "example.com"},
+		User:      webauthn.UserEntity{ID: ceremony.UserID[:]},
+		Challenge: ceremony.Challenge,
+	}
+}
+
+// register verifies a registration and returns the credential as the service would store it
+func register(t *testing.T, verifier *webauthnImpl.Verifier, authenticator *webauthntest.Authenticator, userID uuid.UUID) *webauthn.Credential {
+	t.Helper()
+
+	ceremony := newCeremony(webauthn.CeremonyRegistration, userID, webauthn.UserVerificationPreferred)
+	response, err := authenticator.Register(creationOptions(ceremony))
+	require.NoError(t, err)
+
+	attested, err := verifier.VerifyRegistration(ceremony, response)
+	require.NoError(t, err)
+
+	return &webauthn.Credential{
+		ID:           uuid.New(),
+		UserID:       userID,
+		CredentialID: attested.CredentialID,
+		PublicKey:    attested.PublicKey,
+		Algorithm:    attested.Algorithm,
+		SignCount:    attested.SignCount,
+	}
+}
+
+func TestVerifier_VerifyRegistration(t *testing.T) {
+	verifier := webauthnImpl.NewVerifier("example.com", []string{testOrigin})
+
+	t.Run("accepts a none attestation", func(t *testing.T) {
+		authenticator := webauthntest.NewAuthenticator("example.com", testOrigin)
+		ceremony := newCeremony(webauthn.CeremonyRegistration, uuid.New(), webauthn.UserVerificationPreferred)
+
+		response, err := authenticator.Register(creationOptions(ceremony))
+		require.NoError(t, err)
+
+		attested, err := verifier.VerifyRegistration(ceremony, response)
+		require.NoError(t, err)
+		assert.Equal(t, []byte(response.RawID), []byte(attested.CredentialID))
+		assert.Equal(t, int64(webauthn.AlgES256), attested.Algorithm)
+		assert.NotEmpty(t, attested.PublicKey)
+	})
+
+	tests := []struct {
+		name    string
+		rpID    string
+		origin  string
+		mutate  func(*webauthn.RegistrationResponse)
+		wantErr error
+	}{
+		{
+			name:    "rejects another relying party",
+			rpID:    "evil.example",
+			origin:  testOrigin,
+			wantErr: webauthn.ErrVerificationFailed,
+		},
+		{
+			name:    "rejects another origin",
+			rpID:    "example.com",
+			origin:  "https://evil.example",
+			wantErr: webauthn.ErrVerificationFailed,
+		},
+		{
+			name:   "rejects a corrupt attestation object",
+			rpID:   "example.com",
+			origin: testOrigin,
+			mutate: func(r *webauthn.RegistrationResponse) {
+				r.Response.AttestationObject = r.Response.AttestationObject[:len(r.Response.AttestationObject)-10]
+			},
+			wantErr: webauthn.ErrVerificationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := webauthntest.NewAuthenticator(tt.rpID, tt.origin)
+			ceremony := newCeremony(webauthn.CeremonyRegistration, uuid.New(), webauthn.UserVerificationPreferred)
+
+			response, err := authenticator.Register(creationOptions(ceremony))
+			require.NoError(t, err)
+			if tt.mutate != nil {
+				tt.mutate(response)
+			}
+
+			_, err = verifier.VerifyRegistration(ceremony, response)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestVerifier_VerifyAssertion(t *testing.T) {
+	verifier := webauthnImpl.NewVerifier("example.com", []string{testOrigin})
+	userID := uuid.New()
+
+	verify := func(t *testing.T, authenticator *webauthntest.Authenticator, credential *webauthn.Credential, userVerification string, mutate func(*webauthn.AssertionResponse)) (*webauthn.AssertionResult, error) {
+		t.Helper()
+
+		ceremony := newCeremony(webauthn.CeremonyAuthentication, userID, userVerification)
+		response, err := authenticator.Login(&webauthn.RequestOptions{
+			Challenge:        ceremony.Challenge,
+			AllowCredentials: []webauthn.CredentialDescriptor{{Type: webauthn.PublicKeyCredentialType, ID: credential.CredentialID}},
+		})
+		require.NoError(t, err)
+		if mutate != nil {
+			mutate(response)
+		}
+		return verifier.VerifyAssertion(ceremony, credential, response)
+	}
+
+	t.Run("accepts a valid assertion", func(t *testing.T) {
+		authenticator := webauthntest.NewAuthenticator("example.com", testOrigin)
+		credential := register(t, verifier, authenticator, userID)
+
+		result, err := verify(t, authenticator, credential, webauthn.UserVerificationRequired, nil)
+		require.NoError(t, err)
+		assert.True(t, result.UserVerified)
+		assert.Equal(t, uint32(1), result.SignCount)
+	})
+
+	t.Run("rejects a tampered signature", func(t *testing.T) {
+		authenticator := webauthntest.NewAuthenticator("example.com", testOrigin)
+		credential := register(t, verifier, authenticator, userID)
+
+		_, err := verify(t, authenticator, credential, webauthn.UserVerificationPreferred, func(r *webauthn.AssertionResponse) {
+			r.Response.Signature[len(r.Response.Signature)-1] ^= 0xff
+		})
+		assert.ErrorIs(t, err, webauthn.ErrVerificationFailed)
+	})
+
+	t.Run("rejects another user's handle", func(t *testing.T) {
+		authenticator := webauthntest.NewAuthenticator("example.com", testOrigin)
+		credential := register(t, verifier, authenticator, userID)
+
+		other := uuid.New()
+		_, err := verify(t, authenticator, credential, webauthn.UserVerificationPreferred, func(r *webauthn.AssertionResponse) {
+			r.Response.UserHandle = other[:]
+		})
+		assert.ErrorIs(t, err, webauthn.ErrVerificationFailed)
+	})
+
+	t.Run("requires user verification when asked", func(t *testing.T) {
+		authenticator := webauthntest.NewAuthenticator("example.com", testOrigin)
+		credential := register(t, verifier, authenticator, userID)
+		authenticator.UserVerified = false
+
+		_, err := verify(t, authenticator, credential, webauthn.UserVerificationPreferred, nil)
+		require.NoError(t, err)
+
+		_, err = verify(t, authenticator, credential, webauthn.UserVerificationRequired, nil)
+		assert.ErrorIs(t, err, webauthn.ErrUserVerificationRequired)
+	})
+}
@@ -0,0 +1,226 @@
+// Package webauthntest provides a software WebAuthn authenticator for tests.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/victoralfred/um_sys/internal/domain/webauthn"
+)
+
+// Authenticator is a software authenticator holding ES256 credentials in memory.
+// It answers ceremony options the way a browser and platform authenticator would.
+type Authenticator struct {
+	// RPID is the relying party ID the authenticator scopes its credentials to
+	RPID string
+
+	// Origin is reported in client data as the page that ran the ceremony
+	Origin string
+
+	// UserVerified controls whether responses claim the user was verified
+	UserVerified bool
+
+	mu          sync.Mutex
+	credentials []*credential
+}
+
+type credential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+	signCount  uint32
+}
+
+// NewAuthenticator creates an authenticator for a relying party that verifies its user
+func NewAuthenticator(rpID, origin string) *Authenticator {
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		UserVerified: true,
+	}
+}
+
+// Register creates a credential for the options and returns the create() response
+func (a *Authenticator) Register(options *webauthn.CreationOptions) (*webauthn.RegistrationResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, excluded := range options.ExcludeCredentials {
+		if a.find(excluded.ID) != nil {
+			return nil, errors.New("webauthntest: authenticator already holds an excluded credential")
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	cred := &credential{
+		id:         id,
+		key:        key,
+		userHandle: append([]byte(nil), options.User.ID...),
+	}
+	a.credentials = append(a.credentials, cred)
+
+	clientDataJSON, err := a.clientData("webauthn.create", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authenticatorData(cred.signCount)
+	authData = append(authData, make([]byte, 16)...) // AAGUID of zeros, as with "none" attestation
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(id)))
+	authData = append(authData, id...)
+	authData = append(authData, coseKey(&key.PublicKey)...)
+	authData[32] |= 0x40 // Attested credential data included
+
+	attestationObject := encodeMap(
+		entry{encodeText("fmt"), encodeText("none")},
+		entry{encodeText("attStmt"), encodeMap()},
+		entry{encodeText("authData"), encodeBytes(authData)},
+	)
+
+	return &webauthn.RegistrationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(id),
+		RawID: id,
+		Type:  webauthn.PublicKeyCredentialType,
+		Response: webauthn.AttestationResponse{
+			ClientDataJSON:    clientDataJSON,
+			AttestationObject: attestationObject,
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// Login signs the challenge with a credential allowed by the options and returns the get()
+// response. With no allowed credentials it uses its first credential, as a passkey would.
+func (a *Authenticator) Login(options *webauthn.RequestOptions) (*webauthn.AssertionResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var cred *credential
+	if len(options.AllowCredentials) == 0 {
+		if len(a.credentials) > 0 {
+			cred = a.credentials[0]
+		}
+	} else {
+		for _, allowed := range options.AllowCredentials {
+			if cred = a.find(allowed.ID); cred != nil {
+				break
+			}
+		}
+	}
+	if cred == nil {
+		return nil, errors.New("webauthntest: no matching credential")
+	}
+
+	cred.signCount++
+
+	clientDataJSON, err := a.clientData("webauthn.get", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	authData := a.authenticatorData(cred.signCount)
+
+	digest := sha256.Sum256(clientDataJSON)
+	signed := sha256.Sum256(append(append([]byte{}, authData...), digest[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, cred.key, signed[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthn.AssertionResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(cred.id),
+		RawID: cred.id,
+		Type:  webauthn.PublicKeyCredentialType,
+		Response: webauthn.AuthenticatorAssertionResponse{
+			ClientDataJSON:    clientDataJSON,
+			AuthenticatorData: authData,
+			Signature:         signature,
+			UserHandle:        cred.userHandle,
+		},
+	}, nil
+}
+
+// Clone returns an authenticator holding copies of the same keys and counters,
+// simulating a credential that has been extracted and duplicated
+func (a *Authenticator) Clone() *Authenticator {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	clone := &Authenticator{
+		RPID:         a.RPID,
+		Origin:       a.Origin,
+		UserVerified: a.UserVerified,
+	}
+	for _, cred := range a.credentials {
+		copied := *cred
+		clone.credentials = append(clone.credentials, &copied)
+	}
+	return clone
+}
+
+func (a *Authenticator) find(id []byte) *credential {
+	for _, cred := range a.credentials {
+		if string(cred.id) == string(id) {
+			return cred
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) clientData(ceremonyType string, challenge []byte) ([]byte, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":        ceremonyType,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("webauthntest: %w", err)
+	}
+	return data, nil
+}
+
+// authenticatorData builds the fixed part of the authenticator data
+func (a *Authenticator) authenticatorData(signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+
+	flags := byte(0x01) // User present
+	if a.UserVerified {
+		flags |= 0x04
+	}
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	return binary.BigEndian.AppendUint32(data, signCount)
+}
+
+// coseKey encodes a P-256 public key as an ES256 COSE_Key
+func coseKey(key *ecdsa.PublicKey) []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+
+	return encodeMap(
+		entry{encodeInt(1), encodeInt(2)},                 // kty: EC2
+		entry{encodeInt(3), encodeInt(webauthn.AlgES256)}, // alg: ES256
+		entry{encodeInt(-1), encodeInt(1)},                // crv: P-256
+		entry{encodeInt(-2), encodeBytes(x)},
+		entry{encodeInt(-3), encodeBytes(y)},
+	)
+}
@@ -111,9 +111,18 @@ func TestAuthIntegration_CompleteFlow(t *testing.T) {
 	})
 
 	// Create auth handler
+	authService := services.NewAuthService(
+		userRepo,
+		tokenService,
+		passwordHasher,
+		passwordValidator,
+		nil,
+		services.DefaultAuthConfig(),
+	)
 	authHandler := handlers.NewAuthHandler(
 		userService,
 		tokenService,
+		authService,
 		passwordHasher,
 		passwordValidator,
 		logger,
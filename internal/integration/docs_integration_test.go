@@ -193,9 +193,18 @@ func setupTestServerWithDocs(t *testing.T) *gin.Engine {
 	})
 
 	// Create handlers
+	authService := services.NewAuthService(
+		userRepo,
+		tokenService,
+		passwordHasher,
+		passwordValidator,
+		nil,
+		services.DefaultAuthConfig(),
+	)
 	authHandler := handlers.NewAuthHandler(
 		userService,
 		tokenService,
+		authService,
 		passwordHasher,
 		passwordValidator,
 		logger,
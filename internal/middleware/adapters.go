@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/victoralfred/um_sys/internal/domain/apikey"
 	"github.com/victoralfred/um_sys/internal/domain/auth"
 	"github.com/victoralfred/um_sys/internal/services"
 )
@@ -11,7 +12,9 @@ import (
 // TokenServiceAdapter adapts services.TokenService to middleware.TokenService interface
 // This follows the Adapter Pattern and Dependency Inversion Principle
 type TokenServiceAdapter struct {
-	service *services.TokenService
+	service            *services.TokenService
+	apiKeyService      *services.APIKeyService
+	verificationPolicy *auth.EmailVerificationPolicy
 }
 
 // NewTokenServiceAdapter creates a new adapter
@@ -21,6 +24,16 @@ func NewTokenServiceAdapter(service *services.TokenService) *TokenServiceAdapter
 	}
 }
 
+// SetEmailVerificationPolicy withholds the policy's restricted permissions from unverified users
+func (a *TokenServiceAdapter) SetEmailVerificationPolicy(policy *auth.EmailVerificationPolicy) {
+	a.verificationPolicy = policy
+}
+
+// SetAPIKeyService enables the ApiKey authorization scheme
+func (a *TokenServiceAdapter) SetAPIKeyService(apiKeyService *services.APIKeyService) {
+	a.apiKeyService = apiKeyService
+}
+
 // ValidateToken adapts the ValidateToken method
 func (a *TokenServiceAdapter) ValidateToken(token string) (*TokenClaims, error) {
 	ctx := context.Background()
@@ -31,34 +44,91 @@ func (a *TokenServiceAdapter) ValidateToken(token string) (*TokenClaims, error)
 		return nil, err
 	}
 
+	// Split role permissions into usable and withheld by the verification policy.
+	// Service accounts have no email, so the policy does not apply to them.
+	exempt := claims.PrincipalType == auth.PrincipalServiceAccount
+	permissions := []string{}
+	var withheld []string
+	for _, p := range extractPermissionsFromRoles(claims.Roles) {
+		if exempt || a.verificationPolicy.Allows(claims.EmailVerified, p) {
+			permissions = append(permissions, p)
+		} else {
+			withheld = append(withheld, p)
+		}
+	}
+
+	var actorID string
+	if claims.ActorID != nil {
+		actorID = claims.ActorID.String()
+	}
+
+	var organizationID string
+	if claims.OrganizationID != nil {
+		organizationID = claims.OrganizationID.String()
+	}
+
 	// Convert to middleware TokenClaims
 	return &TokenClaims{
-		UserID:      claims.UserID.String(),
-		Email:       claims.Email,
-		Roles:       claims.Roles,
-		Permissions: extractPermissionsFromRoles(claims.Roles),
+		UserID:              claims.UserID.String(),
+		Email:               claims.Email,
+		EmailVerified:       claims.EmailVerified,
+		Roles:               claims.Roles,
+		Permissions:         permissions,
+		WithheldPermissions: withheld,
+		ClientID:            claims.ClientID,
+		Scopes:              claims.Scopes,
+		PrincipalType:       string(claims.PrincipalType),
+		FamilyID:            claims.FamilyID,
+		IssuedAt:            claims.IssuedAt,
+		SessionExpiresAt:    claims.SessionExpiresAt,
+		IdleTimeout:         claims.IdleTimeout,
+		ActorID:             actorID,
+		OrganizationID:      organizationID,
+		OrganizationRole:    claims.OrganizationRole,
 	}, nil
 }
 
-// IsTokenBlacklisted checks if token is blacklisted
-func (a *TokenServiceAdapter) IsTokenBlacklisted(token string) bool {
-	ctx := context.Background()
+// ValidateAPIKey authenticates an API key. The key's permissions are its scopes
+// narrowed to what the user it acts as currently holds.
+func (a *TokenServiceAdapter) ValidateAPIKey(key, clientIP string) (*TokenClaims, error) {
+	if a.apiKeyService == nil {
+		return nil, apikey.ErrInvalidAPIKey
+	}
 
-	// Parse token to get JTI
-	claims, err := a.service.ValidateToken(ctx, token, auth.AccessToken)
+	ctx := context.Background()
+	apiKey, u, err := a.apiKeyService.Authenticate(ctx, key, clientIP)
 	if err != nil {
-		// If we can't validate, consider it invalid (not blacklisted)
-		return false
+		return nil, err
 	}
 
-	// Check if revoked
-	revoked, err := a.service.IsTokenRevoked(ctx, claims.JTI)
+	roles, err := a.service.RolesFor(ctx, u)
 	if err != nil {
-		// If we can't check, consider it not blacklisted
-		return false
+		return nil, err
+	}
+	permissions := []string{}
+	var withheld []string
+	for _, p := range extractPermissionsFromRoles(roles) {
+		if !apiKey.HasScope(p) {
+			continue
+		}
+		if a.verificationPolicy.Allows(u.EmailVerified, p) {
+			permissions = append(permissions, p)
+		} else {
+			withheld = append(withheld, p)
+		}
 	}
 
-	return revoked
+	return &TokenClaims{
+		UserID:              u.ID.String(),
+		Email:               u.Email,
+		EmailVerified:       u.EmailVerified,
+		Roles:               roles,
+		Permissions:         permissions,
+		WithheldPermissions: withheld,
+		Scopes:              apiKey.Scopes,
+		APIKeyID:            apiKey.ID.String(),
+		PrincipalType:       string(auth.PrincipalUser),
+	}, nil
 }
 
 // RBACServiceAdapter adapts services.RBACService to middleware.RBACService interface
@@ -139,18 +209,34 @@ func unique(strings []string) []string {
 
 // SimpleTokenService is a minimal implementation for testing
 type SimpleTokenService struct {
-	validTokens map[string]*TokenClaims
-	blacklist   map[string]bool
+	validTokens  map[string]*TokenClaims
+	validAPIKeys map[string]*TokenClaims
+	blacklist    map[string]bool
 }
 
 // NewSimpleTokenService creates a simple token service for testing
 func NewSimpleTokenService() *SimpleTokenService {
 	return &SimpleTokenService{
-		validTokens: make(map[string]*TokenClaims),
-		blacklist:   make(map[string]bool),
+		validTokens:  make(map[string]*TokenClaims),
+		validAPIKeys: make(map[string]*TokenClaims),
+		blacklist:    make(map[string]bool),
 	}
 }
 
+// AddValidAPIKey adds a valid API key for testing
+func (s *SimpleTokenService) AddValidAPIKey(key string, claims *TokenClaims) {
+	s.validAPIKeys[key] = claims
+}
+
+// ValidateAPIKey validates an API key
+func (s *SimpleTokenService) ValidateAPIKey(key, clientIP string) (*TokenClaims, error) {
+	claims, ok := s.validAPIKeys[key]
+	if !ok {
+		return nil, apikey.ErrInvalidAPIKey
+	}
+	return claims, nil
+}
+
 // AddValidToken adds a valid token for testing
 func (s *SimpleTokenService) AddValidToken(token string, claims *TokenClaims) {
 	s.validTokens[token] = claims
@@ -163,6 +249,9 @@ func (s *SimpleTokenService) BlacklistToken(token string) {
 
 // ValidateToken validates a token
 func (s *SimpleTokenService) ValidateToken(token string) (*TokenClaims, error) {
+	if s.blacklist[token] {
+		return nil, auth.ErrTokenRevoked
+	}
 	claims, ok := s.validTokens[token]
 	if !ok {
 		return nil, auth.ErrInvalidToken
@@ -170,11 +259,6 @@ func (s *SimpleTokenService) ValidateToken(token string) (*TokenClaims, error) {
 	return claims, nil
 }
 
-// IsTokenBlacklisted checks if token is blacklisted
-func (s *SimpleTokenService) IsTokenBlacklisted(token string) bool {
-	return s.blacklist[token]
-}
-
 // SimpleRBACService is a minimal implementation for testing
 type SimpleRBACService struct {
 	userRoles       map[string][]string
@@ -1,17 +1,27 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/apikey"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
 )
 
 // TokenService interface - Interface Segregation Principle
 type TokenService interface {
+	// ValidateToken returns auth.ErrTokenRevoked for revoked tokens
 	ValidateToken(token string) (*TokenClaims, error)
-	IsTokenBlacklisted(token string) bool
+}
+
+// APIKeyValidator is implemented by token services that also accept API keys
+type APIKeyValidator interface {
+	ValidateAPIKey(key, clientIP string) (*TokenClaims, error)
 }
 
 // RBACService interface - Interface Segregation Principle
@@ -22,14 +32,46 @@ type RBACService interface {
 
 // TokenClaims represents JWT claims
 type TokenClaims struct {
-	UserID      string
-	Email       string
-	Roles       []string
-	Permissions []string
+	UserID        string
+	Email         string
+	EmailVerified bool
+	Roles         []string
+	Permissions   []string
+	// WithheldPermissions are granted by role but blocked until the email is verified
+	WithheldPermissions []string
+	// ClientID and Scopes are set for tokens issued to OAuth clients
+	ClientID string
+	Scopes   []string
+	// APIKeyID is set when the request authenticated with an API key; Scopes then holds the key's permissions
+	APIKeyID string
+	// PrincipalType is "user" or "service_account"; UserID holds the service account ID for the latter
+	PrincipalType string
+	// FamilyID is the refresh token family the access token was issued from, which identifies its session
+	FamilyID string
+	// IssuedAt, SessionExpiresAt and IdleTimeout let the session policy be enforced from the token alone
+	IssuedAt         time.Time
+	SessionExpiresAt time.Time
+	IdleTimeout      time.Duration
+	// ActorID is set on impersonation tokens to the admin acting as the user in UserID
+	ActorID string
+	// OrganizationID and OrganizationRole are set when the user acts in an organization
+	OrganizationID   string
+	OrganizationRole string
 }
 
-// Auth middleware handles JWT authentication - Single Responsibility Principle
+// Auth middleware handles JWT authentication - Single Responsibility Principle.
+// Tokens issued to OAuth clients are refused; see ClientAuth.
 func Auth(tokenService TokenService) gin.HandlerFunc {
+	return authenticate(tokenService, false)
+}
+
+// ClientAuth middleware is Auth for the endpoints OAuth clients call with the
+// tokens issued to them, such as userinfo
+func ClientAuth(tokenService TokenService) gin.HandlerFunc {
+	return authenticate(tokenService, true)
+}
+
+func authenticate(tokenService TokenService, allowClients bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Extract token from Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -45,14 +87,18 @@ func Auth(tokenService TokenService) gin.HandlerFunc {
 			return
 		}
 
-		// Check Bearer format
+		// Check Bearer or ApiKey format
 		parts := strings.Split(authHeader, " ")
+		if len(parts) == 2 && parts[0] == "ApiKey" {
+			authenticateAPIKey(c, tokenService, parts[1])
+			return
+		}
 		if len(parts) != 2 || parts[0] != "Bearer" {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
 				"error": gin.H{
 					"code":    "AUTH_INVALID_FORMAT",
-					"message": "Invalid authorization format. Use 'Bearer <token>'",
+					"message": "Invalid authorization format. Use 'Bearer <token>' or 'ApiKey <key>'",
 				},
 			})
 			c.Abort()
@@ -63,6 +109,17 @@ func Auth(tokenService TokenService) gin.HandlerFunc {
 
 		// Validate token
 		claims, err := tokenService.ValidateToken(token)
+		if errors.Is(err, auth.ErrTokenRevoked) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "AUTH_TOKEN_REVOKED",
+					"message": "Token has been revoked",
+				},
+			})
+			c.Abort()
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
@@ -75,13 +132,26 @@ func Auth(tokenService TokenService) gin.HandlerFunc {
 			return
 		}
 
-		// Check if token is blacklisted
-		if tokenService.IsTokenBlacklisted(token) {
+		// The session policy travels in the token, so enforcing it needs no lookup
+		if code, message, ended := sessionEnded(claims, time.Now()); ended {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
 				"error": gin.H{
-					"code":    "AUTH_TOKEN_REVOKED",
-					"message": "Token has been revoked",
+					"code":    code,
+					"message": message,
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		// A token a user granted to an OAuth client is only good for what the client was allowed
+		if isClientToken(claims) && !allowClients {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "CLIENT_TOKEN_NOT_ALLOWED",
+					"message": "Tokens issued to OAuth clients are not accepted by this endpoint",
 				},
 			})
 			c.Abort()
@@ -89,17 +159,153 @@ func Auth(tokenService TokenService) gin.HandlerFunc {
 		}
 
 		// Store user info in context for downstream handlers
-		c.Set("user_id", claims.UserID)
-		c.Set("email", claims.Email)
-		c.Set("roles", claims.Roles)
-		c.Set("permissions", claims.Permissions)
-		c.Set("authenticated", true)
+		setClaims(c, claims)
 		c.Set("token", token)
 
 		c.Next()
 	}
 }
 
+// sessionEnded reports whether the token's session has outlived its policy:
+// past its sliding or maximum lifetime, or unrefreshed for longer than its idle timeout
+func sessionEnded(claims *TokenClaims, now time.Time) (code, message string, ended bool) {
+	switch {
+	case !claims.SessionExpiresAt.IsZero() && now.After(claims.SessionExpiresAt):
+		return "SESSION_EXPIRED", "Session has expired; please sign in again", true
+	case claims.IdleTimeout > 0 && now.Sub(claims.IssuedAt) > claims.IdleTimeout:
+		return "SESSION_IDLE_TIMEOUT", "Session timed out after inactivity; please sign in again", true
+	default:
+		return "", "", false
+	}
+}
+
+// authenticateAPIKey handles the ApiKey authorization scheme
+func authenticateAPIKey(c *gin.Context, tokenService TokenService, key string) {
+	validator, ok := tokenService.(APIKeyValidator)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "AUTH_INVALID_FORMAT",
+				"message": "API keys are not accepted. Use 'Bearer <token>'",
+			},
+		})
+		c.Abort()
+		return
+	}
+
+	claims, err := validator.ValidateAPIKey(key, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, apikey.ErrIPNotAllowed) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "API_KEY_IP_NOT_ALLOWED",
+					"message": "API key is not allowed from this address",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "AUTH_INVALID_API_KEY",
+				"message": "Invalid, expired or revoked API key",
+			},
+		})
+		c.Abort()
+		return
+	}
+
+	setClaims(c, claims)
+	c.Next()
+}
+
+// setClaims stores the authenticated principal in the context for downstream handlers
+func setClaims(c *gin.Context, claims *TokenClaims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("email", claims.Email)
+	c.Set("roles", claims.Roles)
+	c.Set("permissions", claims.Permissions)
+	c.Set("withheld_permissions", claims.WithheldPermissions)
+	c.Set("email_verified", claims.EmailVerified)
+	c.Set("principal_type", principalType(claims))
+	if claims.ClientID != "" {
+		c.Set("client_id", claims.ClientID)
+		c.Set("scopes", claims.Scopes)
+	}
+	if claims.APIKeyID != "" {
+		c.Set("api_key_id", claims.APIKeyID)
+		c.Set("api_key_scopes", claims.Scopes)
+	}
+	if claims.FamilyID != "" {
+		c.Set("family_id", claims.FamilyID)
+	}
+	if claims.ActorID != "" {
+		c.Set("actor_id", claims.ActorID)
+	}
+	if claims.OrganizationID != "" {
+		c.Set("organization_id", claims.OrganizationID)
+		c.Set("organization_role", claims.OrganizationRole)
+	}
+	c.Set("authenticated", true)
+}
+
+// isClientToken reports whether the claims belong to a user's token issued to an OAuth client;
+// service account tokens also name a client but act for the account itself
+func isClientToken(claims *TokenClaims) bool {
+	return claims.ClientID != "" && principalType(claims) == string(auth.PrincipalUser)
+}
+
+// principalType returns the claims' principal type, treating tokens without one as users
+func principalType(claims *TokenClaims) string {
+	if claims.PrincipalType == "" {
+		return string(auth.PrincipalUser)
+	}
+	return claims.PrincipalType
+}
+
+// RequireUserPrincipal middleware rejects service accounts from endpoints that act on a user account
+func RequireUserPrincipal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("principal_type") == string(auth.PrincipalServiceAccount) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "PRINCIPAL_NOT_ALLOWED",
+					"message": "This endpoint is only available to user accounts",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ForbidAPIKey middleware keeps API keys away from managing the account and its
+// credentials, which RequirePermission scopes do not cover
+func ForbidAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("api_key_id") != "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "API_KEY_NOT_ALLOWED",
+					"message": "This endpoint is not available to API keys",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // RequireRole middleware checks if user has required role - Single Responsibility Principle
 func RequireRole(role string, rbacService RBACService) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -164,6 +370,36 @@ func RequirePermission(permission string, rbacService RBACService) gin.HandlerFu
 			return
 		}
 
+		// Permissions withheld by the email verification policy are refused before the RBAC lookup
+		if withheld, ok := c.Get("withheld_permissions"); ok {
+			for _, p := range withheld.([]string) {
+				if p == permission {
+					c.JSON(http.StatusForbidden, gin.H{
+						"success": false,
+						"error": gin.H{
+							"code":    "EMAIL_NOT_VERIFIED",
+							"message": "Verify your email address to access this resource",
+						},
+					})
+					c.Abort()
+					return
+				}
+			}
+		}
+
+		// API keys only carry the permissions they were scoped to
+		if scopes, ok := c.Get("api_key_scopes"); ok && !containsString(scopes.([]string), permission) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "API_KEY_SCOPE_INSUFFICIENT",
+					"message": "API key is not scoped for this resource",
+				},
+			})
+			c.Abort()
+			return
+		}
+
 		// Check if user has required permission
 		hasPermission, err := rbacService.UserHasPermission(userID.(string), permission)
 		if err != nil {
@@ -194,6 +430,15 @@ func RequirePermission(permission string, rbacService RBACService) gin.HandlerFu
 	}
 }
 
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // OptionalAuth middleware for endpoints that work with or without auth - Open/Closed Principle
 func OptionalAuth(tokenService TokenService) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -226,9 +471,8 @@ func OptionalAuth(tokenService TokenService) gin.HandlerFunc {
 			return
 		}
 
-		// Check if token is blacklisted
-		if tokenService.IsTokenBlacklisted(token) {
-			// Blacklisted token, continue as unauthenticated
+		// A token whose session has ended, or that was issued to an OAuth client, counts as no token
+		if _, _, ended := sessionEnded(claims, time.Now()); ended || isClientToken(claims) {
 			c.Next()
 			return
 		}
@@ -238,6 +482,10 @@ func OptionalAuth(tokenService TokenService) gin.HandlerFunc {
 		c.Set("email", claims.Email)
 		c.Set("roles", claims.Roles)
 		c.Set("permissions", claims.Permissions)
+		c.Set("principal_type", principalType(claims))
+		if claims.ActorID != "" {
+			c.Set("actor_id", claims.ActorID)
+		}
 		c.Set("authenticated", true)
 		c.Set("token", token)
 
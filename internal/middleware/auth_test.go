@@ -4,10 +4,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
 )
 
 // MockTokenService for testing
@@ -23,11 +26,6 @@ func (m *MockTokenService) ValidateToken(token string) (*TokenClaims, error) {
 	return args.Get(0).(*TokenClaims), args.Error(1)
 }
 
-func (m *MockTokenService) IsTokenBlacklisted(token string) bool {
-	args := m.Called(token)
-	return args.Bool(0)
-}
-
 // MockRBACService for testing
 type MockRBACService struct {
 	mock.Mock
@@ -114,12 +112,7 @@ func TestAuth_BlacklistedToken(t *testing.T) {
 	// Arrange
 	gin.SetMode(gin.TestMode)
 	mockTokenService := new(MockTokenService)
-	claims := &TokenClaims{
-		UserID: "user123",
-		Email:  "test@example.com",
-	}
-	mockTokenService.On("ValidateToken", "blacklisted-token").Return(claims, nil)
-	mockTokenService.On("IsTokenBlacklisted", "blacklisted-token").Return(true)
+	mockTokenService.On("ValidateToken", "blacklisted-token").Return(nil, auth.ErrTokenRevoked)
 
 	router := gin.New()
 	router.Use(Auth(mockTokenService))
@@ -139,6 +132,65 @@ func TestAuth_BlacklistedToken(t *testing.T) {
 	mockTokenService.AssertExpectations(t)
 }
 
+func TestAuth_EndedSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		claims *TokenClaims
+		code   string
+	}{
+		{
+			name: "past the session lifetime",
+			claims: &TokenClaims{
+				UserID:           "user123",
+				IssuedAt:         time.Now().Add(-time.Minute),
+				SessionExpiresAt: time.Now().Add(-time.Second),
+			},
+			code: "SESSION_EXPIRED",
+		},
+		{
+			name: "idle for too long",
+			claims: &TokenClaims{
+				UserID:      "user123",
+				IssuedAt:    time.Now().Add(-time.Hour),
+				IdleTimeout: 30 * time.Minute,
+			},
+			code: "SESSION_IDLE_TIMEOUT",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTokenService := new(MockTokenService)
+			mockTokenService.On("ValidateToken", "ended-token").Return(tt.claims, nil)
+
+			router := gin.New()
+			router.Use(Auth(mockTokenService))
+			router.GET("/test", func(c *gin.Context) {
+				c.JSON(200, gin.H{"message": "success"})
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer ended-token")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Contains(t, w.Body.String(), tt.code)
+		})
+	}
+
+	t.Run("within the session limits", func(t *testing.T) {
+		_, _, ended := sessionEnded(&TokenClaims{
+			IssuedAt:         time.Now().Add(-10 * time.Minute),
+			IdleTimeout:      30 * time.Minute,
+			SessionExpiresAt: time.Now().Add(time.Hour),
+		}, time.Now())
+		assert.False(t, ended)
+	})
+}
+
 func TestAuth_ValidToken(t *testing.T) {
 	// Arrange
 	gin.SetMode(gin.TestMode)
@@ -150,7 +202,6 @@ func TestAuth_ValidToken(t *testing.T) {
 		Permissions: []string{"read:profile"},
 	}
 	mockTokenService.On("ValidateToken", "valid-token").Return(claims, nil)
-	mockTokenService.On("IsTokenBlacklisted", "valid-token").Return(false)
 
 	router := gin.New()
 	router.Use(Auth(mockTokenService))
@@ -176,6 +227,105 @@ func TestAuth_ValidToken(t *testing.T) {
 	mockTokenService.AssertExpectations(t)
 }
 
+func TestAuth_ClientToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		middleware func(TokenService) gin.HandlerFunc
+		claims     *TokenClaims
+		wantStatus int
+	}{
+		{
+			name:       "refused by Auth",
+			middleware: Auth,
+			claims:     &TokenClaims{UserID: "user123", ClientID: "client123", Scopes: []string{"openid"}},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "accepted by ClientAuth",
+			middleware: ClientAuth,
+			claims:     &TokenClaims{UserID: "user123", ClientID: "client123", Scopes: []string{"openid"}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "service account token accepted by Auth",
+			middleware: Auth,
+			claims:     &TokenClaims{UserID: "account123", ClientID: "client123", PrincipalType: "service_account"},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			tokenService := NewSimpleTokenService()
+			tokenService.AddValidToken("client-token", tt.claims)
+
+			router := gin.New()
+			router.Use(tt.middleware(tokenService))
+			router.GET("/test", func(c *gin.Context) {
+				c.JSON(200, gin.H{"message": "success"})
+			})
+
+			// Act
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer client-token")
+			router.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), "CLIENT_TOKEN_NOT_ALLOWED")
+			}
+		})
+	}
+}
+
+func TestRequireUserPrincipal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		principalType string
+		wantStatus    int
+	}{
+		{name: "user", principalType: "user", wantStatus: http.StatusOK},
+		{name: "service account", principalType: "service_account", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockTokenService := new(MockTokenService)
+			mockTokenService.On("ValidateToken", "valid-token").Return(&TokenClaims{
+				UserID:        "principal123",
+				Roles:         []string{"user"},
+				PrincipalType: tt.principalType,
+			}, nil)
+
+			router := gin.New()
+			router.Use(Auth(mockTokenService), RequireUserPrincipal())
+			router.GET("/test", func(c *gin.Context) {
+				c.JSON(200, gin.H{"message": "success"})
+			})
+
+			// Act
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			router.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), "PRINCIPAL_NOT_ALLOWED")
+			}
+		})
+	}
+}
+
 func TestRequireRole_NoAuthentication(t *testing.T) {
 	// Arrange
 	gin.SetMode(gin.TestMode)
@@ -278,6 +428,177 @@ func TestRequirePermission_NoPermission(t *testing.T) {
 	mockRBACService.AssertExpectations(t)
 }
 
+func TestRequirePermission_WithheldUntilEmailVerified(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	mockTokenService := new(MockTokenService)
+	mockRBACService := new(MockRBACService)
+
+	claims := &TokenClaims{
+		UserID:              "user123",
+		Email:               "test@example.com",
+		EmailVerified:       false,
+		Roles:               []string{"admin"},
+		Permissions:         []string{"billing:read"},
+		WithheldPermissions: []string{"billing:write"},
+	}
+	mockTokenService.On("ValidateToken", "valid-token").Return(claims, nil)
+
+	router := gin.New()
+	router.Use(Auth(mockTokenService))
+	router.Use(RequirePermission("billing:write", mockRBACService))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "success"})
+	})
+
+	// Act
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "EMAIL_NOT_VERIFIED")
+	mockRBACService.AssertNotCalled(t, "UserHasPermission", mock.Anything, mock.Anything)
+}
+
+func TestAuth_APIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokenService := NewSimpleTokenService()
+	tokenService.AddValidAPIKey("umk_valid", &TokenClaims{
+		UserID:      "user123",
+		Roles:       []string{"user"},
+		Permissions: []string{"profile:read"},
+		Scopes:      []string{"profile:read"},
+		APIKeyID:    "key123",
+	})
+
+	router := gin.New()
+	router.Use(Auth(tokenService))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"user_id":    c.GetString("user_id"),
+			"api_key_id": c.GetString("api_key_id"),
+		})
+	})
+
+	t.Run("accepts a valid key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "ApiKey umk_valid")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"user_id":"user123"`)
+		assert.Contains(t, w.Body.String(), `"api_key_id":"key123"`)
+	})
+
+	t.Run("rejects an unknown key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "ApiKey umk_unknown")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "AUTH_INVALID_API_KEY")
+	})
+
+	t.Run("rejects keys when the token service does not support them", func(t *testing.T) {
+		router := gin.New()
+		router.Use(Auth(new(MockTokenService)))
+		router.GET("/test", func(c *gin.Context) {
+			c.JSON(200, gin.H{"message": "success"})
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "ApiKey umk_valid")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "AUTH_INVALID_FORMAT")
+	})
+}
+
+func TestForbidAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokenService := NewSimpleTokenService()
+	tokenService.AddValidToken("valid-token", &TokenClaims{UserID: "user123", Roles: []string{"user"}})
+	tokenService.AddValidAPIKey("umk_valid", &TokenClaims{
+		UserID:   "user123",
+		Roles:    []string{"user"},
+		Scopes:   []string{"profile:read"},
+		APIKeyID: "key123",
+	})
+
+	router := gin.New()
+	router.Use(Auth(tokenService), ForbidAPIKey())
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "success"})
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "session token", authorization: "Bearer valid-token", wantStatus: http.StatusOK},
+		{name: "API key", authorization: "ApiKey umk_valid", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", tt.authorization)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), "API_KEY_NOT_ALLOWED")
+			}
+		})
+	}
+}
+
+func TestRequirePermission_APIKeyScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokenService := NewSimpleTokenService()
+	tokenService.AddValidAPIKey("umk_valid", &TokenClaims{
+		UserID:      "user123",
+		Permissions: []string{"profile:read"},
+		Scopes:      []string{"profile:read"},
+		APIKeyID:    "key123",
+	})
+
+	// The user holds both permissions; the key is only scoped for one
+	rbacService := NewSimpleRBACService()
+	rbacService.SetUserPermissions("user123", []string{"profile:read", "profile:write"})
+
+	router := gin.New()
+	router.Use(Auth(tokenService))
+	router.GET("/read", RequirePermission("profile:read", rbacService), func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "success"})
+	})
+	router.GET("/write", RequirePermission("profile:write", rbacService), func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "success"})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/read", nil)
+	req.Header.Set("Authorization", "ApiKey umk_valid")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/write", nil)
+	req.Header.Set("Authorization", "ApiKey umk_valid")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "API_KEY_SCOPE_INSUFFICIENT")
+}
+
 func TestOptionalAuth_NoToken(t *testing.T) {
 	// Arrange
 	gin.SetMode(gin.TestMode)
@@ -309,7 +630,6 @@ func TestOptionalAuth_ValidToken(t *testing.T) {
 		Email:  "test@example.com",
 	}
 	mockTokenService.On("ValidateToken", "valid-token").Return(claims, nil)
-	mockTokenService.On("IsTokenBlacklisted", "valid-token").Return(false)
 
 	router := gin.New()
 	router.Use(OptionalAuth(mockTokenService))
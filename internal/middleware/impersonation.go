@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+)
+
+// ForbidImpersonation middleware keeps admins acting as a user away from
+// sensitive operations such as password, MFA and billing changes
+func ForbidImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("actor_id") != "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "IMPERSONATION_FORBIDDEN",
+					"message": "This action is not available while impersonating a user",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// AuditImpersonation middleware writes an audit entry for every request made
+// with an impersonation token, naming the admin as the actor
+func AuditImpersonation(auditService audit.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		actorID, err := uuid.Parse(c.GetString("actor_id"))
+		if err != nil {
+			return
+		}
+		userID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			return
+		}
+
+		severity := audit.SeverityInfo
+		if c.Writer.Status() == http.StatusForbidden {
+			severity = audit.SeverityWarning
+		}
+
+		// Errors are ignored so that auditing never changes the response
+		_, _ = auditService.Log(c.Request.Context(), &audit.CreateLogRequest{
+			EventType:   audit.EventTypeImpersonatedRequest,
+			Severity:    severity,
+			UserID:      &userID,
+			ActorID:     &actorID,
+			ActorType:   audit.ActorTypeUser,
+			EntityType:  "user",
+			EntityID:    userID.String(),
+			Action:      c.Request.Method,
+			Description: "Request made while impersonating user",
+			IPAddress:   c.ClientIP(),
+			UserAgent:   c.Request.UserAgent(),
+			RequestID:   c.GetString("request_id"),
+			Metadata: map[string]interface{}{
+				"method": c.Request.Method,
+				"path":   c.Request.URL.Path,
+				"status": c.Writer.Status(),
+			},
+		})
+	}
+}
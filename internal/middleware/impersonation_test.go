@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+)
+
+// RecordingAuditService keeps the entries it is asked to log.
+// Only Log is implemented; calling any other method panics.
+type RecordingAuditService struct {
+	audit.AuditService
+	entries []*audit.CreateLogRequest
+}
+
+func (r *RecordingAuditService) Log(ctx context.Context, req *audit.CreateLogRequest) (*audit.LogEntry, error) {
+	r.entries = append(r.entries, req)
+	return &audit.LogEntry{}, nil
+}
+
+func TestImpersonation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	userID := uuid.New().String()
+	adminID := uuid.New().String()
+
+	setup := func() (*gin.Engine, *RecordingAuditService) {
+		tokenService := NewSimpleTokenService()
+		tokenService.AddValidToken("own-token", &TokenClaims{UserID: userID})
+		tokenService.AddValidToken("impersonation-token", &TokenClaims{UserID: userID, ActorID: adminID})
+
+		auditService := &RecordingAuditService{}
+		router := gin.New()
+		router.Use(Auth(tokenService), AuditImpersonation(auditService))
+		router.GET("/profile", func(c *gin.Context) {
+			c.JSON(200, gin.H{"message": "success"})
+		})
+		router.POST("/password", ForbidImpersonation(), func(c *gin.Context) {
+			c.JSON(200, gin.H{"message": "success"})
+		})
+		return router, auditService
+	}
+
+	request := func(router *gin.Engine, method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("audits every request made while impersonating", func(t *testing.T) {
+		router, auditService := setup()
+
+		w := request(router, "GET", "/profile", "impersonation-token")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		require.Len(t, auditService.entries, 1)
+		entry := auditService.entries[0]
+		assert.Equal(t, audit.EventTypeImpersonatedRequest, entry.EventType)
+		assert.Equal(t, adminID, entry.ActorID.String())
+		assert.Equal(t, userID, entry.UserID.String())
+		assert.Equal(t, "/profile", entry.Metadata["path"])
+	})
+
+	t.Run("refuses sensitive operations while impersonating", func(t *testing.T) {
+		router, auditService := setup()
+
+		w := request(router, "POST", "/password", "impersonation-token")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "IMPERSONATION_FORBIDDEN")
+
+		// Refused attempts are audited too
+		require.Len(t, auditService.entries, 1)
+		assert.Equal(t, http.StatusForbidden, auditService.entries[0].Metadata["status"])
+	})
+
+	t.Run("leaves users' own requests alone", func(t *testing.T) {
+		router, auditService := setup()
+
+		w := request(router, "POST", "/password", "own-token")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, auditService.entries)
+	})
+}
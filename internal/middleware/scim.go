@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/victoralfred/um_sys/internal/domain/scim"
+)
+
+// SCIMTokenValidator resolves provisioning bearer tokens to the organization they belong to
+type SCIMTokenValidator interface {
+	AuthenticateToken(ctx context.Context, token string) (*scim.Token, error)
+}
+
+// SCIMAuth middleware authenticates an identity provider's provisioning requests.
+// Failures use the SCIM error format because SCIM clients do not understand ours.
+func SCIMAuth(validator SCIMTokenValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			scimUnauthorized(c, "Authorization header must use the Bearer scheme")
+			return
+		}
+
+		token, err := validator.AuthenticateToken(c.Request.Context(), parts[1])
+		if err != nil {
+			scimUnauthorized(c, "Invalid provisioning token")
+			return
+		}
+
+		c.Set("scim_organization_id", token.OrganizationID.String())
+		c.Set("scim_token_id", token.ID.String())
+
+		c.Next()
+	}
+}
+
+func scimUnauthorized(c *gin.Context, detail string) {
+	c.Header("WWW-Authenticate", `Bearer realm="SCIM"`)
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(http.StatusUnauthorized, scim.NewErrorResponse(http.StatusUnauthorized, "", detail))
+	c.Abort()
+}
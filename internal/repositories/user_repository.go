@@ -65,13 +65,15 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*user.User,
 			first_name, last_name, phone_number,
 			is_active, is_verified, verified_at,
 			last_login_at, failed_login_attempts, locked_until,
-			mfa_enabled, created_at, updated_at
+			mfa_enabled, password_reset_token_hash, password_reset_expires_at,
+			password_changed_at, created_at, updated_at
 		FROM users
 		WHERE id = $1 AND deleted_at IS NULL`
 
 	var u user.User
 	var isActive, isVerified, mfaEnabled bool
-	var verifiedAt, lastLoginAt, lockedUntil sql.NullTime
+	var verifiedAt, lastLoginAt, lockedUntil, resetExpiry, passwordChangedAt sql.NullTime
+	var resetTokenHash sql.NullString
 
 	err := r.db.QueryRow(ctx, query, id).Scan(
 		&u.ID,
@@ -88,6 +90,9 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*user.User,
 		&u.FailedLoginAttempts,
 		&lockedUntil,
 		&mfaEnabled,
+		&resetTokenHash,
+		&resetExpiry,
+		&passwordChangedAt,
 		&u.CreatedAt,
 		&u.UpdatedAt,
 	)
@@ -117,6 +122,13 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*user.User,
 		u.LockedUntil = &lockedUntil.Time
 	}
 	u.MFAEnabled = mfaEnabled
+	u.PasswordResetToken = resetTokenHash.String
+	if resetExpiry.Valid {
+		u.PasswordResetExpiry = &resetExpiry.Time
+	}
+	if passwordChangedAt.Valid {
+		u.PasswordChangedAt = &passwordChangedAt.Time
+	}
 
 	return &u, nil
 }
@@ -129,13 +141,15 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*user.Us
 			first_name, last_name, phone_number,
 			is_active, is_verified, verified_at,
 			last_login_at, failed_login_attempts, locked_until,
-			mfa_enabled, created_at, updated_at
+			mfa_enabled, password_reset_token_hash, password_reset_expires_at,
+			password_changed_at, created_at, updated_at
 		FROM users
 		WHERE email = $1 AND deleted_at IS NULL`
 
 	var u user.User
 	var isActive, isVerified, mfaEnabled bool
-	var verifiedAt, lastLoginAt, lockedUntil sql.NullTime
+	var verifiedAt, lastLoginAt, lockedUntil, resetExpiry, passwordChangedAt sql.NullTime
+	var resetTokenHash sql.NullString
 
 	err := r.db.QueryRow(ctx, query, email).Scan(
 		&u.ID,
@@ -152,6 +166,9 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*user.Us
 		&u.FailedLoginAttempts,
 		&lockedUntil,
 		&mfaEnabled,
+		&resetTokenHash,
+		&resetExpiry,
+		&passwordChangedAt,
 		&u.CreatedAt,
 		&u.UpdatedAt,
 	)
@@ -181,6 +198,13 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*user.Us
 		u.LockedUntil = &lockedUntil.Time
 	}
 	u.MFAEnabled = mfaEnabled
+	u.PasswordResetToken = resetTokenHash.String
+	if resetExpiry.Valid {
+		u.PasswordResetExpiry = &resetExpiry.Time
+	}
+	if passwordChangedAt.Valid {
+		u.PasswordChangedAt = &passwordChangedAt.Time
+	}
 
 	return &u, nil
 }
@@ -193,13 +217,15 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*u
 			first_name, last_name, phone_number,
 			is_active, is_verified, verified_at,
 			last_login_at, failed_login_attempts, locked_until,
-			mfa_enabled, created_at, updated_at
+			mfa_enabled, password_reset_token_hash, password_reset_expires_at,
+			password_changed_at, created_at, updated_at
 		FROM users
 		WHERE username = $1 AND deleted_at IS NULL`
 
 	var u user.User
 	var isActive, isVerified, mfaEnabled bool
-	var verifiedAt, lastLoginAt, lockedUntil sql.NullTime
+	var verifiedAt, lastLoginAt, lockedUntil, resetExpiry, passwordChangedAt sql.NullTime
+	var resetTokenHash sql.NullString
 
 	err := r.db.QueryRow(ctx, query, username).Scan(
 		&u.ID,
@@ -216,6 +242,9 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*u
 		&u.FailedLoginAttempts,
 		&lockedUntil,
 		&mfaEnabled,
+		&resetTokenHash,
+		&resetExpiry,
+		&passwordChangedAt,
 		&u.CreatedAt,
 		&u.UpdatedAt,
 	)
@@ -245,6 +274,13 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*u
 		u.LockedUntil = &lockedUntil.Time
 	}
 	u.MFAEnabled = mfaEnabled
+	u.PasswordResetToken = resetTokenHash.String
+	if resetExpiry.Valid {
+		u.PasswordResetExpiry = &resetExpiry.Time
+	}
+	if passwordChangedAt.Valid {
+		u.PasswordChangedAt = &passwordChangedAt.Time
+	}
 
 	return &u, nil
 }
@@ -267,7 +303,10 @@ func (r *UserRepository) Update(ctx context.Context, u *user.User) error {
 			locked_until = $13,
 			mfa_enabled = $14,
 			mfa_secret = $15,
-			updated_at = $16
+			updated_at = $16,
+			password_reset_token_hash = $17,
+			password_reset_expires_at = $18,
+			password_changed_at = $19
 		WHERE id = $1 AND deleted_at IS NULL`
 
 	var verifiedAt, lastLoginAt, lockedUntil *time.Time
@@ -281,6 +320,12 @@ func (r *UserRepository) Update(ctx context.Context, u *user.User) error {
 		lockedUntil = u.LockedUntil
 	}
 
+	// Reset tokens are stored hashed; an empty hash clears a consumed token
+	var resetTokenHash *string
+	if u.PasswordResetToken != "" {
+		resetTokenHash = &u.PasswordResetToken
+	}
+
 	result, err := r.db.Exec(ctx, query,
 		u.ID,
 		u.Email,
@@ -298,6 +343,9 @@ func (r *UserRepository) Update(ctx context.Context, u *user.User) error {
 		u.MFAEnabled,
 		u.MFASecret,
 		time.Now(),
+		resetTokenHash,
+		u.PasswordResetExpiry,
+		u.PasswordChangedAt,
 	)
 
 	if err != nil {
@@ -368,6 +416,31 @@ func (r *UserRepository) ExistsByUsername(ctx context.Context, username string)
 	return exists, nil
 }
 
+// EachPasswordHash calls fn with the password hash of every account
+func (r *UserRepository) EachPasswordHash(ctx context.Context, fn func(hash string) error) error {
+	query := `
+		SELECT password_hash FROM users
+		WHERE deleted_at IS NULL AND password_hash <> ''`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to list password hashes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return fmt.Errorf("failed to scan password hash: %w", err)
+		}
+		if err := fn(hash); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
 // UpdateLastLogin updates the user's last login time and IP
 func (r *UserRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID, loginTime time.Time) error {
 	query := `
@@ -392,23 +465,61 @@ func (r *UserRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID, logi
 	return nil
 }
 
-// IncrementFailedLoginAttempts increments failed login attempts
-func (r *UserRepository) IncrementFailedLoginAttempts(ctx context.Context, id uuid.UUID) error {
+// IncrementFailedLoginAttempts increments failed login attempts and returns the new count.
+// Whether the account is then locked is decided by the lockout policy.
+func (r *UserRepository) IncrementFailedLoginAttempts(ctx context.Context, id uuid.UUID) (int, error) {
 	query := `
 		UPDATE users 
 		SET 
 			failed_login_attempts = failed_login_attempts + 1,
-			locked_until = CASE 
-				WHEN failed_login_attempts >= 4 THEN NOW() + INTERVAL '15 minutes'
-				ELSE locked_until
-			END,
 			updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING failed_login_attempts`
+
+	var attempts int
+	err := r.db.QueryRow(ctx, query, id).Scan(&attempts)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, user.ErrUserNotFound
+		}
+		return 0, fmt.Errorf("failed to increment failed login attempts: %w", err)
+	}
+
+	return attempts, nil
+}
+
+// Lock locks a user out of signing in until the given time
+func (r *UserRepository) Lock(ctx context.Context, id uuid.UUID, until time.Time) error {
+	query := `
+		UPDATE users 
+		SET locked_until = $2, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := r.db.Exec(ctx, query, id, until)
+
+	if err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return user.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Unlock clears a lockout and the failed login attempts that led to it
+func (r *UserRepository) Unlock(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE users 
+		SET failed_login_attempts = 0, locked_until = NULL, updated_at = NOW()
 		WHERE id = $1 AND deleted_at IS NULL`
 
 	result, err := r.db.Exec(ctx, query, id)
 
 	if err != nil {
-		return fmt.Errorf("failed to increment failed login attempts: %w", err)
+		return fmt.Errorf("failed to unlock user: %w", err)
 	}
 
 	if result.RowsAffected() == 0 {
@@ -427,7 +538,8 @@ func (r *UserRepository) List(ctx context.Context, filter user.ListFilter) ([]*u
 			first_name, last_name, phone_number,
 			is_active, is_verified, verified_at,
 			last_login_at, failed_login_attempts, locked_until,
-			mfa_enabled, created_at, updated_at
+			mfa_enabled, password_reset_token_hash, password_reset_expires_at,
+			password_changed_at, created_at, updated_at
 		FROM users
 		WHERE deleted_at IS NULL`
 
@@ -482,7 +594,8 @@ func (r *UserRepository) List(ctx context.Context, filter user.ListFilter) ([]*u
 	for rows.Next() {
 		var u user.User
 		var isActive, isVerified, mfaEnabled bool
-		var verifiedAt, lastLoginAt, lockedUntil sql.NullTime
+		var verifiedAt, lastLoginAt, lockedUntil, resetExpiry, passwordChangedAt sql.NullTime
+		var resetTokenHash sql.NullString
 
 		err := rows.Scan(
 			&u.ID,
@@ -499,6 +612,9 @@ func (r *UserRepository) List(ctx context.Context, filter user.ListFilter) ([]*u
 			&u.FailedLoginAttempts,
 			&lockedUntil,
 			&mfaEnabled,
+			&resetTokenHash,
+			&resetExpiry,
+			&passwordChangedAt,
 			&u.CreatedAt,
 			&u.UpdatedAt,
 		)
@@ -525,6 +641,13 @@ func (r *UserRepository) List(ctx context.Context, filter user.ListFilter) ([]*u
 			u.LockedUntil = &lockedUntil.Time
 		}
 		u.MFAEnabled = mfaEnabled
+		u.PasswordResetToken = resetTokenHash.String
+		if resetExpiry.Valid {
+			u.PasswordResetExpiry = &resetExpiry.Time
+		}
+		if passwordChangedAt.Valid {
+			u.PasswordChangedAt = &passwordChangedAt.Time
+		}
 
 		users = append(users, &u)
 	}
@@ -43,12 +43,31 @@ type Services struct {
 	AuditService   *services.AuditService
 	// FeatureService   *services.FeatureService // Replaced with FeatureFlagService
 	AnalyticsService *services.AnalyticsService
+	SCIMTokens       middleware.SCIMTokenValidator // Authenticates identity providers' provisioning requests
 
 	// Handlers
-	AuthHandler      *handlers.AuthHandler
-	ProfileHandler   *handlers.ProfileHandler
-	DocsHandler      *handlers.DocsHandler
-	AnalyticsHandler *handlers.AnalyticsHandler
+	AuthHandler              *handlers.AuthHandler
+	ProfileHandler           *handlers.ProfileHandler
+	DocsHandler              *handlers.DocsHandler
+	AnalyticsHandler         *handlers.AnalyticsHandler
+	JWKSHandler              *handlers.JWKSHandler
+	PasswordHandler          *handlers.PasswordHandler
+	EmailVerificationHandler *handlers.EmailVerificationHandler
+	OAuthHandler             *handlers.OAuthHandler
+	FederationHandler        *handlers.FederationHandler
+	SAMLHandler              *handlers.SAMLHandler
+	SCIMHandler              *handlers.SCIMHandler
+	APIKeyHandler            *handlers.APIKeyHandler
+	ServiceAccountHandler    *handlers.ServiceAccountHandler
+	WebAuthnHandler          *handlers.WebAuthnHandler
+	MagicLinkHandler         *handlers.MagicLinkHandler
+	TrustedDeviceHandler     *handlers.TrustedDeviceHandler
+	SessionHandler           *handlers.SessionHandler
+	AdminUserHandler         *handlers.AdminUserHandler
+	ImpersonationHandler     *handlers.ImpersonationHandler
+	PasswordHashHandler      *handlers.PasswordHashHandler
+	OrganizationHandler      *handlers.OrganizationHandler
+	InvitationHandler        *handlers.InvitationHandler
 }
 
 // New creates a new server instance - Factory pattern
@@ -109,13 +128,27 @@ func (s *HTTPServer) setupRoutes() {
 	// Protected routes
 	protected := v1.Group("")
 	protected.Use(middleware.Auth(s.services.TokenService))
+	if s.services.AuditService != nil {
+		protected.Use(middleware.AuditImpersonation(s.services.AuditService))
+	}
 	s.setupProtectedRoutes(protected)
 
-	// Admin routes
+	// Endpoints OAuth clients call with the tokens users granted them
+	clients := v1.Group("")
+	clients.Use(middleware.ClientAuth(s.services.TokenService))
+	s.setupClientRoutes(clients)
+
+	// Admin routes, which impersonation tokens and API keys never reach; key scopes
+	// cannot narrow what a role grants, so an admin's key would act as the admin
 	admin := v1.Group("/admin")
 	admin.Use(middleware.Auth(s.services.TokenService))
+	admin.Use(middleware.ForbidImpersonation())
+	admin.Use(middleware.ForbidAPIKey())
 	admin.Use(middleware.RequireRole("admin", s.services.RBACService))
 	s.setupAdminRoutes(admin)
+
+	// SCIM provisioning, authenticated with organizations' provisioning tokens
+	s.setupSCIMRoutes(s.router.Group("/scim/v2"))
 }
 
 // setupPublicRoutes sets up public endpoints
@@ -133,29 +166,123 @@ func (s *HTTPServer) setupPublicRoutes(rg *gin.RouterGroup) {
 		rg.GET("/docs/", s.services.DocsHandler.GetDocsIndex)
 	}
 
+	// Token verification keys
+	if s.services.JWKSHandler != nil {
+		rg.GET("/.well-known/jwks.json", s.services.JWKSHandler.GetJWKS)
+	} else {
+		rg.GET("/.well-known/jwks.json", s.notImplemented)
+	}
+
+	// OpenID Connect provider
+	oauth := rg.Group("/oauth")
+	if s.services.OAuthHandler != nil {
+		rg.GET("/.well-known/openid-configuration", s.services.OAuthHandler.Discovery)
+		oauth.GET("/authorize", s.services.OAuthHandler.StartAuthorization)
+		oauth.POST("/token", s.services.OAuthHandler.Token)
+		oauth.POST("/introspect", s.services.OAuthHandler.Introspect)
+		oauth.POST("/revoke", s.services.OAuthHandler.Revoke)
+	} else {
+		rg.GET("/.well-known/openid-configuration", s.notImplemented)
+		oauth.GET("/authorize", s.notImplemented)
+		oauth.POST("/token", s.notImplemented)
+		oauth.POST("/introspect", s.notImplemented)
+		oauth.POST("/revoke", s.notImplemented)
+	}
+
 	// Auth endpoints
 	auth := rg.Group("/auth")
 	{
 		if s.services.AuthHandler != nil {
 			auth.POST("/register", s.services.AuthHandler.Register)
 			auth.POST("/login", s.services.AuthHandler.Login)
+			auth.POST("/login/security-key", s.services.AuthHandler.BeginSecurityKeyLogin)
 			auth.POST("/refresh", s.services.AuthHandler.RefreshToken)
 		} else {
 			auth.POST("/register", s.notImplemented)
 			auth.POST("/login", s.notImplemented)
+			auth.POST("/login/security-key", s.notImplemented)
 			auth.POST("/refresh", s.notImplemented)
 		}
-		auth.POST("/password/forgot", s.notImplemented)
-		auth.POST("/password/reset", s.notImplemented)
-		auth.POST("/email/verify", s.notImplemented)
+		if s.services.PasswordHandler != nil {
+			auth.POST("/password/forgot", s.services.PasswordHandler.ForgotPassword)
+			auth.POST("/password/reset", s.services.PasswordHandler.ResetPassword)
+			auth.POST("/password/expired", s.services.PasswordHandler.ChangeExpiredPassword)
+		} else {
+			auth.POST("/password/forgot", s.notImplemented)
+			auth.POST("/password/reset", s.notImplemented)
+			auth.POST("/password/expired", s.notImplemented)
+		}
+		if s.services.EmailVerificationHandler != nil {
+			auth.POST("/email/verify", s.services.EmailVerificationHandler.VerifyEmail)
+		} else {
+			auth.POST("/email/verify", s.notImplemented)
+		}
+		if s.services.FederationHandler != nil {
+			auth.GET("/federated/providers", s.services.FederationHandler.ListProviders)
+			auth.GET("/federated/:provider", s.services.FederationHandler.StartLogin)
+			auth.GET("/federated/:provider/callback", s.services.FederationHandler.Callback)
+		} else {
+			auth.GET("/federated/providers", s.notImplemented)
+			auth.GET("/federated/:provider", s.notImplemented)
+			auth.GET("/federated/:provider/callback", s.notImplemented)
+		}
+		if s.services.SAMLHandler != nil {
+			auth.GET("/saml/:orgId/metadata", s.services.SAMLHandler.Metadata)
+			auth.GET("/saml/:orgId/login", s.services.SAMLHandler.StartLogin)
+			auth.POST("/saml/:orgId/acs", s.services.SAMLHandler.ConsumeAssertion)
+		} else {
+			auth.GET("/saml/:orgId/metadata", s.notImplemented)
+			auth.GET("/saml/:orgId/login", s.notImplemented)
+			auth.POST("/saml/:orgId/acs", s.notImplemented)
+		}
+		if s.services.WebAuthnHandler != nil {
+			auth.POST("/passkey/options", s.services.WebAuthnHandler.BeginPasskeyLogin)
+			auth.POST("/passkey/login", s.services.WebAuthnHandler.PasskeyLogin)
+		} else {
+			auth.POST("/passkey/options", s.notImplemented)
+			auth.POST("/passkey/login", s.notImplemented)
+		}
+		if s.services.MagicLinkHandler != nil {
+			auth.POST("/magic-link", s.services.MagicLinkHandler.RequestLink)
+			auth.POST("/magic-link/verify", s.services.MagicLinkHandler.RedeemLink)
+		} else {
+			auth.POST("/magic-link", s.notImplemented)
+			auth.POST("/magic-link/verify", s.notImplemented)
+		}
+	}
+
+	// Accepting an organization invitation by creating an account
+	if s.services.InvitationHandler != nil {
+		rg.POST("/invitations/register", s.services.InvitationHandler.AcceptWithRegistration)
+	} else {
+		rg.POST("/invitations/register", s.notImplemented)
 	}
 
 	// Public billing endpoint
 	rg.GET("/billing/plans", s.getPlans)
 }
 
+// setupClientRoutes sets up the endpoints that also accept tokens issued to OAuth clients
+func (s *HTTPServer) setupClientRoutes(rg *gin.RouterGroup) {
+	oauth := rg.Group("/oauth")
+	{
+		if s.services.OAuthHandler != nil {
+			oauth.GET("/userinfo", s.services.OAuthHandler.UserInfo)
+			oauth.POST("/userinfo", s.services.OAuthHandler.UserInfo)
+		} else {
+			oauth.GET("/userinfo", s.notImplemented)
+			oauth.POST("/userinfo", s.notImplemented)
+		}
+	}
+}
+
 // setupProtectedRoutes sets up authenticated endpoints
 func (s *HTTPServer) setupProtectedRoutes(rg *gin.RouterGroup) {
+	// Sensitive operations are refused to admins impersonating a user
+	noImpersonation := middleware.ForbidImpersonation()
+	// Managing the account and its credentials is refused to API keys
+	noAPIKey := middleware.ForbidAPIKey()
+
 	// Auth endpoints
 	auth := rg.Group("/auth")
 	{
@@ -164,14 +291,51 @@ func (s *HTTPServer) setupProtectedRoutes(rg *gin.RouterGroup) {
 		} else {
 			auth.POST("/logout", s.notImplemented)
 		}
-		auth.GET("/sessions", s.notImplemented)
-		auth.DELETE("/sessions/:sessionId", s.notImplemented)
-		auth.POST("/email/resend", s.notImplemented)
+		if s.services.SessionHandler != nil {
+			auth.GET("/sessions", s.services.SessionHandler.ListSessions)
+			auth.DELETE("/sessions", noImpersonation, noAPIKey, s.services.SessionHandler.RevokeOtherSessions)
+			auth.DELETE("/sessions/:sessionId", noImpersonation, noAPIKey, s.services.SessionHandler.RevokeSession)
+		} else {
+			auth.GET("/sessions", s.notImplemented)
+			auth.DELETE("/sessions", s.notImplemented)
+			auth.DELETE("/sessions/:sessionId", s.notImplemented)
+		}
+		if s.services.EmailVerificationHandler != nil {
+			auth.POST("/email/resend", s.services.EmailVerificationHandler.ResendVerification)
+		} else {
+			auth.POST("/email/resend", s.notImplemented)
+		}
+		if s.services.ImpersonationHandler != nil {
+			auth.DELETE("/impersonation", s.services.ImpersonationHandler.End)
+		} else {
+			auth.DELETE("/impersonation", s.notImplemented)
+		}
+		if s.services.OrganizationHandler != nil {
+			auth.POST("/organization", middleware.RequireUserPrincipal(), noImpersonation, noAPIKey, s.services.OrganizationHandler.Switch)
+		} else {
+			auth.POST("/organization", s.notImplemented)
+		}
 		auth.POST("/permissions/check", s.notImplemented)
 	}
 
-	// User endpoints
+	// OpenID Connect provider endpoints for signed-in users
+	oauth := rg.Group("/oauth")
+	oauth.Use(noAPIKey)
+	{
+		if s.services.OAuthHandler != nil {
+			oauth.POST("/authorize", noImpersonation, s.services.OAuthHandler.Authorize)
+			oauth.GET("/consents", s.services.OAuthHandler.ListConsents)
+			oauth.DELETE("/consents/:clientId", noImpersonation, s.services.OAuthHandler.RevokeConsent)
+		} else {
+			oauth.POST("/authorize", s.notImplemented)
+			oauth.GET("/consents", s.notImplemented)
+			oauth.DELETE("/consents/:clientId", s.notImplemented)
+		}
+	}
+
+	// User endpoints act on the caller's own account, which service accounts do not have
 	users := rg.Group("/users")
+	users.Use(middleware.RequireUserPrincipal())
 	{
 		if s.services.AuthHandler != nil {
 			users.GET("/me", s.services.AuthHandler.GetCurrentUser)
@@ -179,18 +343,96 @@ func (s *HTTPServer) setupProtectedRoutes(rg *gin.RouterGroup) {
 			users.GET("/me", s.notImplemented)
 		}
 		if s.services.ProfileHandler != nil {
-			users.PATCH("/me", s.services.ProfileHandler.UpdateProfile)
+			users.PATCH("/me", noAPIKey, s.services.ProfileHandler.UpdateProfile)
 			users.POST("/me/avatar", s.services.ProfileHandler.UploadProfilePicture)
 		} else {
 			users.PATCH("/me", s.notImplemented)
 			users.POST("/me/avatar", s.notImplemented)
 		}
-		users.POST("/me/password", s.notImplemented)
-		users.DELETE("/me", s.notImplemented)
+		if s.services.FederationHandler != nil {
+			users.GET("/me/identities", s.services.FederationHandler.ListIdentities)
+			users.POST("/me/identities/:provider", noImpersonation, noAPIKey, s.services.FederationHandler.LinkIdentity)
+			users.DELETE("/me/identities/:identityId", noImpersonation, noAPIKey, s.services.FederationHandler.UnlinkIdentity)
+		} else {
+			users.GET("/me/identities", s.notImplemented)
+			users.POST("/me/identities/:provider", s.notImplemented)
+			users.DELETE("/me/identities/:identityId", s.notImplemented)
+		}
+		if s.services.APIKeyHandler != nil {
+			users.GET("/me/api-keys", noAPIKey, s.services.APIKeyHandler.ListKeys)
+			users.POST("/me/api-keys", noImpersonation, noAPIKey, s.services.APIKeyHandler.CreateKey)
+			users.DELETE("/me/api-keys/:keyId", noImpersonation, noAPIKey, s.services.APIKeyHandler.RevokeKey)
+		} else {
+			users.GET("/me/api-keys", s.notImplemented)
+			users.POST("/me/api-keys", s.notImplemented)
+			users.DELETE("/me/api-keys/:keyId", s.notImplemented)
+		}
+		users.POST("/me/password", noImpersonation, noAPIKey, s.notImplemented)
+		users.DELETE("/me", noImpersonation, noAPIKey, s.notImplemented)
 		users.GET("/me/roles", s.notImplemented)
 		users.GET("/search", s.notImplemented)
 	}
 
+	// Organization endpoints; the caller's role in each organization decides what they may do
+	organizations := rg.Group("/organizations")
+	organizations.Use(middleware.RequireUserPrincipal())
+	organizations.Use(noAPIKey)
+	{
+		if s.services.OrganizationHandler != nil {
+			organizations.GET("", s.services.OrganizationHandler.List)
+			organizations.POST("", noImpersonation, s.services.OrganizationHandler.Create)
+			organizations.GET("/:orgId", s.services.OrganizationHandler.Get)
+			organizations.PATCH("/:orgId", noImpersonation, s.services.OrganizationHandler.Update)
+			organizations.DELETE("/:orgId", noImpersonation, s.services.OrganizationHandler.Delete)
+			organizations.GET("/:orgId/members", s.services.OrganizationHandler.ListMembers)
+			organizations.POST("/:orgId/members", noImpersonation, s.services.OrganizationHandler.AddMember)
+			organizations.PATCH("/:orgId/members/:userId", noImpersonation, s.services.OrganizationHandler.UpdateMember)
+			organizations.DELETE("/:orgId/members/:userId", noImpersonation, s.services.OrganizationHandler.RemoveMember)
+		} else {
+			organizations.GET("", s.notImplemented)
+			organizations.POST("", s.notImplemented)
+			organizations.GET("/:orgId", s.notImplemented)
+			organizations.PATCH("/:orgId", s.notImplemented)
+			organizations.DELETE("/:orgId", s.notImplemented)
+			organizations.GET("/:orgId/members", s.notImplemented)
+			organizations.POST("/:orgId/members", s.notImplemented)
+			organizations.PATCH("/:orgId/members/:userId", s.notImplemented)
+			organizations.DELETE("/:orgId/members/:userId", s.notImplemented)
+		}
+		if s.services.InvitationHandler != nil {
+			organizations.GET("/:orgId/invitations", s.services.InvitationHandler.List)
+			organizations.POST("/:orgId/invitations", noImpersonation, s.services.InvitationHandler.Invite)
+			organizations.POST("/:orgId/invitations/:invitationId/resend", noImpersonation, s.services.InvitationHandler.Resend)
+			organizations.DELETE("/:orgId/invitations/:invitationId", noImpersonation, s.services.InvitationHandler.Revoke)
+		} else {
+			organizations.GET("/:orgId/invitations", s.notImplemented)
+			organizations.POST("/:orgId/invitations", s.notImplemented)
+			organizations.POST("/:orgId/invitations/:invitationId/resend", s.notImplemented)
+			organizations.DELETE("/:orgId/invitations/:invitationId", s.notImplemented)
+		}
+		if s.services.APIKeyHandler != nil {
+			organizations.GET("/:orgId/api-keys", s.services.APIKeyHandler.ListOrganizationKeys)
+			organizations.POST("/:orgId/api-keys", noImpersonation, s.services.APIKeyHandler.CreateOrganizationKey)
+			organizations.DELETE("/:orgId/api-keys/:keyId", noImpersonation, s.services.APIKeyHandler.RevokeOrganizationKey)
+		} else {
+			organizations.GET("/:orgId/api-keys", s.notImplemented)
+			organizations.POST("/:orgId/api-keys", s.notImplemented)
+			organizations.DELETE("/:orgId/api-keys/:keyId", s.notImplemented)
+		}
+	}
+
+	// Accepting an organization invitation sent to the caller's address
+	invitations := rg.Group("/invitations")
+	invitations.Use(middleware.RequireUserPrincipal())
+	invitations.Use(noAPIKey)
+	{
+		if s.services.InvitationHandler != nil {
+			invitations.POST("/accept", noImpersonation, s.services.InvitationHandler.Accept)
+		} else {
+			invitations.POST("/accept", s.notImplemented)
+		}
+	}
+
 	// Profile endpoints
 	profile := rg.Group("/profile")
 	{
@@ -209,8 +451,11 @@ func (s *HTTPServer) setupProtectedRoutes(rg *gin.RouterGroup) {
 		}
 	}
 
-	// MFA endpoints
+	// MFA endpoints manage second factors on the caller's own account
 	mfa := rg.Group("/mfa")
+	mfa.Use(middleware.RequireUserPrincipal())
+	mfa.Use(noImpersonation)
+	mfa.Use(noAPIKey)
 	{
 		mfa.GET("/status", s.notImplemented)
 		mfa.POST("/totp/setup", s.notImplemented)
@@ -220,10 +465,38 @@ func (s *HTTPServer) setupProtectedRoutes(rg *gin.RouterGroup) {
 		mfa.POST("/challenge", s.notImplemented)
 		mfa.POST("/backup-codes/regenerate", s.notImplemented)
 		mfa.DELETE("/disable", s.notImplemented)
+		if s.services.WebAuthnHandler != nil {
+			mfa.POST("/webauthn/registration/options", s.services.WebAuthnHandler.BeginRegistration)
+			mfa.POST("/webauthn/registration", s.services.WebAuthnHandler.FinishRegistration)
+			mfa.GET("/webauthn/credentials", s.services.WebAuthnHandler.ListCredentials)
+			mfa.PATCH("/webauthn/credentials/:credentialId", s.services.WebAuthnHandler.RenameCredential)
+			mfa.DELETE("/webauthn/credentials/:credentialId", s.services.WebAuthnHandler.DeleteCredential)
+			mfa.POST("/webauthn/challenge", s.services.WebAuthnHandler.BeginChallenge)
+			mfa.POST("/webauthn/verify", s.services.WebAuthnHandler.VerifyChallenge)
+		} else {
+			mfa.POST("/webauthn/registration/options", s.notImplemented)
+			mfa.POST("/webauthn/registration", s.notImplemented)
+			mfa.GET("/webauthn/credentials", s.notImplemented)
+			mfa.PATCH("/webauthn/credentials/:credentialId", s.notImplemented)
+			mfa.DELETE("/webauthn/credentials/:credentialId", s.notImplemented)
+			mfa.POST("/webauthn/challenge", s.notImplemented)
+			mfa.POST("/webauthn/verify", s.notImplemented)
+		}
+		if s.services.TrustedDeviceHandler != nil {
+			mfa.GET("/trusted-devices", s.services.TrustedDeviceHandler.ListDevices)
+			mfa.DELETE("/trusted-devices", s.services.TrustedDeviceHandler.RevokeAllDevices)
+			mfa.DELETE("/trusted-devices/:deviceId", s.services.TrustedDeviceHandler.RevokeDevice)
+		} else {
+			mfa.GET("/trusted-devices", s.notImplemented)
+			mfa.DELETE("/trusted-devices", s.notImplemented)
+			mfa.DELETE("/trusted-devices/:deviceId", s.notImplemented)
+		}
 	}
 
 	// Billing endpoints
 	billing := rg.Group("/billing")
+	billing.Use(noImpersonation)
+	billing.Use(noAPIKey)
 	{
 		billing.GET("/subscription", s.notImplemented)
 		billing.POST("/subscription", s.notImplemented)
@@ -277,6 +550,7 @@ func (s *HTTPServer) setupProtectedRoutes(rg *gin.RouterGroup) {
 
 	// Compliance
 	compliance := rg.Group("/compliance")
+	compliance.Use(noAPIKey)
 	{
 		compliance.POST("/gdpr/export", s.notImplemented)
 		compliance.POST("/gdpr/delete", s.notImplemented)
@@ -304,7 +578,16 @@ func (s *HTTPServer) setupAdminRoutes(rg *gin.RouterGroup) {
 		users.GET("", s.notImplemented)
 		users.GET("/:userId", s.notImplemented)
 		users.POST("/:userId/suspend", s.notImplemented)
-		users.POST("/:userId/activate", s.notImplemented)
+		if s.services.AdminUserHandler != nil {
+			users.POST("/:userId/activate", s.services.AdminUserHandler.Activate)
+		} else {
+			users.POST("/:userId/activate", s.notImplemented)
+		}
+		if s.services.ImpersonationHandler != nil {
+			users.POST("/:userId/impersonate", s.services.ImpersonationHandler.Start)
+		} else {
+			users.POST("/:userId/impersonate", s.notImplemented)
+		}
 		users.POST("/:userId/reset-password", s.notImplemented)
 		users.DELETE("/:userId", s.notImplemented)
 	}
@@ -320,6 +603,81 @@ func (s *HTTPServer) setupAdminRoutes(rg *gin.RouterGroup) {
 		roles.DELETE("/users/:userId/roles/:roleId", s.notImplemented)
 	}
 
+	// OAuth client registration
+	oauthClients := rg.Group("/oauth/clients")
+	{
+		if s.services.OAuthHandler != nil {
+			oauthClients.GET("", s.services.OAuthHandler.ListClients)
+			oauthClients.POST("", s.services.OAuthHandler.RegisterClient)
+			oauthClients.DELETE("/:clientId", s.services.OAuthHandler.DeleteClient)
+		} else {
+			oauthClients.GET("", s.notImplemented)
+			oauthClients.POST("", s.notImplemented)
+			oauthClients.DELETE("/:clientId", s.notImplemented)
+		}
+	}
+
+	// API keys of any owner
+	apiKeys := rg.Group("/api-keys")
+	{
+		if s.services.APIKeyHandler != nil {
+			apiKeys.GET("", s.services.APIKeyHandler.AdminListKeys)
+			apiKeys.DELETE("/:keyId", s.services.APIKeyHandler.AdminRevokeKey)
+		} else {
+			apiKeys.GET("", s.notImplemented)
+			apiKeys.DELETE("/:keyId", s.notImplemented)
+		}
+	}
+
+	// Service accounts for non-interactive clients
+	serviceAccounts := rg.Group("/service-accounts")
+	{
+		if s.services.ServiceAccountHandler != nil {
+			serviceAccounts.GET("", s.services.ServiceAccountHandler.List)
+			serviceAccounts.POST("", s.services.ServiceAccountHandler.Create)
+			serviceAccounts.GET("/:accountId", s.services.ServiceAccountHandler.Get)
+			serviceAccounts.PATCH("/:accountId", s.services.ServiceAccountHandler.Update)
+			serviceAccounts.DELETE("/:accountId", s.services.ServiceAccountHandler.Delete)
+			serviceAccounts.POST("/:accountId/secret", s.services.ServiceAccountHandler.RotateSecret)
+			serviceAccounts.GET("/:accountId/roles", s.services.ServiceAccountHandler.ListRoles)
+			serviceAccounts.POST("/:accountId/roles", s.services.ServiceAccountHandler.AssignRole)
+			serviceAccounts.DELETE("/:accountId/roles/:role", s.services.ServiceAccountHandler.RemoveRole)
+		} else {
+			serviceAccounts.GET("", s.notImplemented)
+			serviceAccounts.POST("", s.notImplemented)
+			serviceAccounts.GET("/:accountId", s.notImplemented)
+			serviceAccounts.PATCH("/:accountId", s.notImplemented)
+			serviceAccounts.DELETE("/:accountId", s.notImplemented)
+			serviceAccounts.POST("/:accountId/secret", s.notImplemented)
+			serviceAccounts.GET("/:accountId/roles", s.notImplemented)
+			serviceAccounts.POST("/:accountId/roles", s.notImplemented)
+			serviceAccounts.DELETE("/:accountId/roles/:role", s.notImplemented)
+		}
+	}
+
+	// Organization SAML connections and SCIM provisioning tokens
+	organizations := rg.Group("/organizations")
+	{
+		if s.services.SAMLHandler != nil {
+			organizations.GET("/:orgId/saml", s.services.SAMLHandler.GetConnection)
+			organizations.PUT("/:orgId/saml", s.services.SAMLHandler.ConfigureConnection)
+			organizations.DELETE("/:orgId/saml", s.services.SAMLHandler.DeleteConnection)
+		} else {
+			organizations.GET("/:orgId/saml", s.notImplemented)
+			organizations.PUT("/:orgId/saml", s.notImplemented)
+			organizations.DELETE("/:orgId/saml", s.notImplemented)
+		}
+		if s.services.SCIMHandler != nil {
+			organizations.GET("/:orgId/scim/tokens", s.services.SCIMHandler.ListTokens)
+			organizations.POST("/:orgId/scim/tokens", s.services.SCIMHandler.CreateToken)
+			organizations.DELETE("/:orgId/scim/tokens/:tokenId", s.services.SCIMHandler.RevokeToken)
+		} else {
+			organizations.GET("/:orgId/scim/tokens", s.notImplemented)
+			organizations.POST("/:orgId/scim/tokens", s.notImplemented)
+			organizations.DELETE("/:orgId/scim/tokens/:tokenId", s.notImplemented)
+		}
+	}
+
 	// System monitoring
 	system := rg.Group("/system")
 	{
@@ -327,6 +685,13 @@ func (s *HTTPServer) setupAdminRoutes(rg *gin.RouterGroup) {
 		system.GET("/health/detailed", s.notImplemented)
 	}
 
+	// Password hash algorithms still in use
+	if s.services.PasswordHashHandler != nil {
+		rg.GET("/security/password-hashes", s.services.PasswordHashHandler.Report)
+	} else {
+		rg.GET("/security/password-hashes", s.notImplemented)
+	}
+
 	// Audit logs
 	audit := rg.Group("/audit")
 	{
@@ -400,6 +765,39 @@ func (s *HTTPServer) rateLimitStatus(c *gin.Context) {
 	})
 }
 
+// setupSCIMRoutes sets up the SCIM 2.0 endpoints identity providers provision users and groups through
+func (s *HTTPServer) setupSCIMRoutes(rg *gin.RouterGroup) {
+	if s.services.SCIMHandler == nil || s.services.SCIMTokens == nil {
+		rg.Any("/Users", s.notImplemented)
+		rg.Any("/Users/:id", s.notImplemented)
+		rg.Any("/Groups", s.notImplemented)
+		rg.Any("/Groups/:id", s.notImplemented)
+		return
+	}
+
+	rg.Use(middleware.SCIMAuth(s.services.SCIMTokens))
+
+	users := rg.Group("/Users")
+	{
+		users.GET("", s.services.SCIMHandler.ListUsers)
+		users.POST("", s.services.SCIMHandler.CreateUser)
+		users.GET("/:id", s.services.SCIMHandler.GetUser)
+		users.PUT("/:id", s.services.SCIMHandler.ReplaceUser)
+		users.PATCH("/:id", s.services.SCIMHandler.PatchUser)
+		users.DELETE("/:id", s.services.SCIMHandler.DeleteUser)
+	}
+
+	groups := rg.Group("/Groups")
+	{
+		groups.GET("", s.services.SCIMHandler.ListGroups)
+		groups.POST("", s.services.SCIMHandler.CreateGroup)
+		groups.GET("/:id", s.services.SCIMHandler.GetGroup)
+		groups.PUT("/:id", s.services.SCIMHandler.ReplaceGroup)
+		groups.PATCH("/:id", s.services.SCIMHandler.PatchGroup)
+		groups.DELETE("/:id", s.services.SCIMHandler.DeleteGroup)
+	}
+}
+
 func (s *HTTPServer) notImplemented(c *gin.Context) {
 	c.JSON(http.StatusBadRequest, gin.H{
 		"success": false,
@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/victoralfred/um_sys/internal/config"
+	"github.com/victoralfred/um_sys/internal/handlers"
 	"github.com/victoralfred/um_sys/internal/middleware"
 	"go.uber.org/zap"
 )
@@ -165,6 +166,24 @@ func TestServer_PublicRoutes(t *testing.T) {
 			path:       "/v1/auth/email/verify",
 			wantStatus: http.StatusBadRequest,
 		},
+		{
+			name:       "jwks endpoint exists",
+			method:     "GET",
+			path:       "/v1/.well-known/jwks.json",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "SAML login endpoint exists",
+			method:     "GET",
+			path:       "/v1/auth/saml/123/login",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "SCIM users endpoint exists",
+			method:     "GET",
+			path:       "/scim/v2/Users",
+			wantStatus: http.StatusBadRequest,
+		},
 		{
 			name:       "get plans endpoint exists",
 			method:     "GET",
@@ -199,10 +218,13 @@ func TestServer_ProtectedRoutes_RequireAuth(t *testing.T) {
 	}{
 		{"logout", "POST", "/v1/auth/logout"},
 		{"get sessions", "GET", "/v1/auth/sessions"},
+		{"sign out other sessions", "DELETE", "/v1/auth/sessions"},
+		{"end impersonation", "DELETE", "/v1/auth/impersonation"},
 		{"get current user", "GET", "/v1/users/me"},
 		{"update profile", "PATCH", "/v1/users/me"},
 		{"change password", "POST", "/v1/users/me/password"},
 		{"get MFA status", "GET", "/v1/mfa/status"},
+		{"list trusted devices", "GET", "/v1/mfa/trusted-devices"},
 		{"get subscription", "GET", "/v1/billing/subscription"},
 		{"get activity", "GET", "/v1/audit/activity"},
 		{"get feature flags", "GET", "/v1/features/flags"},
@@ -231,6 +253,137 @@ func TestServer_ProtectedRoutes_RequireAuth(t *testing.T) {
 	}
 }
 
+func TestServer_ClientTokens(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"api keys", "GET", "/v1/users/me/api-keys", http.StatusForbidden},
+		{"current user", "GET", "/v1/users/me", http.StatusForbidden},
+		// Userinfo is not wired in the test server, but the token gets past authentication
+		{"userinfo", "GET", "/v1/oauth/userinfo", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			gin.SetMode(gin.TestMode)
+			server := setupTestServer(t)
+			server.services.TokenService.(*middleware.SimpleTokenService).AddValidToken("client-token", &middleware.TokenClaims{
+				UserID:   "user123",
+				Roles:    []string{"user"},
+				ClientID: "client123",
+				Scopes:   []string{"openid", "profile"},
+			})
+			server.Setup()
+
+			// Act - request with a token the user granted to an OAuth client
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer client-token")
+			server.router.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), "CLIENT_TOKEN_NOT_ALLOWED")
+			}
+		})
+	}
+}
+
+func TestServer_APIKeysCannotManageTheAccount(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+	}{
+		{"sign out other sessions", "DELETE", "/v1/auth/sessions"},
+		{"switch organization", "POST", "/v1/auth/organization"},
+		{"authorize OAuth client", "POST", "/v1/oauth/authorize"},
+		{"list API keys", "GET", "/v1/users/me/api-keys"},
+		{"create API key", "POST", "/v1/users/me/api-keys"},
+		{"change password", "POST", "/v1/users/me/password"},
+		{"list organizations", "GET", "/v1/organizations"},
+		{"invite to organization", "POST", "/v1/organizations/123/invitations"},
+		{"accept invitation", "POST", "/v1/invitations/accept"},
+		{"register passkey", "POST", "/v1/mfa/webauthn/registration/options"},
+		{"list trusted devices", "GET", "/v1/mfa/trusted-devices"},
+		{"get subscription", "GET", "/v1/billing/subscription"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			gin.SetMode(gin.TestMode)
+			server := setupTestServer(t)
+			server.services.TokenService.(*middleware.SimpleTokenService).AddValidAPIKey("umk_valid", &middleware.TokenClaims{
+				UserID:   "user123",
+				Roles:    []string{"user"},
+				Scopes:   []string{"profile:read"},
+				APIKeyID: "key123",
+			})
+			// The guards sit on the wired routes; the requests never reach the handlers
+			server.services.SessionHandler = handlers.NewSessionHandler(nil, zap.NewNop())
+			server.services.OAuthHandler = handlers.NewOAuthHandler(nil, zap.NewNop())
+			server.services.APIKeyHandler = handlers.NewAPIKeyHandler(nil, zap.NewNop())
+			server.services.OrganizationHandler = handlers.NewOrganizationHandler(nil, zap.NewNop())
+			server.Setup()
+
+			// Act
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "ApiKey umk_valid")
+			server.router.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.Contains(t, w.Body.String(), "API_KEY_NOT_ALLOWED")
+		})
+	}
+}
+
+func TestServer_APIKeysCannotReachAdminRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+	}{
+		{"list users", "GET", "/v1/admin/users"},
+		{"impersonate user", "POST", "/v1/admin/users/123/impersonate"},
+		{"create OAuth client", "POST", "/v1/admin/oauth/clients"},
+		{"create SCIM token", "POST", "/v1/admin/organizations/123/scim/tokens"},
+		{"list API keys", "GET", "/v1/admin/api-keys"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange - a narrowly scoped key created by an admin
+			gin.SetMode(gin.TestMode)
+			server := setupTestServer(t)
+			server.services.TokenService.(*middleware.SimpleTokenService).AddValidAPIKey("umk_admin", &middleware.TokenClaims{
+				UserID:   "admin123",
+				Roles:    []string{"admin"},
+				Scopes:   []string{"profile:read"},
+				APIKeyID: "key123",
+			})
+			server.Setup()
+
+			// Act
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "ApiKey umk_admin")
+			server.router.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.Contains(t, w.Body.String(), "API_KEY_NOT_ALLOWED")
+		})
+	}
+}
+
 func TestServer_AdminRoutes_RequireAdminRole(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -241,7 +394,10 @@ func TestServer_AdminRoutes_RequireAdminRole(t *testing.T) {
 		{"get user", "GET", "/v1/admin/users/123"},
 		{"suspend user", "POST", "/v1/admin/users/123/suspend"},
 		{"list roles", "GET", "/v1/admin/roles"},
+		{"configure SAML connection", "PUT", "/v1/admin/organizations/123/saml"},
+		{"create SCIM token", "POST", "/v1/admin/organizations/123/scim/tokens"},
 		{"system stats", "GET", "/v1/admin/system/stats"},
+		{"password hash report", "GET", "/v1/admin/security/password-hashes"},
 		{"audit logs", "GET", "/v1/admin/audit/logs"},
 	}
 
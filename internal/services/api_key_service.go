@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/apikey"
+	"github.com/victoralfred/um_sys/internal/domain/organization"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+)
+
+const (
+	// APIKeyPrefix starts every key so leaked keys are easy to recognise and scan for
+	APIKeyPrefix = "umk_"
+
+	apiKeySecretBytes = 32
+
+	// apiKeyDisplayLength is how much of a key is stored in clear for display
+	apiKeyDisplayLength = len(APIKeyPrefix) + 8
+
+	// lastUsedUpdateInterval throttles last-used writes for busy keys
+	lastUsedUpdateInterval = time.Minute
+)
+
+// APIKeyConfig holds API key settings
+type APIKeyConfig struct {
+	// MaxKeysPerOwner caps an owner's active keys; zero means unlimited
+	MaxKeysPerOwner int
+
+	// MaxLifetime caps how far in the future a key may expire; zero allows keys that never expire
+	MaxLifetime time.Duration
+}
+
+// DefaultAPIKeyConfig returns the default API key settings
+func DefaultAPIKeyConfig() APIKeyConfig {
+	return APIKeyConfig{
+		MaxKeysPerOwner: 50,
+	}
+}
+
+// APIKeyService issues, authenticates and revokes API keys
+type APIKeyService struct {
+	repo        apikey.Repository
+	userRepo    user.Repository
+	memberships organization.MembershipRepository
+	config      APIKeyConfig
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(repo apikey.Repository, userRepo user.Repository, config APIKeyConfig) *APIKeyService {
+	return &APIKeyService{
+		repo:     repo,
+		userRepo: userRepo,
+		config:   config,
+	}
+}
+
+// SetMembershipRepository enables organization-owned keys, managed by members
+// whose organization role grants api_keys:manage
+func (s *APIKeyService) SetMembershipRepository(memberships organization.MembershipRepository) {
+	s.memberships = memberships
+}
+
+// CreateKey issues a key for an owner, acting as the createdBy user. Each scope must
+// be one of the grantable permissions, so a key never exceeds its creator's access.
+// The secret is returned once and cannot be retrieved again.
+func (s *APIKeyService) CreateKey(
+	ctx context.Context,
+	ownerType apikey.OwnerType,
+	ownerID, createdBy uuid.UUID,
+	req *apikey.CreateRequest,
+	grantable []string,
+) (*apikey.APIKey, string, error) {
+	scopes, err := validateScopes(req.Scopes, grantable)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, entry := range req.AllowedIPs {
+		if err := apikey.ValidateAllowedIP(entry); err != nil {
+			return nil, "", fmt.Errorf("%w: %s", err, entry)
+		}
+	}
+
+	now := time.Now()
+	expiresAt := req.ExpiresAt
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, "", apikey.ErrInvalidExpiry
+	}
+	if s.config.MaxLifetime > 0 {
+		limit := now.Add(s.config.MaxLifetime)
+		if expiresAt == nil {
+			expiresAt = &limit
+		} else if expiresAt.After(limit) {
+			return nil, "", apikey.ErrInvalidExpiry
+		}
+	}
+
+	if s.config.MaxKeysPerOwner > 0 {
+		count, err := s.repo.CountActiveByOwner(ctx, ownerType, ownerID)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to count api keys: %w", err)
+		}
+		if count >= s.config.MaxKeysPerOwner {
+			return nil, "", apikey.ErrTooManyKeys
+		}
+	}
+
+	secret, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &apikey.APIKey{
+		ID:         uuid.New(),
+		OwnerType:  ownerType,
+		OwnerID:    ownerID,
+		CreatedBy:  createdBy,
+		Name:       req.Name,
+		Prefix:     secret[:apiKeyDisplayLength],
+		KeyHash:    hashAPIKey(secret),
+		Scopes:     scopes,
+		AllowedIPs: req.AllowedIPs,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  now,
+	}
+
+	if err := s.repo.Create(ctx, key); err != nil {
+		return nil, "", fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return key, secret, nil
+}
+
+// ListKeys returns an owner's keys
+func (s *APIKeyService) ListKeys(ctx context.Context, ownerType apikey.OwnerType, ownerID uuid.UUID) ([]*apikey.APIKey, error) {
+	return s.repo.ListByOwner(ctx, ownerType, ownerID)
+}
+
+// RevokeKey revokes one of an owner's keys. Keys of other owners are reported as not found.
+func (s *APIKeyService) RevokeKey(ctx context.Context, ownerType apikey.OwnerType, ownerID, keyID uuid.UUID) error {
+	key, err := s.repo.GetByID(ctx, keyID)
+	if err != nil {
+		return err
+	}
+	if key.OwnerType != ownerType || key.OwnerID != ownerID {
+		return apikey.ErrAPIKeyNotFound
+	}
+
+	return s.revoke(ctx, key)
+}
+
+// RevokeAnyKey revokes a key regardless of owner, for administrators
+func (s *APIKeyService) RevokeAnyKey(ctx context.Context, keyID uuid.UUID) error {
+	key, err := s.repo.GetByID(ctx, keyID)
+	if err != nil {
+		return err
+	}
+
+	return s.revoke(ctx, key)
+}
+
+func (s *APIKeyService) revoke(ctx context.Context, key *apikey.APIKey) error {
+	// Revoking twice is harmless
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	return s.repo.Revoke(ctx, key.ID, time.Now())
+}
+
+// CreateOrganizationKey issues a key owned by an organization, acting as the actor
+func (s *APIKeyService) CreateOrganizationKey(
+	ctx context.Context,
+	orgID, actorID uuid.UUID,
+	req *apikey.CreateRequest,
+	grantable []string,
+) (*apikey.APIKey, string, error) {
+	if err := s.authorizeOrganization(ctx, orgID, actorID); err != nil {
+		return nil, "", err
+	}
+
+	return s.CreateKey(ctx, apikey.OwnerOrganization, orgID, actorID, req, grantable)
+}
+
+// ListOrganizationKeys returns an organization's keys
+func (s *APIKeyService) ListOrganizationKeys(ctx context.Context, orgID, actorID uuid.UUID) ([]*apikey.APIKey, error) {
+	if err := s.authorizeOrganization(ctx, orgID, actorID); err != nil {
+		return nil, err
+	}
+
+	return s.ListKeys(ctx, apikey.OwnerOrganization, orgID)
+}
+
+// RevokeOrganizationKey revokes one of an organization's keys
+func (s *APIKeyService) RevokeOrganizationKey(ctx context.Context, orgID, actorID, keyID uuid.UUID) error {
+	if err := s.authorizeOrganization(ctx, orgID, actorID); err != nil {
+		return err
+	}
+
+	return s.RevokeKey(ctx, apikey.OwnerOrganization, orgID, keyID)
+}
+
+// authorizeOrganization checks the actor's organization role allows managing its keys
+func (s *APIKeyService) authorizeOrganization(ctx context.Context, orgID, actorID uuid.UUID) error {
+	if s.memberships == nil {
+		return organization.ErrOrganizationNotFound
+	}
+
+	membership, err := s.memberships.Get(ctx, orgID, actorID)
+	if err != nil {
+		// Non-members cannot tell an organization exists
+		if errors.Is(err, organization.ErrNotMember) {
+			return organization.ErrOrganizationNotFound
+		}
+		return fmt.Errorf("failed to get organization membership: %w", err)
+	}
+
+	if !membership.Role.HasPermission(organization.PermissionAPIKeysManage) {
+		return organization.ErrInsufficientRole
+	}
+
+	return nil
+}
+
+// Authenticate resolves a presented key to the key record and the user it acts as
+func (s *APIKeyService) Authenticate(ctx context.Context, secret, clientIP string) (*apikey.APIKey, *user.User, error) {
+	if !strings.HasPrefix(secret, APIKeyPrefix) {
+		return nil, nil, apikey.ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.GetByHash(ctx, hashAPIKey(secret))
+	if err != nil {
+		if errors.Is(err, apikey.ErrAPIKeyNotFound) {
+			return nil, nil, apikey.ErrInvalidAPIKey
+		}
+		return nil, nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	if !key.IsActive() {
+		return nil, nil, apikey.ErrInvalidAPIKey
+	}
+	if !key.AllowsIP(clientIP) {
+		return nil, nil, apikey.ErrIPNotAllowed
+	}
+
+	// A key stops working as soon as the account it acts as can no longer sign in
+	u, err := s.userRepo.GetByID(ctx, key.CreatedBy)
+	if err != nil {
+		return nil, nil, apikey.ErrInvalidAPIKey
+	}
+	if u.Status != user.StatusActive || u.IsLocked() {
+		return nil, nil, apikey.ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedUpdateInterval || key.LastUsedIP != clientIP {
+		_ = s.repo.UpdateLastUsed(ctx, key.ID, now, clientIP)
+		key.LastUsedAt = &now
+		key.LastUsedIP = clientIP
+	}
+
+	return key, u, nil
+}
+
+// validateScopes checks scope syntax and that every scope is grantable, dropping duplicates
+func validateScopes(requested, grantable []string) ([]string, error) {
+	allowed := make(map[string]bool, len(grantable))
+	for _, p := range grantable {
+		allowed[p] = true
+	}
+
+	scopes := make([]string, 0, len(requested))
+	seen := make(map[string]bool, len(requested))
+	for _, scope := range requested {
+		if _, _, err := apikey.ParseScope(scope); err != nil {
+			return nil, fmt.Errorf("%w: %q", err, scope)
+		}
+		if !allowed[scope] {
+			return nil, fmt.Errorf("%w: %s", apikey.ErrScopeNotGrantable, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes, nil
+}
+
+// generateAPIKey generates a new key secret
+func generateAPIKey() (string, error) {
+	b := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashAPIKey hashes a key secret for storage and lookup. Keys carry 256 bits of
+// entropy, so a fast unsalted hash is sufficient.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
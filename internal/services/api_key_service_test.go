@@ -0,0 +1,290 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/apikey"
+	"github.com/victoralfred/um_sys/internal/domain/organization"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+var testGrantable = []string{"profile:read", "profile:write", "billing:read:own"}
+
+func newAPIKeyFixture(t *testing.T, config services.APIKeyConfig) (*services.APIKeyService, *InMemoryAPIKeyRepository, *InMemoryUserRepository, *user.User) {
+	t.Helper()
+
+	users := NewInMemoryUserRepository()
+	u, err := user.NewUser("keys@example.com", "keyuser", "hash")
+	require.NoError(t, err)
+	u.Status = user.StatusActive
+	require.NoError(t, users.Create(context.Background(), u))
+
+	repo := NewInMemoryAPIKeyRepository()
+	return services.NewAPIKeyService(repo, users, config), repo, users, u
+}
+
+func TestAPIKeyService_CreateKey(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns the secret once and stores only its hash", func(t *testing.T) {
+		service, repo, _, u := newAPIKeyFixture(t, services.DefaultAPIKeyConfig())
+
+		key, secret, err := service.CreateKey(ctx, apikey.OwnerUser, u.ID, u.ID, &apikey.CreateRequest{
+			Name:   "CI",
+			Scopes: []string{"profile:read", "profile:read"},
+		}, testGrantable)
+		require.NoError(t, err)
+
+		assert.True(t, strings.HasPrefix(secret, services.APIKeyPrefix))
+		assert.True(t, strings.HasPrefix(secret, key.Prefix))
+		assert.Equal(t, []string{"profile:read"}, key.Scopes)
+
+		stored, err := repo.GetByID(ctx, key.ID)
+		require.NoError(t, err)
+		assert.NotEmpty(t, stored.KeyHash)
+		assert.NotContains(t, stored.KeyHash, secret)
+		assert.Nil(t, stored.ExpiresAt)
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		service, _, _, u := newAPIKeyFixture(t, services.DefaultAPIKeyConfig())
+		past := time.Now().Add(-time.Hour)
+
+		tests := []struct {
+			name string
+			req  apikey.CreateRequest
+			err  error
+		}{
+			{"malformed scope", apikey.CreateRequest{Name: "k", Scopes: []string{"profile"}}, apikey.ErrInvalidScope},
+			{"scope the creator lacks", apikey.CreateRequest{Name: "k", Scopes: []string{"users:delete"}}, apikey.ErrScopeNotGrantable},
+			{"bad allowlist entry", apikey.CreateRequest{Name: "k", Scopes: []string{"profile:read"}, AllowedIPs: []string{"10.0.0.0/33"}}, apikey.ErrInvalidAllowedIP},
+			{"expiry in the past", apikey.CreateRequest{Name: "k", Scopes: []string{"profile:read"}, ExpiresAt: &past}, apikey.ErrInvalidExpiry},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := tt.req
+				_, _, err := service.CreateKey(ctx, apikey.OwnerUser, u.ID, u.ID, &req, testGrantable)
+				assert.ErrorIs(t, err, tt.err)
+			})
+		}
+	})
+
+	t.Run("applies the maximum lifetime", func(t *testing.T) {
+		service, _, _, u := newAPIKeyFixture(t, services.APIKeyConfig{MaxLifetime: 24 * time.Hour})
+
+		key, _, err := service.CreateKey(ctx, apikey.OwnerUser, u.ID, u.ID, &apikey.CreateRequest{
+			Name: "default expiry", Scopes: []string{"profile:read"},
+		}, testGrantable)
+		require.NoError(t, err)
+		require.NotNil(t, key.ExpiresAt)
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), *key.ExpiresAt, time.Minute)
+
+		tooLate := time.Now().Add(48 * time.Hour)
+		_, _, err = service.CreateKey(ctx, apikey.OwnerUser, u.ID, u.ID, &apikey.CreateRequest{
+			Name: "too long", Scopes: []string{"profile:read"}, ExpiresAt: &tooLate,
+		}, testGrantable)
+		assert.ErrorIs(t, err, apikey.ErrInvalidExpiry)
+	})
+
+	t.Run("enforces the per-owner limit on active keys", func(t *testing.T) {
+		service, _, _, u := newAPIKeyFixture(t, services.APIKeyConfig{MaxKeysPerOwner: 1})
+		req := &apikey.CreateRequest{Name: "k", Scopes: []string{"profile:read"}}
+
+		key, _, err := service.CreateKey(ctx, apikey.OwnerUser, u.ID, u.ID, req, testGrantable)
+		require.NoError(t, err)
+
+		_, _, err = service.CreateKey(ctx, apikey.OwnerUser, u.ID, u.ID, req, testGrantable)
+		assert.ErrorIs(t, err, apikey.ErrTooManyKeys)
+
+		// Revoked keys do not count
+		require.NoError(t, service.RevokeKey(ctx, apikey.OwnerUser, u.ID, key.ID))
+		_, _, err = service.CreateKey(ctx, apikey.OwnerUser, u.ID, u.ID, req, testGrantable)
+		assert.NoError(t, err)
+	})
+}
+
+func TestAPIKeyService_Authenticate(t *testing.T) {
+	ctx := context.Background()
+
+	create := func(t *testing.T, service *services.APIKeyService, u *user.User, req *apikey.CreateRequest) (*apikey.APIKey, string) {
+		t.Helper()
+		key, secret, err := service.CreateKey(ctx, apikey.OwnerUser, u.ID, u.ID, req, testGrantable)
+		require.NoError(t, err)
+		return key, secret
+	}
+
+	t.Run("resolves the key and user and records usage", func(t *testing.T) {
+		service, repo, _, u := newAPIKeyFixture(t, services.DefaultAPIKeyConfig())
+		key, secret := create(t, service, u, &apikey.CreateRequest{Name: "k", Scopes: []string{"profile:read"}})
+
+		gotKey, gotUser, err := service.Authenticate(ctx, secret, "203.0.113.7")
+		require.NoError(t, err)
+		assert.Equal(t, key.ID, gotKey.ID)
+		assert.Equal(t, u.ID, gotUser.ID)
+
+		stored, err := repo.GetByID(ctx, key.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.LastUsedAt)
+		assert.Equal(t, "203.0.113.7", stored.LastUsedIP)
+	})
+
+	t.Run("rejects unknown and malformed keys", func(t *testing.T) {
+		service, _, _, _ := newAPIKeyFixture(t, services.DefaultAPIKeyConfig())
+
+		_, _, err := service.Authenticate(ctx, services.APIKeyPrefix+"unknown", "203.0.113.7")
+		assert.ErrorIs(t, err, apikey.ErrInvalidAPIKey)
+
+		_, _, err = service.Authenticate(ctx, "not-a-key", "203.0.113.7")
+		assert.ErrorIs(t, err, apikey.ErrInvalidAPIKey)
+	})
+
+	t.Run("rejects revoked keys", func(t *testing.T) {
+		service, _, _, u := newAPIKeyFixture(t, services.DefaultAPIKeyConfig())
+		key, secret := create(t, service, u, &apikey.CreateRequest{Name: "k", Scopes: []string{"profile:read"}})
+
+		require.NoError(t, service.RevokeKey(ctx, apikey.OwnerUser, u.ID, key.ID))
+
+		_, _, err := service.Authenticate(ctx, secret, "203.0.113.7")
+		assert.ErrorIs(t, err, apikey.ErrInvalidAPIKey)
+	})
+
+	t.Run("rejects expired keys", func(t *testing.T) {
+		service, repo, _, u := newAPIKeyFixture(t, services.DefaultAPIKeyConfig())
+		key, secret := create(t, service, u, &apikey.CreateRequest{Name: "k", Scopes: []string{"profile:read"}})
+
+		stored, err := repo.GetByID(ctx, key.ID)
+		require.NoError(t, err)
+		expired := time.Now().Add(-time.Minute)
+		stored.ExpiresAt = &expired
+		require.NoError(t, repo.Create(ctx, stored))
+
+		_, _, err = service.Authenticate(ctx, secret, "203.0.113.7")
+		assert.ErrorIs(t, err, apikey.ErrInvalidAPIKey)
+	})
+
+	t.Run("enforces the IP allowlist", func(t *testing.T) {
+		service, _, _, u := newAPIKeyFixture(t, services.DefaultAPIKeyConfig())
+		_, secret := create(t, service, u, &apikey.CreateRequest{
+			Name:       "k",
+			Scopes:     []string{"profile:read"},
+			AllowedIPs: []string{"10.0.0.0/8", "203.0.113.7"},
+		})
+
+		_, _, err := service.Authenticate(ctx, secret, "10.1.2.3")
+		assert.NoError(t, err)
+
+		_, _, err = service.Authenticate(ctx, secret, "203.0.113.7")
+		assert.NoError(t, err)
+
+		_, _, err = service.Authenticate(ctx, secret, "198.51.100.1")
+		assert.ErrorIs(t, err, apikey.ErrIPNotAllowed)
+	})
+
+	t.Run("stops working when the user is no longer active", func(t *testing.T) {
+		service, _, users, u := newAPIKeyFixture(t, services.DefaultAPIKeyConfig())
+		_, secret := create(t, service, u, &apikey.CreateRequest{Name: "k", Scopes: []string{"profile:read"}})
+
+		u.Status = user.StatusSuspended
+		require.NoError(t, users.Update(ctx, u))
+
+		_, _, err := service.Authenticate(ctx, secret, "203.0.113.7")
+		assert.ErrorIs(t, err, apikey.ErrInvalidAPIKey)
+	})
+}
+
+func TestAPIKeyService_RevokeKey(t *testing.T) {
+	ctx := context.Background()
+	service, _, _, u := newAPIKeyFixture(t, services.DefaultAPIKeyConfig())
+
+	key, _, err := service.CreateKey(ctx, apikey.OwnerUser, u.ID, u.ID, &apikey.CreateRequest{
+		Name: "k", Scopes: []string{"profile:read"},
+	}, testGrantable)
+	require.NoError(t, err)
+
+	t.Run("hides other owners' keys", func(t *testing.T) {
+		err := service.RevokeKey(ctx, apikey.OwnerUser, uuid.New(), key.ID)
+		assert.ErrorIs(t, err, apikey.ErrAPIKeyNotFound)
+	})
+
+	t.Run("is idempotent", func(t *testing.T) {
+		require.NoError(t, service.RevokeKey(ctx, apikey.OwnerUser, u.ID, key.ID))
+		assert.NoError(t, service.RevokeKey(ctx, apikey.OwnerUser, u.ID, key.ID))
+	})
+
+	t.Run("lists revoked keys with their revocation time", func(t *testing.T) {
+		keys, err := service.ListKeys(ctx, apikey.OwnerUser, u.ID)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.NotNil(t, keys[0].RevokedAt)
+	})
+}
+
+func TestAPIKeyService_OrganizationKeys(t *testing.T) {
+	ctx := context.Background()
+	service, _, _, u := newAPIKeyFixture(t, services.DefaultAPIKeyConfig())
+
+	orgs := NewInMemoryOrganizationRepository()
+	memberships := NewInMemoryMembershipRepository(orgs)
+	service.SetMembershipRepository(memberships)
+
+	org := &organization.Organization{ID: uuid.New(), Name: "Acme", Slug: "acme", CreatedBy: u.ID}
+	require.NoError(t, orgs.Create(ctx, org))
+	require.NoError(t, memberships.Add(ctx, &organization.Membership{OrganizationID: org.ID, UserID: u.ID, Role: organization.RoleAdmin}))
+
+	member := uuid.New()
+	require.NoError(t, memberships.Add(ctx, &organization.Membership{OrganizationID: org.ID, UserID: member, Role: organization.RoleMember}))
+
+	t.Run("admins create keys owned by the organization", func(t *testing.T) {
+		key, _, err := service.CreateOrganizationKey(ctx, org.ID, u.ID, &apikey.CreateRequest{
+			Name: "deploy", Scopes: []string{"profile:read"},
+		}, testGrantable)
+		require.NoError(t, err)
+		assert.Equal(t, apikey.OwnerOrganization, key.OwnerType)
+		assert.Equal(t, org.ID, key.OwnerID)
+		assert.Equal(t, u.ID, key.CreatedBy)
+
+		keys, err := service.ListOrganizationKeys(ctx, org.ID, u.ID)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+
+		mine, err := service.ListKeys(ctx, apikey.OwnerUser, u.ID)
+		require.NoError(t, err)
+		assert.Empty(t, mine)
+
+		require.NoError(t, service.RevokeOrganizationKey(ctx, org.ID, u.ID, key.ID))
+	})
+
+	t.Run("members without the role are refused", func(t *testing.T) {
+		_, _, err := service.CreateOrganizationKey(ctx, org.ID, member, &apikey.CreateRequest{
+			Name: "k", Scopes: []string{"profile:read"},
+		}, testGrantable)
+		assert.ErrorIs(t, err, organization.ErrInsufficientRole)
+
+		_, err = service.ListOrganizationKeys(ctx, org.ID, member)
+		assert.ErrorIs(t, err, organization.ErrInsufficientRole)
+	})
+
+	t.Run("non-members cannot tell the organization exists", func(t *testing.T) {
+		_, err := service.ListOrganizationKeys(ctx, org.ID, uuid.New())
+		assert.ErrorIs(t, err, organization.ErrOrganizationNotFound)
+	})
+
+	t.Run("hides keys of other organizations and users", func(t *testing.T) {
+		key, _, err := service.CreateKey(ctx, apikey.OwnerUser, u.ID, u.ID, &apikey.CreateRequest{
+			Name: "personal", Scopes: []string{"profile:read"},
+		}, testGrantable)
+		require.NoError(t, err)
+
+		err = service.RevokeOrganizationKey(ctx, org.ID, u.ID, key.ID)
+		assert.ErrorIs(t, err, apikey.ErrAPIKeyNotFound)
+	})
+}
@@ -45,6 +45,12 @@ func (s *AuditService) Log(ctx context.Context, req *audit.CreateLogRequest) (*a
 		return nil, audit.ErrMissingRequiredFields
 	}
 
+	// Actions without an explicit actor type were performed by a human
+	actorType := req.ActorType
+	if actorType == "" && req.ActorID != nil {
+		actorType = audit.ActorTypeUser
+	}
+
 	now := time.Now()
 	entry := &audit.LogEntry{
 		ID:          uuid.New(),
@@ -53,6 +59,7 @@ func (s *AuditService) Log(ctx context.Context, req *audit.CreateLogRequest) (*a
 		Severity:    req.Severity,
 		UserID:      req.UserID,
 		ActorID:     req.ActorID,
+		ActorType:   actorType,
 		EntityType:  req.EntityType,
 		EntityID:    req.EntityID,
 		Action:      req.Action,
@@ -71,11 +78,14 @@ func (s *AuditService) Log(ctx context.Context, req *audit.CreateLogRequest) (*a
 		return nil, fmt.Errorf("failed to create log entry: %w", err)
 	}
 
-	triggeredRules, err := s.alertRepo.CheckRules(ctx, entry)
-	if err == nil && len(triggeredRules) > 0 {
-		for _, rule := range triggeredRules {
-			if s.notificationSvc != nil {
-				_ = s.notificationSvc.SendAlert(ctx, rule, entry)
+	// Alerting is optional; without alert rules entries are only stored
+	if s.alertRepo != nil {
+		triggeredRules, err := s.alertRepo.CheckRules(ctx, entry)
+		if err == nil && len(triggeredRules) > 0 {
+			for _, rule := range triggeredRules {
+				if s.notificationSvc != nil {
+					_ = s.notificationSvc.SendAlert(ctx, rule, entry)
+				}
 			}
 		}
 	}
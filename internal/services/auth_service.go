@@ -1 +1,1000 @@
 package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mail"
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+	"github.com/victoralfred/um_sys/internal/domain/risk"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/domain/webauthn"
+	"github.com/victoralfred/um_sys/pkg/security"
+)
+
+const (
+	resetTokenBytes      = 32
+	magicLinkSecretBytes = 32
+
+	// backgroundMailTimeout bounds emails sent after the request returns
+	backgroundMailTimeout = 30 * time.Second
+)
+
+// AuthConfig holds settings for the account recovery flows
+type AuthConfig struct {
+	// PasswordResetURL is the page that accepts reset tokens; the token is appended as ?token=
+	PasswordResetURL string
+
+	// PasswordResetExpiry is how long a reset token stays valid
+	PasswordResetExpiry time.Duration
+
+	// MagicLinkURL is the page that redeems sign-in links; the token is appended as ?token=
+	MagicLinkURL string
+
+	// MagicLinkExpiry is how long a sign-in link stays valid
+	MagicLinkExpiry time.Duration
+
+	// MinResponseTime pads responses that must not reveal whether an account exists
+	MinResponseTime time.Duration
+}
+
+// DefaultAuthConfig returns the default authentication settings
+func DefaultAuthConfig() AuthConfig {
+	return AuthConfig{
+		PasswordResetURL:    "http://localhost:8080/reset-password",
+		PasswordResetExpiry: time.Hour,
+		MagicLinkURL:        "http://localhost:8080/magic-link",
+		MagicLinkExpiry:     10 * time.Minute,
+		MinResponseTime:     500 * time.Millisecond,
+	}
+}
+
+// AuthService implements auth.AuthService
+type AuthService struct {
+	userRepo          user.Repository
+	tokenService      *TokenService
+	passwordHasher    security.Hasher
+	passwordValidator *security.PasswordValidator
+	mailSender        mail.Sender
+	sessionService    *SessionService
+	emailVerification *EmailVerificationService
+	webauthn          *WebAuthnService
+	magicLinks        auth.MagicLinkRepository
+	mfaService        mfa.Service
+	lockout           *LockoutService
+	risk              *RiskService
+	trustedDevices    *TrustedDeviceService
+	passwordPolicy    *PasswordPolicyService
+	config            AuthConfig
+	logger            *zap.Logger
+}
+
+// NewAuthService creates a new authentication service
+func NewAuthService(
+	userRepo user.Repository,
+	tokenService *TokenService,
+	passwordHasher security.Hasher,
+	passwordValidator *security.PasswordValidator,
+	mailSender mail.Sender,
+	config AuthConfig,
+) *AuthService {
+	return &AuthService{
+		userRepo:          userRepo,
+		tokenService:      tokenService,
+		passwordHasher:    passwordHasher,
+		passwordValidator: passwordValidator,
+		mailSender:        mailSender,
+		config:            config,
+		logger:            zap.NewNop(),
+	}
+}
+
+// SetLogger sets the logger for failures that happen after a request has returned
+func (s *AuthService) SetLogger(logger *zap.Logger) {
+	s.logger = logger
+}
+
+// SetSessionService sets the session service used to end sessions when credentials change
+func (s *AuthService) SetSessionService(sessionService *SessionService) {
+	s.sessionService = sessionService
+}
+
+// SetEmailVerificationService enables verification emails and the verification login policy
+func (s *AuthService) SetEmailVerificationService(emailVerification *EmailVerificationService) {
+	s.emailVerification = emailVerification
+}
+
+// SetWebAuthnService enables passwordless login with passkeys
+func (s *AuthService) SetWebAuthnService(webauthnService *WebAuthnService) {
+	s.webauthn = webauthnService
+}
+
+// SetMagicLinkRepository enables passwordless sign-in with links sent by email
+func (s *AuthService) SetMagicLinkRepository(repo auth.MagicLinkRepository) {
+	s.magicLinks = repo
+}
+
+// SetMFAService sets the service that checks second factors for sign-ins that need one.
+// Without it, accounts with MFA enabled cannot finish such sign-ins.
+func (s *AuthService) SetMFAService(mfaService mfa.Service) {
+	s.mfaService = mfaService
+}
+
+// SetLockoutService enables the progressive lockout and sign-in throttling policy.
+// Without it, failed sign-ins are counted but never lock the account.
+func (s *AuthService) SetLockoutService(lockout *LockoutService) {
+	s.lockout = lockout
+}
+
+// SetRiskService enables risk-based sign-in: risky sign-ins must pass the second
+// factor even on trusted devices or are refused, and users are told about sign-ins
+// from new devices
+func (s *AuthService) SetRiskService(riskService *RiskService) {
+	s.risk = riskService
+}
+
+// SetTrustedDeviceService lets users skip MFA on devices they chose to remember.
+// Trusted devices are revoked when the password changes.
+func (s *AuthService) SetTrustedDeviceService(trustedDevices *TrustedDeviceService) {
+	s.trustedDevices = trustedDevices
+}
+
+// SetPasswordPolicyService enables password history and maximum password age.
+// Expired passwords must be changed with ChangeExpiredPassword before signing in.
+func (s *AuthService) SetPasswordPolicyService(passwordPolicy *PasswordPolicyService) {
+	s.passwordPolicy = passwordPolicy
+}
+
+// Register creates a new user account
+func (s *AuthService) Register(ctx context.Context, req *auth.RegisterRequest) (*user.User, error) {
+	email := strings.ToLower(req.Email)
+
+	if err := s.validatePassword(ctx, req.Password, req.Username, email); err != nil {
+		return nil, err
+	}
+
+	exists, err := s.userRepo.ExistsByEmail(ctx, email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check email: %w", err)
+	}
+	if exists {
+		return nil, auth.ErrEmailAlreadyExists
+	}
+
+	exists, err = s.userRepo.ExistsByUsername(ctx, req.Username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check username: %w", err)
+	}
+	if exists {
+		return nil, auth.ErrUsernameAlreadyExists
+	}
+
+	hashedPassword, err := s.passwordHasher.HashPassword(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	newUser, err := user.NewUser(email, req.Username, hashedPassword)
+	if err != nil {
+		return nil, err
+	}
+	newUser.FirstName = req.FirstName
+	newUser.LastName = req.LastName
+	newUser.Status = user.StatusActive
+
+	if err := s.userRepo.Create(ctx, newUser); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// The account exists either way; a lost email can be re-sent by the user
+	if s.emailVerification != nil {
+		_ = s.emailVerification.SendVerification(ctx, newUser)
+	}
+
+	return newUser, nil
+}
+
+// Login authenticates a user and returns tokens.
+// A request carrying a passkey assertion signs in with it instead of a password.
+func (s *AuthService) Login(ctx context.Context, req *auth.LoginRequest) (*auth.TokenPair, *user.User, error) {
+	if s.lockout != nil {
+		if _, err := s.lockout.CheckIP(ctx, req.IPAddress); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var u *user.User
+	var err error
+
+	if req.Passkey != nil {
+		u, err = s.authenticatePasskey(ctx, req.Passkey)
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			s.recordFailure(ctx, req.IPAddress, "", nil)
+		}
+	} else {
+		u, err = s.authenticatePassword(ctx, req)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	assessment, err := s.assessRisk(ctx, u, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A passkey is a second factor in itself. Password sign-ins always need the
+	// second factor the user enrolled; the risk engine can only take away the
+	// trusted device exemption, never waive the factor.
+	verified := false
+	if req.Passkey == nil {
+		stepUp := assessment != nil && assessment.Decision == risk.DecisionStepUp
+		verified, err = s.requireSecondFactor(ctx, u, req.MFAMethod, req.MFACode, req.MFAAssertion, req.DeviceToken, stepUp)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Only reveal the expiry once the sign-in is otherwise complete
+		if s.passwordPolicy != nil && s.passwordPolicy.IsExpired(u) {
+			return nil, nil, auth.ErrPasswordExpired
+		}
+	}
+
+	tokenPair, u, err := s.completeLogin(ctx, u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if verified && req.RememberDevice {
+		tokenPair.TrustedDeviceToken = s.rememberDevice(ctx, u, req.IPAddress, req.UserAgent)
+	}
+
+	// History only sharpens later assessments; the sign-in has already succeeded
+	if assessment != nil {
+		_ = s.risk.RecordLogin(ctx, u, assessment)
+	}
+
+	return tokenPair, u, nil
+}
+
+// BeginSecurityKeyChallenge starts the security key step of a password sign-in.
+// The request carries the same identifier and password as the sign-in, and the
+// challenge only accepts the security keys of the account they belong to; the
+// answer is sent back with the sign-in as its MFA assertion.
+func (s *AuthService) BeginSecurityKeyChallenge(ctx context.Context, req *auth.LoginRequest) (*webauthn.LoginOptions, error) {
+	if s.webauthn == nil {
+		return nil, mfa.ErrMethodNotConfigured
+	}
+
+	if s.lockout != nil {
+		if _, err := s.lockout.CheckIP(ctx, req.IPAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	u, err := s.authenticatePassword(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.webauthn.BeginChallenge(ctx, u.ID)
+}
+
+// authenticatePassword returns the user whose identifier and password the request carries
+func (s *AuthService) authenticatePassword(ctx context.Context, req *auth.LoginRequest) (*user.User, error) {
+	identifier := req.Username
+	if req.Email != "" {
+		identifier = strings.ToLower(req.Email)
+	}
+
+	var u *user.User
+	var err error
+
+	if req.Email != "" {
+		u, err = s.userRepo.GetByEmail(ctx, identifier)
+	} else {
+		u, err = s.userRepo.GetByUsername(ctx, identifier)
+	}
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			s.recordFailure(ctx, req.IPAddress, identifier, nil)
+			return nil, auth.ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if u.IsLocked() {
+		return nil, auth.ErrAccountLocked
+	}
+
+	if !s.passwordHasher.VerifyPassword(req.Password, u.PasswordHash) {
+		s.recordFailure(ctx, req.IPAddress, identifier, u)
+		return nil, auth.ErrInvalidCredentials
+	}
+
+	s.upgradePasswordHash(ctx, u, req.Password)
+
+	return u, nil
+}
+
+// upgradePasswordHash re-hashes a just verified password when its hash was produced
+// by an outdated algorithm or with outdated parameters. A failed upgrade is retried
+// at the next sign-in, so it does not fail this one.
+func (s *AuthService) upgradePasswordHash(ctx context.Context, u *user.User, password string) {
+	if !s.passwordHasher.NeedsRehash(u.PasswordHash) {
+		return
+	}
+
+	hashedPassword, err := s.passwordHasher.HashPassword(password)
+	if err != nil {
+		return
+	}
+
+	previous := u.PasswordHash
+	u.PasswordHash = hashedPassword
+	if err := s.userRepo.Update(ctx, u); err != nil {
+		u.PasswordHash = previous
+	}
+}
+
+// assessRisk refuses sign-ins the risk engine blocks. The returned assessment says
+// whether the sign-in needs a step-up; accounts without MFA cannot be challenged and
+// are only notified.
+func (s *AuthService) assessRisk(ctx context.Context, u *user.User, req *auth.LoginRequest) (*risk.Assessment, error) {
+	if s.risk == nil {
+		return nil, nil
+	}
+
+	assessment, err := s.risk.Assess(ctx, u, req.IPAddress, req.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	if assessment.Decision == risk.DecisionBlock {
+		return nil, auth.ErrLoginBlocked
+	}
+
+	return assessment, nil
+}
+
+// authenticatePasskey returns the user whose passkey produced the assertion.
+// Any failure to verify it is reported as invalid credentials.
+func (s *AuthService) authenticatePasskey(ctx context.Context, req *webauthn.FinishLoginRequest) (*user.User, error) {
+	if s.webauthn == nil {
+		return nil, auth.ErrInvalidCredentials
+	}
+
+	credential, err := s.webauthn.FinishLogin(ctx, req)
+	if err != nil {
+		if isPasskeyRejection(err) {
+			return nil, auth.ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to verify passkey: %w", err)
+	}
+
+	u, err := s.userRepo.GetByID(ctx, credential.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return nil, auth.ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if u.IsLocked() {
+		return nil, auth.ErrAccountLocked
+	}
+
+	return u, nil
+}
+
+// completeLogin applies the account policies that follow authentication and issues tokens
+func (s *AuthService) completeLogin(ctx context.Context, u *user.User) (*auth.TokenPair, *user.User, error) {
+	if u.Status != user.StatusActive {
+		return nil, nil, auth.ErrAccountInactive
+	}
+
+	if s.emailVerification != nil {
+		if err := s.emailVerification.CheckLogin(u); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tokenPair, err := s.tokenService.GenerateTokenPair(ctx, u)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	_ = s.userRepo.UpdateLastLogin(ctx, u.ID, time.Now())
+
+	return tokenPair, u, nil
+}
+
+// recordFailure applies the lockout policy to a failed sign-in, or only counts it
+// against the account when no policy is configured
+func (s *AuthService) recordFailure(ctx context.Context, ip, identifier string, u *user.User) {
+	if s.lockout != nil {
+		s.lockout.RecordFailure(ctx, ip, identifier, u)
+		return
+	}
+	if u != nil {
+		_, _ = s.userRepo.IncrementFailedLoginAttempts(ctx, u.ID)
+	}
+}
+
+// isPasskeyRejection reports whether a passkey login failed because of the assertion
+// rather than an internal error
+func isPasskeyRejection(err error) bool {
+	for _, target := range []error{
+		webauthn.ErrCeremonyNotFound,
+		webauthn.ErrCeremonyExpired,
+		webauthn.ErrCredentialNotFound,
+		webauthn.ErrVerificationFailed,
+		webauthn.ErrUnsupportedAlgorithm,
+		webauthn.ErrUserVerificationRequired,
+		webauthn.ErrCloneDetected,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestMagicLink emails a one-time sign-in link and returns the nonce the requesting
+// browser must present to redeem it. A nonce is returned whether or not the email belongs
+// to an account, and the call always takes at least MinResponseTime.
+func (s *AuthService) RequestMagicLink(ctx context.Context, email string) (string, error) {
+	if s.magicLinks == nil {
+		return "", auth.ErrMagicLinkDisabled
+	}
+
+	defer s.padResponse(time.Now())
+
+	nonce, err := generateMagicLinkSecret()
+	if err != nil {
+		return "", err
+	}
+
+	u, err := s.userRepo.GetByEmail(ctx, strings.ToLower(email))
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return nonce, nil
+		}
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Inactive accounts get no email, but the caller sees the same outcome
+	if u.Status != user.StatusActive {
+		return nonce, nil
+	}
+
+	token, err := generateMagicLinkSecret()
+	if err != nil {
+		return "", err
+	}
+
+	// Abandoned links are cleared as new ones are requested, and a new request
+	// replaces any outstanding link of the user
+	_ = s.magicLinks.DeleteExpired(ctx)
+	if err := s.magicLinks.DeleteByUser(ctx, u.ID); err != nil {
+		return "", fmt.Errorf("failed to replace sign-in links: %w", err)
+	}
+
+	now := time.Now()
+	link := &auth.MagicLink{
+		ID:        uuid.New(),
+		UserID:    u.ID,
+		TokenHash: hashMagicLinkSecret(token),
+		NonceHash: hashMagicLinkSecret(nonce),
+		ExpiresAt: now.Add(s.config.MagicLinkExpiry),
+		CreatedAt: now,
+	}
+	if err := s.magicLinks.Create(ctx, link); err != nil {
+		return "", fmt.Errorf("failed to store sign-in link: %w", err)
+	}
+
+	s.sendInBackground(ctx, s.magicLinkMessage(u, token), "sign-in email")
+
+	return nonce, nil
+}
+
+// RedeemMagicLink signs in with a link, presented with the nonce of the browser that
+// requested it. Accounts with MFA enabled must also pass a second factor unless the
+// browser is a trusted device; the link stays valid until they do, so the code can be
+// supplied in a retry.
+func (s *AuthService) RedeemMagicLink(ctx context.Context, req *auth.MagicLinkRedeemRequest, nonce string) (*auth.TokenPair, *user.User, error) {
+	if s.magicLinks == nil {
+		return nil, nil, auth.ErrMagicLinkDisabled
+	}
+
+	link, err := s.magicLinks.GetByTokenHash(ctx, hashMagicLinkSecret(req.Token))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidMagicLink) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("failed to get sign-in link: %w", err)
+	}
+
+	if link.IsExpired() {
+		return nil, nil, auth.ErrInvalidMagicLink
+	}
+	if subtle.ConstantTimeCompare([]byte(hashMagicLinkSecret(nonce)), []byte(link.NonceHash)) != 1 {
+		return nil, nil, auth.ErrInvalidMagicLink
+	}
+
+	u, err := s.userRepo.GetByID(ctx, link.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return nil, nil, auth.ErrInvalidMagicLink
+		}
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if u.IsLocked() {
+		return nil, nil, auth.ErrAccountLocked
+	}
+
+	verified, err := s.requireSecondFactor(ctx, u, req.MFAMethod, req.MFACode, nil, req.DeviceToken, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Consuming the link last keeps it single-use even if two redemptions race
+	if err := s.magicLinks.Consume(ctx, link.ID); err != nil {
+		if errors.Is(err, auth.ErrInvalidMagicLink) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("failed to consume sign-in link: %w", err)
+	}
+
+	tokenPair, u, err := s.completeLogin(ctx, u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if verified && req.RememberDevice {
+		tokenPair.TrustedDeviceToken = s.rememberDevice(ctx, u, req.IPAddress, req.UserAgent)
+	}
+
+	return tokenPair, u, nil
+}
+
+// requireSecondFactor requires a valid MFA code or security key assertion from
+// accounts that have MFA enabled, unless the sign-in comes from a device the user
+// trusts. Sign-ins that need a step-up are challenged even on a trusted device. It
+// reports whether a second factor was checked, which is what lets the device be
+// remembered.
+func (s *AuthService) requireSecondFactor(
+	ctx context.Context,
+	u *user.User,
+	method mfa.Method,
+	code string,
+	assertion *webauthn.FinishLoginRequest,
+	deviceToken string,
+	stepUp bool,
+) (bool, error) {
+	enabled := u.MFAEnabled
+	if s.mfaService != nil {
+		var err error
+		if enabled, err = s.mfaService.IsEnabled(ctx, u.ID); err != nil {
+			return false, fmt.Errorf("failed to check MFA: %w", err)
+		}
+	}
+	if !enabled {
+		return false, nil
+	}
+
+	if !stepUp && s.deviceTrusted(ctx, u, deviceToken) {
+		return false, nil
+	}
+
+	if method == mfa.MethodWebAuthn {
+		err := s.verifySecurityKey(ctx, u, assertion)
+		return err == nil, err
+	}
+
+	if err := s.verifySecondFactor(ctx, u, method, code); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// verifySecurityKey checks a security key assertion answering a challenge from
+// BeginSecurityKeyChallenge for the same account
+func (s *AuthService) verifySecurityKey(ctx context.Context, u *user.User, assertion *webauthn.FinishLoginRequest) error {
+	if assertion == nil || s.webauthn == nil {
+		return auth.ErrMFARequired
+	}
+
+	if _, err := s.webauthn.VerifyChallenge(ctx, u.ID, assertion); err != nil {
+		if isPasskeyRejection(err) {
+			return auth.ErrInvalidMFACode
+		}
+		return fmt.Errorf("failed to verify security key: %w", err)
+	}
+
+	return nil
+}
+
+// verifySecondFactor checks an MFA code for an account that has MFA enabled
+func (s *AuthService) verifySecondFactor(ctx context.Context, u *user.User, method mfa.Method, code string) error {
+	if code == "" || s.mfaService == nil {
+		return auth.ErrMFARequired
+	}
+
+	result, err := s.mfaService.VerifyCode(ctx, &mfa.VerifyRequest{
+		UserID: u.ID,
+		Method: method,
+		Code:   code,
+	})
+	if err != nil {
+		if errors.Is(err, mfa.ErrMethodNotConfigured) || errors.Is(err, mfa.ErrInvalidMethod) || errors.Is(err, mfa.ErrRateLimited) {
+			return auth.ErrInvalidMFACode
+		}
+		return fmt.Errorf("failed to verify MFA code: %w", err)
+	}
+	if !result.Valid {
+		return auth.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// deviceTrusted reports whether the device token names a device the user trusts
+func (s *AuthService) deviceTrusted(ctx context.Context, u *user.User, deviceToken string) bool {
+	if s.trustedDevices == nil || deviceToken == "" {
+		return false
+	}
+	_, err := s.trustedDevices.Verify(ctx, u.ID, deviceToken)
+	return err == nil
+}
+
+// rememberDevice trusts the device a sign-in came from and returns its token.
+// The sign-in has already succeeded, so failing to remember it is not an error.
+func (s *AuthService) rememberDevice(ctx context.Context, u *user.User, ipAddress, userAgent string) string {
+	if s.trustedDevices == nil {
+		return ""
+	}
+	token, _, err := s.trustedDevices.Trust(ctx, u.ID, ipAddress, userAgent)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// Logout revokes the user's tokens
+func (s *AuthService) Logout(ctx context.Context, userID uuid.UUID, tokenID string) error {
+	return s.tokenService.RevokeToken(ctx, tokenID)
+}
+
+// RefreshTokens refreshes the token pair
+func (s *AuthService) RefreshTokens(ctx context.Context, refreshToken string) (*auth.TokenPair, error) {
+	return s.tokenService.RefreshTokens(ctx, refreshToken)
+}
+
+// ValidateToken validates an access token
+func (s *AuthService) ValidateToken(ctx context.Context, token string) (*auth.Claims, error) {
+	return s.tokenService.ValidateToken(ctx, token, auth.AccessToken)
+}
+
+// RequestPasswordReset initiates password reset process.
+// It succeeds whether or not the email belongs to an account, and always
+// takes at least MinResponseTime, so callers cannot probe for accounts.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	defer s.padResponse(time.Now())
+
+	u, err := s.userRepo.GetByEmail(ctx, strings.ToLower(email))
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Inactive accounts get no email, but the caller sees the same outcome
+	if u.Status != user.StatusActive {
+		return nil
+	}
+
+	token, tokenHash, err := generateResetToken(u.ID)
+	if err != nil {
+		return err
+	}
+
+	// Only the hash is stored; a new request replaces any outstanding token
+	expiresAt := time.Now().Add(s.config.PasswordResetExpiry)
+	u.PasswordResetToken = tokenHash
+	u.PasswordResetExpiry = &expiresAt
+
+	if err := s.userRepo.Update(ctx, u); err != nil {
+		return fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	// Sending in the background keeps mail latency and failures from revealing the account
+	s.sendInBackground(ctx, s.passwordResetMessage(u, token), "password reset email")
+
+	return nil
+}
+
+// ResetPassword resets user password with token
+func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	userID, err := parseResetToken(token)
+	if err != nil {
+		return auth.ErrInvalidResetToken
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return auth.ErrInvalidResetToken
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !u.CanResetPassword() {
+		return auth.ErrInvalidResetToken
+	}
+
+	tokenHash := hashResetToken(token)
+	if subtle.ConstantTimeCompare([]byte(tokenHash), []byte(u.PasswordResetToken)) != 1 {
+		return auth.ErrInvalidResetToken
+	}
+
+	if err := s.validatePassword(ctx, newPassword, u.Username, u.Email); err != nil {
+		return err
+	}
+
+	if err := s.checkPasswordReuse(ctx, u, newPassword); err != nil {
+		return err
+	}
+
+	// Proving control of the mailbox also clears any lockout
+	u.PasswordResetToken = ""
+	u.PasswordResetExpiry = nil
+	u.FailedLoginAttempts = 0
+	u.LockedUntil = nil
+
+	return s.setPassword(ctx, u, newPassword)
+}
+
+// ChangePassword changes user password (requires old password)
+func (s *AuthService) ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error {
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !s.passwordHasher.VerifyPassword(oldPassword, u.PasswordHash) {
+		return auth.ErrInvalidCredentials
+	}
+
+	if err := s.validatePassword(ctx, newPassword, u.Username, u.Email); err != nil {
+		return err
+	}
+
+	if err := s.checkPasswordReuse(ctx, u, newPassword); err != nil {
+		return err
+	}
+
+	return s.setPassword(ctx, u, newPassword)
+}
+
+// ChangeExpiredPassword replaces a password that has outlived the maximum password
+// age. The user has no session, so the request must pass every check a password
+// sign-in would; afterwards the user signs in with the new password.
+func (s *AuthService) ChangeExpiredPassword(ctx context.Context, req *auth.ExpiredPasswordChangeRequest) error {
+	if s.lockout != nil {
+		if _, err := s.lockout.CheckIP(ctx, req.IPAddress); err != nil {
+			return err
+		}
+	}
+
+	u, err := s.authenticatePassword(ctx, &req.LoginRequest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.assessRisk(ctx, u, &req.LoginRequest); err != nil {
+		return err
+	}
+
+	if _, err := s.requireSecondFactor(ctx, u, req.MFAMethod, req.MFACode, req.MFAAssertion, req.DeviceToken, false); err != nil {
+		return err
+	}
+
+	if u.Status != user.StatusActive {
+		return auth.ErrAccountInactive
+	}
+
+	if s.passwordPolicy == nil || !s.passwordPolicy.IsExpired(u) {
+		return auth.ErrPasswordNotExpired
+	}
+
+	if err := s.validatePassword(ctx, req.NewPassword, u.Username, u.Email); err != nil {
+		return err
+	}
+
+	if err := s.checkPasswordReuse(ctx, u, req.NewPassword); err != nil {
+		return err
+	}
+
+	return s.setPassword(ctx, u, req.NewPassword)
+}
+
+// checkPasswordReuse refuses a new password the password history remembers
+func (s *AuthService) checkPasswordReuse(ctx context.Context, u *user.User, newPassword string) error {
+	if s.passwordPolicy == nil {
+		return nil
+	}
+	return s.passwordPolicy.CheckReuse(ctx, u, newPassword)
+}
+
+// setPassword stores a new password hash, signs the user out everywhere and
+// forgets their trusted devices
+func (s *AuthService) setPassword(ctx context.Context, u *user.User, newPassword string) error {
+	hashedPassword, err := s.passwordHasher.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	// Recorded first: a failed update leaves the current password in its own history, which is harmless
+	if s.passwordPolicy != nil {
+		if err := s.passwordPolicy.Remember(ctx, u.ID, u.PasswordHash); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	u.PasswordHash = hashedPassword
+	u.PasswordChangedAt = &now
+	u.UpdatedAt = now
+
+	if err := s.userRepo.Update(ctx, u); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if err := s.tokenService.RevokeAllForUser(ctx, u.ID); err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+
+	if s.sessionService != nil {
+		if err := s.sessionService.InvalidateUserSessions(ctx, u.ID); err != nil {
+			return fmt.Errorf("failed to invalidate sessions: %w", err)
+		}
+	}
+
+	if s.trustedDevices != nil {
+		if err := s.trustedDevices.RevokeAll(ctx, u.ID, "password changed"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validatePassword checks a password against the password policy
+func (s *AuthService) validatePassword(ctx context.Context, password, username, email string) error {
+	result, err := s.passwordValidator.Validate(ctx, password, username, email)
+	if err != nil || !result.IsValid {
+		var details []string
+		if result != nil {
+			details = result.Errors
+		}
+		return fmt.Errorf("%w: %s", auth.ErrPasswordTooWeak, strings.Join(details, "; "))
+	}
+	return nil
+}
+
+// passwordResetMessage builds the email that delivers a reset link
+func (s *AuthService) passwordResetMessage(u *user.User, token string) *mail.Message {
+	link := s.config.PasswordResetURL + "?token=" + url.QueryEscape(token)
+	minutes := int(s.config.PasswordResetExpiry.Minutes())
+
+	return &mail.Message{
+		To:      []string{u.Email},
+		Subject: "Reset your password",
+		TextBody: fmt.Sprintf(
+			"Hi %s,\n\nWe received a request to reset your password. Use the link below to choose a new one:\n\n%s\n\n"+
+				"The link expires in %d minutes and can only be used once. If you didn't ask for this, you can ignore this email.\n",
+			u.Username, link, minutes,
+		),
+	}
+}
+
+// magicLinkMessage builds the email that delivers a sign-in link
+func (s *AuthService) magicLinkMessage(u *user.User, token string) *mail.Message {
+	link := s.config.MagicLinkURL + "?token=" + url.QueryEscape(token)
+	minutes := int(s.config.MagicLinkExpiry.Minutes())
+
+	return &mail.Message{
+		To:      []string{u.Email},
+		Subject: "Your sign-in link",
+		TextBody: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to sign in:\n\n%s\n\n"+
+				"The link expires in %d minutes, can only be used once, and only works in the browser you requested it from. "+
+				"If you didn't ask for this, you can ignore this email.\n",
+			u.Username, link, minutes,
+		),
+	}
+}
+
+// sendInBackground sends msg without holding up the request; failures are logged
+// because the caller has already been told the email is on its way
+func (s *AuthService) sendInBackground(ctx context.Context, msg *mail.Message, description string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundMailTimeout)
+
+	go func() {
+		defer cancel()
+		if err := s.mailSender.Send(ctx, msg); err != nil {
+			s.logger.Error("Failed to send "+description, zap.Error(err))
+		}
+	}()
+}
+
+// padResponse sleeps until MinResponseTime has elapsed since start
+func (s *AuthService) padResponse(start time.Time) {
+	if remaining := s.config.MinResponseTime - time.Since(start); remaining > 0 {
+		time.Sleep(remaining)
+	}
+}
+
+// generateResetToken creates a reset token and the hash to store for it.
+// The token embeds the user ID so it can be checked without a lookup by token.
+func generateResetToken(userID uuid.UUID) (string, string, error) {
+	secret := make([]byte, resetTokenBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(userID[:]) + "." + base64.RawURLEncoding.EncodeToString(secret)
+	return token, hashResetToken(token), nil
+}
+
+// parseResetToken extracts the user ID from a reset token
+func parseResetToken(token string) (uuid.UUID, error) {
+	idPart, secretPart, ok := strings.Cut(token, ".")
+	if !ok || secretPart == "" {
+		return uuid.Nil, auth.ErrInvalidResetToken
+	}
+
+	idBytes, err := base64.RawURLEncoding.DecodeString(idPart)
+	if err != nil {
+		return uuid.Nil, auth.ErrInvalidResetToken
+	}
+
+	return uuid.FromBytes(idBytes)
+}
+
+// hashResetToken returns the hex SHA-256 digest stored in place of a reset token
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateMagicLinkSecret generates a sign-in link token or browser nonce
+func generateMagicLinkSecret() (string, error) {
+	b := make([]byte, magicLinkSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate sign-in link: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashMagicLinkSecret returns the hex SHA-256 digest stored in place of a link token or nonce
+func hashMagicLinkSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,474 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mail"
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+	"github.com/victoralfred/um_sys/pkg/security"
+)
+
+type authServiceFixture struct {
+	service      *services.AuthService
+	userRepo     *MockUserRepository
+	mailSender   *MockMailSender
+	tokenService *services.TokenService
+	hasher       *security.PasswordHasher
+	user         *user.User
+}
+
+func newAuthServiceFixture(t *testing.T) *authServiceFixture {
+	t.Helper()
+
+	userRepo := new(MockUserRepository)
+	mailSender := new(MockMailSender)
+	hasher := security.NewPasswordHasher()
+
+	tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+	tokenService.SetTokenStore(NewInMemoryTokenStore())
+
+	validator := security.NewPasswordValidator(&security.PasswordPolicy{
+		MinLength:        8,
+		RequireUppercase: 1,
+		RequireLowercase: 1,
+		RequireNumbers:   1,
+	})
+
+	config := services.DefaultAuthConfig()
+	config.PasswordResetURL = "https://app.example.com/reset"
+	config.MagicLinkURL = "https://app.example.com/magic-link"
+	config.MinResponseTime = 20 * time.Millisecond
+
+	passwordHash, err := hasher.HashPassword("OldPassword1")
+	require.NoError(t, err)
+
+	return &authServiceFixture{
+		service:      services.NewAuthService(userRepo, tokenService, hasher, validator, mailSender, config),
+		userRepo:     userRepo,
+		mailSender:   mailSender,
+		tokenService: tokenService,
+		hasher:       hasher,
+		user: &user.User{
+			ID:           uuid.New(),
+			Email:        "test@example.com",
+			Username:     "testuser",
+			PasswordHash: passwordHash,
+			Status:       user.StatusActive,
+		},
+	}
+}
+
+var (
+	resetLinkPattern = regexp.MustCompile(`https://app\.example\.com/reset\?token=(\S+)`)
+	magicLinkPattern = regexp.MustCompile(`https://app\.example\.com/magic-link\?token=(\S+)`)
+)
+
+// expectMail expects one email, which is sent in the background, and delivers it on the returned channel
+func (f *authServiceFixture) expectMail(err error) <-chan *mail.Message {
+	sent := make(chan *mail.Message, 1)
+	f.mailSender.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sent <- args.Get(1).(*mail.Message)
+	}).Return(err).Once()
+	return sent
+}
+
+func waitForMail(t *testing.T, sent <-chan *mail.Message) *mail.Message {
+	t.Helper()
+
+	select {
+	case msg := <-sent:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("email was not sent")
+		return nil
+	}
+}
+
+// requestResetToken runs the forgot-password flow and returns the token from the email
+func (f *authServiceFixture) requestResetToken(t *testing.T, ctx context.Context) string {
+	t.Helper()
+
+	f.userRepo.On("GetByEmail", ctx, f.user.Email).Return(f.user, nil).Once()
+	f.userRepo.On("Update", ctx, f.user).Return(nil).Once()
+	sent := f.expectMail(nil)
+
+	require.NoError(t, f.service.RequestPasswordReset(ctx, f.user.Email))
+	msg := waitForMail(t, sent)
+
+	match := resetLinkPattern.FindStringSubmatch(msg.TextBody)
+	require.Len(t, match, 2)
+
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+func TestAuthService_RequestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stores only a hash of the emailed token", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+
+		token := f.requestResetToken(t, ctx)
+
+		assert.NotEmpty(t, f.user.PasswordResetToken)
+		assert.NotEqual(t, token, f.user.PasswordResetToken)
+		assert.NotContains(t, f.user.PasswordResetToken, token)
+		require.NotNil(t, f.user.PasswordResetExpiry)
+		assert.True(t, f.user.PasswordResetExpiry.After(time.Now()))
+		f.mailSender.AssertExpectations(t)
+	})
+
+	t.Run("unknown email succeeds silently after the minimum response time", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		f.userRepo.On("GetByEmail", ctx, "nobody@example.com").Return(nil, user.ErrUserNotFound)
+
+		start := time.Now()
+		err := f.service.RequestPasswordReset(ctx, "nobody@example.com")
+
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+		f.mailSender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+
+	t.Run("inactive account receives no email", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		f.user.Status = user.StatusSuspended
+		f.userRepo.On("GetByEmail", ctx, f.user.Email).Return(f.user, nil)
+
+		err := f.service.RequestPasswordReset(ctx, f.user.Email)
+
+		assert.NoError(t, err)
+		f.mailSender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+
+	t.Run("mail failures are not reported to the caller", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		f.userRepo.On("GetByEmail", ctx, f.user.Email).Return(f.user, nil)
+		f.userRepo.On("Update", ctx, f.user).Return(nil)
+		sent := f.expectMail(errors.New("smtp unavailable"))
+
+		err := f.service.RequestPasswordReset(ctx, f.user.Email)
+
+		assert.NoError(t, err)
+		waitForMail(t, sent)
+	})
+}
+
+func TestAuthService_ResetPassword(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("resets password and revokes existing tokens", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+
+		tokenPair, err := f.tokenService.GenerateTokenPair(ctx, f.user)
+		require.NoError(t, err)
+
+		token := f.requestResetToken(t, ctx)
+		f.userRepo.On("GetByID", ctx, f.user.ID).Return(f.user, nil)
+		f.userRepo.On("Update", ctx, f.user).Return(nil).Once()
+
+		err = f.service.ResetPassword(ctx, token, "NewPassword2")
+		require.NoError(t, err)
+
+		assert.True(t, f.hasher.VerifyPassword("NewPassword2", f.user.PasswordHash))
+		assert.Empty(t, f.user.PasswordResetToken)
+		assert.Nil(t, f.user.PasswordResetExpiry)
+		assert.NotNil(t, f.user.PasswordChangedAt)
+
+		_, err = f.tokenService.ValidateToken(ctx, tokenPair.AccessToken, auth.AccessToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+		_, err = f.tokenService.RefreshTokens(ctx, tokenPair.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+	})
+
+	t.Run("token is single use", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+
+		token := f.requestResetToken(t, ctx)
+		f.userRepo.On("GetByID", ctx, f.user.ID).Return(f.user, nil)
+		f.userRepo.On("Update", ctx, f.user).Return(nil).Once()
+
+		require.NoError(t, f.service.ResetPassword(ctx, token, "NewPassword2"))
+
+		err := f.service.ResetPassword(ctx, token, "AnotherPassword3")
+		assert.ErrorIs(t, err, auth.ErrInvalidResetToken)
+	})
+
+	t.Run("expired token is rejected", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+
+		token := f.requestResetToken(t, ctx)
+		expired := time.Now().Add(-time.Minute)
+		f.user.PasswordResetExpiry = &expired
+		f.userRepo.On("GetByID", ctx, f.user.ID).Return(f.user, nil)
+
+		err := f.service.ResetPassword(ctx, token, "NewPassword2")
+		assert.ErrorIs(t, err, auth.ErrInvalidResetToken)
+	})
+
+	t.Run("tampered token is rejected", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+
+		token := f.requestResetToken(t, ctx)
+		f.userRepo.On("GetByID", ctx, f.user.ID).Return(f.user, nil)
+
+		err := f.service.ResetPassword(ctx, token+"x", "NewPassword2")
+		assert.ErrorIs(t, err, auth.ErrInvalidResetToken)
+
+		err = f.service.ResetPassword(ctx, "not-a-token", "NewPassword2")
+		assert.ErrorIs(t, err, auth.ErrInvalidResetToken)
+	})
+
+	t.Run("weak password is rejected by policy", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+
+		token := f.requestResetToken(t, ctx)
+		f.userRepo.On("GetByID", ctx, f.user.ID).Return(f.user, nil)
+
+		err := f.service.ResetPassword(ctx, token, "weakpass")
+		assert.ErrorIs(t, err, auth.ErrPasswordTooWeak)
+		assert.NotEmpty(t, f.user.PasswordResetToken, "token must stay usable after a rejected password")
+	})
+
+	t.Run("reused password is rejected by history", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		f.service.SetPasswordPolicyService(services.NewPasswordPolicyService(
+			NewInMemoryPasswordHistoryRepository(), f.hasher, services.DefaultPasswordPolicyConfig(),
+		))
+
+		token := f.requestResetToken(t, ctx)
+		f.userRepo.On("GetByID", ctx, f.user.ID).Return(f.user, nil)
+
+		err := f.service.ResetPassword(ctx, token, "OldPassword1")
+		assert.ErrorIs(t, err, auth.ErrPasswordReused)
+		assert.NotEmpty(t, f.user.PasswordResetToken, "token must stay usable after a rejected password")
+	})
+}
+
+// enableMagicLinks turns on sign-in links for the fixture's service
+func (f *authServiceFixture) enableMagicLinks(t *testing.T) *InMemoryMagicLinkRepository {
+	t.Helper()
+
+	repo := NewInMemoryMagicLinkRepository()
+	f.service.SetMagicLinkRepository(repo)
+	return repo
+}
+
+// requestMagicLink runs the sign-in link request and returns the emailed token and the browser nonce
+func (f *authServiceFixture) requestMagicLink(t *testing.T, ctx context.Context) (string, string) {
+	t.Helper()
+
+	f.userRepo.On("GetByEmail", ctx, f.user.Email).Return(f.user, nil).Once()
+	sent := f.expectMail(nil)
+
+	nonce, err := f.service.RequestMagicLink(ctx, f.user.Email)
+	require.NoError(t, err)
+	require.NotEmpty(t, nonce)
+	msg := waitForMail(t, sent)
+
+	match := magicLinkPattern.FindStringSubmatch(msg.TextBody)
+	require.Len(t, match, 2)
+
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token, nonce
+}
+
+func TestAuthService_RequestMagicLink(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("is disabled unless configured", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+
+		_, err := f.service.RequestMagicLink(ctx, f.user.Email)
+		assert.ErrorIs(t, err, auth.ErrMagicLinkDisabled)
+	})
+
+	t.Run("stores only hashes of the token and nonce", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		repo := f.enableMagicLinks(t)
+
+		token, nonce := f.requestMagicLink(t, ctx)
+
+		require.Len(t, repo.links, 1)
+		for _, link := range repo.links {
+			assert.Equal(t, f.user.ID, link.UserID)
+			assert.NotContains(t, link.TokenHash, token)
+			assert.NotContains(t, link.NonceHash, nonce)
+			assert.True(t, link.ExpiresAt.After(time.Now()))
+			assert.True(t, link.ExpiresAt.Before(time.Now().Add(services.DefaultAuthConfig().MagicLinkExpiry+time.Second)))
+		}
+	})
+
+	t.Run("a new request replaces the outstanding link", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		repo := f.enableMagicLinks(t)
+
+		f.requestMagicLink(t, ctx)
+		f.requestMagicLink(t, ctx)
+
+		assert.Len(t, repo.links, 1)
+	})
+
+	t.Run("unknown email still returns a nonce after the minimum response time", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		f.enableMagicLinks(t)
+		f.userRepo.On("GetByEmail", ctx, "nobody@example.com").Return(nil, user.ErrUserNotFound)
+
+		start := time.Now()
+		nonce, err := f.service.RequestMagicLink(ctx, "nobody@example.com")
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, nonce)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+		f.mailSender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+
+	t.Run("mail failures are not reported to the caller", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		f.enableMagicLinks(t)
+		f.userRepo.On("GetByEmail", ctx, f.user.Email).Return(f.user, nil)
+		sent := f.expectMail(errors.New("smtp unavailable"))
+
+		nonce, err := f.service.RequestMagicLink(ctx, f.user.Email)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, nonce)
+		waitForMail(t, sent)
+	})
+}
+
+func TestAuthService_RedeemMagicLink(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("signs in once from the requesting browser", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		f.enableMagicLinks(t)
+
+		token, nonce := f.requestMagicLink(t, ctx)
+		f.userRepo.On("GetByID", ctx, f.user.ID).Return(f.user, nil)
+		f.userRepo.On("UpdateLastLogin", ctx, f.user.ID, mock.Anything).Return(nil)
+
+		tokenPair, u, err := f.service.RedeemMagicLink(ctx, &auth.MagicLinkRedeemRequest{Token: token}, nonce)
+		require.NoError(t, err)
+		assert.Equal(t, f.user.ID, u.ID)
+		assert.NotEmpty(t, tokenPair.AccessToken)
+		assert.NotEmpty(t, tokenPair.FamilyID)
+
+		_, _, err = f.service.RedeemMagicLink(ctx, &auth.MagicLinkRedeemRequest{Token: token}, nonce)
+		assert.ErrorIs(t, err, auth.ErrInvalidMagicLink)
+	})
+
+	t.Run("rejects another browser", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		f.enableMagicLinks(t)
+
+		token, _ := f.requestMagicLink(t, ctx)
+
+		_, _, err := f.service.RedeemMagicLink(ctx, &auth.MagicLinkRedeemRequest{Token: token}, "")
+		assert.ErrorIs(t, err, auth.ErrInvalidMagicLink)
+
+		_, _, err = f.service.RedeemMagicLink(ctx, &auth.MagicLinkRedeemRequest{Token: token}, "other-browser-nonce")
+		assert.ErrorIs(t, err, auth.ErrInvalidMagicLink)
+	})
+
+	t.Run("rejects expired and unknown links", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		repo := f.enableMagicLinks(t)
+
+		token, nonce := f.requestMagicLink(t, ctx)
+		repo.expire()
+
+		_, _, err := f.service.RedeemMagicLink(ctx, &auth.MagicLinkRedeemRequest{Token: token}, nonce)
+		assert.ErrorIs(t, err, auth.ErrInvalidMagicLink)
+
+		_, _, err = f.service.RedeemMagicLink(ctx, &auth.MagicLinkRedeemRequest{Token: "unknown"}, nonce)
+		assert.ErrorIs(t, err, auth.ErrInvalidMagicLink)
+	})
+
+	t.Run("requires a second factor when MFA is enabled", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		f.enableMagicLinks(t)
+
+		mfaRepo := new(MockMFARepository)
+		totp := new(MockTOTPProvider)
+		f.service.SetMFAService(services.NewMFAService(mfaRepo, f.userRepo, totp, nil, nil, nil, nil, nil))
+
+		mfaRepo.On("GetSettings", ctx, f.user.ID).Return(&mfa.Settings{
+			UserID:     f.user.ID,
+			Enabled:    true,
+			TOTPSecret: "JBSWY3DPEHPK3PXP",
+			Methods:    []mfa.Method{mfa.MethodTOTP},
+		}, nil)
+		mfaRepo.On("SaveSettings", ctx, mock.Anything).Return(nil)
+		mfaRepo.On("LogAudit", ctx, mock.Anything).Return(nil)
+		totp.On("ValidateCode", "JBSWY3DPEHPK3PXP", "654321").Return(false, nil)
+		totp.On("ValidateCode", "JBSWY3DPEHPK3PXP", "123456").Return(true, nil)
+
+		token, nonce := f.requestMagicLink(t, ctx)
+		f.userRepo.On("GetByID", ctx, f.user.ID).Return(f.user, nil)
+		f.userRepo.On("UpdateLastLogin", ctx, f.user.ID, mock.Anything).Return(nil)
+
+		_, _, err := f.service.RedeemMagicLink(ctx, &auth.MagicLinkRedeemRequest{Token: token}, nonce)
+		assert.ErrorIs(t, err, auth.ErrMFARequired)
+
+		_, _, err = f.service.RedeemMagicLink(ctx, &auth.MagicLinkRedeemRequest{
+			Token:     token,
+			MFAMethod: mfa.MethodTOTP,
+			MFACode:   "654321",
+		}, nonce)
+		assert.ErrorIs(t, err, auth.ErrInvalidMFACode)
+
+		// The link survives failed second factors
+		tokenPair, _, err := f.service.RedeemMagicLink(ctx, &auth.MagicLinkRedeemRequest{
+			Token:     token,
+			MFAMethod: mfa.MethodTOTP,
+			MFACode:   "123456",
+		}, nonce)
+		require.NoError(t, err)
+		assert.NotEmpty(t, tokenPair.AccessToken)
+	})
+
+	t.Run("fails closed for MFA accounts without an MFA service", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		f.enableMagicLinks(t)
+		f.user.MFAEnabled = true
+
+		token, nonce := f.requestMagicLink(t, ctx)
+		f.userRepo.On("GetByID", ctx, f.user.ID).Return(f.user, nil)
+
+		_, _, err := f.service.RedeemMagicLink(ctx, &auth.MagicLinkRedeemRequest{
+			Token:     token,
+			MFAMethod: mfa.MethodTOTP,
+			MFACode:   "123456",
+		}, nonce)
+		assert.ErrorIs(t, err, auth.ErrMFARequired)
+	})
+
+	t.Run("applies the account status checks", func(t *testing.T) {
+		f := newAuthServiceFixture(t)
+		f.enableMagicLinks(t)
+
+		token, nonce := f.requestMagicLink(t, ctx)
+		suspended := *f.user
+		suspended.Status = user.StatusSuspended
+		f.userRepo.On("GetByID", ctx, f.user.ID).Return(&suspended, nil)
+
+		_, _, err := f.service.RedeemMagicLink(ctx, &auth.MagicLinkRedeemRequest{Token: token}, nonce)
+		assert.ErrorIs(t, err, auth.ErrAccountInactive)
+	})
+}
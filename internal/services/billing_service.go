@@ -57,14 +57,23 @@ func (s *BillingService) CreateSubscription(ctx context.Context, req *billing.Cr
 		return nil, billing.ErrPlanInactive
 	}
 
-	existingSub, err := s.subscriptionRepo.GetActiveByUserID(ctx, req.UserID)
+	// An organization's subscription is billed to the organization, not to the
+	// member who set it up
+	customerID := req.UserID
+	var existingSub *billing.Subscription
+	if req.OrganizationID != nil {
+		customerID = *req.OrganizationID
+		existingSub, err = s.subscriptionRepo.GetActiveByOrganizationID(ctx, *req.OrganizationID)
+	} else {
+		existingSub, err = s.subscriptionRepo.GetActiveByUserID(ctx, req.UserID)
+	}
 	if err == nil && existingSub != nil {
 		return nil, billing.ErrSubscriptionAlreadyExists
 	} else if err != nil && err != billing.ErrNoActiveSubscription {
 		return nil, err
 	}
 
-	stripeCustomerID, err := s.gateway.CreateCustomer(ctx, req.UserID, "")
+	stripeCustomerID, err := s.gateway.CreateCustomer(ctx, customerID, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create customer: %w", err)
 	}
@@ -92,6 +101,7 @@ func (s *BillingService) CreateSubscription(ctx context.Context, req *billing.Cr
 		Metadata:             req.Metadata,
 		CreatedAt:            now,
 		UpdatedAt:            now,
+		OrganizationID:       req.OrganizationID,
 	}
 
 	if trialDays > 0 {
@@ -251,6 +261,32 @@ func (s *BillingService) GetCurrentSubscription(ctx context.Context, userID uuid
 	return s.subscriptionRepo.GetActiveByUserID(ctx, userID)
 }
 
+// CurrentPlan returns the plan of the user's active subscription
+func (s *BillingService) CurrentPlan(ctx context.Context, userID uuid.UUID) (*billing.Plan, error) {
+	subscription, err := s.subscriptionRepo.GetActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.planRepo.GetByID(ctx, subscription.PlanID)
+}
+
+func (s *BillingService) GetOrganizationSubscriptions(ctx context.Context, organizationID uuid.UUID) ([]*billing.Subscription, error) {
+	return s.subscriptionRepo.GetByOrganizationID(ctx, organizationID)
+}
+
+func (s *BillingService) GetCurrentOrganizationSubscription(ctx context.Context, organizationID uuid.UUID) (*billing.Subscription, error) {
+	return s.subscriptionRepo.GetActiveByOrganizationID(ctx, organizationID)
+}
+
+// CurrentOrganizationPlan returns the plan of the organization's active subscription
+func (s *BillingService) CurrentOrganizationPlan(ctx context.Context, organizationID uuid.UUID) (*billing.Plan, error) {
+	subscription, err := s.subscriptionRepo.GetActiveByOrganizationID(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return s.planRepo.GetByID(ctx, subscription.PlanID)
+}
+
 func (s *BillingService) CreatePlan(ctx context.Context, plan *billing.Plan) error {
 	if plan.ID == uuid.Nil {
 		plan.ID = uuid.New()
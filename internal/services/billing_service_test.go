@@ -100,6 +100,22 @@ func (m *MockSubscriptionRepository) GetActiveByUserID(ctx context.Context, user
 	return args.Get(0).(*billing.Subscription), args.Error(1)
 }
 
+func (m *MockSubscriptionRepository) GetByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]*billing.Subscription, error) {
+	args := m.Called(ctx, organizationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*billing.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionRepository) GetActiveByOrganizationID(ctx context.Context, organizationID uuid.UUID) (*billing.Subscription, error) {
+	args := m.Called(ctx, organizationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*billing.Subscription), args.Error(1)
+}
+
 func (m *MockSubscriptionRepository) Update(ctx context.Context, subscription *billing.Subscription) error {
 	args := m.Called(ctx, subscription)
 	return args.Error(0)
@@ -327,6 +343,70 @@ func TestBillingService_CreateSubscription(t *testing.T) {
 		mockGateway.AssertExpectations(t)
 	})
 
+	t.Run("organization subscription is billed to the organization", func(t *testing.T) {
+		// Arrange
+		mockPlanRepo := new(MockPlanRepository)
+		mockSubRepo := new(MockSubscriptionRepository)
+		mockGateway := new(MockPaymentGateway)
+
+		billingService := services.NewBillingService(
+			mockPlanRepo, mockSubRepo, new(MockPaymentRepository),
+			nil, nil, nil, nil, mockGateway, nil,
+		)
+
+		userID := uuid.New()
+		organizationID := uuid.New()
+		plan := &billing.Plan{ID: uuid.New(), Name: "Team Plan", IsActive: true}
+
+		mockPlanRepo.On("GetByID", ctx, plan.ID).Return(plan, nil)
+		mockSubRepo.On("GetActiveByOrganizationID", ctx, organizationID).Return(nil, billing.ErrNoActiveSubscription)
+		mockGateway.On("CreateCustomer", ctx, organizationID, mock.AnythingOfType("string")).Return("cus_org", nil)
+		mockGateway.On("CreateSubscription", ctx, "cus_org", mock.AnythingOfType("string"), 0).Return("sub_org", nil)
+		mockSubRepo.On("Create", ctx, mock.AnythingOfType("*billing.Subscription")).Return(nil)
+
+		// Act
+		subscription, err := billingService.CreateSubscription(ctx, &billing.CreateSubscriptionRequest{
+			UserID:         userID,
+			PlanID:         plan.ID,
+			OrganizationID: &organizationID,
+		})
+
+		// Assert
+		require.NoError(t, err)
+		require.NotNil(t, subscription.OrganizationID)
+		assert.Equal(t, organizationID, *subscription.OrganizationID)
+		assert.Equal(t, userID, subscription.UserID)
+		mockSubRepo.AssertNotCalled(t, "GetActiveByUserID", ctx, userID)
+		mockGateway.AssertExpectations(t)
+	})
+
+	t.Run("organization with an active subscription", func(t *testing.T) {
+		// Arrange
+		mockPlanRepo := new(MockPlanRepository)
+		mockSubRepo := new(MockSubscriptionRepository)
+
+		billingService := services.NewBillingService(
+			mockPlanRepo, mockSubRepo, new(MockPaymentRepository),
+			nil, nil, nil, nil, new(MockPaymentGateway), nil,
+		)
+
+		organizationID := uuid.New()
+		plan := &billing.Plan{ID: uuid.New(), Name: "Team Plan", IsActive: true}
+
+		mockPlanRepo.On("GetByID", ctx, plan.ID).Return(plan, nil)
+		mockSubRepo.On("GetActiveByOrganizationID", ctx, organizationID).Return(&billing.Subscription{ID: uuid.New()}, nil)
+
+		// Act
+		_, err := billingService.CreateSubscription(ctx, &billing.CreateSubscriptionRequest{
+			UserID:         uuid.New(),
+			PlanID:         plan.ID,
+			OrganizationID: &organizationID,
+		})
+
+		// Assert
+		assert.ErrorIs(t, err, billing.ErrSubscriptionAlreadyExists)
+	})
+
 	t.Run("plan not found", func(t *testing.T) {
 		// Arrange
 		mockPlanRepo := new(MockPlanRepository)
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mail"
+	"github.com/victoralfred/um_sys/internal/domain/ratelimit"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+)
+
+// EmailVerificationConfig holds settings for email verification
+type EmailVerificationConfig struct {
+	// VerifyURL is the page that accepts verification tokens; the token is appended as ?token=
+	VerifyURL string
+
+	// TokenExpiry is how long a verification link stays valid
+	TokenExpiry time.Duration
+
+	// ResendLimit is how many verification emails a user may request per ResendWindow
+	ResendLimit  int
+	ResendWindow time.Duration
+
+	// Policy controls what unverified accounts may do
+	Policy auth.EmailVerificationPolicy
+}
+
+// DefaultEmailVerificationConfig returns the default email verification settings
+func DefaultEmailVerificationConfig() EmailVerificationConfig {
+	return EmailVerificationConfig{
+		VerifyURL:    "http://localhost:8080/verify-email",
+		TokenExpiry:  24 * time.Hour,
+		ResendLimit:  3,
+		ResendWindow: time.Hour,
+	}
+}
+
+// EmailVerificationService issues and redeems signed email verification links
+type EmailVerificationService struct {
+	userRepo    user.Repository
+	mailSender  mail.Sender
+	rateLimiter ratelimit.RateLimiter
+	secret      []byte
+	config      EmailVerificationConfig
+}
+
+// NewEmailVerificationService creates a new email verification service.
+// Resends are not throttled when rateLimiter is nil.
+func NewEmailVerificationService(
+	userRepo user.Repository,
+	mailSender mail.Sender,
+	rateLimiter ratelimit.RateLimiter,
+	secret string,
+	config EmailVerificationConfig,
+) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepo:    userRepo,
+		mailSender:  mailSender,
+		rateLimiter: rateLimiter,
+		secret:      []byte(secret),
+		config:      config,
+	}
+}
+
+// Policy returns the verification policy applied to unverified accounts
+func (s *EmailVerificationService) Policy() *auth.EmailVerificationPolicy {
+	return &s.config.Policy
+}
+
+// CheckLogin returns ErrEmailNotVerified if the policy blocks the user from signing in
+func (s *EmailVerificationService) CheckLogin(u *user.User) error {
+	if s.config.Policy.RequireForLogin && !u.EmailVerified {
+		return auth.ErrEmailNotVerified
+	}
+	return nil
+}
+
+// SendVerification emails a verification link to the user
+func (s *EmailVerificationService) SendVerification(ctx context.Context, u *user.User) error {
+	if u.EmailVerified {
+		return auth.ErrEmailAlreadyVerified
+	}
+
+	token := s.generateToken(u.ID, u.Email, time.Now().Add(s.config.TokenExpiry))
+	link := s.config.VerifyURL + "?token=" + url.QueryEscape(token)
+	hours := int(s.config.TokenExpiry.Hours())
+
+	msg := &mail.Message{
+		To:      []string{u.Email},
+		Subject: "Verify your email address",
+		TextBody: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\n"+
+				"The link expires in %d hours. If you didn't create an account, you can ignore this email.\n",
+			u.Username, link, hours,
+		),
+	}
+
+	if err := s.mailSender.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	return nil
+}
+
+// ResendVerification emails a new verification link, subject to the resend limit.
+// The returned result describes the caller's remaining allowance.
+func (s *EmailVerificationService) ResendVerification(ctx context.Context, userID uuid.UUID) (*ratelimit.RateLimitResult, error) {
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.EmailVerified {
+		return nil, auth.ErrEmailAlreadyVerified
+	}
+
+	var result *ratelimit.RateLimitResult
+	if s.rateLimiter != nil {
+		result, err = s.rateLimiter.Check(ctx, "email_verification:"+userID.String(), s.config.ResendLimit, s.config.ResendWindow)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check resend limit: %w", err)
+		}
+		if !result.Allowed {
+			return result, auth.ErrVerificationThrottled
+		}
+	}
+
+	if err := s.SendVerification(ctx, u); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// VerifyEmail marks the user's email as verified if the token is valid
+func (s *EmailVerificationService) VerifyEmail(ctx context.Context, token string) (*user.User, error) {
+	userID, expiresAt, signature, err := s.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(expiresAt) {
+		return nil, auth.ErrInvalidVerificationToken
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, auth.ErrInvalidVerificationToken
+	}
+
+	// The signature covers the address, so a link stops working once the email changes
+	expected := s.sign(userID, u.Email, expiresAt)
+	if !hmac.Equal(signature, expected) {
+		return nil, auth.ErrInvalidVerificationToken
+	}
+
+	// Verifying twice is harmless; the link was valid either way
+	if u.EmailVerified {
+		return u, nil
+	}
+
+	now := time.Now()
+	u.EmailVerified = true
+	u.EmailVerifiedAt = &now
+	u.UpdatedAt = now
+
+	if err := s.userRepo.Update(ctx, u); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return u, nil
+}
+
+// generateToken builds a verification token of the form base64(userID|expiry).base64(hmac)
+func (s *EmailVerificationService) generateToken(userID uuid.UUID, email string, expiresAt time.Time) string {
+	payload := make([]byte, 0, 24)
+	payload = append(payload, userID[:]...)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(expiresAt.Unix()))
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(userID, email, expiresAt))
+}
+
+// parseToken decodes a verification token without checking its signature
+func (s *EmailVerificationService) parseToken(token string) (uuid.UUID, time.Time, []byte, error) {
+	payloadPart, signaturePart, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, time.Time{}, nil, auth.ErrInvalidVerificationToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(payloadPart)
+	if err != nil || len(payload) != 24 {
+		return uuid.Nil, time.Time{}, nil, auth.ErrInvalidVerificationToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(signaturePart)
+	if err != nil {
+		return uuid.Nil, time.Time{}, nil, auth.ErrInvalidVerificationToken
+	}
+
+	userID, err := uuid.FromBytes(payload[:16])
+	if err != nil {
+		return uuid.Nil, time.Time{}, nil, auth.ErrInvalidVerificationToken
+	}
+
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[16:])), 0)
+
+	return userID, expiresAt, signature, nil
+}
+
+// sign computes the token signature over the user, address and expiry
+func (s *EmailVerificationService) sign(userID uuid.UUID, email string, expiresAt time.Time) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("email-verification\x00"))
+	mac.Write(userID[:])
+	mac.Write([]byte(strings.ToLower(email)))
+	_ = binary.Write(mac, binary.BigEndian, expiresAt.Unix())
+	return mac.Sum(nil)
+}
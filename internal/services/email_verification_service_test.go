@@ -0,0 +1,221 @@
+package services_test
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mail"
+	"github.com/victoralfred/um_sys/internal/domain/ratelimit"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// MockRateLimiter is a mock implementation of ratelimit.RateLimiter for testing
+type MockRateLimiter struct {
+	mock.Mock
+}
+
+func (m *MockRateLimiter) Check(ctx context.Context, key string, limit int, window time.Duration) (*ratelimit.RateLimitResult, error) {
+	args := m.Called(ctx, key, limit, window)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ratelimit.RateLimitResult), args.Error(1)
+}
+
+func (m *MockRateLimiter) Reset(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockRateLimiter) GetStatus(ctx context.Context, key string, limit int, window time.Duration) (*ratelimit.RateLimitResult, error) {
+	args := m.Called(ctx, key, limit, window)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ratelimit.RateLimitResult), args.Error(1)
+}
+
+var verifyLinkPattern = regexp.MustCompile(`https://app\.example\.com/verify\?token=(\S+)`)
+
+func newVerificationService(userRepo *MockUserRepository, sender *MockMailSender, limiter ratelimit.RateLimiter, expiry time.Duration) *services.EmailVerificationService {
+	config := services.DefaultEmailVerificationConfig()
+	config.VerifyURL = "https://app.example.com/verify"
+	config.TokenExpiry = expiry
+	config.Policy = auth.EmailVerificationPolicy{
+		RequireForLogin:       true,
+		RestrictedPermissions: []string{"billing:write"},
+	}
+	return services.NewEmailVerificationService(userRepo, sender, limiter, "verification-secret", config)
+}
+
+// sendVerificationToken sends a verification email and returns the token it contains
+func sendVerificationToken(t *testing.T, ctx context.Context, service *services.EmailVerificationService, sender *MockMailSender, u *user.User) string {
+	t.Helper()
+
+	var sent *mail.Message
+	sender.On("Send", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		sent = args.Get(1).(*mail.Message)
+	}).Return(nil).Once()
+
+	require.NoError(t, service.SendVerification(ctx, u))
+	require.NotNil(t, sent)
+	assert.Equal(t, []string{u.Email}, sent.To)
+
+	match := verifyLinkPattern.FindStringSubmatch(sent.TextBody)
+	require.Len(t, match, 2)
+
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+func TestEmailVerificationService_VerifyEmail(t *testing.T) {
+	ctx := context.Background()
+
+	newUser := func() *user.User {
+		return &user.User{
+			ID:       uuid.New(),
+			Email:    "test@example.com",
+			Username: "testuser",
+			Status:   user.StatusActive,
+		}
+	}
+
+	t.Run("valid link verifies the email", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		sender := new(MockMailSender)
+		service := newVerificationService(userRepo, sender, nil, time.Hour)
+		u := newUser()
+
+		token := sendVerificationToken(t, ctx, service, sender, u)
+		userRepo.On("GetByID", ctx, u.ID).Return(u, nil)
+		userRepo.On("Update", ctx, u).Return(nil).Once()
+
+		verified, err := service.VerifyEmail(ctx, token)
+		require.NoError(t, err)
+		assert.True(t, verified.EmailVerified)
+		assert.NotNil(t, verified.EmailVerifiedAt)
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("expired link is rejected", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		sender := new(MockMailSender)
+		service := newVerificationService(userRepo, sender, nil, -time.Minute)
+		u := newUser()
+
+		token := sendVerificationToken(t, ctx, service, sender, u)
+
+		_, err := service.VerifyEmail(ctx, token)
+		assert.ErrorIs(t, err, auth.ErrInvalidVerificationToken)
+	})
+
+	t.Run("forged signature is rejected", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		sender := new(MockMailSender)
+		service := newVerificationService(userRepo, sender, nil, time.Hour)
+		u := newUser()
+
+		token := sendVerificationToken(t, ctx, service, sender, u)
+		userRepo.On("GetByID", ctx, u.ID).Return(u, nil)
+
+		other := services.NewEmailVerificationService(userRepo, sender, nil, "another-secret", services.DefaultEmailVerificationConfig())
+		_, err := other.VerifyEmail(ctx, token)
+		assert.ErrorIs(t, err, auth.ErrInvalidVerificationToken)
+
+		_, err = service.VerifyEmail(ctx, "garbage")
+		assert.ErrorIs(t, err, auth.ErrInvalidVerificationToken)
+	})
+
+	t.Run("link stops working after the email changes", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		sender := new(MockMailSender)
+		service := newVerificationService(userRepo, sender, nil, time.Hour)
+		u := newUser()
+
+		token := sendVerificationToken(t, ctx, service, sender, u)
+		u.Email = "changed@example.com"
+		userRepo.On("GetByID", ctx, u.ID).Return(u, nil)
+
+		_, err := service.VerifyEmail(ctx, token)
+		assert.ErrorIs(t, err, auth.ErrInvalidVerificationToken)
+	})
+}
+
+func TestEmailVerificationService_ResendVerification(t *testing.T) {
+	ctx := context.Background()
+
+	u := &user.User{
+		ID:       uuid.New(),
+		Email:    "test@example.com",
+		Username: "testuser",
+		Status:   user.StatusActive,
+	}
+
+	t.Run("sends when under the limit", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		sender := new(MockMailSender)
+		limiter := new(MockRateLimiter)
+		service := newVerificationService(userRepo, sender, limiter, time.Hour)
+
+		userRepo.On("GetByID", ctx, u.ID).Return(u, nil)
+		limiter.On("Check", ctx, "email_verification:"+u.ID.String(), 3, time.Hour).
+			Return(&ratelimit.RateLimitResult{Allowed: true, Limit: 3, Remaining: 2}, nil)
+		sender.On("Send", ctx, mock.Anything).Return(nil).Once()
+
+		result, err := service.ResendVerification(ctx, u.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Remaining)
+		sender.AssertExpectations(t)
+	})
+
+	t.Run("throttled when over the limit", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		sender := new(MockMailSender)
+		limiter := new(MockRateLimiter)
+		service := newVerificationService(userRepo, sender, limiter, time.Hour)
+
+		userRepo.On("GetByID", ctx, u.ID).Return(u, nil)
+		limiter.On("Check", ctx, "email_verification:"+u.ID.String(), 3, time.Hour).
+			Return(&ratelimit.RateLimitResult{Allowed: false, Limit: 3, RetryAfter: time.Minute}, nil)
+
+		result, err := service.ResendVerification(ctx, u.ID)
+		assert.ErrorIs(t, err, auth.ErrVerificationThrottled)
+		assert.Equal(t, time.Minute, result.RetryAfter)
+		sender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+
+	t.Run("already verified", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		service := newVerificationService(userRepo, new(MockMailSender), new(MockRateLimiter), time.Hour)
+
+		verified := *u
+		verified.EmailVerified = true
+		userRepo.On("GetByID", ctx, u.ID).Return(&verified, nil)
+
+		_, err := service.ResendVerification(ctx, u.ID)
+		assert.ErrorIs(t, err, auth.ErrEmailAlreadyVerified)
+	})
+}
+
+func TestEmailVerificationService_Policy(t *testing.T) {
+	service := newVerificationService(new(MockUserRepository), new(MockMailSender), nil, time.Hour)
+
+	assert.ErrorIs(t, service.CheckLogin(&user.User{EmailVerified: false}), auth.ErrEmailNotVerified)
+	assert.NoError(t, service.CheckLogin(&user.User{EmailVerified: true}))
+
+	policy := service.Policy()
+	assert.False(t, policy.Allows(false, "billing:write"))
+	assert.True(t, policy.Allows(false, "billing:read"))
+	assert.True(t, policy.Allows(true, "billing:write"))
+}
@@ -0,0 +1,475 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/federation"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+)
+
+const (
+	// maxUsernameAttempts bounds the search for a free username when creating federated accounts
+	maxUsernameAttempts = 10
+)
+
+// FederationConfig holds settings for federated login
+type FederationConfig struct {
+	// StateExpiry is how long a user has to complete sign-in at the upstream provider
+	StateExpiry time.Duration
+}
+
+// DefaultFederationConfig returns the default federated login settings
+func DefaultFederationConfig() FederationConfig {
+	return FederationConfig{
+		StateExpiry: 10 * time.Minute,
+	}
+}
+
+type registeredProvider struct {
+	config federation.Provider
+	idp    federation.IdentityProvider
+}
+
+// FederationService signs users in through upstream OpenID Connect providers
+// and manages the external identities linked to their accounts
+type FederationService struct {
+	userRepo          user.Repository
+	identityRepo      federation.IdentityRepository
+	tokenService      *TokenService
+	emailVerification *EmailVerificationService
+	secret            []byte
+	config            FederationConfig
+	providers         map[string]*registeredProvider
+	providerOrder     []string
+}
+
+// NewFederationService creates a new federation service.
+// The secret signs the authorization state carried through the upstream round trip.
+func NewFederationService(
+	userRepo user.Repository,
+	identityRepo federation.IdentityRepository,
+	tokenService *TokenService,
+	secret string,
+	config FederationConfig,
+) *FederationService {
+	return &FederationService{
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		tokenService: tokenService,
+		secret:       []byte(secret),
+		config:       config,
+		providers:    make(map[string]*registeredProvider),
+	}
+}
+
+// SetEmailVerificationService applies the email verification login policy to federated sign-ins
+func (s *FederationService) SetEmailVerificationService(emailVerification *EmailVerificationService) {
+	s.emailVerification = emailVerification
+}
+
+// RegisterProvider makes an upstream provider available for sign-in
+func (s *FederationService) RegisterProvider(config federation.Provider, idp federation.IdentityProvider) {
+	if _, exists := s.providers[config.ID]; !exists {
+		s.providerOrder = append(s.providerOrder, config.ID)
+	}
+	s.providers[config.ID] = &registeredProvider{config: config, idp: idp}
+}
+
+// Providers returns the registered providers in registration order
+func (s *FederationService) Providers() []federation.Provider {
+	providers := make([]federation.Provider, 0, len(s.providerOrder))
+	for _, id := range s.providerOrder {
+		providers = append(providers, s.providers[id].config)
+	}
+	return providers
+}
+
+// BeginAuthorization starts a sign-in at an upstream provider. It returns the
+// provider URL to redirect the browser to and a signed state token that must be
+// kept by the browser (in a cookie) and presented again at the callback.
+// When linkUserID is set, the flow links the identity to that user instead of signing in.
+func (s *FederationService) BeginAuthorization(providerID string, linkUserID *uuid.UUID) (string, string, error) {
+	provider, ok := s.providers[providerID]
+	if !ok {
+		return "", "", federation.ErrProviderNotFound
+	}
+
+	state, err := generateOAuthSecret()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := generateOAuthSecret()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := generateOAuthSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	stateToken, err := s.signState(&federation.AuthorizationState{
+		ProviderID:   providerID,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(s.config.StateExpiry),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return provider.idp.AuthCodeURL(state, nonce, challenge), stateToken, nil
+}
+
+// CompleteAuthorization finishes a sign-in at the provider callback. The state
+// token comes from the browser's cookie, state and code from the callback query.
+func (s *FederationService) CompleteAuthorization(ctx context.Context, providerID, stateToken, state, code string) (*federation.Result, error) {
+	provider, ok := s.providers[providerID]
+	if !ok {
+		return nil, federation.ErrProviderNotFound
+	}
+
+	authState, err := s.parseState(stateToken)
+	if err != nil {
+		return nil, err
+	}
+	// The state parameter ties the callback to the browser that started the flow
+	if authState.ProviderID != providerID || subtle.ConstantTimeCompare([]byte(authState.State), []byte(state)) != 1 {
+		return nil, federation.ErrInvalidState
+	}
+
+	claims, err := provider.idp.Exchange(ctx, code, authState.CodeVerifier, authState.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := s.identityRepo.GetByProviderSubject(ctx, providerID, claims.Subject)
+	if err != nil && !errors.Is(err, federation.ErrIdentityNotFound) {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	if authState.LinkUserID != nil {
+		return s.linkIdentity(ctx, *authState.LinkUserID, providerID, claims, identity)
+	}
+
+	return s.signIn(ctx, &provider.config, claims, identity)
+}
+
+// linkIdentity attaches an external identity to a signed-in user
+func (s *FederationService) linkIdentity(ctx context.Context, userID uuid.UUID, providerID string, claims *federation.ExternalClaims, identity *federation.Identity) (*federation.Result, error) {
+	if identity != nil && identity.UserID != userID {
+		return nil, federation.ErrIdentityAlreadyLinked
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Linking the same identity twice is harmless
+	if identity != nil {
+		return &federation.Result{User: u, Identity: identity}, nil
+	}
+
+	identity, err = s.createIdentity(ctx, userID, providerID, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &federation.Result{User: u, Identity: identity, Linked: true}, nil
+}
+
+// signInExternal signs in the account linked to an external identity, resolving the
+// account on first sign-in. Other federation protocols use it to link and provision
+// accounts the same way OpenID Connect providers do.
+func (s *FederationService) signInExternal(ctx context.Context, provider *federation.Provider, claims *federation.ExternalClaims) (*federation.Result, error) {
+	identity, err := s.identityRepo.GetByProviderSubject(ctx, provider.ID, claims.Subject)
+	if err != nil && !errors.Is(err, federation.ErrIdentityNotFound) {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	return s.signIn(ctx, provider, claims, identity)
+}
+
+// signIn resolves the account for an external identity and issues tokens for it
+func (s *FederationService) signIn(ctx context.Context, provider *federation.Provider, claims *federation.ExternalClaims, identity *federation.Identity) (*federation.Result, error) {
+	result := &federation.Result{Identity: identity}
+
+	if identity != nil {
+		u, err := s.userRepo.GetByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		result.User = u
+	} else {
+		u, created, err := s.resolveAccount(ctx, provider, claims)
+		if err != nil {
+			return nil, err
+		}
+
+		identity, err = s.createIdentity(ctx, u.ID, provider.ID, claims)
+		if err != nil {
+			return nil, err
+		}
+
+		result.User = u
+		result.Identity = identity
+		result.Created = created
+		result.Linked = true
+	}
+
+	u := result.User
+	if u.IsLocked() {
+		return nil, auth.ErrAccountLocked
+	}
+	if u.Status != user.StatusActive {
+		return nil, auth.ErrAccountInactive
+	}
+	if s.emailVerification != nil {
+		if err := s.emailVerification.CheckLogin(u); err != nil {
+			return nil, err
+		}
+	}
+
+	tokenPair, err := s.tokenService.GenerateTokenPair(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+	result.TokenPair = tokenPair
+
+	now := time.Now()
+	_ = s.userRepo.UpdateLastLogin(ctx, u.ID, now)
+	_ = s.identityRepo.UpdateLastLogin(ctx, result.Identity.ID, now)
+	result.Identity.LastLoginAt = &now
+
+	return result, nil
+}
+
+// resolveAccount finds the account a first-time identity belongs to, creating one if the provider allows it
+func (s *FederationService) resolveAccount(ctx context.Context, provider *federation.Provider, claims *federation.ExternalClaims) (*user.User, bool, error) {
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if email == "" {
+		if !provider.AllowSignup {
+			return nil, false, federation.ErrSignupDisabled
+		}
+		return nil, false, federation.ErrEmailMissing
+	}
+
+	existing, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, user.ErrUserNotFound) {
+		return nil, false, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if existing != nil {
+		if !provider.LinkByEmail {
+			return nil, false, federation.ErrAccountExists
+		}
+		if !claims.EmailVerified {
+			return nil, false, federation.ErrEmailNotVerified
+		}
+		// An unverified local account may have been registered by someone who does
+		// not own the address; linking to it would hand them the real owner's sign-in
+		if !existing.EmailVerified {
+			return nil, false, federation.ErrAccountExists
+		}
+		return existing, false, nil
+	}
+
+	if !provider.AllowSignup {
+		return nil, false, federation.ErrSignupDisabled
+	}
+
+	u, err := s.createUser(ctx, email, claims)
+	if err != nil {
+		return nil, false, err
+	}
+	return u, true, nil
+}
+
+// createUser creates an account for a federated identity. The account gets a
+// random password nobody knows; the user can set one through password reset.
+func (s *FederationService) createUser(ctx context.Context, email string, claims *federation.ExternalClaims) (*user.User, error) {
+	username, err := availableUsername(ctx, s.userRepo, claims.PreferredUsername, email)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := randomPasswordHash()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := user.NewUser(email, username, passwordHash)
+	if err != nil {
+		return nil, err
+	}
+	u.FirstName = claims.GivenName
+	u.LastName = claims.FamilyName
+	u.Status = user.StatusActive
+	if claims.EmailVerified {
+		now := time.Now()
+		u.EmailVerified = true
+		u.EmailVerifiedAt = &now
+	}
+
+	if err := s.userRepo.Create(ctx, u); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return u, nil
+}
+
+// availableUsername derives a free username from a preferred username or the email
+func availableUsername(ctx context.Context, userRepo user.Repository, preferred, email string) (string, error) {
+	base := sanitizeUsername(preferred)
+	if base == "" {
+		local, _, _ := strings.Cut(email, "@")
+		base = sanitizeUsername(local)
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	candidate := base
+	for i := 0; i < maxUsernameAttempts; i++ {
+		exists, err := userRepo.ExistsByUsername(ctx, candidate)
+		if err != nil {
+			return "", fmt.Errorf("failed to check username: %w", err)
+		}
+		if !exists {
+			return candidate, nil
+		}
+
+		suffix, err := generateOAuthSecret()
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s_%s", base, strings.ToLower(suffix[:6]))
+	}
+
+	return "", auth.ErrUsernameAlreadyExists
+}
+
+// randomPasswordHash hashes a random password nobody knows, for accounts created
+// on behalf of an identity provider
+func randomPasswordHash() (string, error) {
+	password, err := generateOAuthSecret()
+	if err != nil {
+		return "", err
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(passwordHash), nil
+}
+
+func (s *FederationService) createIdentity(ctx context.Context, userID uuid.UUID, providerID string, claims *federation.ExternalClaims) (*federation.Identity, error) {
+	identity := &federation.Identity{
+		ID:         uuid.New(),
+		UserID:     userID,
+		ProviderID: providerID,
+		Subject:    claims.Subject,
+		Email:      strings.ToLower(claims.Email),
+		LinkedAt:   time.Now(),
+	}
+
+	if err := s.identityRepo.Create(ctx, identity); err != nil {
+		if errors.Is(err, federation.ErrIdentityAlreadyLinked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return identity, nil
+}
+
+// ListIdentities returns the external identities linked to a user
+func (s *FederationService) ListIdentities(ctx context.Context, userID uuid.UUID) ([]*federation.Identity, error) {
+	return s.identityRepo.ListByUser(ctx, userID)
+}
+
+// UnlinkIdentity removes an external identity from a user
+func (s *FederationService) UnlinkIdentity(ctx context.Context, userID, identityID uuid.UUID) error {
+	return s.identityRepo.Delete(ctx, userID, identityID)
+}
+
+// signState encodes the authorization state as base64(json).base64(hmac)
+func (s *FederationService) signState(state *federation.AuthorizationState) (string, error) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.signStatePayload(payload)), nil
+}
+
+// parseState verifies and decodes a state token produced by signState
+func (s *FederationService) parseState(token string) (*federation.AuthorizationState, error) {
+	payloadPart, signaturePart, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, federation.ErrInvalidState
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(payloadPart)
+	if err != nil {
+		return nil, federation.ErrInvalidState
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(signaturePart)
+	if err != nil {
+		return nil, federation.ErrInvalidState
+	}
+	if !hmac.Equal(signature, s.signStatePayload(payload)) {
+		return nil, federation.ErrInvalidState
+	}
+
+	var state federation.AuthorizationState
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return nil, federation.ErrInvalidState
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return nil, federation.ErrInvalidState
+	}
+
+	return &state, nil
+}
+
+func (s *FederationService) signStatePayload(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("federation-state\x00"))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// sanitizeUsername keeps the characters allowed in usernames and truncates to the column limit
+func sanitizeUsername(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			b.WriteRune(r)
+		}
+		if b.Len() >= 40 {
+			break
+		}
+	}
+	return b.String()
+}
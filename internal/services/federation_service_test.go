@@ -0,0 +1,295 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/federation"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/infrastructure/oidc"
+	"github.com/victoralfred/um_sys/internal/infrastructure/oidc/oidctest"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+type federationFixture struct {
+	service      *services.FederationService
+	tokenService *services.TokenService
+	users        *InMemoryUserRepository
+	idp          *oidctest.Server
+}
+
+// newFederationFixture registers two providers backed by the same stub IdP:
+// "stub" allows signup and linking by email, "strict" allows neither
+func newFederationFixture(t *testing.T) *federationFixture {
+	t.Helper()
+
+	idp, err := oidctest.NewServer("um-sys", "stub-secret")
+	require.NoError(t, err)
+	t.Cleanup(idp.Close)
+
+	users := NewInMemoryUserRepository()
+	tokenService := services.NewTokenService("test-secret-key-min-32-characters!!", "test-issuer", 15*time.Minute, 7*24*time.Hour, users)
+	tokenService.SetTokenStore(NewInMemoryTokenStore())
+
+	service := services.NewFederationService(
+		users,
+		NewInMemoryIdentityRepository(),
+		tokenService,
+		"state-secret",
+		services.DefaultFederationConfig(),
+	)
+
+	for _, config := range []federation.Provider{
+		{ID: "stub", Name: "Stub", AllowSignup: true, LinkByEmail: true},
+		{ID: "strict", Name: "Strict"},
+	} {
+		config.Issuer = idp.Issuer()
+		config.ClientID = idp.ClientID
+		config.ClientSecret = idp.ClientSecret
+		config.RedirectURL = "http://localhost:8080/v1/auth/federated/" + config.ID + "/callback"
+
+		provider, err := oidc.NewProvider(context.Background(), config, nil)
+		require.NoError(t, err)
+		service.RegisterProvider(config, provider)
+	}
+
+	return &federationFixture{
+		service:      service,
+		tokenService: tokenService,
+		users:        users,
+		idp:          idp,
+	}
+}
+
+// authorize runs a full round trip through the stub IdP as the browser would
+func (f *federationFixture) authorize(t *testing.T, providerID string, linkUserID *uuid.UUID) (*federation.Result, error) {
+	t.Helper()
+
+	authURL, stateToken, err := f.service.BeginAuthorization(providerID, linkUserID)
+	require.NoError(t, err)
+
+	callback, err := f.idp.Authorize(authURL)
+	require.NoError(t, err)
+
+	return f.service.CompleteAuthorization(
+		context.Background(),
+		providerID,
+		stateToken,
+		callback.Query().Get("state"),
+		callback.Query().Get("code"),
+	)
+}
+
+func (f *federationFixture) createUser(t *testing.T, email string, verified bool) *user.User {
+	t.Helper()
+
+	u, err := user.NewUser(email, "local_"+uuid.NewString()[:8], "hash")
+	require.NoError(t, err)
+	u.Status = user.StatusActive
+	u.EmailVerified = verified
+	require.NoError(t, f.users.Create(context.Background(), u))
+	return u
+}
+
+func TestFederationService_SignIn(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping federation integration tests in short mode")
+	}
+	ctx := context.Background()
+
+	t.Run("creates an account on first sign-in and reuses it afterwards", func(t *testing.T) {
+		f := newFederationFixture(t)
+		f.idp.SetUser(federation.ExternalClaims{
+			Subject:           "sub-new",
+			Email:             "New.User@Example.com",
+			EmailVerified:     true,
+			PreferredUsername: "new.user",
+		})
+
+		result, err := f.authorize(t, "stub", nil)
+		require.NoError(t, err)
+		assert.True(t, result.Created)
+		assert.True(t, result.Linked)
+		assert.Equal(t, "new.user@example.com", result.User.Email)
+		assert.Equal(t, "new.user", result.User.Username)
+		assert.True(t, result.User.EmailVerified)
+		require.NotNil(t, result.TokenPair)
+
+		claims, err := f.tokenService.ValidateToken(ctx, result.TokenPair.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, result.User.ID, claims.UserID)
+
+		again, err := f.authorize(t, "stub", nil)
+		require.NoError(t, err)
+		assert.False(t, again.Created)
+		assert.False(t, again.Linked)
+		assert.Equal(t, result.User.ID, again.User.ID)
+		assert.NotNil(t, again.Identity.LastLoginAt)
+	})
+
+	t.Run("links to an existing account by verified email", func(t *testing.T) {
+		f := newFederationFixture(t)
+		existing := f.createUser(t, "jane@example.com", true)
+		f.idp.SetUser(federation.ExternalClaims{Subject: "sub-jane", Email: "jane@example.com", EmailVerified: true})
+
+		result, err := f.authorize(t, "stub", nil)
+		require.NoError(t, err)
+		assert.False(t, result.Created)
+		assert.True(t, result.Linked)
+		assert.Equal(t, existing.ID, result.User.ID)
+	})
+
+	t.Run("refuses to link when the provider has not verified the email", func(t *testing.T) {
+		f := newFederationFixture(t)
+		f.createUser(t, "jane@example.com", true)
+		f.idp.SetUser(federation.ExternalClaims{Subject: "sub-jane", Email: "jane@example.com"})
+
+		_, err := f.authorize(t, "stub", nil)
+		assert.ErrorIs(t, err, federation.ErrEmailNotVerified)
+	})
+
+	t.Run("refuses to link to an unverified local account", func(t *testing.T) {
+		f := newFederationFixture(t)
+		f.createUser(t, "jane@example.com", false)
+		f.idp.SetUser(federation.ExternalClaims{Subject: "sub-jane", Email: "jane@example.com", EmailVerified: true})
+
+		_, err := f.authorize(t, "stub", nil)
+		assert.ErrorIs(t, err, federation.ErrAccountExists)
+	})
+
+	t.Run("honours providers without signup or email linking", func(t *testing.T) {
+		f := newFederationFixture(t)
+		f.idp.SetUser(federation.ExternalClaims{Subject: "sub-unknown", Email: "unknown@example.com", EmailVerified: true})
+
+		_, err := f.authorize(t, "strict", nil)
+		assert.ErrorIs(t, err, federation.ErrSignupDisabled)
+
+		f.createUser(t, "unknown@example.com", true)
+		_, err = f.authorize(t, "strict", nil)
+		assert.ErrorIs(t, err, federation.ErrAccountExists)
+	})
+
+	t.Run("rejects inactive accounts", func(t *testing.T) {
+		f := newFederationFixture(t)
+		existing := f.createUser(t, "jane@example.com", true)
+		existing.Status = user.StatusSuspended
+		require.NoError(t, f.users.Update(ctx, existing))
+		f.idp.SetUser(federation.ExternalClaims{Subject: "sub-jane", Email: "jane@example.com", EmailVerified: true})
+
+		_, err := f.authorize(t, "stub", nil)
+		assert.ErrorIs(t, err, auth.ErrAccountInactive)
+	})
+}
+
+func TestFederationService_LinkIdentities(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping federation integration tests in short mode")
+	}
+	ctx := context.Background()
+
+	t.Run("links, lists and unlinks identities", func(t *testing.T) {
+		f := newFederationFixture(t)
+		u := f.createUser(t, "jane@example.com", true)
+
+		// The upstream email differs from the account's; linking does not depend on it
+		f.idp.SetUser(federation.ExternalClaims{Subject: "sub-a", Email: "jane@work.example"})
+		result, err := f.authorize(t, "stub", &u.ID)
+		require.NoError(t, err)
+		assert.True(t, result.Linked)
+		assert.Nil(t, result.TokenPair)
+
+		f.idp.SetUser(federation.ExternalClaims{Subject: "sub-b"})
+		_, err = f.authorize(t, "strict", &u.ID)
+		require.NoError(t, err)
+
+		identities, err := f.service.ListIdentities(ctx, u.ID)
+		require.NoError(t, err)
+		assert.Len(t, identities, 2)
+
+		// A linked identity signs in to the account even where signup is disabled
+		signIn, err := f.authorize(t, "strict", nil)
+		require.NoError(t, err)
+		assert.Equal(t, u.ID, signIn.User.ID)
+
+		require.NoError(t, f.service.UnlinkIdentity(ctx, u.ID, signIn.Identity.ID))
+		_, err = f.authorize(t, "strict", nil)
+		assert.ErrorIs(t, err, federation.ErrSignupDisabled)
+
+		identities, err = f.service.ListIdentities(ctx, u.ID)
+		require.NoError(t, err)
+		assert.Len(t, identities, 1)
+	})
+
+	t.Run("rejects an identity linked to another account", func(t *testing.T) {
+		f := newFederationFixture(t)
+		owner := f.createUser(t, "owner@example.com", true)
+		other := f.createUser(t, "other@example.com", true)
+
+		f.idp.SetUser(federation.ExternalClaims{Subject: "sub-owned"})
+		_, err := f.authorize(t, "stub", &owner.ID)
+		require.NoError(t, err)
+
+		_, err = f.authorize(t, "stub", &other.ID)
+		assert.ErrorIs(t, err, federation.ErrIdentityAlreadyLinked)
+	})
+
+	t.Run("cannot unlink another user's identity", func(t *testing.T) {
+		f := newFederationFixture(t)
+		owner := f.createUser(t, "owner@example.com", true)
+
+		f.idp.SetUser(federation.ExternalClaims{Subject: "sub-owned"})
+		result, err := f.authorize(t, "stub", &owner.ID)
+		require.NoError(t, err)
+
+		err = f.service.UnlinkIdentity(ctx, uuid.New(), result.Identity.ID)
+		assert.ErrorIs(t, err, federation.ErrIdentityNotFound)
+	})
+}
+
+func TestFederationService_State(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping federation integration tests in short mode")
+	}
+	ctx := context.Background()
+
+	f := newFederationFixture(t)
+	f.idp.SetUser(federation.ExternalClaims{Subject: "sub-state", Email: "state@example.com", EmailVerified: true})
+
+	authURL, stateToken, err := f.service.BeginAuthorization("stub", nil)
+	require.NoError(t, err)
+	callback, err := f.idp.Authorize(authURL)
+	require.NoError(t, err)
+	state, code := callback.Query().Get("state"), callback.Query().Get("code")
+
+	t.Run("rejects a tampered state token", func(t *testing.T) {
+		_, err := f.service.CompleteAuthorization(ctx, "stub", stateToken+"x", state, code)
+		assert.ErrorIs(t, err, federation.ErrInvalidState)
+	})
+
+	t.Run("rejects a state parameter from another flow", func(t *testing.T) {
+		_, err := f.service.CompleteAuthorization(ctx, "stub", stateToken, "other-state", code)
+		assert.ErrorIs(t, err, federation.ErrInvalidState)
+	})
+
+	t.Run("rejects a callback for a different provider", func(t *testing.T) {
+		_, err := f.service.CompleteAuthorization(ctx, "strict", stateToken, state, code)
+		assert.ErrorIs(t, err, federation.ErrInvalidState)
+	})
+
+	t.Run("rejects an unknown provider", func(t *testing.T) {
+		_, _, err := f.service.BeginAuthorization("missing", nil)
+		assert.ErrorIs(t, err, federation.ErrProviderNotFound)
+	})
+
+	t.Run("accepts the untampered callback", func(t *testing.T) {
+		result, err := f.service.CompleteAuthorization(ctx, "stub", stateToken, state, code)
+		require.NoError(t, err)
+		assert.True(t, result.Created)
+	})
+}
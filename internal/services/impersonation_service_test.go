@@ -0,0 +1,135 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+func TestImpersonationService(t *testing.T) {
+	ctx := context.Background()
+	adminID := uuid.New()
+
+	newUser := func(name string, status user.Status) *user.User {
+		return &user.User{
+			ID:       uuid.New(),
+			Email:    name + "@example.com",
+			Username: name,
+			Status:   status,
+		}
+	}
+	suspended := newUser("suspended", user.StatusSuspended)
+
+	setup := func(t *testing.T, auditErr error) (*services.ImpersonationService, *services.TokenService, *user.User, *MockAuditService) {
+		t.Helper()
+
+		target := newUser("customer", user.StatusActive)
+		userRepo := NewInMemoryUserRepository()
+		require.NoError(t, userRepo.Create(ctx, target))
+		require.NoError(t, userRepo.Create(ctx, suspended))
+
+		tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		tokenService.SetTokenStore(NewInMemoryTokenStore())
+
+		auditService := new(MockAuditService)
+		if auditErr != nil {
+			auditService.On("Log", mock.Anything, mock.Anything).Return(nil, auditErr)
+		} else {
+			auditService.On("Log", mock.Anything, mock.Anything).Return(&audit.LogEntry{}, nil)
+		}
+
+		service := services.NewImpersonationService(userRepo, tokenService, services.DefaultImpersonationConfig())
+		service.SetAuditService(auditService)
+		return service, tokenService, target, auditService
+	}
+
+	t.Run("issues a short-lived token naming the admin as actor", func(t *testing.T) {
+		service, tokenService, target, auditService := setup(t, nil)
+
+		tokenPair, u, err := service.Start(ctx, &auth.ImpersonationRequest{
+			ActorID: adminID,
+			UserID:  target.ID,
+			Reason:  "Ticket #4521",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, target.ID, u.ID)
+		assert.Empty(t, tokenPair.RefreshToken)
+		assert.Equal(t, 900, tokenPair.ExpiresIn)
+
+		claims, err := tokenService.ValidateToken(ctx, tokenPair.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, target.ID, claims.UserID)
+		assert.Equal(t, target.ID.String(), claims.Subject)
+		require.NotNil(t, claims.ActorID)
+		assert.Equal(t, adminID, *claims.ActorID)
+
+		logged := auditService.Calls[0].Arguments.Get(1).(*audit.CreateLogRequest)
+		assert.Equal(t, audit.EventTypeImpersonationStarted, logged.EventType)
+		assert.Equal(t, adminID, *logged.ActorID)
+		assert.Equal(t, target.ID, *logged.UserID)
+		assert.Equal(t, "Ticket #4521", logged.Metadata["reason"])
+	})
+
+	t.Run("ordinary tokens name no actor", func(t *testing.T) {
+		_, tokenService, target, _ := setup(t, nil)
+
+		tokenPair, err := tokenService.GenerateTokenPair(ctx, target)
+		require.NoError(t, err)
+
+		claims, err := tokenService.ValidateToken(ctx, tokenPair.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		assert.Nil(t, claims.ActorID)
+	})
+
+	t.Run("refuses to impersonate without an audit entry", func(t *testing.T) {
+		service, _, target, _ := setup(t, errors.New("audit log unavailable"))
+
+		_, _, err := service.Start(ctx, &auth.ImpersonationRequest{ActorID: adminID, UserID: target.ID, Reason: "Ticket #4521"})
+		assert.Error(t, err)
+	})
+
+	t.Run("refuses self, unknown and inactive users", func(t *testing.T) {
+		service, _, _, _ := setup(t, nil)
+
+		_, _, err := service.Start(ctx, &auth.ImpersonationRequest{ActorID: adminID, UserID: adminID, Reason: "test"})
+		assert.ErrorIs(t, err, auth.ErrImpersonationNotAllowed)
+
+		_, _, err = service.Start(ctx, &auth.ImpersonationRequest{ActorID: adminID, UserID: uuid.New(), Reason: "test"})
+		assert.ErrorIs(t, err, user.ErrUserNotFound)
+
+		_, _, err = service.Start(ctx, &auth.ImpersonationRequest{ActorID: adminID, UserID: suspended.ID, Reason: "test"})
+		assert.ErrorIs(t, err, auth.ErrAccountInactive)
+	})
+
+	t.Run("ending impersonation revokes the token", func(t *testing.T) {
+		service, tokenService, target, auditService := setup(t, nil)
+
+		tokenPair, _, err := service.Start(ctx, &auth.ImpersonationRequest{ActorID: adminID, UserID: target.ID, Reason: "test"})
+		require.NoError(t, err)
+
+		require.NoError(t, service.End(ctx, tokenPair.AccessToken, "10.0.0.1", "Firefox"))
+
+		_, err = tokenService.ValidateToken(ctx, tokenPair.AccessToken, auth.AccessToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+
+		logged := auditService.Calls[1].Arguments.Get(1).(*audit.CreateLogRequest)
+		assert.Equal(t, audit.EventTypeImpersonationEnded, logged.EventType)
+		assert.Equal(t, adminID, *logged.ActorID)
+
+		// Only impersonation tokens can be ended
+		ownPair, err := tokenService.GenerateTokenPair(ctx, target)
+		require.NoError(t, err)
+		assert.ErrorIs(t, service.End(ctx, ownPair.AccessToken, "10.0.0.1", "Firefox"), auth.ErrNotImpersonating)
+	})
+}
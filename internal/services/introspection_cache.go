@@ -0,0 +1,66 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+)
+
+// maxIntrospectionCacheEntries bounds the memory held by the cache; once full,
+// new results are only cached after expired entries have been swept out
+const maxIntrospectionCacheEntries = 10000
+
+type cachedIntrospection struct {
+	claims    *auth.Claims
+	expiresAt time.Time
+}
+
+// introspectionCache keeps the claims of recently introspected tokens in memory,
+// keyed by the token hash, so gateways asking about the same token repeatedly
+// do not each pay for parsing and signature verification. Revocation is not
+// cached; it is always checked against the shared token store.
+type introspectionCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedIntrospection
+}
+
+func newIntrospectionCache() *introspectionCache {
+	return &introspectionCache{
+		entries: make(map[string]cachedIntrospection),
+	}
+}
+
+func (c *introspectionCache) get(key string) (*auth.Claims, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.claims, true
+}
+
+func (c *introspectionCache) set(key string, claims *auth.Claims, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxIntrospectionCacheEntries {
+		now := time.Now()
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxIntrospectionCacheEntries {
+			return
+		}
+	}
+
+	c.entries[key] = cachedIntrospection{claims: claims, expiresAt: expiresAt}
+}
@@ -0,0 +1,527 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mail"
+	"github.com/victoralfred/um_sys/internal/domain/organization"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+)
+
+// InvitationConfig holds settings for organization invitations
+type InvitationConfig struct {
+	// AcceptURL is the page that accepts invitation tokens; the token is appended as ?token=
+	AcceptURL string
+
+	// TokenExpiry is how long an invitation link stays valid
+	TokenExpiry time.Duration
+}
+
+// DefaultInvitationConfig returns the default invitation settings
+func DefaultInvitationConfig() InvitationConfig {
+	return InvitationConfig{
+		AcceptURL:   "http://localhost:8080/accept-invitation",
+		TokenExpiry: 7 * 24 * time.Hour,
+	}
+}
+
+// InvitationService invites people to organizations by email with a pre-assigned
+// role. Links are signed and expire; resending one replaces the previous link.
+type InvitationService struct {
+	invitations   organization.InvitationRepository
+	organizations *OrganizationService
+	userRepo      user.Repository
+	mailSender    mail.Sender
+	secret        []byte
+	config        InvitationConfig
+	authService   *AuthService
+	auditService  audit.AuditService
+}
+
+// NewInvitationService creates a new invitation service
+func NewInvitationService(
+	invitations organization.InvitationRepository,
+	organizations *OrganizationService,
+	userRepo user.Repository,
+	mailSender mail.Sender,
+	secret string,
+	config InvitationConfig,
+) *InvitationService {
+	return &InvitationService{
+		invitations:   invitations,
+		organizations: organizations,
+		userRepo:      userRepo,
+		mailSender:    mailSender,
+		secret:        []byte(secret),
+		config:        config,
+	}
+}
+
+// SetAuthService enables accepting invitations by creating an account
+func (s *InvitationService) SetAuthService(authService *AuthService) {
+	s.authService = authService
+}
+
+// SetAuditService sets the audit service used to record invitations
+func (s *InvitationService) SetAuditService(auditService audit.AuditService) {
+	s.auditService = auditService
+}
+
+// Invite emails an invitation to join an organization. Pending invitations hold
+// a seat until they are accepted, revoked or expire.
+func (s *InvitationService) Invite(ctx context.Context, orgID uuid.UUID, req *organization.InviteRequest, actorID uuid.UUID) (*organization.Invitation, error) {
+	if !req.Role.IsValid() {
+		return nil, organization.ErrInvalidRole
+	}
+
+	actor, err := s.organizations.authorize(ctx, orgID, actorID, organization.PermissionMembersManage)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Role.Includes(req.Role) {
+		return nil, organization.ErrInsufficientRole
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	if existing, err := s.userRepo.GetByEmail(ctx, email); err == nil {
+		if _, err := s.organizations.memberships.Get(ctx, orgID, existing.ID); err == nil {
+			return nil, organization.ErrAlreadyMember
+		} else if !errors.Is(err, organization.ErrNotMember) {
+			return nil, fmt.Errorf("failed to get organization membership: %w", err)
+		}
+	} else if !errors.Is(err, user.ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	now := time.Now()
+
+	// An expired invitation gives way to the new one
+	if pending, err := s.invitations.GetPendingByEmail(ctx, orgID, email); err == nil {
+		if pending.IsOpen(now) {
+			return nil, organization.ErrInvitationPending
+		}
+		pending.Status = organization.InvitationStatusExpired
+		pending.UpdatedAt = now
+		if err := s.invitations.Update(ctx, pending); err != nil {
+			return nil, fmt.Errorf("failed to update invitation: %w", err)
+		}
+	} else if !errors.Is(err, organization.ErrInvitationNotFound) {
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	seatLimit, err := s.organizations.seatLimit(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := &organization.Invitation{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		Email:          email,
+		Role:           req.Role,
+		Status:         organization.InvitationStatusPending,
+		InvitedBy:      actorID,
+		ExpiresAt:      now.Add(s.config.TokenExpiry).Truncate(time.Microsecond),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	if err := s.invitations.CreateWithinSeatLimit(ctx, invitation, seatLimit); err != nil {
+		if errors.Is(err, organization.ErrInvitationPending) || errors.Is(err, organization.ErrSeatLimitReached) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	// An invitation nobody was told about would hold a seat and block inviting
+	// the address again, so it is withdrawn if its email cannot be sent
+	if err := s.send(ctx, invitation); err != nil {
+		if deleteErr := s.invitations.Delete(ctx, invitation.ID); deleteErr != nil {
+			return nil, errors.Join(err, fmt.Errorf("failed to withdraw invitation: %w", deleteErr))
+		}
+		return nil, err
+	}
+
+	s.log(ctx, audit.EventTypeOrganizationInvitationSent, invitation, actorID, "Organization invitation sent", nil)
+
+	return invitation, nil
+}
+
+// List returns an organization's pending invitations, including expired ones
+// that may be resent
+func (s *InvitationService) List(ctx context.Context, orgID, actorID uuid.UUID) ([]*organization.Invitation, error) {
+	if _, err := s.organizations.authorize(ctx, orgID, actorID, organization.PermissionMembersManage); err != nil {
+		return nil, err
+	}
+	return s.invitations.ListPending(ctx, orgID)
+}
+
+// Resend emails a new link for a pending invitation and restarts its expiry.
+// Links sent earlier stop working.
+func (s *InvitationService) Resend(ctx context.Context, orgID, invitationID, actorID uuid.UUID) (*organization.Invitation, error) {
+	invitation, err := s.manageable(ctx, orgID, invitationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	// An expired invitation no longer holds a seat, so it needs one again
+	now := time.Now()
+	seatLimit := 0
+	if !invitation.IsOpen(now) {
+		if seatLimit, err = s.organizations.seatLimit(ctx, orgID); err != nil {
+			return nil, err
+		}
+	}
+
+	invitation.ExpiresAt = now.Add(s.config.TokenExpiry).Truncate(time.Microsecond)
+	invitation.UpdatedAt = now
+
+	if err := s.invitations.UpdateWithinSeatLimit(ctx, invitation, seatLimit); err != nil {
+		if errors.Is(err, organization.ErrSeatLimitReached) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update invitation: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeOrganizationInvitationResent, invitation, actorID, "Organization invitation resent", nil)
+
+	if err := s.send(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// Revoke cancels a pending invitation so its link can no longer be accepted
+func (s *InvitationService) Revoke(ctx context.Context, orgID, invitationID, actorID uuid.UUID) error {
+	invitation, err := s.manageable(ctx, orgID, invitationID, actorID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	invitation.Status = organization.InvitationStatusRevoked
+	invitation.RevokedAt = &now
+	invitation.UpdatedAt = now
+
+	if err := s.invitations.Update(ctx, invitation); err != nil {
+		return fmt.Errorf("failed to update invitation: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeOrganizationInvitationRevoked, invitation, actorID, "Organization invitation revoked", nil)
+
+	return nil
+}
+
+// Accept adds the signed-in user to the organization of an invitation sent to
+// their email address
+func (s *InvitationService) Accept(ctx context.Context, token string, userID uuid.UUID) (*organization.Membership, error) {
+	invitation, err := s.redeem(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if u.Status != user.StatusActive {
+		return nil, auth.ErrAccountInactive
+	}
+	if !strings.EqualFold(u.Email, invitation.Email) {
+		return nil, organization.ErrInvitationEmailMismatch
+	}
+
+	return s.join(ctx, invitation, u, false)
+}
+
+// AcceptWithRegistration creates an account for the invited address and adds it
+// to the organization. Addresses that already have an account must sign in and
+// accept instead.
+func (s *InvitationService) AcceptWithRegistration(
+	ctx context.Context,
+	req *organization.AcceptInvitationWithRegistrationRequest,
+) (*organization.Membership, *user.User, error) {
+	if s.authService == nil {
+		return nil, nil, organization.ErrInvitationRequiresSignIn
+	}
+
+	invitation, err := s.redeem(ctx, req.Token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Check seats before creating an account that could not join; joining
+	// takes the seat
+	if err := s.organizations.checkMemberSeat(ctx, invitation.OrganizationID); err != nil {
+		return nil, nil, err
+	}
+
+	u, err := s.authService.Register(ctx, &auth.RegisterRequest{
+		Email:     invitation.Email,
+		Username:  req.Username,
+		Password:  req.Password,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+	})
+	if err != nil {
+		if errors.Is(err, auth.ErrEmailAlreadyExists) {
+			return nil, nil, organization.ErrInvitationRequiresSignIn
+		}
+		return nil, nil, err
+	}
+
+	// The link was delivered to the address, which proves the new account owns it
+	now := time.Now()
+	u.EmailVerified = true
+	u.EmailVerifiedAt = &now
+	u.UpdatedAt = now
+	if err := s.userRepo.Update(ctx, u); err != nil {
+		return nil, nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	membership, err := s.join(ctx, invitation, u, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return membership, u, nil
+}
+
+// redeem returns the open invitation a token was issued for
+func (s *InvitationService) redeem(ctx context.Context, token string) (*organization.Invitation, error) {
+	invitationID, expiresAt, signature, err := s.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(expiresAt) {
+		return nil, organization.ErrInvalidInvitation
+	}
+
+	invitation, err := s.invitations.GetByID(ctx, invitationID)
+	if err != nil {
+		if errors.Is(err, organization.ErrInvitationNotFound) {
+			return nil, organization.ErrInvalidInvitation
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	// Only the latest link works: resending moves the expiry the signature covers
+	expected := s.sign(invitation.ID, invitation.OrganizationID, invitation.Email, invitation.ExpiresAt)
+	if !hmac.Equal(signature, expected) || !invitation.IsOpen(time.Now()) {
+		return nil, organization.ErrInvalidInvitation
+	}
+
+	return invitation, nil
+}
+
+// join adds the user to the invitation's organization with its role and marks
+// the invitation accepted
+func (s *InvitationService) join(
+	ctx context.Context,
+	invitation *organization.Invitation,
+	u *user.User,
+	createdAccount bool,
+) (*organization.Membership, error) {
+	if _, err := s.organizations.memberships.Get(ctx, invitation.OrganizationID, u.ID); err == nil {
+		return nil, organization.ErrAlreadyMember
+	} else if !errors.Is(err, organization.ErrNotMember) {
+		return nil, fmt.Errorf("failed to get organization membership: %w", err)
+	}
+
+	seatLimit, err := s.organizations.seatLimit(ctx, invitation.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	membership := &organization.Membership{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         u.ID,
+		Role:           invitation.Role,
+		AddedBy:        invitation.InvitedBy,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	// The invitation held a seat, but the plan may have shrunk since. Its own
+	// seat is taken up by the new member, so open invitations are not counted.
+	if err := s.organizations.memberships.AddWithinSeatLimit(ctx, membership, seatLimit, false); err != nil {
+		if errors.Is(err, organization.ErrAlreadyMember) || errors.Is(err, organization.ErrSeatLimitReached) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to add organization member: %w", err)
+	}
+
+	invitation.Status = organization.InvitationStatusAccepted
+	invitation.AcceptedBy = &u.ID
+	invitation.AcceptedAt = &now
+	invitation.UpdatedAt = now
+
+	if err := s.invitations.Update(ctx, invitation); err != nil {
+		return nil, fmt.Errorf("failed to update invitation: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeOrganizationInvitationAccepted, invitation, u.ID, "Organization invitation accepted", map[string]interface{}{
+		"user_id":         u.ID.String(),
+		"created_account": createdAccount,
+	})
+
+	return membership, nil
+}
+
+// manageable returns a pending invitation the actor may resend or revoke
+func (s *InvitationService) manageable(ctx context.Context, orgID, invitationID, actorID uuid.UUID) (*organization.Invitation, error) {
+	actor, err := s.organizations.authorize(ctx, orgID, actorID, organization.PermissionMembersManage)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, err := s.invitations.GetByID(ctx, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	if invitation.OrganizationID != orgID {
+		return nil, organization.ErrInvitationNotFound
+	}
+	if invitation.Status != organization.InvitationStatusPending {
+		return nil, organization.ErrInvitationNotPending
+	}
+	if !actor.Role.Includes(invitation.Role) {
+		return nil, organization.ErrInsufficientRole
+	}
+
+	return invitation, nil
+}
+
+// send emails the invitation link
+func (s *InvitationService) send(ctx context.Context, invitation *organization.Invitation) error {
+	org, err := s.organizations.orgs.GetByID(ctx, invitation.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	inviter := "A member"
+	if u, err := s.userRepo.GetByID(ctx, invitation.InvitedBy); err == nil {
+		inviter = u.Username
+	}
+
+	token := s.generateToken(invitation.ID, invitation.OrganizationID, invitation.Email, invitation.ExpiresAt)
+	link := s.config.AcceptURL + "?token=" + url.QueryEscape(token)
+	days := int(s.config.TokenExpiry.Hours() / 24)
+
+	msg := &mail.Message{
+		To:      []string{invitation.Email},
+		Subject: fmt.Sprintf("You've been invited to join %s", org.Name),
+		TextBody: fmt.Sprintf(
+			"Hi,\n\n%s has invited you to join %s as %s. Accept the invitation by opening the link below:\n\n%s\n\n"+
+				"The link expires in %d days. If you weren't expecting this, you can ignore this email.\n",
+			inviter, org.Name, invitation.Role, link, days,
+		),
+	}
+
+	if err := s.mailSender.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send invitation email: %w", err)
+	}
+
+	return nil
+}
+
+// generateToken builds an invitation token of the form base64(invitationID|expiry).base64(hmac).
+// The expiry is kept to the microsecond, as stored, so every resend yields a new token.
+func (s *InvitationService) generateToken(invitationID, orgID uuid.UUID, email string, expiresAt time.Time) string {
+	payload := make([]byte, 0, 24)
+	payload = append(payload, invitationID[:]...)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(expiresAt.UnixMicro()))
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(invitationID, orgID, email, expiresAt))
+}
+
+// parseToken decodes an invitation token without checking its signature
+func (s *InvitationService) parseToken(token string) (uuid.UUID, time.Time, []byte, error) {
+	payloadPart, signaturePart, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, time.Time{}, nil, organization.ErrInvalidInvitation
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(payloadPart)
+	if err != nil || len(payload) != 24 {
+		return uuid.Nil, time.Time{}, nil, organization.ErrInvalidInvitation
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(signaturePart)
+	if err != nil {
+		return uuid.Nil, time.Time{}, nil, organization.ErrInvalidInvitation
+	}
+
+	invitationID, err := uuid.FromBytes(payload[:16])
+	if err != nil {
+		return uuid.Nil, time.Time{}, nil, organization.ErrInvalidInvitation
+	}
+
+	expiresAt := time.UnixMicro(int64(binary.BigEndian.Uint64(payload[16:])))
+
+	return invitationID, expiresAt, signature, nil
+}
+
+// sign computes the token signature over the invitation, organization, address and expiry
+func (s *InvitationService) sign(invitationID, orgID uuid.UUID, email string, expiresAt time.Time) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("organization-invitation\x00"))
+	mac.Write(invitationID[:])
+	mac.Write(orgID[:])
+	mac.Write([]byte(strings.ToLower(email)))
+	_ = binary.Write(mac, binary.BigEndian, expiresAt.UnixMicro())
+	return mac.Sum(nil)
+}
+
+// log records invitation changes when an audit service is configured. The
+// invitation's address, role and inviter are always included.
+func (s *InvitationService) log(
+	ctx context.Context,
+	eventType audit.EventType,
+	invitation *organization.Invitation,
+	actorID uuid.UUID,
+	description string,
+	metadata map[string]interface{},
+) {
+	if s.auditService == nil {
+		return
+	}
+
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["invitation_id"] = invitation.ID.String()
+	metadata["email"] = invitation.Email
+	metadata["role"] = string(invitation.Role)
+	metadata["invited_by"] = invitation.InvitedBy.String()
+
+	_, _ = s.auditService.Log(ctx, &audit.CreateLogRequest{
+		EventType:   eventType,
+		Severity:    audit.SeverityInfo,
+		ActorID:     &actorID,
+		ActorType:   audit.ActorTypeUser,
+		EntityType:  "organization",
+		EntityID:    invitation.OrganizationID.String(),
+		Action:      string(eventType),
+		Description: description,
+		Metadata:    metadata,
+	})
+}
@@ -0,0 +1,430 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/billing"
+	"github.com/victoralfred/um_sys/internal/domain/mail"
+	"github.com/victoralfred/um_sys/internal/domain/organization"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+	"github.com/victoralfred/um_sys/pkg/security"
+)
+
+var invitationLinkPattern = regexp.MustCompile(`https://app\.example\.com/invitations\?token=(\S+)`)
+
+// organizationPlans is a fixed set of organization subscriptions for testing
+type organizationPlans map[uuid.UUID]*billing.Plan
+
+func (p organizationPlans) CurrentOrganizationPlan(ctx context.Context, organizationID uuid.UUID) (*billing.Plan, error) {
+	plan, ok := p[organizationID]
+	if !ok {
+		return nil, billing.ErrNoActiveSubscription
+	}
+	return plan, nil
+}
+
+type invitationFixture struct {
+	*organizationFixture
+	service     *services.InvitationService
+	invitations *InMemoryInvitationRepository
+	plans       organizationPlans
+	links       []string
+}
+
+func newInvitationFixture(t *testing.T) *invitationFixture {
+	t.Helper()
+
+	f := &invitationFixture{
+		organizationFixture: newOrganizationFixture(t),
+		invitations:         NewInMemoryInvitationRepository(),
+		plans:               organizationPlans{},
+	}
+
+	mailSender := new(MockMailSender)
+	mailSender.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		msg := args.Get(1).(*mail.Message)
+		match := invitationLinkPattern.FindStringSubmatch(msg.TextBody)
+		require.Len(t, match, 2)
+		token, err := url.QueryUnescape(match[1])
+		require.NoError(t, err)
+		f.links = append(f.links, token)
+	}).Return(nil)
+
+	validator := security.NewPasswordValidator(&security.PasswordPolicy{
+		MinLength:        8,
+		RequireUppercase: 1,
+		RequireLowercase: 1,
+		RequireNumbers:   1,
+	})
+	authService := services.NewAuthService(f.users, f.tokenService, security.NewPasswordHasher(), validator, mailSender, services.DefaultAuthConfig())
+
+	config := services.DefaultInvitationConfig()
+	config.AcceptURL = "https://app.example.com/invitations"
+
+	f.service = services.NewInvitationService(f.invitations, f.organizationFixture.service, f.users, mailSender, "invitation-secret", config)
+	f.service.SetAuthService(authService)
+	f.organizationFixture.service.SetPlanProvider(f.plans)
+	f.memberships.invitations = f.invitations
+	f.invitations.memberships = f.memberships
+	f.organizationFixture.service.SetInvitationRepository(f.invitations)
+
+	return f
+}
+
+// lastToken returns the token from the most recent invitation email
+func (f *invitationFixture) lastToken(t *testing.T) string {
+	t.Helper()
+	require.NotEmpty(t, f.links)
+	return f.links[len(f.links)-1]
+}
+
+func TestInvitationService_Invite(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("existing users accept with the pre-assigned role", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		invitee := f.user(t)
+
+		auditService := new(MockAuditService)
+		auditService.On("Log", ctx, mock.Anything).Return(&audit.LogEntry{}, nil)
+		f.service.SetAuditService(auditService)
+
+		invitation, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{
+			Email: invitee.Email,
+			Role:  organization.RoleAdmin,
+		}, owner.ID)
+		require.NoError(t, err)
+		assert.Equal(t, organization.InvitationStatusPending, invitation.Status)
+
+		membership, err := f.service.Accept(ctx, f.lastToken(t), invitee.ID)
+		require.NoError(t, err)
+		assert.Equal(t, organization.RoleAdmin, membership.Role)
+		assert.Equal(t, owner.ID, membership.AddedBy)
+
+		stored, err := f.invitations.GetByID(ctx, invitation.ID)
+		require.NoError(t, err)
+		assert.Equal(t, organization.InvitationStatusAccepted, stored.Status)
+		require.NotNil(t, stored.AcceptedBy)
+		assert.Equal(t, invitee.ID, *stored.AcceptedBy)
+
+		// The inviter, role and acceptance are all recorded
+		require.Len(t, auditService.Calls, 2)
+		sent := auditService.Calls[0].Arguments.Get(1).(*audit.CreateLogRequest)
+		assert.Equal(t, audit.EventTypeOrganizationInvitationSent, sent.EventType)
+		assert.Equal(t, owner.ID, *sent.ActorID)
+		assert.Equal(t, string(organization.RoleAdmin), sent.Metadata["role"])
+		accepted := auditService.Calls[1].Arguments.Get(1).(*audit.CreateLogRequest)
+		assert.Equal(t, audit.EventTypeOrganizationInvitationAccepted, accepted.EventType)
+		assert.Equal(t, invitee.ID, *accepted.ActorID)
+		assert.Equal(t, owner.ID.String(), accepted.Metadata["invited_by"])
+
+		// Links work once
+		_, err = f.service.Accept(ctx, f.lastToken(t), invitee.ID)
+		assert.ErrorIs(t, err, organization.ErrInvalidInvitation)
+	})
+
+	t.Run("new users create their account on acceptance", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+
+		_, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{
+			Email: "New.Hire@Example.com",
+			Role:  organization.RoleMember,
+		}, owner.ID)
+		require.NoError(t, err)
+
+		membership, u, err := f.service.AcceptWithRegistration(ctx, &organization.AcceptInvitationWithRegistrationRequest{
+			Token:     f.lastToken(t),
+			Username:  "newhire",
+			Password:  "Str0ngPassword",
+			FirstName: "New",
+			LastName:  "Hire",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "new.hire@example.com", u.Email)
+		assert.True(t, u.EmailVerified)
+		assert.Equal(t, u.ID, membership.UserID)
+		assert.Equal(t, organization.RoleMember, membership.Role)
+	})
+
+	t.Run("addresses with an account must sign in to accept", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		invitee := f.user(t)
+
+		_, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: invitee.Email, Role: organization.RoleMember}, owner.ID)
+		require.NoError(t, err)
+
+		_, _, err = f.service.AcceptWithRegistration(ctx, &organization.AcceptInvitationWithRegistrationRequest{
+			Token:     f.lastToken(t),
+			Username:  "someoneelse",
+			Password:  "Str0ngPassword",
+			FirstName: "Some",
+			LastName:  "One",
+		})
+		assert.ErrorIs(t, err, organization.ErrInvitationRequiresSignIn)
+	})
+
+	t.Run("invitations are bound to their address", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+
+		_, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "invitee@example.com", Role: organization.RoleMember}, owner.ID)
+		require.NoError(t, err)
+
+		_, err = f.service.Accept(ctx, f.lastToken(t), f.user(t).ID)
+		assert.ErrorIs(t, err, organization.ErrInvitationEmailMismatch)
+	})
+
+	t.Run("tampered tokens are rejected", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		invitee := f.user(t)
+
+		_, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: invitee.Email, Role: organization.RoleMember}, owner.ID)
+		require.NoError(t, err)
+
+		payload, _, _ := strings.Cut(f.lastToken(t), ".")
+		forged := payload + "." + base64.RawURLEncoding.EncodeToString(make([]byte, sha256.Size))
+		_, err = f.service.Accept(ctx, forged, invitee.ID)
+		assert.ErrorIs(t, err, organization.ErrInvalidInvitation)
+		_, err = f.service.Accept(ctx, "not-a-token", invitee.ID)
+		assert.ErrorIs(t, err, organization.ErrInvalidInvitation)
+	})
+
+	t.Run("roles can only be granted by members holding them", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		admin := f.add(t, org, owner, organization.RoleAdmin)
+		member := f.add(t, org, owner, organization.RoleMember)
+
+		_, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "a@example.com", Role: organization.RoleOwner}, admin.ID)
+		assert.ErrorIs(t, err, organization.ErrInsufficientRole)
+
+		_, err = f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "a@example.com", Role: organization.RoleMember}, member.ID)
+		assert.ErrorIs(t, err, organization.ErrInsufficientRole)
+
+		_, err = f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "a@example.com", Role: organization.RoleMember}, f.user(t).ID)
+		assert.ErrorIs(t, err, organization.ErrOrganizationNotFound)
+	})
+
+	t.Run("members and pending addresses cannot be invited again", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		member := f.add(t, org, owner, organization.RoleMember)
+
+		_, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: member.Email, Role: organization.RoleMember}, owner.ID)
+		assert.ErrorIs(t, err, organization.ErrAlreadyMember)
+
+		_, err = f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "a@example.com", Role: organization.RoleMember}, owner.ID)
+		require.NoError(t, err)
+		_, err = f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "A@example.com", Role: organization.RoleAdmin}, owner.ID)
+		assert.ErrorIs(t, err, organization.ErrInvitationPending)
+	})
+
+	t.Run("invitations whose email cannot be sent are withdrawn", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+
+		mailSender := new(MockMailSender)
+		mailSender.On("Send", mock.Anything, mock.Anything).Return(errors.New("smtp unavailable"))
+		service := services.NewInvitationService(f.invitations, f.organizationFixture.service, f.users, mailSender, "invitation-secret", services.DefaultInvitationConfig())
+
+		invitation, err := service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "a@example.com", Role: organization.RoleMember}, owner.ID)
+		assert.Error(t, err)
+		assert.Nil(t, invitation)
+
+		// Nothing is left pending, so the address can be invited again
+		pending, err := f.invitations.ListPending(ctx, org.ID)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+
+		_, err = f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "a@example.com", Role: organization.RoleMember}, owner.ID)
+		assert.NoError(t, err)
+	})
+}
+
+func TestInvitationService_ResendAndRevoke(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("resending replaces the previous link", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		invitee := f.user(t)
+
+		invitation, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: invitee.Email, Role: organization.RoleMember}, owner.ID)
+		require.NoError(t, err)
+		first := f.lastToken(t)
+
+		// Let the invitation expire before resending it
+		invitation.ExpiresAt = time.Now().Add(-time.Minute).Truncate(time.Microsecond)
+		require.NoError(t, f.invitations.Update(ctx, invitation))
+
+		resent, err := f.service.Resend(ctx, org.ID, invitation.ID, owner.ID)
+		require.NoError(t, err)
+		assert.True(t, resent.IsOpen(time.Now()))
+
+		_, err = f.service.Accept(ctx, first, invitee.ID)
+		assert.ErrorIs(t, err, organization.ErrInvalidInvitation)
+
+		_, err = f.service.Accept(ctx, f.lastToken(t), invitee.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("revoked invitations cannot be accepted", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		invitee := f.user(t)
+
+		invitation, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: invitee.Email, Role: organization.RoleMember}, owner.ID)
+		require.NoError(t, err)
+
+		require.NoError(t, f.service.Revoke(ctx, org.ID, invitation.ID, owner.ID))
+
+		_, err = f.service.Accept(ctx, f.lastToken(t), invitee.ID)
+		assert.ErrorIs(t, err, organization.ErrInvalidInvitation)
+
+		err = f.service.Revoke(ctx, org.ID, invitation.ID, owner.ID)
+		assert.ErrorIs(t, err, organization.ErrInvitationNotPending)
+
+		_, err = f.service.Resend(ctx, org.ID, invitation.ID, owner.ID)
+		assert.ErrorIs(t, err, organization.ErrInvitationNotPending)
+	})
+
+	t.Run("invitations are managed within their organization", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		other := f.create(t, owner)
+
+		invitation, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "a@example.com", Role: organization.RoleMember}, owner.ID)
+		require.NoError(t, err)
+
+		err = f.service.Revoke(ctx, other.ID, invitation.ID, owner.ID)
+		assert.ErrorIs(t, err, organization.ErrInvitationNotFound)
+	})
+}
+
+func TestInvitationService_SeatLimits(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("pending invitations hold seats", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		f.plans[org.ID] = &billing.Plan{Limits: billing.PlanLimits{MaxTeamMembers: 2}}
+
+		invitation, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "a@example.com", Role: organization.RoleMember}, owner.ID)
+		require.NoError(t, err)
+
+		_, err = f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "b@example.com", Role: organization.RoleMember}, owner.ID)
+		assert.ErrorIs(t, err, organization.ErrSeatLimitReached)
+
+		// Revoking frees the seat
+		require.NoError(t, f.service.Revoke(ctx, org.ID, invitation.ID, owner.ID))
+		_, err = f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "b@example.com", Role: organization.RoleMember}, owner.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("acceptance fails once the plan is full", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		invitee := f.user(t)
+
+		_, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: invitee.Email, Role: organization.RoleMember}, owner.ID)
+		require.NoError(t, err)
+
+		// The organization subscribes to a single-seat plan after inviting
+		f.plans[org.ID] = &billing.Plan{Limits: billing.PlanLimits{MaxTeamMembers: 1}}
+
+		_, err = f.service.Accept(ctx, f.lastToken(t), invitee.ID)
+		assert.ErrorIs(t, err, organization.ErrSeatLimitReached)
+	})
+
+	t.Run("members added directly take seats", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		f.plans[org.ID] = &billing.Plan{Limits: billing.PlanLimits{MaxTeamMembers: 3}}
+
+		_, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: "a@example.com", Role: organization.RoleMember}, owner.ID)
+		require.NoError(t, err)
+
+		_, err = f.organizationFixture.service.AddMember(ctx, org.ID, &organization.AddMemberRequest{UserID: f.user(t).ID, Role: organization.RoleMember}, owner.ID)
+		require.NoError(t, err)
+
+		// The owner, the new member and the pending invitation fill the plan
+		_, err = f.organizationFixture.service.AddMember(ctx, org.ID, &organization.AddMemberRequest{UserID: f.user(t).ID, Role: organization.RoleMember}, owner.ID)
+		assert.ErrorIs(t, err, organization.ErrSeatLimitReached)
+	})
+
+	t.Run("concurrent additions cannot overfill the plan", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		f.plans[org.ID] = &billing.Plan{Limits: billing.PlanLimits{MaxTeamMembers: 3}}
+
+		candidates := make([]*user.User, 10)
+		for i := range candidates {
+			candidates[i] = f.user(t)
+		}
+
+		var wg sync.WaitGroup
+		for _, candidate := range candidates {
+			wg.Add(1)
+			go func(candidate *user.User) {
+				defer wg.Done()
+				_, _ = f.organizationFixture.service.AddMember(ctx, org.ID, &organization.AddMemberRequest{UserID: candidate.ID, Role: organization.RoleMember}, owner.ID)
+			}(candidate)
+		}
+		wg.Wait()
+
+		members, err := f.organizationFixture.service.ListMembers(ctx, org.ID, owner.ID)
+		require.NoError(t, err)
+		assert.Len(t, members, 3)
+	})
+
+	t.Run("organizations without a limit invite freely", func(t *testing.T) {
+		f := newInvitationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		f.plans[org.ID] = &billing.Plan{}
+
+		for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+			_, err := f.service.Invite(ctx, org.ID, &organization.InviteRequest{Email: email, Role: organization.RoleMember}, owner.ID)
+			require.NoError(t, err)
+		}
+
+		invitations, err := f.service.List(ctx, org.ID, owner.ID)
+		require.NoError(t, err)
+		assert.Len(t, invitations, 3)
+	})
+}
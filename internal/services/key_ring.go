@@ -0,0 +1,324 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+)
+
+const rsaKeySize = 2048
+
+// KeyRing holds the asymmetric keys used to sign and verify tokens.
+// Exactly one key is active at a time; rotated-out keys stay in the ring
+// as "retiring" until every token they signed has expired.
+type KeyRing struct {
+	mu               sync.RWMutex
+	algorithm        auth.SigningAlgorithm
+	retirementPeriod time.Duration
+	keys             map[string]*auth.SigningKey
+	activeKID        string
+}
+
+// NewKeyRing creates a key ring with a freshly generated active key.
+// retirementPeriod should be at least the longest token lifetime so that
+// tokens signed before a rotation keep validating.
+func NewKeyRing(algorithm auth.SigningAlgorithm, retirementPeriod time.Duration) (*KeyRing, error) {
+	ring := &KeyRing{
+		algorithm:        algorithm,
+		retirementPeriod: retirementPeriod,
+		keys:             make(map[string]*auth.SigningKey),
+	}
+
+	if _, err := ring.Rotate(); err != nil {
+		return nil, err
+	}
+
+	return ring, nil
+}
+
+// NewKeyRingFromKey creates a key ring whose active key was provisioned externally.
+// Retiring keys only verify tokens; they are the keys the active key replaced,
+// kept so that tokens signed before the replacement stay valid.
+func NewKeyRingFromKey(key *auth.SigningKey, retiring []*auth.SigningKey, retirementPeriod time.Duration) (*KeyRing, error) {
+	ring := &KeyRing{
+		algorithm:        key.Algorithm,
+		retirementPeriod: retirementPeriod,
+		keys:             make(map[string]*auth.SigningKey),
+	}
+
+	for _, old := range retiring {
+		if err := ring.AddKey(old, false); err != nil {
+			return nil, err
+		}
+	}
+	if err := ring.AddKey(key, true); err != nil {
+		return nil, err
+	}
+
+	return ring, nil
+}
+
+// Algorithm returns the algorithm used for newly generated keys
+func (r *KeyRing) Algorithm() auth.SigningAlgorithm {
+	return r.algorithm
+}
+
+// Rotate generates a new active key and moves the current one to retiring
+func (r *KeyRing) Rotate() (*auth.SigningKey, error) {
+	key, err := GenerateSigningKey(r.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.AddKey(key, true); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// AddKey adds an externally provisioned key to the ring, optionally making it the active key
+func (r *KeyRing) AddKey(key *auth.SigningKey, activate bool) error {
+	if key == nil || key.ID == "" || key.PublicKey == nil {
+		return fmt.Errorf("invalid signing key")
+	}
+	if activate && key.PrivateKey == nil {
+		return fmt.Errorf("cannot activate a key without a private key")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if activate {
+		if current, ok := r.keys[r.activeKID]; ok && current.ID != key.ID {
+			retiresAt := now.Add(r.retirementPeriod)
+			current.Status = auth.KeyStatusRetiring
+			current.RetiresAt = &retiresAt
+		}
+		key.Status = auth.KeyStatusActive
+		key.RetiresAt = nil
+		r.activeKID = key.ID
+	} else if key.Status == "" {
+		key.Status = auth.KeyStatusRetiring
+	}
+
+	r.keys[key.ID] = key
+	r.pruneLocked(now)
+
+	return nil
+}
+
+// ActiveKey returns the key currently used for signing
+func (r *KeyRing) ActiveKey() (*auth.SigningKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[r.activeKID]
+	if !ok {
+		return nil, auth.ErrNoActiveSigningKey
+	}
+
+	return key, nil
+}
+
+// VerificationKey returns the active or retiring key with the given key ID
+func (r *KeyRing) VerificationKey(kid string) (*auth.SigningKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[kid]
+	if !ok {
+		return nil, auth.ErrSigningKeyNotFound
+	}
+
+	if key.RetiresAt != nil && time.Now().After(*key.RetiresAt) {
+		return nil, auth.ErrSigningKeyNotFound
+	}
+
+	return key, nil
+}
+
+// Keys returns all keys that can still verify tokens, newest first
+func (r *KeyRing) Keys() []*auth.SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	keys := make([]*auth.SigningKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		if key.RetiresAt != nil && now.After(*key.RetiresAt) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	return keys
+}
+
+// JWKS returns the public half of every verification key
+func (r *KeyRing) JWKS() *auth.JWKS {
+	jwks := &auth.JWKS{Keys: []auth.JWK{}}
+
+	for _, key := range r.Keys() {
+		jwk, err := publicKeyToJWK(key)
+		if err != nil {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, *jwk)
+	}
+
+	return jwks
+}
+
+// pruneLocked drops keys whose retirement period has elapsed; caller must hold the write lock
+func (r *KeyRing) pruneLocked(now time.Time) {
+	for kid, key := range r.keys {
+		if kid != r.activeKID && key.RetiresAt != nil && now.After(*key.RetiresAt) {
+			delete(r.keys, kid)
+		}
+	}
+}
+
+// GenerateSigningKey generates a new key pair for the given algorithm
+func GenerateSigningKey(algorithm auth.SigningAlgorithm) (*auth.SigningKey, error) {
+	var signer crypto.Signer
+	var err error
+
+	switch algorithm {
+	case auth.SigningAlgorithmRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, rsaKeySize)
+	case auth.SigningAlgorithmES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case auth.SigningAlgorithmEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %s", auth.ErrUnsupportedAlgorithm, algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", algorithm, err)
+	}
+
+	return newSigningKey(algorithm, signer)
+}
+
+// ParseSigningKeyPEM parses a PKCS#8, PKCS#1 or SEC 1 encoded private key
+func ParseSigningKeyPEM(data []byte) (*auth.SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return newSigningKey(auth.SigningAlgorithmRS256, k)
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: only P-256 EC keys are supported", auth.ErrUnsupportedAlgorithm)
+		}
+		return newSigningKey(auth.SigningAlgorithmES256, k)
+	case ed25519.PrivateKey:
+		return newSigningKey(auth.SigningAlgorithmEdDSA, k)
+	default:
+		return nil, fmt.Errorf("%w: unsupported private key type %T", auth.ErrUnsupportedAlgorithm, parsed)
+	}
+}
+
+// signingMethodFor maps a signing algorithm to its JWT signing method
+func signingMethodFor(algorithm auth.SigningAlgorithm) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case auth.SigningAlgorithmHS256:
+		return jwt.SigningMethodHS256, nil
+	case auth.SigningAlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	case auth.SigningAlgorithmES256:
+		return jwt.SigningMethodES256, nil
+	case auth.SigningAlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", auth.ErrUnsupportedAlgorithm, algorithm)
+	}
+}
+
+// newSigningKey wraps a private key and derives its key ID from the public key
+func newSigningKey(algorithm auth.SigningAlgorithm, signer crypto.Signer) (*auth.SigningKey, error) {
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	// Deriving the kid from the public key keeps it stable across replicas sharing a key
+	sum := sha256.Sum256(der)
+
+	return &auth.SigningKey{
+		ID:         base64.RawURLEncoding.EncodeToString(sum[:12]),
+		Algorithm:  algorithm,
+		PrivateKey: signer,
+		PublicKey:  signer.Public(),
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// publicKeyToJWK converts a signing key's public half to JWK format
+func publicKeyToJWK(key *auth.SigningKey) (*auth.JWK, error) {
+	jwk := &auth.JWK{
+		Use: "sig",
+		Alg: string(key.Algorithm),
+		Kid: key.ID,
+	}
+
+	switch pub := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return nil, fmt.Errorf("%w: unsupported public key type %T", auth.ErrUnsupportedAlgorithm, key.PublicKey)
+	}
+
+	return jwk, nil
+}
@@ -0,0 +1,116 @@
+package services_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+func TestKeyRing_GenerateKeys(t *testing.T) {
+	tests := []struct {
+		algorithm auth.SigningAlgorithm
+		kty       string
+	}{
+		{auth.SigningAlgorithmRS256, "RSA"},
+		{auth.SigningAlgorithmES256, "EC"},
+		{auth.SigningAlgorithmEdDSA, "OKP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.algorithm), func(t *testing.T) {
+			ring, err := services.NewKeyRing(tt.algorithm, time.Hour)
+			require.NoError(t, err)
+
+			active, err := ring.ActiveKey()
+			require.NoError(t, err)
+			assert.Equal(t, tt.algorithm, active.Algorithm)
+			assert.Equal(t, auth.KeyStatusActive, active.Status)
+			assert.NotEmpty(t, active.ID)
+
+			jwks := ring.JWKS()
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, tt.kty, jwks.Keys[0].Kty)
+			assert.Equal(t, active.ID, jwks.Keys[0].Kid)
+			assert.Equal(t, string(tt.algorithm), jwks.Keys[0].Alg)
+			assert.Equal(t, "sig", jwks.Keys[0].Use)
+		})
+	}
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		_, err := services.NewKeyRing(auth.SigningAlgorithm("none"), time.Hour)
+		assert.ErrorIs(t, err, auth.ErrUnsupportedAlgorithm)
+	})
+}
+
+func TestKeyRing_Rotate(t *testing.T) {
+	t.Run("previous key keeps verifying while retiring", func(t *testing.T) {
+		ring, err := services.NewKeyRing(auth.SigningAlgorithmES256, time.Hour)
+		require.NoError(t, err)
+
+		oldKey, err := ring.ActiveKey()
+		require.NoError(t, err)
+
+		newKey, err := ring.Rotate()
+		require.NoError(t, err)
+		assert.NotEqual(t, oldKey.ID, newKey.ID)
+
+		active, err := ring.ActiveKey()
+		require.NoError(t, err)
+		assert.Equal(t, newKey.ID, active.ID)
+
+		retiring, err := ring.VerificationKey(oldKey.ID)
+		require.NoError(t, err)
+		assert.Equal(t, auth.KeyStatusRetiring, retiring.Status)
+		require.NotNil(t, retiring.RetiresAt)
+
+		assert.Len(t, ring.JWKS().Keys, 2)
+	})
+
+	t.Run("retired keys are dropped after the retirement period", func(t *testing.T) {
+		ring, err := services.NewKeyRing(auth.SigningAlgorithmEdDSA, time.Nanosecond)
+		require.NoError(t, err)
+
+		oldKey, err := ring.ActiveKey()
+		require.NoError(t, err)
+
+		_, err = ring.Rotate()
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond)
+
+		_, err = ring.VerificationKey(oldKey.ID)
+		assert.ErrorIs(t, err, auth.ErrSigningKeyNotFound)
+		assert.Len(t, ring.JWKS().Keys, 1)
+	})
+}
+
+func TestParseSigningKeyPEM(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	key, err := services.ParseSigningKeyPEM(data)
+	require.NoError(t, err)
+	assert.Equal(t, auth.SigningAlgorithmES256, key.Algorithm)
+
+	// The key ID is derived from the public key, so parsing twice yields the same kid
+	again, err := services.ParseSigningKeyPEM(data)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, again.ID)
+
+	_, err = services.ParseSigningKeyPEM([]byte("not a key"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/ratelimit"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+)
+
+// counterLimit turns the rate limiter into a plain counter: a check never hits it,
+// and Limit - Remaining is the number of events in the window
+const counterLimit = math.MaxInt32
+
+// LockoutConfig holds the sign-in lockout and throttling policy.
+// Lockouts double with every repeat offence up to a cap, so a patient attacker
+// gets exponentially fewer guesses while a user who mistypes is barely slowed.
+type LockoutConfig struct {
+	// AccountMaxFailures is how many failed sign-ins lock an account; every further
+	// AccountMaxFailures failures lock it again for twice as long
+	AccountMaxFailures int
+
+	// AccountLockout is the length of the first lockout, MaxAccountLockout the longest
+	AccountLockout    time.Duration
+	MaxAccountLockout time.Duration
+
+	// IPMaxFailures is how many failed sign-ins a client address may make per IPWindow
+	// before it is blocked for IPWindow. The block doubles for every earlier block of
+	// the address within StrikeMemory, up to MaxIPWindow.
+	IPMaxFailures int
+	IPWindow      time.Duration
+	MaxIPWindow   time.Duration
+	StrikeMemory  time.Duration
+
+	// StuffingMaxAccounts is how many different accounts one address may fail to sign
+	// in to per StuffingWindow before it is blocked for StuffingBlock. This catches
+	// credential stuffing, which tries few passwords against many accounts.
+	StuffingMaxAccounts int
+	StuffingWindow      time.Duration
+	StuffingBlock       time.Duration
+}
+
+// DefaultLockoutConfig returns the default lockout policy
+func DefaultLockoutConfig() LockoutConfig {
+	return LockoutConfig{
+		AccountMaxFailures:  5,
+		AccountLockout:      15 * time.Minute,
+		MaxAccountLockout:   24 * time.Hour,
+		IPMaxFailures:       20,
+		IPWindow:            15 * time.Minute,
+		MaxIPWindow:         24 * time.Hour,
+		StrikeMemory:        24 * time.Hour,
+		StuffingMaxAccounts: 10,
+		StuffingWindow:      time.Hour,
+		StuffingBlock:       24 * time.Hour,
+	}
+}
+
+// LockoutService applies the sign-in lockout policy. Accounts are locked in the
+// user repository so every sign-in method honours the lock; client addresses are
+// throttled with the rate limiter and are not throttled at all without one.
+type LockoutService struct {
+	userRepo     user.Repository
+	rateLimiter  ratelimit.RateLimiter
+	auditService audit.AuditService
+	config       LockoutConfig
+}
+
+// NewLockoutService creates a new lockout service
+func NewLockoutService(userRepo user.Repository, config LockoutConfig) *LockoutService {
+	return &LockoutService{
+		userRepo: userRepo,
+		config:   config,
+	}
+}
+
+// SetRateLimiter enables per-address throttling and credential stuffing detection
+func (s *LockoutService) SetRateLimiter(rateLimiter ratelimit.RateLimiter) {
+	s.rateLimiter = rateLimiter
+}
+
+// SetAuditService records locks, unlocks and address blocks in the audit log
+func (s *LockoutService) SetAuditService(auditService audit.AuditService) {
+	s.auditService = auditService
+}
+
+// CheckIP returns ErrLoginThrottled, with the time until the address may try again,
+// if sign-ins from the address are currently blocked. Rate limiter failures let the
+// attempt through rather than lock everyone out.
+func (s *LockoutService) CheckIP(ctx context.Context, ip string) (*ratelimit.RateLimitResult, error) {
+	if s.rateLimiter == nil || ip == "" {
+		return nil, nil
+	}
+
+	result, err := s.rateLimiter.GetStatus(ctx, stuffingBlockKey(ip), 1, s.config.StuffingBlock)
+	if err == nil && !result.Allowed {
+		return result, auth.ErrLoginThrottled
+	}
+
+	strikes := s.strikes(ctx, ip)
+	if strikes == 0 {
+		return nil, nil
+	}
+
+	result, err = s.rateLimiter.GetStatus(ctx, ipBlockKey(ip), 1, s.ipBlock(strikes))
+	if err == nil && !result.Allowed {
+		return result, auth.ErrLoginThrottled
+	}
+
+	return nil, nil
+}
+
+// RecordFailure counts a failed sign-in against the client address, the identifier
+// that was tried and, when it belongs to an account, that account
+func (s *LockoutService) RecordFailure(ctx context.Context, ip, identifier string, u *user.User) {
+	if s.rateLimiter != nil && ip != "" {
+		s.recordIPFailure(ctx, ip)
+		s.recordStuffing(ctx, ip, identifier)
+	}
+
+	if u != nil {
+		s.recordAccountFailure(ctx, u, ip)
+	}
+}
+
+// Unlock lifts an account lockout early and restores a locked or suspended account
+func (s *LockoutService) Unlock(ctx context.Context, userID uuid.UUID, actor audit.Actor) (*user.User, error) {
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.Unlock(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to unlock user: %w", err)
+	}
+	u.FailedLoginAttempts = 0
+	u.LockedUntil = nil
+
+	if u.Status == user.StatusLocked || u.Status == user.StatusSuspended {
+		u.Status = user.StatusActive
+		if err := s.userRepo.Update(ctx, u); err != nil {
+			return nil, fmt.Errorf("failed to activate user: %w", err)
+		}
+	}
+
+	actorID := actor.ID
+	s.log(ctx, &audit.CreateLogRequest{
+		EventType:   audit.EventTypeUserUnlocked,
+		Severity:    audit.SeverityInfo,
+		UserID:      &u.ID,
+		ActorID:     &actorID,
+		ActorType:   actor.Type,
+		EntityType:  "user",
+		EntityID:    u.ID.String(),
+		Action:      string(audit.EventTypeUserUnlocked),
+		Description: "Account unlocked by an administrator",
+	})
+
+	return u, nil
+}
+
+// recordAccountFailure locks the account each time it reaches another multiple of
+// AccountMaxFailures, doubling the lockout each time
+func (s *LockoutService) recordAccountFailure(ctx context.Context, u *user.User, ip string) {
+	attempts, err := s.userRepo.IncrementFailedLoginAttempts(ctx, u.ID)
+	if err != nil || s.config.AccountMaxFailures <= 0 || attempts%s.config.AccountMaxFailures != 0 {
+		return
+	}
+
+	lockout := backoff(s.config.AccountLockout, attempts/s.config.AccountMaxFailures-1, s.config.MaxAccountLockout)
+	until := time.Now().Add(lockout)
+	if err := s.userRepo.Lock(ctx, u.ID, until); err != nil {
+		return
+	}
+
+	s.log(ctx, &audit.CreateLogRequest{
+		EventType:   audit.EventTypeUserLocked,
+		Severity:    audit.SeverityWarning,
+		UserID:      &u.ID,
+		EntityType:  "user",
+		EntityID:    u.ID.String(),
+		Action:      string(audit.EventTypeUserLocked),
+		Description: "Account locked after repeated failed sign-in attempts",
+		IPAddress:   ip,
+		Metadata: map[string]interface{}{
+			"failed_attempts": attempts,
+			"locked_until":    until,
+		},
+	})
+}
+
+// recordIPFailure counts a failure against the address and blocks it once the
+// failures use up its allowance, for twice as long as the previous block
+func (s *LockoutService) recordIPFailure(ctx context.Context, ip string) {
+	result, err := s.rateLimiter.Check(ctx, ipFailuresKey(ip), s.config.IPMaxFailures, s.config.IPWindow)
+	if err != nil || !result.Allowed || result.Remaining > 0 {
+		return
+	}
+
+	if _, err := s.rateLimiter.Check(ctx, ipStrikesKey(ip), counterLimit, s.config.StrikeMemory); err != nil {
+		return
+	}
+	block := s.ipBlock(s.strikes(ctx, ip))
+
+	// The address starts over with a full allowance once the block ends
+	_ = s.rateLimiter.Reset(ctx, ipBlockKey(ip))
+	_, _ = s.rateLimiter.Check(ctx, ipBlockKey(ip), 1, block)
+	_ = s.rateLimiter.Reset(ctx, ipFailuresKey(ip))
+
+	s.log(ctx, &audit.CreateLogRequest{
+		EventType:   audit.EventTypeRateLimited,
+		Severity:    audit.SeverityWarning,
+		EntityType:  "ip_address",
+		EntityID:    ip,
+		Action:      "login.throttled",
+		Description: "Sign-ins blocked after repeated failed attempts from one address",
+		IPAddress:   ip,
+		Metadata: map[string]interface{}{
+			"blocked": block.String(),
+		},
+	})
+}
+
+// recordStuffing blocks the address once it has failed to sign in to too many
+// different accounts. Only an identifier's first failure in the window counts.
+func (s *LockoutService) recordStuffing(ctx context.Context, ip, identifier string) {
+	if identifier == "" {
+		return
+	}
+
+	tried, err := s.rateLimiter.Check(ctx, stuffingIdentifierKey(ip, identifier), counterLimit, s.config.StuffingWindow)
+	if err != nil || tried.Limit-tried.Remaining > 1 {
+		return
+	}
+
+	result, err := s.rateLimiter.Check(ctx, stuffingAccountsKey(ip), s.config.StuffingMaxAccounts, s.config.StuffingWindow)
+	if err != nil || !result.Allowed || result.Remaining > 0 {
+		return
+	}
+
+	_, _ = s.rateLimiter.Check(ctx, stuffingBlockKey(ip), 1, s.config.StuffingBlock)
+
+	s.log(ctx, &audit.CreateLogRequest{
+		EventType:   audit.EventTypeRateLimited,
+		Severity:    audit.SeverityCritical,
+		EntityType:  "ip_address",
+		EntityID:    ip,
+		Action:      "login.credential_stuffing",
+		Description: "Sign-ins blocked after failures against many accounts from one address",
+		IPAddress:   ip,
+		Metadata: map[string]interface{}{
+			"accounts": s.config.StuffingMaxAccounts,
+			"blocked":  s.config.StuffingBlock.String(),
+		},
+	})
+}
+
+// strikes returns how many times the address was blocked within StrikeMemory
+func (s *LockoutService) strikes(ctx context.Context, ip string) int {
+	result, err := s.rateLimiter.GetStatus(ctx, ipStrikesKey(ip), counterLimit, s.config.StrikeMemory)
+	if err != nil {
+		return 0
+	}
+	return result.Limit - result.Remaining
+}
+
+// ipBlock returns how long the address's latest block lasts
+func (s *LockoutService) ipBlock(strikes int) time.Duration {
+	return backoff(s.config.IPWindow, strikes-1, s.config.MaxIPWindow)
+}
+
+func (s *LockoutService) log(ctx context.Context, req *audit.CreateLogRequest) {
+	if s.auditService == nil {
+		return
+	}
+	_, _ = s.auditService.Log(ctx, req)
+}
+
+// backoff doubles base once per step, never exceeding max
+func backoff(base time.Duration, steps int, max time.Duration) time.Duration {
+	d := base
+	for i := 0; i < steps && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+func ipFailuresKey(ip string) string {
+	return "login_failures:ip:" + ip
+}
+
+func ipStrikesKey(ip string) string {
+	return "login_strikes:ip:" + ip
+}
+
+func ipBlockKey(ip string) string {
+	return "login_throttled:ip:" + ip
+}
+
+func stuffingAccountsKey(ip string) string {
+	return "login_accounts:ip:" + ip
+}
+
+func stuffingBlockKey(ip string) string {
+	return "login_blocked:ip:" + ip
+}
+
+// stuffingIdentifierKey hashes the identifier so emails are not written to the rate limiter
+func stuffingIdentifierKey(ip, identifier string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(identifier)))
+	return "login_identifier:ip:" + ip + ":" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,276 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+type lockoutFixture struct {
+	service  *services.LockoutService
+	userRepo *InMemoryUserRepository
+	limiter  *InMemoryRateLimiter
+	audit    *MockAuditService
+	user     *user.User
+}
+
+func newLockoutFixture(t *testing.T) *lockoutFixture {
+	t.Helper()
+
+	userRepo := NewInMemoryUserRepository()
+	u := &user.User{
+		ID:       uuid.New(),
+		Email:    "test@example.com",
+		Username: "testuser",
+		Status:   user.StatusActive,
+	}
+	require.NoError(t, userRepo.Create(context.Background(), u))
+
+	config := services.DefaultLockoutConfig()
+	config.AccountMaxFailures = 3
+	config.AccountLockout = time.Minute
+	config.MaxAccountLockout = 3 * time.Minute
+	config.IPMaxFailures = 5
+	config.IPWindow = time.Minute
+	config.MaxIPWindow = time.Hour
+	config.StuffingMaxAccounts = 4
+
+	auditService := new(MockAuditService)
+	auditService.On("Log", mock.Anything, mock.Anything).Return(nil, nil)
+
+	limiter := NewInMemoryRateLimiter()
+	service := services.NewLockoutService(userRepo, config)
+	service.SetRateLimiter(limiter)
+	service.SetAuditService(auditService)
+
+	return &lockoutFixture{
+		service:  service,
+		userRepo: userRepo,
+		limiter:  limiter,
+		audit:    auditService,
+		user:     u,
+	}
+}
+
+func (f *lockoutFixture) fail(ctx context.Context, ip, identifier string, n int) {
+	for i := 0; i < n; i++ {
+		f.service.RecordFailure(ctx, ip, identifier, f.user)
+	}
+}
+
+func (f *lockoutFixture) auditedEvents(eventType audit.EventType) []*audit.CreateLogRequest {
+	var events []*audit.CreateLogRequest
+	for _, call := range f.audit.Calls {
+		req := call.Arguments.Get(1).(*audit.CreateLogRequest)
+		if req.EventType == eventType {
+			events = append(events, req)
+		}
+	}
+	return events
+}
+
+func TestLockoutService_AccountLockout(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("locks the account after repeated failures", func(t *testing.T) {
+		f := newLockoutFixture(t)
+
+		f.fail(ctx, "10.0.0.1", f.user.Email, 2)
+		stored, err := f.userRepo.GetByID(ctx, f.user.ID)
+		require.NoError(t, err)
+		assert.False(t, stored.IsLocked())
+
+		f.fail(ctx, "10.0.0.1", f.user.Email, 1)
+		stored, err = f.userRepo.GetByID(ctx, f.user.ID)
+		require.NoError(t, err)
+		assert.True(t, stored.IsLocked())
+		assert.WithinDuration(t, time.Now().Add(time.Minute), *stored.LockedUntil, 5*time.Second)
+
+		events := f.auditedEvents(audit.EventTypeUserLocked)
+		require.Len(t, events, 1)
+		assert.Equal(t, f.user.ID.String(), events[0].EntityID)
+	})
+
+	t.Run("doubles the lockout for repeat offences up to the cap", func(t *testing.T) {
+		f := newLockoutFixture(t)
+
+		f.fail(ctx, "10.0.0.1", f.user.Email, 6)
+		stored, err := f.userRepo.GetByID(ctx, f.user.ID)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(2*time.Minute), *stored.LockedUntil, 5*time.Second)
+
+		f.fail(ctx, "10.0.0.2", f.user.Email, 6)
+		stored, err = f.userRepo.GetByID(ctx, f.user.ID)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(3*time.Minute), *stored.LockedUntil, 5*time.Second)
+	})
+
+	t.Run("an administrator can unlock the account", func(t *testing.T) {
+		f := newLockoutFixture(t)
+		f.fail(ctx, "10.0.0.1", f.user.Email, 3)
+
+		stored, err := f.userRepo.GetByID(ctx, f.user.ID)
+		require.NoError(t, err)
+		stored.Status = user.StatusLocked
+		require.NoError(t, f.userRepo.Update(ctx, stored))
+
+		admin := audit.Actor{ID: uuid.New(), Type: audit.ActorTypeUser}
+		unlocked, err := f.service.Unlock(ctx, f.user.ID, admin)
+		require.NoError(t, err)
+		assert.Equal(t, user.StatusActive, unlocked.Status)
+
+		stored, err = f.userRepo.GetByID(ctx, f.user.ID)
+		require.NoError(t, err)
+		assert.False(t, stored.IsLocked())
+		assert.Zero(t, stored.FailedLoginAttempts)
+		assert.Equal(t, user.StatusActive, stored.Status)
+
+		events := f.auditedEvents(audit.EventTypeUserUnlocked)
+		require.Len(t, events, 1)
+		assert.Equal(t, admin.ID, *events[0].ActorID)
+
+		_, err = f.service.Unlock(ctx, uuid.New(), admin)
+		assert.ErrorIs(t, err, user.ErrUserNotFound)
+	})
+}
+
+func TestLockoutService_IPThrottling(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("throttles an address after too many failures", func(t *testing.T) {
+		f := newLockoutFixture(t)
+
+		for i := 0; i < 4; i++ {
+			f.service.RecordFailure(ctx, "10.0.0.1", f.user.Email, nil)
+		}
+		_, err := f.service.CheckIP(ctx, "10.0.0.1")
+		require.NoError(t, err)
+
+		f.service.RecordFailure(ctx, "10.0.0.1", f.user.Email, nil)
+		result, err := f.service.CheckIP(ctx, "10.0.0.1")
+		assert.ErrorIs(t, err, auth.ErrLoginThrottled)
+		require.NotNil(t, result)
+		assert.True(t, result.RetryAfter > 0)
+
+		_, err = f.service.CheckIP(ctx, "10.0.0.2")
+		assert.NoError(t, err)
+
+		assert.Len(t, f.auditedEvents(audit.EventTypeRateLimited), 1)
+	})
+
+	t.Run("lets the address retry after the window and doubles it next time", func(t *testing.T) {
+		f := newLockoutFixture(t)
+
+		for i := 0; i < 5; i++ {
+			f.service.RecordFailure(ctx, "10.0.0.1", f.user.Email, nil)
+		}
+		f.limiter.expire(61 * time.Second)
+
+		_, err := f.service.CheckIP(ctx, "10.0.0.1")
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			f.service.RecordFailure(ctx, "10.0.0.1", f.user.Email, nil)
+		}
+		f.limiter.expire(61 * time.Second)
+
+		result, err := f.service.CheckIP(ctx, "10.0.0.1")
+		assert.ErrorIs(t, err, auth.ErrLoginThrottled)
+		require.NotNil(t, result)
+		assert.True(t, result.RetryAfter > 30*time.Second)
+	})
+
+	t.Run("does nothing without a rate limiter", func(t *testing.T) {
+		service := services.NewLockoutService(NewInMemoryUserRepository(), services.DefaultLockoutConfig())
+
+		for i := 0; i < 100; i++ {
+			service.RecordFailure(ctx, "10.0.0.1", "someone", nil)
+		}
+		_, err := service.CheckIP(ctx, "10.0.0.1")
+		assert.NoError(t, err)
+	})
+}
+
+func TestLockoutService_CredentialStuffing(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("blocks an address that fails against many accounts", func(t *testing.T) {
+		f := newLockoutFixture(t)
+
+		for i := 0; i < 4; i++ {
+			f.service.RecordFailure(ctx, "10.0.0.1", fmt.Sprintf("user%d@example.com", i), nil)
+		}
+
+		result, err := f.service.CheckIP(ctx, "10.0.0.1")
+		assert.ErrorIs(t, err, auth.ErrLoginThrottled)
+		require.NotNil(t, result)
+		assert.True(t, result.RetryAfter > time.Hour)
+
+		events := f.auditedEvents(audit.EventTypeRateLimited)
+		require.Len(t, events, 1)
+		assert.Equal(t, "login.credential_stuffing", events[0].Action)
+	})
+
+	t.Run("counts each account once", func(t *testing.T) {
+		f := newLockoutFixture(t)
+
+		for i := 0; i < 3; i++ {
+			f.service.RecordFailure(ctx, "10.0.0.1", "user0@example.com", nil)
+			f.service.RecordFailure(ctx, "10.0.0.1", "USER1@example.com", nil)
+			f.service.RecordFailure(ctx, "10.0.0.1", "user1@example.com", nil)
+		}
+		f.service.RecordFailure(ctx, "10.0.0.1", "user2@example.com", nil)
+
+		// Ten failures are over the address limit, but only three accounts were tried
+		for _, event := range f.auditedEvents(audit.EventTypeRateLimited) {
+			assert.NotEqual(t, "login.credential_stuffing", event.Action)
+		}
+	})
+}
+
+func TestAuthService_LoginLockout(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthServiceFixture(t)
+
+	userRepo := NewInMemoryUserRepository()
+	require.NoError(t, userRepo.Create(ctx, f.user))
+	service := services.NewAuthService(userRepo, f.tokenService, f.hasher, nil, f.mailSender, services.DefaultAuthConfig())
+
+	config := services.DefaultLockoutConfig()
+	config.AccountMaxFailures = 2
+	lockout := services.NewLockoutService(userRepo, config)
+	lockout.SetRateLimiter(NewInMemoryRateLimiter())
+	service.SetLockoutService(lockout)
+
+	login := func(password string) error {
+		_, _, err := service.Login(ctx, &auth.LoginRequest{
+			Email:     f.user.Email,
+			Password:  password,
+			IPAddress: "10.0.0.1",
+		})
+		return err
+	}
+
+	assert.ErrorIs(t, login("WrongPassword1"), auth.ErrInvalidCredentials)
+	assert.ErrorIs(t, login("WrongPassword1"), auth.ErrInvalidCredentials)
+	assert.ErrorIs(t, login("OldPassword1"), auth.ErrAccountLocked)
+
+	// The lock lifts by itself once it expires
+	require.NoError(t, userRepo.Lock(ctx, f.user.ID, time.Now().Add(-time.Second)))
+	require.NoError(t, login("OldPassword1"))
+
+	stored, err := userRepo.GetByID(ctx, f.user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, stored.FailedLoginAttempts)
+}
@@ -29,6 +29,7 @@ type MFAService struct {
 	cache          mfa.Cache
 	passwordHasher auth.PasswordHasher
 	rateLimiter    mfa.RateLimiter
+	trustedDevices *TrustedDeviceService
 }
 
 // NewMFAService creates a new MFA service
@@ -54,6 +55,11 @@ func NewMFAService(
 	}
 }
 
+// SetTrustedDeviceService revokes the user's trusted devices when MFA is disabled
+func (s *MFAService) SetTrustedDeviceService(trustedDevices *TrustedDeviceService) {
+	s.trustedDevices = trustedDevices
+}
+
 // SetupMFA initiates MFA setup for a user
 func (s *MFAService) SetupMFA(ctx context.Context, req *mfa.SetupRequest) (*mfa.SetupResponse, error) {
 	// Check if MFA is already enabled
@@ -212,14 +218,9 @@ func (s *MFAService) VerifySetup(ctx context.Context, req *mfa.VerifySetupReques
 			}
 		}
 
-	case mfa.MethodSMS, mfa.MethodEmail:
-		// For SMS/Email, we would verify the code sent to the user
-		// This is simplified for now
-		if req.Code != "123456" { // In production, validate against sent code
-			return mfa.ErrInvalidCode
-		}
-
 	default:
+		// SMS and email codes are not checked against the codes sent yet, so
+		// those methods cannot be enabled
 		return mfa.ErrInvalidMethod
 	}
 
@@ -301,12 +302,9 @@ func (s *MFAService) VerifyCode(ctx context.Context, req *mfa.VerifyRequest) (*m
 			}
 		}
 
-	case mfa.MethodSMS, mfa.MethodEmail:
-		// For SMS/Email, validate against cached challenge
-		// This is simplified for now
-		valid = req.Code == "123456"
-
 	default:
+		// SMS and email codes are not checked against the codes sent yet, so
+		// they are refused rather than accepted
 		return nil, mfa.ErrInvalidMethod
 	}
 
@@ -385,6 +383,13 @@ func (s *MFAService) DisableMFA(ctx context.Context, req *mfa.DisableRequest) er
 		_ = err
 	}
 
+	// Remembered devices must not skip MFA if it is enabled again
+	if s.trustedDevices != nil {
+		if err := s.trustedDevices.RevokeAll(ctx, req.UserID, "MFA disabled"); err != nil {
+			return err
+		}
+	}
+
 	// Log audit event
 	s.logAudit(ctx, req.UserID, "disable", "", true, "")
 
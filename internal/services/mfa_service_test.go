@@ -442,6 +442,30 @@ func TestMFAService_VerifyCode(t *testing.T) {
 
 		mockRepo.AssertExpectations(t)
 	})
+
+	t.Run("SMS and email codes are refused", func(t *testing.T) {
+		// Arrange
+		mockRepo := new(MockMFARepository)
+		mfaService := services.NewMFAService(mockRepo, new(MockUserRepository), new(MockTOTPProvider), nil, nil, new(MockCodeGenerator), nil, nil)
+
+		userID := uuid.New()
+		settings := &mfa.Settings{
+			UserID:        userID,
+			Enabled:       true,
+			Methods:       []mfa.Method{mfa.MethodSMS, mfa.MethodEmail},
+			PrimaryMethod: mfa.MethodSMS,
+		}
+		mockRepo.On("GetSettings", ctx, userID).Return(settings, nil)
+
+		for _, method := range []mfa.Method{mfa.MethodSMS, mfa.MethodEmail} {
+			// Act
+			resp, err := mfaService.VerifyCode(ctx, &mfa.VerifyRequest{UserID: userID, Method: method, Code: "123456"})
+
+			// Assert
+			assert.ErrorIs(t, err, mfa.ErrInvalidMethod)
+			assert.Nil(t, resp)
+		}
+	})
 }
 
 func TestMFAService_DisableMFA(t *testing.T) {
@@ -2,11 +2,29 @@ package services_test
 
 import (
 	"context"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
+	"github.com/victoralfred/um_sys/internal/domain/apikey"
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/federation"
+	"github.com/victoralfred/um_sys/internal/domain/mail"
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+	"github.com/victoralfred/um_sys/internal/domain/oauth"
+	"github.com/victoralfred/um_sys/internal/domain/organization"
+	"github.com/victoralfred/um_sys/internal/domain/ratelimit"
+	"github.com/victoralfred/um_sys/internal/domain/risk"
+	"github.com/victoralfred/um_sys/internal/domain/saml"
+	"github.com/victoralfred/um_sys/internal/domain/scim"
+	"github.com/victoralfred/um_sys/internal/domain/serviceaccount"
+	"github.com/victoralfred/um_sys/internal/domain/session"
 	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/domain/webauthn"
 )
 
 // MockUserRepository is a mock implementation of user.Repository for testing
@@ -66,7 +84,17 @@ func (m *MockUserRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID,
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) IncrementFailedLoginAttempts(ctx context.Context, id uuid.UUID) error {
+func (m *MockUserRepository) IncrementFailedLoginAttempts(ctx context.Context, id uuid.UUID) (int, error) {
+	args := m.Called(ctx, id)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockUserRepository) Lock(ctx context.Context, id uuid.UUID, until time.Time) error {
+	args := m.Called(ctx, id, until)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) Unlock(ctx context.Context, id uuid.UUID) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
@@ -100,3 +128,1925 @@ func (m *MockPasswordHasher) VerifyPassword(password, hash string) error {
 	args := m.Called(password, hash)
 	return args.Error(0)
 }
+
+// InMemoryTokenStore is an in-memory implementation of auth.TokenStore for testing
+type InMemoryTokenStore struct {
+	mu       sync.Mutex
+	tokens   map[string]uuid.UUID
+	families map[string]*auth.TokenFamily
+}
+
+func NewInMemoryTokenStore() *InMemoryTokenStore {
+	return &InMemoryTokenStore{
+		tokens:   make(map[string]uuid.UUID),
+		families: make(map[string]*auth.TokenFamily),
+	}
+}
+
+func (s *InMemoryTokenStore) Store(ctx context.Context, tokenID string, userID uuid.UUID, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[tokenID] = userID
+	return nil
+}
+
+func (s *InMemoryTokenStore) Exists(ctx context.Context, tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.tokens[tokenID]
+	return ok, nil
+}
+
+func (s *InMemoryTokenStore) Delete(ctx context.Context, tokenID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, tokenID)
+	return nil
+}
+
+func (s *InMemoryTokenStore) DeleteAllForUser(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, owner := range s.tokens {
+		if owner == userID {
+			delete(s.tokens, id)
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryTokenStore) SaveFamily(ctx context.Context, family *auth.TokenFamily) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *family
+	s.families[family.ID] = &stored
+	return nil
+}
+
+func (s *InMemoryTokenStore) GetFamily(ctx context.Context, familyID string) (*auth.TokenFamily, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	family, ok := s.families[familyID]
+	if !ok {
+		return nil, auth.ErrTokenFamilyNotFound
+	}
+	result := *family
+	return &result, nil
+}
+
+func (s *InMemoryTokenStore) RotateFamily(ctx context.Context, familyID, currentTokenID, nextTokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	family, ok := s.families[familyID]
+	if !ok {
+		return auth.ErrTokenFamilyNotFound
+	}
+	if family.CurrentJTI != currentTokenID {
+		return auth.ErrRefreshTokenReused
+	}
+	now := time.Now()
+	family.CurrentJTI = nextTokenID
+	family.RotatedAt = &now
+	family.ExpiresAt = expiresAt
+	return nil
+}
+
+func (s *InMemoryTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	family, ok := s.families[familyID]
+	if !ok {
+		return auth.ErrTokenFamilyNotFound
+	}
+	now := time.Now()
+	family.RevokedAt = &now
+	return nil
+}
+
+func (s *InMemoryTokenStore) RevokeUserFamilies(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, family := range s.families {
+		if family.UserID == userID && family.RevokedAt == nil {
+			family.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// InMemorySessionRepository is an in-memory implementation of session.Repository for testing
+type InMemorySessionRepository struct {
+	mu       sync.Mutex
+	sessions map[string]*session.Session
+}
+
+func NewInMemorySessionRepository() *InMemorySessionRepository {
+	return &InMemorySessionRepository{sessions: make(map[string]*session.Session)}
+}
+
+func (r *InMemorySessionRepository) Store(ctx context.Context, sess *session.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *sess
+	r.sessions[sess.ID] = &stored
+	return nil
+}
+
+func (r *InMemorySessionRepository) GetByID(ctx context.Context, sessionID string) (*session.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sess, ok := r.sessions[sessionID]
+	if !ok {
+		return nil, session.ErrSessionNotFound
+	}
+	result := *sess
+	return &result, nil
+}
+
+func (r *InMemorySessionRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*session.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []*session.Session
+	for _, sess := range r.sessions {
+		if sess.UserID == userID {
+			result := *sess
+			sessions = append(sessions, &result)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *InMemorySessionRepository) Update(ctx context.Context, sess *session.Session) error {
+	return r.Store(ctx, sess)
+}
+
+func (r *InMemorySessionRepository) Delete(ctx context.Context, sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, sessionID)
+	return nil
+}
+
+func (r *InMemorySessionRepository) DeleteExpired(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, sess := range r.sessions {
+		if sess.IsExpired() {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (r *InMemorySessionRepository) UpdateLastActivity(ctx context.Context, sessionID string, lastActivity time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sess, ok := r.sessions[sessionID]
+	if !ok {
+		return session.ErrSessionNotFound
+	}
+	sess.LastActivity = lastActivity
+	return nil
+}
+
+// MockAuditService is a mock implementation of audit.AuditService for testing.
+// Only Log is mocked; calling any other method panics.
+type MockAuditService struct {
+	mock.Mock
+	audit.AuditService
+}
+
+func (m *MockAuditService) Log(ctx context.Context, req *audit.CreateLogRequest) (*audit.LogEntry, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*audit.LogEntry), args.Error(1)
+}
+
+// MockMailSender is a mock implementation of mail.Sender for testing
+type MockMailSender struct {
+	mock.Mock
+}
+
+func (m *MockMailSender) Send(ctx context.Context, msg *mail.Message) error {
+	args := m.Called(ctx, msg)
+	return args.Error(0)
+}
+
+// InMemoryOAuthClientRepository is an in-memory implementation of oauth.ClientRepository for testing
+type InMemoryOAuthClientRepository struct {
+	mu      sync.Mutex
+	clients map[string]*oauth.Client
+}
+
+func NewInMemoryOAuthClientRepository() *InMemoryOAuthClientRepository {
+	return &InMemoryOAuthClientRepository{clients: make(map[string]*oauth.Client)}
+}
+
+func (r *InMemoryOAuthClientRepository) Create(ctx context.Context, client *oauth.Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *client
+	r.clients[client.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryOAuthClientRepository) GetByID(ctx context.Context, clientID string) (*oauth.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[clientID]
+	if !ok {
+		return nil, oauth.ErrClientNotFound
+	}
+	result := *client
+	return &result, nil
+}
+
+func (r *InMemoryOAuthClientRepository) List(ctx context.Context) ([]*oauth.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var clients []*oauth.Client
+	for _, client := range r.clients {
+		result := *client
+		clients = append(clients, &result)
+	}
+	return clients, nil
+}
+
+func (r *InMemoryOAuthClientRepository) Delete(ctx context.Context, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[clientID]; !ok {
+		return oauth.ErrClientNotFound
+	}
+	delete(r.clients, clientID)
+	return nil
+}
+
+// InMemoryOAuthCodeRepository is an in-memory implementation of oauth.AuthorizationCodeRepository for testing
+type InMemoryOAuthCodeRepository struct {
+	mu    sync.Mutex
+	codes map[string]*oauth.AuthorizationCode
+}
+
+func NewInMemoryOAuthCodeRepository() *InMemoryOAuthCodeRepository {
+	return &InMemoryOAuthCodeRepository{codes: make(map[string]*oauth.AuthorizationCode)}
+}
+
+func (r *InMemoryOAuthCodeRepository) Create(ctx context.Context, code *oauth.AuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *code
+	r.codes[code.CodeHash] = &stored
+	return nil
+}
+
+func (r *InMemoryOAuthCodeRepository) Consume(ctx context.Context, codeHash string) (*oauth.AuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	code, ok := r.codes[codeHash]
+	if !ok {
+		return nil, oauth.ErrAuthorizationCodeNotFound
+	}
+	if code.UsedAt != nil {
+		result := *code
+		return &result, oauth.ErrAuthorizationCodeUsed
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	result := *code
+	return &result, nil
+}
+
+func (r *InMemoryOAuthCodeRepository) SetFamily(ctx context.Context, codeHash, familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	code, ok := r.codes[codeHash]
+	if !ok {
+		return oauth.ErrAuthorizationCodeNotFound
+	}
+	code.FamilyID = familyID
+	return nil
+}
+
+// InMemoryOAuthConsentRepository is an in-memory implementation of oauth.ConsentRepository for testing
+type InMemoryOAuthConsentRepository struct {
+	mu       sync.Mutex
+	consents map[string]*oauth.Consent
+}
+
+func NewInMemoryOAuthConsentRepository() *InMemoryOAuthConsentRepository {
+	return &InMemoryOAuthConsentRepository{consents: make(map[string]*oauth.Consent)}
+}
+
+func (r *InMemoryOAuthConsentRepository) Get(ctx context.Context, userID uuid.UUID, clientID string) (*oauth.Consent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	consent, ok := r.consents[userID.String()+"/"+clientID]
+	if !ok {
+		return nil, oauth.ErrConsentNotFound
+	}
+	result := *consent
+	return &result, nil
+}
+
+func (r *InMemoryOAuthConsentRepository) Save(ctx context.Context, consent *oauth.Consent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *consent
+	r.consents[consent.UserID.String()+"/"+consent.ClientID] = &stored
+	return nil
+}
+
+func (r *InMemoryOAuthConsentRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*oauth.Consent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var consents []*oauth.Consent
+	for _, consent := range r.consents {
+		if consent.UserID == userID {
+			result := *consent
+			consents = append(consents, &result)
+		}
+	}
+	return consents, nil
+}
+
+func (r *InMemoryOAuthConsentRepository) Delete(ctx context.Context, userID uuid.UUID, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := userID.String() + "/" + clientID
+	if _, ok := r.consents[key]; !ok {
+		return oauth.ErrConsentNotFound
+	}
+	delete(r.consents, key)
+	return nil
+}
+
+// InMemoryUserRepository is an in-memory implementation of user.Repository for testing
+type InMemoryUserRepository struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]*user.User
+}
+
+func NewInMemoryUserRepository() *InMemoryUserRepository {
+	return &InMemoryUserRepository{users: make(map[uuid.UUID]*user.User)}
+}
+
+func (r *InMemoryUserRepository) Create(ctx context.Context, u *user.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.users {
+		if existing.Email == u.Email {
+			return user.ErrEmailAlreadyExists
+		}
+		if existing.Username == u.Username {
+			return user.ErrUsernameAlreadyExists
+		}
+	}
+	stored := *u
+	r.users[u.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*user.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return nil, user.ErrUserNotFound
+	}
+	result := *u
+	return &result, nil
+}
+
+func (r *InMemoryUserRepository) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	return r.find(func(u *user.User) bool { return u.Email == email })
+}
+
+func (r *InMemoryUserRepository) GetByUsername(ctx context.Context, username string) (*user.User, error) {
+	return r.find(func(u *user.User) bool { return u.Username == username })
+}
+
+func (r *InMemoryUserRepository) Update(ctx context.Context, u *user.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[u.ID]; !ok {
+		return user.ErrUserNotFound
+	}
+	stored := *u
+	r.users[u.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[id]; !ok {
+		return user.ErrUserNotFound
+	}
+	delete(r.users, id)
+	return nil
+}
+
+func (r *InMemoryUserRepository) List(ctx context.Context, filter user.ListFilter) ([]*user.User, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []*user.User
+	for _, u := range r.users {
+		result := *u
+		users = append(users, &result)
+	}
+	return users, int64(len(users)), nil
+}
+
+func (r *InMemoryUserRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID, loginTime time.Time) error {
+	return r.modify(id, func(u *user.User) {
+		u.LastLoginAt = &loginTime
+		u.FailedLoginAttempts = 0
+		u.LockedUntil = nil
+	})
+}
+
+func (r *InMemoryUserRepository) IncrementFailedLoginAttempts(ctx context.Context, id uuid.UUID) (int, error) {
+	var attempts int
+	err := r.modify(id, func(u *user.User) {
+		u.FailedLoginAttempts++
+		attempts = u.FailedLoginAttempts
+	})
+	return attempts, err
+}
+
+func (r *InMemoryUserRepository) Lock(ctx context.Context, id uuid.UUID, until time.Time) error {
+	return r.modify(id, func(u *user.User) { u.LockedUntil = &until })
+}
+
+func (r *InMemoryUserRepository) Unlock(ctx context.Context, id uuid.UUID) error {
+	return r.modify(id, func(u *user.User) {
+		u.FailedLoginAttempts = 0
+		u.LockedUntil = nil
+	})
+}
+
+func (r *InMemoryUserRepository) UpdateMFA(ctx context.Context, id uuid.UUID, enabled bool, secret string, backupCodes []string) error {
+	return r.modify(id, func(u *user.User) {
+		u.MFAEnabled = enabled
+		u.MFASecret = secret
+		u.MFABackupCodes = backupCodes
+	})
+}
+
+func (r *InMemoryUserRepository) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	_, err := r.GetByEmail(ctx, email)
+	return err == nil, nil
+}
+
+func (r *InMemoryUserRepository) ExistsByUsername(ctx context.Context, username string) (bool, error) {
+	_, err := r.GetByUsername(ctx, username)
+	return err == nil, nil
+}
+
+func (r *InMemoryUserRepository) EachPasswordHash(ctx context.Context, fn func(hash string) error) error {
+	r.mu.Lock()
+	hashes := make([]string, 0, len(r.users))
+	for _, u := range r.users {
+		if u.PasswordHash != "" {
+			hashes = append(hashes, u.PasswordHash)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, hash := range hashes {
+		if err := fn(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryUserRepository) find(match func(*user.User) bool) (*user.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if match(u) {
+			result := *u
+			return &result, nil
+		}
+	}
+	return nil, user.ErrUserNotFound
+}
+
+func (r *InMemoryUserRepository) modify(id uuid.UUID, fn func(*user.User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return user.ErrUserNotFound
+	}
+	fn(u)
+	return nil
+}
+
+// InMemoryIdentityRepository is an in-memory implementation of federation.IdentityRepository for testing
+type InMemoryIdentityRepository struct {
+	mu         sync.Mutex
+	identities map[uuid.UUID]*federation.Identity
+}
+
+func NewInMemoryIdentityRepository() *InMemoryIdentityRepository {
+	return &InMemoryIdentityRepository{identities: make(map[uuid.UUID]*federation.Identity)}
+}
+
+func (r *InMemoryIdentityRepository) Create(ctx context.Context, identity *federation.Identity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.identities {
+		if existing.ProviderID == identity.ProviderID && existing.Subject == identity.Subject {
+			return federation.ErrIdentityAlreadyLinked
+		}
+	}
+	stored := *identity
+	r.identities[identity.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryIdentityRepository) GetByProviderSubject(ctx context.Context, providerID, subject string) (*federation.Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, identity := range r.identities {
+		if identity.ProviderID == providerID && identity.Subject == subject {
+			result := *identity
+			return &result, nil
+		}
+	}
+	return nil, federation.ErrIdentityNotFound
+}
+
+func (r *InMemoryIdentityRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*federation.Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var identities []*federation.Identity
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			result := *identity
+			identities = append(identities, &result)
+		}
+	}
+	return identities, nil
+}
+
+func (r *InMemoryIdentityRepository) Delete(ctx context.Context, userID, identityID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identity, ok := r.identities[identityID]
+	if !ok || identity.UserID != userID {
+		return federation.ErrIdentityNotFound
+	}
+	delete(r.identities, identityID)
+	return nil
+}
+
+func (r *InMemoryIdentityRepository) UpdateLastLogin(ctx context.Context, identityID uuid.UUID, loginTime time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if identity, ok := r.identities[identityID]; ok {
+		identity.LastLoginAt = &loginTime
+	}
+	return nil
+}
+
+// InMemoryAPIKeyRepository is an in-memory implementation of apikey.Repository for testing
+type InMemoryAPIKeyRepository struct {
+	mu   sync.Mutex
+	keys map[uuid.UUID]*apikey.APIKey
+}
+
+func NewInMemoryAPIKeyRepository() *InMemoryAPIKeyRepository {
+	return &InMemoryAPIKeyRepository{keys: make(map[uuid.UUID]*apikey.APIKey)}
+}
+
+func (r *InMemoryAPIKeyRepository) Create(ctx context.Context, key *apikey.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *key
+	r.keys[key.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryAPIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*apikey.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, apikey.ErrAPIKeyNotFound
+	}
+	result := *key
+	return &result, nil
+}
+
+func (r *InMemoryAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*apikey.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range r.keys {
+		if key.KeyHash == keyHash {
+			result := *key
+			return &result, nil
+		}
+	}
+	return nil, apikey.ErrAPIKeyNotFound
+}
+
+func (r *InMemoryAPIKeyRepository) ListByOwner(ctx context.Context, ownerType apikey.OwnerType, ownerID uuid.UUID) ([]*apikey.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var keys []*apikey.APIKey
+	for _, key := range r.keys {
+		if key.OwnerType == ownerType && key.OwnerID == ownerID {
+			result := *key
+			keys = append(keys, &result)
+		}
+	}
+	return keys, nil
+}
+
+func (r *InMemoryAPIKeyRepository) CountActiveByOwner(ctx context.Context, ownerType apikey.OwnerType, ownerID uuid.UUID) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, key := range r.keys {
+		if key.OwnerType == ownerType && key.OwnerID == ownerID && key.IsActive() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *InMemoryAPIKeyRepository) Revoke(ctx context.Context, id uuid.UUID, revokedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[id]
+	if !ok || key.RevokedAt != nil {
+		return apikey.ErrAPIKeyNotFound
+	}
+	key.RevokedAt = &revokedAt
+	return nil
+}
+
+func (r *InMemoryAPIKeyRepository) UpdateLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time, ip string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if key, ok := r.keys[id]; ok {
+		key.LastUsedAt = &usedAt
+		key.LastUsedIP = ip
+	}
+	return nil
+}
+
+// InMemoryServiceAccountRepository is an in-memory implementation of serviceaccount.Repository for testing
+type InMemoryServiceAccountRepository struct {
+	mu       sync.Mutex
+	accounts map[uuid.UUID]*serviceaccount.ServiceAccount
+}
+
+func NewInMemoryServiceAccountRepository() *InMemoryServiceAccountRepository {
+	return &InMemoryServiceAccountRepository{accounts: make(map[uuid.UUID]*serviceaccount.ServiceAccount)}
+}
+
+func (r *InMemoryServiceAccountRepository) copyOf(account *serviceaccount.ServiceAccount) *serviceaccount.ServiceAccount {
+	result := *account
+	result.Roles = append([]string{}, account.Roles...)
+	return &result
+}
+
+func (r *InMemoryServiceAccountRepository) Create(ctx context.Context, account *serviceaccount.ServiceAccount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.accounts {
+		if existing.Name == account.Name {
+			return serviceaccount.ErrServiceAccountExists
+		}
+	}
+	stored := r.copyOf(account)
+	stored.Roles = nil
+	r.accounts[account.ID] = stored
+	return nil
+}
+
+func (r *InMemoryServiceAccountRepository) GetByID(ctx context.Context, id uuid.UUID) (*serviceaccount.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[id]
+	if !ok {
+		return nil, serviceaccount.ErrServiceAccountNotFound
+	}
+	return r.copyOf(account), nil
+}
+
+func (r *InMemoryServiceAccountRepository) GetByClientID(ctx context.Context, clientID string) (*serviceaccount.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, account := range r.accounts {
+		if account.ClientID == clientID {
+			return r.copyOf(account), nil
+		}
+	}
+	return nil, serviceaccount.ErrServiceAccountNotFound
+}
+
+func (r *InMemoryServiceAccountRepository) List(ctx context.Context) ([]*serviceaccount.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var accounts []*serviceaccount.ServiceAccount
+	for _, account := range r.accounts {
+		accounts = append(accounts, r.copyOf(account))
+	}
+	return accounts, nil
+}
+
+func (r *InMemoryServiceAccountRepository) Update(ctx context.Context, account *serviceaccount.ServiceAccount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.accounts[account.ID]
+	if !ok {
+		return serviceaccount.ErrServiceAccountNotFound
+	}
+	for id, existing := range r.accounts {
+		if id != account.ID && existing.Name == account.Name {
+			return serviceaccount.ErrServiceAccountExists
+		}
+	}
+	stored.Name = account.Name
+	stored.Description = account.Description
+	stored.Status = account.Status
+	stored.SecretHash = account.SecretHash
+	stored.SecretRotatedAt = account.SecretRotatedAt
+	stored.UpdatedAt = account.UpdatedAt
+	return nil
+}
+
+func (r *InMemoryServiceAccountRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.accounts[id]; !ok {
+		return serviceaccount.ErrServiceAccountNotFound
+	}
+	delete(r.accounts, id)
+	return nil
+}
+
+func (r *InMemoryServiceAccountRepository) AssignRole(ctx context.Context, assignment *serviceaccount.RoleAssignment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[assignment.ServiceAccountID]
+	if !ok {
+		return serviceaccount.ErrServiceAccountNotFound
+	}
+	if account.HasRole(assignment.Role) {
+		return serviceaccount.ErrRoleAlreadyAssigned
+	}
+	account.Roles = append(account.Roles, assignment.Role)
+	return nil
+}
+
+func (r *InMemoryServiceAccountRepository) RemoveRole(ctx context.Context, id uuid.UUID, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[id]
+	if !ok || !account.HasRole(role) {
+		return serviceaccount.ErrRoleNotAssigned
+	}
+	roles := account.Roles[:0]
+	for _, r := range account.Roles {
+		if r != role {
+			roles = append(roles, r)
+		}
+	}
+	account.Roles = roles
+	return nil
+}
+
+func (r *InMemoryServiceAccountRepository) UpdateLastAuthenticated(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if account, ok := r.accounts[id]; ok {
+		account.LastAuthenticatedAt = &at
+	}
+	return nil
+}
+
+// InMemoryWebAuthnCredentialRepository is an in-memory implementation of webauthn.CredentialRepository for testing
+type InMemoryWebAuthnCredentialRepository struct {
+	mu          sync.Mutex
+	credentials map[uuid.UUID]*webauthn.Credential
+}
+
+func NewInMemoryWebAuthnCredentialRepository() *InMemoryWebAuthnCredentialRepository {
+	return &InMemoryWebAuthnCredentialRepository{credentials: make(map[uuid.UUID]*webauthn.Credential)}
+}
+
+func (r *InMemoryWebAuthnCredentialRepository) Create(ctx context.Context, credential *webauthn.Credential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.credentials {
+		if string(existing.CredentialID) == string(credential.CredentialID) {
+			return webauthn.ErrCredentialExists
+		}
+	}
+	stored := *credential
+	r.credentials[credential.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryWebAuthnCredentialRepository) GetByCredentialID(ctx context.Context, credentialID []byte) (*webauthn.Credential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, credential := range r.credentials {
+		if string(credential.CredentialID) == string(credentialID) {
+			result := *credential
+			return &result, nil
+		}
+	}
+	return nil, webauthn.ErrCredentialNotFound
+}
+
+func (r *InMemoryWebAuthnCredentialRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*webauthn.Credential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var credentials []*webauthn.Credential
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			result := *credential
+			credentials = append(credentials, &result)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *InMemoryWebAuthnCredentialRepository) Rename(ctx context.Context, userID, id uuid.UUID, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credential, ok := r.credentials[id]
+	if !ok || credential.UserID != userID {
+		return webauthn.ErrCredentialNotFound
+	}
+	credential.Name = name
+	return nil
+}
+
+func (r *InMemoryWebAuthnCredentialRepository) UpdateSignCount(ctx context.Context, id uuid.UUID, signCount uint32, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if credential, ok := r.credentials[id]; ok {
+		credential.SignCount = signCount
+		credential.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+func (r *InMemoryWebAuthnCredentialRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credential, ok := r.credentials[id]
+	if !ok || credential.UserID != userID {
+		return webauthn.ErrCredentialNotFound
+	}
+	delete(r.credentials, id)
+	return nil
+}
+
+// InMemoryWebAuthnCeremonyRepository is an in-memory implementation of webauthn.CeremonyRepository for testing
+type InMemoryWebAuthnCeremonyRepository struct {
+	mu         sync.Mutex
+	ceremonies map[uuid.UUID]*webauthn.Ceremony
+}
+
+func NewInMemoryWebAuthnCeremonyRepository() *InMemoryWebAuthnCeremonyRepository {
+	return &InMemoryWebAuthnCeremonyRepository{ceremonies: make(map[uuid.UUID]*webauthn.Ceremony)}
+}
+
+func (r *InMemoryWebAuthnCeremonyRepository) Create(ctx context.Context, ceremony *webauthn.Ceremony) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *ceremony
+	r.ceremonies[ceremony.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryWebAuthnCeremonyRepository) Consume(ctx context.Context, id uuid.UUID) (*webauthn.Ceremony, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ceremony, ok := r.ceremonies[id]
+	if !ok {
+		return nil, webauthn.ErrCeremonyNotFound
+	}
+	delete(r.ceremonies, id)
+	return ceremony, nil
+}
+
+func (r *InMemoryWebAuthnCeremonyRepository) DeleteExpired(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, ceremony := range r.ceremonies {
+		if ceremony.IsExpired() {
+			delete(r.ceremonies, id)
+		}
+	}
+	return nil
+}
+
+// InMemoryMagicLinkRepository is an in-memory implementation of auth.MagicLinkRepository for testing
+type InMemoryMagicLinkRepository struct {
+	mu    sync.Mutex
+	links map[uuid.UUID]*auth.MagicLink
+}
+
+func NewInMemoryMagicLinkRepository() *InMemoryMagicLinkRepository {
+	return &InMemoryMagicLinkRepository{links: make(map[uuid.UUID]*auth.MagicLink)}
+}
+
+func (r *InMemoryMagicLinkRepository) Create(ctx context.Context, link *auth.MagicLink) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *link
+	r.links[link.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryMagicLinkRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*auth.MagicLink, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, link := range r.links {
+		if link.TokenHash == tokenHash {
+			result := *link
+			return &result, nil
+		}
+	}
+	return nil, auth.ErrInvalidMagicLink
+}
+
+func (r *InMemoryMagicLinkRepository) Consume(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.links[id]; !ok {
+		return auth.ErrInvalidMagicLink
+	}
+	delete(r.links, id)
+	return nil
+}
+
+func (r *InMemoryMagicLinkRepository) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, link := range r.links {
+		if link.UserID == userID {
+			delete(r.links, id)
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryMagicLinkRepository) DeleteExpired(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, link := range r.links {
+		if link.IsExpired() {
+			delete(r.links, id)
+		}
+	}
+	return nil
+}
+
+// expire moves every stored link past its expiry
+func (r *InMemoryMagicLinkRepository) expire() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, link := range r.links {
+		link.ExpiresAt = time.Now().Add(-time.Minute)
+	}
+}
+
+// InMemoryRateLimiter is an in-memory sliding window implementation of ratelimit.RateLimiter for testing
+type InMemoryRateLimiter struct {
+	mu     sync.Mutex
+	events map[string][]time.Time
+}
+
+func NewInMemoryRateLimiter() *InMemoryRateLimiter {
+	return &InMemoryRateLimiter{events: make(map[string][]time.Time)}
+}
+
+func (l *InMemoryRateLimiter) Check(ctx context.Context, key string, limit int, window time.Duration) (*ratelimit.RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := l.status(key, limit, window)
+	if result.Allowed {
+		l.events[key] = append(l.events[key], time.Now())
+		result.Remaining--
+	}
+	return result, nil
+}
+
+func (l *InMemoryRateLimiter) Reset(ctx context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.events, key)
+	return nil
+}
+
+func (l *InMemoryRateLimiter) GetStatus(ctx context.Context, key string, limit int, window time.Duration) (*ratelimit.RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.status(key, limit, window), nil
+}
+
+// expire moves every recorded event back by d
+func (l *InMemoryRateLimiter) expire(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, events := range l.events {
+		for i := range events {
+			events[i] = events[i].Add(-d)
+		}
+	}
+}
+
+func (l *InMemoryRateLimiter) status(key string, limit int, window time.Duration) *ratelimit.RateLimitResult {
+	now := time.Now()
+	var current []time.Time
+	for _, at := range l.events[key] {
+		if at.After(now.Add(-window)) {
+			current = append(current, at)
+		}
+	}
+	l.events[key] = current
+
+	resetTime := now.Add(window)
+	if len(current) > 0 {
+		resetTime = current[0].Add(window)
+	}
+
+	result := &ratelimit.RateLimitResult{
+		Allowed:   len(current) < limit,
+		Limit:     limit,
+		Remaining: limit - len(current),
+		ResetTime: resetTime,
+	}
+	if !result.Allowed {
+		result.Remaining = 0
+		result.RetryAfter = time.Until(resetTime)
+	}
+	return result
+}
+
+// InMemoryLoginHistoryRepository is an in-memory implementation of risk.LoginHistoryRepository for testing
+type InMemoryLoginHistoryRepository struct {
+	mu     sync.Mutex
+	events []*risk.LoginEvent
+}
+
+func NewInMemoryLoginHistoryRepository() *InMemoryLoginHistoryRepository {
+	return &InMemoryLoginHistoryRepository{}
+}
+
+func (r *InMemoryLoginHistoryRepository) Create(ctx context.Context, event *risk.LoginEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *event
+	r.events = append(r.events, &stored)
+	return nil
+}
+
+func (r *InMemoryLoginHistoryRepository) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]*risk.LoginEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []*risk.LoginEvent
+	for i := len(r.events) - 1; i >= 0 && len(events) < limit; i-- {
+		if r.events[i].UserID == userID {
+			event := *r.events[i]
+			events = append(events, &event)
+		}
+	}
+	return events, nil
+}
+
+// StaticLocator is a risk.Locator backed by a fixed table of addresses for testing
+type StaticLocator map[string]*risk.Location
+
+func (l StaticLocator) Locate(ip string) (*risk.Location, error) {
+	location, ok := l[ip]
+	if !ok {
+		return nil, risk.ErrLocationNotFound
+	}
+	stored := *location
+	return &stored, nil
+}
+
+// InMemoryTrustedDeviceRepository is an in-memory implementation of mfa.TrustedDeviceRepository for testing
+type InMemoryTrustedDeviceRepository struct {
+	mu      sync.Mutex
+	devices map[uuid.UUID]*mfa.TrustedDevice
+}
+
+func NewInMemoryTrustedDeviceRepository() *InMemoryTrustedDeviceRepository {
+	return &InMemoryTrustedDeviceRepository{devices: make(map[uuid.UUID]*mfa.TrustedDevice)}
+}
+
+func (r *InMemoryTrustedDeviceRepository) Create(ctx context.Context, device *mfa.TrustedDevice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *device
+	r.devices[device.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryTrustedDeviceRepository) GetByID(ctx context.Context, id uuid.UUID) (*mfa.TrustedDevice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	device, ok := r.devices[id]
+	if !ok {
+		return nil, mfa.ErrTrustedDeviceNotFound
+	}
+	found := *device
+	return &found, nil
+}
+
+func (r *InMemoryTrustedDeviceRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*mfa.TrustedDevice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var devices []*mfa.TrustedDevice
+	for _, device := range r.devices {
+		if device.UserID == userID {
+			found := *device
+			devices = append(devices, &found)
+		}
+	}
+	return devices, nil
+}
+
+func (r *InMemoryTrustedDeviceRepository) UpdateLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if device, ok := r.devices[id]; ok {
+		device.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+func (r *InMemoryTrustedDeviceRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	device, ok := r.devices[id]
+	if !ok || device.UserID != userID {
+		return mfa.ErrTrustedDeviceNotFound
+	}
+	delete(r.devices, id)
+	return nil
+}
+
+func (r *InMemoryTrustedDeviceRepository) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, device := range r.devices {
+		if device.UserID == userID {
+			delete(r.devices, id)
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryTrustedDeviceRepository) DeleteExpired(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, device := range r.devices {
+		if device.IsExpired() {
+			delete(r.devices, id)
+		}
+	}
+	return nil
+}
+
+// expire makes every device's trust lapse
+func (r *InMemoryTrustedDeviceRepository) expire() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, device := range r.devices {
+		device.ExpiresAt = time.Now().Add(-time.Minute)
+	}
+}
+
+// InMemorySAMLConnectionRepository is an in-memory implementation of saml.ConnectionRepository for testing
+type InMemorySAMLConnectionRepository struct {
+	mu          sync.Mutex
+	connections map[uuid.UUID]*saml.Connection
+}
+
+func NewInMemorySAMLConnectionRepository() *InMemorySAMLConnectionRepository {
+	return &InMemorySAMLConnectionRepository{connections: make(map[uuid.UUID]*saml.Connection)}
+}
+
+func (r *InMemorySAMLConnectionRepository) Create(ctx context.Context, connection *saml.Connection) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *connection
+	r.connections[connection.OrganizationID] = &stored
+	return nil
+}
+
+func (r *InMemorySAMLConnectionRepository) GetByOrganization(ctx context.Context, organizationID uuid.UUID) (*saml.Connection, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	connection, ok := r.connections[organizationID]
+	if !ok {
+		return nil, saml.ErrConnectionNotFound
+	}
+	result := *connection
+	return &result, nil
+}
+
+func (r *InMemorySAMLConnectionRepository) Update(ctx context.Context, connection *saml.Connection) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.connections[connection.OrganizationID]; !ok {
+		return saml.ErrConnectionNotFound
+	}
+	stored := *connection
+	r.connections[connection.OrganizationID] = &stored
+	return nil
+}
+
+func (r *InMemorySAMLConnectionRepository) Delete(ctx context.Context, organizationID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.connections[organizationID]; !ok {
+		return saml.ErrConnectionNotFound
+	}
+	delete(r.connections, organizationID)
+	return nil
+}
+
+// InMemoryAssertionCache is an in-memory implementation of saml.AssertionCache for testing
+type InMemoryAssertionCache struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func NewInMemoryAssertionCache() *InMemoryAssertionCache {
+	return &InMemoryAssertionCache{used: make(map[string]time.Time)}
+}
+
+func (c *InMemoryAssertionCache) Use(ctx context.Context, issuer, assertionID string, expiresAt time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := issuer + " " + assertionID
+	if _, ok := c.used[key]; ok {
+		return saml.ErrAssertionReplayed
+	}
+	c.used[key] = expiresAt
+	return nil
+}
+
+// InMemorySCIMTokenRepository is an in-memory implementation of scim.TokenRepository for testing
+type InMemorySCIMTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]*scim.Token
+}
+
+func NewInMemorySCIMTokenRepository() *InMemorySCIMTokenRepository {
+	return &InMemorySCIMTokenRepository{tokens: make(map[uuid.UUID]*scim.Token)}
+}
+
+func (r *InMemorySCIMTokenRepository) Create(ctx context.Context, token *scim.Token) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *token
+	r.tokens[token.ID] = &stored
+	return nil
+}
+
+func (r *InMemorySCIMTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*scim.Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			result := *token
+			return &result, nil
+		}
+	}
+	return nil, scim.ErrTokenNotFound
+}
+
+func (r *InMemorySCIMTokenRepository) ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*scim.Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tokens []*scim.Token
+	for _, token := range r.tokens {
+		if token.OrganizationID == organizationID {
+			result := *token
+			tokens = append(tokens, &result)
+		}
+	}
+	return tokens, nil
+}
+
+func (r *InMemorySCIMTokenRepository) Revoke(ctx context.Context, organizationID, id uuid.UUID, revokedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[id]
+	if !ok || token.OrganizationID != organizationID {
+		return scim.ErrTokenNotFound
+	}
+	if token.RevokedAt == nil {
+		token.RevokedAt = &revokedAt
+	}
+	return nil
+}
+
+func (r *InMemorySCIMTokenRepository) UpdateLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if token, ok := r.tokens[id]; ok {
+		token.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+// InMemorySCIMAccountRepository is an in-memory implementation of scim.AccountRepository for testing
+type InMemorySCIMAccountRepository struct {
+	mu       sync.Mutex
+	accounts []*scim.Account
+}
+
+func NewInMemorySCIMAccountRepository() *InMemorySCIMAccountRepository {
+	return &InMemorySCIMAccountRepository{}
+}
+
+func (r *InMemorySCIMAccountRepository) Create(ctx context.Context, account *scim.Account) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.accounts {
+		if existing.OrganizationID == account.OrganizationID &&
+			(existing.UserID == account.UserID || strings.EqualFold(existing.UserName, account.UserName)) {
+			return scim.ErrUniqueness
+		}
+	}
+	stored := *account
+	r.accounts = append(r.accounts, &stored)
+	return nil
+}
+
+func (r *InMemorySCIMAccountRepository) Get(ctx context.Context, organizationID, userID uuid.UUID) (*scim.Account, error) {
+	return r.find(func(a *scim.Account) bool { return a.OrganizationID == organizationID && a.UserID == userID })
+}
+
+func (r *InMemorySCIMAccountRepository) GetByUserName(ctx context.Context, organizationID uuid.UUID, userName string) (*scim.Account, error) {
+	return r.find(func(a *scim.Account) bool {
+		return a.OrganizationID == organizationID && strings.EqualFold(a.UserName, userName)
+	})
+}
+
+func (r *InMemorySCIMAccountRepository) ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*scim.Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var accounts []*scim.Account
+	for _, account := range r.accounts {
+		if account.OrganizationID == organizationID {
+			result := *account
+			accounts = append(accounts, &result)
+		}
+	}
+	return accounts, nil
+}
+
+func (r *InMemorySCIMAccountRepository) Update(ctx context.Context, account *scim.Account) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.accounts {
+		if existing.OrganizationID == account.OrganizationID && existing.UserID == account.UserID {
+			stored := *account
+			r.accounts[i] = &stored
+			return nil
+		}
+	}
+	return scim.ErrResourceNotFound
+}
+
+func (r *InMemorySCIMAccountRepository) find(match func(*scim.Account) bool) (*scim.Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, account := range r.accounts {
+		if match(account) {
+			result := *account
+			return &result, nil
+		}
+	}
+	return nil, scim.ErrResourceNotFound
+}
+
+// InMemorySCIMGroupRepository is an in-memory implementation of scim.GroupRepository for testing
+type InMemorySCIMGroupRepository struct {
+	mu     sync.Mutex
+	groups []*scim.Group
+}
+
+func NewInMemorySCIMGroupRepository() *InMemorySCIMGroupRepository {
+	return &InMemorySCIMGroupRepository{}
+}
+
+func (r *InMemorySCIMGroupRepository) Create(ctx context.Context, group *scim.Group) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.groups = append(r.groups, copySCIMGroup(group))
+	return nil
+}
+
+func (r *InMemorySCIMGroupRepository) GetByID(ctx context.Context, organizationID, id uuid.UUID) (*scim.Group, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, group := range r.groups {
+		if group.OrganizationID == organizationID && group.ID == id {
+			return copySCIMGroup(group), nil
+		}
+	}
+	return nil, scim.ErrResourceNotFound
+}
+
+func (r *InMemorySCIMGroupRepository) ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*scim.Group, error) {
+	return r.list(func(g *scim.Group) bool { return g.OrganizationID == organizationID }), nil
+}
+
+func (r *InMemorySCIMGroupRepository) ListByMember(ctx context.Context, organizationID, userID uuid.UUID) ([]*scim.Group, error) {
+	return r.list(func(g *scim.Group) bool { return g.OrganizationID == organizationID && g.HasMember(userID) }), nil
+}
+
+func (r *InMemorySCIMGroupRepository) Update(ctx context.Context, group *scim.Group) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.groups {
+		if existing.OrganizationID == group.OrganizationID && existing.ID == group.ID {
+			r.groups[i] = copySCIMGroup(group)
+			return nil
+		}
+	}
+	return scim.ErrResourceNotFound
+}
+
+func (r *InMemorySCIMGroupRepository) Delete(ctx context.Context, organizationID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.groups {
+		if existing.OrganizationID == organizationID && existing.ID == id {
+			r.groups = append(r.groups[:i], r.groups[i+1:]...)
+			return nil
+		}
+	}
+	return scim.ErrResourceNotFound
+}
+
+func (r *InMemorySCIMGroupRepository) list(match func(*scim.Group) bool) []*scim.Group {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var groups []*scim.Group
+	for _, group := range r.groups {
+		if match(group) {
+			groups = append(groups, copySCIMGroup(group))
+		}
+	}
+	return groups
+}
+
+func copySCIMGroup(group *scim.Group) *scim.Group {
+	result := *group
+	result.Members = append([]uuid.UUID(nil), group.Members...)
+	return &result
+}
+
+// InMemoryPasswordHistoryRepository is an in-memory implementation of auth.PasswordHistoryRepository for testing
+type InMemoryPasswordHistoryRepository struct {
+	mu      sync.Mutex
+	entries []*auth.PasswordHistoryEntry
+}
+
+func NewInMemoryPasswordHistoryRepository() *InMemoryPasswordHistoryRepository {
+	return &InMemoryPasswordHistoryRepository{}
+}
+
+func (r *InMemoryPasswordHistoryRepository) Add(ctx context.Context, entry *auth.PasswordHistoryEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *entry
+	r.entries = append(r.entries, &stored)
+	return nil
+}
+
+func (r *InMemoryPasswordHistoryRepository) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]*auth.PasswordHistoryEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var entries []*auth.PasswordHistoryEntry
+	// Entries are appended in order, so the newest are last
+	for i := len(r.entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		if r.entries[i].UserID == userID {
+			entry := *r.entries[i]
+			entries = append(entries, &entry)
+		}
+	}
+	return entries, nil
+}
+
+func (r *InMemoryPasswordHistoryRepository) Prune(ctx context.Context, userID uuid.UUID, keep int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var kept []*auth.PasswordHistoryEntry
+	count := 0
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		if r.entries[i].UserID == userID {
+			if count >= keep {
+				continue
+			}
+			count++
+		}
+		kept = append([]*auth.PasswordHistoryEntry{r.entries[i]}, kept...)
+	}
+	r.entries = kept
+	return nil
+}
+
+// InMemoryPasswordExpiryRepository is an in-memory implementation of auth.PasswordExpiryRepository for testing
+type InMemoryPasswordExpiryRepository struct {
+	mu      sync.Mutex
+	notices []*auth.PasswordExpiryNotice
+	warned  map[uuid.UUID]time.Time
+}
+
+func NewInMemoryPasswordExpiryRepository(notices ...*auth.PasswordExpiryNotice) *InMemoryPasswordExpiryRepository {
+	return &InMemoryPasswordExpiryRepository{notices: notices, warned: make(map[uuid.UUID]time.Time)}
+}
+
+func (r *InMemoryPasswordExpiryRepository) ClaimUnwarned(ctx context.Context, from, to time.Time, limit int) ([]*auth.PasswordExpiryNotice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var notices []*auth.PasswordExpiryNotice
+	for _, notice := range r.notices {
+		if len(notices) >= limit {
+			break
+		}
+		if notice.SetAt.Before(from) || notice.SetAt.After(to) {
+			continue
+		}
+		if warnedFor, ok := r.warned[notice.UserID]; ok && warnedFor.Equal(notice.SetAt) {
+			continue
+		}
+		r.warned[notice.UserID] = notice.SetAt
+		result := *notice
+		notices = append(notices, &result)
+	}
+	return notices, nil
+}
+
+func (r *InMemoryPasswordExpiryRepository) ReleaseWarning(ctx context.Context, userID uuid.UUID, setAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if warnedFor, ok := r.warned[userID]; ok && warnedFor.Equal(setAt) {
+		delete(r.warned, userID)
+	}
+	return nil
+}
+
+// InMemoryOrganizationRepository is an in-memory implementation of organization.Repository for testing
+type InMemoryOrganizationRepository struct {
+	mu   sync.Mutex
+	orgs map[uuid.UUID]*organization.Organization
+}
+
+func NewInMemoryOrganizationRepository() *InMemoryOrganizationRepository {
+	return &InMemoryOrganizationRepository{orgs: make(map[uuid.UUID]*organization.Organization)}
+}
+
+func (r *InMemoryOrganizationRepository) Create(ctx context.Context, org *organization.Organization) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.orgs {
+		if existing.Slug == org.Slug {
+			return organization.ErrSlugTaken
+		}
+	}
+	stored := *org
+	r.orgs[org.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryOrganizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*organization.Organization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	org, ok := r.orgs[id]
+	if !ok {
+		return nil, organization.ErrOrganizationNotFound
+	}
+	result := *org
+	return &result, nil
+}
+
+func (r *InMemoryOrganizationRepository) Update(ctx context.Context, org *organization.Organization) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.orgs[org.ID]; !ok {
+		return organization.ErrOrganizationNotFound
+	}
+	for _, existing := range r.orgs {
+		if existing.ID != org.ID && existing.Slug == org.Slug {
+			return organization.ErrSlugTaken
+		}
+	}
+	stored := *org
+	r.orgs[org.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryOrganizationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.orgs[id]; !ok {
+		return organization.ErrOrganizationNotFound
+	}
+	delete(r.orgs, id)
+	return nil
+}
+
+// InMemoryMembershipRepository is an in-memory implementation of organization.MembershipRepository
+// for testing. Memberships of organizations deleted from orgs are dropped, like the database cascade.
+type InMemoryMembershipRepository struct {
+	mu          sync.Mutex
+	orgs        *InMemoryOrganizationRepository
+	memberships []*organization.Membership
+
+	// invitations, when set, are counted as holding seats
+	invitations *InMemoryInvitationRepository
+}
+
+func NewInMemoryMembershipRepository(orgs *InMemoryOrganizationRepository) *InMemoryMembershipRepository {
+	return &InMemoryMembershipRepository{orgs: orgs}
+}
+
+// live returns the memberships of organizations that still exist
+func (r *InMemoryMembershipRepository) live(ctx context.Context) []*organization.Membership {
+	var live []*organization.Membership
+	for _, m := range r.memberships {
+		if _, err := r.orgs.GetByID(ctx, m.OrganizationID); err == nil {
+			live = append(live, m)
+		}
+	}
+	return live
+}
+
+func (r *InMemoryMembershipRepository) Add(ctx context.Context, membership *organization.Membership) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.live(ctx) {
+		if m.OrganizationID == membership.OrganizationID && m.UserID == membership.UserID {
+			return organization.ErrAlreadyMember
+		}
+	}
+	stored := *membership
+	r.memberships = append(r.memberships, &stored)
+	return nil
+}
+
+func (r *InMemoryMembershipRepository) AddWithinSeatLimit(
+	ctx context.Context,
+	membership *organization.Membership,
+	seatLimit int,
+	countInvitations bool,
+) error {
+	seatLock.Lock()
+	defer seatLock.Unlock()
+	if seatLimit > 0 {
+		var invitations *InMemoryInvitationRepository
+		if countInvitations {
+			invitations = r.invitations
+		}
+		if countSeats(ctx, r, invitations, membership.OrganizationID, membership.CreatedAt) >= seatLimit {
+			return organization.ErrSeatLimitReached
+		}
+	}
+	return r.Add(ctx, membership)
+}
+
+func (r *InMemoryMembershipRepository) Get(ctx context.Context, organizationID, userID uuid.UUID) (*organization.Membership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.live(ctx) {
+		if m.OrganizationID == organizationID && m.UserID == userID {
+			result := *m
+			return &result, nil
+		}
+	}
+	return nil, organization.ErrNotMember
+}
+
+func (r *InMemoryMembershipRepository) ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*organization.Membership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var memberships []*organization.Membership
+	for _, m := range r.live(ctx) {
+		if m.OrganizationID == organizationID {
+			result := *m
+			memberships = append(memberships, &result)
+		}
+	}
+	return memberships, nil
+}
+
+func (r *InMemoryMembershipRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*organization.Membership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var memberships []*organization.Membership
+	for _, m := range r.live(ctx) {
+		if m.UserID == userID {
+			result := *m
+			result.Organization, _ = r.orgs.GetByID(ctx, m.OrganizationID)
+			memberships = append(memberships, &result)
+		}
+	}
+	return memberships, nil
+}
+
+func (r *InMemoryMembershipRepository) UpdateRole(ctx context.Context, organizationID, userID uuid.UUID, role organization.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.live(ctx) {
+		if m.OrganizationID == organizationID && m.UserID == userID {
+			m.Role = role
+			m.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return organization.ErrNotMember
+}
+
+func (r *InMemoryMembershipRepository) Remove(ctx context.Context, organizationID, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, m := range r.memberships {
+		if m.OrganizationID == organizationID && m.UserID == userID {
+			r.memberships = append(r.memberships[:i], r.memberships[i+1:]...)
+			return nil
+		}
+	}
+	return organization.ErrNotMember
+}
+
+func (r *InMemoryMembershipRepository) CountByRole(ctx context.Context, organizationID uuid.UUID, role organization.Role) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, m := range r.live(ctx) {
+		if m.OrganizationID == organizationID && m.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// InMemoryInvitationRepository is an in-memory implementation of organization.InvitationRepository for testing
+type InMemoryInvitationRepository struct {
+	mu          sync.Mutex
+	invitations map[uuid.UUID]*organization.Invitation
+
+	// memberships, when set, are counted as taking seats
+	memberships *InMemoryMembershipRepository
+}
+
+func NewInMemoryInvitationRepository() *InMemoryInvitationRepository {
+	return &InMemoryInvitationRepository{invitations: make(map[uuid.UUID]*organization.Invitation)}
+}
+
+func (r *InMemoryInvitationRepository) Create(ctx context.Context, invitation *organization.Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.invitations {
+		if existing.OrganizationID == invitation.OrganizationID &&
+			strings.EqualFold(existing.Email, invitation.Email) &&
+			existing.Status == organization.InvitationStatusPending {
+			return organization.ErrInvitationPending
+		}
+	}
+	stored := *invitation
+	r.invitations[invitation.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryInvitationRepository) CreateWithinSeatLimit(ctx context.Context, invitation *organization.Invitation, seatLimit int) error {
+	seatLock.Lock()
+	defer seatLock.Unlock()
+	if seatLimit > 0 && countSeats(ctx, r.memberships, r, invitation.OrganizationID, invitation.CreatedAt) >= seatLimit {
+		return organization.ErrSeatLimitReached
+	}
+	return r.Create(ctx, invitation)
+}
+
+func (r *InMemoryInvitationRepository) UpdateWithinSeatLimit(ctx context.Context, invitation *organization.Invitation, seatLimit int) error {
+	seatLock.Lock()
+	defer seatLock.Unlock()
+	if seatLimit > 0 && countSeats(ctx, r.memberships, r, invitation.OrganizationID, invitation.UpdatedAt) >= seatLimit {
+		return organization.ErrSeatLimitReached
+	}
+	return r.Update(ctx, invitation)
+}
+
+func (r *InMemoryInvitationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.invitations[id]; !ok {
+		return organization.ErrInvitationNotFound
+	}
+	delete(r.invitations, id)
+	return nil
+}
+
+func (r *InMemoryInvitationRepository) GetByID(ctx context.Context, id uuid.UUID) (*organization.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invitation, ok := r.invitations[id]
+	if !ok {
+		return nil, organization.ErrInvitationNotFound
+	}
+	result := *invitation
+	return &result, nil
+}
+
+func (r *InMemoryInvitationRepository) GetPendingByEmail(ctx context.Context, organizationID uuid.UUID, email string) (*organization.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, invitation := range r.invitations {
+		if invitation.OrganizationID == organizationID &&
+			strings.EqualFold(invitation.Email, email) &&
+			invitation.Status == organization.InvitationStatusPending {
+			result := *invitation
+			return &result, nil
+		}
+	}
+	return nil, organization.ErrInvitationNotFound
+}
+
+func (r *InMemoryInvitationRepository) ListPending(ctx context.Context, organizationID uuid.UUID) ([]*organization.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var invitations []*organization.Invitation
+	for _, invitation := range r.invitations {
+		if invitation.OrganizationID == organizationID && invitation.Status == organization.InvitationStatusPending {
+			result := *invitation
+			invitations = append(invitations, &result)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		return invitations[i].CreatedAt.After(invitations[j].CreatedAt)
+	})
+	return invitations, nil
+}
+
+func (r *InMemoryInvitationRepository) CountOpen(ctx context.Context, organizationID uuid.UUID, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, invitation := range r.invitations {
+		if invitation.OrganizationID == organizationID && invitation.IsOpen(now) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *InMemoryInvitationRepository) Update(ctx context.Context, invitation *organization.Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.invitations[invitation.ID]; !ok {
+		return organization.ErrInvitationNotFound
+	}
+	stored := *invitation
+	r.invitations[invitation.ID] = &stored
+	return nil
+}
+
+// seatLock makes seat-limited writes to the in-memory repositories one at a time,
+// as locking the organization row does in PostgreSQL
+var seatLock sync.Mutex
+
+// countSeats counts an organization's members and open invitations; nil repositories count nothing
+func countSeats(
+	ctx context.Context,
+	memberships *InMemoryMembershipRepository,
+	invitations *InMemoryInvitationRepository,
+	organizationID uuid.UUID,
+	now time.Time,
+) int {
+	seats := 0
+	if memberships != nil {
+		members, _ := memberships.ListByOrganization(ctx, organizationID)
+		seats += len(members)
+	}
+	if invitations != nil {
+		open, _ := invitations.CountOpen(ctx, organizationID, now)
+		seats += open
+	}
+	return seats
+}
@@ -0,0 +1,763 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/oauth"
+	"github.com/victoralfred/um_sys/internal/domain/serviceaccount"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+)
+
+const (
+	oauthSecretBytes = 32
+
+	// RFC 7636 section 4.1 bounds the code verifier length
+	minCodeVerifierLength = 43
+	maxCodeVerifierLength = 128
+)
+
+// OAuthConfig holds settings for the OpenID Connect provider
+type OAuthConfig struct {
+	// Issuer is the provider's base URL; endpoints are published relative to it
+	Issuer string
+
+	// LoginURL is the page that signs users in before an authorization request
+	// is completed; the original request is appended as ?return_to=
+	LoginURL string
+
+	// AuthorizationCodeExpiry is how long an authorization code may be redeemed
+	AuthorizationCodeExpiry time.Duration
+
+	// IntrospectionCacheTTL is how long verified token claims are reused by the
+	// introspection endpoint; revocation is still checked on every request.
+	// Zero disables the cache.
+	IntrospectionCacheTTL time.Duration
+}
+
+// DefaultOAuthConfig returns the default OpenID Connect provider settings
+func DefaultOAuthConfig() OAuthConfig {
+	return OAuthConfig{
+		Issuer:                  "http://localhost:8080/v1",
+		LoginURL:                "http://localhost:8080/login",
+		AuthorizationCodeExpiry: time.Minute,
+		IntrospectionCacheTTL:   5 * time.Second,
+	}
+}
+
+// OAuthService implements an OAuth 2.1 authorization server with OpenID Connect.
+// Users authorize clients with the authorization code flow with PKCE; service
+// accounts obtain tokens with the client credentials grant.
+type OAuthService struct {
+	clientRepo      oauth.ClientRepository
+	codeRepo        oauth.AuthorizationCodeRepository
+	consentRepo     oauth.ConsentRepository
+	userRepo        user.Repository
+	tokenService    *TokenService
+	serviceAccounts *ServiceAccountService
+	introspections  *introspectionCache
+	config          OAuthConfig
+}
+
+// NewOAuthService creates a new OAuth service
+func NewOAuthService(
+	clientRepo oauth.ClientRepository,
+	codeRepo oauth.AuthorizationCodeRepository,
+	consentRepo oauth.ConsentRepository,
+	userRepo user.Repository,
+	tokenService *TokenService,
+	config OAuthConfig,
+) *OAuthService {
+	service := &OAuthService{
+		clientRepo:   clientRepo,
+		codeRepo:     codeRepo,
+		consentRepo:  consentRepo,
+		userRepo:     userRepo,
+		tokenService: tokenService,
+		config:       config,
+	}
+	if config.IntrospectionCacheTTL > 0 {
+		service.introspections = newIntrospectionCache()
+	}
+	return service
+}
+
+// SetServiceAccountService enables the client_credentials grant for service accounts
+func (s *OAuthService) SetServiceAccountService(serviceAccounts *ServiceAccountService) {
+	s.serviceAccounts = serviceAccounts
+}
+
+// LoginURL returns the URL that signs a user in and then resumes the given authorization request
+func (s *OAuthService) LoginURL(authorizeURL string) string {
+	if s.config.LoginURL == "" {
+		return ""
+	}
+	return s.config.LoginURL + "?return_to=" + url.QueryEscape(authorizeURL)
+}
+
+// Discovery returns the OpenID Provider metadata document
+func (s *OAuthService) Discovery() *oauth.DiscoveryDocument {
+	algorithm := string(auth.SigningAlgorithmHS256)
+	if s.tokenService.keyRing != nil {
+		algorithm = string(s.tokenService.keyRing.Algorithm())
+	}
+
+	grantTypes := []string{oauth.GrantTypeAuthorizationCode, oauth.GrantTypeRefreshToken}
+	if s.serviceAccounts != nil {
+		grantTypes = append(grantTypes, oauth.GrantTypeClientCredentials)
+	}
+
+	return &oauth.DiscoveryDocument{
+		Issuer:                            s.config.Issuer,
+		AuthorizationEndpoint:             s.config.Issuer + "/oauth/authorize",
+		TokenEndpoint:                     s.config.Issuer + "/oauth/token",
+		IntrospectionEndpoint:             s.config.Issuer + "/oauth/introspect",
+		RevocationEndpoint:                s.config.Issuer + "/oauth/revoke",
+		UserInfoEndpoint:                  s.config.Issuer + "/oauth/userinfo",
+		JWKSURI:                           s.config.Issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   oauth.SupportedScopes,
+		ResponseTypesSupported:            []string{oauth.ResponseTypeCode},
+		GrantTypesSupported:               grantTypes,
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{algorithm},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{oauth.CodeChallengeMethodS256},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "at_hash",
+			"email", "email_verified", "name", "given_name", "family_name",
+			"preferred_username", "picture", "locale", "zoneinfo", "updated_at",
+		},
+	}
+}
+
+// RegisterClient registers a new client and returns it with its secret.
+// The secret is only available here; public clients get no secret.
+func (s *OAuthService) RegisterClient(ctx context.Context, req *oauth.RegisterClientRequest) (*oauth.Client, string, error) {
+	for _, uri := range req.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return nil, "", err
+		}
+	}
+
+	scopes := req.AllowedScopes
+	if len(scopes) == 0 {
+		scopes = oauth.SupportedScopes
+	}
+	for _, scope := range scopes {
+		if !oauth.HasScope(oauth.SupportedScopes, scope) {
+			return nil, "", fmt.Errorf("%w: %s", oauth.ErrInvalidScope, scope)
+		}
+	}
+
+	now := time.Now()
+	client := &oauth.Client{
+		ID:            uuid.New().String(),
+		Name:          req.Name,
+		RedirectURIs:  req.RedirectURIs,
+		AllowedScopes: scopes,
+		Public:        req.Public,
+		FirstParty:    req.FirstParty,
+		CanIntrospect: req.CanIntrospect,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	var secret string
+	if !client.Public {
+		var err error
+		secret, err = generateOAuthSecret()
+		if err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = hashOAuthSecret(secret)
+	}
+
+	if err := s.clientRepo.Create(ctx, client); err != nil {
+		return nil, "", fmt.Errorf("failed to create client: %w", err)
+	}
+
+	return client, secret, nil
+}
+
+// ListClients returns all registered clients
+func (s *OAuthService) ListClients(ctx context.Context) ([]*oauth.Client, error) {
+	return s.clientRepo.List(ctx)
+}
+
+// DeleteClient removes a client; tokens already issued to it stay valid until they expire
+func (s *OAuthService) DeleteClient(ctx context.Context, clientID string) error {
+	return s.clientRepo.Delete(ctx, clientID)
+}
+
+// ValidateAuthorizationRequest checks an authorization request and returns the client and
+// requested scopes. ErrClientNotFound and ErrInvalidRedirectURI mean the redirect URI
+// cannot be trusted, so the error must be shown to the user instead of redirected.
+func (s *OAuthService) ValidateAuthorizationRequest(ctx context.Context, req *oauth.AuthorizationRequest) (*oauth.Client, []string, error) {
+	client, err := s.clientRepo.GetByID(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, oauth.ErrClientNotFound) {
+			return nil, nil, oauth.ErrClientNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to get client: %w", err)
+	}
+
+	if !client.HasRedirectURI(req.RedirectURI) {
+		return nil, nil, oauth.ErrInvalidRedirectURI
+	}
+
+	if req.ResponseType != oauth.ResponseTypeCode {
+		return client, nil, oauth.ErrUnsupportedResponseType
+	}
+
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != oauth.CodeChallengeMethodS256 {
+		return client, nil, oauth.ErrPKCERequired
+	}
+
+	scopes := req.Scopes()
+	if len(scopes) == 0 || !client.AllowsScopes(scopes) {
+		return client, nil, oauth.ErrInvalidScope
+	}
+
+	return client, scopes, nil
+}
+
+// Authorize issues an authorization code for a signed-in user and returns the URL to
+// redirect the user agent to. It returns ErrConsentRequired if the user has not yet
+// granted the requested scopes to a third-party client.
+func (s *OAuthService) Authorize(ctx context.Context, userID uuid.UUID, req *oauth.AuthorizationRequest) (string, error) {
+	client, scopes, err := s.ValidateAuthorizationRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	if !client.FirstParty {
+		consent, err := s.consentRepo.Get(ctx, userID, client.ID)
+		if err != nil && !errors.Is(err, oauth.ErrConsentNotFound) {
+			return "", fmt.Errorf("failed to get consent: %w", err)
+		}
+		if consent == nil || !consent.Covers(scopes) {
+			return "", oauth.ErrConsentRequired
+		}
+	}
+
+	code, err := generateOAuthSecret()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	authCode := &oauth.AuthorizationCode{
+		CodeHash:            hashOAuthSecret(code),
+		ClientID:            client.ID,
+		UserID:              userID,
+		RedirectURI:         req.RedirectURI,
+		Scopes:              scopes,
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		AuthTime:            now,
+		ExpiresAt:           now.Add(s.config.AuthorizationCodeExpiry),
+		CreatedAt:           now,
+	}
+	if err := s.codeRepo.Create(ctx, authCode); err != nil {
+		return "", fmt.Errorf("failed to store authorization code: %w", err)
+	}
+
+	return AuthorizationRedirect(req, url.Values{"code": {code}}), nil
+}
+
+// GrantConsent records that a user allows a client the requested scopes, in addition
+// to any scopes granted before
+func (s *OAuthService) GrantConsent(ctx context.Context, userID uuid.UUID, req *oauth.AuthorizationRequest) error {
+	client, scopes, err := s.ValidateAuthorizationRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	consent, err := s.consentRepo.Get(ctx, userID, client.ID)
+	if err != nil {
+		if !errors.Is(err, oauth.ErrConsentNotFound) {
+			return fmt.Errorf("failed to get consent: %w", err)
+		}
+		consent = &oauth.Consent{
+			UserID:    userID,
+			ClientID:  client.ID,
+			GrantedAt: now,
+		}
+	}
+
+	for _, scope := range scopes {
+		if !oauth.HasScope(consent.Scopes, scope) {
+			consent.Scopes = append(consent.Scopes, scope)
+		}
+	}
+	consent.UpdatedAt = now
+
+	return s.consentRepo.Save(ctx, consent)
+}
+
+// ListConsents returns the clients a user has granted access to
+func (s *OAuthService) ListConsents(ctx context.Context, userID uuid.UUID) ([]*oauth.Consent, error) {
+	return s.consentRepo.ListByUser(ctx, userID)
+}
+
+// RevokeConsent withdraws a user's consent; the client must ask again on its next authorization request
+func (s *OAuthService) RevokeConsent(ctx context.Context, userID uuid.UUID, clientID string) error {
+	return s.consentRepo.Delete(ctx, userID, clientID)
+}
+
+// Exchange handles a token endpoint request
+func (s *OAuthService) Exchange(ctx context.Context, req *oauth.TokenRequest) (*oauth.TokenResponse, error) {
+	// Service accounts authenticate with their own credentials, not as registered clients
+	if req.GrantType == oauth.GrantTypeClientCredentials {
+		if s.serviceAccounts == nil {
+			return nil, oauth.ErrUnsupportedGrantType
+		}
+		return s.serviceAccounts.IssueToken(ctx, req.ClientID, req.ClientSecret, req.Scope)
+	}
+
+	client, err := s.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case oauth.GrantTypeAuthorizationCode:
+		return s.redeemAuthorizationCode(ctx, client, req)
+	case oauth.GrantTypeRefreshToken:
+		return s.refresh(ctx, client, req.RefreshToken)
+	default:
+		return nil, oauth.ErrUnsupportedGrantType
+	}
+}
+
+// Introspect reports whether a token is active and what it was issued for (RFC 7662).
+// Only confidential clients and service accounts may ask. Invalid, expired and
+// revoked tokens are all reported the same way, as inactive, and so are tokens
+// issued to other clients unless the caller is registered with can_introspect.
+func (s *OAuthService) Introspect(ctx context.Context, clientID, clientSecret, token, tokenTypeHint string) (*oauth.IntrospectionResponse, error) {
+	callerID, err := s.authenticateCaller(ctx, clientID, clientSecret, false)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.introspectionClaims(ctx, token, tokenTypeHint)
+	if err != nil {
+		return &oauth.IntrospectionResponse{Active: false}, nil
+	}
+
+	// Callers only learn about their own tokens unless they are allowed to
+	// introspect any token (RFC 7662 section 4)
+	if claims.ClientID != callerID {
+		allowed, err := s.mayIntrospectAny(ctx, callerID)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return &oauth.IntrospectionResponse{Active: false}, nil
+		}
+	}
+
+	resp := &oauth.IntrospectionResponse{
+		Active:        true,
+		Scope:         strings.Join(claims.Scopes, " "),
+		ClientID:      claims.ClientID,
+		Username:      claims.Username,
+		ExpiresAt:     claims.ExpiresAt.Unix(),
+		IssuedAt:      claims.IssuedAt.Unix(),
+		Subject:       claims.Subject,
+		Audience:      claims.Audience,
+		Issuer:        claims.Issuer,
+		JTI:           claims.JTI,
+		PrincipalType: string(claims.PrincipalType),
+	}
+	if claims.TokenType == auth.AccessToken {
+		resp.TokenType = "Bearer"
+	}
+	if !claims.NotBefore.IsZero() {
+		resp.NotBefore = claims.NotBefore.Unix()
+	}
+
+	return resp, nil
+}
+
+// mayIntrospectAny reports whether the caller may introspect tokens issued to
+// others; service accounts only ever see their own
+func (s *OAuthService) mayIntrospectAny(ctx context.Context, callerID string) (bool, error) {
+	if strings.HasPrefix(callerID, ServiceAccountClientIDPrefix) {
+		return false, nil
+	}
+
+	client, err := s.clientRepo.GetByID(ctx, callerID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get oauth client: %w", err)
+	}
+
+	return client.CanIntrospect, nil
+}
+
+// introspectionClaims validates a token, reusing recently verified claims when the
+// cache is enabled. Revocation is checked against the token store every time, so a
+// revocation made on any instance is reported on the next request.
+func (s *OAuthService) introspectionClaims(ctx context.Context, token, tokenTypeHint string) (*auth.Claims, error) {
+	if s.introspections == nil {
+		return s.validateAnyToken(ctx, token, tokenTypeHint)
+	}
+
+	key := hashOAuthSecret(token)
+	if claims, ok := s.introspections.get(key); ok {
+		if err := s.tokenService.CheckRevocation(ctx, claims); err != nil {
+			return nil, err
+		}
+		return claims, nil
+	}
+
+	claims, err := s.validateAnyToken(ctx, token, tokenTypeHint)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.config.IntrospectionCacheTTL)
+	if claims.ExpiresAt.Before(expiresAt) {
+		expiresAt = claims.ExpiresAt
+	}
+	s.introspections.set(key, claims, expiresAt)
+
+	return claims, nil
+}
+
+// Revoke revokes a token issued to the calling client (RFC 7009). Revoking a refresh
+// token also revokes every token in its family. Tokens that are already invalid need
+// no revocation and are accepted without error.
+func (s *OAuthService) Revoke(ctx context.Context, clientID, clientSecret, token, tokenTypeHint string) error {
+	callerID, err := s.authenticateCaller(ctx, clientID, clientSecret, true)
+	if err != nil {
+		return err
+	}
+
+	claims, err := s.validateAnyToken(ctx, token, tokenTypeHint)
+	if err != nil {
+		return nil
+	}
+
+	if claims.ClientID != callerID {
+		return oauth.ErrUnauthorizedClient
+	}
+
+	if claims.TokenType == auth.RefreshToken {
+		return s.tokenService.RevokeRefreshToken(ctx, token)
+	}
+	return s.tokenService.RevokeToken(ctx, claims.JTI)
+}
+
+// validateAnyToken validates an access or refresh token, trying the hinted type first
+func (s *OAuthService) validateAnyToken(ctx context.Context, token, tokenTypeHint string) (*auth.Claims, error) {
+	tokenTypes := []auth.TokenType{auth.AccessToken, auth.RefreshToken}
+	if tokenTypeHint == oauth.TokenTypeHintRefreshToken {
+		tokenTypes = []auth.TokenType{auth.RefreshToken, auth.AccessToken}
+	}
+
+	var err error
+	for _, tokenType := range tokenTypes {
+		var claims *auth.Claims
+		claims, err = s.tokenService.ValidateToken(ctx, token, tokenType)
+		if err == nil {
+			return claims, nil
+		}
+	}
+	return nil, err
+}
+
+// redeemAuthorizationCode exchanges an authorization code and its PKCE verifier for tokens
+func (s *OAuthService) redeemAuthorizationCode(ctx context.Context, client *oauth.Client, req *oauth.TokenRequest) (*oauth.TokenResponse, error) {
+	codeHash := hashOAuthSecret(req.Code)
+
+	code, err := s.codeRepo.Consume(ctx, codeHash)
+	if err != nil {
+		if errors.Is(err, oauth.ErrAuthorizationCodeUsed) {
+			// A replayed code may have been intercepted, so the tokens it produced are revoked (RFC 6749 section 4.1.2)
+			if code != nil && code.FamilyID != "" {
+				_ = s.tokenService.RevokeFamily(ctx, code.FamilyID)
+			}
+			return nil, oauth.ErrInvalidGrant
+		}
+		if errors.Is(err, oauth.ErrAuthorizationCodeNotFound) {
+			return nil, oauth.ErrInvalidGrant
+		}
+		return nil, fmt.Errorf("failed to redeem authorization code: %w", err)
+	}
+
+	if code.ClientID != client.ID || code.IsExpired() || code.RedirectURI != req.RedirectURI {
+		return nil, oauth.ErrInvalidGrant
+	}
+
+	if !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		return nil, oauth.ErrInvalidGrant
+	}
+
+	u, err := s.activeUser(ctx, code.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenPair, err := s.tokenService.GenerateClientTokenPair(ctx, u, client.ID, code.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.codeRepo.SetFamily(ctx, codeHash, tokenPair.FamilyID); err != nil {
+		return nil, fmt.Errorf("failed to record token family: %w", err)
+	}
+
+	return s.tokenResponse(u, client, code.Scopes, tokenPair, code.Nonce, code.AuthTime)
+}
+
+// refresh rotates a refresh token issued to the authenticated client
+func (s *OAuthService) refresh(ctx context.Context, client *oauth.Client, refreshToken string) (*oauth.TokenResponse, error) {
+	claims, err := s.tokenService.ValidateToken(ctx, refreshToken, auth.RefreshToken)
+	if err != nil || claims.ClientID != client.ID {
+		return nil, oauth.ErrInvalidGrant
+	}
+
+	tokenPair, err := s.tokenService.RefreshTokens(ctx, refreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrRefreshTokenReused) || errors.Is(err, auth.ErrTokenRevoked) ||
+			errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrAccountInactive) {
+			return nil, oauth.ErrInvalidGrant
+		}
+		return nil, err
+	}
+
+	u, err := s.activeUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The original authentication time is not tracked across rotations, so auth_time is omitted
+	return s.tokenResponse(u, client, claims.Scopes, tokenPair, "", time.Time{})
+}
+
+// tokenResponse builds the token endpoint response, adding an ID token for the openid scope.
+// A refresh token is only handed out when offline access was granted.
+func (s *OAuthService) tokenResponse(u *user.User, client *oauth.Client, scopes []string, tokenPair *auth.TokenPair, nonce string, authTime time.Time) (*oauth.TokenResponse, error) {
+	resp := &oauth.TokenResponse{
+		AccessToken: tokenPair.AccessToken,
+		TokenType:   tokenPair.TokenType,
+		ExpiresIn:   tokenPair.ExpiresIn,
+		Scope:       strings.Join(scopes, " "),
+	}
+
+	if oauth.HasScope(scopes, oauth.ScopeOfflineAccess) {
+		resp.RefreshToken = tokenPair.RefreshToken
+	}
+
+	if oauth.HasScope(scopes, oauth.ScopeOpenID) {
+		idToken, err := s.tokenService.GenerateIDToken(u, &auth.IDTokenParams{
+			Issuer:      s.config.Issuer,
+			ClientID:    client.ID,
+			Nonce:       nonce,
+			AuthTime:    authTime,
+			AccessToken: tokenPair.AccessToken,
+			Claims:      UserInfoClaims(u, scopes),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate id token: %w", err)
+		}
+		resp.IDToken = idToken
+	}
+
+	return resp, nil
+}
+
+// UserInfo returns the claims about a user released by the granted scopes
+func (s *OAuthService) UserInfo(ctx context.Context, userID uuid.UUID, scopes []string) (map[string]interface{}, error) {
+	if !oauth.HasScope(scopes, oauth.ScopeOpenID) {
+		return nil, oauth.ErrInsufficientScope
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return UserInfoClaims(u, scopes), nil
+}
+
+// authenticateClient verifies a client's credentials; public clients are identified by ID alone
+func (s *OAuthService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*oauth.Client, error) {
+	if clientID == "" {
+		return nil, oauth.ErrInvalidClient
+	}
+
+	client, err := s.clientRepo.GetByID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, oauth.ErrClientNotFound) {
+			return nil, oauth.ErrInvalidClient
+		}
+		return nil, fmt.Errorf("failed to get client: %w", err)
+	}
+
+	if client.Public {
+		return client, nil
+	}
+
+	if clientSecret == "" || subtle.ConstantTimeCompare([]byte(hashOAuthSecret(clientSecret)), []byte(client.SecretHash)) != 1 {
+		return nil, oauth.ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// authenticateCaller verifies the credentials of a client or service account calling the
+// introspection or revocation endpoint and returns its client ID
+func (s *OAuthService) authenticateCaller(ctx context.Context, clientID, clientSecret string, allowPublic bool) (string, error) {
+	if s.serviceAccounts != nil && strings.HasPrefix(clientID, ServiceAccountClientIDPrefix) {
+		account, err := s.serviceAccounts.Authenticate(ctx, clientID, clientSecret)
+		if err != nil {
+			if errors.Is(err, serviceaccount.ErrInvalidCredentials) {
+				return "", oauth.ErrInvalidClient
+			}
+			return "", err
+		}
+		return account.ClientID, nil
+	}
+
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return "", err
+	}
+
+	// Public clients have no secret, so letting them introspect would let anyone probe tokens
+	if client.Public && !allowPublic {
+		return "", oauth.ErrInvalidClient
+	}
+
+	return client.ID, nil
+}
+
+// activeUser loads a user that may still be issued tokens
+func (s *OAuthService) activeUser(ctx context.Context, userID uuid.UUID) (*user.User, error) {
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return nil, oauth.ErrInvalidGrant
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if u.Status != user.StatusActive {
+		return nil, oauth.ErrInvalidGrant
+	}
+
+	return u, nil
+}
+
+// UserInfoClaims maps a user to the standard OpenID Connect claims released by the given scopes
+func UserInfoClaims(u *user.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": u.ID.String(),
+	}
+
+	if oauth.HasScope(scopes, oauth.ScopeEmail) {
+		claims["email"] = u.Email
+		claims["email_verified"] = u.EmailVerified
+	}
+
+	if oauth.HasScope(scopes, oauth.ScopeProfile) {
+		claims["preferred_username"] = u.Username
+		claims["updated_at"] = u.UpdatedAt.Unix()
+		if name := strings.TrimSpace(u.FirstName + " " + u.LastName); name != "" {
+			claims["name"] = name
+		}
+		if u.FirstName != "" {
+			claims["given_name"] = u.FirstName
+		}
+		if u.LastName != "" {
+			claims["family_name"] = u.LastName
+		}
+		if u.ProfilePictureURL != "" {
+			claims["picture"] = u.ProfilePictureURL
+		}
+		if u.Locale != "" {
+			claims["locale"] = u.Locale
+		}
+		if u.Timezone != "" {
+			claims["zoneinfo"] = u.Timezone
+		}
+	}
+
+	return claims
+}
+
+// AuthorizationRedirect builds the redirect back to the client, carrying the
+// request's state alongside the given response parameters
+func AuthorizationRedirect(req *oauth.AuthorizationRequest, params url.Values) string {
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	separator := "?"
+	if strings.Contains(req.RedirectURI, "?") {
+		separator = "&"
+	}
+
+	return req.RedirectURI + separator + params.Encode()
+}
+
+// validateRedirectURI checks that a redirect URI is absolute and has no fragment (RFC 6749 section 3.1.2)
+func validateRedirectURI(uri string) error {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme == "" || parsed.Fragment != "" {
+		return fmt.Errorf("%w: %s", oauth.ErrInvalidRedirectURI, uri)
+	}
+
+	// Plain HTTP is only allowed for loopback redirects used by native apps and local development
+	if parsed.Scheme == "http" {
+		host := parsed.Hostname()
+		if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return fmt.Errorf("%w: %s must use https", oauth.ErrInvalidRedirectURI, uri)
+		}
+	}
+
+	return nil
+}
+
+// verifyCodeChallenge checks a PKCE code verifier against an S256 code challenge
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < minCodeVerifierLength || len(verifier) > maxCodeVerifierLength {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// generateOAuthSecret generates a random client secret or authorization code
+func generateOAuthSecret() (string, error) {
+	b := make([]byte, oauthSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashOAuthSecret hashes a client secret or authorization code for storage
+func hashOAuthSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
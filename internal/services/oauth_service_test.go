@@ -0,0 +1,562 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/oauth"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+const (
+	testIssuer       = "https://id.example.com/v1"
+	testRedirectURI  = "https://app.example.com/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mJ92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+type oauthFixture struct {
+	service      *services.OAuthService
+	tokenService *services.TokenService
+	keyRing      *services.KeyRing
+	consents     *InMemoryOAuthConsentRepository
+	user         *user.User
+}
+
+func newOAuthFixture(t *testing.T) *oauthFixture {
+	t.Helper()
+
+	keyRing, err := services.NewKeyRing(auth.SigningAlgorithmRS256, time.Hour)
+	require.NoError(t, err)
+
+	u := &user.User{
+		ID:            uuid.New(),
+		Email:         "test@example.com",
+		Username:      "testuser",
+		FirstName:     "Test",
+		LastName:      "User",
+		EmailVerified: true,
+		Status:        user.StatusActive,
+	}
+	userRepo := new(MockUserRepository)
+	userRepo.On("GetByID", mock.Anything, u.ID).Return(u, nil)
+
+	tokenService := services.NewTokenService("", "test-issuer", 15*time.Minute, 7*24*time.Hour, userRepo)
+	tokenService.SetKeyRing(keyRing)
+	tokenService.SetTokenStore(NewInMemoryTokenStore())
+
+	consents := NewInMemoryOAuthConsentRepository()
+	config := services.DefaultOAuthConfig()
+	config.Issuer = testIssuer
+
+	service := services.NewOAuthService(
+		NewInMemoryOAuthClientRepository(),
+		NewInMemoryOAuthCodeRepository(),
+		consents,
+		userRepo,
+		tokenService,
+		config,
+	)
+
+	return &oauthFixture{
+		service:      service,
+		tokenService: tokenService,
+		keyRing:      keyRing,
+		consents:     consents,
+		user:         u,
+	}
+}
+
+func (f *oauthFixture) registerClient(t *testing.T, firstParty bool) (*oauth.Client, string) {
+	t.Helper()
+
+	client, secret, err := f.service.RegisterClient(context.Background(), &oauth.RegisterClientRequest{
+		Name:         "Example App",
+		RedirectURIs: []string{testRedirectURI},
+		FirstParty:   firstParty,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, secret)
+
+	return client, secret
+}
+
+func authorizationRequest(clientID, scope string) *oauth.AuthorizationRequest {
+	sum := sha256.Sum256([]byte(testCodeVerifier))
+	return &oauth.AuthorizationRequest{
+		ClientID:            clientID,
+		RedirectURI:         testRedirectURI,
+		ResponseType:        oauth.ResponseTypeCode,
+		Scope:               scope,
+		State:               "xyz",
+		Nonce:               "n-0S6_WzA2Mj",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+		CodeChallengeMethod: oauth.CodeChallengeMethodS256,
+	}
+}
+
+// authorizeCode runs the authorization endpoint and returns the issued code
+func (f *oauthFixture) authorizeCode(t *testing.T, req *oauth.AuthorizationRequest) string {
+	t.Helper()
+
+	redirect, err := f.service.Authorize(context.Background(), f.user.ID, req)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(redirect, testRedirectURI+"?"))
+
+	parsed, err := url.Parse(redirect)
+	require.NoError(t, err)
+	assert.Equal(t, req.State, parsed.Query().Get("state"))
+
+	code := parsed.Query().Get("code")
+	require.NotEmpty(t, code)
+	return code
+}
+
+func TestOAuthService_AuthorizationCodeFlow(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("exchanges a code for tokens and an id token", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		req := authorizationRequest(client.ID, "openid email profile offline_access")
+
+		code := f.authorizeCode(t, req)
+
+		resp, err := f.service.Exchange(ctx, &oauth.TokenRequest{
+			GrantType:    oauth.GrantTypeAuthorizationCode,
+			Code:         code,
+			RedirectURI:  testRedirectURI,
+			CodeVerifier: testCodeVerifier,
+			ClientID:     client.ID,
+			ClientSecret: secret,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer", resp.TokenType)
+		assert.NotEmpty(t, resp.RefreshToken)
+		assert.Equal(t, "openid email profile offline_access", resp.Scope)
+
+		// The access token remembers which client it was issued to
+		claims, err := f.tokenService.ValidateToken(ctx, resp.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, client.ID, claims.ClientID)
+		assert.Equal(t, []string{client.ID}, claims.Audience)
+		assert.Equal(t, []string{"openid", "email", "profile", "offline_access"}, claims.Scopes)
+
+		// The ID token verifies against the published keys
+		key, err := f.keyRing.ActiveKey()
+		require.NoError(t, err)
+		idToken, err := jwt.Parse(resp.IDToken, func(token *jwt.Token) (interface{}, error) {
+			return key.PublicKey, nil
+		}, jwt.WithIssuer(testIssuer), jwt.WithAudience(client.ID))
+		require.NoError(t, err)
+
+		idClaims := idToken.Claims.(jwt.MapClaims)
+		assert.Equal(t, f.user.ID.String(), idClaims["sub"])
+		assert.Equal(t, req.Nonce, idClaims["nonce"])
+		assert.Equal(t, f.user.Email, idClaims["email"])
+		assert.Equal(t, "Test User", idClaims["name"])
+		assert.NotEmpty(t, idClaims["at_hash"])
+		assert.NotEmpty(t, idClaims["auth_time"])
+	})
+
+	t.Run("refresh token is withheld without offline_access", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+
+		code := f.authorizeCode(t, authorizationRequest(client.ID, "openid"))
+
+		resp, err := f.service.Exchange(ctx, &oauth.TokenRequest{
+			GrantType:    oauth.GrantTypeAuthorizationCode,
+			Code:         code,
+			RedirectURI:  testRedirectURI,
+			CodeVerifier: testCodeVerifier,
+			ClientID:     client.ID,
+			ClientSecret: secret,
+		})
+		require.NoError(t, err)
+		assert.Empty(t, resp.RefreshToken)
+		assert.NotEmpty(t, resp.IDToken)
+	})
+
+	t.Run("replayed code is rejected and revokes issued tokens", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		code := f.authorizeCode(t, authorizationRequest(client.ID, "openid"))
+
+		tokenReq := &oauth.TokenRequest{
+			GrantType:    oauth.GrantTypeAuthorizationCode,
+			Code:         code,
+			RedirectURI:  testRedirectURI,
+			CodeVerifier: testCodeVerifier,
+			ClientID:     client.ID,
+			ClientSecret: secret,
+		}
+
+		first, err := f.service.Exchange(ctx, tokenReq)
+		require.NoError(t, err)
+
+		_, err = f.service.Exchange(ctx, tokenReq)
+		assert.ErrorIs(t, err, oauth.ErrInvalidGrant)
+
+		_, err = f.tokenService.ValidateToken(ctx, first.AccessToken, auth.AccessToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+	})
+
+	t.Run("wrong code verifier is rejected", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		code := f.authorizeCode(t, authorizationRequest(client.ID, "openid"))
+
+		_, err := f.service.Exchange(ctx, &oauth.TokenRequest{
+			GrantType:    oauth.GrantTypeAuthorizationCode,
+			Code:         code,
+			RedirectURI:  testRedirectURI,
+			CodeVerifier: strings.Repeat("a", 43),
+			ClientID:     client.ID,
+			ClientSecret: secret,
+		})
+		assert.ErrorIs(t, err, oauth.ErrInvalidGrant)
+	})
+
+	t.Run("redirect uri must match the authorization request", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		code := f.authorizeCode(t, authorizationRequest(client.ID, "openid"))
+
+		_, err := f.service.Exchange(ctx, &oauth.TokenRequest{
+			GrantType:    oauth.GrantTypeAuthorizationCode,
+			Code:         code,
+			RedirectURI:  "https://app.example.com/other",
+			CodeVerifier: testCodeVerifier,
+			ClientID:     client.ID,
+			ClientSecret: secret,
+		})
+		assert.ErrorIs(t, err, oauth.ErrInvalidGrant)
+	})
+
+	t.Run("confidential client must authenticate", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, _ := f.registerClient(t, true)
+		code := f.authorizeCode(t, authorizationRequest(client.ID, "openid"))
+
+		_, err := f.service.Exchange(ctx, &oauth.TokenRequest{
+			GrantType:    oauth.GrantTypeAuthorizationCode,
+			Code:         code,
+			RedirectURI:  testRedirectURI,
+			CodeVerifier: testCodeVerifier,
+			ClientID:     client.ID,
+			ClientSecret: "wrong-secret",
+		})
+		assert.ErrorIs(t, err, oauth.ErrInvalidClient)
+	})
+}
+
+func TestOAuthService_ValidateAuthorizationRequest(t *testing.T) {
+	ctx := context.Background()
+	f := newOAuthFixture(t)
+	client, _ := f.registerClient(t, true)
+
+	tests := []struct {
+		name    string
+		modify  func(req *oauth.AuthorizationRequest)
+		wantErr error
+	}{
+		{"unknown client", func(req *oauth.AuthorizationRequest) { req.ClientID = "unknown" }, oauth.ErrClientNotFound},
+		{"unregistered redirect uri", func(req *oauth.AuthorizationRequest) { req.RedirectURI = "https://evil.example.com/cb" }, oauth.ErrInvalidRedirectURI},
+		{"implicit flow", func(req *oauth.AuthorizationRequest) { req.ResponseType = "token" }, oauth.ErrUnsupportedResponseType},
+		{"missing PKCE", func(req *oauth.AuthorizationRequest) { req.CodeChallenge = "" }, oauth.ErrPKCERequired},
+		{"plain PKCE", func(req *oauth.AuthorizationRequest) { req.CodeChallengeMethod = "plain" }, oauth.ErrPKCERequired},
+		{"unknown scope", func(req *oauth.AuthorizationRequest) { req.Scope = "openid admin" }, oauth.ErrInvalidScope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := authorizationRequest(client.ID, "openid")
+			tt.modify(req)
+
+			_, _, err := f.service.ValidateAuthorizationRequest(ctx, req)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestOAuthService_Consent(t *testing.T) {
+	ctx := context.Background()
+	f := newOAuthFixture(t)
+	client, _ := f.registerClient(t, false)
+
+	req := authorizationRequest(client.ID, "openid email")
+
+	// Third-party clients need the user's consent before a code is issued
+	_, err := f.service.Authorize(ctx, f.user.ID, req)
+	assert.ErrorIs(t, err, oauth.ErrConsentRequired)
+
+	require.NoError(t, f.service.GrantConsent(ctx, f.user.ID, req))
+	f.authorizeCode(t, req)
+
+	// Asking for more than was granted prompts again
+	_, err = f.service.Authorize(ctx, f.user.ID, authorizationRequest(client.ID, "openid email profile"))
+	assert.ErrorIs(t, err, oauth.ErrConsentRequired)
+
+	require.NoError(t, f.service.RevokeConsent(ctx, f.user.ID, client.ID))
+	_, err = f.service.Authorize(ctx, f.user.ID, req)
+	assert.ErrorIs(t, err, oauth.ErrConsentRequired)
+}
+
+func TestOAuthService_RefreshGrant(t *testing.T) {
+	ctx := context.Background()
+	f := newOAuthFixture(t)
+	client, secret := f.registerClient(t, true)
+	otherClient, otherSecret := f.registerClient(t, true)
+
+	code := f.authorizeCode(t, authorizationRequest(client.ID, "openid offline_access"))
+	initial, err := f.service.Exchange(ctx, &oauth.TokenRequest{
+		GrantType:    oauth.GrantTypeAuthorizationCode,
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+		ClientID:     client.ID,
+		ClientSecret: secret,
+	})
+	require.NoError(t, err)
+
+	// A refresh token is bound to the client it was issued to
+	_, err = f.service.Exchange(ctx, &oauth.TokenRequest{
+		GrantType:    oauth.GrantTypeRefreshToken,
+		RefreshToken: initial.RefreshToken,
+		ClientID:     otherClient.ID,
+		ClientSecret: otherSecret,
+	})
+	assert.ErrorIs(t, err, oauth.ErrInvalidGrant)
+
+	refreshed, err := f.service.Exchange(ctx, &oauth.TokenRequest{
+		GrantType:    oauth.GrantTypeRefreshToken,
+		RefreshToken: initial.RefreshToken,
+		ClientID:     client.ID,
+		ClientSecret: secret,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, refreshed.IDToken)
+	assert.NotEqual(t, initial.RefreshToken, refreshed.RefreshToken)
+
+	claims, err := f.tokenService.ValidateToken(ctx, refreshed.AccessToken, auth.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, client.ID, claims.ClientID)
+	assert.Equal(t, []string{"openid", "offline_access"}, claims.Scopes)
+}
+
+func TestOAuthService_UserInfo(t *testing.T) {
+	ctx := context.Background()
+	f := newOAuthFixture(t)
+
+	_, err := f.service.UserInfo(ctx, f.user.ID, []string{"email"})
+	assert.ErrorIs(t, err, oauth.ErrInsufficientScope)
+
+	claims, err := f.service.UserInfo(ctx, f.user.ID, []string{"openid", "email"})
+	require.NoError(t, err)
+	assert.Equal(t, f.user.ID.String(), claims["sub"])
+	assert.Equal(t, f.user.Email, claims["email"])
+	assert.Equal(t, true, claims["email_verified"])
+	assert.NotContains(t, claims, "preferred_username")
+}
+
+// issueTokens runs the authorization code flow for a client and returns its tokens
+func (f *oauthFixture) issueTokens(t *testing.T, client *oauth.Client, secret, scope string) *oauth.TokenResponse {
+	t.Helper()
+
+	code := f.authorizeCode(t, authorizationRequest(client.ID, scope))
+	resp, err := f.service.Exchange(context.Background(), &oauth.TokenRequest{
+		GrantType:    oauth.GrantTypeAuthorizationCode,
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+		ClientID:     client.ID,
+		ClientSecret: secret,
+	})
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestOAuthService_Introspect(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("describes an active access token", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		tokens := f.issueTokens(t, client, secret, "openid profile")
+
+		resp, err := f.service.Introspect(ctx, client.ID, secret, tokens.AccessToken, "")
+		require.NoError(t, err)
+		assert.True(t, resp.Active)
+		assert.Equal(t, "openid profile", resp.Scope)
+		assert.Equal(t, client.ID, resp.ClientID)
+		assert.Equal(t, f.user.ID.String(), resp.Subject)
+		assert.Equal(t, "testuser", resp.Username)
+		assert.Equal(t, "Bearer", resp.TokenType)
+		assert.NotZero(t, resp.ExpiresAt)
+		assert.NotEmpty(t, resp.JTI)
+	})
+
+	t.Run("describes a refresh token", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		tokens := f.issueTokens(t, client, secret, "openid offline_access")
+
+		resp, err := f.service.Introspect(ctx, client.ID, secret, tokens.RefreshToken, oauth.TokenTypeHintRefreshToken)
+		require.NoError(t, err)
+		assert.True(t, resp.Active)
+		assert.Empty(t, resp.TokenType)
+	})
+
+	t.Run("reports garbage as inactive", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+
+		resp, err := f.service.Introspect(ctx, client.ID, secret, "not-a-token", "")
+		require.NoError(t, err)
+		assert.Equal(t, &oauth.IntrospectionResponse{Active: false}, resp)
+	})
+
+	t.Run("requires a confidential client", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		tokens := f.issueTokens(t, client, secret, "openid")
+
+		_, err := f.service.Introspect(ctx, client.ID, "wrong-secret", tokens.AccessToken, "")
+		assert.ErrorIs(t, err, oauth.ErrInvalidClient)
+
+		public, _, err := f.service.RegisterClient(ctx, &oauth.RegisterClientRequest{
+			Name:         "Public App",
+			RedirectURIs: []string{testRedirectURI},
+			Public:       true,
+		})
+		require.NoError(t, err)
+
+		_, err = f.service.Introspect(ctx, public.ID, "", tokens.AccessToken, "")
+		assert.ErrorIs(t, err, oauth.ErrInvalidClient)
+	})
+
+	t.Run("reports tokens of other clients as inactive", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		other, otherSecret := f.registerClient(t, true)
+		tokens := f.issueTokens(t, client, secret, "openid")
+
+		resp, err := f.service.Introspect(ctx, other.ID, otherSecret, tokens.AccessToken, "")
+		require.NoError(t, err)
+		assert.Equal(t, &oauth.IntrospectionResponse{Active: false}, resp)
+	})
+
+	t.Run("resource servers may introspect any client's tokens", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		tokens := f.issueTokens(t, client, secret, "openid")
+
+		resourceServer, rsSecret, err := f.service.RegisterClient(ctx, &oauth.RegisterClientRequest{
+			Name:          "API",
+			RedirectURIs:  []string{testRedirectURI},
+			CanIntrospect: true,
+		})
+		require.NoError(t, err)
+
+		resp, err := f.service.Introspect(ctx, resourceServer.ID, rsSecret, tokens.AccessToken, "")
+		require.NoError(t, err)
+		assert.True(t, resp.Active)
+		assert.Equal(t, client.ID, resp.ClientID)
+	})
+
+	t.Run("a token revoked here is inactive despite the cache", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		tokens := f.issueTokens(t, client, secret, "openid offline_access")
+
+		resp, err := f.service.Introspect(ctx, client.ID, secret, tokens.AccessToken, "")
+		require.NoError(t, err)
+		require.True(t, resp.Active)
+
+		// Revoking the refresh token takes the access token's family with it
+		require.NoError(t, f.service.Revoke(ctx, client.ID, secret, tokens.RefreshToken, ""))
+
+		resp, err = f.service.Introspect(ctx, client.ID, secret, tokens.AccessToken, "")
+		require.NoError(t, err)
+		assert.False(t, resp.Active)
+	})
+
+	t.Run("a revocation made elsewhere is seen on the next request", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		tokens := f.issueTokens(t, client, secret, "openid")
+
+		resp, err := f.service.Introspect(ctx, client.ID, secret, tokens.AccessToken, "")
+		require.NoError(t, err)
+		require.True(t, resp.Active)
+
+		// Another instance revoking the token only writes to the shared token store
+		require.NoError(t, f.tokenService.RevokeToken(ctx, resp.JTI))
+
+		resp, err = f.service.Introspect(ctx, client.ID, secret, tokens.AccessToken, "")
+		require.NoError(t, err)
+		assert.False(t, resp.Active)
+	})
+}
+
+func TestOAuthService_Revoke(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("revokes an access token", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		tokens := f.issueTokens(t, client, secret, "openid")
+
+		require.NoError(t, f.service.Revoke(ctx, client.ID, secret, tokens.AccessToken, oauth.TokenTypeHintAccessToken))
+
+		_, err := f.tokenService.ValidateToken(ctx, tokens.AccessToken, auth.AccessToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+	})
+
+	t.Run("revoking a refresh token ends the grant", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		tokens := f.issueTokens(t, client, secret, "openid offline_access")
+
+		require.NoError(t, f.service.Revoke(ctx, client.ID, secret, tokens.RefreshToken, oauth.TokenTypeHintRefreshToken))
+
+		_, err := f.tokenService.ValidateToken(ctx, tokens.RefreshToken, auth.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+		_, err = f.tokenService.ValidateToken(ctx, tokens.AccessToken, auth.AccessToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+	})
+
+	t.Run("invalid tokens are accepted", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+
+		assert.NoError(t, f.service.Revoke(ctx, client.ID, secret, "not-a-token", ""))
+	})
+
+	t.Run("another client's token is refused", func(t *testing.T) {
+		f := newOAuthFixture(t)
+		client, secret := f.registerClient(t, true)
+		otherClient, otherSecret := f.registerClient(t, true)
+		tokens := f.issueTokens(t, client, secret, "openid")
+
+		err := f.service.Revoke(ctx, otherClient.ID, otherSecret, tokens.AccessToken, "")
+		assert.ErrorIs(t, err, oauth.ErrUnauthorizedClient)
+
+		_, err = f.tokenService.ValidateToken(ctx, tokens.AccessToken, auth.AccessToken)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,454 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/billing"
+	"github.com/victoralfred/um_sys/internal/domain/organization"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+)
+
+// OrganizationPlanProvider returns the billing plan an organization is currently subscribed to
+type OrganizationPlanProvider interface {
+	CurrentOrganizationPlan(ctx context.Context, organizationID uuid.UUID) (*billing.Plan, error)
+}
+
+// OrganizationService manages organizations, their members and the organization
+// users act in. Every action is authorized by the caller's role in the organization.
+type OrganizationService struct {
+	orgs         organization.Repository
+	memberships  organization.MembershipRepository
+	userRepo     user.Repository
+	tokenService *TokenService
+	auditService audit.AuditService
+	plans        OrganizationPlanProvider
+	invitations  organization.InvitationRepository
+}
+
+// NewOrganizationService creates a new organization service
+func NewOrganizationService(
+	orgs organization.Repository,
+	memberships organization.MembershipRepository,
+	userRepo user.Repository,
+	tokenService *TokenService,
+) *OrganizationService {
+	return &OrganizationService{
+		orgs:         orgs,
+		memberships:  memberships,
+		userRepo:     userRepo,
+		tokenService: tokenService,
+	}
+}
+
+// SetAuditService sets the audit service used to record organization changes
+func (s *OrganizationService) SetAuditService(auditService audit.AuditService) {
+	s.auditService = auditService
+}
+
+// SetPlanProvider enforces the team member limit of each organization's plan.
+// Without it, or for organizations without a subscription, seats are unlimited.
+func (s *OrganizationService) SetPlanProvider(plans OrganizationPlanProvider) {
+	s.plans = plans
+}
+
+// SetInvitationRepository lets pending invitations hold seats on the organization's plan
+func (s *OrganizationService) SetInvitationRepository(invitations organization.InvitationRepository) {
+	s.invitations = invitations
+}
+
+// Create creates an organization owned by the actor
+func (s *OrganizationService) Create(ctx context.Context, req *organization.CreateRequest, actorID uuid.UUID) (*organization.Organization, error) {
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if err := organization.ValidateSlug(slug); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	org := &organization.Organization{
+		ID:        uuid.New(),
+		Name:      strings.TrimSpace(req.Name),
+		Slug:      slug,
+		CreatedBy: actorID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := s.orgs.Create(ctx, org); err != nil {
+		if errors.Is(err, organization.ErrSlugTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	if err := s.memberships.Add(ctx, &organization.Membership{
+		OrganizationID: org.ID,
+		UserID:         actorID,
+		Role:           organization.RoleOwner,
+		AddedBy:        actorID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to add organization owner: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeOrganizationCreated, org.ID, actorID, "Organization created", map[string]interface{}{
+		"slug": org.Slug,
+	})
+
+	return org, nil
+}
+
+// ListForUser returns the organizations a user belongs to, with their role in each
+func (s *OrganizationService) ListForUser(ctx context.Context, userID uuid.UUID) ([]*organization.Membership, error) {
+	return s.memberships.ListByUser(ctx, userID)
+}
+
+// Get retrieves an organization the actor belongs to
+func (s *OrganizationService) Get(ctx context.Context, orgID, actorID uuid.UUID) (*organization.Organization, error) {
+	if _, err := s.authorize(ctx, orgID, actorID, organization.PermissionOrganizationRead); err != nil {
+		return nil, err
+	}
+	return s.orgs.GetByID(ctx, orgID)
+}
+
+// Update changes an organization's name or slug
+func (s *OrganizationService) Update(ctx context.Context, orgID uuid.UUID, req *organization.UpdateRequest, actorID uuid.UUID) (*organization.Organization, error) {
+	if _, err := s.authorize(ctx, orgID, actorID, organization.PermissionOrganizationUpdate); err != nil {
+		return nil, err
+	}
+
+	org, err := s.orgs.GetByID(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]interface{}{}
+	if req.Name != nil {
+		org.Name = strings.TrimSpace(*req.Name)
+		changes["name"] = org.Name
+	}
+	if req.Slug != nil {
+		slug := strings.ToLower(strings.TrimSpace(*req.Slug))
+		if err := organization.ValidateSlug(slug); err != nil {
+			return nil, err
+		}
+		org.Slug = slug
+		changes["slug"] = org.Slug
+	}
+	org.UpdatedAt = time.Now()
+
+	if err := s.orgs.Update(ctx, org); err != nil {
+		if errors.Is(err, organization.ErrSlugTaken) || errors.Is(err, organization.ErrOrganizationNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeOrganizationUpdated, org.ID, actorID, "Organization updated", changes)
+
+	return org, nil
+}
+
+// Delete deletes an organization along with its memberships
+func (s *OrganizationService) Delete(ctx context.Context, orgID, actorID uuid.UUID) error {
+	if _, err := s.authorize(ctx, orgID, actorID, organization.PermissionOrganizationDelete); err != nil {
+		return err
+	}
+
+	if err := s.orgs.Delete(ctx, orgID); err != nil {
+		if errors.Is(err, organization.ErrOrganizationNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeOrganizationDeleted, orgID, actorID, "Organization deleted", nil)
+
+	return nil
+}
+
+// ListMembers returns an organization's members
+func (s *OrganizationService) ListMembers(ctx context.Context, orgID, actorID uuid.UUID) ([]*organization.Membership, error) {
+	if _, err := s.authorize(ctx, orgID, actorID, organization.PermissionMembersRead); err != nil {
+		return nil, err
+	}
+	return s.memberships.ListByOrganization(ctx, orgID)
+}
+
+// AddMember adds an existing user to an organization. Members may only grant
+// roles whose permissions they hold themselves.
+func (s *OrganizationService) AddMember(ctx context.Context, orgID uuid.UUID, req *organization.AddMemberRequest, actorID uuid.UUID) (*organization.Membership, error) {
+	if !req.Role.IsValid() {
+		return nil, organization.ErrInvalidRole
+	}
+
+	actor, err := s.authorize(ctx, orgID, actorID, organization.PermissionMembersManage)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Role.Includes(req.Role) {
+		return nil, organization.ErrInsufficientRole
+	}
+
+	if _, err := s.userRepo.GetByID(ctx, req.UserID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.memberships.Get(ctx, orgID, req.UserID); err == nil {
+		return nil, organization.ErrAlreadyMember
+	} else if !errors.Is(err, organization.ErrNotMember) {
+		return nil, fmt.Errorf("failed to get organization membership: %w", err)
+	}
+
+	seatLimit, err := s.seatLimit(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	membership := &organization.Membership{
+		OrganizationID: orgID,
+		UserID:         req.UserID,
+		Role:           req.Role,
+		AddedBy:        actorID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	// Open invitations hold seats, since each may become a member
+	if err := s.memberships.AddWithinSeatLimit(ctx, membership, seatLimit, s.invitations != nil); err != nil {
+		if errors.Is(err, organization.ErrAlreadyMember) || errors.Is(err, organization.ErrSeatLimitReached) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to add organization member: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeOrganizationMemberAdded, orgID, actorID, "Organization member added", map[string]interface{}{
+		"user_id": req.UserID.String(),
+		"role":    string(req.Role),
+	})
+
+	return membership, nil
+}
+
+// UpdateMemberRole changes a member's role. The actor's role must include both
+// the member's current role and the new one.
+func (s *OrganizationService) UpdateMemberRole(ctx context.Context, orgID, userID uuid.UUID, role organization.Role, actorID uuid.UUID) (*organization.Membership, error) {
+	if !role.IsValid() {
+		return nil, organization.ErrInvalidRole
+	}
+
+	actor, err := s.authorize(ctx, orgID, actorID, organization.PermissionMembersManage)
+	if err != nil {
+		return nil, err
+	}
+
+	membership, err := s.memberships.Get(ctx, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Role.Includes(membership.Role) || !actor.Role.Includes(role) {
+		return nil, organization.ErrInsufficientRole
+	}
+	if membership.Role == role {
+		return membership, nil
+	}
+
+	if membership.Role == organization.RoleOwner {
+		if err := s.ensureAnotherOwner(ctx, orgID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.memberships.UpdateRole(ctx, orgID, userID, role); err != nil {
+		return nil, fmt.Errorf("failed to update organization member: %w", err)
+	}
+
+	previous := membership.Role
+	membership.Role = role
+	membership.UpdatedAt = time.Now()
+
+	s.log(ctx, audit.EventTypeOrganizationMemberRoleChanged, orgID, actorID, "Organization member role changed", map[string]interface{}{
+		"user_id":       userID.String(),
+		"previous_role": string(previous),
+		"role":          string(role),
+	})
+
+	return membership, nil
+}
+
+// RemoveMember removes a user from an organization. Members may always leave;
+// removing someone else requires a role that includes theirs.
+func (s *OrganizationService) RemoveMember(ctx context.Context, orgID, userID, actorID uuid.UUID) error {
+	var membership *organization.Membership
+	if userID == actorID {
+		m, err := s.memberships.Get(ctx, orgID, userID)
+		if err != nil {
+			return err
+		}
+		membership = m
+	} else {
+		actor, err := s.authorize(ctx, orgID, actorID, organization.PermissionMembersManage)
+		if err != nil {
+			return err
+		}
+
+		m, err := s.memberships.Get(ctx, orgID, userID)
+		if err != nil {
+			return err
+		}
+		if !actor.Role.Includes(m.Role) {
+			return organization.ErrInsufficientRole
+		}
+		membership = m
+	}
+
+	if membership.Role == organization.RoleOwner {
+		if err := s.ensureAnotherOwner(ctx, orgID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.memberships.Remove(ctx, orgID, userID); err != nil {
+		if errors.Is(err, organization.ErrNotMember) {
+			return err
+		}
+		return fmt.Errorf("failed to remove organization member: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeOrganizationMemberRemoved, orgID, actorID, "Organization member removed", map[string]interface{}{
+		"user_id": userID.String(),
+		"role":    string(membership.Role),
+	})
+
+	return nil
+}
+
+// Switch issues tokens acting in another organization, or in the user's personal
+// account when orgID is nil, continuing the session of the given token family
+func (s *OrganizationService) Switch(ctx context.Context, userID uuid.UUID, familyID string, orgID *uuid.UUID) (*auth.TokenPair, error) {
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if u.Status != user.StatusActive {
+		return nil, auth.ErrAccountInactive
+	}
+
+	var membership *organization.Membership
+	if orgID != nil {
+		membership, err = s.memberships.Get(ctx, *orgID, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.tokenService.SwitchOrganization(ctx, u, familyID, membership)
+}
+
+// authorize returns the actor's membership if their role grants the permission
+func (s *OrganizationService) authorize(ctx context.Context, orgID, actorID uuid.UUID, permission string) (*organization.Membership, error) {
+	membership, err := s.memberships.Get(ctx, orgID, actorID)
+	if err != nil {
+		// Non-members cannot tell an organization exists
+		if errors.Is(err, organization.ErrNotMember) {
+			return nil, organization.ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization membership: %w", err)
+	}
+
+	if !membership.Role.HasPermission(permission) {
+		return nil, organization.ErrInsufficientRole
+	}
+
+	return membership, nil
+}
+
+// seatLimit returns the team member limit of the organization's plan, or zero
+// if its seats are unlimited
+func (s *OrganizationService) seatLimit(ctx context.Context, orgID uuid.UUID) (int, error) {
+	if s.plans == nil {
+		return 0, nil
+	}
+
+	plan, err := s.plans.CurrentOrganizationPlan(ctx, orgID)
+	if err != nil {
+		if errors.Is(err, billing.ErrNoActiveSubscription) || errors.Is(err, billing.ErrSubscriptionNotFound) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get organization plan: %w", err)
+	}
+
+	// Plans without a team member limit allow any number of members
+	if plan.Limits.MaxTeamMembers <= 0 {
+		return 0, nil
+	}
+
+	return plan.Limits.MaxTeamMembers, nil
+}
+
+// checkMemberSeat returns ErrSeatLimitReached if the organization has no seat
+// for another member. It does not reserve the seat, so the membership must
+// still be added within the seat limit.
+func (s *OrganizationService) checkMemberSeat(ctx context.Context, orgID uuid.UUID) error {
+	seatLimit, err := s.seatLimit(ctx, orgID)
+	if err != nil || seatLimit == 0 {
+		return err
+	}
+
+	members, err := s.memberships.ListByOrganization(ctx, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to list organization members: %w", err)
+	}
+	if len(members) >= seatLimit {
+		return organization.ErrSeatLimitReached
+	}
+
+	return nil
+}
+
+// ensureAnotherOwner returns ErrLastOwner unless the organization has more than one owner
+func (s *OrganizationService) ensureAnotherOwner(ctx context.Context, orgID uuid.UUID) error {
+	owners, err := s.memberships.CountByRole(ctx, orgID, organization.RoleOwner)
+	if err != nil {
+		return fmt.Errorf("failed to count organization owners: %w", err)
+	}
+	if owners <= 1 {
+		return organization.ErrLastOwner
+	}
+	return nil
+}
+
+// log records organization changes when an audit service is configured
+func (s *OrganizationService) log(
+	ctx context.Context,
+	eventType audit.EventType,
+	orgID uuid.UUID,
+	actorID uuid.UUID,
+	description string,
+	metadata map[string]interface{},
+) {
+	if s.auditService == nil {
+		return
+	}
+
+	_, _ = s.auditService.Log(ctx, &audit.CreateLogRequest{
+		EventType:   eventType,
+		Severity:    audit.SeverityInfo,
+		ActorID:     &actorID,
+		ActorType:   audit.ActorTypeUser,
+		EntityType:  "organization",
+		EntityID:    orgID.String(),
+		Action:      string(eventType),
+		Description: description,
+		Metadata:    metadata,
+	})
+}
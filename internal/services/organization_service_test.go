@@ -0,0 +1,339 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/organization"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+type organizationFixture struct {
+	service      *services.OrganizationService
+	tokenService *services.TokenService
+	orgs         *InMemoryOrganizationRepository
+	memberships  *InMemoryMembershipRepository
+	users        *InMemoryUserRepository
+}
+
+func newOrganizationFixture(t *testing.T) *organizationFixture {
+	t.Helper()
+
+	users := NewInMemoryUserRepository()
+	orgs := NewInMemoryOrganizationRepository()
+	memberships := NewInMemoryMembershipRepository(orgs)
+
+	tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, users)
+	tokenService.SetTokenStore(NewInMemoryTokenStore())
+	tokenService.SetOrganizationMemberships(memberships)
+
+	return &organizationFixture{
+		service:      services.NewOrganizationService(orgs, memberships, users, tokenService),
+		tokenService: tokenService,
+		orgs:         orgs,
+		memberships:  memberships,
+		users:        users,
+	}
+}
+
+func (f *organizationFixture) user(t *testing.T) *user.User {
+	t.Helper()
+
+	id := uuid.New()
+	u := &user.User{
+		ID:       id,
+		Email:    id.String() + "@example.com",
+		Username: id.String(),
+		Status:   user.StatusActive,
+	}
+	require.NoError(t, f.users.Create(context.Background(), u))
+	return u
+}
+
+func (f *organizationFixture) create(t *testing.T, owner *user.User) *organization.Organization {
+	t.Helper()
+
+	org, err := f.service.Create(context.Background(), &organization.CreateRequest{
+		Name: "Acme",
+		Slug: "acme-" + uuid.NewString()[:8],
+	}, owner.ID)
+	require.NoError(t, err)
+	return org
+}
+
+func (f *organizationFixture) add(t *testing.T, org *organization.Organization, actor *user.User, role organization.Role) *user.User {
+	t.Helper()
+
+	member := f.user(t)
+	_, err := f.service.AddMember(context.Background(), org.ID, &organization.AddMemberRequest{
+		UserID: member.ID,
+		Role:   role,
+	}, actor.ID)
+	require.NoError(t, err)
+	return member
+}
+
+func TestOrganizationService_Create(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("creator becomes the owner", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		owner := f.user(t)
+
+		auditService := new(MockAuditService)
+		auditService.On("Log", ctx, mock.MatchedBy(func(req *audit.CreateLogRequest) bool {
+			return req.EventType == audit.EventTypeOrganizationCreated && *req.ActorID == owner.ID
+		})).Return(&audit.LogEntry{}, nil).Once()
+		f.service.SetAuditService(auditService)
+
+		org, err := f.service.Create(ctx, &organization.CreateRequest{Name: " Acme ", Slug: "Acme-Corp"}, owner.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Acme", org.Name)
+		assert.Equal(t, "acme-corp", org.Slug)
+
+		membership, err := f.memberships.Get(ctx, org.ID, owner.ID)
+		require.NoError(t, err)
+		assert.Equal(t, organization.RoleOwner, membership.Role)
+
+		listed, err := f.service.ListForUser(ctx, owner.ID)
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, "acme-corp", listed[0].Organization.Slug)
+		auditService.AssertExpectations(t)
+	})
+
+	t.Run("rejects invalid and taken slugs", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		owner := f.user(t)
+
+		_, err := f.service.Create(ctx, &organization.CreateRequest{Name: "Acme", Slug: "acme corp"}, owner.ID)
+		assert.ErrorIs(t, err, organization.ErrInvalidSlug)
+
+		_, err = f.service.Create(ctx, &organization.CreateRequest{Name: "Acme", Slug: "acme"}, owner.ID)
+		require.NoError(t, err)
+		_, err = f.service.Create(ctx, &organization.CreateRequest{Name: "Acme", Slug: "acme"}, owner.ID)
+		assert.ErrorIs(t, err, organization.ErrSlugTaken)
+	})
+}
+
+func TestOrganizationService_Roles(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("non-members cannot see the organization", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		org := f.create(t, f.user(t))
+
+		_, err := f.service.Get(ctx, org.ID, f.user(t).ID)
+		assert.ErrorIs(t, err, organization.ErrOrganizationNotFound)
+	})
+
+	t.Run("members can read but not manage", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		member := f.add(t, org, owner, organization.RoleMember)
+
+		_, err := f.service.Get(ctx, org.ID, member.ID)
+		require.NoError(t, err)
+
+		name := "Renamed"
+		_, err = f.service.Update(ctx, org.ID, &organization.UpdateRequest{Name: &name}, member.ID)
+		assert.ErrorIs(t, err, organization.ErrInsufficientRole)
+
+		_, err = f.service.AddMember(ctx, org.ID, &organization.AddMemberRequest{
+			UserID: f.user(t).ID,
+			Role:   organization.RoleMember,
+		}, member.ID)
+		assert.ErrorIs(t, err, organization.ErrInsufficientRole)
+	})
+
+	t.Run("admins cannot grant roles they do not include", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		admin := f.add(t, org, owner, organization.RoleAdmin)
+
+		member := f.add(t, org, admin, organization.RoleMember)
+
+		for _, role := range []organization.Role{organization.RoleOwner, organization.RoleBilling} {
+			_, err := f.service.AddMember(ctx, org.ID, &organization.AddMemberRequest{
+				UserID: f.user(t).ID,
+				Role:   role,
+			}, admin.ID)
+			assert.ErrorIs(t, err, organization.ErrInsufficientRole, role)
+
+			_, err = f.service.UpdateMemberRole(ctx, org.ID, member.ID, role, admin.ID)
+			assert.ErrorIs(t, err, organization.ErrInsufficientRole, role)
+		}
+
+		assert.ErrorIs(t, f.service.RemoveMember(ctx, org.ID, owner.ID, admin.ID), organization.ErrInsufficientRole)
+		assert.ErrorIs(t, f.service.Delete(ctx, org.ID, admin.ID), organization.ErrInsufficientRole)
+	})
+
+	t.Run("adding requires an existing non-member", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+
+		_, err := f.service.AddMember(ctx, org.ID, &organization.AddMemberRequest{
+			UserID: uuid.New(),
+			Role:   organization.RoleMember,
+		}, owner.ID)
+		assert.ErrorIs(t, err, user.ErrUserNotFound)
+
+		_, err = f.service.AddMember(ctx, org.ID, &organization.AddMemberRequest{
+			UserID: owner.ID,
+			Role:   organization.RoleMember,
+		}, owner.ID)
+		assert.ErrorIs(t, err, organization.ErrAlreadyMember)
+	})
+
+	t.Run("the last owner cannot leave or be demoted", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+
+		assert.ErrorIs(t, f.service.RemoveMember(ctx, org.ID, owner.ID, owner.ID), organization.ErrLastOwner)
+		_, err := f.service.UpdateMemberRole(ctx, org.ID, owner.ID, organization.RoleAdmin, owner.ID)
+		assert.ErrorIs(t, err, organization.ErrLastOwner)
+
+		second := f.add(t, org, owner, organization.RoleOwner)
+		require.NoError(t, f.service.RemoveMember(ctx, org.ID, owner.ID, owner.ID))
+
+		members, err := f.service.ListMembers(ctx, org.ID, second.ID)
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		assert.Equal(t, second.ID, members[0].UserID)
+	})
+
+	t.Run("members can leave", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		member := f.add(t, org, owner, organization.RoleMember)
+
+		require.NoError(t, f.service.RemoveMember(ctx, org.ID, member.ID, member.ID))
+
+		_, err := f.memberships.Get(ctx, org.ID, member.ID)
+		assert.ErrorIs(t, err, organization.ErrNotMember)
+	})
+}
+
+func TestOrganizationService_Switch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("tokens carry the organization through refresh", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		billing := f.add(t, org, owner, organization.RoleBilling)
+
+		initial, err := f.tokenService.GenerateTokenPair(ctx, billing)
+		require.NoError(t, err)
+
+		switched, err := f.service.Switch(ctx, billing.ID, initial.FamilyID, &org.ID)
+		require.NoError(t, err)
+		assert.Equal(t, initial.FamilyID, switched.FamilyID)
+
+		claims, err := f.tokenService.ValidateToken(ctx, switched.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		require.NotNil(t, claims.OrganizationID)
+		assert.Equal(t, org.ID, *claims.OrganizationID)
+		assert.Equal(t, string(organization.RoleBilling), claims.OrganizationRole)
+
+		// The refresh token from before the switch is no longer the family's current one
+		_, err = f.tokenService.RefreshTokens(ctx, initial.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrRefreshTokenReused)
+	})
+
+	t.Run("refreshed tokens keep the organization", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+
+		initial, err := f.tokenService.GenerateTokenPair(ctx, owner)
+		require.NoError(t, err)
+		switched, err := f.service.Switch(ctx, owner.ID, initial.FamilyID, &org.ID)
+		require.NoError(t, err)
+
+		refreshed, err := f.tokenService.RefreshTokens(ctx, switched.RefreshToken)
+		require.NoError(t, err)
+
+		claims, err := f.tokenService.ValidateToken(ctx, refreshed.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		require.NotNil(t, claims.OrganizationID)
+		assert.Equal(t, org.ID, *claims.OrganizationID)
+		assert.Equal(t, string(organization.RoleOwner), claims.OrganizationRole)
+
+		// Switching back to the personal account drops the claim
+		personal, err := f.service.Switch(ctx, owner.ID, refreshed.FamilyID, nil)
+		require.NoError(t, err)
+		claims, err = f.tokenService.ValidateToken(ctx, personal.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		assert.Nil(t, claims.OrganizationID)
+	})
+
+	t.Run("removed members fall back to their personal account", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+		member := f.add(t, org, owner, organization.RoleMember)
+
+		initial, err := f.tokenService.GenerateTokenPair(ctx, member)
+		require.NoError(t, err)
+		switched, err := f.service.Switch(ctx, member.ID, initial.FamilyID, &org.ID)
+		require.NoError(t, err)
+
+		require.NoError(t, f.service.RemoveMember(ctx, org.ID, member.ID, owner.ID))
+
+		refreshed, err := f.tokenService.RefreshTokens(ctx, switched.RefreshToken)
+		require.NoError(t, err)
+		claims, err := f.tokenService.ValidateToken(ctx, refreshed.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		assert.Nil(t, claims.OrganizationID)
+	})
+
+	t.Run("cannot switch into an organization the user does not belong to", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		org := f.create(t, f.user(t))
+		outsider := f.user(t)
+
+		initial, err := f.tokenService.GenerateTokenPair(ctx, outsider)
+		require.NoError(t, err)
+
+		_, err = f.service.Switch(ctx, outsider.ID, initial.FamilyID, &org.ID)
+		assert.ErrorIs(t, err, organization.ErrNotMember)
+	})
+
+	t.Run("cannot continue another user's family", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+
+		other, err := f.tokenService.GenerateTokenPair(ctx, f.user(t))
+		require.NoError(t, err)
+
+		_, err = f.service.Switch(ctx, owner.ID, other.FamilyID, &org.ID)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("callers without a session get no tokens", func(t *testing.T) {
+		f := newOrganizationFixture(t)
+		owner := f.user(t)
+		org := f.create(t, owner)
+
+		// API keys carry no refresh token family
+		switched, err := f.service.Switch(ctx, owner.ID, "", &org.ID)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		assert.Nil(t, switched)
+	})
+}
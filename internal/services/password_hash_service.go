@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/pkg/security"
+)
+
+// PasswordHashService reports how far stored password hashes are from the
+// preferred algorithm. Hashes are upgraded by AuthService as users sign in.
+type PasswordHashService struct {
+	reader   user.PasswordHashReader
+	registry *security.HasherRegistry
+}
+
+// NewPasswordHashService creates a new password hash service
+func NewPasswordHashService(reader user.PasswordHashReader, registry *security.HasherRegistry) *PasswordHashService {
+	return &PasswordHashService{
+		reader:   reader,
+		registry: registry,
+	}
+}
+
+// Report counts accounts by the algorithm and cost of their password hash
+func (s *PasswordHashService) Report(ctx context.Context) (*auth.PasswordHashReport, error) {
+	counts := make(map[security.HashInfo]int64)
+
+	err := s.reader.EachPasswordHash(ctx, func(hash string) error {
+		counts[s.registry.Describe(hash)]++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read password hashes: %w", err)
+	}
+
+	report := &auth.PasswordHashReport{
+		Preferred:  s.registry.Preferred().Name(),
+		Algorithms: make([]auth.PasswordHashCount, 0, len(counts)),
+	}
+	for info, count := range counts {
+		report.Total += count
+		if !info.Current {
+			report.Outdated += count
+		}
+		report.Algorithms = append(report.Algorithms, auth.PasswordHashCount{
+			Algorithm:  info.Algorithm,
+			Parameters: info.Parameters,
+			Current:    info.Current,
+			Count:      count,
+		})
+	}
+
+	// Largest groups first, so the report reads the same on every call
+	sort.Slice(report.Algorithms, func(i, j int) bool {
+		a, b := report.Algorithms[i], report.Algorithms[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Algorithm != b.Algorithm {
+			return a.Algorithm < b.Algorithm
+		}
+		return a.Parameters < b.Parameters
+	})
+
+	return report, nil
+}
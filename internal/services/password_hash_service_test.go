@@ -0,0 +1,89 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+	"github.com/victoralfred/um_sys/pkg/security"
+)
+
+func TestAuthService_UpgradesPasswordHashOnLogin(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthServiceFixture(t)
+
+	legacy := security.NewBcryptHasher(4)
+	registry := security.NewHasherRegistry(f.hasher, legacy)
+
+	bcryptHash, err := legacy.HashPassword("OldPassword1")
+	require.NoError(t, err)
+	f.user.PasswordHash = bcryptHash
+
+	userRepo := NewInMemoryUserRepository()
+	require.NoError(t, userRepo.Create(ctx, f.user))
+	service := services.NewAuthService(userRepo, f.tokenService, registry, nil, f.mailSender, services.DefaultAuthConfig())
+
+	login := func(password string) error {
+		_, _, err := service.Login(ctx, &auth.LoginRequest{Email: f.user.Email, Password: password})
+		return err
+	}
+
+	// A failed sign-in leaves the hash alone
+	assert.ErrorIs(t, login("WrongPassword1"), auth.ErrInvalidCredentials)
+	stored, err := userRepo.GetByID(ctx, f.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, bcryptHash, stored.PasswordHash)
+
+	require.NoError(t, login("OldPassword1"))
+
+	stored, err = userRepo.GetByID(ctx, f.user.ID)
+	require.NoError(t, err)
+	assert.True(t, f.hasher.Matches(stored.PasswordHash))
+	assert.False(t, registry.NeedsRehash(stored.PasswordHash))
+	assert.Nil(t, stored.PasswordChangedAt, "an upgrade is not a password change")
+
+	// The upgraded hash still accepts the same password
+	require.NoError(t, login("OldPassword1"))
+}
+
+func TestPasswordHashService_Report(t *testing.T) {
+	ctx := context.Background()
+	argon := security.NewPasswordHasher()
+	legacy := security.NewBcryptHasher(4)
+	registry := security.NewHasherRegistry(argon, legacy)
+	userRepo := NewInMemoryUserRepository()
+
+	create := func(hasher security.Hasher) {
+		hash, err := hasher.HashPassword("Secret123")
+		require.NoError(t, err)
+		id := uuid.New()
+		require.NoError(t, userRepo.Create(ctx, &user.User{
+			ID:           id,
+			Email:        id.String() + "@example.com",
+			Username:     id.String(),
+			PasswordHash: hash,
+		}))
+	}
+	create(argon)
+	create(legacy)
+	create(legacy)
+	create(security.NewBcryptHasher(5))
+
+	report, err := services.NewPasswordHashService(userRepo, registry).Report(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(4), report.Total)
+	assert.Equal(t, int64(3), report.Outdated)
+	assert.Equal(t, "argon2id", report.Preferred)
+	assert.Equal(t, []auth.PasswordHashCount{
+		{Algorithm: "bcrypt", Parameters: "cost=4", Count: 2},
+		{Algorithm: "argon2id", Parameters: "m=65536,t=3,p=2", Current: true, Count: 1},
+		{Algorithm: "bcrypt", Parameters: "cost=5", Count: 1},
+	}, report.Algorithms)
+}
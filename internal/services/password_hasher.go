@@ -6,6 +6,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/pkg/security"
 )
 
 // BCryptPasswordHasher implements password hashing using bcrypt
@@ -77,3 +78,30 @@ func (h *BCryptPasswordHasher) validatePasswordStrength(password string) error {
 
 	return nil
 }
+
+// RegistryPasswordHasher exposes a hasher registry as an auth.PasswordHasher, so
+// password confirmations accept hashes from any registered algorithm
+type RegistryPasswordHasher struct {
+	registry *security.HasherRegistry
+}
+
+// NewRegistryPasswordHasher creates a password hasher backed by a hasher registry
+func NewRegistryPasswordHasher(registry *security.HasherRegistry) *RegistryPasswordHasher {
+	return &RegistryPasswordHasher{
+		registry: registry,
+	}
+}
+
+// HashPassword hashes a password with the registry's preferred algorithm
+func (h *RegistryPasswordHasher) HashPassword(password string) (string, error) {
+	return h.registry.HashPassword(password)
+}
+
+// VerifyPassword verifies a password against a hash
+func (h *RegistryPasswordHasher) VerifyPassword(password, hash string) error {
+	if password == "" || hash == "" || !h.registry.VerifyPassword(password, hash) {
+		return auth.ErrInvalidCredentials
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mail"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/pkg/security"
+)
+
+// expiryWarningBatchSize caps how many accounts are loaded per query when sending warnings
+const expiryWarningBatchSize = 100
+
+// PasswordPolicyConfig holds the password reuse and rotation policy
+type PasswordPolicyConfig struct {
+	// HistoryDepth is how many previous passwords cannot be reused, besides the
+	// current one; zero disables the reuse check
+	HistoryDepth int
+
+	// MaxAge is how long a password may be used before it must be changed at the
+	// next sign-in; zero means passwords do not expire
+	MaxAge time.Duration
+
+	// WarningPeriod is how long before expiry users are warned by email
+	WarningPeriod time.Duration
+
+	// WarningInterval is how often accounts to warn are looked for
+	WarningInterval time.Duration
+
+	// ChangePasswordURL is the page where users change their password
+	ChangePasswordURL string
+}
+
+// DefaultPasswordPolicyConfig returns the default password policy: no reuse of
+// the last five passwords and no expiry
+func DefaultPasswordPolicyConfig() PasswordPolicyConfig {
+	return PasswordPolicyConfig{
+		HistoryDepth:      5,
+		WarningPeriod:     14 * 24 * time.Hour,
+		WarningInterval:   time.Hour,
+		ChangePasswordURL: "http://localhost:8080/account/password",
+	}
+}
+
+// PasswordPolicyService enforces password history and maximum password age
+type PasswordPolicyService struct {
+	history    auth.PasswordHistoryRepository
+	expiry     auth.PasswordExpiryRepository
+	hasher     security.Hasher
+	mailSender mail.Sender
+	config     PasswordPolicyConfig
+	logger     *zap.Logger
+}
+
+// NewPasswordPolicyService creates a new password policy service. The hasher must
+// verify every algorithm stored hashes may use.
+func NewPasswordPolicyService(history auth.PasswordHistoryRepository, hasher security.Hasher, config PasswordPolicyConfig) *PasswordPolicyService {
+	return &PasswordPolicyService{
+		history: history,
+		hasher:  hasher,
+		config:  config,
+		logger:  zap.NewNop(),
+	}
+}
+
+// SetLogger sets the logger for warnings that could not be sent
+func (s *PasswordPolicyService) SetLogger(logger *zap.Logger) {
+	s.logger = logger
+}
+
+// SetExpiryWarnings enables emails warning users that their password expires soon
+func (s *PasswordPolicyService) SetExpiryWarnings(repo auth.PasswordExpiryRepository, mailSender mail.Sender) {
+	s.expiry = repo
+	s.mailSender = mailSender
+}
+
+// CheckReuse returns ErrPasswordReused if the password is the account's current
+// password or one of its recent ones
+func (s *PasswordPolicyService) CheckReuse(ctx context.Context, u *user.User, password string) error {
+	if s.config.HistoryDepth <= 0 {
+		return nil
+	}
+
+	entries, err := s.history.ListRecent(ctx, u.ID, s.config.HistoryDepth)
+	if err != nil {
+		return fmt.Errorf("failed to load password history: %w", err)
+	}
+
+	hashes := make([]string, 0, len(entries)+1)
+	if u.PasswordHash != "" {
+		hashes = append(hashes, u.PasswordHash)
+	}
+	for _, entry := range entries {
+		hashes = append(hashes, entry.PasswordHash)
+	}
+
+	history := security.NewPasswordHistory(len(hashes))
+	history.SetHasher(s.hasher)
+
+	used, err := history.IsPasswordUsed(password, hashes)
+	if err != nil {
+		return fmt.Errorf("failed to check password history: %w", err)
+	}
+	if used {
+		return auth.ErrPasswordReused
+	}
+
+	return nil
+}
+
+// Remember records the hash of a password the account is about to stop using,
+// keeping only as many as the history depth
+func (s *PasswordPolicyService) Remember(ctx context.Context, userID uuid.UUID, previousHash string) error {
+	if s.config.HistoryDepth <= 0 || previousHash == "" {
+		return nil
+	}
+
+	if err := s.history.Add(ctx, &auth.PasswordHistoryEntry{
+		ID:           uuid.New(),
+		UserID:       userID,
+		PasswordHash: previousHash,
+		CreatedAt:    time.Now(),
+	}); err != nil {
+		return fmt.Errorf("failed to record password history: %w", err)
+	}
+
+	if err := s.history.Prune(ctx, userID, s.config.HistoryDepth); err != nil {
+		return fmt.Errorf("failed to prune password history: %w", err)
+	}
+
+	return nil
+}
+
+// ExpiresAt returns when the account's password expires, or nil if passwords do not expire
+func (s *PasswordPolicyService) ExpiresAt(u *user.User) *time.Time {
+	if s.config.MaxAge <= 0 {
+		return nil
+	}
+
+	expiresAt := passwordSetAt(u).Add(s.config.MaxAge)
+	return &expiresAt
+}
+
+// IsExpired checks if the account must change its password before signing in
+func (s *PasswordPolicyService) IsExpired(u *user.User) bool {
+	expiresAt := s.ExpiresAt(u)
+	return expiresAt != nil && time.Now().After(*expiresAt)
+}
+
+// SendExpiryWarnings emails every account whose password expires within the
+// warning period and has not been warned about it yet. It returns how many were warned.
+// Every instance runs it; each account is claimed by one of them before it is emailed.
+func (s *PasswordPolicyService) SendExpiryWarnings(ctx context.Context) (int, error) {
+	if s.config.MaxAge <= 0 || s.expiry == nil || s.mailSender == nil {
+		return 0, nil
+	}
+
+	// Passwords set in this window expire between now and the end of the warning period
+	from := time.Now().Add(-s.config.MaxAge)
+	to := from.Add(s.config.WarningPeriod)
+
+	warned := 0
+	var failed []*auth.PasswordExpiryNotice
+	var claimErr error
+	for {
+		notices, err := s.expiry.ClaimUnwarned(ctx, from, to, expiryWarningBatchSize)
+		if err != nil {
+			claimErr = fmt.Errorf("failed to claim expiring passwords: %w", err)
+			break
+		}
+
+		// One undeliverable address does not hold up everyone else's warning
+		for _, notice := range notices {
+			if err := s.mailSender.Send(ctx, s.expiryWarningMessage(notice)); err != nil {
+				s.logger.Error("Failed to send password expiry warning",
+					zap.String("user_id", notice.UserID.String()), zap.Error(err))
+				failed = append(failed, notice)
+				continue
+			}
+			warned++
+		}
+
+		if len(notices) < expiryWarningBatchSize {
+			break
+		}
+	}
+
+	// Released only now so this run does not claim them again; the next run retries them
+	for _, notice := range failed {
+		if err := s.expiry.ReleaseWarning(ctx, notice.UserID, notice.SetAt); err != nil {
+			s.logger.Error("Failed to release password expiry warning",
+				zap.String("user_id", notice.UserID.String()), zap.Error(err))
+		}
+	}
+
+	return warned, claimErr
+}
+
+// StartExpiryWarnings sends expiry warnings every warning interval until ctx is cancelled
+func (s *PasswordPolicyService) StartExpiryWarnings(ctx context.Context) {
+	if s.config.MaxAge <= 0 || s.config.WarningInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.config.WarningInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// Accounts that were not warned are picked up again next tick
+				_, _ = s.SendExpiryWarnings(ctx)
+			}
+		}
+	}()
+}
+
+// expiryWarningMessage builds the email that warns of an expiring password
+func (s *PasswordPolicyService) expiryWarningMessage(notice *auth.PasswordExpiryNotice) *mail.Message {
+	expiresAt := notice.SetAt.Add(s.config.MaxAge)
+	days := int(time.Until(expiresAt).Hours()/24) + 1
+
+	return &mail.Message{
+		To:      []string{notice.Email},
+		Subject: "Your password expires soon",
+		TextBody: fmt.Sprintf(
+			"Hi %s,\n\nYour password expires in %d days, on %s. Choose a new one here:\n\n%s\n\n"+
+				"Once it expires you will be asked to change it the next time you sign in.\n",
+			notice.Username, days, expiresAt.UTC().Format("January 2, 2006"), s.config.ChangePasswordURL,
+		),
+	}
+}
+
+// passwordSetAt returns when the account's current password was set; accounts
+// that never changed it have had it since they were created
+func passwordSetAt(u *user.User) time.Time {
+	if u.PasswordChangedAt != nil {
+		return *u.PasswordChangedAt
+	}
+	return u.CreatedAt
+}
@@ -0,0 +1,246 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mail"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+	"github.com/victoralfred/um_sys/pkg/security"
+)
+
+type passwordPolicyFixture struct {
+	auth     *services.AuthService
+	policy   *services.PasswordPolicyService
+	history  *InMemoryPasswordHistoryRepository
+	userRepo *InMemoryUserRepository
+	user     *user.User
+}
+
+func newPasswordPolicyFixture(t *testing.T, config services.PasswordPolicyConfig) *passwordPolicyFixture {
+	t.Helper()
+
+	f := newAuthServiceFixture(t)
+	f.user.CreatedAt = time.Now()
+
+	userRepo := NewInMemoryUserRepository()
+	require.NoError(t, userRepo.Create(context.Background(), f.user))
+
+	validator := security.NewPasswordValidator(&security.PasswordPolicy{MinLength: 8})
+	authService := services.NewAuthService(userRepo, f.tokenService, f.hasher, validator, f.mailSender, services.DefaultAuthConfig())
+
+	history := NewInMemoryPasswordHistoryRepository()
+	policy := services.NewPasswordPolicyService(history, f.hasher, config)
+	authService.SetPasswordPolicyService(policy)
+
+	return &passwordPolicyFixture{
+		auth:     authService,
+		policy:   policy,
+		history:  history,
+		userRepo: userRepo,
+		user:     f.user,
+	}
+}
+
+// age backdates when the user's current password was set
+func (f *passwordPolicyFixture) age(t *testing.T, by time.Duration) {
+	t.Helper()
+
+	u, err := f.userRepo.GetByID(context.Background(), f.user.ID)
+	require.NoError(t, err)
+	setAt := time.Now().Add(-by)
+	u.PasswordChangedAt = &setAt
+	require.NoError(t, f.userRepo.Update(context.Background(), u))
+}
+
+func TestPasswordPolicyService_History(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("refuses the current and recent passwords", func(t *testing.T) {
+		config := services.DefaultPasswordPolicyConfig()
+		config.HistoryDepth = 2
+		f := newPasswordPolicyFixture(t, config)
+
+		err := f.auth.ChangePassword(ctx, f.user.ID, "OldPassword1", "OldPassword1")
+		assert.ErrorIs(t, err, auth.ErrPasswordReused)
+
+		require.NoError(t, f.auth.ChangePassword(ctx, f.user.ID, "OldPassword1", "NewPassword2"))
+		require.NoError(t, f.auth.ChangePassword(ctx, f.user.ID, "NewPassword2", "NewPassword3"))
+
+		for _, reused := range []string{"OldPassword1", "NewPassword2", "NewPassword3"} {
+			err = f.auth.ChangePassword(ctx, f.user.ID, "NewPassword3", reused)
+			assert.ErrorIs(t, err, auth.ErrPasswordReused, reused)
+		}
+	})
+
+	t.Run("forgets passwords beyond the history depth", func(t *testing.T) {
+		config := services.DefaultPasswordPolicyConfig()
+		config.HistoryDepth = 2
+		f := newPasswordPolicyFixture(t, config)
+
+		require.NoError(t, f.auth.ChangePassword(ctx, f.user.ID, "OldPassword1", "NewPassword2"))
+		require.NoError(t, f.auth.ChangePassword(ctx, f.user.ID, "NewPassword2", "NewPassword3"))
+		require.NoError(t, f.auth.ChangePassword(ctx, f.user.ID, "NewPassword3", "NewPassword4"))
+
+		entries, err := f.history.ListRecent(ctx, f.user.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+
+		assert.NoError(t, f.auth.ChangePassword(ctx, f.user.ID, "NewPassword4", "OldPassword1"))
+	})
+
+	t.Run("zero depth allows reuse", func(t *testing.T) {
+		config := services.DefaultPasswordPolicyConfig()
+		config.HistoryDepth = 0
+		f := newPasswordPolicyFixture(t, config)
+
+		assert.NoError(t, f.auth.ChangePassword(ctx, f.user.ID, "OldPassword1", "OldPassword1"))
+	})
+}
+
+func TestPasswordPolicyService_Expiry(t *testing.T) {
+	ctx := context.Background()
+
+	config := services.DefaultPasswordPolicyConfig()
+	config.MaxAge = 90 * 24 * time.Hour
+
+	login := func(f *passwordPolicyFixture, password string) error {
+		_, _, err := f.auth.Login(ctx, &auth.LoginRequest{Email: f.user.Email, Password: password})
+		return err
+	}
+
+	changeExpired := func(f *passwordPolicyFixture, password, newPassword string) error {
+		return f.auth.ChangeExpiredPassword(ctx, &auth.ExpiredPasswordChangeRequest{
+			LoginRequest: auth.LoginRequest{Email: f.user.Email, Password: password},
+			NewPassword:  newPassword,
+		})
+	}
+
+	t.Run("refuses sign-in with an expired password until it is changed", func(t *testing.T) {
+		f := newPasswordPolicyFixture(t, config)
+		f.age(t, 91*24*time.Hour)
+
+		assert.ErrorIs(t, login(f, "OldPassword1"), auth.ErrPasswordExpired)
+
+		assert.ErrorIs(t, changeExpired(f, "WrongPassword1", "NewPassword2"), auth.ErrInvalidCredentials)
+		assert.ErrorIs(t, changeExpired(f, "OldPassword1", "OldPassword1"), auth.ErrPasswordReused)
+		require.NoError(t, changeExpired(f, "OldPassword1", "NewPassword2"))
+
+		assert.NoError(t, login(f, "NewPassword2"))
+	})
+
+	t.Run("only replaces expired passwords", func(t *testing.T) {
+		f := newPasswordPolicyFixture(t, config)
+		f.age(t, 30*24*time.Hour)
+
+		assert.NoError(t, login(f, "OldPassword1"))
+		assert.ErrorIs(t, changeExpired(f, "OldPassword1", "NewPassword2"), auth.ErrPasswordNotExpired)
+	})
+
+	t.Run("passwords never expire without a maximum age", func(t *testing.T) {
+		f := newPasswordPolicyFixture(t, services.DefaultPasswordPolicyConfig())
+		f.age(t, 10*365*24*time.Hour)
+
+		stored, err := f.userRepo.GetByID(ctx, f.user.ID)
+		require.NoError(t, err)
+		assert.Nil(t, f.policy.ExpiresAt(stored))
+		assert.NoError(t, login(f, "OldPassword1"))
+	})
+}
+
+func TestPasswordPolicyService_SendExpiryWarnings(t *testing.T) {
+	ctx := context.Background()
+
+	config := services.DefaultPasswordPolicyConfig()
+	config.MaxAge = 90 * 24 * time.Hour
+	config.WarningPeriod = 7 * 24 * time.Hour
+	config.ChangePasswordURL = "https://app.example.com/account/password"
+
+	expiring := &auth.PasswordExpiryNotice{
+		UserID:   uuid.New(),
+		Email:    "expiring@example.com",
+		Username: "expiring",
+		SetAt:    time.Now().Add(-85 * 24 * time.Hour),
+	}
+	notYet := &auth.PasswordExpiryNotice{
+		UserID:   uuid.New(),
+		Email:    "recent@example.com",
+		Username: "recent",
+		SetAt:    time.Now().Add(-30 * 24 * time.Hour),
+	}
+
+	var sent []*mail.Message
+	mailSender := new(MockMailSender)
+	mailSender.On("Send", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		sent = append(sent, args.Get(1).(*mail.Message))
+	}).Return(nil)
+
+	service := services.NewPasswordPolicyService(NewInMemoryPasswordHistoryRepository(), security.NewPasswordHasher(), config)
+	service.SetExpiryWarnings(NewInMemoryPasswordExpiryRepository(expiring, notYet), mailSender)
+
+	warned, err := service.SendExpiryWarnings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, warned)
+	require.Len(t, sent, 1)
+	assert.Equal(t, []string{"expiring@example.com"}, sent[0].To)
+	assert.Contains(t, sent[0].TextBody, "expires in 5 days")
+	assert.Contains(t, sent[0].TextBody, config.ChangePasswordURL)
+
+	// Each password is only warned about once
+	warned, err = service.SendExpiryWarnings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, warned)
+	assert.Len(t, sent, 1)
+}
+
+func TestPasswordPolicyService_SendExpiryWarningsSkipsFailures(t *testing.T) {
+	ctx := context.Background()
+
+	config := services.DefaultPasswordPolicyConfig()
+	config.MaxAge = 90 * 24 * time.Hour
+	config.WarningPeriod = 7 * 24 * time.Hour
+
+	undeliverable := &auth.PasswordExpiryNotice{
+		UserID:   uuid.New(),
+		Email:    "bounces@example.com",
+		Username: "bounces",
+		SetAt:    time.Now().Add(-86 * 24 * time.Hour),
+	}
+	deliverable := &auth.PasswordExpiryNotice{
+		UserID:   uuid.New(),
+		Email:    "expiring@example.com",
+		Username: "expiring",
+		SetAt:    time.Now().Add(-85 * 24 * time.Hour),
+	}
+
+	isTo := func(address string) interface{} {
+		return mock.MatchedBy(func(msg *mail.Message) bool { return msg.To[0] == address })
+	}
+	mailSender := new(MockMailSender)
+	mailSender.On("Send", ctx, isTo(undeliverable.Email)).Return(errors.New("mailbox unavailable")).Once()
+	mailSender.On("Send", ctx, isTo(deliverable.Email)).Return(nil).Once()
+
+	service := services.NewPasswordPolicyService(NewInMemoryPasswordHistoryRepository(), security.NewPasswordHasher(), config)
+	service.SetExpiryWarnings(NewInMemoryPasswordExpiryRepository(undeliverable, deliverable), mailSender)
+
+	warned, err := service.SendExpiryWarnings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, warned)
+
+	// The failed warning is retried on the next run
+	mailSender.On("Send", ctx, isTo(undeliverable.Email)).Return(nil).Once()
+
+	warned, err = service.SendExpiryWarnings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, warned)
+	mailSender.AssertExpectations(t)
+}
@@ -2,11 +2,13 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/victoralfred/um_sys/internal/domain/organization"
 	"github.com/victoralfred/um_sys/internal/domain/rbac"
 )
 
@@ -16,6 +18,7 @@ type RBACService struct {
 	permissionRepo rbac.PermissionRepository
 	policyRepo     rbac.PolicyRepository
 	cache          rbac.CacheService
+	memberships    organization.MembershipRepository
 }
 
 // NewRBACService creates a new RBAC service
@@ -33,6 +36,11 @@ func NewRBACService(
 	}
 }
 
+// SetOrganizationMemberships enables access checks scoped to an organization
+func (s *RBACService) SetOrganizationMemberships(memberships organization.MembershipRepository) {
+	s.memberships = memberships
+}
+
 // CreateRole creates a new role
 func (s *RBACService) CreateRole(ctx context.Context, role *rbac.Role) error {
 	// Check if role already exists
@@ -337,6 +345,10 @@ func (s *RBACService) GetRolePermissions(ctx context.Context, roleID uuid.UUID)
 
 // CheckAccess checks if a user has access to a resource/action
 func (s *RBACService) CheckAccess(ctx context.Context, req *rbac.AccessRequest) (*rbac.AccessResponse, error) {
+	if organizationID, ok := req.OrganizationID(); ok && s.memberships != nil {
+		return s.checkOrganizationAccess(ctx, req, organizationID)
+	}
+
 	// Check basic permissions first
 	hasPermission, err := s.permissionRepo.HasPermission(ctx, req.UserID, req.Resource, req.Action)
 	if err != nil {
@@ -365,6 +377,50 @@ func (s *RBACService) CheckAccess(ctx context.Context, req *rbac.AccessRequest)
 	}, nil
 }
 
+// checkOrganizationAccess decides a request scoped to an organization by the
+// user's role there. Global roles grant nothing inside an organization, except
+// to super admins.
+func (s *RBACService) checkOrganizationAccess(ctx context.Context, req *rbac.AccessRequest, organizationID uuid.UUID) (*rbac.AccessResponse, error) {
+	superAdmin, err := s.roleRepo.HasRole(ctx, req.UserID, rbac.RoleSuperAdmin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check role: %w", err)
+	}
+	if superAdmin {
+		return &rbac.AccessResponse{
+			Allowed: true,
+			Reason:  "permission granted through role",
+		}, nil
+	}
+
+	reason := "not a member of the organization"
+	membership, err := s.memberships.Get(ctx, organizationID, req.UserID)
+	switch {
+	case err == nil:
+		if membership.Role.HasPermission(req.Resource + ":" + req.Action) {
+			return &rbac.AccessResponse{
+				Allowed: true,
+				Reason:  "permission granted through organization role",
+			}, nil
+		}
+		reason = "insufficient organization role"
+	case !errors.Is(err, organization.ErrNotMember):
+		return nil, fmt.Errorf("failed to get organization membership: %w", err)
+	}
+
+	// Policies may still grant access, for example to a resource's owner
+	if s.policyRepo != nil {
+		policyResp, err := s.policyRepo.EvaluatePolicies(ctx, req)
+		if err == nil && policyResp != nil && policyResp.Allowed {
+			return policyResp, nil
+		}
+	}
+
+	return &rbac.AccessResponse{
+		Allowed: false,
+		Reason:  reason,
+	}, nil
+}
+
 // HasPermission checks if a user has a specific permission
 func (s *RBACService) HasPermission(ctx context.Context, userID uuid.UUID, resource, action string) (bool, error) {
 	hasPermission, err := s.permissionRepo.HasPermission(ctx, userID, resource, action)
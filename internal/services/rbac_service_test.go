@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/victoralfred/um_sys/internal/domain/organization"
 	"github.com/victoralfred/um_sys/internal/domain/rbac"
 	"github.com/victoralfred/um_sys/internal/services"
 )
@@ -327,6 +328,47 @@ func TestRBACService_CheckAccess(t *testing.T) {
 	})
 }
 
+func TestRBACService_CheckAccess_Organization(t *testing.T) {
+	ctx := context.Background()
+
+	f := newOrganizationFixture(t)
+	owner := f.user(t)
+	org := f.create(t, owner)
+	member := f.add(t, org, owner, organization.RoleMember)
+
+	mockRoleRepo := new(MockRoleRepository)
+	mockRoleRepo.On("HasRole", ctx, mock.Anything, rbac.RoleSuperAdmin).Return(false, nil)
+	// Global permissions are never consulted for organization-scoped requests
+	mockPermRepo := new(MockPermissionRepository)
+
+	rbacService := services.NewRBACService(mockRoleRepo, mockPermRepo, nil, nil)
+	rbacService.SetOrganizationMemberships(f.memberships)
+
+	check := func(userID uuid.UUID, resource, action string) *rbac.AccessResponse {
+		resp, err := rbacService.CheckAccess(ctx, &rbac.AccessRequest{
+			UserID:   userID,
+			Resource: resource,
+			Action:   action,
+			Context:  map[string]interface{}{rbac.ContextOrganizationID: org.ID.String()},
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	assert.True(t, check(owner.ID, "billing", "manage").Allowed)
+	assert.True(t, check(member.ID, "members", "read").Allowed)
+
+	resp := check(member.ID, "billing", "view")
+	assert.False(t, resp.Allowed)
+	assert.Equal(t, "insufficient organization role", resp.Reason)
+
+	resp = check(f.user(t).ID, "organization", "read")
+	assert.False(t, resp.Allowed)
+	assert.Equal(t, "not a member of the organization", resp.Reason)
+
+	mockPermRepo.AssertNotCalled(t, "HasPermission", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestRBACService_InitializeSystemRoles(t *testing.T) {
 	ctx := context.Background()
 
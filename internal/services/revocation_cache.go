@@ -0,0 +1,86 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// revocationCacheLimit bounds how many tokens the cache remembers at once
+const revocationCacheLimit = 10000
+
+// revocationCache remembers for a short while that access tokens were found
+// unrevoked, so that authenticating each request does not query the token
+// store. A nil cache remembers nothing.
+type revocationCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]time.Time // token ID to when the lookup goes stale
+}
+
+func newRevocationCache(ttl time.Duration) *revocationCache {
+	return &revocationCache{
+		ttl:     ttl,
+		entries: make(map[string]time.Time),
+	}
+}
+
+// unrevoked reports whether the token was recently found unrevoked
+func (c *revocationCache) unrevoked(tokenID string, now time.Time) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	staleAt, ok := c.entries[tokenID]
+	return ok && now.Before(staleAt)
+}
+
+// remember records that the token was found unrevoked
+func (c *revocationCache) remember(tokenID string, now time.Time) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= revocationCacheLimit {
+		for id, staleAt := range c.entries {
+			if !now.Before(staleAt) {
+				delete(c.entries, id)
+			}
+		}
+		// Still full of fresh entries: start over rather than grow without bound
+		if len(c.entries) >= revocationCacheLimit {
+			c.entries = make(map[string]time.Time)
+		}
+	}
+
+	c.entries[tokenID] = now.Add(c.ttl)
+}
+
+// forget drops a token so that its next use is looked up again
+func (c *revocationCache) forget(tokenID string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, tokenID)
+}
+
+// clear drops every token, for revocations that cover tokens by family or user
+func (c *revocationCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]time.Time)
+}
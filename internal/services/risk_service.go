@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/mail"
+	"github.com/victoralfred/um_sys/internal/domain/risk"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+)
+
+// earthRadiusKm is the mean radius used for great-circle distances
+const earthRadiusKm = 6371.0
+
+// userAgentVersions matches version numbers, which change with every browser update
+var userAgentVersions = regexp.MustCompile(`[0-9][0-9._]*`)
+
+// RiskConfig holds the scoring rules for risk-based sign-in.
+// Each signal adds points to a sign-in's score, and the score decides whether the
+// sign-in is allowed, needs a second factor or is blocked.
+type RiskConfig struct {
+	NewDeviceScore        int // Device not seen in the user's recent sign-ins
+	NewNetworkScore       int // Neither the IP address nor its network seen before
+	ImpossibleTravelScore int // Too far from the previous sign-in to have travelled there
+	FailedAttemptScore    int // Per failed attempt since the last successful sign-in
+	MaxFailedAttemptScore int
+
+	StepUpThreshold int // Score at which a second factor is required
+	BlockThreshold  int // Score at which the sign-in is refused
+
+	// MaxTravelSpeed is the fastest plausible journey between sign-ins, in km/h.
+	// Moves shorter than MinTravelDistance km are ignored as GeoIP noise.
+	MaxTravelSpeed    float64
+	MinTravelDistance float64
+
+	// HistorySize is how many recent sign-ins a new one is compared with
+	HistorySize int
+}
+
+// DefaultRiskConfig returns the default risk scoring rules.
+// A new device alone only triggers a notification; a new device on a new network,
+// or impossible travel, needs a second factor; impossible travel on a new device is blocked.
+func DefaultRiskConfig() RiskConfig {
+	return RiskConfig{
+		NewDeviceScore:        30,
+		NewNetworkScore:       20,
+		ImpossibleTravelScore: 60,
+		FailedAttemptScore:    10,
+		MaxFailedAttemptScore: 30,
+		StepUpThreshold:       50,
+		BlockThreshold:        90,
+		MaxTravelSpeed:        1000,
+		MinTravelDistance:     300,
+		HistorySize:           50,
+	}
+}
+
+// RiskService scores sign-ins against the user's sign-in history
+type RiskService struct {
+	history      risk.LoginHistoryRepository
+	locator      risk.Locator
+	mailSender   mail.Sender
+	auditService audit.AuditService
+	config       RiskConfig
+	logger       *zap.Logger
+}
+
+// NewRiskService creates a new risk service
+func NewRiskService(history risk.LoginHistoryRepository, config RiskConfig) *RiskService {
+	return &RiskService{
+		history: history,
+		config:  config,
+		logger:  zap.NewNop(),
+	}
+}
+
+// SetLogger sets the logger for notifications that fail after a sign-in has returned
+func (s *RiskService) SetLogger(logger *zap.Logger) {
+	s.logger = logger
+}
+
+// SetLocator enables the network and travel signals, which need GeoIP data
+func (s *RiskService) SetLocator(locator risk.Locator) {
+	s.locator = locator
+}
+
+// SetMailSender enables "new sign-in" notifications
+func (s *RiskService) SetMailSender(mailSender mail.Sender) {
+	s.mailSender = mailSender
+}
+
+// SetAuditService records every risk decision in the audit log
+func (s *RiskService) SetAuditService(auditService audit.AuditService) {
+	s.auditService = auditService
+}
+
+// Assess scores a sign-in by a user who has already proved who they are.
+// A user without any sign-in history has nothing to compare with, so only
+// their failed attempts count.
+func (s *RiskService) Assess(ctx context.Context, u *user.User, ip, userAgent string) (*risk.Assessment, error) {
+	assessment := &risk.Assessment{
+		DeviceFingerprint: DeviceFingerprint(userAgent),
+		IPAddress:         ip,
+		UserAgent:         userAgent,
+		Location:          s.locate(ip),
+	}
+
+	events, err := s.history.ListRecent(ctx, u.ID, s.config.HistorySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sign-in history: %w", err)
+	}
+
+	if len(events) > 0 {
+		if !knownDevice(events, assessment.DeviceFingerprint) {
+			assessment.AddSignal(risk.SignalNewDevice, s.config.NewDeviceScore)
+		}
+		if !knownNetwork(events, ip, assessment.Location) {
+			assessment.AddSignal(risk.SignalNewNetwork, s.config.NewNetworkScore)
+		}
+		if s.impossibleTravel(events[0], assessment.Location) {
+			assessment.AddSignal(risk.SignalImpossibleTravel, s.config.ImpossibleTravelScore)
+		}
+	}
+
+	if u.FailedLoginAttempts > 0 {
+		score := u.FailedLoginAttempts * s.config.FailedAttemptScore
+		assessment.AddSignal(risk.SignalFailedAttempts, min(score, s.config.MaxFailedAttemptScore))
+	}
+
+	switch {
+	case assessment.Score >= s.config.BlockThreshold:
+		assessment.Decision = risk.DecisionBlock
+	case assessment.Score >= s.config.StepUpThreshold:
+		assessment.Decision = risk.DecisionStepUp
+	default:
+		assessment.Decision = risk.DecisionAllow
+	}
+
+	s.logDecision(ctx, u, assessment)
+
+	return assessment, nil
+}
+
+// RecordLogin adds a completed sign-in to the user's history and tells the user
+// about sign-ins from a device or network they have not used before
+func (s *RiskService) RecordLogin(ctx context.Context, u *user.User, assessment *risk.Assessment) error {
+	event := &risk.LoginEvent{
+		ID:                uuid.New(),
+		UserID:            u.ID,
+		DeviceFingerprint: assessment.DeviceFingerprint,
+		IPAddress:         assessment.IPAddress,
+		UserAgent:         assessment.UserAgent,
+		CreatedAt:         time.Now(),
+	}
+	if assessment.Location != nil {
+		event.Location = *assessment.Location
+	}
+
+	if err := s.history.Create(ctx, event); err != nil {
+		return fmt.Errorf("failed to record sign-in: %w", err)
+	}
+
+	// The notification is sent without holding up the sign-in
+	if s.mailSender != nil && (assessment.HasSignal(risk.SignalNewDevice) || assessment.HasSignal(risk.SignalNewNetwork)) {
+		msg := newSignInMessage(u, event)
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundMailTimeout)
+
+		go func() {
+			defer cancel()
+			if err := s.mailSender.Send(ctx, msg); err != nil {
+				s.logger.Error("Failed to send new sign-in email", zap.Error(err))
+			}
+		}()
+	}
+
+	return nil
+}
+
+func (s *RiskService) locate(ip string) *risk.Location {
+	if s.locator == nil || ip == "" {
+		return nil
+	}
+
+	// A sign-in from an address the data does not cover is scored without it
+	location, err := s.locator.Locate(ip)
+	if err != nil {
+		return nil
+	}
+	return location
+}
+
+// impossibleTravel reports whether getting from the previous sign-in's location to
+// this one would have needed a speed above MaxTravelSpeed
+func (s *RiskService) impossibleTravel(previous *risk.LoginEvent, location *risk.Location) bool {
+	if !location.HasCoordinates() || !previous.Location.HasCoordinates() {
+		return false
+	}
+
+	distance := greatCircleDistance(
+		*previous.Location.Latitude, *previous.Location.Longitude,
+		*location.Latitude, *location.Longitude,
+	)
+	if distance < s.config.MinTravelDistance {
+		return false
+	}
+
+	hours := time.Since(previous.CreatedAt).Hours()
+	return hours <= 0 || distance/hours > s.config.MaxTravelSpeed
+}
+
+func (s *RiskService) logDecision(ctx context.Context, u *user.User, assessment *risk.Assessment) {
+	if s.auditService == nil {
+		return
+	}
+
+	severity := audit.SeverityInfo
+	switch assessment.Decision {
+	case risk.DecisionStepUp:
+		severity = audit.SeverityWarning
+	case risk.DecisionBlock:
+		severity = audit.SeverityCritical
+	}
+
+	metadata := map[string]interface{}{
+		"score":              assessment.Score,
+		"decision":           assessment.Decision,
+		"signals":            assessment.Signals,
+		"device_fingerprint": assessment.DeviceFingerprint,
+	}
+	if assessment.Location != nil {
+		metadata["country"] = assessment.Location.Country
+		metadata["asn"] = assessment.Location.ASN
+	}
+
+	_, _ = s.auditService.Log(ctx, &audit.CreateLogRequest{
+		EventType:   audit.EventTypeLoginRiskAssessed,
+		Severity:    severity,
+		UserID:      &u.ID,
+		EntityType:  "user",
+		EntityID:    u.ID.String(),
+		Action:      string(assessment.Decision),
+		Description: fmt.Sprintf("Sign-in risk score %d", assessment.Score),
+		IPAddress:   assessment.IPAddress,
+		UserAgent:   assessment.UserAgent,
+		Metadata:    metadata,
+	})
+}
+
+// DeviceFingerprint identifies the kind of device a user agent describes.
+// Version numbers are dropped so that browser and OS updates do not make a
+// device look new.
+func DeviceFingerprint(userAgent string) string {
+	normalized := userAgentVersions.ReplaceAllString(strings.ToLower(userAgent), "")
+	normalized = strings.Join(strings.Fields(normalized), " ")
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:16])
+}
+
+func knownDevice(events []*risk.LoginEvent, fingerprint string) bool {
+	for _, event := range events {
+		if event.DeviceFingerprint == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// knownNetwork reports whether the address, or the autonomous system it belongs to,
+// appears in the history
+func knownNetwork(events []*risk.LoginEvent, ip string, location *risk.Location) bool {
+	for _, event := range events {
+		if event.IPAddress == ip {
+			return true
+		}
+		if location != nil && location.ASN != 0 && event.Location.ASN == location.ASN {
+			return true
+		}
+	}
+	return false
+}
+
+// greatCircleDistance returns the distance in km between two points given in degrees
+func greatCircleDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+func newSignInMessage(u *user.User, event *risk.LoginEvent) *mail.Message {
+	where := event.IPAddress
+	if place := describeLocation(&event.Location); place != "" {
+		where = fmt.Sprintf("%s (%s)", event.IPAddress, place)
+	}
+
+	device := event.UserAgent
+	if device == "" {
+		device = "Unknown device"
+	}
+
+	return &mail.Message{
+		To:      []string{u.Email},
+		Subject: "New sign-in to your account",
+		TextBody: fmt.Sprintf(
+			"Hi %s,\n\nYour account was just signed in to from a device or network you haven't used recently.\n\n"+
+				"When: %s\nWhere: %s\nDevice: %s\n\n"+
+				"If this was you, there's nothing to do. If not, change your password right away.\n",
+			u.Username, event.CreatedAt.UTC().Format(time.RFC1123), where, device,
+		),
+	}
+}
+
+func describeLocation(location *risk.Location) string {
+	var parts []string
+	if location.City != "" {
+		parts = append(parts, location.City)
+	}
+	if location.Country != "" {
+		parts = append(parts, location.Country)
+	}
+	return strings.Join(parts, ", ")
+}
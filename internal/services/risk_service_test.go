@@ -0,0 +1,378 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mail"
+	"github.com/victoralfred/um_sys/internal/domain/risk"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+const (
+	laptopChrome  = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	laptopUpdated = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.6167.85 Safari/537.36"
+	phoneSafari   = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1"
+
+	londonHome   = "81.2.69.160"
+	londonMobile = "81.2.69.200"
+	manchester   = "2.24.0.1"
+	sydney       = "1.128.0.1"
+)
+
+func coordinates(lat, lon float64) (*float64, *float64) {
+	return &lat, &lon
+}
+
+func riskLocations() StaticLocator {
+	londonLat, londonLon := coordinates(51.5142, -0.0931)
+	manchesterLat, manchesterLon := coordinates(53.4809, -2.2374)
+	sydneyLat, sydneyLon := coordinates(-33.8688, 151.2093)
+
+	return StaticLocator{
+		londonHome:   {Country: "GB", City: "London", Latitude: londonLat, Longitude: londonLon, ASN: 20712},
+		londonMobile: {Country: "GB", City: "London", Latitude: londonLat, Longitude: londonLon, ASN: 20712},
+		manchester:   {Country: "GB", City: "Manchester", Latitude: manchesterLat, Longitude: manchesterLon, ASN: 5089},
+		sydney:       {Country: "AU", City: "Sydney", Latitude: sydneyLat, Longitude: sydneyLon, ASN: 1221},
+	}
+}
+
+type riskFixture struct {
+	service    *services.RiskService
+	history    *InMemoryLoginHistoryRepository
+	mailSender *MockMailSender
+	audit      *MockAuditService
+	user       *user.User
+}
+
+func newRiskFixture(t *testing.T) *riskFixture {
+	t.Helper()
+
+	history := NewInMemoryLoginHistoryRepository()
+	mailSender := new(MockMailSender)
+	auditService := new(MockAuditService)
+	auditService.On("Log", mock.Anything, mock.Anything).Return(&audit.LogEntry{}, nil)
+
+	service := services.NewRiskService(history, services.DefaultRiskConfig())
+	service.SetLocator(riskLocations())
+	service.SetMailSender(mailSender)
+	service.SetAuditService(auditService)
+
+	return &riskFixture{
+		service:    service,
+		history:    history,
+		mailSender: mailSender,
+		audit:      auditService,
+		user: &user.User{
+			ID:       uuid.New(),
+			Email:    "test@example.com",
+			Username: "testuser",
+			Status:   user.StatusActive,
+		},
+	}
+}
+
+// signedIn adds a past sign-in to the user's history
+func (f *riskFixture) signedIn(t *testing.T, ip, userAgent string, ago time.Duration) {
+	t.Helper()
+
+	location, err := riskLocations().Locate(ip)
+	require.NoError(t, err)
+
+	require.NoError(t, f.history.Create(context.Background(), &risk.LoginEvent{
+		ID:                uuid.New(),
+		UserID:            f.user.ID,
+		DeviceFingerprint: services.DeviceFingerprint(userAgent),
+		IPAddress:         ip,
+		UserAgent:         userAgent,
+		Location:          *location,
+		CreatedAt:         time.Now().Add(-ago),
+	}))
+}
+
+func (f *riskFixture) assess(t *testing.T, ip, userAgent string) *risk.Assessment {
+	t.Helper()
+
+	assessment, err := f.service.Assess(context.Background(), f.user, ip, userAgent)
+	require.NoError(t, err)
+	return assessment
+}
+
+func TestRiskService_Assess(t *testing.T) {
+	t.Run("allows a first sign-in", func(t *testing.T) {
+		f := newRiskFixture(t)
+
+		assessment := f.assess(t, sydney, phoneSafari)
+		assert.Equal(t, risk.DecisionAllow, assessment.Decision)
+		assert.Zero(t, assessment.Score)
+		assert.Empty(t, assessment.Signals)
+		assert.Equal(t, "Sydney", assessment.Location.City)
+	})
+
+	t.Run("allows a known device on a known network", func(t *testing.T) {
+		f := newRiskFixture(t)
+		f.signedIn(t, londonHome, laptopChrome, 24*time.Hour)
+
+		// Browser updates and other addresses on the same network are still familiar
+		assessment := f.assess(t, londonMobile, laptopUpdated)
+		assert.Equal(t, risk.DecisionAllow, assessment.Decision)
+		assert.Empty(t, assessment.Signals)
+	})
+
+	t.Run("scores a new device", func(t *testing.T) {
+		f := newRiskFixture(t)
+		f.signedIn(t, londonHome, laptopChrome, 24*time.Hour)
+
+		assessment := f.assess(t, londonHome, phoneSafari)
+		assert.Equal(t, risk.DecisionAllow, assessment.Decision)
+		assert.Equal(t, []risk.Signal{risk.SignalNewDevice}, assessment.Signals)
+		assert.Equal(t, 30, assessment.Score)
+	})
+
+	t.Run("steps up a new device on a new network", func(t *testing.T) {
+		f := newRiskFixture(t)
+		f.signedIn(t, londonHome, laptopChrome, 24*time.Hour)
+
+		assessment := f.assess(t, manchester, phoneSafari)
+		assert.Equal(t, risk.DecisionStepUp, assessment.Decision)
+		assert.ElementsMatch(t, []risk.Signal{risk.SignalNewDevice, risk.SignalNewNetwork}, assessment.Signals)
+		assert.False(t, assessment.HasSignal(risk.SignalImpossibleTravel), "London to Manchester is a short trip")
+	})
+
+	t.Run("detects impossible travel", func(t *testing.T) {
+		f := newRiskFixture(t)
+		f.signedIn(t, londonHome, laptopChrome, 2*time.Hour)
+
+		assessment := f.assess(t, sydney, laptopChrome)
+		assert.Equal(t, risk.DecisionStepUp, assessment.Decision)
+		assert.ElementsMatch(t, []risk.Signal{risk.SignalNewNetwork, risk.SignalImpossibleTravel}, assessment.Signals)
+
+		assessment = f.assess(t, sydney, phoneSafari)
+		assert.Equal(t, risk.DecisionBlock, assessment.Decision)
+	})
+
+	t.Run("allows travel that had enough time", func(t *testing.T) {
+		f := newRiskFixture(t)
+		f.signedIn(t, londonHome, laptopChrome, 48*time.Hour)
+
+		assessment := f.assess(t, sydney, laptopChrome)
+		assert.False(t, assessment.HasSignal(risk.SignalImpossibleTravel))
+	})
+
+	t.Run("scores failed attempts up to a cap", func(t *testing.T) {
+		f := newRiskFixture(t)
+		f.signedIn(t, londonHome, laptopChrome, 24*time.Hour)
+
+		f.user.FailedLoginAttempts = 2
+		assessment := f.assess(t, londonHome, laptopChrome)
+		assert.Equal(t, []risk.Signal{risk.SignalFailedAttempts}, assessment.Signals)
+		assert.Equal(t, 20, assessment.Score)
+
+		f.user.FailedLoginAttempts = 10
+		assessment = f.assess(t, londonHome, phoneSafari)
+		assert.Equal(t, 60, assessment.Score)
+		assert.Equal(t, risk.DecisionStepUp, assessment.Decision)
+	})
+
+	t.Run("works without GeoIP data", func(t *testing.T) {
+		f := newRiskFixture(t)
+		f.signedIn(t, londonHome, laptopChrome, time.Hour)
+		f.service.SetLocator(nil)
+
+		assessment := f.assess(t, sydney, laptopChrome)
+		assert.Nil(t, assessment.Location)
+		assert.Equal(t, []risk.Signal{risk.SignalNewNetwork}, assessment.Signals)
+	})
+
+	t.Run("applies the configured thresholds", func(t *testing.T) {
+		f := newRiskFixture(t)
+		config := services.DefaultRiskConfig()
+		config.StepUpThreshold = 30
+		config.BlockThreshold = 50
+		f.service = services.NewRiskService(f.history, config)
+		f.service.SetLocator(riskLocations())
+		f.signedIn(t, londonHome, laptopChrome, 24*time.Hour)
+
+		assert.Equal(t, risk.DecisionStepUp, f.assess(t, londonHome, phoneSafari).Decision)
+		assert.Equal(t, risk.DecisionBlock, f.assess(t, manchester, phoneSafari).Decision)
+	})
+
+	t.Run("records every decision in the audit log", func(t *testing.T) {
+		f := newRiskFixture(t)
+		f.signedIn(t, londonHome, laptopChrome, 2*time.Hour)
+
+		f.assess(t, londonHome, laptopChrome)
+		f.assess(t, sydney, phoneSafari)
+
+		f.audit.AssertNumberOfCalls(t, "Log", 2)
+		blocked := f.audit.Calls[1].Arguments.Get(1).(*audit.CreateLogRequest)
+		assert.Equal(t, audit.EventTypeLoginRiskAssessed, blocked.EventType)
+		assert.Equal(t, audit.SeverityCritical, blocked.Severity)
+		assert.Equal(t, string(risk.DecisionBlock), blocked.Action)
+		assert.Equal(t, sydney, blocked.IPAddress)
+		assert.Equal(t, "AU", blocked.Metadata["country"])
+	})
+}
+
+func TestRiskService_RecordLogin(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("notifies the user of a new device", func(t *testing.T) {
+		f := newRiskFixture(t)
+		f.signedIn(t, londonHome, laptopChrome, 24*time.Hour)
+
+		mails := make(chan *mail.Message, 1)
+		f.mailSender.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			mails <- args.Get(1).(*mail.Message)
+		}).Return(nil)
+
+		assessment := f.assess(t, manchester, phoneSafari)
+		require.NoError(t, f.service.RecordLogin(ctx, f.user, assessment))
+
+		sent := waitForMail(t, mails)
+		assert.Equal(t, []string{f.user.Email}, sent.To)
+		assert.Equal(t, "New sign-in to your account", sent.Subject)
+		assert.Contains(t, sent.TextBody, "Manchester, GB")
+		assert.Contains(t, sent.TextBody, "iPhone")
+
+		events, err := f.history.ListRecent(ctx, f.user.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, manchester, events[0].IPAddress)
+		assert.Equal(t, uint32(5089), events[0].Location.ASN)
+	})
+
+	t.Run("stays quiet for familiar and first sign-ins", func(t *testing.T) {
+		f := newRiskFixture(t)
+
+		require.NoError(t, f.service.RecordLogin(ctx, f.user, f.assess(t, londonHome, laptopChrome)))
+		require.NoError(t, f.service.RecordLogin(ctx, f.user, f.assess(t, londonMobile, laptopUpdated)))
+
+		f.mailSender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+}
+
+func TestDeviceFingerprint(t *testing.T) {
+	assert.Equal(t, services.DeviceFingerprint(laptopChrome), services.DeviceFingerprint(laptopUpdated))
+	assert.NotEqual(t, services.DeviceFingerprint(laptopChrome), services.DeviceFingerprint(phoneSafari))
+	assert.Len(t, services.DeviceFingerprint(""), 32)
+}
+
+func TestAuthService_LoginRisk(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T, mfaEnabled bool) (*authServiceFixture, *services.AuthService, *riskFixture) {
+		f := newAuthServiceFixture(t)
+		f.user.MFAEnabled = mfaEnabled
+
+		userRepo := NewInMemoryUserRepository()
+		require.NoError(t, userRepo.Create(ctx, f.user))
+		service := services.NewAuthService(userRepo, f.tokenService, f.hasher, nil, f.mailSender, services.DefaultAuthConfig())
+
+		r := newRiskFixture(t)
+		r.user = f.user
+		r.mailSender = f.mailSender
+		r.service.SetMailSender(f.mailSender)
+		service.SetRiskService(r.service)
+
+		return f, service, r
+	}
+
+	login := func(service *services.AuthService, f *authServiceFixture, ip, userAgent string) error {
+		_, _, err := service.Login(ctx, &auth.LoginRequest{
+			Email:     f.user.Email,
+			Password:  "OldPassword1",
+			IPAddress: ip,
+			UserAgent: userAgent,
+		})
+		return err
+	}
+
+	t.Run("records successful sign-ins", func(t *testing.T) {
+		f, service, r := setup(t, false)
+
+		require.NoError(t, login(service, f, londonHome, laptopChrome))
+		require.NoError(t, login(service, f, londonHome, laptopChrome))
+
+		events, err := r.history.ListRecent(ctx, f.user.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, events, 2)
+	})
+
+	t.Run("requires the enrolled second factor on sign-ins the risk engine allows", func(t *testing.T) {
+		f, service, r := setup(t, true)
+
+		// An account without history is allowed by the risk engine
+		assert.Equal(t, risk.DecisionAllow, r.assess(t, londonHome, laptopChrome).Decision)
+		assert.ErrorIs(t, login(service, f, londonHome, laptopChrome), auth.ErrMFARequired)
+
+		r.signedIn(t, londonHome, laptopChrome, 24*time.Hour)
+		assert.Equal(t, risk.DecisionAllow, r.assess(t, londonHome, laptopChrome).Decision)
+		assert.ErrorIs(t, login(service, f, londonHome, laptopChrome), auth.ErrMFARequired)
+	})
+
+	t.Run("requires a second factor for risky sign-ins", func(t *testing.T) {
+		f, service, r := setup(t, true)
+		r.signedIn(t, londonHome, laptopChrome, 24*time.Hour)
+
+		err := login(service, f, manchester, phoneSafari)
+		assert.ErrorIs(t, err, auth.ErrMFARequired)
+	})
+
+	t.Run("challenges risky sign-ins on trusted devices", func(t *testing.T) {
+		f, service, r := setup(t, true)
+		r.signedIn(t, londonHome, laptopChrome, 24*time.Hour)
+
+		trustedDevices := services.NewTrustedDeviceService(NewInMemoryTrustedDeviceRepository(), "device-secret", services.DefaultTrustedDeviceConfig())
+		service.SetTrustedDeviceService(trustedDevices)
+		deviceToken, _, err := trustedDevices.Trust(ctx, f.user.ID, londonHome, laptopChrome)
+		require.NoError(t, err)
+
+		loginFrom := func(ip string) error {
+			_, _, err := service.Login(ctx, &auth.LoginRequest{
+				Email:       f.user.Email,
+				Password:    "OldPassword1",
+				DeviceToken: deviceToken,
+				IPAddress:   ip,
+				UserAgent:   laptopChrome,
+			})
+			return err
+		}
+
+		// A familiar sign-in from the trusted device only needs the password
+		require.NoError(t, loginFrom(londonHome))
+
+		assert.ErrorIs(t, loginFrom(sydney), auth.ErrMFARequired)
+	})
+
+	t.Run("lets accounts without MFA through with a notification", func(t *testing.T) {
+		f, service, r := setup(t, false)
+		r.signedIn(t, londonHome, laptopChrome, 24*time.Hour)
+		sent := f.expectMail(nil)
+
+		require.NoError(t, login(service, f, manchester, phoneSafari))
+		waitForMail(t, sent)
+		f.mailSender.AssertNumberOfCalls(t, "Send", 1)
+	})
+
+	t.Run("blocks sign-ins above the block threshold", func(t *testing.T) {
+		f, service, r := setup(t, false)
+		r.signedIn(t, londonHome, laptopChrome, time.Hour)
+
+		err := login(service, f, sydney, phoneSafari)
+		assert.ErrorIs(t, err, auth.ErrLoginBlocked)
+
+		events, err := r.history.ListRecent(ctx, f.user.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, events, 1, "blocked sign-ins are not added to the history")
+	})
+}
@@ -0,0 +1,408 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/federation"
+	"github.com/victoralfred/um_sys/internal/domain/rbac"
+	"github.com/victoralfred/um_sys/internal/domain/saml"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+)
+
+// SAMLConfig holds SAML service provider settings
+type SAMLConfig struct {
+	// BaseURL is the public URL of the API, from which each organization's
+	// entity ID and assertion consumer service URL are derived
+	BaseURL string
+
+	// RequestExpiry is how long a user has to complete an SP-initiated sign-in
+	RequestExpiry time.Duration
+}
+
+// DefaultSAMLConfig returns the default SAML settings
+func DefaultSAMLConfig() SAMLConfig {
+	return SAMLConfig{
+		RequestExpiry: 10 * time.Minute,
+	}
+}
+
+// SAMLService signs users in through their organization's SAML 2.0 identity provider.
+// Accounts are linked and provisioned just in time like federated OpenID Connect sign-ins.
+type SAMLService struct {
+	connectionRepo saml.ConnectionRepository
+	assertionCache saml.AssertionCache
+	protocol       saml.Protocol
+	federation     *FederationService
+	userRepo       user.Repository
+	roleRepo       rbac.RoleRepository
+	secret         []byte
+	config         SAMLConfig
+}
+
+// NewSAMLService creates a new SAML service.
+// The secret signs the request state carried through the identity provider round trip.
+func NewSAMLService(
+	connectionRepo saml.ConnectionRepository,
+	assertionCache saml.AssertionCache,
+	protocol saml.Protocol,
+	federationService *FederationService,
+	userRepo user.Repository,
+	secret string,
+	config SAMLConfig,
+) *SAMLService {
+	return &SAMLService{
+		connectionRepo: connectionRepo,
+		assertionCache: assertionCache,
+		protocol:       protocol,
+		federation:     federationService,
+		userRepo:       userRepo,
+		secret:         []byte(secret),
+		config:         config,
+	}
+}
+
+// SetRoleRepository enables granting roles from connections' role mappings
+func (s *SAMLService) SetRoleRepository(roleRepo rbac.RoleRepository) {
+	s.roleRepo = roleRepo
+}
+
+// ServiceProvider returns how this application identifies itself to an organization's identity provider
+func (s *SAMLService) ServiceProvider(organizationID uuid.UUID) saml.ServiceProvider {
+	base := strings.TrimSuffix(s.config.BaseURL, "/") + "/v1/auth/saml/" + organizationID.String()
+	return saml.ServiceProvider{
+		EntityID: base + "/metadata",
+		ACSURL:   base + "/acs",
+	}
+}
+
+// Metadata returns the service provider metadata an organization imports into its identity provider
+func (s *SAMLService) Metadata(organizationID uuid.UUID) ([]byte, error) {
+	return s.protocol.Metadata(s.ServiceProvider(organizationID))
+}
+
+// ConfigureConnection creates or replaces an organization's connection from uploaded IdP metadata
+func (s *SAMLService) ConfigureConnection(ctx context.Context, organizationID uuid.UUID, req *saml.ConnectionRequest) (*saml.Connection, error) {
+	metadata, err := s.protocol.ParseMetadata([]byte(req.Metadata))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkRoles(ctx, req); err != nil {
+		return nil, err
+	}
+
+	mapping := saml.DefaultAttributeMapping()
+	if req.AttributeMapping != nil {
+		mapping = *req.AttributeMapping
+	}
+
+	existing, err := s.connectionRepo.GetByOrganization(ctx, organizationID)
+	if err != nil && !errors.Is(err, saml.ErrConnectionNotFound) {
+		return nil, fmt.Errorf("failed to get SAML connection: %w", err)
+	}
+
+	now := time.Now()
+	connection := &saml.Connection{
+		ID:                uuid.New(),
+		OrganizationID:    organizationID,
+		IdPEntityID:       metadata.EntityID,
+		SSOURL:            metadata.SSOURL,
+		Certificates:      metadata.Certificates,
+		Metadata:          req.Metadata,
+		AttributeMapping:  mapping,
+		RoleMapping:       req.RoleMapping,
+		DefaultRoles:      req.DefaultRoles,
+		AllowSignup:       req.AllowSignup,
+		AllowIdPInitiated: req.AllowIdPInitiated,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	if existing != nil {
+		connection.ID = existing.ID
+		connection.CreatedAt = existing.CreatedAt
+		if err := s.connectionRepo.Update(ctx, connection); err != nil {
+			return nil, fmt.Errorf("failed to update SAML connection: %w", err)
+		}
+		return connection, nil
+	}
+
+	if err := s.connectionRepo.Create(ctx, connection); err != nil {
+		return nil, fmt.Errorf("failed to create SAML connection: %w", err)
+	}
+	return connection, nil
+}
+
+// checkRoles makes sure every role a connection grants exists
+func (s *SAMLService) checkRoles(ctx context.Context, req *saml.ConnectionRequest) error {
+	if s.roleRepo == nil {
+		return nil
+	}
+
+	names := append([]string(nil), req.DefaultRoles...)
+	for _, roles := range req.RoleMapping {
+		names = append(names, roles...)
+	}
+	for _, name := range names {
+		if _, err := s.roleRepo.GetByName(ctx, name); err != nil {
+			if errors.Is(err, rbac.ErrRoleNotFound) {
+				return fmt.Errorf("%w: %s", rbac.ErrRoleNotFound, name)
+			}
+			return fmt.Errorf("failed to get role: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetConnection returns an organization's connection
+func (s *SAMLService) GetConnection(ctx context.Context, organizationID uuid.UUID) (*saml.Connection, error) {
+	return s.connectionRepo.GetByOrganization(ctx, organizationID)
+}
+
+// DeleteConnection removes an organization's connection. Linked identities are kept so
+// that a replacement connection signs users in to the same accounts.
+func (s *SAMLService) DeleteConnection(ctx context.Context, organizationID uuid.UUID) error {
+	return s.connectionRepo.Delete(ctx, organizationID)
+}
+
+// BeginLogin starts an SP-initiated sign-in. It returns the identity provider URL to
+// redirect the browser to and a signed state token that the browser must keep (in a
+// cookie) and present again with the response.
+func (s *SAMLService) BeginLogin(ctx context.Context, organizationID uuid.UUID) (string, string, error) {
+	connection, err := s.connectionRepo.GetByOrganization(ctx, organizationID)
+	if err != nil {
+		return "", "", err
+	}
+
+	relayState, err := generateOAuthSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	request, err := s.protocol.NewAuthnRequest(s.ServiceProvider(organizationID), connection, relayState)
+	if err != nil {
+		return "", "", err
+	}
+
+	stateToken, err := s.signState(&saml.RequestState{
+		OrganizationID: organizationID,
+		RequestID:      request.ID,
+		RelayState:     relayState,
+		ExpiresAt:      time.Now().Add(s.config.RequestExpiry),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return request.URL, stateToken, nil
+}
+
+// CompleteLogin verifies a response posted to the assertion consumer service and signs
+// the user in. A response answers the browser's own request when the state token and
+// relay state match; anything else is IdP-initiated and accepted only if the connection allows it.
+func (s *SAMLService) CompleteLogin(ctx context.Context, organizationID uuid.UUID, encodedResponse, relayState, stateToken string) (*federation.Result, error) {
+	connection, err := s.connectionRepo.GetByOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	inResponseTo := ""
+	if state, ok := s.parseState(stateToken); ok && state.OrganizationID == organizationID &&
+		subtle.ConstantTimeCompare([]byte(state.RelayState), []byte(relayState)) == 1 {
+		inResponseTo = state.RequestID
+	}
+	if inResponseTo == "" && !connection.AllowIdPInitiated {
+		return nil, saml.ErrUnsolicitedResponse
+	}
+
+	assertion, err := s.protocol.VerifyResponse(encodedResponse, &saml.Expectations{
+		ServiceProvider: s.ServiceProvider(organizationID),
+		IdPEntityID:     connection.IdPEntityID,
+		Certificates:    connection.Certificates,
+		InResponseTo:    inResponseTo,
+		Now:             time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Bearer assertions may only be used once (SAML profiles section 4.1.4.5)
+	if err := s.assertionCache.Use(ctx, assertion.Issuer, assertion.ID, assertion.NotOnOrAfter); err != nil {
+		return nil, err
+	}
+
+	mapping := connection.AttributeMapping
+	claims := &federation.ExternalClaims{
+		Subject:           assertion.NameID,
+		Email:             assertion.Attribute(mapping.Email),
+		EmailVerified:     true, // The organization's identity provider vouches for its users
+		GivenName:         assertion.Attribute(mapping.FirstName),
+		FamilyName:        assertion.Attribute(mapping.LastName),
+		PreferredUsername: assertion.Attribute(mapping.Username),
+	}
+	if claims.Email == "" && strings.Contains(assertion.NameID, "@") {
+		claims.Email = assertion.NameID
+	}
+
+	// An identity provider can assert any address, so existing accounts are never
+	// linked by email; only accounts it provisioned itself are signed in
+	provider := &federation.Provider{
+		ID:          samlProviderID(organizationID),
+		AllowSignup: connection.AllowSignup,
+	}
+	result, err := s.federation.signInExternal(ctx, provider, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.updateProfile(ctx, result.User, claims, assertion.Attribute(mapping.PhoneNumber)); err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	if mapping.Groups != "" {
+		groups = assertion.Attributes[mapping.Groups]
+	}
+	if err := s.grantRoles(ctx, result.User.ID, connection, groups, result.Created); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// updateProfile keeps the profile fields the identity provider releases up to date
+func (s *SAMLService) updateProfile(ctx context.Context, u *user.User, claims *federation.ExternalClaims, phoneNumber string) error {
+	changed := false
+	for _, field := range []struct {
+		current *string
+		value   string
+	}{
+		{&u.FirstName, claims.GivenName},
+		{&u.LastName, claims.FamilyName},
+		{&u.PhoneNumber, phoneNumber},
+	} {
+		if field.value != "" && *field.current != field.value {
+			*field.current = field.value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	u.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(ctx, u); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+// grantRoles grants the roles mapped from the user's groups, and the connection's
+// default roles to newly provisioned users. Roles are only ever added; revoking them
+// stays with the organization's admins.
+func (s *SAMLService) grantRoles(ctx context.Context, userID uuid.UUID, connection *saml.Connection, groups []string, created bool) error {
+	if s.roleRepo == nil {
+		return nil
+	}
+
+	var names []string
+	if created {
+		names = append(names, connection.DefaultRoles...)
+	}
+	for _, group := range groups {
+		names = append(names, connection.RoleMapping[group]...)
+	}
+
+	for _, name := range names {
+		has, err := s.roleRepo.HasRole(ctx, userID, name)
+		if err != nil {
+			return fmt.Errorf("failed to check role: %w", err)
+		}
+		if has {
+			continue
+		}
+
+		role, err := s.roleRepo.GetByName(ctx, name)
+		if err != nil {
+			// Roles deleted since the connection was configured are skipped
+			if errors.Is(err, rbac.ErrRoleNotFound) {
+				continue
+			}
+			return fmt.Errorf("failed to get role: %w", err)
+		}
+
+		if err := s.roleRepo.AssignRole(ctx, &rbac.UserRole{
+			UserID:    userID,
+			RoleID:    role.ID,
+			GrantedAt: time.Now(),
+		}); err != nil {
+			return fmt.Errorf("failed to assign role: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// samlProviderID is the provider ID of identities linked through an organization's connection
+func samlProviderID(organizationID uuid.UUID) string {
+	return "saml:" + organizationID.String()
+}
+
+// signState encodes the request state as base64(json).base64(hmac)
+func (s *SAMLService) signState(state *saml.RequestState) (string, error) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.signStatePayload(payload)), nil
+}
+
+// parseState verifies and decodes a state token produced by signState
+func (s *SAMLService) parseState(token string) (*saml.RequestState, bool) {
+	payloadPart, signaturePart, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(payloadPart)
+	if err != nil {
+		return nil, false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(signaturePart)
+	if err != nil {
+		return nil, false
+	}
+	if !hmac.Equal(signature, s.signStatePayload(payload)) {
+		return nil, false
+	}
+
+	var state saml.RequestState
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return nil, false
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return nil, false
+	}
+
+	return &state, true
+}
+
+func (s *SAMLService) signStatePayload(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("saml-state\x00"))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
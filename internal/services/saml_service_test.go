@@ -0,0 +1,257 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/federation"
+	"github.com/victoralfred/um_sys/internal/domain/rbac"
+	"github.com/victoralfred/um_sys/internal/domain/saml"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	samlImpl "github.com/victoralfred/um_sys/internal/infrastructure/saml"
+	"github.com/victoralfred/um_sys/internal/infrastructure/saml/samltest"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+type samlFixture struct {
+	service *services.SAMLService
+	users   *InMemoryUserRepository
+	roles   *MockRoleRepository
+	idp     *samltest.IdentityProvider
+	orgID   uuid.UUID
+}
+
+// newSAMLFixture configures an organization whose identity provider maps the
+// Engineering group to the developer role and provisions members
+func newSAMLFixture(t *testing.T) *samlFixture {
+	t.Helper()
+
+	idp, err := samltest.NewIdentityProvider("https://idp.acme.com")
+	require.NoError(t, err)
+
+	users := NewInMemoryUserRepository()
+	tokenService := services.NewTokenService("test-secret-key-min-32-characters!!", "test-issuer", 15*time.Minute, 7*24*time.Hour, users)
+	tokenService.SetTokenStore(NewInMemoryTokenStore())
+	federationService := services.NewFederationService(users, NewInMemoryIdentityRepository(), tokenService, "state-secret", services.DefaultFederationConfig())
+
+	config := services.DefaultSAMLConfig()
+	config.BaseURL = "https://app.example.com"
+	service := services.NewSAMLService(
+		NewInMemorySAMLConnectionRepository(),
+		NewInMemoryAssertionCache(),
+		samlImpl.NewProtocol(0),
+		federationService,
+		users,
+		"state-secret",
+		config,
+	)
+
+	roles := new(MockRoleRepository)
+	for _, name := range []string{"member", "developer"} {
+		roles.On("GetByName", mock.Anything, name).Return(&rbac.Role{ID: uuid.New(), Name: name}, nil)
+	}
+	roles.On("GetByName", mock.Anything, mock.Anything).Return(nil, rbac.ErrRoleNotFound)
+	roles.On("HasRole", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	roles.On("AssignRole", mock.Anything, mock.Anything).Return(nil)
+	service.SetRoleRepository(roles)
+
+	f := &samlFixture{service: service, users: users, roles: roles, idp: idp, orgID: uuid.New()}
+	f.configure(t, false)
+	return f
+}
+
+func (f *samlFixture) configure(t *testing.T, allowIdPInitiated bool) *saml.Connection {
+	t.Helper()
+
+	mapping := saml.DefaultAttributeMapping()
+	mapping.PhoneNumber = "phone"
+	connection, err := f.service.ConfigureConnection(context.Background(), f.orgID, &saml.ConnectionRequest{
+		Metadata:          string(f.idp.Metadata()),
+		AttributeMapping:  &mapping,
+		RoleMapping:       map[string][]string{"Engineering": {"developer"}},
+		DefaultRoles:      []string{"member"},
+		AllowSignup:       true,
+		AllowIdPInitiated: allowIdPInitiated,
+	})
+	require.NoError(t, err)
+	return connection
+}
+
+func (f *samlFixture) response(t *testing.T, inResponseTo, email string, attributes map[string][]string) string {
+	t.Helper()
+
+	encoded, err := f.idp.Response(samltest.ResponseOptions{
+		ServiceProvider: f.service.ServiceProvider(f.orgID),
+		InResponseTo:    inResponseTo,
+		NameID:          email,
+		Attributes:      attributes,
+	})
+	require.NoError(t, err)
+	return encoded
+}
+
+// login runs an SP-initiated sign-in through the test IdP as the browser would
+func (f *samlFixture) login(t *testing.T, email string, attributes map[string][]string) (*federation.Result, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	redirectURL, stateToken, err := f.service.BeginLogin(ctx, f.orgID)
+	require.NoError(t, err)
+
+	request, err := f.idp.ReadAuthnRequest(redirectURL)
+	require.NoError(t, err)
+	assert.Equal(t, f.service.ServiceProvider(f.orgID).ACSURL, request.ACSURL)
+
+	return f.service.CompleteLogin(ctx, f.orgID, f.response(t, request.ID, email, attributes), request.RelayState, stateToken)
+}
+
+func TestSAMLService_Login(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("provisions users just in time and maps their attributes and groups", func(t *testing.T) {
+		f := newSAMLFixture(t)
+
+		result, err := f.login(t, "jane@acme.com", map[string][]string{
+			"firstName": {"Jane"},
+			"lastName":  {"Doe"},
+			"phone":     {"+15550100"},
+			"groups":    {"Engineering", "Everyone"},
+		})
+		require.NoError(t, err)
+		assert.True(t, result.Created)
+		assert.NotNil(t, result.TokenPair)
+		assert.Equal(t, "saml:"+f.orgID.String(), result.Identity.ProviderID)
+
+		u, err := f.users.GetByID(ctx, result.User.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "jane@acme.com", u.Email)
+		assert.Equal(t, "Jane", u.FirstName)
+		assert.Equal(t, "Doe", u.LastName)
+		assert.Equal(t, "+15550100", u.PhoneNumber)
+		assert.True(t, u.EmailVerified)
+		assert.Equal(t, user.StatusActive, u.Status)
+
+		var granted []uuid.UUID
+		for _, call := range f.roles.Calls {
+			if call.Method == "AssignRole" {
+				userRole := call.Arguments.Get(1).(*rbac.UserRole)
+				assert.Equal(t, u.ID, userRole.UserID)
+				granted = append(granted, userRole.RoleID)
+			}
+		}
+		assert.Len(t, granted, 2, "the default role and the role mapped from Engineering")
+	})
+
+	t.Run("signs returning users in to the same account and refreshes their profile", func(t *testing.T) {
+		f := newSAMLFixture(t)
+
+		first, err := f.login(t, "jane@acme.com", map[string][]string{"lastName": {"Doe"}})
+		require.NoError(t, err)
+
+		second, err := f.login(t, "jane@acme.com", map[string][]string{"lastName": {"Smith"}})
+		require.NoError(t, err)
+		assert.False(t, second.Created)
+		assert.Equal(t, first.User.ID, second.User.ID)
+
+		u, err := f.users.GetByID(ctx, first.User.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Smith", u.LastName)
+	})
+
+	t.Run("accepts IdP-initiated sign-in only when enabled", func(t *testing.T) {
+		f := newSAMLFixture(t)
+		unsolicited := f.response(t, "", "jane@acme.com", nil)
+
+		_, err := f.service.CompleteLogin(ctx, f.orgID, unsolicited, "", "")
+		assert.ErrorIs(t, err, saml.ErrUnsolicitedResponse)
+
+		f.configure(t, true)
+		result, err := f.service.CompleteLogin(ctx, f.orgID, unsolicited, "", "")
+		require.NoError(t, err)
+		assert.True(t, result.Created)
+	})
+
+	t.Run("rejects replayed assertions", func(t *testing.T) {
+		f := newSAMLFixture(t)
+		f.configure(t, true)
+		encoded := f.response(t, "", "jane@acme.com", nil)
+
+		_, err := f.service.CompleteLogin(ctx, f.orgID, encoded, "", "")
+		require.NoError(t, err)
+
+		_, err = f.service.CompleteLogin(ctx, f.orgID, encoded, "", "")
+		assert.ErrorIs(t, err, saml.ErrAssertionReplayed)
+	})
+
+	t.Run("does not take over existing accounts with the same email", func(t *testing.T) {
+		f := newSAMLFixture(t)
+		existing, err := user.NewUser("jane@acme.com", "jane", "hash")
+		require.NoError(t, err)
+		existing.EmailVerified = true
+		require.NoError(t, f.users.Create(ctx, existing))
+
+		_, err = f.login(t, "jane@acme.com", nil)
+		assert.ErrorIs(t, err, federation.ErrAccountExists)
+	})
+
+	t.Run("rejects responses for another organization", func(t *testing.T) {
+		f := newSAMLFixture(t)
+		f.configure(t, true)
+		other := f.orgID
+		f.orgID = uuid.New()
+		f.configure(t, true)
+
+		// Signed by the same IdP, but addressed to the first organization's service provider
+		encoded, err := f.idp.Response(samltest.ResponseOptions{
+			ServiceProvider: f.service.ServiceProvider(other),
+			NameID:          "jane@acme.com",
+		})
+		require.NoError(t, err)
+
+		_, err = f.service.CompleteLogin(ctx, f.orgID, encoded, "", "")
+		assert.ErrorIs(t, err, saml.ErrInvalidResponse)
+	})
+}
+
+func TestSAMLService_ConfigureConnection(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("replaces the connection in place", func(t *testing.T) {
+		f := newSAMLFixture(t)
+		first, err := f.service.GetConnection(ctx, f.orgID)
+		require.NoError(t, err)
+		assert.Equal(t, f.idp.EntityID, first.IdPEntityID)
+		assert.Equal(t, f.idp.SSOURL, first.SSOURL)
+
+		second := f.configure(t, true)
+		assert.Equal(t, first.ID, second.ID)
+		assert.True(t, second.AllowIdPInitiated)
+	})
+
+	t.Run("rejects invalid metadata and unknown roles", func(t *testing.T) {
+		f := newSAMLFixture(t)
+
+		_, err := f.service.ConfigureConnection(ctx, f.orgID, &saml.ConnectionRequest{Metadata: "<nope/>"})
+		assert.ErrorIs(t, err, saml.ErrInvalidMetadata)
+
+		_, err = f.service.ConfigureConnection(ctx, f.orgID, &saml.ConnectionRequest{
+			Metadata:     string(f.idp.Metadata()),
+			DefaultRoles: []string{"superuser"},
+		})
+		assert.ErrorIs(t, err, rbac.ErrRoleNotFound)
+	})
+
+	t.Run("deleting the connection stops sign-in", func(t *testing.T) {
+		f := newSAMLFixture(t)
+		require.NoError(t, f.service.DeleteConnection(ctx, f.orgID))
+
+		_, _, err := f.service.BeginLogin(ctx, f.orgID)
+		assert.ErrorIs(t, err, saml.ErrConnectionNotFound)
+	})
+}
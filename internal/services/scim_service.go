@@ -0,0 +1,970 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/rbac"
+	"github.com/victoralfred/um_sys/internal/domain/scim"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+)
+
+const (
+	// SCIMTokenPrefix starts every provisioning token so leaked tokens are easy to recognise
+	SCIMTokenPrefix = "scim_"
+
+	// scimTokenDisplayLength is how much of a token is stored in clear for display
+	scimTokenDisplayLength = len(SCIMTokenPrefix) + 8
+)
+
+// SCIMConfig holds SCIM provisioning settings
+type SCIMConfig struct {
+	// BaseURL is the public URL of the API, from which resource locations are derived
+	BaseURL string
+
+	// MaxResults caps the page size of list requests
+	MaxResults int
+}
+
+// DefaultSCIMConfig returns the default SCIM settings
+func DefaultSCIMConfig() SCIMConfig {
+	return SCIMConfig{
+		MaxResults: 100,
+	}
+}
+
+// SCIMService lets an organization's identity provider create, update and
+// deprovision users and groups through SCIM 2.0. Groups are backed by RBAC roles
+// granted to their members.
+type SCIMService struct {
+	tokenRepo      scim.TokenRepository
+	accountRepo    scim.AccountRepository
+	groupRepo      scim.GroupRepository
+	userRepo       user.Repository
+	tokenService   *TokenService
+	sessionService *SessionService
+	roleRepo       rbac.RoleRepository
+	config         SCIMConfig
+}
+
+// NewSCIMService creates a new SCIM service
+func NewSCIMService(
+	tokenRepo scim.TokenRepository,
+	accountRepo scim.AccountRepository,
+	groupRepo scim.GroupRepository,
+	userRepo user.Repository,
+	tokenService *TokenService,
+	config SCIMConfig,
+) *SCIMService {
+	if config.MaxResults <= 0 {
+		config.MaxResults = DefaultSCIMConfig().MaxResults
+	}
+	return &SCIMService{
+		tokenRepo:    tokenRepo,
+		accountRepo:  accountRepo,
+		groupRepo:    groupRepo,
+		userRepo:     userRepo,
+		tokenService: tokenService,
+		config:       config,
+	}
+}
+
+// SetRoleRepository enables backing groups with RBAC roles
+func (s *SCIMService) SetRoleRepository(roleRepo rbac.RoleRepository) {
+	s.roleRepo = roleRepo
+}
+
+// SetSessionService ends the sessions of deprovisioned users as well as revoking their tokens
+func (s *SCIMService) SetSessionService(sessionService *SessionService) {
+	s.sessionService = sessionService
+}
+
+// CreateToken issues a provisioning token for an organization. The secret is
+// returned once and cannot be retrieved again.
+func (s *SCIMService) CreateToken(ctx context.Context, organizationID, createdBy uuid.UUID, req *scim.TokenRequest) (*scim.Token, string, error) {
+	secret, err := generateOAuthSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	secret = SCIMTokenPrefix + secret
+
+	token := &scim.Token{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+		Name:           req.Name,
+		Prefix:         secret[:scimTokenDisplayLength],
+		TokenHash:      hashOAuthSecret(secret),
+		CreatedBy:      createdBy,
+		CreatedAt:      time.Now(),
+	}
+
+	if err := s.tokenRepo.Create(ctx, token); err != nil {
+		return nil, "", fmt.Errorf("failed to create SCIM token: %w", err)
+	}
+
+	return token, secret, nil
+}
+
+// ListTokens returns an organization's provisioning tokens
+func (s *SCIMService) ListTokens(ctx context.Context, organizationID uuid.UUID) ([]*scim.Token, error) {
+	return s.tokenRepo.ListByOrganization(ctx, organizationID)
+}
+
+// RevokeToken revokes one of an organization's provisioning tokens
+func (s *SCIMService) RevokeToken(ctx context.Context, organizationID, tokenID uuid.UUID) error {
+	return s.tokenRepo.Revoke(ctx, organizationID, tokenID, time.Now())
+}
+
+// AuthenticateToken resolves a presented bearer token to the provisioning token,
+// and so to the organization whose users and groups the request may manage
+func (s *SCIMService) AuthenticateToken(ctx context.Context, secret string) (*scim.Token, error) {
+	if !strings.HasPrefix(secret, SCIMTokenPrefix) {
+		return nil, scim.ErrInvalidToken
+	}
+
+	token, err := s.tokenRepo.GetByHash(ctx, hashOAuthSecret(secret))
+	if err != nil {
+		if errors.Is(err, scim.ErrTokenNotFound) {
+			return nil, scim.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get SCIM token: %w", err)
+	}
+	if token.RevokedAt != nil {
+		return nil, scim.ErrInvalidToken
+	}
+
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedUpdateInterval {
+		_ = s.tokenRepo.UpdateLastUsed(ctx, token.ID, now)
+		token.LastUsedAt = &now
+	}
+
+	return token, nil
+}
+
+// GetUser returns one of an organization's provisioned users
+func (s *SCIMService) GetUser(ctx context.Context, organizationID, userID uuid.UUID) (*scim.UserResource, error) {
+	account, u, err := s.getAccount(ctx, organizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.groupRepo.ListByMember(ctx, organizationID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+
+	return s.userResource(account, u, groups)
+}
+
+// ListUsers returns a page of an organization's provisioned users matching the query filter
+func (s *SCIMService) ListUsers(ctx context.Context, organizationID uuid.UUID, query *scim.ListQuery) (*scim.ListResponse, error) {
+	filter, err := parseListFilter(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := s.accountRepo.ListByOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM accounts: %w", err)
+	}
+	groups, err := s.groupRepo.ListByOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+
+	var matches []interface{}
+	for _, account := range accounts {
+		if account.IsDeprovisioned() {
+			continue
+		}
+		u, err := s.userRepo.GetByID(ctx, account.UserID)
+		if err != nil {
+			if errors.Is(err, user.ErrUserNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+
+		var memberOf []*scim.Group
+		for _, group := range groups {
+			if group.HasMember(account.UserID) {
+				memberOf = append(memberOf, group)
+			}
+		}
+
+		resource, err := s.userResource(account, u, memberOf)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := matchesFilter(filter, resource)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, resource)
+		}
+	}
+
+	return s.page(matches, query), nil
+}
+
+// CreateUser provisions a user for an organization. A user the organization
+// deprovisioned earlier is reactivated instead. Users that exist but were not
+// provisioned by the organization are reported as a uniqueness conflict rather
+// than taken over.
+func (s *SCIMService) CreateUser(ctx context.Context, organizationID uuid.UUID, resource *scim.UserResource) (*scim.UserResource, error) {
+	email, err := userEmail(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.accountRepo.GetByUserName(ctx, organizationID, resource.UserName)
+	if err != nil && !errors.Is(err, scim.ErrResourceNotFound) {
+		return nil, fmt.Errorf("failed to get SCIM account: %w", err)
+	}
+	if account != nil && !account.IsDeprovisioned() {
+		return nil, fmt.Errorf("%w: userName %s is already provisioned", scim.ErrUniqueness, resource.UserName)
+	}
+
+	existing, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, user.ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if existing != nil && (account == nil || account.UserID != existing.ID) {
+		previous, err := s.accountRepo.Get(ctx, organizationID, existing.ID)
+		if err != nil {
+			if errors.Is(err, scim.ErrResourceNotFound) {
+				return nil, fmt.Errorf("%w: a user with email %s already exists", scim.ErrUniqueness, email)
+			}
+			return nil, fmt.Errorf("failed to get SCIM account: %w", err)
+		}
+		if !previous.IsDeprovisioned() {
+			return nil, fmt.Errorf("%w: a user with email %s is already provisioned", scim.ErrUniqueness, email)
+		}
+		account = previous
+	}
+
+	if account != nil {
+		return s.reprovisionUser(ctx, account, resource, email)
+	}
+
+	preferred, _, _ := strings.Cut(resource.UserName, "@")
+	username, err := availableUsername(ctx, s.userRepo, preferred, email)
+	if err != nil {
+		return nil, err
+	}
+	passwordHash, err := randomPasswordHash()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := user.NewUser(email, username, passwordHash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", scim.ErrInvalidValue, err)
+	}
+	applyUserAttributes(u, resource, email)
+	u.Status = user.StatusActive
+	if !resource.IsActive() {
+		u.Status = user.StatusSuspended
+	}
+
+	if err := s.userRepo.Create(ctx, u); err != nil {
+		if errors.Is(err, user.ErrEmailAlreadyExists) {
+			return nil, fmt.Errorf("%w: a user with email %s already exists", scim.ErrUniqueness, email)
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	now := time.Now()
+	account = &scim.Account{
+		OrganizationID: organizationID,
+		UserID:         u.ID,
+		UserName:       resource.UserName,
+		ExternalID:     resource.ExternalID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.accountRepo.Create(ctx, account); err != nil {
+		if errors.Is(err, scim.ErrUniqueness) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create SCIM account: %w", err)
+	}
+
+	return s.userResource(account, u, nil)
+}
+
+// reprovisionUser brings back a user the organization deprovisioned
+func (s *SCIMService) reprovisionUser(ctx context.Context, account *scim.Account, resource *scim.UserResource, email string) (*scim.UserResource, error) {
+	u, err := s.userRepo.GetByID(ctx, account.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	account.DeprovisionedAt = nil
+	return s.updateUser(ctx, account, u, resource, email)
+}
+
+// ReplaceUser replaces a provisioned user's attributes. The request is rejected if
+// ifMatch is set and does not match the user's current version.
+func (s *SCIMService) ReplaceUser(ctx context.Context, organizationID, userID uuid.UUID, resource *scim.UserResource, ifMatch string) (*scim.UserResource, error) {
+	account, u, err := s.getAccount(ctx, organizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkUserVersion(ctx, account, u, ifMatch); err != nil {
+		return nil, err
+	}
+
+	email, err := userEmail(resource)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateUser(ctx, account, u, resource, email)
+}
+
+// PatchUser applies a PATCH request to a provisioned user
+func (s *SCIMService) PatchUser(ctx context.Context, organizationID, userID uuid.UUID, request *scim.PatchRequest, ifMatch string) (*scim.UserResource, error) {
+	account, u, err := s.getAccount(ctx, organizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.groupRepo.ListByMember(ctx, organizationID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+	current, err := s.userResource(account, u, groups)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+		return nil, err
+	}
+
+	var patched scim.UserResource
+	if err := patchResource(current, request, &patched); err != nil {
+		return nil, err
+	}
+
+	email, err := userEmail(&patched)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateUser(ctx, account, u, &patched, email)
+}
+
+// updateUser stores a user's new attributes, suspending or reactivating the account
+// when the active attribute changes
+func (s *SCIMService) updateUser(ctx context.Context, account *scim.Account, u *user.User, resource *scim.UserResource, email string) (*scim.UserResource, error) {
+	if !strings.EqualFold(account.UserName, resource.UserName) {
+		other, err := s.accountRepo.GetByUserName(ctx, account.OrganizationID, resource.UserName)
+		if err != nil && !errors.Is(err, scim.ErrResourceNotFound) {
+			return nil, fmt.Errorf("failed to get SCIM account: %w", err)
+		}
+		if other != nil && other.UserID != account.UserID {
+			return nil, fmt.Errorf("%w: userName %s is already provisioned", scim.ErrUniqueness, resource.UserName)
+		}
+	}
+	if email != u.Email {
+		other, err := s.userRepo.GetByEmail(ctx, email)
+		if err != nil && !errors.Is(err, user.ErrUserNotFound) {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		if other != nil && other.ID != u.ID {
+			return nil, fmt.Errorf("%w: a user with email %s already exists", scim.ErrUniqueness, email)
+		}
+	}
+
+	applyUserAttributes(u, resource, email)
+
+	deactivated := false
+	if resource.IsActive() {
+		u.Status = user.StatusActive
+	} else if u.Status == user.StatusActive {
+		u.Status = user.StatusSuspended
+		deactivated = true
+	}
+
+	now := time.Now()
+	u.UpdatedAt = now
+	if err := s.userRepo.Update(ctx, u); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	if deactivated {
+		s.signOut(ctx, u.ID)
+	}
+
+	account.UserName = resource.UserName
+	account.ExternalID = resource.ExternalID
+	account.UpdatedAt = now
+	if err := s.accountRepo.Update(ctx, account); err != nil {
+		return nil, fmt.Errorf("failed to update SCIM account: %w", err)
+	}
+
+	groups, err := s.groupRepo.ListByMember(ctx, account.OrganizationID, u.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+	return s.userResource(account, u, groups)
+}
+
+// DeleteUser deprovisions a user. The account is suspended rather than deleted so
+// that its history is kept: its tokens are revoked, it is removed from the
+// organization's groups and it is no longer visible through SCIM.
+func (s *SCIMService) DeleteUser(ctx context.Context, organizationID, userID uuid.UUID, ifMatch string) error {
+	account, u, err := s.getAccount(ctx, organizationID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkUserVersion(ctx, account, u, ifMatch); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	u.Status = user.StatusSuspended
+	u.UpdatedAt = now
+	if err := s.userRepo.Update(ctx, u); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	s.signOut(ctx, u.ID)
+
+	groups, err := s.groupRepo.ListByMember(ctx, organizationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+	for _, group := range groups {
+		members := make([]uuid.UUID, 0, len(group.Members))
+		for _, member := range group.Members {
+			if member != userID {
+				members = append(members, member)
+			}
+		}
+		if err := s.setMembers(ctx, group, members); err != nil {
+			return err
+		}
+		group.UpdatedAt = now
+		if err := s.groupRepo.Update(ctx, group); err != nil {
+			return fmt.Errorf("failed to update SCIM group: %w", err)
+		}
+	}
+
+	account.DeprovisionedAt = &now
+	account.UpdatedAt = now
+	if err := s.accountRepo.Update(ctx, account); err != nil {
+		return fmt.Errorf("failed to update SCIM account: %w", err)
+	}
+	return nil
+}
+
+// GetGroup returns one of an organization's groups
+func (s *SCIMService) GetGroup(ctx context.Context, organizationID, groupID uuid.UUID) (*scim.GroupResource, error) {
+	group, err := s.groupRepo.GetByID(ctx, organizationID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupResource(group)
+}
+
+// ListGroups returns a page of an organization's groups matching the query filter
+func (s *SCIMService) ListGroups(ctx context.Context, organizationID uuid.UUID, query *scim.ListQuery) (*scim.ListResponse, error) {
+	filter, err := parseListFilter(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.groupRepo.ListByOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+
+	var matches []interface{}
+	for _, group := range groups {
+		resource, err := s.groupResource(group)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := matchesFilter(filter, resource)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, resource)
+		}
+	}
+
+	return s.page(matches, query), nil
+}
+
+// CreateGroup creates a group for an organization, along with the role granted to its members
+func (s *SCIMService) CreateGroup(ctx context.Context, organizationID uuid.UUID, resource *scim.GroupResource) (*scim.GroupResource, error) {
+	if err := s.checkDisplayName(ctx, organizationID, uuid.Nil, resource.DisplayName); err != nil {
+		return nil, err
+	}
+	members, err := s.resolveMembers(ctx, organizationID, resource.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	group := &scim.Group{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+		DisplayName:    resource.DisplayName,
+		ExternalID:     resource.ExternalID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	if s.roleRepo != nil {
+		role := &rbac.Role{
+			ID:          uuid.New(),
+			Name:        group.RoleName(),
+			Description: "Members of the SCIM group " + group.DisplayName,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := s.roleRepo.Create(ctx, role); err != nil {
+			return nil, fmt.Errorf("failed to create group role: %w", err)
+		}
+		group.RoleID = role.ID
+	}
+
+	if err := s.setMembers(ctx, group, members); err != nil {
+		return nil, err
+	}
+
+	if err := s.groupRepo.Create(ctx, group); err != nil {
+		if s.roleRepo != nil {
+			_ = s.roleRepo.Delete(ctx, group.RoleID)
+		}
+		if errors.Is(err, scim.ErrUniqueness) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create SCIM group: %w", err)
+	}
+
+	return s.groupResource(group)
+}
+
+// ReplaceGroup replaces a group's attributes and members
+func (s *SCIMService) ReplaceGroup(ctx context.Context, organizationID, groupID uuid.UUID, resource *scim.GroupResource, ifMatch string) (*scim.GroupResource, error) {
+	group, err := s.groupRepo.GetByID(ctx, organizationID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.groupResource(group)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+		return nil, err
+	}
+
+	return s.updateGroup(ctx, group, resource)
+}
+
+// PatchGroup applies a PATCH request to a group, typically to add or remove members
+func (s *SCIMService) PatchGroup(ctx context.Context, organizationID, groupID uuid.UUID, request *scim.PatchRequest, ifMatch string) (*scim.GroupResource, error) {
+	group, err := s.groupRepo.GetByID(ctx, organizationID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.groupResource(group)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+		return nil, err
+	}
+
+	var patched scim.GroupResource
+	if err := patchResource(current, request, &patched); err != nil {
+		return nil, err
+	}
+	return s.updateGroup(ctx, group, &patched)
+}
+
+// updateGroup stores a group's new attributes, granting its role to new members
+// and revoking it from removed ones
+func (s *SCIMService) updateGroup(ctx context.Context, group *scim.Group, resource *scim.GroupResource) (*scim.GroupResource, error) {
+	if err := s.checkDisplayName(ctx, group.OrganizationID, group.ID, resource.DisplayName); err != nil {
+		return nil, err
+	}
+	members, err := s.resolveMembers(ctx, group.OrganizationID, resource.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.setMembers(ctx, group, members); err != nil {
+		return nil, err
+	}
+	group.DisplayName = resource.DisplayName
+	group.ExternalID = resource.ExternalID
+	group.UpdatedAt = time.Now()
+
+	if err := s.groupRepo.Update(ctx, group); err != nil {
+		if errors.Is(err, scim.ErrUniqueness) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update SCIM group: %w", err)
+	}
+
+	return s.groupResource(group)
+}
+
+// DeleteGroup removes a group and revokes its role from the members
+func (s *SCIMService) DeleteGroup(ctx context.Context, organizationID, groupID uuid.UUID, ifMatch string) error {
+	group, err := s.groupRepo.GetByID(ctx, organizationID, groupID)
+	if err != nil {
+		return err
+	}
+	current, err := s.groupResource(group)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+		return err
+	}
+
+	if err := s.setMembers(ctx, group, nil); err != nil {
+		return err
+	}
+	if err := s.groupRepo.Delete(ctx, organizationID, groupID); err != nil {
+		return err
+	}
+	if s.roleRepo != nil && group.RoleID != uuid.Nil {
+		if err := s.roleRepo.Delete(ctx, group.RoleID); err != nil && !errors.Is(err, rbac.ErrRoleNotFound) {
+			return fmt.Errorf("failed to delete group role: %w", err)
+		}
+	}
+	return nil
+}
+
+// getAccount returns a provisioned user and its account. Deprovisioned users are not found.
+func (s *SCIMService) getAccount(ctx context.Context, organizationID, userID uuid.UUID) (*scim.Account, *user.User, error) {
+	account, err := s.accountRepo.Get(ctx, organizationID, userID)
+	if err != nil {
+		if errors.Is(err, scim.ErrResourceNotFound) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("failed to get SCIM account: %w", err)
+	}
+	if account.IsDeprovisioned() {
+		return nil, nil, scim.ErrResourceNotFound
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return nil, nil, scim.ErrResourceNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return account, u, nil
+}
+
+// checkUserVersion compares ifMatch with a user's current version
+func (s *SCIMService) checkUserVersion(ctx context.Context, account *scim.Account, u *user.User, ifMatch string) error {
+	if ifMatch == "" {
+		return nil
+	}
+	groups, err := s.groupRepo.ListByMember(ctx, account.OrganizationID, u.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+	current, err := s.userResource(account, u, groups)
+	if err != nil {
+		return err
+	}
+	return checkVersion(ifMatch, current.Meta.Version)
+}
+
+// signOut revokes a user's tokens and ends their sessions. Failures are ignored
+// because the account is already suspended, which stops tokens being refreshed.
+func (s *SCIMService) signOut(ctx context.Context, userID uuid.UUID) {
+	if s.sessionService != nil {
+		_ = s.sessionService.InvalidateUserSessions(ctx, userID)
+	}
+	_ = s.tokenService.RevokeAllForUser(ctx, userID)
+}
+
+// checkDisplayName makes sure no other group of the organization has the display name
+func (s *SCIMService) checkDisplayName(ctx context.Context, organizationID, groupID uuid.UUID, displayName string) error {
+	if strings.TrimSpace(displayName) == "" {
+		return fmt.Errorf("%w: displayName is required", scim.ErrInvalidValue)
+	}
+
+	groups, err := s.groupRepo.ListByOrganization(ctx, organizationID)
+	if err != nil {
+		return fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+	for _, group := range groups {
+		if group.ID != groupID && strings.EqualFold(group.DisplayName, displayName) {
+			return fmt.Errorf("%w: a group named %s already exists", scim.ErrUniqueness, displayName)
+		}
+	}
+	return nil
+}
+
+// resolveMembers checks that every member is a user the organization provisioned
+func (s *SCIMService) resolveMembers(ctx context.Context, organizationID uuid.UUID, references []scim.Reference) ([]uuid.UUID, error) {
+	members := make([]uuid.UUID, 0, len(references))
+	seen := make(map[uuid.UUID]bool, len(references))
+	for _, reference := range references {
+		userID, err := uuid.Parse(reference.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unknown member %q", scim.ErrInvalidValue, reference.Value)
+		}
+		if seen[userID] {
+			continue
+		}
+
+		account, err := s.accountRepo.Get(ctx, organizationID, userID)
+		if err != nil {
+			if errors.Is(err, scim.ErrResourceNotFound) {
+				return nil, fmt.Errorf("%w: unknown member %q", scim.ErrInvalidValue, reference.Value)
+			}
+			return nil, fmt.Errorf("failed to get SCIM account: %w", err)
+		}
+		if account.IsDeprovisioned() {
+			return nil, fmt.Errorf("%w: unknown member %q", scim.ErrInvalidValue, reference.Value)
+		}
+
+		seen[userID] = true
+		members = append(members, userID)
+	}
+	return members, nil
+}
+
+// setMembers changes a group's members, granting the group's role to those added
+// and revoking it from those removed
+func (s *SCIMService) setMembers(ctx context.Context, group *scim.Group, members []uuid.UUID) error {
+	if s.roleRepo != nil && group.RoleID != uuid.Nil {
+		keep := make(map[uuid.UUID]bool, len(members))
+		for _, member := range members {
+			keep[member] = true
+			if group.HasMember(member) {
+				continue
+			}
+			userRole := &rbac.UserRole{
+				UserID:    member,
+				RoleID:    group.RoleID,
+				GrantedAt: time.Now(),
+			}
+			if err := s.roleRepo.AssignRole(ctx, userRole); err != nil {
+				return fmt.Errorf("failed to grant group role: %w", err)
+			}
+		}
+		for _, member := range group.Members {
+			if keep[member] {
+				continue
+			}
+			if err := s.roleRepo.RemoveRole(ctx, member, group.RoleID); err != nil {
+				return fmt.Errorf("failed to revoke group role: %w", err)
+			}
+		}
+	}
+
+	group.Members = members
+	return nil
+}
+
+// userResource builds the SCIM representation of a provisioned user
+func (s *SCIMService) userResource(account *scim.Account, u *user.User, groups []*scim.Group) (*scim.UserResource, error) {
+	active := scim.Flag(u.Status == user.StatusActive)
+	resource := &scim.UserResource{
+		Schemas:    []string{scim.SchemaUser},
+		ID:         u.ID.String(),
+		ExternalID: account.ExternalID,
+		UserName:   account.UserName,
+		Emails:     []scim.MultiValue{{Value: u.Email, Type: "work", Primary: true}},
+		Active:     &active,
+	}
+	if u.FirstName != "" || u.LastName != "" {
+		resource.Name = &scim.Name{
+			Formatted:  strings.TrimSpace(u.FirstName + " " + u.LastName),
+			GivenName:  u.FirstName,
+			FamilyName: u.LastName,
+		}
+		resource.DisplayName = resource.Name.Formatted
+	}
+	if u.PhoneNumber != "" {
+		resource.PhoneNumbers = []scim.MultiValue{{Value: u.PhoneNumber, Primary: true}}
+	}
+	for _, group := range groups {
+		resource.Groups = append(resource.Groups, scim.Reference{
+			Value:   group.ID.String(),
+			Ref:     s.location("Groups", group.ID),
+			Display: group.DisplayName,
+		})
+	}
+
+	version, err := scim.Version(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	lastModified := u.UpdatedAt
+	if account.UpdatedAt.After(lastModified) {
+		lastModified = account.UpdatedAt
+	}
+	resource.Meta = &scim.Meta{
+		ResourceType: "User",
+		Created:      account.CreatedAt,
+		LastModified: lastModified,
+		Location:     s.location("Users", u.ID),
+		Version:      version,
+	}
+	return resource, nil
+}
+
+// groupResource builds the SCIM representation of a group
+func (s *SCIMService) groupResource(group *scim.Group) (*scim.GroupResource, error) {
+	resource := &scim.GroupResource{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          group.ID.String(),
+		ExternalID:  group.ExternalID,
+		DisplayName: group.DisplayName,
+	}
+	for _, member := range group.Members {
+		resource.Members = append(resource.Members, scim.Reference{
+			Value: member.String(),
+			Ref:   s.location("Users", member),
+		})
+	}
+
+	version, err := scim.Version(resource)
+	if err != nil {
+		return nil, err
+	}
+	resource.Meta = &scim.Meta{
+		ResourceType: "Group",
+		Created:      group.CreatedAt,
+		LastModified: group.UpdatedAt,
+		Location:     s.location("Groups", group.ID),
+		Version:      version,
+	}
+	return resource, nil
+}
+
+func (s *SCIMService) location(resourceType string, id uuid.UUID) string {
+	return strings.TrimSuffix(s.config.BaseURL, "/") + "/scim/v2/" + resourceType + "/" + id.String()
+}
+
+// page returns the requested page of a list's matches (RFC 7644 section 3.4.2.4)
+func (s *SCIMService) page(matches []interface{}, query *scim.ListQuery) *scim.ListResponse {
+	startIndex := query.StartIndex
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	count := query.Count
+	if count <= 0 || count > s.config.MaxResults {
+		count = s.config.MaxResults
+	}
+
+	resources := []interface{}{}
+	if start := startIndex - 1; start < len(matches) {
+		end := start + count
+		if end > len(matches) {
+			end = len(matches)
+		}
+		resources = matches[start:end]
+	}
+
+	return &scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: len(matches),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// userEmail returns the email address of a user resource. Identity providers that
+// send no emails usually use the address as the userName.
+func userEmail(resource *scim.UserResource) (string, error) {
+	if strings.TrimSpace(resource.UserName) == "" {
+		return "", fmt.Errorf("%w: userName is required", scim.ErrInvalidValue)
+	}
+
+	email := resource.PrimaryEmail()
+	if email == "" && strings.Contains(resource.UserName, "@") {
+		email = resource.UserName
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", fmt.Errorf("%w: an email address is required", scim.ErrInvalidValue)
+	}
+	return email, nil
+}
+
+// applyUserAttributes copies the attributes of a user resource the account stores.
+// Email addresses are treated as verified because the identity provider owns them.
+func applyUserAttributes(u *user.User, resource *scim.UserResource, email string) {
+	if resource.Name != nil {
+		u.FirstName = resource.Name.GivenName
+		u.LastName = resource.Name.FamilyName
+	} else {
+		u.FirstName = ""
+		u.LastName = ""
+	}
+	u.PhoneNumber = resource.PrimaryPhoneNumber()
+
+	if u.Email != email || !u.EmailVerified {
+		now := time.Now()
+		u.Email = email
+		u.EmailVerified = true
+		u.EmailVerifiedAt = &now
+	}
+}
+
+// checkVersion implements If-Match: the request proceeds only if one of the listed
+// entity tags matches the resource's current version
+func checkVersion(ifMatch, version string) error {
+	if ifMatch == "" {
+		return nil
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(version, "W/") {
+			return nil
+		}
+	}
+	return scim.ErrPreconditionFailed
+}
+
+// patchResource applies a PATCH request to a copy of a resource, decoding the result into patched
+func patchResource(current interface{}, request *scim.PatchRequest, patched interface{}) error {
+	m, err := scim.ToMap(current)
+	if err != nil {
+		return err
+	}
+	if err := scim.ApplyPatch(m, request); err != nil {
+		return err
+	}
+	return scim.FromMap(m, patched)
+}
+
+func parseListFilter(filter string) (scim.Filter, error) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, nil
+	}
+	return scim.ParseFilter(filter)
+}
+
+func matchesFilter(filter scim.Filter, resource interface{}) (bool, error) {
+	if filter == nil {
+		return true, nil
+	}
+	m, err := scim.ToMap(resource)
+	if err != nil {
+		return false, err
+	}
+	return filter.Matches(m), nil
+}
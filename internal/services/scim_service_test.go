@@ -0,0 +1,343 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/rbac"
+	"github.com/victoralfred/um_sys/internal/domain/scim"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+type scimFixture struct {
+	service      *services.SCIMService
+	tokenService *services.TokenService
+	users        *InMemoryUserRepository
+	roles        *MockRoleRepository
+	orgID        uuid.UUID
+}
+
+func newSCIMFixture(t *testing.T) *scimFixture {
+	t.Helper()
+
+	users := NewInMemoryUserRepository()
+	tokenService := services.NewTokenService("test-secret-key-min-32-characters!!", "test-issuer", 15*time.Minute, 7*24*time.Hour, users)
+	tokenService.SetTokenStore(NewInMemoryTokenStore())
+
+	config := services.DefaultSCIMConfig()
+	config.BaseURL = "https://app.example.com"
+	config.MaxResults = 2
+	service := services.NewSCIMService(
+		NewInMemorySCIMTokenRepository(),
+		NewInMemorySCIMAccountRepository(),
+		NewInMemorySCIMGroupRepository(),
+		users,
+		tokenService,
+		config,
+	)
+
+	roles := new(MockRoleRepository)
+	roles.On("Create", mock.Anything, mock.Anything).Return(nil)
+	roles.On("Delete", mock.Anything, mock.Anything).Return(nil)
+	roles.On("AssignRole", mock.Anything, mock.Anything).Return(nil)
+	roles.On("RemoveRole", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	service.SetRoleRepository(roles)
+
+	return &scimFixture{service: service, tokenService: tokenService, users: users, roles: roles, orgID: uuid.New()}
+}
+
+func (f *scimFixture) createUser(t *testing.T, userName, email string) *scim.UserResource {
+	t.Helper()
+
+	resource, err := f.service.CreateUser(context.Background(), f.orgID, &scim.UserResource{
+		Schemas:  []string{scim.SchemaUser},
+		UserName: userName,
+		Name:     &scim.Name{GivenName: "Jane", FamilyName: "Doe"},
+		Emails:   []scim.MultiValue{{Value: email, Type: "work", Primary: true}},
+	})
+	require.NoError(t, err)
+	return resource
+}
+
+func decodePatch(t *testing.T, body string) *scim.PatchRequest {
+	t.Helper()
+
+	var request scim.PatchRequest
+	require.NoError(t, json.Unmarshal([]byte(body), &request))
+	return &request
+}
+
+func TestSCIMService_Tokens(t *testing.T) {
+	f := newSCIMFixture(t)
+	ctx := context.Background()
+
+	token, secret, err := f.service.CreateToken(ctx, f.orgID, uuid.New(), &scim.TokenRequest{Name: "Okta"})
+	require.NoError(t, err)
+	assert.Contains(t, secret, services.SCIMTokenPrefix)
+	assert.True(t, len(token.Prefix) < len(secret))
+
+	authenticated, err := f.service.AuthenticateToken(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, f.orgID, authenticated.OrganizationID)
+	assert.NotNil(t, authenticated.LastUsedAt)
+
+	_, err = f.service.AuthenticateToken(ctx, secret+"x")
+	assert.ErrorIs(t, err, scim.ErrInvalidToken)
+
+	// Another organization cannot revoke the token
+	assert.ErrorIs(t, f.service.RevokeToken(ctx, uuid.New(), token.ID), scim.ErrTokenNotFound)
+
+	require.NoError(t, f.service.RevokeToken(ctx, f.orgID, token.ID))
+	_, err = f.service.AuthenticateToken(ctx, secret)
+	assert.ErrorIs(t, err, scim.ErrInvalidToken)
+}
+
+func TestSCIMService_CreateUser(t *testing.T) {
+	f := newSCIMFixture(t)
+	ctx := context.Background()
+
+	resource := f.createUser(t, "Jane.Doe@acme.com", "Jane.Doe@acme.com")
+	assert.Equal(t, "Jane.Doe@acme.com", resource.UserName)
+	assert.True(t, resource.IsActive())
+	assert.Equal(t, "https://app.example.com/scim/v2/Users/"+resource.ID, resource.Meta.Location)
+	assert.NotEmpty(t, resource.Meta.Version)
+
+	u, err := f.users.GetByEmail(ctx, "jane.doe@acme.com")
+	require.NoError(t, err)
+	assert.Equal(t, "jane.doe", u.Username)
+	assert.Equal(t, "Jane", u.FirstName)
+	assert.True(t, u.EmailVerified)
+	assert.Equal(t, user.StatusActive, u.Status)
+
+	t.Run("rejects a userName already provisioned, ignoring case", func(t *testing.T) {
+		_, err := f.service.CreateUser(ctx, f.orgID, &scim.UserResource{UserName: "jane.doe@ACME.com", Emails: []scim.MultiValue{{Value: "other@acme.com"}}})
+		assert.ErrorIs(t, err, scim.ErrUniqueness)
+	})
+
+	t.Run("does not take over accounts it did not provision", func(t *testing.T) {
+		local, err := user.NewUser("bob@acme.com", "bob", "hash")
+		require.NoError(t, err)
+		require.NoError(t, f.users.Create(ctx, local))
+
+		_, err = f.service.CreateUser(ctx, f.orgID, &scim.UserResource{UserName: "bob@acme.com"})
+		assert.ErrorIs(t, err, scim.ErrUniqueness)
+	})
+
+	t.Run("requires an email address", func(t *testing.T) {
+		_, err := f.service.CreateUser(ctx, f.orgID, &scim.UserResource{UserName: "carol"})
+		assert.ErrorIs(t, err, scim.ErrInvalidValue)
+	})
+
+	t.Run("users of other organizations are not found", func(t *testing.T) {
+		_, err := f.service.GetUser(ctx, uuid.New(), uuid.MustParse(resource.ID))
+		assert.ErrorIs(t, err, scim.ErrResourceNotFound)
+	})
+}
+
+func TestSCIMService_ListUsers(t *testing.T) {
+	f := newSCIMFixture(t)
+	ctx := context.Background()
+
+	for _, email := range []string{"ann@acme.com", "ben@acme.com", "cat@acme.com"} {
+		f.createUser(t, email, email)
+	}
+
+	response, err := f.service.ListUsers(ctx, f.orgID, &scim.ListQuery{Filter: `userName eq "BEN@acme.com"`})
+	require.NoError(t, err)
+	assert.Equal(t, 1, response.TotalResults)
+	require.Len(t, response.Resources, 1)
+	assert.Equal(t, "ben@acme.com", response.Resources[0].(*scim.UserResource).UserName)
+
+	// Pages are capped at MaxResults
+	response, err = f.service.ListUsers(ctx, f.orgID, &scim.ListQuery{Count: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 3, response.TotalResults)
+	assert.Equal(t, 2, response.ItemsPerPage)
+
+	response, err = f.service.ListUsers(ctx, f.orgID, &scim.ListQuery{StartIndex: 3, Count: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 3, response.StartIndex)
+	assert.Len(t, response.Resources, 1)
+
+	response, err = f.service.ListUsers(ctx, f.orgID, &scim.ListQuery{StartIndex: 10})
+	require.NoError(t, err)
+	assert.Empty(t, response.Resources)
+
+	_, err = f.service.ListUsers(ctx, f.orgID, &scim.ListQuery{Filter: `userName eq`})
+	assert.ErrorIs(t, err, scim.ErrInvalidFilter)
+}
+
+func TestSCIMService_PatchUser(t *testing.T) {
+	f := newSCIMFixture(t)
+	ctx := context.Background()
+
+	resource := f.createUser(t, "jane@acme.com", "jane@acme.com")
+	userID := uuid.MustParse(resource.ID)
+
+	u, err := f.users.GetByID(ctx, userID)
+	require.NoError(t, err)
+	tokenPair, err := f.tokenService.GenerateTokenPair(ctx, u)
+	require.NoError(t, err)
+
+	t.Run("rejects a stale version", func(t *testing.T) {
+		_, err := f.service.PatchUser(ctx, f.orgID, userID, decodePatch(t, `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [{"op": "replace", "path": "name.givenName", "value": "Janet"}]
+		}`), `W/"stale"`)
+		assert.ErrorIs(t, err, scim.ErrPreconditionFailed)
+	})
+
+	patched, err := f.service.PatchUser(ctx, f.orgID, userID, decodePatch(t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "value": {"active": "False"}},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "janet@acme.com"}
+		]
+	}`), resource.Meta.Version)
+	require.NoError(t, err)
+	assert.False(t, patched.IsActive())
+	assert.Equal(t, "janet@acme.com", patched.PrimaryEmail())
+	assert.NotEqual(t, resource.Meta.Version, patched.Meta.Version)
+
+	u, err = f.users.GetByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, user.StatusSuspended, u.Status)
+	assert.Equal(t, "janet@acme.com", u.Email)
+
+	// Deactivation signs the user out everywhere
+	_, err = f.tokenService.RefreshTokens(ctx, tokenPair.RefreshToken)
+	assert.Error(t, err)
+
+	patched, err = f.service.PatchUser(ctx, f.orgID, userID, decodePatch(t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "path": "active", "value": true}]
+	}`), "")
+	require.NoError(t, err)
+	assert.True(t, patched.IsActive())
+}
+
+func TestSCIMService_DeleteUser(t *testing.T) {
+	f := newSCIMFixture(t)
+	ctx := context.Background()
+
+	resource := f.createUser(t, "jane@acme.com", "jane@acme.com")
+	userID := uuid.MustParse(resource.ID)
+
+	group, err := f.service.CreateGroup(ctx, f.orgID, &scim.GroupResource{
+		DisplayName: "Engineering",
+		Members:     []scim.Reference{{Value: resource.ID}},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, f.service.DeleteUser(ctx, f.orgID, userID, ""))
+
+	_, err = f.service.GetUser(ctx, f.orgID, userID)
+	assert.ErrorIs(t, err, scim.ErrResourceNotFound)
+
+	u, err := f.users.GetByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, user.StatusSuspended, u.Status)
+
+	group, err = f.service.GetGroup(ctx, f.orgID, uuid.MustParse(group.ID))
+	require.NoError(t, err)
+	assert.Empty(t, group.Members)
+	f.roles.AssertCalled(t, "RemoveRole", mock.Anything, userID, mock.Anything)
+
+	// Provisioning the user again reactivates the same account
+	again := f.createUser(t, "jane@acme.com", "jane@acme.com")
+	assert.Equal(t, resource.ID, again.ID)
+	assert.True(t, again.IsActive())
+
+	u, err = f.users.GetByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, user.StatusActive, u.Status)
+}
+
+func TestSCIMService_Groups(t *testing.T) {
+	f := newSCIMFixture(t)
+	ctx := context.Background()
+
+	jane := f.createUser(t, "jane@acme.com", "jane@acme.com")
+	john := f.createUser(t, "john@acme.com", "john@acme.com")
+
+	group, err := f.service.CreateGroup(ctx, f.orgID, &scim.GroupResource{
+		Schemas:     []string{scim.SchemaGroup},
+		DisplayName: "Engineering",
+		Members:     []scim.Reference{{Value: jane.ID}},
+	})
+	require.NoError(t, err)
+	groupID := uuid.MustParse(group.ID)
+
+	f.roles.AssertCalled(t, "Create", mock.Anything, mock.MatchedBy(func(role *rbac.Role) bool {
+		return role.Name == "scim:"+group.ID
+	}))
+	f.roles.AssertCalled(t, "AssignRole", mock.Anything, mock.MatchedBy(func(ur *rbac.UserRole) bool {
+		return ur.UserID.String() == jane.ID
+	}))
+
+	// Members see the group on their user resource
+	resource, err := f.service.GetUser(ctx, f.orgID, uuid.MustParse(jane.ID))
+	require.NoError(t, err)
+	require.Len(t, resource.Groups, 1)
+	assert.Equal(t, "Engineering", resource.Groups[0].Display)
+
+	t.Run("adds and removes members the way Azure AD does", func(t *testing.T) {
+		patched, err := f.service.PatchGroup(ctx, f.orgID, groupID, decodePatch(t, `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [
+				{"op": "Add", "path": "members", "value": [{"value": "`+john.ID+`"}]},
+				{"op": "Remove", "path": "members", "value": [{"value": "`+jane.ID+`"}]}
+			]
+		}`), group.Meta.Version)
+		require.NoError(t, err)
+		require.Len(t, patched.Members, 1)
+		assert.Equal(t, john.ID, patched.Members[0].Value)
+
+		f.roles.AssertCalled(t, "RemoveRole", mock.Anything, uuid.MustParse(jane.ID), mock.Anything)
+	})
+
+	t.Run("removes members by filter", func(t *testing.T) {
+		patched, err := f.service.PatchGroup(ctx, f.orgID, groupID, decodePatch(t, `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [{"op": "remove", "path": "members[value eq \"`+john.ID+`\"]"}]
+		}`), "")
+		require.NoError(t, err)
+		assert.Empty(t, patched.Members)
+	})
+
+	t.Run("rejects unknown members", func(t *testing.T) {
+		_, err := f.service.ReplaceGroup(ctx, f.orgID, groupID, &scim.GroupResource{
+			DisplayName: "Engineering",
+			Members:     []scim.Reference{{Value: uuid.New().String()}},
+		}, "")
+		assert.ErrorIs(t, err, scim.ErrInvalidValue)
+	})
+
+	t.Run("rejects duplicate display names", func(t *testing.T) {
+		_, err := f.service.CreateGroup(ctx, f.orgID, &scim.GroupResource{DisplayName: "engineering"})
+		assert.ErrorIs(t, err, scim.ErrUniqueness)
+
+		// Other organizations may use the same name
+		_, err = f.service.CreateGroup(ctx, uuid.New(), &scim.GroupResource{DisplayName: "Engineering"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("filters groups", func(t *testing.T) {
+		response, err := f.service.ListGroups(ctx, f.orgID, &scim.ListQuery{Filter: `displayName eq "engineering"`})
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.TotalResults)
+	})
+
+	require.NoError(t, f.service.DeleteGroup(ctx, f.orgID, groupID, ""))
+	_, err = f.service.GetGroup(ctx, f.orgID, groupID)
+	assert.ErrorIs(t, err, scim.ErrResourceNotFound)
+	f.roles.AssertCalled(t, "Delete", mock.Anything, mock.Anything)
+}
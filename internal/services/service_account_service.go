@@ -0,0 +1,377 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/oauth"
+	"github.com/victoralfred/um_sys/internal/domain/serviceaccount"
+)
+
+const (
+	// ServiceAccountClientIDPrefix starts every service account client ID so they
+	// cannot be confused with OAuth clients acting for users
+	ServiceAccountClientIDPrefix = "sa_"
+
+	serviceAccountClientIDBytes = 16
+	maxRoleNameLength           = 100
+)
+
+// ServiceAccountService manages service accounts and issues their tokens
+type ServiceAccountService struct {
+	repo         serviceaccount.Repository
+	tokenService *TokenService
+	auditService audit.AuditService
+}
+
+// NewServiceAccountService creates a new service account service
+func NewServiceAccountService(repo serviceaccount.Repository, tokenService *TokenService) *ServiceAccountService {
+	return &ServiceAccountService{
+		repo:         repo,
+		tokenService: tokenService,
+	}
+}
+
+// SetAuditService sets the audit service used to record service account activity
+func (s *ServiceAccountService) SetAuditService(auditService audit.AuditService) {
+	s.auditService = auditService
+}
+
+// Create creates a service account with the requested roles and returns it with its secret.
+// The secret is only available here and after rotation.
+func (s *ServiceAccountService) Create(ctx context.Context, req *serviceaccount.CreateRequest, actor audit.Actor) (*serviceaccount.ServiceAccount, string, error) {
+	roles := make([]string, 0, len(req.Roles))
+	for _, role := range req.Roles {
+		role = strings.TrimSpace(role)
+		if err := validateRoleName(role); err != nil {
+			return nil, "", err
+		}
+		if !containsRole(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	clientID, err := generateServiceAccountClientID()
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := generateOAuthSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	account := &serviceaccount.ServiceAccount{
+		ID:              uuid.New(),
+		Name:            strings.TrimSpace(req.Name),
+		Description:     req.Description,
+		ClientID:        clientID,
+		SecretHash:      hashOAuthSecret(secret),
+		Roles:           []string{},
+		Status:          serviceaccount.StatusActive,
+		CreatedBy:       actor.ID,
+		SecretRotatedAt: now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	if err := s.repo.Create(ctx, account); err != nil {
+		if errors.Is(err, serviceaccount.ErrServiceAccountExists) {
+			return nil, "", err
+		}
+		return nil, "", fmt.Errorf("failed to create service account: %w", err)
+	}
+
+	for _, role := range roles {
+		if err := s.repo.AssignRole(ctx, &serviceaccount.RoleAssignment{
+			ServiceAccountID: account.ID,
+			Role:             role,
+			GrantedBy:        actor.ID,
+			GrantedAt:        now,
+		}); err != nil {
+			return nil, "", fmt.Errorf("failed to assign role: %w", err)
+		}
+		account.Roles = append(account.Roles, role)
+	}
+
+	s.log(ctx, audit.EventTypeServiceAccountCreated, account, actor, "Service account created", map[string]interface{}{
+		"client_id": account.ClientID,
+		"roles":     account.Roles,
+	})
+
+	return account, secret, nil
+}
+
+// Get retrieves a service account
+func (s *ServiceAccountService) Get(ctx context.Context, id uuid.UUID) (*serviceaccount.ServiceAccount, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// List returns every service account
+func (s *ServiceAccountService) List(ctx context.Context) ([]*serviceaccount.ServiceAccount, error) {
+	return s.repo.List(ctx)
+}
+
+// Update changes a service account's name, description or status.
+// Disabling an account stops it obtaining new tokens; tokens already issued expire on their own.
+func (s *ServiceAccountService) Update(ctx context.Context, id uuid.UUID, req *serviceaccount.UpdateRequest, actor audit.Actor) (*serviceaccount.ServiceAccount, error) {
+	account, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		account.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		account.Description = *req.Description
+	}
+	if req.Status != nil {
+		account.Status = *req.Status
+	}
+	account.UpdatedAt = time.Now()
+
+	if err := s.repo.Update(ctx, account); err != nil {
+		if errors.Is(err, serviceaccount.ErrServiceAccountExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update service account: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeServiceAccountUpdated, account, actor, "Service account updated", map[string]interface{}{
+		"status": account.Status,
+	})
+
+	return account, nil
+}
+
+// Delete removes a service account
+func (s *ServiceAccountService) Delete(ctx context.Context, id uuid.UUID, actor audit.Actor) error {
+	account, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete service account: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeServiceAccountDeleted, account, actor, "Service account deleted", nil)
+
+	return nil
+}
+
+// RotateSecret replaces a service account's secret and returns the new one.
+// The previous secret stops working immediately.
+func (s *ServiceAccountService) RotateSecret(ctx context.Context, id uuid.UUID, actor audit.Actor) (string, error) {
+	account, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := generateOAuthSecret()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	account.SecretHash = hashOAuthSecret(secret)
+	account.SecretRotatedAt = now
+	account.UpdatedAt = now
+
+	if err := s.repo.Update(ctx, account); err != nil {
+		return "", fmt.Errorf("failed to rotate service account secret: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeServiceAccountSecretRotated, account, actor, "Service account secret rotated", nil)
+
+	return secret, nil
+}
+
+// AssignRole grants a role to a service account, mirroring RBACService.AssignRoleToUser.
+// The role takes effect on the next token the account obtains.
+func (s *ServiceAccountService) AssignRole(ctx context.Context, id uuid.UUID, role string, actor audit.Actor) error {
+	role = strings.TrimSpace(role)
+	if err := validateRoleName(role); err != nil {
+		return err
+	}
+
+	account, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if account.HasRole(role) {
+		return serviceaccount.ErrRoleAlreadyAssigned
+	}
+
+	if err := s.repo.AssignRole(ctx, &serviceaccount.RoleAssignment{
+		ServiceAccountID: id,
+		Role:             role,
+		GrantedBy:        actor.ID,
+		GrantedAt:        time.Now(),
+	}); err != nil {
+		if errors.Is(err, serviceaccount.ErrRoleAlreadyAssigned) {
+			return err
+		}
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeRoleAssigned, account, actor, "Role assigned to service account", map[string]interface{}{
+		"role": role,
+	})
+
+	return nil
+}
+
+// RemoveRole withdraws a role from a service account, mirroring RBACService.RemoveRoleFromUser
+func (s *ServiceAccountService) RemoveRole(ctx context.Context, id uuid.UUID, role string, actor audit.Actor) error {
+	account, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.RemoveRole(ctx, id, role); err != nil {
+		if errors.Is(err, serviceaccount.ErrRoleNotAssigned) {
+			return err
+		}
+		return fmt.Errorf("failed to remove role: %w", err)
+	}
+
+	s.log(ctx, audit.EventTypeRoleRevoked, account, actor, "Role removed from service account", map[string]interface{}{
+		"role": role,
+	})
+
+	return nil
+}
+
+// GetRoles returns the roles assigned to a service account
+func (s *ServiceAccountService) GetRoles(ctx context.Context, id uuid.UUID) ([]string, error) {
+	account, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return account.Roles, nil
+}
+
+// Authenticate verifies a service account's client credentials
+func (s *ServiceAccountService) Authenticate(ctx context.Context, clientID, secret string) (*serviceaccount.ServiceAccount, error) {
+	if !strings.HasPrefix(clientID, ServiceAccountClientIDPrefix) || secret == "" {
+		return nil, serviceaccount.ErrInvalidCredentials
+	}
+
+	account, err := s.repo.GetByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, serviceaccount.ErrServiceAccountNotFound) {
+			return nil, serviceaccount.ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to get service account: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashOAuthSecret(secret)), []byte(account.SecretHash)) != 1 {
+		return nil, serviceaccount.ErrInvalidCredentials
+	}
+
+	if !account.IsActive() {
+		return nil, serviceaccount.ErrInvalidCredentials
+	}
+
+	return account, nil
+}
+
+// IssueToken handles the client_credentials grant. Service account tokens carry the
+// account's roles rather than OAuth scopes, so requesting a scope is refused.
+func (s *ServiceAccountService) IssueToken(ctx context.Context, clientID, secret, scope string) (*oauth.TokenResponse, error) {
+	account, err := s.Authenticate(ctx, clientID, secret)
+	if err != nil {
+		if errors.Is(err, serviceaccount.ErrInvalidCredentials) {
+			return nil, oauth.ErrInvalidClient
+		}
+		return nil, err
+	}
+
+	if strings.TrimSpace(scope) != "" {
+		return nil, fmt.Errorf("%w: service accounts are authorized by role", oauth.ErrInvalidScope)
+	}
+
+	tokenPair, err := s.tokenService.GenerateServiceAccountToken(account)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_ = s.repo.UpdateLastAuthenticated(ctx, account.ID, now)
+
+	s.log(ctx, audit.EventTypeServiceAccountTokenIssued, account,
+		audit.Actor{ID: account.ID, Type: audit.ActorTypeServiceAccount},
+		"Access token issued to service account", nil)
+
+	return &oauth.TokenResponse{
+		AccessToken: tokenPair.AccessToken,
+		TokenType:   tokenPair.TokenType,
+		ExpiresIn:   tokenPair.ExpiresIn,
+	}, nil
+}
+
+// log records service account activity when an audit service is configured
+func (s *ServiceAccountService) log(
+	ctx context.Context,
+	eventType audit.EventType,
+	account *serviceaccount.ServiceAccount,
+	actor audit.Actor,
+	description string,
+	metadata map[string]interface{},
+) {
+	if s.auditService == nil {
+		return
+	}
+
+	actorID := actor.ID
+	_, _ = s.auditService.Log(ctx, &audit.CreateLogRequest{
+		EventType:   eventType,
+		Severity:    audit.SeverityInfo,
+		ActorID:     &actorID,
+		ActorType:   actor.Type,
+		EntityType:  "service_account",
+		EntityID:    account.ID.String(),
+		Action:      string(eventType),
+		Description: description,
+		Metadata:    metadata,
+	})
+}
+
+// validateRoleName checks that a role name can be carried in a token
+func validateRoleName(role string) error {
+	if role == "" || len(role) > maxRoleNameLength || strings.ContainsAny(role, " \t\n") {
+		return fmt.Errorf("%w: %q", serviceaccount.ErrInvalidRole, role)
+	}
+	return nil
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// generateServiceAccountClientID generates a new service account client ID
+func generateServiceAccountClientID() (string, error) {
+	b := make([]byte, serviceAccountClientIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate client id: %w", err)
+	}
+	return ServiceAccountClientIDPrefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,238 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/oauth"
+	"github.com/victoralfred/um_sys/internal/domain/serviceaccount"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+type serviceAccountFixture struct {
+	service      *services.ServiceAccountService
+	oauthService *services.OAuthService
+	tokenService *services.TokenService
+	repo         *InMemoryServiceAccountRepository
+	admin        audit.Actor
+}
+
+func newServiceAccountFixture(t *testing.T) *serviceAccountFixture {
+	t.Helper()
+
+	f := newOAuthFixture(t)
+	repo := NewInMemoryServiceAccountRepository()
+	service := services.NewServiceAccountService(repo, f.tokenService)
+	f.service.SetServiceAccountService(service)
+
+	return &serviceAccountFixture{
+		service:      service,
+		oauthService: f.service,
+		tokenService: f.tokenService,
+		repo:         repo,
+		admin:        audit.Actor{ID: uuid.New(), Type: audit.ActorTypeUser},
+	}
+}
+
+func (f *serviceAccountFixture) create(t *testing.T, roles ...string) (*serviceaccount.ServiceAccount, string) {
+	t.Helper()
+
+	account, secret, err := f.service.Create(context.Background(), &serviceaccount.CreateRequest{
+		Name:  "reporting-" + uuid.NewString()[:8],
+		Roles: roles,
+	}, f.admin)
+	require.NoError(t, err)
+	return account, secret
+}
+
+func (f *serviceAccountFixture) exchange(clientID, secret string) (*oauth.TokenResponse, error) {
+	return f.oauthService.Exchange(context.Background(), &oauth.TokenRequest{
+		GrantType:    oauth.GrantTypeClientCredentials,
+		ClientID:     clientID,
+		ClientSecret: secret,
+	})
+}
+
+func TestServiceAccountService_Create(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns secret once and stores only its hash", func(t *testing.T) {
+		f := newServiceAccountFixture(t)
+
+		account, secret := f.create(t, "reporter", "reporter", "auditor")
+
+		assert.True(t, strings.HasPrefix(account.ClientID, services.ServiceAccountClientIDPrefix))
+		assert.NotEmpty(t, secret)
+		assert.Equal(t, []string{"reporter", "auditor"}, account.Roles)
+		assert.Equal(t, f.admin.ID, account.CreatedBy)
+
+		stored, err := f.repo.GetByID(ctx, account.ID)
+		require.NoError(t, err)
+		assert.NotEqual(t, secret, stored.SecretHash)
+		assert.ElementsMatch(t, []string{"reporter", "auditor"}, stored.Roles)
+	})
+
+	t.Run("rejects malformed role names", func(t *testing.T) {
+		f := newServiceAccountFixture(t)
+
+		_, _, err := f.service.Create(ctx, &serviceaccount.CreateRequest{
+			Name:  "bad-roles",
+			Roles: []string{"two words"},
+		}, f.admin)
+		assert.ErrorIs(t, err, serviceaccount.ErrInvalidRole)
+	})
+
+	t.Run("rejects duplicate names", func(t *testing.T) {
+		f := newServiceAccountFixture(t)
+
+		req := &serviceaccount.CreateRequest{Name: "exporter"}
+		_, _, err := f.service.Create(ctx, req, f.admin)
+		require.NoError(t, err)
+
+		_, _, err = f.service.Create(ctx, req, f.admin)
+		assert.ErrorIs(t, err, serviceaccount.ErrServiceAccountExists)
+	})
+}
+
+func TestServiceAccountService_ClientCredentialsGrant(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("issues an access token carrying roles and principal type", func(t *testing.T) {
+		f := newServiceAccountFixture(t)
+		account, secret := f.create(t, "reporter")
+
+		resp, err := f.exchange(account.ClientID, secret)
+		require.NoError(t, err)
+		assert.Empty(t, resp.RefreshToken)
+		assert.Equal(t, "Bearer", resp.TokenType)
+
+		claims, err := f.tokenService.ValidateToken(ctx, resp.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, auth.PrincipalServiceAccount, claims.PrincipalType)
+		assert.Equal(t, account.ID, claims.UserID)
+		assert.Equal(t, []string{"reporter"}, claims.Roles)
+
+		stored, err := f.repo.GetByID(ctx, account.ID)
+		require.NoError(t, err)
+		assert.NotNil(t, stored.LastAuthenticatedAt)
+	})
+
+	t.Run("rejects a wrong secret", func(t *testing.T) {
+		f := newServiceAccountFixture(t)
+		account, _ := f.create(t)
+
+		_, err := f.exchange(account.ClientID, "wrong-secret")
+		assert.ErrorIs(t, err, oauth.ErrInvalidClient)
+	})
+
+	t.Run("rejects a disabled account", func(t *testing.T) {
+		f := newServiceAccountFixture(t)
+		account, secret := f.create(t)
+
+		disabled := serviceaccount.StatusDisabled
+		_, err := f.service.Update(ctx, account.ID, &serviceaccount.UpdateRequest{Status: &disabled}, f.admin)
+		require.NoError(t, err)
+
+		_, err = f.exchange(account.ClientID, secret)
+		assert.ErrorIs(t, err, oauth.ErrInvalidClient)
+	})
+
+	t.Run("rotation invalidates the previous secret", func(t *testing.T) {
+		f := newServiceAccountFixture(t)
+		account, oldSecret := f.create(t)
+
+		newSecret, err := f.service.RotateSecret(ctx, account.ID, f.admin)
+		require.NoError(t, err)
+		assert.NotEqual(t, oldSecret, newSecret)
+
+		_, err = f.exchange(account.ClientID, oldSecret)
+		assert.ErrorIs(t, err, oauth.ErrInvalidClient)
+
+		_, err = f.exchange(account.ClientID, newSecret)
+		assert.NoError(t, err)
+	})
+
+	t.Run("refuses requested scopes", func(t *testing.T) {
+		f := newServiceAccountFixture(t)
+		account, secret := f.create(t)
+
+		_, err := f.oauthService.Exchange(ctx, &oauth.TokenRequest{
+			GrantType:    oauth.GrantTypeClientCredentials,
+			ClientID:     account.ClientID,
+			ClientSecret: secret,
+			Scope:        "openid",
+		})
+		assert.ErrorIs(t, err, oauth.ErrInvalidScope)
+	})
+
+	t.Run("unsupported without service accounts", func(t *testing.T) {
+		f := newOAuthFixture(t)
+
+		_, err := f.service.Exchange(ctx, &oauth.TokenRequest{
+			GrantType:    oauth.GrantTypeClientCredentials,
+			ClientID:     "sa_unknown",
+			ClientSecret: "secret",
+		})
+		assert.ErrorIs(t, err, oauth.ErrUnsupportedGrantType)
+	})
+}
+
+func TestServiceAccountService_Roles(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("assigned roles appear in the next token", func(t *testing.T) {
+		f := newServiceAccountFixture(t)
+		account, secret := f.create(t)
+
+		require.NoError(t, f.service.AssignRole(ctx, account.ID, "admin", f.admin))
+		assert.ErrorIs(t, f.service.AssignRole(ctx, account.ID, "admin", f.admin), serviceaccount.ErrRoleAlreadyAssigned)
+
+		resp, err := f.exchange(account.ClientID, secret)
+		require.NoError(t, err)
+
+		claims, err := f.tokenService.ValidateToken(ctx, resp.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"admin"}, claims.Roles)
+	})
+
+	t.Run("removing a missing role fails", func(t *testing.T) {
+		f := newServiceAccountFixture(t)
+		account, _ := f.create(t, "reporter")
+
+		require.NoError(t, f.service.RemoveRole(ctx, account.ID, "reporter", f.admin))
+		assert.ErrorIs(t, f.service.RemoveRole(ctx, account.ID, "reporter", f.admin), serviceaccount.ErrRoleNotAssigned)
+
+		roles, err := f.service.GetRoles(ctx, account.ID)
+		require.NoError(t, err)
+		assert.Empty(t, roles)
+	})
+}
+
+func TestServiceAccountService_AuditActorType(t *testing.T) {
+	f := newServiceAccountFixture(t)
+
+	auditService := new(MockAuditService)
+	auditService.On("Log", mock.Anything, mock.MatchedBy(func(req *audit.CreateLogRequest) bool {
+		return req.EventType == audit.EventTypeServiceAccountCreated && req.ActorType == audit.ActorTypeUser
+	})).Return(&audit.LogEntry{}, nil).Once()
+	auditService.On("Log", mock.Anything, mock.MatchedBy(func(req *audit.CreateLogRequest) bool {
+		return req.EventType == audit.EventTypeServiceAccountTokenIssued &&
+			req.ActorType == audit.ActorTypeServiceAccount &&
+			req.ActorID != nil && req.EntityID == req.ActorID.String()
+	})).Return(&audit.LogEntry{}, nil).Once()
+	f.service.SetAuditService(auditService)
+
+	account, secret := f.create(t)
+	_, err := f.exchange(account.ClientID, secret)
+	require.NoError(t, err)
+
+	auditService.AssertExpectations(t)
+}
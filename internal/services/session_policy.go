@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/billing"
+	"github.com/victoralfred/um_sys/internal/domain/session"
+)
+
+// PlanProvider returns the billing plan a user is currently subscribed to
+type PlanProvider interface {
+	CurrentPlan(ctx context.Context, userID uuid.UUID) (*billing.Plan, error)
+}
+
+// SessionPolicyConfig sets session limits for everyone, by billing plan and by role
+type SessionPolicyConfig struct {
+	Default session.Policy
+	Plans   map[billing.PlanType]session.Policy
+	Roles   map[string]session.Policy
+}
+
+// SessionPolicies resolves the session policy that applies to a user
+type SessionPolicies struct {
+	config SessionPolicyConfig
+	plans  PlanProvider
+}
+
+// NewSessionPolicies creates a new session policy resolver
+func NewSessionPolicies(config SessionPolicyConfig) *SessionPolicies {
+	return &SessionPolicies{config: config}
+}
+
+// SetPlanProvider enables per-plan policies
+func (p *SessionPolicies) SetPlanProvider(plans PlanProvider) {
+	p.plans = plans
+}
+
+// PolicyFor returns the default policy, overridden by the limits the user's
+// plan sets, then by those their roles set. A user with several roles gets the
+// strictest of their role policies.
+func (p *SessionPolicies) PolicyFor(ctx context.Context, userID uuid.UUID, roles []string) session.Policy {
+	policy := p.config.Default
+
+	// A plan that cannot be looked up does not apply
+	if p.plans != nil && len(p.config.Plans) > 0 {
+		if plan, err := p.plans.CurrentPlan(ctx, userID); err == nil && plan != nil {
+			policy = policy.Override(p.config.Plans[plan.Type])
+		}
+	}
+
+	var rolePolicy session.Policy
+	for _, role := range roles {
+		if rp, ok := p.config.Roles[role]; ok {
+			rolePolicy = rolePolicy.Tighten(rp)
+		}
+	}
+
+	return policy.Override(rolePolicy)
+}
@@ -0,0 +1,234 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/billing"
+	"github.com/victoralfred/um_sys/internal/domain/session"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+)
+
+// StaticPlanProvider subscribes every user to the same plan
+type StaticPlanProvider struct {
+	plan *billing.Plan
+}
+
+func (p *StaticPlanProvider) CurrentPlan(ctx context.Context, userID uuid.UUID) (*billing.Plan, error) {
+	if p.plan == nil {
+		return nil, errors.New("no active subscription")
+	}
+	return p.plan, nil
+}
+
+func TestSessionPolicies_PolicyFor(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	policies := services.NewSessionPolicies(services.SessionPolicyConfig{
+		Default: session.Policy{IdleTimeout: time.Hour, MaxConcurrent: 10, OnLimit: session.LimitEvictOldest},
+		Plans: map[billing.PlanType]session.Policy{
+			billing.PlanTypeEnterprise: {IdleTimeout: 15 * time.Minute, MaxLifetime: 12 * time.Hour},
+		},
+		Roles: map[string]session.Policy{
+			"user":  {MaxConcurrent: 5},
+			"admin": {MaxConcurrent: 2, IdleTimeout: 10 * time.Minute, OnLimit: session.LimitReject},
+		},
+	})
+
+	t.Run("falls back to the default", func(t *testing.T) {
+		policy := policies.PolicyFor(ctx, userID, nil)
+		assert.Equal(t, time.Hour, policy.IdleTimeout)
+		assert.Equal(t, 10, policy.MaxConcurrent)
+	})
+
+	t.Run("roles override the default", func(t *testing.T) {
+		policy := policies.PolicyFor(ctx, userID, []string{"user"})
+		assert.Equal(t, time.Hour, policy.IdleTimeout)
+		assert.Equal(t, 5, policy.MaxConcurrent)
+		assert.Equal(t, session.LimitEvictOldest, policy.OnLimit)
+	})
+
+	t.Run("several roles take the strictest limits", func(t *testing.T) {
+		policy := policies.PolicyFor(ctx, userID, []string{"user", "admin"})
+		assert.Equal(t, 10*time.Minute, policy.IdleTimeout)
+		assert.Equal(t, 2, policy.MaxConcurrent)
+		assert.Equal(t, session.LimitReject, policy.OnLimit)
+	})
+
+	t.Run("plans override the default and roles override plans", func(t *testing.T) {
+		plans := &StaticPlanProvider{plan: &billing.Plan{Type: billing.PlanTypeEnterprise}}
+		policies.SetPlanProvider(plans)
+		defer policies.SetPlanProvider(nil)
+
+		policy := policies.PolicyFor(ctx, userID, []string{"user"})
+		assert.Equal(t, 15*time.Minute, policy.IdleTimeout)
+		assert.Equal(t, 12*time.Hour, policy.MaxLifetime)
+		assert.Equal(t, 5, policy.MaxConcurrent)
+
+		// A plan that cannot be looked up does not apply
+		plans.plan = nil
+		policy = policies.PolicyFor(ctx, userID, []string{"user"})
+		assert.Equal(t, time.Hour, policy.IdleTimeout)
+		assert.Zero(t, policy.MaxLifetime)
+	})
+}
+
+func TestTokenService_SessionPolicy(t *testing.T) {
+	ctx := context.Background()
+	testUser := &user.User{
+		ID:       uuid.New(),
+		Email:    "test@example.com",
+		Username: "testuser",
+		Status:   user.StatusActive,
+	}
+
+	newTokenService := func(policy session.Policy) *services.TokenService {
+		tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		tokenService.SetTokenStore(NewInMemoryTokenStore())
+		tokenService.SetSessionPolicies(services.NewSessionPolicies(services.SessionPolicyConfig{Default: policy}))
+		return tokenService
+	}
+
+	t.Run("refresh tokens slide up to the maximum lifetime", func(t *testing.T) {
+		tokenService := newTokenService(session.Policy{SlidingExpiry: 2 * time.Hour, MaxLifetime: 8 * time.Hour})
+
+		tokenPair, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(2*time.Hour), tokenPair.RefreshExpiresAt, time.Minute)
+
+		claims, err := tokenService.ValidateToken(ctx, tokenPair.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		assert.WithinDuration(t, tokenPair.RefreshExpiresAt, claims.SessionExpiresAt, time.Second)
+
+		tokenService = newTokenService(session.Policy{SlidingExpiry: 12 * time.Hour, MaxLifetime: 8 * time.Hour})
+		tokenPair, err = tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(8*time.Hour), tokenPair.RefreshExpiresAt, time.Minute)
+	})
+
+	t.Run("access tokens carry the idle timeout and expire within it", func(t *testing.T) {
+		tokenService := newTokenService(session.Policy{IdleTimeout: 10 * time.Minute})
+
+		tokenPair, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+		assert.Equal(t, 300, tokenPair.ExpiresIn)
+
+		claims, err := tokenService.ValidateToken(ctx, tokenPair.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, 10*time.Minute, claims.IdleTimeout)
+		assert.True(t, claims.SessionExpiresAt.IsZero())
+	})
+
+	t.Run("refresh ends an idle session", func(t *testing.T) {
+		tokenService := newTokenService(session.Policy{IdleTimeout: 50 * time.Millisecond})
+
+		tokenPair, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+		time.Sleep(100 * time.Millisecond)
+
+		_, err = tokenService.RefreshTokens(ctx, tokenPair.RefreshToken)
+		assert.ErrorIs(t, err, session.ErrSessionIdle)
+
+		// The session's tokens stay revoked
+		_, err = tokenService.RefreshTokens(ctx, tokenPair.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+	})
+
+	t.Run("refresh keeps an active session going", func(t *testing.T) {
+		tokenService := newTokenService(session.Policy{IdleTimeout: time.Hour})
+
+		tokenPair, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+
+		_, err = tokenService.RefreshTokens(ctx, tokenPair.RefreshToken)
+		assert.NoError(t, err)
+	})
+}
+
+func TestSessionService_ConcurrentLimit(t *testing.T) {
+	ctx := context.Background()
+	testUser := &user.User{
+		ID:       uuid.New(),
+		Email:    "test@example.com",
+		Username: "testuser",
+		Status:   user.StatusActive,
+	}
+
+	setup := func(policy session.Policy) (*services.SessionService, *services.TokenService) {
+		policies := services.NewSessionPolicies(services.SessionPolicyConfig{Default: policy})
+
+		tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		tokenService.SetTokenStore(NewInMemoryTokenStore())
+		tokenService.SetSessionPolicies(policies)
+
+		sessionService := services.NewSessionService(NewInMemorySessionRepository())
+		sessionService.SetTokenService(tokenService)
+		sessionService.SetSessionPolicies(policies)
+		tokenService.SetSessionService(sessionService)
+
+		return sessionService, tokenService
+	}
+
+	signIn := func(t *testing.T, sessionService *services.SessionService, tokenService *services.TokenService) (*auth.TokenPair, *session.Session, error) {
+		t.Helper()
+
+		tokenPair, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+
+		sess, err := sessionService.StartSession(ctx, testUser, tokenPair.FamilyID, londonHome, laptopChrome, time.Hour)
+		if err != nil {
+			return tokenPair, nil, err
+		}
+		require.NoError(t, tokenService.BindSession(ctx, tokenPair.FamilyID, sess.ID))
+		time.Sleep(time.Millisecond)
+
+		return tokenPair, sess, nil
+	}
+
+	t.Run("evicts the oldest session", func(t *testing.T) {
+		sessionService, tokenService := setup(session.Policy{MaxConcurrent: 2})
+
+		oldestPair, _, err := signIn(t, sessionService, tokenService)
+		require.NoError(t, err)
+		_, second, err := signIn(t, sessionService, tokenService)
+		require.NoError(t, err)
+		_, third, err := signIn(t, sessionService, tokenService)
+		require.NoError(t, err)
+
+		sessions, err := sessionService.GetUserSessions(ctx, testUser.ID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		assert.ElementsMatch(t, []string{second.ID, third.ID}, []string{sessions[0].ID, sessions[1].ID})
+
+		_, err = tokenService.ValidateToken(ctx, oldestPair.AccessToken, auth.AccessToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+	})
+
+	t.Run("rejects sign-ins over the limit", func(t *testing.T) {
+		sessionService, tokenService := setup(session.Policy{MaxConcurrent: 1, OnLimit: session.LimitReject})
+
+		_, first, err := signIn(t, sessionService, tokenService)
+		require.NoError(t, err)
+		_, _, err = signIn(t, sessionService, tokenService)
+		assert.ErrorIs(t, err, session.ErrSessionLimitReached)
+
+		sessions, err := sessionService.GetUserSessions(ctx, testUser.ID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, first.ID, sessions[0].ID)
+
+		// Signing out makes room again
+		require.NoError(t, sessionService.RevokeSession(ctx, testUser.ID, first.ID))
+		_, _, err = signIn(t, sessionService, tokenService)
+		assert.NoError(t, err)
+	})
+}
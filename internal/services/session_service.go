@@ -4,16 +4,26 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/risk"
 	"github.com/victoralfred/um_sys/internal/domain/session"
+	"github.com/victoralfred/um_sys/internal/domain/user"
 )
 
 // SessionService handles session management operations
 type SessionService struct {
-	repo session.Repository
+	repo         session.Repository
+	tokenService *TokenService
+	policies     *SessionPolicies
+	locator      risk.Locator
+	auditService audit.AuditService
 }
 
 // NewSessionService creates a new session service
@@ -23,6 +33,27 @@ func NewSessionService(repo session.Repository) *SessionService {
 	}
 }
 
+// SetTokenService sets the token service used to revoke the refresh token
+// family bound to a session when the session ends
+func (s *SessionService) SetTokenService(tokenService *TokenService) {
+	s.tokenService = tokenService
+}
+
+// SetSessionPolicies caps how many sessions each user may hold at once
+func (s *SessionService) SetSessionPolicies(policies *SessionPolicies) {
+	s.policies = policies
+}
+
+// SetLocator records where sessions were started from using GeoIP data
+func (s *SessionService) SetLocator(locator risk.Locator) {
+	s.locator = locator
+}
+
+// SetAuditService records sessions revoked by their users in the audit log
+func (s *SessionService) SetAuditService(auditService audit.AuditService) {
+	s.auditService = auditService
+}
+
 // CreateSession creates a new user session
 func (s *SessionService) CreateSession(ctx context.Context, userID uuid.UUID, tokenID, ipAddress, userAgent string, expiresIn time.Duration) (*session.Session, error) {
 	sessionID, err := generateSessionID()
@@ -41,6 +72,7 @@ func (s *SessionService) CreateSession(ctx context.Context, userID uuid.UUID, to
 		LastActivity: now,
 		ExpiresAt:    now.Add(expiresIn),
 		IsActive:     true,
+		Location:     s.locate(ipAddress),
 	}
 
 	if err := s.repo.Store(ctx, sess); err != nil {
@@ -50,6 +82,72 @@ func (s *SessionService) CreateSession(ctx context.Context, userID uuid.UUID, to
 	return sess, nil
 }
 
+// StartSession records the session of a sign-in after applying the user's
+// concurrent session limit. Over the limit, the user's oldest sessions are
+// signed out, or, if the policy says so, session.ErrSessionLimitReached is
+// returned and the caller must refuse the sign-in.
+func (s *SessionService) StartSession(ctx context.Context, u *user.User, tokenID, ipAddress, userAgent string, expiresIn time.Duration) (*session.Session, error) {
+	if s.policies != nil {
+		var roles []string
+		if s.tokenService != nil {
+			var err error
+			if roles, err = s.tokenService.RolesFor(ctx, u); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := s.enforceLimit(ctx, u.ID, s.policies.PolicyFor(ctx, u.ID, roles)); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.CreateSession(ctx, u.ID, tokenID, ipAddress, userAgent, expiresIn)
+}
+
+// enforceLimit makes room for one more session under the policy's cap
+func (s *SessionService) enforceLimit(ctx context.Context, userID uuid.UUID, policy session.Policy) error {
+	if policy.MaxConcurrent <= 0 {
+		return nil
+	}
+
+	sessions, err := s.GetUserSessions(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	excess := len(sessions) - policy.MaxConcurrent + 1
+	if excess <= 0 {
+		return nil
+	}
+	if policy.OnLimit == session.LimitReject {
+		return session.ErrSessionLimitReached
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	for _, sess := range sessions[:excess] {
+		if err := s.endSession(ctx, sess); err != nil {
+			return fmt.Errorf("failed to evict session %s: %w", sess.ID, err)
+		}
+
+		s.log(ctx, &audit.CreateLogRequest{
+			EventType:   audit.EventTypeUserSessionRevoked,
+			Severity:    audit.SeverityInfo,
+			UserID:      &userID,
+			EntityType:  "session",
+			EntityID:    sess.ID,
+			SessionID:   sess.ID,
+			Action:      "evict",
+			Description: "Session signed out to stay within the concurrent session limit",
+			IPAddress:   sess.IPAddress,
+			UserAgent:   sess.UserAgent,
+		})
+	}
+
+	return nil
+}
+
 // GetSession retrieves a session by ID and updates last activity
 func (s *SessionService) GetSession(ctx context.Context, sessionID string) (*session.Session, error) {
 	sess, err := s.repo.GetByID(ctx, sessionID)
@@ -84,21 +182,45 @@ func (s *SessionService) GetUserSessions(ctx context.Context, userID uuid.UUID)
 		}
 	}
 
+	// Most recently used first
+	sort.Slice(activeSessions, func(i, j int) bool {
+		return activeSessions[i].LastActivity.After(activeSessions[j].LastActivity)
+	})
+
 	return activeSessions, nil
 }
 
-// InvalidateSession deactivates a session
-func (s *SessionService) InvalidateSession(ctx context.Context, sessionID string) error {
+// TouchSession records activity on a session, such as its tokens being
+// refreshed, and keeps it alive until at least expiresAt
+func (s *SessionService) TouchSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
 	sess, err := s.repo.GetByID(ctx, sessionID)
 	if err != nil {
 		return err
 	}
 
-	sess.Deactivate()
+	if !sess.IsActive {
+		return nil
+	}
+
+	sess.UpdateActivity()
+	if expiresAt.After(sess.ExpiresAt) {
+		sess.ExpiresAt = expiresAt
+	}
 	return s.repo.Update(ctx, sess)
 }
 
-// InvalidateUserSessions deactivates all sessions for a user
+// InvalidateSession deactivates a session and revokes the tokens bound to it
+func (s *SessionService) InvalidateSession(ctx context.Context, sessionID string) error {
+	sess, err := s.repo.GetByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return s.endSession(ctx, sess)
+}
+
+// InvalidateUserSessions deactivates all sessions for a user and revokes the
+// tokens bound to them
 func (s *SessionService) InvalidateUserSessions(ctx context.Context, userID uuid.UUID) error {
 	sessions, err := s.repo.GetByUserID(ctx, userID)
 	if err != nil {
@@ -107,8 +229,7 @@ func (s *SessionService) InvalidateUserSessions(ctx context.Context, userID uuid
 
 	for _, sess := range sessions {
 		if sess.IsActive {
-			sess.Deactivate()
-			if err := s.repo.Update(ctx, sess); err != nil {
+			if err := s.endSession(ctx, sess); err != nil {
 				return fmt.Errorf("failed to invalidate session %s: %w", sess.ID, err)
 			}
 		}
@@ -117,6 +238,93 @@ func (s *SessionService) InvalidateUserSessions(ctx context.Context, userID uuid
 	return nil
 }
 
+// RevokeSession signs one of the user's sessions out. It returns
+// session.ErrSessionNotFound if the session does not belong to the user.
+func (s *SessionService) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID string) error {
+	sess, err := s.repo.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			return session.ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if sess.UserID != userID || !sess.IsActive || sess.IsExpired() {
+		return session.ErrSessionNotFound
+	}
+
+	if err := s.endSession(ctx, sess); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	s.log(ctx, &audit.CreateLogRequest{
+		EventType:   audit.EventTypeUserSessionRevoked,
+		Severity:    audit.SeverityInfo,
+		UserID:      &userID,
+		ActorID:     &userID,
+		ActorType:   audit.ActorTypeUser,
+		EntityType:  "session",
+		EntityID:    sess.ID,
+		SessionID:   sess.ID,
+		Action:      "revoke",
+		Description: "Session revoked",
+		IPAddress:   sess.IPAddress,
+		UserAgent:   sess.UserAgent,
+	})
+
+	return nil
+}
+
+// RevokeOtherSessions signs the user out everywhere except the session bound to
+// the given refresh token family, and returns how many sessions were ended
+func (s *SessionService) RevokeOtherSessions(ctx context.Context, userID uuid.UUID, currentFamilyID string) (int, error) {
+	sessions, err := s.GetUserSessions(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	revoked := 0
+	for _, sess := range sessions {
+		if currentFamilyID != "" && sess.TokenID == currentFamilyID {
+			continue
+		}
+		if err := s.endSession(ctx, sess); err != nil {
+			return revoked, fmt.Errorf("failed to revoke session %s: %w", sess.ID, err)
+		}
+		revoked++
+	}
+
+	if revoked > 0 {
+		s.log(ctx, &audit.CreateLogRequest{
+			EventType:   audit.EventTypeUserSessionRevoked,
+			Severity:    audit.SeverityInfo,
+			UserID:      &userID,
+			ActorID:     &userID,
+			ActorType:   audit.ActorTypeUser,
+			EntityType:  "user",
+			EntityID:    userID.String(),
+			Action:      "revoke_others",
+			Description: fmt.Sprintf("Signed out of %d other sessions", revoked),
+		})
+	}
+
+	return revoked, nil
+}
+
+// endSession revokes the session's refresh token family, which also rejects the
+// access tokens issued from it, then deactivates the session
+func (s *SessionService) endSession(ctx context.Context, sess *session.Session) error {
+	if s.tokenService != nil && sess.TokenID != "" {
+		// A family that has already expired has no tokens left to revoke
+		if err := s.tokenService.RevokeFamily(ctx, sess.TokenID); err != nil && !errors.Is(err, auth.ErrTokenFamilyNotFound) {
+			return fmt.Errorf("failed to revoke session tokens: %w", err)
+		}
+	}
+
+	sess.Deactivate()
+	return s.repo.Update(ctx, sess)
+}
+
 // DeleteSession permanently removes a session
 func (s *SessionService) DeleteSession(ctx context.Context, sessionID string) error {
 	return s.repo.Delete(ctx, sessionID)
@@ -145,6 +353,25 @@ func (s *SessionService) ValidateSession(ctx context.Context, sessionID string)
 	return sess, nil
 }
 
+func (s *SessionService) locate(ip string) *risk.Location {
+	if s.locator == nil || ip == "" {
+		return nil
+	}
+
+	location, err := s.locator.Locate(ip)
+	if err != nil {
+		return nil
+	}
+	return location
+}
+
+func (s *SessionService) log(ctx context.Context, req *audit.CreateLogRequest) {
+	if s.auditService == nil {
+		return
+	}
+	_, _ = s.auditService.Log(ctx, req)
+}
+
 func generateSessionID() (string, error) {
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
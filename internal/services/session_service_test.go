@@ -8,6 +8,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/session"
+	"github.com/victoralfred/um_sys/internal/domain/user"
 	"github.com/victoralfred/um_sys/internal/infrastructure/redis"
 	"github.com/victoralfred/um_sys/internal/services"
 )
@@ -205,3 +208,116 @@ func setupSessionService(t *testing.T) *services.SessionService {
 	require.NotNil(t, repo)
 	return services.NewSessionService(repo)
 }
+
+func TestSessionService_Revocation(t *testing.T) {
+	ctx := context.Background()
+	testUser := &user.User{
+		ID:       uuid.New(),
+		Email:    "test@example.com",
+		Username: "testuser",
+		Status:   user.StatusActive,
+	}
+
+	setup := func(t *testing.T) (*services.SessionService, *services.TokenService) {
+		tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		tokenService.SetTokenStore(NewInMemoryTokenStore())
+
+		sessionService := services.NewSessionService(NewInMemorySessionRepository())
+		sessionService.SetTokenService(tokenService)
+		sessionService.SetLocator(riskLocations())
+		tokenService.SetSessionService(sessionService)
+
+		return sessionService, tokenService
+	}
+
+	signIn := func(t *testing.T, sessionService *services.SessionService, tokenService *services.TokenService, ip string) (*auth.TokenPair, *session.Session) {
+		t.Helper()
+
+		tokenPair, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+
+		sess, err := sessionService.CreateSession(ctx, testUser.ID, tokenPair.FamilyID, ip, laptopChrome, time.Hour)
+		require.NoError(t, err)
+		require.NoError(t, tokenService.BindSession(ctx, tokenPair.FamilyID, sess.ID))
+
+		return tokenPair, sess
+	}
+
+	t.Run("records where sessions were started", func(t *testing.T) {
+		sessionService, tokenService := setup(t)
+
+		_, sess := signIn(t, sessionService, tokenService, londonHome)
+		require.NotNil(t, sess.Location)
+		assert.Equal(t, "London", sess.Location.City)
+
+		_, sess = signIn(t, sessionService, tokenService, "192.0.2.1")
+		assert.Nil(t, sess.Location)
+	})
+
+	t.Run("revoking a session revokes its tokens", func(t *testing.T) {
+		sessionService, tokenService := setup(t)
+
+		revokedPair, revoked := signIn(t, sessionService, tokenService, londonHome)
+		keptPair, kept := signIn(t, sessionService, tokenService, sydney)
+
+		require.NoError(t, sessionService.RevokeSession(ctx, testUser.ID, revoked.ID))
+
+		_, err := tokenService.ValidateToken(ctx, revokedPair.AccessToken, auth.AccessToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+		_, err = tokenService.RefreshTokens(ctx, revokedPair.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+
+		_, err = tokenService.ValidateToken(ctx, keptPair.AccessToken, auth.AccessToken)
+		assert.NoError(t, err)
+
+		sessions, err := sessionService.GetUserSessions(ctx, testUser.ID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, kept.ID, sessions[0].ID)
+
+		// A session can only be revoked once, and only by its user
+		assert.ErrorIs(t, sessionService.RevokeSession(ctx, testUser.ID, revoked.ID), session.ErrSessionNotFound)
+		assert.ErrorIs(t, sessionService.RevokeSession(ctx, uuid.New(), kept.ID), session.ErrSessionNotFound)
+		assert.ErrorIs(t, sessionService.RevokeSession(ctx, testUser.ID, "unknown"), session.ErrSessionNotFound)
+	})
+
+	t.Run("signs out everywhere else", func(t *testing.T) {
+		sessionService, tokenService := setup(t)
+
+		currentPair, current := signIn(t, sessionService, tokenService, londonHome)
+		otherPair, _ := signIn(t, sessionService, tokenService, sydney)
+		_, _ = signIn(t, sessionService, tokenService, manchester)
+
+		revoked, err := sessionService.RevokeOtherSessions(ctx, testUser.ID, currentPair.FamilyID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, revoked)
+
+		sessions, err := sessionService.GetUserSessions(ctx, testUser.ID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, current.ID, sessions[0].ID)
+
+		_, err = tokenService.ValidateToken(ctx, currentPair.AccessToken, auth.AccessToken)
+		assert.NoError(t, err)
+		_, err = tokenService.ValidateToken(ctx, otherPair.AccessToken, auth.AccessToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+	})
+
+	t.Run("refreshing tokens records activity", func(t *testing.T) {
+		sessionService, tokenService := setup(t)
+
+		tokenPair, sess := signIn(t, sessionService, tokenService, londonHome)
+		time.Sleep(10 * time.Millisecond)
+
+		_, err := tokenService.RefreshTokens(ctx, tokenPair.RefreshToken)
+		require.NoError(t, err)
+
+		sessions, err := sessionService.GetUserSessions(ctx, testUser.ID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.True(t, sessions[0].LastActivity.After(sess.LastActivity))
+
+		// The session lives as long as the refresh token that keeps it going
+		assert.True(t, sessions[0].ExpiresAt.After(sess.ExpiresAt))
+	})
+}
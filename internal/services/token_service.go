@@ -2,16 +2,31 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 
+	"github.com/victoralfred/um_sys/internal/domain/audit"
 	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/organization"
+	"github.com/victoralfred/um_sys/internal/domain/rbac"
+	"github.com/victoralfred/um_sys/internal/domain/serviceaccount"
+	"github.com/victoralfred/um_sys/internal/domain/session"
 	"github.com/victoralfred/um_sys/internal/domain/user"
 )
 
+// RoleProvider returns the names of the RBAC roles currently granted to a user
+type RoleProvider interface {
+	UserRoleNames(ctx context.Context, userID uuid.UUID) ([]string, error)
+}
+
 // TokenService implements JWT token operations
 type TokenService struct {
 	secretKey          []byte
@@ -20,6 +35,13 @@ type TokenService struct {
 	refreshTokenExpiry time.Duration
 	userRepo           user.Repository
 	tokenStore         auth.TokenStore
+	revocations        *revocationCache
+	keyRing            *KeyRing
+	sessionService     *SessionService
+	sessionPolicies    *SessionPolicies
+	auditService       audit.AuditService
+	memberships        organization.MembershipRepository
+	roles              RoleProvider
 }
 
 // NewTokenService creates a new token service
@@ -39,81 +61,459 @@ func NewTokenService(
 	}
 }
 
+// SetSessionPolicies applies idle timeouts, sliding expiry and maximum
+// lifetimes to the tokens of first-party sign-ins
+func (s *TokenService) SetSessionPolicies(policies *SessionPolicies) {
+	s.sessionPolicies = policies
+}
+
 // SetTokenStore sets the token store for blacklisting
 func (s *TokenService) SetTokenStore(store auth.TokenStore) {
 	s.tokenStore = store
 }
 
-// GenerateTokenPair generates access and refresh tokens for a user
+// SetRevocationCacheTTL lets an access token that was found unrevoked skip the
+// token store for ttl. Revocations made through this instance apply at once;
+// those made by other instances apply within ttl. Use it when the token store
+// is too slow to query on every request.
+func (s *TokenService) SetRevocationCacheTTL(ttl time.Duration) {
+	s.revocations = newRevocationCache(ttl)
+}
+
+// SetSessionService sets the session service used to end the session
+// linked to a refresh token family when token reuse is detected
+func (s *TokenService) SetSessionService(sessionService *SessionService) {
+	s.sessionService = sessionService
+}
+
+// SetAuditService sets the audit service used to record token reuse alerts
+func (s *TokenService) SetAuditService(auditService audit.AuditService) {
+	s.auditService = auditService
+}
+
+// SetOrganizationMemberships enables organization claims, looked up from the
+// caller's membership of their family's active organization whenever tokens are issued
+func (s *TokenService) SetOrganizationMemberships(memberships organization.MembershipRepository) {
+	s.memberships = memberships
+}
+
+// RefreshTokenExpiry returns the lifetime of issued refresh tokens
+func (s *TokenService) RefreshTokenExpiry() time.Duration {
+	return s.refreshTokenExpiry
+}
+
+// SetRoleProvider puts the roles granted to each user in their tokens
+func (s *TokenService) SetRoleProvider(roles RoleProvider) {
+	s.roles = roles
+}
+
+// SetKeyRing switches token signing from the shared HMAC secret to the
+// asymmetric keys held by the key ring
+func (s *TokenService) SetKeyRing(keyRing *KeyRing) {
+	s.keyRing = keyRing
+}
+
+// JWKS returns the public keys that verify tokens issued by this service.
+// The set is empty when tokens are signed with the shared HMAC secret.
+func (s *TokenService) JWKS() *auth.JWKS {
+	if s.keyRing == nil {
+		return &auth.JWKS{Keys: []auth.JWK{}}
+	}
+	return s.keyRing.JWKS()
+}
+
+// GenerateTokenPair generates access and refresh tokens for a user.
+// Each call starts a new refresh token family.
 func (s *TokenService) GenerateTokenPair(ctx context.Context, u *user.User) (*auth.TokenPair, error) {
+	return s.startFamily(ctx, u, &auth.TokenFamily{})
+}
+
+// GenerateClientTokenPair generates tokens issued to an OAuth client with the granted scopes.
+// The client and scopes carry over to every token rotated from the pair.
+func (s *TokenService) GenerateClientTokenPair(ctx context.Context, u *user.User, clientID string, scopes []string) (*auth.TokenPair, error) {
+	return s.startFamily(ctx, u, &auth.TokenFamily{
+		ClientID: clientID,
+		Scopes:   scopes,
+	})
+}
+
+// GenerateServiceAccountToken issues an access token to a service account.
+// No refresh token is issued; the account authenticates again when the token expires.
+func (s *TokenService) GenerateServiceAccountToken(account *serviceaccount.ServiceAccount) (*auth.TokenPair, error) {
+	now := time.Now()
+
+	claims := &auth.Claims{
+		UserID:        account.ID,
+		Username:      account.Name,
+		Roles:         account.Roles,
+		TokenType:     auth.AccessToken,
+		PrincipalType: auth.PrincipalServiceAccount,
+		ExpiresAt:     now.Add(s.accessTokenExpiry),
+		IssuedAt:      now,
+		NotBefore:     now,
+		Subject:       account.ID.String(),
+		Issuer:        s.issuer,
+		Audience:      []string{s.issuer},
+		JTI:           uuid.New().String(),
+		ClientID:      account.ClientID,
+	}
+
+	accessToken, err := s.generateToken(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	return &auth.TokenPair{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.accessTokenExpiry.Seconds()),
+		ExpiresAt:   claims.ExpiresAt,
+	}, nil
+}
+
+// GenerateImpersonationToken issues an access token that lets an admin act as
+// another user. It carries the target as the subject and the admin as the actor,
+// and no refresh token is issued, so impersonation ends when the token expires.
+func (s *TokenService) GenerateImpersonationToken(ctx context.Context, target *user.User, actorID uuid.UUID, ttl time.Duration) (*auth.TokenPair, *auth.Claims, error) {
+	roles, err := s.RolesFor(ctx, target)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	now := time.Now()
 
+	claims := &auth.Claims{
+		UserID:        target.ID,
+		Email:         target.Email,
+		Username:      target.Username,
+		Roles:         roles,
+		TokenType:     auth.AccessToken,
+		PrincipalType: auth.PrincipalUser,
+		EmailVerified: target.EmailVerified,
+		ExpiresAt:     now.Add(ttl),
+		IssuedAt:      now,
+		NotBefore:     now,
+		Subject:       target.ID.String(),
+		Issuer:        s.issuer,
+		Audience:      []string{s.issuer},
+		JTI:           uuid.New().String(),
+		ActorID:       &actorID,
+	}
+
+	accessToken, err := s.generateToken(claims)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate impersonation token: %w", err)
+	}
+
+	return &auth.TokenPair{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(ttl.Seconds()),
+		ExpiresAt:   claims.ExpiresAt,
+	}, claims, nil
+}
+
+// startFamily issues the first token pair of a new refresh token family
+func (s *TokenService) startFamily(ctx context.Context, u *user.User, family *auth.TokenFamily) (*auth.TokenPair, error) {
+	family.ID = uuid.New().String()
+	family.UserID = u.ID
+
+	membership, err := s.activeMembership(ctx, family)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.RolesFor(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenPair, refreshClaims, err := s.issueTokenPair(u, family, roles, s.policyFor(ctx, u, family, roles), membership)
+	if err != nil {
+		return nil, err
+	}
+
+	// Persist the family so that rotated refresh tokens can be detected on reuse
+	if s.tokenStore != nil {
+		family.CurrentJTI = refreshClaims.JTI
+		family.CreatedAt = refreshClaims.IssuedAt
+		family.ExpiresAt = refreshClaims.ExpiresAt
+		if err := s.tokenStore.SaveFamily(ctx, family); err != nil {
+			return nil, fmt.Errorf("failed to store token family: %w", err)
+		}
+	}
+
+	return tokenPair, nil
+}
+
+// BindSession links a refresh token family to the session it was issued for
+func (s *TokenService) BindSession(ctx context.Context, familyID, sessionID string) error {
+	if s.tokenStore == nil {
+		return nil
+	}
+
+	family, err := s.tokenStore.GetFamily(ctx, familyID)
+	if err != nil {
+		return err
+	}
+
+	family.SessionID = sessionID
+	return s.tokenStore.SaveFamily(ctx, family)
+}
+
+// SwitchOrganization issues a token pair acting in the membership's organization,
+// or in the user's personal account when membership is nil. The pair continues
+// the caller's refresh token family, so the session is kept and the family's
+// previous refresh token stops working.
+func (s *TokenService) SwitchOrganization(ctx context.Context, u *user.User, familyID string, membership *organization.Membership) (*auth.TokenPair, error) {
+	var organizationID *uuid.UUID
+	if membership != nil {
+		organizationID = &membership.OrganizationID
+	}
+
+	// Switching continues a session; callers without one, such as API keys, get no tokens
+	if familyID == "" {
+		return nil, auth.ErrInvalidToken
+	}
+
+	// Without a store there is no family to continue
+	if s.tokenStore == nil {
+		return s.startFamily(ctx, u, &auth.TokenFamily{OrganizationID: organizationID})
+	}
+
+	family, err := s.tokenStore.GetFamily(ctx, familyID)
+	if err != nil {
+		if errors.Is(err, auth.ErrTokenFamilyNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get token family: %w", err)
+	}
+	if family.UserID != u.ID || family.IsRevoked() {
+		return nil, auth.ErrInvalidToken
+	}
+
+	roles, err := s.RolesFor(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := s.policyFor(ctx, u, family, roles)
+	if err := s.checkSessionPolicy(ctx, family, policy); err != nil {
+		return nil, err
+	}
+
+	family.OrganizationID = organizationID
+	tokenPair, refreshClaims, err := s.issueTokenPair(u, family, roles, policy, membership)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.tokenStore.RotateFamily(ctx, family.ID, family.CurrentJTI, refreshClaims.JTI, refreshClaims.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	// Once rotated, only the refresh token just issued can change the family,
+	// so the organization can be saved without racing a refresh
+	rotated, err := s.tokenStore.GetFamily(ctx, family.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token family: %w", err)
+	}
+	rotated.OrganizationID = organizationID
+	if err := s.tokenStore.SaveFamily(ctx, rotated); err != nil {
+		return nil, fmt.Errorf("failed to store token family: %w", err)
+	}
+
+	return tokenPair, nil
+}
+
+// RevokeFamily revokes every token issued from a refresh token family
+func (s *TokenService) RevokeFamily(ctx context.Context, familyID string) error {
+	if s.tokenStore == nil {
+		return nil
+	}
+
+	s.revocations.clear()
+	return s.tokenStore.RevokeFamily(ctx, familyID)
+}
+
+// RevokeAllForUser revokes every token issued to a user, signing them out everywhere
+func (s *TokenService) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	if s.tokenStore == nil {
+		return nil
+	}
+
+	s.revocations.clear()
+	return s.tokenStore.RevokeUserFamilies(ctx, userID)
+}
+
+// RolesFor returns the roles carried in a user's access tokens: the user role
+// every account holds, and the roles granted to the user through RBAC
+func (s *TokenService) RolesFor(ctx context.Context, u *user.User) ([]string, error) {
+	roles := []string{rbac.RoleUser}
+	if s.roles == nil {
+		return roles, nil
+	}
+
+	granted, err := s.roles.UserRoleNames(ctx, u.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+
+	for _, role := range granted {
+		if role != rbac.RoleUser {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles, nil
+}
+
+// policyFor returns the session policy for a family's tokens. Tokens issued to
+// OAuth clients are not sessions and have no policy.
+func (s *TokenService) policyFor(ctx context.Context, u *user.User, family *auth.TokenFamily, roles []string) session.Policy {
+	if s.sessionPolicies == nil || family.ClientID != "" {
+		return session.Policy{}
+	}
+	return s.sessionPolicies.PolicyFor(ctx, u.ID, roles)
+}
+
+// activeMembership returns the user's membership of the family's active
+// organization. Members who have since left get tokens for their personal account.
+func (s *TokenService) activeMembership(ctx context.Context, family *auth.TokenFamily) (*organization.Membership, error) {
+	if s.memberships == nil || family.OrganizationID == nil {
+		return nil, nil
+	}
+
+	membership, err := s.memberships.Get(ctx, *family.OrganizationID, family.UserID)
+	if err != nil {
+		if errors.Is(err, organization.ErrNotMember) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get organization membership: %w", err)
+	}
+
+	return membership, nil
+}
+
+// issueTokenPair signs a new access and refresh token belonging to the given
+// family, acting in the membership's organization when one is given
+func (s *TokenService) issueTokenPair(u *user.User, family *auth.TokenFamily, roles []string, policy session.Policy, membership *organization.Membership) (*auth.TokenPair, *auth.Claims, error) {
+	now := time.Now()
+	accessExpiry := now.Add(s.accessTokenExpiry)
+	refreshExpiry := now.Add(s.refreshTokenExpiry)
+
+	// The refresh token slides forward on every refresh, but never past the
+	// session's maximum lifetime; the session ends when it expires
+	var sessionExpiresAt time.Time
+	if policy.SlidingExpiry > 0 || policy.MaxLifetime > 0 {
+		if policy.SlidingExpiry > 0 {
+			refreshExpiry = now.Add(policy.SlidingExpiry)
+		}
+		if policy.MaxLifetime > 0 {
+			started := family.CreatedAt
+			if started.IsZero() {
+				started = now
+			}
+			refreshExpiry = earliest(refreshExpiry, started.Add(policy.MaxLifetime))
+		}
+		// Middleware rejects the access token once the session has ended
+		sessionExpiresAt = refreshExpiry
+	}
+
+	// Refreshes are then due at least twice per idle timeout, so a session in
+	// use is never mistaken for an idle one when its tokens are refreshed
+	if policy.IdleTimeout > 0 {
+		accessExpiry = earliest(accessExpiry, now.Add(policy.IdleTimeout/2))
+	}
+
 	// Generate JTI (JWT ID) for token revocation
 	accessTokenID := uuid.New().String()
 	refreshTokenID := uuid.New().String()
 
+	// Tokens issued to an OAuth client are meant for that client, not for this API
+	audience := []string{s.issuer}
+	if family.ClientID != "" {
+		audience = []string{family.ClientID}
+	}
+
 	// Create access token claims
 	accessClaims := &auth.Claims{
-		UserID:    u.ID,
-		Email:     u.Email,
-		Username:  u.Username,
-		Roles:     []string{"user"}, // TODO: Load actual roles from user
-		TokenType: auth.AccessToken,
-		ExpiresAt: now.Add(s.accessTokenExpiry),
-		IssuedAt:  now,
-		NotBefore: now,
-		Subject:   u.ID.String(),
-		Issuer:    s.issuer,
-		Audience:  []string{s.issuer},
-		JTI:       accessTokenID,
+		UserID:        u.ID,
+		Email:         u.Email,
+		Username:      u.Username,
+		Roles:         roles,
+		TokenType:     auth.AccessToken,
+		PrincipalType: auth.PrincipalUser,
+		EmailVerified: u.EmailVerified,
+		ExpiresAt:     accessExpiry,
+		IssuedAt:      now,
+		NotBefore:     now,
+		Subject:       u.ID.String(),
+		Issuer:        s.issuer,
+		Audience:      audience,
+		JTI:           accessTokenID,
+		FamilyID:      family.ID,
+		ClientID:      family.ClientID,
+		Scopes:        family.Scopes,
+
+		SessionExpiresAt: sessionExpiresAt,
+		IdleTimeout:      policy.IdleTimeout,
 	}
 
 	// Create refresh token claims
 	refreshClaims := &auth.Claims{
-		UserID:    u.ID,
-		Email:     u.Email,
-		Username:  u.Username,
-		TokenType: auth.RefreshToken,
-		ExpiresAt: now.Add(s.refreshTokenExpiry),
-		IssuedAt:  now,
-		NotBefore: now,
-		Subject:   u.ID.String(),
-		Issuer:    s.issuer,
-		Audience:  []string{s.issuer},
-		JTI:       refreshTokenID,
+		UserID:        u.ID,
+		Email:         u.Email,
+		Username:      u.Username,
+		TokenType:     auth.RefreshToken,
+		PrincipalType: auth.PrincipalUser,
+		ExpiresAt:     refreshExpiry,
+		IssuedAt:      now,
+		NotBefore:     now,
+		Subject:       u.ID.String(),
+		Issuer:        s.issuer,
+		Audience:      audience,
+		JTI:           refreshTokenID,
+		FamilyID:      family.ID,
+		ClientID:      family.ClientID,
+		Scopes:        family.Scopes,
+	}
+
+	if membership != nil {
+		organizationID := membership.OrganizationID
+		for _, claims := range []*auth.Claims{accessClaims, refreshClaims} {
+			claims.OrganizationID = &organizationID
+			claims.OrganizationRole = string(membership.Role)
+		}
 	}
 
 	// Generate access token
 	accessToken, err := s.generateToken(accessClaims)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
 	// Generate refresh token
 	refreshToken, err := s.generateToken(refreshClaims)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
 	return &auth.TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    int(s.accessTokenExpiry.Seconds()),
-		ExpiresAt:    accessClaims.ExpiresAt,
-	}, nil
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(accessExpiry.Sub(now).Seconds()),
+		ExpiresAt:        accessClaims.ExpiresAt,
+		FamilyID:         family.ID,
+		RefreshExpiresAt: refreshExpiry,
+	}, refreshClaims, nil
 }
 
 // ValidateToken validates a token and returns the claims
 func (s *TokenService) ValidateToken(ctx context.Context, tokenString string, tokenType auth.TokenType) (*auth.Claims, error) {
 	// Parse the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Check signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return s.secretKey, nil
-	})
+	token, err := jwt.Parse(tokenString, s.keyFunc)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -136,26 +536,57 @@ func (s *TokenService) ValidateToken(ctx context.Context, tokenString string, to
 		return nil, fmt.Errorf("invalid token type: expected %s, got %s", tokenType, claims.TokenType)
 	}
 
-	// Check if token is revoked (if token store is available)
-	if s.tokenStore != nil {
-		revoked, err := s.IsTokenRevoked(ctx, claims.JTI)
-		if err != nil {
-			return nil, fmt.Errorf("failed to check token revocation: %w", err)
+	// Check if token is revoked (if token store is available); access tokens
+	// recently found unrevoked are not looked up again
+	now := time.Now()
+	if tokenType != auth.AccessToken || !s.revocations.unrevoked(claims.JTI, now) {
+		if err := s.CheckRevocation(ctx, claims); err != nil {
+			return nil, err
 		}
-		if revoked {
-			return nil, auth.ErrTokenRevoked
+		if tokenType == auth.AccessToken {
+			s.revocations.remember(claims.JTI, now)
 		}
 	}
 
 	// Check expiration
-	if time.Now().After(claims.ExpiresAt) {
+	if now.After(claims.ExpiresAt) {
 		return nil, auth.ErrTokenExpired
 	}
 
 	return claims, nil
 }
 
-// RefreshTokens generates new token pair from refresh token
+// CheckRevocation returns ErrTokenRevoked if the token or its refresh token family has been revoked
+func (s *TokenService) CheckRevocation(ctx context.Context, claims *auth.Claims) error {
+	if s.tokenStore == nil {
+		return nil
+	}
+
+	revoked, err := s.IsTokenRevoked(ctx, claims.JTI)
+	if err != nil {
+		return fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return auth.ErrTokenRevoked
+	}
+
+	// Tokens from a revoked family are rejected even if their own JTI is not blacklisted
+	if claims.FamilyID != "" {
+		family, err := s.tokenStore.GetFamily(ctx, claims.FamilyID)
+		if err != nil && !errors.Is(err, auth.ErrTokenFamilyNotFound) {
+			return fmt.Errorf("failed to check token family: %w", err)
+		}
+		if family != nil && family.IsRevoked() {
+			return auth.ErrTokenRevoked
+		}
+	}
+
+	return nil
+}
+
+// RefreshTokens generates new token pair from refresh token.
+// The refresh token is rotated within its family; presenting a refresh token
+// that has already been rotated revokes the whole family.
 func (s *TokenService) RefreshTokens(ctx context.Context, refreshToken string) (*auth.TokenPair, error) {
 	// Validate refresh token
 	claims, err := s.ValidateToken(ctx, refreshToken, auth.RefreshToken)
@@ -164,6 +595,7 @@ func (s *TokenService) RefreshTokens(ctx context.Context, refreshToken string) (
 	}
 
 	// Get fresh user data (in case roles or status changed)
+	var u *user.User
 	if s.userRepo != nil {
 		freshUser, err := s.userRepo.GetByID(ctx, claims.UserID)
 		if err != nil {
@@ -175,19 +607,145 @@ func (s *TokenService) RefreshTokens(ctx context.Context, refreshToken string) (
 			return nil, auth.ErrAccountInactive
 		}
 
-		// Generate new token pair
-		return s.GenerateTokenPair(ctx, freshUser)
+		u = freshUser
+	} else {
+		// If no user repo, generate with existing claims (less secure)
+		u = &user.User{
+			ID:       claims.UserID,
+			Email:    claims.Email,
+			Username: claims.Username,
+			Status:   user.StatusActive,
+		}
+	}
+
+	// Without a store there is no family state to rotate, and tokens issued
+	// before families existed start a new one
+	if s.tokenStore == nil || claims.FamilyID == "" {
+		return s.startFamily(ctx, u, &auth.TokenFamily{
+			ClientID:       claims.ClientID,
+			Scopes:         claims.Scopes,
+			OrganizationID: claims.OrganizationID,
+		})
+	}
+
+	return s.rotateRefreshToken(ctx, claims, u)
+}
+
+// rotateRefreshToken issues a new pair in the presented token's family and
+// makes the new refresh token the only one the family accepts
+func (s *TokenService) rotateRefreshToken(ctx context.Context, claims *auth.Claims, u *user.User) (*auth.TokenPair, error) {
+	family, err := s.tokenStore.GetFamily(ctx, claims.FamilyID)
+	if err != nil {
+		if errors.Is(err, auth.ErrTokenFamilyNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get token family: %w", err)
+	}
+
+	if family.CurrentJTI != claims.JTI {
+		return nil, s.handleRefreshTokenReuse(ctx, family, claims)
+	}
+
+	roles, err := s.RolesFor(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := s.policyFor(ctx, u, family, roles)
+	if err := s.checkSessionPolicy(ctx, family, policy); err != nil {
+		return nil, err
+	}
+
+	membership, err := s.activeMembership(ctx, family)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenPair, refreshClaims, err := s.issueTokenPair(u, family, roles, policy, membership)
+	if err != nil {
+		return nil, err
+	}
+
+	// The compare-and-swap guards against two concurrent redemptions of the same token
+	err = s.tokenStore.RotateFamily(ctx, family.ID, claims.JTI, refreshClaims.JTI, refreshClaims.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, auth.ErrRefreshTokenReused) {
+			return nil, s.handleRefreshTokenReuse(ctx, family, claims)
+		}
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	if s.sessionService != nil && family.SessionID != "" {
+		// Activity tracking is best effort and must not fail the refresh
+		_ = s.sessionService.TouchSession(ctx, family.SessionID, refreshClaims.ExpiresAt)
 	}
 
-	// If no user repo, generate with existing claims (less secure)
-	u := &user.User{
-		ID:       claims.UserID,
-		Email:    claims.Email,
-		Username: claims.Username,
-		Status:   user.StatusActive,
+	return tokenPair, nil
+}
+
+// checkSessionPolicy ends the family's session if it has been idle, or alive,
+// for longer than the policy allows. Policies are checked again on refresh so
+// that a tightened policy applies to sessions already started.
+func (s *TokenService) checkSessionPolicy(ctx context.Context, family *auth.TokenFamily, policy session.Policy) error {
+	// The family is rotated on every refresh, so its rotation time is the last
+	// time the session's tokens were used to get new ones
+	lastUsed := family.CreatedAt
+	if family.RotatedAt != nil {
+		lastUsed = *family.RotatedAt
+	}
+
+	var err error
+	switch {
+	case policy.IdleTimeout > 0 && time.Since(lastUsed) > policy.IdleTimeout:
+		err = session.ErrSessionIdle
+	case policy.MaxLifetime > 0 && time.Since(family.CreatedAt) > policy.MaxLifetime:
+		err = session.ErrSessionExpired
+	default:
+		return nil
+	}
+
+	if revokeErr := s.RevokeFamily(ctx, family.ID); revokeErr != nil {
+		return fmt.Errorf("failed to revoke token family: %w", revokeErr)
+	}
+	if s.sessionService != nil && family.SessionID != "" {
+		// The family is already revoked, so a failure here leaves no usable tokens behind
+		_ = s.sessionService.InvalidateSession(ctx, family.SessionID)
 	}
 
-	return s.GenerateTokenPair(ctx, u)
+	return err
+}
+
+// handleRefreshTokenReuse revokes a family whose rotated refresh token was
+// presented again, ends the linked session and raises a security alert
+func (s *TokenService) handleRefreshTokenReuse(ctx context.Context, family *auth.TokenFamily, claims *auth.Claims) error {
+	if err := s.RevokeFamily(ctx, family.ID); err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+
+	if s.sessionService != nil && family.SessionID != "" {
+		// The family is already revoked, so a failure here leaves no usable tokens behind
+		_ = s.sessionService.InvalidateSession(ctx, family.SessionID)
+	}
+
+	if s.auditService != nil {
+		userID := family.UserID
+		_, _ = s.auditService.Log(ctx, &audit.CreateLogRequest{
+			EventType:   audit.EventTypeSecurityAlert,
+			Severity:    audit.SeverityCritical,
+			UserID:      &userID,
+			EntityType:  "token_family",
+			EntityID:    family.ID,
+			Action:      "refresh_token_reuse",
+			Description: "Rotated refresh token was reused; token family revoked",
+			SessionID:   family.SessionID,
+			Metadata: map[string]interface{}{
+				"token_id":  claims.JTI,
+				"issued_at": claims.IssuedAt,
+			},
+		})
+	}
+
+	return auth.ErrRefreshTokenReused
 }
 
 // RevokeToken revokes a token (adds to blacklist)
@@ -203,6 +761,7 @@ func (s *TokenService) RevokeToken(ctx context.Context, tokenID string) error {
 		maxExpiry = s.refreshTokenExpiry
 	}
 
+	s.revocations.forget(tokenID)
 	return s.tokenStore.Store(ctx, tokenID, uuid.Nil, time.Now().Add(maxExpiry))
 }
 
@@ -215,22 +774,138 @@ func (s *TokenService) IsTokenRevoked(ctx context.Context, tokenID string) (bool
 	return s.tokenStore.Exists(ctx, tokenID)
 }
 
+// GenerateIDToken signs an OpenID Connect ID token for a user
+func (s *TokenService) GenerateIDToken(u *user.User, params *auth.IDTokenParams) (string, error) {
+	now := time.Now()
+
+	issuer := params.Issuer
+	if issuer == "" {
+		issuer = s.issuer
+	}
+
+	jwtClaims := jwt.MapClaims{
+		"iss": issuer,
+		"sub": u.ID.String(),
+		"aud": params.ClientID,
+		"azp": params.ClientID,
+		"exp": now.Add(s.accessTokenExpiry).Unix(),
+		"iat": now.Unix(),
+	}
+	for name, value := range params.Claims {
+		if _, reserved := jwtClaims[name]; !reserved {
+			jwtClaims[name] = value
+		}
+	}
+	if !params.AuthTime.IsZero() {
+		jwtClaims["auth_time"] = params.AuthTime.Unix()
+	}
+	if params.Nonce != "" {
+		jwtClaims["nonce"] = params.Nonce
+	}
+
+	if params.AccessToken != "" {
+		atHash, err := s.accessTokenHash(params.AccessToken)
+		if err != nil {
+			return "", err
+		}
+		jwtClaims["at_hash"] = atHash
+	}
+
+	return s.signClaims(jwtClaims)
+}
+
+// accessTokenHash computes the at_hash claim: the left half of the access token's
+// hash, using the hash function of the ID token's signing algorithm
+func (s *TokenService) accessTokenHash(accessToken string) (string, error) {
+	algorithm := auth.SigningAlgorithmHS256
+	if s.keyRing != nil {
+		key, err := s.keyRing.ActiveKey()
+		if err != nil {
+			return "", fmt.Errorf("failed to get signing key: %w", err)
+		}
+		algorithm = key.Algorithm
+	}
+
+	var sum []byte
+	if algorithm == auth.SigningAlgorithmEdDSA {
+		// Ed25519 signs with SHA-512
+		digest := sha512.Sum512([]byte(accessToken))
+		sum = digest[:]
+	} else {
+		digest := sha256.Sum256([]byte(accessToken))
+		sum = digest[:]
+	}
+
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
+
 // generateToken generates a JWT token from claims
 func (s *TokenService) generateToken(claims *auth.Claims) (string, error) {
 	// Convert to JWT claims
 	jwtClaims := jwt.MapClaims{
-		"user_id":    claims.UserID.String(),
-		"email":      claims.Email,
-		"username":   claims.Username,
-		"roles":      claims.Roles,
-		"token_type": string(claims.TokenType),
-		"exp":        claims.ExpiresAt.Unix(),
-		"iat":        claims.IssuedAt.Unix(),
-		"nbf":        claims.NotBefore.Unix(),
-		"sub":        claims.Subject,
-		"iss":        claims.Issuer,
-		"aud":        claims.Audience,
-		"jti":        claims.JTI,
+		"user_id":        claims.UserID.String(),
+		"email":          claims.Email,
+		"username":       claims.Username,
+		"roles":          claims.Roles,
+		"token_type":     string(claims.TokenType),
+		"exp":            claims.ExpiresAt.Unix(),
+		"iat":            claims.IssuedAt.Unix(),
+		"nbf":            claims.NotBefore.Unix(),
+		"sub":            claims.Subject,
+		"iss":            claims.Issuer,
+		"aud":            claims.Audience,
+		"jti":            claims.JTI,
+		"email_verified": claims.EmailVerified,
+		"principal_type": string(claims.PrincipalType),
+	}
+	if claims.FamilyID != "" {
+		jwtClaims["fid"] = claims.FamilyID
+	}
+	if claims.ClientID != "" {
+		jwtClaims["azp"] = claims.ClientID
+		jwtClaims["scope"] = strings.Join(claims.Scopes, " ")
+	}
+	if !claims.SessionExpiresAt.IsZero() {
+		jwtClaims["sexp"] = claims.SessionExpiresAt.Unix()
+	}
+	if claims.IdleTimeout > 0 {
+		jwtClaims["idle"] = int64(claims.IdleTimeout.Seconds())
+	}
+	if claims.ActorID != nil {
+		// RFC 8693 actor claim
+		jwtClaims["act"] = map[string]interface{}{"sub": claims.ActorID.String()}
+	}
+	if claims.OrganizationID != nil {
+		jwtClaims["org_id"] = claims.OrganizationID.String()
+		jwtClaims["org_role"] = claims.OrganizationRole
+	}
+
+	return s.signClaims(jwtClaims)
+}
+
+// signClaims signs a set of JWT claims with the active key
+func (s *TokenService) signClaims(jwtClaims jwt.MapClaims) (string, error) {
+	// Sign with the key ring's active key when asymmetric signing is enabled
+	if s.keyRing != nil {
+		key, err := s.keyRing.ActiveKey()
+		if err != nil {
+			return "", fmt.Errorf("failed to get signing key: %w", err)
+		}
+
+		method, err := signingMethodFor(key.Algorithm)
+		if err != nil {
+			return "", err
+		}
+
+		token := jwt.NewWithClaims(method, jwtClaims)
+		token.Header["kid"] = key.ID
+
+		tokenString, err := token.SignedString(key.PrivateKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign token: %w", err)
+		}
+
+		return tokenString, nil
 	}
 
 	// Create token
@@ -245,6 +920,34 @@ func (s *TokenService) generateToken(claims *auth.Claims) (string, error) {
 	return tokenString, nil
 }
 
+// keyFunc resolves the verification key for a parsed token
+func (s *TokenService) keyFunc(token *jwt.Token) (interface{}, error) {
+	if s.keyRing != nil {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("missing kid header")
+		}
+
+		key, err := s.keyRing.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		// The algorithm is pinned by the key, never taken from the token header
+		if token.Method.Alg() != string(key.Algorithm) {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.PublicKey, nil
+	}
+
+	// Check signing method
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return s.secretKey, nil
+}
+
 // mapToClaims converts JWT MapClaims to our Claims struct
 func (s *TokenService) mapToClaims(m jwt.MapClaims) (*auth.Claims, error) {
 	// Parse user ID
@@ -299,40 +1002,101 @@ func (s *TokenService) mapToClaims(m jwt.MapClaims) (*auth.Claims, error) {
 	// Parse token type
 	tokenTypeStr, _ := m["token_type"].(string)
 
+	// Tokens issued before refresh token families existed carry no family ID
+	familyID, _ := m["fid"].(string)
+	emailVerified, _ := m["email_verified"].(bool)
+
+	// Only tokens issued to OAuth clients are scoped
+	clientID, _ := m["azp"].(string)
+	scope, _ := m["scope"].(string)
+
+	// Tokens issued before service accounts existed were always issued to users
+	principalType := auth.PrincipalUser
+	if p, ok := m["principal_type"].(string); ok && p != "" {
+		principalType = auth.PrincipalType(p)
+	}
+
+	// Only tokens of sessions under a policy carry its limits
+	var sessionExpiresAt time.Time
+	if sexp, ok := m["sexp"].(float64); ok {
+		sessionExpiresAt = time.Unix(int64(sexp), 0)
+	}
+	idle, _ := m["idle"].(float64)
+
+	// Only impersonation tokens name an actor
+	var actorID *uuid.UUID
+	if act, ok := m["act"].(map[string]interface{}); ok {
+		sub, _ := act["sub"].(string)
+		id, err := uuid.Parse(sub)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse act: %w", err)
+		}
+		actorID = &id
+	}
+
+	// Only tokens acting in an organization name one
+	var organizationID *uuid.UUID
+	if orgID, ok := m["org_id"].(string); ok && orgID != "" {
+		id, err := uuid.Parse(orgID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse org_id: %w", err)
+		}
+		organizationID = &id
+	}
+	organizationRole, _ := m["org_role"].(string)
+
 	return &auth.Claims{
-		UserID:    userID,
-		Email:     m["email"].(string),
-		Username:  m["username"].(string),
-		Roles:     roles,
-		TokenType: auth.TokenType(tokenTypeStr),
-		ExpiresAt: time.Unix(int64(exp), 0),
-		IssuedAt:  time.Unix(int64(iat), 0),
-		NotBefore: time.Unix(int64(nbf), 0),
-		Subject:   m["sub"].(string),
-		Issuer:    m["iss"].(string),
-		Audience:  audience,
-		JTI:       m["jti"].(string),
+		UserID:        userID,
+		Email:         m["email"].(string),
+		Username:      m["username"].(string),
+		Roles:         roles,
+		TokenType:     auth.TokenType(tokenTypeStr),
+		ExpiresAt:     time.Unix(int64(exp), 0),
+		IssuedAt:      time.Unix(int64(iat), 0),
+		NotBefore:     time.Unix(int64(nbf), 0),
+		Subject:       m["sub"].(string),
+		Issuer:        m["iss"].(string),
+		Audience:      audience,
+		JTI:           m["jti"].(string),
+		FamilyID:      familyID,
+		EmailVerified: emailVerified,
+		ClientID:      clientID,
+		Scopes:        strings.Fields(scope),
+		PrincipalType: principalType,
+
+		SessionExpiresAt: sessionExpiresAt,
+		IdleTimeout:      time.Duration(idle) * time.Second,
+		ActorID:          actorID,
+		OrganizationID:   organizationID,
+		OrganizationRole: organizationRole,
 	}, nil
 }
 
+// earliest returns the earlier of two times
+func earliest(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
 // RevokeRefreshToken revokes a refresh token by parsing it and revoking its JTI
 func (s *TokenService) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
 	// Parse the refresh token to get the JTI
-	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
-		// Check signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return s.secretKey, nil
-	})
+	token, err := jwt.Parse(refreshToken, s.keyFunc)
 
 	// If we can't parse the token, consider it already invalid
 	if err != nil {
 		return nil
 	}
 
-	// Extract claims and revoke the token
+	// Extract claims and revoke the token along with its family
 	if mapClaims, ok := token.Claims.(jwt.MapClaims); ok {
+		if fid, ok := mapClaims["fid"].(string); ok && fid != "" {
+			if err := s.RevokeFamily(ctx, fid); err != nil && !errors.Is(err, auth.ErrTokenFamilyNotFound) {
+				return err
+			}
+		}
 		if jti, ok := mapClaims["jti"].(string); ok && jti != "" {
 			return s.RevokeToken(ctx, jti)
 		}
@@ -7,8 +7,10 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/victoralfred/um_sys/internal/domain/audit"
 	"github.com/victoralfred/um_sys/internal/domain/auth"
 	"github.com/victoralfred/um_sys/internal/domain/user"
 	"github.com/victoralfred/um_sys/internal/services"
@@ -150,3 +152,358 @@ func TestTokenService_RefreshTokens(t *testing.T) {
 		assert.Nil(t, newTokenPair)
 	})
 }
+
+func TestTokenService_AsymmetricSigning(t *testing.T) {
+	ctx := context.Background()
+
+	testUser := &user.User{
+		ID:       uuid.New(),
+		Email:    "test@example.com",
+		Username: "testuser",
+		Status:   user.StatusActive,
+	}
+
+	for _, alg := range []auth.SigningAlgorithm{auth.SigningAlgorithmRS256, auth.SigningAlgorithmES256, auth.SigningAlgorithmEdDSA} {
+		t.Run(string(alg), func(t *testing.T) {
+			// Arrange
+			ring, err := services.NewKeyRing(alg, time.Hour)
+			require.NoError(t, err)
+
+			tokenService := services.NewTokenService("", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+			tokenService.SetKeyRing(ring)
+
+			// Act
+			tokenPair, err := tokenService.GenerateTokenPair(ctx, testUser)
+			require.NoError(t, err)
+
+			claims, err := tokenService.ValidateToken(ctx, tokenPair.AccessToken, auth.AccessToken)
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, testUser.ID, claims.UserID)
+		})
+	}
+
+	t.Run("tokens survive key rotation", func(t *testing.T) {
+		ring, err := services.NewKeyRing(auth.SigningAlgorithmES256, time.Hour)
+		require.NoError(t, err)
+
+		tokenService := services.NewTokenService("", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		tokenService.SetKeyRing(ring)
+
+		tokenPair, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+
+		_, err = ring.Rotate()
+		require.NoError(t, err)
+
+		_, err = tokenService.ValidateToken(ctx, tokenPair.AccessToken, auth.AccessToken)
+		assert.NoError(t, err)
+		assert.Len(t, tokenService.JWKS().Keys, 2)
+	})
+
+	t.Run("tokens survive replacing a provisioned key when the old key is kept as retiring", func(t *testing.T) {
+		oldKey, err := services.GenerateSigningKey(auth.SigningAlgorithmES256)
+		require.NoError(t, err)
+		oldRing, err := services.NewKeyRingFromKey(oldKey, nil, time.Hour)
+		require.NoError(t, err)
+
+		oldService := services.NewTokenService("", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		oldService.SetKeyRing(oldRing)
+		tokenPair, err := oldService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+
+		// A restart with a new key file, listing the old one as retiring
+		newKey, err := services.GenerateSigningKey(auth.SigningAlgorithmES256)
+		require.NoError(t, err)
+		newRing, err := services.NewKeyRingFromKey(newKey, []*auth.SigningKey{oldKey}, time.Hour)
+		require.NoError(t, err)
+
+		newService := services.NewTokenService("", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		newService.SetKeyRing(newRing)
+
+		_, err = newService.ValidateToken(ctx, tokenPair.AccessToken, auth.AccessToken)
+		assert.NoError(t, err)
+
+		active, err := newRing.ActiveKey()
+		require.NoError(t, err)
+		assert.Equal(t, newKey.ID, active.ID)
+		assert.Len(t, newService.JWKS().Keys, 2)
+
+		// Without the old key, its tokens no longer verify
+		replaced, err := services.NewKeyRingFromKey(newKey, nil, time.Hour)
+		require.NoError(t, err)
+		newService.SetKeyRing(replaced)
+		_, err = newService.ValidateToken(ctx, tokenPair.AccessToken, auth.AccessToken)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects HMAC tokens once asymmetric signing is enabled", func(t *testing.T) {
+		hmacService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		tokenPair, err := hmacService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+
+		ring, err := services.NewKeyRing(auth.SigningAlgorithmRS256, time.Hour)
+		require.NoError(t, err)
+		asymmetricService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		asymmetricService.SetKeyRing(ring)
+
+		claims, err := asymmetricService.ValidateToken(ctx, tokenPair.AccessToken, auth.AccessToken)
+		assert.Error(t, err)
+		assert.Nil(t, claims)
+	})
+
+	t.Run("HMAC service publishes no keys", func(t *testing.T) {
+		tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		assert.Empty(t, tokenService.JWKS().Keys)
+	})
+}
+
+func TestTokenService_RefreshTokenRotation(t *testing.T) {
+	ctx := context.Background()
+
+	testUser := &user.User{
+		ID:       uuid.New(),
+		Email:    "test@example.com",
+		Username: "testuser",
+		Status:   user.StatusActive,
+	}
+
+	newTokenService := func() (*services.TokenService, *InMemoryTokenStore) {
+		store := NewInMemoryTokenStore()
+		tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		tokenService.SetTokenStore(store)
+		return tokenService, store
+	}
+
+	t.Run("refresh rotates within the same family", func(t *testing.T) {
+		tokenService, store := newTokenService()
+
+		initial, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+		require.NotEmpty(t, initial.FamilyID)
+
+		rotated, err := tokenService.RefreshTokens(ctx, initial.RefreshToken)
+		require.NoError(t, err)
+		assert.Equal(t, initial.FamilyID, rotated.FamilyID)
+
+		claims, err := tokenService.ValidateToken(ctx, rotated.RefreshToken, auth.RefreshToken)
+		require.NoError(t, err)
+
+		family, err := store.GetFamily(ctx, initial.FamilyID)
+		require.NoError(t, err)
+		assert.Equal(t, claims.JTI, family.CurrentJTI)
+		assert.NotNil(t, family.RotatedAt)
+		assert.False(t, family.IsRevoked())
+
+		// The rotated token can itself be rotated
+		_, err = tokenService.RefreshTokens(ctx, rotated.RefreshToken)
+		require.NoError(t, err)
+	})
+
+	t.Run("reuse of a rotated token revokes the family, session and alerts", func(t *testing.T) {
+		tokenService, store := newTokenService()
+
+		sessionRepo := NewInMemorySessionRepository()
+		sessionService := services.NewSessionService(sessionRepo)
+		tokenService.SetSessionService(sessionService)
+
+		auditService := new(MockAuditService)
+		auditService.On("Log", ctx, mock.MatchedBy(func(req *audit.CreateLogRequest) bool {
+			return req.EventType == audit.EventTypeSecurityAlert &&
+				req.UserID != nil && *req.UserID == testUser.ID &&
+				req.Action == "refresh_token_reuse"
+		})).Return(&audit.LogEntry{}, nil).Once()
+		tokenService.SetAuditService(auditService)
+
+		initial, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+
+		sess, err := sessionService.CreateSession(ctx, testUser.ID, initial.FamilyID, "127.0.0.1", "test-agent", time.Hour)
+		require.NoError(t, err)
+		require.NoError(t, tokenService.BindSession(ctx, initial.FamilyID, sess.ID))
+
+		rotated, err := tokenService.RefreshTokens(ctx, initial.RefreshToken)
+		require.NoError(t, err)
+
+		// Replay the original refresh token
+		_, err = tokenService.RefreshTokens(ctx, initial.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrRefreshTokenReused)
+
+		family, err := store.GetFamily(ctx, initial.FamilyID)
+		require.NoError(t, err)
+		assert.True(t, family.IsRevoked())
+
+		// Every token from the family is now rejected, including the legitimate one
+		_, err = tokenService.RefreshTokens(ctx, rotated.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+		_, err = tokenService.ValidateToken(ctx, rotated.AccessToken, auth.AccessToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+
+		ended, err := sessionRepo.GetByID(ctx, sess.ID)
+		require.NoError(t, err)
+		assert.False(t, ended.IsActive)
+
+		auditService.AssertExpectations(t)
+	})
+
+	t.Run("other families are unaffected by reuse", func(t *testing.T) {
+		tokenService, _ := newTokenService()
+
+		first, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+		second, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+
+		_, err = tokenService.RefreshTokens(ctx, first.RefreshToken)
+		require.NoError(t, err)
+		_, err = tokenService.RefreshTokens(ctx, first.RefreshToken)
+		require.ErrorIs(t, err, auth.ErrRefreshTokenReused)
+
+		_, err = tokenService.ValidateToken(ctx, second.AccessToken, auth.AccessToken)
+		assert.NoError(t, err)
+		_, err = tokenService.RefreshTokens(ctx, second.RefreshToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("revoking a refresh token revokes its family", func(t *testing.T) {
+		tokenService, _ := newTokenService()
+
+		pair, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+
+		require.NoError(t, tokenService.RevokeRefreshToken(ctx, pair.RefreshToken))
+
+		_, err = tokenService.ValidateToken(ctx, pair.AccessToken, auth.AccessToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+	})
+}
+
+// countingTokenStore counts the lookups made to check token revocation
+type countingTokenStore struct {
+	*InMemoryTokenStore
+	lookups int
+}
+
+func (s *countingTokenStore) Exists(ctx context.Context, tokenID string) (bool, error) {
+	s.lookups++
+	return s.InMemoryTokenStore.Exists(ctx, tokenID)
+}
+
+func (s *countingTokenStore) GetFamily(ctx context.Context, familyID string) (*auth.TokenFamily, error) {
+	s.lookups++
+	return s.InMemoryTokenStore.GetFamily(ctx, familyID)
+}
+
+func TestTokenService_RevocationCache(t *testing.T) {
+	ctx := context.Background()
+
+	testUser := &user.User{
+		ID:       uuid.New(),
+		Email:    "test@example.com",
+		Username: "testuser",
+		Status:   user.StatusActive,
+	}
+
+	newTokenService := func() (*services.TokenService, *countingTokenStore) {
+		store := &countingTokenStore{InMemoryTokenStore: NewInMemoryTokenStore()}
+		tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		tokenService.SetTokenStore(store)
+		tokenService.SetRevocationCacheTTL(time.Minute)
+		return tokenService, store
+	}
+
+	t.Run("access tokens are looked up once", func(t *testing.T) {
+		tokenService, store := newTokenService()
+
+		pair, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+
+		_, err = tokenService.ValidateToken(ctx, pair.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		lookups := store.lookups
+
+		_, err = tokenService.ValidateToken(ctx, pair.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, lookups, store.lookups)
+	})
+
+	t.Run("refresh tokens are always looked up", func(t *testing.T) {
+		tokenService, store := newTokenService()
+
+		pair, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+
+		_, err = tokenService.ValidateToken(ctx, pair.RefreshToken, auth.RefreshToken)
+		require.NoError(t, err)
+		lookups := store.lookups
+
+		_, err = tokenService.ValidateToken(ctx, pair.RefreshToken, auth.RefreshToken)
+		require.NoError(t, err)
+		assert.Greater(t, store.lookups, lookups)
+	})
+
+	t.Run("revocations through the service apply at once", func(t *testing.T) {
+		tokenService, _ := newTokenService()
+
+		pair, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+		_, err = tokenService.ValidateToken(ctx, pair.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+
+		require.NoError(t, tokenService.RevokeFamily(ctx, pair.FamilyID))
+
+		_, err = tokenService.ValidateToken(ctx, pair.AccessToken, auth.AccessToken)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+	})
+}
+
+// staticRoleProvider grants the same roles to every user
+type staticRoleProvider struct {
+	roles []string
+	err   error
+}
+
+func (p *staticRoleProvider) UserRoleNames(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	return p.roles, p.err
+}
+
+func TestTokenService_Roles(t *testing.T) {
+	ctx := context.Background()
+
+	testUser := &user.User{
+		ID:       uuid.New(),
+		Email:    "test@example.com",
+		Username: "testuser",
+		Status:   user.StatusActive,
+	}
+
+	t.Run("tokens carry the roles granted to the user", func(t *testing.T) {
+		tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		tokenService.SetRoleProvider(&staticRoleProvider{roles: []string{"admin", "user"}})
+
+		pair, err := tokenService.GenerateTokenPair(ctx, testUser)
+		require.NoError(t, err)
+
+		claims, err := tokenService.ValidateToken(ctx, pair.AccessToken, auth.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user", "admin"}, claims.Roles)
+	})
+
+	t.Run("users without grants hold the user role", func(t *testing.T) {
+		tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		tokenService.SetRoleProvider(&staticRoleProvider{})
+
+		roles, err := tokenService.RolesFor(ctx, testUser)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user"}, roles)
+	})
+
+	t.Run("no tokens are issued when roles cannot be loaded", func(t *testing.T) {
+		tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+		tokenService.SetRoleProvider(&staticRoleProvider{err: assert.AnError})
+
+		_, err := tokenService.GenerateTokenPair(ctx, testUser)
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
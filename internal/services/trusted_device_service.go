@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+)
+
+// TrustedDeviceConfig holds the "remember this device" policy
+type TrustedDeviceConfig struct {
+	// TrustDuration is how long a remembered device skips MFA
+	TrustDuration time.Duration
+
+	// MaxDevicesPerUser caps remembered devices; the least recently trusted are
+	// forgotten first. Zero means unlimited.
+	MaxDevicesPerUser int
+}
+
+// DefaultTrustedDeviceConfig returns the default trusted device settings
+func DefaultTrustedDeviceConfig() TrustedDeviceConfig {
+	return TrustedDeviceConfig{
+		TrustDuration:     30 * 24 * time.Hour,
+		MaxDevicesPerUser: 10,
+	}
+}
+
+// TrustedDeviceService remembers devices that passed a second factor, so sign-ins
+// from them can skip MFA. A device holds a signed token naming its registry entry;
+// revoking the entry stops the token working.
+type TrustedDeviceService struct {
+	repo         mfa.TrustedDeviceRepository
+	auditService audit.AuditService
+	secret       []byte
+	config       TrustedDeviceConfig
+}
+
+// NewTrustedDeviceService creates a new trusted device service
+func NewTrustedDeviceService(repo mfa.TrustedDeviceRepository, secret string, config TrustedDeviceConfig) *TrustedDeviceService {
+	return &TrustedDeviceService{
+		repo:   repo,
+		secret: []byte(secret),
+		config: config,
+	}
+}
+
+// SetAuditService records devices being trusted and revoked in the audit log
+func (s *TrustedDeviceService) SetAuditService(auditService audit.AuditService) {
+	s.auditService = auditService
+}
+
+// TrustDuration returns how long a remembered device skips MFA
+func (s *TrustedDeviceService) TrustDuration() time.Duration {
+	return s.config.TrustDuration
+}
+
+// Trust remembers the device a user just passed a second factor on and returns
+// the token the device presents on later sign-ins
+func (s *TrustedDeviceService) Trust(ctx context.Context, userID uuid.UUID, ipAddress, userAgent string) (string, *mfa.TrustedDevice, error) {
+	now := time.Now()
+	device := &mfa.TrustedDevice{
+		ID:        uuid.New(),
+		UserID:    userID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		ExpiresAt: now.Add(s.config.TrustDuration).Truncate(time.Second),
+		CreatedAt: now,
+	}
+
+	if err := s.repo.Create(ctx, device); err != nil {
+		return "", nil, fmt.Errorf("failed to trust device: %w", err)
+	}
+
+	if err := s.enforceLimit(ctx, userID); err != nil {
+		return "", nil, err
+	}
+
+	s.log(ctx, &audit.CreateLogRequest{
+		EventType:   audit.EventTypeUserDeviceTrusted,
+		Severity:    audit.SeverityInfo,
+		UserID:      &userID,
+		ActorID:     &userID,
+		ActorType:   audit.ActorTypeUser,
+		EntityType:  "trusted_device",
+		EntityID:    device.ID.String(),
+		Action:      "trust",
+		Description: "Device trusted to skip MFA",
+		IPAddress:   ipAddress,
+		UserAgent:   userAgent,
+		Metadata: map[string]interface{}{
+			"expires_at": device.ExpiresAt,
+		},
+	})
+
+	return s.generateToken(device), device, nil
+}
+
+// Verify returns the user's trusted device the token names. It returns
+// mfa.ErrDeviceNotTrusted if the token is malformed, belongs to another user,
+// or names a device that has expired or been revoked.
+func (s *TrustedDeviceService) Verify(ctx context.Context, userID uuid.UUID, token string) (*mfa.TrustedDevice, error) {
+	deviceID, expiresAt, signature, err := parseTrustedDeviceToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal(signature, s.sign(deviceID, userID, expiresAt)) || time.Now().After(expiresAt) {
+		return nil, mfa.ErrDeviceNotTrusted
+	}
+
+	device, err := s.repo.GetByID(ctx, deviceID)
+	if err != nil {
+		if errors.Is(err, mfa.ErrTrustedDeviceNotFound) {
+			return nil, mfa.ErrDeviceNotTrusted
+		}
+		return nil, fmt.Errorf("failed to get trusted device: %w", err)
+	}
+
+	if device.UserID != userID || device.IsExpired() {
+		return nil, mfa.ErrDeviceNotTrusted
+	}
+
+	now := time.Now()
+	_ = s.repo.UpdateLastUsed(ctx, device.ID, now)
+	device.LastUsedAt = &now
+
+	return device, nil
+}
+
+// List returns the devices a user currently trusts, newest first
+func (s *TrustedDeviceService) List(ctx context.Context, userID uuid.UUID) ([]*mfa.TrustedDevice, error) {
+	devices, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trusted devices: %w", err)
+	}
+
+	trusted := make([]*mfa.TrustedDevice, 0, len(devices))
+	for _, device := range devices {
+		if !device.IsExpired() {
+			trusted = append(trusted, device)
+		}
+	}
+	return trusted, nil
+}
+
+// Revoke stops one of the user's devices from skipping MFA
+func (s *TrustedDeviceService) Revoke(ctx context.Context, userID, deviceID uuid.UUID) error {
+	if err := s.repo.Delete(ctx, userID, deviceID); err != nil {
+		if errors.Is(err, mfa.ErrTrustedDeviceNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to revoke trusted device: %w", err)
+	}
+
+	s.log(ctx, &audit.CreateLogRequest{
+		EventType:   audit.EventTypeUserDeviceRevoked,
+		Severity:    audit.SeverityInfo,
+		UserID:      &userID,
+		ActorID:     &userID,
+		ActorType:   audit.ActorTypeUser,
+		EntityType:  "trusted_device",
+		EntityID:    deviceID.String(),
+		Action:      "revoke",
+		Description: "Trusted device revoked",
+	})
+
+	return nil
+}
+
+// RevokeAll stops every device the user trusts from skipping MFA. The reason is
+// recorded in the audit log.
+func (s *TrustedDeviceService) RevokeAll(ctx context.Context, userID uuid.UUID, reason string) error {
+	if err := s.repo.DeleteByUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke trusted devices: %w", err)
+	}
+
+	s.log(ctx, &audit.CreateLogRequest{
+		EventType:   audit.EventTypeUserDeviceRevoked,
+		Severity:    audit.SeverityInfo,
+		UserID:      &userID,
+		EntityType:  "user",
+		EntityID:    userID.String(),
+		Action:      "revoke_all",
+		Description: "All trusted devices revoked: " + reason,
+	})
+
+	return nil
+}
+
+// enforceLimit forgets the user's oldest devices beyond MaxDevicesPerUser
+func (s *TrustedDeviceService) enforceLimit(ctx context.Context, userID uuid.UUID) error {
+	if s.config.MaxDevicesPerUser <= 0 {
+		return nil
+	}
+
+	devices, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list trusted devices: %w", err)
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].CreatedAt.After(devices[j].CreatedAt)
+	})
+	for _, device := range devices[min(len(devices), s.config.MaxDevicesPerUser):] {
+		if err := s.repo.Delete(ctx, userID, device.ID); err != nil && !errors.Is(err, mfa.ErrTrustedDeviceNotFound) {
+			return fmt.Errorf("failed to forget trusted device: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *TrustedDeviceService) log(ctx context.Context, req *audit.CreateLogRequest) {
+	if s.auditService == nil {
+		return
+	}
+	_, _ = s.auditService.Log(ctx, req)
+}
+
+// generateToken builds a device token of the form base64(deviceID|expiry).base64(hmac)
+func (s *TrustedDeviceService) generateToken(device *mfa.TrustedDevice) string {
+	payload := make([]byte, 0, 24)
+	payload = append(payload, device.ID[:]...)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(device.ExpiresAt.Unix()))
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(device.ID, device.UserID, device.ExpiresAt))
+}
+
+// sign computes the token signature over the device, its owner and the expiry,
+// so a token copied to another account's sign-in is rejected without a lookup
+func (s *TrustedDeviceService) sign(deviceID, userID uuid.UUID, expiresAt time.Time) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("trusted-device\x00"))
+	mac.Write(deviceID[:])
+	mac.Write(userID[:])
+	_ = binary.Write(mac, binary.BigEndian, expiresAt.Unix())
+	return mac.Sum(nil)
+}
+
+// parseTrustedDeviceToken decodes a device token without checking its signature
+func parseTrustedDeviceToken(token string) (uuid.UUID, time.Time, []byte, error) {
+	payloadPart, signaturePart, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, time.Time{}, nil, mfa.ErrDeviceNotTrusted
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(payloadPart)
+	if err != nil || len(payload) != 24 {
+		return uuid.Nil, time.Time{}, nil, mfa.ErrDeviceNotTrusted
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(signaturePart)
+	if err != nil {
+		return uuid.Nil, time.Time{}, nil, mfa.ErrDeviceNotTrusted
+	}
+
+	deviceID, err := uuid.FromBytes(payload[:16])
+	if err != nil {
+		return uuid.Nil, time.Time{}, nil, mfa.ErrDeviceNotTrusted
+	}
+
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[16:])), 0)
+
+	return deviceID, expiresAt, signature, nil
+}
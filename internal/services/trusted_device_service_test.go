@@ -0,0 +1,258 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/audit"
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/services"
+	"github.com/victoralfred/um_sys/pkg/security"
+)
+
+func newTrustedDeviceService(t *testing.T, config services.TrustedDeviceConfig) (*services.TrustedDeviceService, *InMemoryTrustedDeviceRepository, *MockAuditService) {
+	t.Helper()
+
+	repo := NewInMemoryTrustedDeviceRepository()
+	auditService := new(MockAuditService)
+	auditService.On("Log", mock.Anything, mock.Anything).Return(&audit.LogEntry{}, nil)
+
+	service := services.NewTrustedDeviceService(repo, "device-secret", config)
+	service.SetAuditService(auditService)
+	return service, repo, auditService
+}
+
+func TestTrustedDeviceService(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	t.Run("verifies the token of a trusted device", func(t *testing.T) {
+		service, _, auditService := newTrustedDeviceService(t, services.DefaultTrustedDeviceConfig())
+
+		token, device, err := service.Trust(ctx, userID, "10.0.0.1", "Firefox")
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), device.ExpiresAt, time.Minute)
+
+		verified, err := service.Verify(ctx, userID, token)
+		require.NoError(t, err)
+		assert.Equal(t, device.ID, verified.ID)
+		assert.NotNil(t, verified.LastUsedAt)
+
+		devices, err := service.List(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, devices, 1)
+		assert.Equal(t, "Firefox", devices[0].UserAgent)
+
+		logged := auditService.Calls[0].Arguments.Get(1).(*audit.CreateLogRequest)
+		assert.Equal(t, audit.EventTypeUserDeviceTrusted, logged.EventType)
+		assert.Equal(t, device.ID.String(), logged.EntityID)
+	})
+
+	t.Run("rejects tokens that were not issued to the user", func(t *testing.T) {
+		service, _, _ := newTrustedDeviceService(t, services.DefaultTrustedDeviceConfig())
+
+		token, _, err := service.Trust(ctx, userID, "10.0.0.1", "Firefox")
+		require.NoError(t, err)
+
+		_, err = service.Verify(ctx, uuid.New(), token)
+		assert.ErrorIs(t, err, mfa.ErrDeviceNotTrusted)
+
+		other := services.NewTrustedDeviceService(NewInMemoryTrustedDeviceRepository(), "other-secret", services.DefaultTrustedDeviceConfig())
+		_, err = other.Verify(ctx, userID, token)
+		assert.ErrorIs(t, err, mfa.ErrDeviceNotTrusted)
+
+		for _, malformed := range []string{"", "not-a-token", token + "x", "x" + token} {
+			_, err = service.Verify(ctx, userID, malformed)
+			assert.ErrorIs(t, err, mfa.ErrDeviceNotTrusted, malformed)
+		}
+	})
+
+	t.Run("stops trusting revoked and expired devices", func(t *testing.T) {
+		service, repo, _ := newTrustedDeviceService(t, services.DefaultTrustedDeviceConfig())
+
+		revokedToken, revoked, err := service.Trust(ctx, userID, "10.0.0.1", "Firefox")
+		require.NoError(t, err)
+		require.NoError(t, service.Revoke(ctx, userID, revoked.ID))
+		_, err = service.Verify(ctx, userID, revokedToken)
+		assert.ErrorIs(t, err, mfa.ErrDeviceNotTrusted)
+
+		assert.ErrorIs(t, service.Revoke(ctx, userID, revoked.ID), mfa.ErrTrustedDeviceNotFound)
+		assert.ErrorIs(t, service.Revoke(ctx, uuid.New(), revoked.ID), mfa.ErrTrustedDeviceNotFound)
+
+		expiredToken, _, err := service.Trust(ctx, userID, "10.0.0.1", "Safari")
+		require.NoError(t, err)
+		repo.expire()
+		_, err = service.Verify(ctx, userID, expiredToken)
+		assert.ErrorIs(t, err, mfa.ErrDeviceNotTrusted)
+
+		devices, err := service.List(ctx, userID)
+		require.NoError(t, err)
+		assert.Empty(t, devices)
+	})
+
+	t.Run("revokes every device at once", func(t *testing.T) {
+		service, _, _ := newTrustedDeviceService(t, services.DefaultTrustedDeviceConfig())
+
+		first, _, err := service.Trust(ctx, userID, "10.0.0.1", "Firefox")
+		require.NoError(t, err)
+		second, _, err := service.Trust(ctx, userID, "10.0.0.2", "Safari")
+		require.NoError(t, err)
+
+		require.NoError(t, service.RevokeAll(ctx, userID, "password changed"))
+
+		for _, token := range []string{first, second} {
+			_, err := service.Verify(ctx, userID, token)
+			assert.ErrorIs(t, err, mfa.ErrDeviceNotTrusted)
+		}
+	})
+
+	t.Run("forgets the oldest devices beyond the limit", func(t *testing.T) {
+		config := services.DefaultTrustedDeviceConfig()
+		config.MaxDevicesPerUser = 2
+		service, _, _ := newTrustedDeviceService(t, config)
+
+		var tokens []string
+		for _, agent := range []string{"Firefox", "Safari", "Chrome"} {
+			token, _, err := service.Trust(ctx, userID, "10.0.0.1", agent)
+			require.NoError(t, err)
+			tokens = append(tokens, token)
+			time.Sleep(time.Millisecond)
+		}
+
+		devices, err := service.List(ctx, userID)
+		require.NoError(t, err)
+		assert.Len(t, devices, 2)
+
+		_, err = service.Verify(ctx, userID, tokens[0])
+		assert.ErrorIs(t, err, mfa.ErrDeviceNotTrusted)
+		_, err = service.Verify(ctx, userID, tokens[2])
+		assert.NoError(t, err)
+	})
+}
+
+func TestAuthService_TrustedDevices(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*services.AuthService, *services.TrustedDeviceService, *user.User) {
+		f := newAuthServiceFixture(t)
+		f.user.MFAEnabled = true
+
+		userRepo := NewInMemoryUserRepository()
+		require.NoError(t, userRepo.Create(ctx, f.user))
+
+		validator := security.NewPasswordValidator(&security.PasswordPolicy{MinLength: 8})
+		service := services.NewAuthService(userRepo, f.tokenService, f.hasher, validator, f.mailSender, services.DefaultAuthConfig())
+
+		mfaRepo := new(MockMFARepository)
+		totp := new(MockTOTPProvider)
+		mfaRepo.On("GetSettings", ctx, f.user.ID).Return(&mfa.Settings{
+			UserID:     f.user.ID,
+			Enabled:    true,
+			TOTPSecret: "JBSWY3DPEHPK3PXP",
+			Methods:    []mfa.Method{mfa.MethodTOTP},
+		}, nil)
+		mfaRepo.On("SaveSettings", ctx, mock.Anything).Return(nil)
+		mfaRepo.On("LogAudit", ctx, mock.Anything).Return(nil)
+		totp.On("ValidateCode", "JBSWY3DPEHPK3PXP", "123456").Return(true, nil)
+		service.SetMFAService(services.NewMFAService(mfaRepo, userRepo, totp, nil, nil, nil, nil, nil))
+
+		trustedDevices := services.NewTrustedDeviceService(NewInMemoryTrustedDeviceRepository(), "device-secret", services.DefaultTrustedDeviceConfig())
+		service.SetTrustedDeviceService(trustedDevices)
+
+		return service, trustedDevices, f.user
+	}
+
+	login := func(service *services.AuthService, req *auth.LoginRequest) (*auth.TokenPair, error) {
+		req.Email = "test@example.com"
+		if req.Password == "" {
+			req.Password = "OldPassword1"
+		}
+		tokenPair, _, err := service.Login(ctx, req)
+		return tokenPair, err
+	}
+
+	t.Run("remembers a device that passed a second factor", func(t *testing.T) {
+		service, trustedDevices, u := setup(t)
+
+		_, err := login(service, &auth.LoginRequest{})
+		assert.ErrorIs(t, err, auth.ErrMFARequired)
+
+		tokenPair, err := login(service, &auth.LoginRequest{
+			MFAMethod:      mfa.MethodTOTP,
+			MFACode:        "123456",
+			RememberDevice: true,
+			UserAgent:      "Firefox",
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, tokenPair.TrustedDeviceToken)
+
+		devices, err := trustedDevices.List(ctx, u.ID)
+		require.NoError(t, err)
+		require.Len(t, devices, 1)
+		assert.Equal(t, "Firefox", devices[0].UserAgent)
+
+		// The device now skips MFA, and is not remembered a second time
+		tokenPair, err = login(service, &auth.LoginRequest{
+			DeviceToken:    tokenPair.TrustedDeviceToken,
+			RememberDevice: true,
+		})
+		require.NoError(t, err)
+		assert.Empty(t, tokenPair.TrustedDeviceToken)
+	})
+
+	t.Run("only remembers devices when asked", func(t *testing.T) {
+		service, _, _ := setup(t)
+
+		tokenPair, err := login(service, &auth.LoginRequest{MFAMethod: mfa.MethodTOTP, MFACode: "123456"})
+		require.NoError(t, err)
+		assert.Empty(t, tokenPair.TrustedDeviceToken)
+	})
+
+	t.Run("forgets devices when the password changes", func(t *testing.T) {
+		service, trustedDevices, u := setup(t)
+
+		deviceToken, _, err := trustedDevices.Trust(ctx, u.ID, "10.0.0.1", "Firefox")
+		require.NoError(t, err)
+
+		require.NoError(t, service.ChangePassword(ctx, u.ID, "OldPassword1", "NewPassword2"))
+
+		_, err = login(service, &auth.LoginRequest{Password: "NewPassword2", DeviceToken: deviceToken})
+		assert.ErrorIs(t, err, auth.ErrMFARequired)
+	})
+}
+
+func TestMFAService_DisableMFA_RevokesTrustedDevices(t *testing.T) {
+	ctx := context.Background()
+
+	mockRepo := new(MockMFARepository)
+	mockUserRepo := new(MockUserRepository)
+	mockHasher := new(MockPasswordHasher)
+	mfaService := services.NewMFAService(mockRepo, mockUserRepo, nil, nil, nil, nil, nil, mockHasher)
+
+	trustedDevices, _, _ := newTrustedDeviceService(t, services.DefaultTrustedDeviceConfig())
+	mfaService.SetTrustedDeviceService(trustedDevices)
+
+	testUser := &user.User{ID: uuid.New(), PasswordHash: "$2a$10$hashedpassword"}
+	deviceToken, _, err := trustedDevices.Trust(ctx, testUser.ID, "10.0.0.1", "Firefox")
+	require.NoError(t, err)
+
+	mockUserRepo.On("GetByID", ctx, testUser.ID).Return(testUser, nil)
+	mockHasher.On("VerifyPassword", "SecurePass123!", testUser.PasswordHash).Return(nil)
+	mockRepo.On("GetSettings", ctx, testUser.ID).Return(&mfa.Settings{UserID: testUser.ID, Enabled: true}, nil)
+	mockRepo.On("DeleteSettings", ctx, testUser.ID).Return(nil)
+	mockRepo.On("DeleteBackupCodes", ctx, testUser.ID).Return(nil)
+	mockRepo.On("LogAudit", ctx, mock.Anything).Return(nil)
+
+	require.NoError(t, mfaService.DisableMFA(ctx, &mfa.DisableRequest{UserID: testUser.ID, Password: "SecurePass123!"}))
+
+	_, err = trustedDevices.Verify(ctx, testUser.ID, deviceToken)
+	assert.ErrorIs(t, err, mfa.ErrDeviceNotTrusted)
+}
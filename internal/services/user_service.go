@@ -55,7 +55,7 @@ func (s *UserService) UpdateLastLogin(ctx context.Context, id uuid.UUID, loginTi
 	return s.userRepo.UpdateLastLogin(ctx, id, loginTime)
 }
 
-// IncrementFailedLoginAttempts increments failed login attempts
-func (s *UserService) IncrementFailedLoginAttempts(ctx context.Context, id uuid.UUID) error {
+// IncrementFailedLoginAttempts increments failed login attempts and returns the new count
+func (s *UserService) IncrementFailedLoginAttempts(ctx context.Context, id uuid.UUID) (int, error) {
 	return s.userRepo.IncrementFailedLoginAttempts(ctx, id)
 }
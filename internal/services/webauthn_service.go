@@ -0,0 +1,467 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/domain/webauthn"
+)
+
+// WebAuthnConfig holds the relying party settings for security keys and passkeys
+type WebAuthnConfig struct {
+	// RPID is the domain credentials are scoped to; it must match the site's host or a parent of it
+	RPID string
+
+	// RPName is the name authenticators show when registering
+	RPName string
+
+	// Timeout is how long a ceremony may take before its challenge expires
+	Timeout time.Duration
+}
+
+// DefaultWebAuthnConfig returns the default WebAuthn settings
+func DefaultWebAuthnConfig() WebAuthnConfig {
+	return WebAuthnConfig{
+		RPID:    "localhost",
+		RPName:  mfa.TOTPIssuer,
+		Timeout: webauthn.CeremonyTimeout,
+	}
+}
+
+// WebAuthnService registers security keys and passkeys and runs their ceremonies.
+// Credentials serve as a second factor and, for passkeys, as a passwordless login.
+type WebAuthnService struct {
+	credentials webauthn.CredentialRepository
+	ceremonies  webauthn.CeremonyRepository
+	verifier    webauthn.Verifier
+	userRepo    user.Repository
+	mfaRepo     mfa.Repository
+	config      WebAuthnConfig
+}
+
+// NewWebAuthnService creates a new WebAuthn service
+func NewWebAuthnService(
+	credentials webauthn.CredentialRepository,
+	ceremonies webauthn.CeremonyRepository,
+	verifier webauthn.Verifier,
+	userRepo user.Repository,
+	config WebAuthnConfig,
+) *WebAuthnService {
+	return &WebAuthnService{
+		credentials: credentials,
+		ceremonies:  ceremonies,
+		verifier:    verifier,
+		userRepo:    userRepo,
+		config:      config,
+	}
+}
+
+// SetMFARepository records WebAuthn in users' MFA settings and audit log as credentials change
+func (s *WebAuthnService) SetMFARepository(repo mfa.Repository) {
+	s.mfaRepo = repo
+}
+
+// BeginRegistration starts registering a named credential for a user
+func (s *WebAuthnService) BeginRegistration(ctx context.Context, userID uuid.UUID, name string) (*webauthn.RegistrationOptions, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, webauthn.ErrInvalidCredentialName
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	existing, err := s.credentials.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+
+	ceremony, err := s.newCeremony(ctx, webauthn.CeremonyRegistration, &userID, webauthn.UserVerificationPreferred)
+	if err != nil {
+		return nil, err
+	}
+	ceremony.CredentialName = name
+	if err := s.ceremonies.Create(ctx, ceremony); err != nil {
+		return nil, fmt.Errorf("failed to save ceremony: %w", err)
+	}
+
+	displayName := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if displayName == "" {
+		displayName = u.Username
+	}
+
+	return &webauthn.RegistrationOptions{
+		CeremonyID: ceremony.ID,
+		PublicKey: &webauthn.CreationOptions{
+			RP: webauthn.RelyingPartyEntity{
+				ID:   s.config.RPID,
+				Name: s.config.RPName,
+			},
+			// The user handle is the user ID, so passkey logins can identify the account
+			User: webauthn.UserEntity{
+				ID:          userID[:],
+				Name:        u.Email,
+				DisplayName: displayName,
+			},
+			Challenge: ceremony.Challenge,
+			PubKeyCredParams: []webauthn.CredentialParameter{
+				{Type: webauthn.PublicKeyCredentialType, Alg: webauthn.AlgES256},
+				{Type: webauthn.PublicKeyCredentialType, Alg: webauthn.AlgEdDSA},
+				{Type: webauthn.PublicKeyCredentialType, Alg: webauthn.AlgRS256},
+			},
+			Timeout:            s.config.Timeout.Milliseconds(),
+			ExcludeCredentials: descriptors(existing),
+			AuthenticatorSelection: webauthn.AuthenticatorSelection{
+				ResidentKey:      webauthn.ResidentKeyPreferred,
+				UserVerification: ceremony.UserVerification,
+			},
+			Attestation: webauthn.AttestationNone,
+		},
+	}, nil
+}
+
+// FinishRegistration verifies the authenticator's response and stores the new credential
+func (s *WebAuthnService) FinishRegistration(ctx context.Context, userID uuid.UUID, req *webauthn.FinishRegistrationRequest) (*webauthn.Credential, error) {
+	ceremony, err := s.consumeCeremony(ctx, req.CeremonyID, webauthn.CeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.UserID == nil || *ceremony.UserID != userID {
+		return nil, webauthn.ErrCeremonyNotFound
+	}
+
+	attested, err := s.verifier.VerifyRegistration(ceremony, &req.Credential)
+	if err != nil {
+		s.logAudit(ctx, userID, "setup", false, err.Error())
+		return nil, err
+	}
+
+	now := time.Now()
+	credential := &webauthn.Credential{
+		ID:             uuid.New(),
+		UserID:         userID,
+		Name:           ceremony.CredentialName,
+		CredentialID:   attested.CredentialID,
+		PublicKey:      attested.PublicKey,
+		Algorithm:      attested.Algorithm,
+		SignCount:      attested.SignCount,
+		AAGUID:         attested.AAGUID,
+		Transports:     req.Credential.Response.Transports,
+		BackupEligible: attested.BackupEligible,
+		CreatedAt:      now,
+	}
+
+	if err := s.credentials.Create(ctx, credential); err != nil {
+		if errors.Is(err, webauthn.ErrCredentialExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to save credential: %w", err)
+	}
+
+	if err := s.enableMFAMethod(ctx, userID); err != nil {
+		return nil, err
+	}
+	s.logAudit(ctx, userID, "setup", true, credential.Name)
+
+	return credential, nil
+}
+
+// ListCredentials returns the credentials a user has registered
+func (s *WebAuthnService) ListCredentials(ctx context.Context, userID uuid.UUID) ([]*webauthn.Credential, error) {
+	return s.credentials.ListByUser(ctx, userID)
+}
+
+// RenameCredential changes the name of one of a user's credentials
+func (s *WebAuthnService) RenameCredential(ctx context.Context, userID, id uuid.UUID, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return webauthn.ErrInvalidCredentialName
+	}
+	return s.credentials.Rename(ctx, userID, id, name)
+}
+
+// DeleteCredential removes one of a user's credentials. Removing the last one takes
+// WebAuthn out of the user's MFA methods.
+func (s *WebAuthnService) DeleteCredential(ctx context.Context, userID, id uuid.UUID) error {
+	if err := s.credentials.Delete(ctx, userID, id); err != nil {
+		return err
+	}
+
+	remaining, err := s.credentials.ListByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list credentials: %w", err)
+	}
+	if len(remaining) == 0 {
+		if err := s.disableMFAMethod(ctx, userID); err != nil {
+			return err
+		}
+	}
+
+	s.logAudit(ctx, userID, "remove", true, id.String())
+	return nil
+}
+
+// BeginLogin starts a passwordless login. The user is not known yet, so any passkey
+// for this relying party may answer, and the authenticator must verify the user.
+func (s *WebAuthnService) BeginLogin(ctx context.Context) (*webauthn.LoginOptions, error) {
+	ceremony, err := s.newCeremony(ctx, webauthn.CeremonyAuthentication, nil, webauthn.UserVerificationRequired)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ceremonies.Create(ctx, ceremony); err != nil {
+		return nil, fmt.Errorf("failed to save ceremony: %w", err)
+	}
+
+	return s.loginOptions(ceremony, nil), nil
+}
+
+// FinishLogin verifies a passkey login and returns the credential that signed it
+func (s *WebAuthnService) FinishLogin(ctx context.Context, req *webauthn.FinishLoginRequest) (*webauthn.Credential, error) {
+	ceremony, err := s.consumeCeremony(ctx, req.CeremonyID, webauthn.CeremonyAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	// Second-factor ceremonies do not require user verification, so they cannot sign in alone
+	if ceremony.UserID != nil {
+		return nil, webauthn.ErrCeremonyNotFound
+	}
+
+	return s.verifyAssertion(ctx, ceremony, &req.Credential)
+}
+
+// BeginChallenge starts a second-factor check limited to the user's own credentials
+func (s *WebAuthnService) BeginChallenge(ctx context.Context, userID uuid.UUID) (*webauthn.LoginOptions, error) {
+	credentials, err := s.credentials.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+	if len(credentials) == 0 {
+		return nil, mfa.ErrMethodNotConfigured
+	}
+
+	ceremony, err := s.newCeremony(ctx, webauthn.CeremonyAuthentication, &userID, webauthn.UserVerificationPreferred)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ceremonies.Create(ctx, ceremony); err != nil {
+		return nil, fmt.Errorf("failed to save ceremony: %w", err)
+	}
+
+	return s.loginOptions(ceremony, credentials), nil
+}
+
+// VerifyChallenge completes a second-factor check started by BeginChallenge
+func (s *WebAuthnService) VerifyChallenge(ctx context.Context, userID uuid.UUID, req *webauthn.FinishLoginRequest) (*mfa.VerifyResponse, error) {
+	ceremony, err := s.consumeCeremony(ctx, req.CeremonyID, webauthn.CeremonyAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.UserID == nil || *ceremony.UserID != userID {
+		return nil, webauthn.ErrCeremonyNotFound
+	}
+
+	if _, err := s.verifyAssertion(ctx, ceremony, &req.Credential); err != nil {
+		s.logAudit(ctx, userID, "verify", false, err.Error())
+		return nil, err
+	}
+	s.logAudit(ctx, userID, "verify", true, "")
+
+	return &mfa.VerifyResponse{
+		Valid:      true,
+		Method:     mfa.MethodWebAuthn,
+		VerifiedAt: time.Now(),
+	}, nil
+}
+
+// verifyAssertion checks an assertion against the stored credential and advances its counter.
+// A counter that fails to increase means another copy of the key has been used, so the
+// assertion is refused.
+func (s *WebAuthnService) verifyAssertion(ctx context.Context, ceremony *webauthn.Ceremony, response *webauthn.AssertionResponse) (*webauthn.Credential, error) {
+	credential, err := s.credentials.GetByCredentialID(ctx, response.RawID)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.UserID != nil && credential.UserID != *ceremony.UserID {
+		return nil, webauthn.ErrCredentialNotFound
+	}
+
+	result, err := s.verifier.VerifyAssertion(ceremony, credential, response)
+	if err != nil {
+		return nil, err
+	}
+
+	// Authenticators without a counter always report zero
+	if (result.SignCount != 0 || credential.SignCount != 0) && result.SignCount <= credential.SignCount {
+		s.logAudit(ctx, credential.UserID, "clone_detected", false, credential.ID.String())
+		return nil, webauthn.ErrCloneDetected
+	}
+
+	now := time.Now()
+	if err := s.credentials.UpdateSignCount(ctx, credential.ID, result.SignCount, now); err != nil {
+		return nil, fmt.Errorf("failed to update credential: %w", err)
+	}
+	credential.SignCount = result.SignCount
+	credential.LastUsedAt = &now
+
+	return credential, nil
+}
+
+func (s *WebAuthnService) newCeremony(ctx context.Context, ceremonyType webauthn.CeremonyType, userID *uuid.UUID, userVerification string) (*webauthn.Ceremony, error) {
+	challenge := make([]byte, webauthn.ChallengeLength)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+
+	// Abandoned ceremonies are cleared as new ones start
+	_ = s.ceremonies.DeleteExpired(ctx)
+
+	now := time.Now()
+	return &webauthn.Ceremony{
+		ID:               uuid.New(),
+		Type:             ceremonyType,
+		UserID:           userID,
+		Challenge:        challenge,
+		UserVerification: userVerification,
+		ExpiresAt:        now.Add(s.config.Timeout),
+		CreatedAt:        now,
+	}, nil
+}
+
+func (s *WebAuthnService) consumeCeremony(ctx context.Context, id uuid.UUID, ceremonyType webauthn.CeremonyType) (*webauthn.Ceremony, error) {
+	ceremony, err := s.ceremonies.Consume(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.Type != ceremonyType {
+		return nil, webauthn.ErrCeremonyNotFound
+	}
+	if ceremony.IsExpired() {
+		return nil, webauthn.ErrCeremonyExpired
+	}
+	return ceremony, nil
+}
+
+func (s *WebAuthnService) loginOptions(ceremony *webauthn.Ceremony, credentials []*webauthn.Credential) *webauthn.LoginOptions {
+	return &webauthn.LoginOptions{
+		CeremonyID: ceremony.ID,
+		PublicKey: &webauthn.RequestOptions{
+			Challenge:        ceremony.Challenge,
+			Timeout:          s.config.Timeout.Milliseconds(),
+			RPID:             s.config.RPID,
+			AllowCredentials: descriptors(credentials),
+			UserVerification: ceremony.UserVerification,
+		},
+	}
+}
+
+// enableMFAMethod adds WebAuthn to the user's MFA methods
+func (s *WebAuthnService) enableMFAMethod(ctx context.Context, userID uuid.UUID) error {
+	if s.mfaRepo == nil {
+		return nil
+	}
+
+	settings, err := s.mfaRepo.GetSettings(ctx, userID)
+	if err != nil {
+		if err != mfa.ErrSettingsNotFound {
+			return fmt.Errorf("failed to get MFA settings: %w", err)
+		}
+		settings = &mfa.Settings{
+			UserID:    userID,
+			CreatedAt: time.Now(),
+		}
+	}
+
+	for _, method := range settings.Methods {
+		if method == mfa.MethodWebAuthn {
+			return nil
+		}
+	}
+
+	settings.Enabled = true
+	settings.Methods = append(settings.Methods, mfa.MethodWebAuthn)
+	if settings.PrimaryMethod == "" {
+		settings.PrimaryMethod = mfa.MethodWebAuthn
+	}
+	settings.UpdatedAt = time.Now()
+
+	if err := s.mfaRepo.SaveSettings(ctx, settings); err != nil {
+		return fmt.Errorf("failed to save MFA settings: %w", err)
+	}
+	return nil
+}
+
+// disableMFAMethod removes WebAuthn from the user's MFA methods, disabling MFA if nothing is left
+func (s *WebAuthnService) disableMFAMethod(ctx context.Context, userID uuid.UUID) error {
+	if s.mfaRepo == nil {
+		return nil
+	}
+
+	settings, err := s.mfaRepo.GetSettings(ctx, userID)
+	if err != nil {
+		if err == mfa.ErrSettingsNotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to get MFA settings: %w", err)
+	}
+
+	methods := make([]mfa.Method, 0, len(settings.Methods))
+	for _, method := range settings.Methods {
+		if method != mfa.MethodWebAuthn {
+			methods = append(methods, method)
+		}
+	}
+	settings.Methods = methods
+	if settings.PrimaryMethod == mfa.MethodWebAuthn {
+		settings.PrimaryMethod = ""
+		if len(methods) > 0 {
+			settings.PrimaryMethod = methods[0]
+		}
+	}
+	settings.Enabled = len(methods) > 0
+	settings.UpdatedAt = time.Now()
+
+	if err := s.mfaRepo.SaveSettings(ctx, settings); err != nil {
+		return fmt.Errorf("failed to save MFA settings: %w", err)
+	}
+	return nil
+}
+
+// logAudit logs a WebAuthn event to the MFA audit log
+func (s *WebAuthnService) logAudit(ctx context.Context, userID uuid.UUID, action string, success bool, details string) {
+	if s.mfaRepo == nil {
+		return
+	}
+
+	_ = s.mfaRepo.LogAudit(ctx, &mfa.AuditLog{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Action:    action,
+		Method:    mfa.MethodWebAuthn,
+		Success:   success,
+		Details:   details,
+		CreatedAt: time.Now(),
+	})
+}
+
+// descriptors lists credentials the way ceremony options refer to them
+func descriptors(credentials []*webauthn.Credential) []webauthn.CredentialDescriptor {
+	result := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, c := range credentials {
+		result = append(result, webauthn.CredentialDescriptor{
+			Type:       webauthn.PublicKeyCredentialType,
+			ID:         c.CredentialID,
+			Transports: c.Transports,
+		})
+	}
+	return result
+}
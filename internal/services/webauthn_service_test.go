@@ -0,0 +1,475 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/victoralfred/um_sys/internal/domain/auth"
+	"github.com/victoralfred/um_sys/internal/domain/mfa"
+	"github.com/victoralfred/um_sys/internal/domain/user"
+	"github.com/victoralfred/um_sys/internal/domain/webauthn"
+	webauthnImpl "github.com/victoralfred/um_sys/internal/infrastructure/webauthn"
+	"github.com/victoralfred/um_sys/internal/infrastructure/webauthn/webauthntest"
+	"github.com/victoralfred/um_sys/internal/services"
+	"github.com/victoralfred/um_sys/pkg/security"
+)
+
+const webAuthnOrigin = "http://localhost:8080"
+
+type webAuthnFixture struct {
+	service       *services.WebAuthnService
+	authService   *services.AuthService
+	credentials   *InMemoryWebAuthnCredentialRepository
+	authenticator *webauthntest.Authenticator
+	users         *InMemoryUserRepository
+	user          *user.User
+}
+
+func newWebAuthnFixture(t *testing.T) *webAuthnFixture {
+	t.Helper()
+
+	userRepo := NewInMemoryUserRepository()
+	u := &user.User{
+		ID:            uuid.New(),
+		Email:         "test@example.com",
+		Username:      "testuser",
+		FirstName:     "Test",
+		LastName:      "User",
+		EmailVerified: true,
+		Status:        user.StatusActive,
+	}
+	require.NoError(t, userRepo.Create(context.Background(), u))
+
+	credentials := NewInMemoryWebAuthnCredentialRepository()
+	service := services.NewWebAuthnService(
+		credentials,
+		NewInMemoryWebAuthnCeremonyRepository(),
+		webauthnImpl.NewVerifier("localhost", []string{webAuthnOrigin}),
+		userRepo,
+		services.DefaultWebAuthnConfig(),
+	)
+
+	tokenService := services.NewTokenService("test-secret-key-at-least-32-bytes-long!", "test-issuer", 15*time.Minute, 7*24*time.Hour, nil)
+	tokenService.SetTokenStore(NewInMemoryTokenStore())
+	authService := services.NewAuthService(
+		userRepo,
+		tokenService,
+		security.NewPasswordHasher(),
+		security.NewPasswordValidator(&security.PasswordPolicy{MinLength: 8}),
+		new(MockMailSender),
+		services.DefaultAuthConfig(),
+	)
+	authService.SetWebAuthnService(service)
+
+	return &webAuthnFixture{
+		service:       service,
+		authService:   authService,
+		credentials:   credentials,
+		authenticator: webauthntest.NewAuthenticator("localhost", webAuthnOrigin),
+		users:         userRepo,
+		user:          u,
+	}
+}
+
+func (f *webAuthnFixture) register(t *testing.T, authenticator *webauthntest.Authenticator, name string) *webauthn.Credential {
+	t.Helper()
+	ctx := context.Background()
+
+	options, err := f.service.BeginRegistration(ctx, f.user.ID, name)
+	require.NoError(t, err)
+
+	response, err := authenticator.Register(options.PublicKey)
+	require.NoError(t, err)
+
+	credential, err := f.service.FinishRegistration(ctx, f.user.ID, &webauthn.FinishRegistrationRequest{
+		CeremonyID: options.CeremonyID,
+		Credential: *response,
+	})
+	require.NoError(t, err)
+	return credential
+}
+
+// passkeyLogin runs a passwordless ceremony with the authenticator
+func (f *webAuthnFixture) passkeyLogin(t *testing.T, authenticator *webauthntest.Authenticator) *webauthn.FinishLoginRequest {
+	t.Helper()
+
+	options, err := f.service.BeginLogin(context.Background())
+	require.NoError(t, err)
+
+	response, err := authenticator.Login(options.PublicKey)
+	require.NoError(t, err)
+
+	return &webauthn.FinishLoginRequest{CeremonyID: options.CeremonyID, Credential: *response}
+}
+
+func TestWebAuthnService_Registration(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("registers multiple named credentials", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+
+		laptop := f.register(t, f.authenticator, "Laptop")
+		key := f.register(t, webauthntest.NewAuthenticator("localhost", webAuthnOrigin), "YubiKey")
+
+		credentials, err := f.service.ListCredentials(ctx, f.user.ID)
+		require.NoError(t, err)
+		require.Len(t, credentials, 2)
+
+		names := []string{credentials[0].Name, credentials[1].Name}
+		assert.ElementsMatch(t, []string{"Laptop", "YubiKey"}, names)
+		assert.NotEqual(t, laptop.CredentialID, key.CredentialID)
+		assert.Equal(t, int64(webauthn.AlgES256), laptop.Algorithm)
+	})
+
+	t.Run("excludes credentials the user already has", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		existing := f.register(t, f.authenticator, "Laptop")
+
+		options, err := f.service.BeginRegistration(ctx, f.user.ID, "Laptop again")
+		require.NoError(t, err)
+
+		require.Len(t, options.PublicKey.ExcludeCredentials, 1)
+		assert.Equal(t, existing.CredentialID, options.PublicKey.ExcludeCredentials[0].ID)
+		assert.Equal(t, f.user.ID[:], []byte(options.PublicKey.User.ID))
+
+		_, err = f.authenticator.Register(options.PublicKey)
+		assert.Error(t, err)
+	})
+
+	t.Run("requires a name", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+
+		_, err := f.service.BeginRegistration(ctx, f.user.ID, "   ")
+		assert.ErrorIs(t, err, webauthn.ErrInvalidCredentialName)
+	})
+
+	t.Run("rejects a response from another origin", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+
+		options, err := f.service.BeginRegistration(ctx, f.user.ID, "Laptop")
+		require.NoError(t, err)
+
+		phishing := webauthntest.NewAuthenticator("localhost", "https://evil.example.com")
+		response, err := phishing.Register(options.PublicKey)
+		require.NoError(t, err)
+
+		_, err = f.service.FinishRegistration(ctx, f.user.ID, &webauthn.FinishRegistrationRequest{
+			CeremonyID: options.CeremonyID,
+			Credential: *response,
+		})
+		assert.ErrorIs(t, err, webauthn.ErrVerificationFailed)
+	})
+
+	t.Run("rejects a ceremony started by another user", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+
+		options, err := f.service.BeginRegistration(ctx, f.user.ID, "Laptop")
+		require.NoError(t, err)
+		response, err := f.authenticator.Register(options.PublicKey)
+		require.NoError(t, err)
+
+		_, err = f.service.FinishRegistration(ctx, uuid.New(), &webauthn.FinishRegistrationRequest{
+			CeremonyID: options.CeremonyID,
+			Credential: *response,
+		})
+		assert.ErrorIs(t, err, webauthn.ErrCeremonyNotFound)
+	})
+}
+
+func TestWebAuthnService_MFASettings(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("adds and removes the WebAuthn method as credentials change", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+
+		mfaRepo := new(MockMFARepository)
+		f.service.SetMFARepository(mfaRepo)
+
+		mfaRepo.On("GetSettings", mock.Anything, f.user.ID).Return(nil, mfa.ErrSettingsNotFound).Once()
+		mfaRepo.On("SaveSettings", mock.Anything, mock.MatchedBy(func(s *mfa.Settings) bool {
+			return s.Enabled && s.PrimaryMethod == mfa.MethodWebAuthn &&
+				len(s.Methods) == 1 && s.Methods[0] == mfa.MethodWebAuthn
+		})).Return(nil).Once()
+		mfaRepo.On("LogAudit", mock.Anything, mock.Anything).Return(nil)
+
+		credential := f.register(t, f.authenticator, "Laptop")
+
+		mfaRepo.On("GetSettings", mock.Anything, f.user.ID).Return(&mfa.Settings{
+			UserID:        f.user.ID,
+			Enabled:       true,
+			Methods:       []mfa.Method{mfa.MethodTOTP, mfa.MethodWebAuthn},
+			PrimaryMethod: mfa.MethodWebAuthn,
+		}, nil).Once()
+		mfaRepo.On("SaveSettings", mock.Anything, mock.MatchedBy(func(s *mfa.Settings) bool {
+			return s.Enabled && s.PrimaryMethod == mfa.MethodTOTP &&
+				len(s.Methods) == 1 && s.Methods[0] == mfa.MethodTOTP
+		})).Return(nil).Once()
+
+		require.NoError(t, f.service.DeleteCredential(ctx, f.user.ID, credential.ID))
+		mfaRepo.AssertExpectations(t)
+	})
+
+	t.Run("keeps the method while other credentials remain", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+
+		first := f.register(t, f.authenticator, "Laptop")
+		f.register(t, webauthntest.NewAuthenticator("localhost", webAuthnOrigin), "YubiKey")
+
+		mfaRepo := new(MockMFARepository)
+		f.service.SetMFARepository(mfaRepo)
+		mfaRepo.On("LogAudit", mock.Anything, mock.Anything).Return(nil)
+
+		require.NoError(t, f.service.DeleteCredential(ctx, f.user.ID, first.ID))
+		mfaRepo.AssertNotCalled(t, "SaveSettings", mock.Anything, mock.Anything)
+	})
+
+	t.Run("only deletes the user's own credentials", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		credential := f.register(t, f.authenticator, "Laptop")
+
+		err := f.service.DeleteCredential(ctx, uuid.New(), credential.ID)
+		assert.ErrorIs(t, err, webauthn.ErrCredentialNotFound)
+
+		err = f.service.RenameCredential(ctx, uuid.New(), credential.ID, "Mine now")
+		assert.ErrorIs(t, err, webauthn.ErrCredentialNotFound)
+	})
+}
+
+func TestWebAuthnService_VerifyChallenge(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("verifies the user's credential as a second factor", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		credential := f.register(t, f.authenticator, "Laptop")
+
+		options, err := f.service.BeginChallenge(ctx, f.user.ID)
+		require.NoError(t, err)
+		require.Len(t, options.PublicKey.AllowCredentials, 1)
+		assert.Equal(t, credential.CredentialID, options.PublicKey.AllowCredentials[0].ID)
+
+		response, err := f.authenticator.Login(options.PublicKey)
+		require.NoError(t, err)
+
+		result, err := f.service.VerifyChallenge(ctx, f.user.ID, &webauthn.FinishLoginRequest{
+			CeremonyID: options.CeremonyID,
+			Credential: *response,
+		})
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, mfa.MethodWebAuthn, result.Method)
+
+		stored, err := f.credentials.GetByCredentialID(ctx, credential.CredentialID)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(1), stored.SignCount)
+		assert.NotNil(t, stored.LastUsedAt)
+	})
+
+	t.Run("refuses to start without credentials", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+
+		_, err := f.service.BeginChallenge(ctx, f.user.ID)
+		assert.ErrorIs(t, err, mfa.ErrMethodNotConfigured)
+	})
+
+	t.Run("challenges are single-use", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		f.register(t, f.authenticator, "Laptop")
+
+		options, err := f.service.BeginChallenge(ctx, f.user.ID)
+		require.NoError(t, err)
+		response, err := f.authenticator.Login(options.PublicKey)
+		require.NoError(t, err)
+
+		req := &webauthn.FinishLoginRequest{CeremonyID: options.CeremonyID, Credential: *response}
+		_, err = f.service.VerifyChallenge(ctx, f.user.ID, req)
+		require.NoError(t, err)
+
+		_, err = f.service.VerifyChallenge(ctx, f.user.ID, req)
+		assert.ErrorIs(t, err, webauthn.ErrCeremonyNotFound)
+	})
+
+	t.Run("second-factor challenges cannot be used to sign in", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		f.register(t, f.authenticator, "Laptop")
+
+		options, err := f.service.BeginChallenge(ctx, f.user.ID)
+		require.NoError(t, err)
+		response, err := f.authenticator.Login(options.PublicKey)
+		require.NoError(t, err)
+
+		_, err = f.service.FinishLogin(ctx, &webauthn.FinishLoginRequest{
+			CeremonyID: options.CeremonyID,
+			Credential: *response,
+		})
+		assert.ErrorIs(t, err, webauthn.ErrCeremonyNotFound)
+	})
+}
+
+func TestWebAuthnService_PasskeyLogin(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("signs in with a passkey", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		f.register(t, f.authenticator, "Laptop")
+
+		tokenPair, u, err := f.authService.Login(ctx, &auth.LoginRequest{
+			Passkey: f.passkeyLogin(t, f.authenticator),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, f.user.ID, u.ID)
+		assert.NotEmpty(t, tokenPair.AccessToken)
+		assert.NotEmpty(t, tokenPair.RefreshToken)
+	})
+
+	t.Run("requires user verification", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		f.register(t, f.authenticator, "Security key")
+		f.authenticator.UserVerified = false
+
+		_, err := f.service.FinishLogin(ctx, f.passkeyLogin(t, f.authenticator))
+		assert.ErrorIs(t, err, webauthn.ErrUserVerificationRequired)
+	})
+
+	t.Run("detects cloned authenticators", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		f.register(t, f.authenticator, "Laptop")
+		clone := f.authenticator.Clone()
+
+		_, err := f.service.FinishLogin(ctx, f.passkeyLogin(t, f.authenticator))
+		require.NoError(t, err)
+
+		_, err = f.service.FinishLogin(ctx, f.passkeyLogin(t, clone))
+		assert.ErrorIs(t, err, webauthn.ErrCloneDetected)
+	})
+
+	t.Run("rejects unknown passkeys as invalid credentials", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		stranger := webauthntest.NewAuthenticator("localhost", webAuthnOrigin)
+		other := newWebAuthnFixture(t)
+		other.register(t, stranger, "Elsewhere")
+
+		_, _, err := f.authService.Login(ctx, &auth.LoginRequest{
+			Passkey: f.passkeyLogin(t, stranger),
+		})
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+
+	t.Run("rejects a replayed login", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		f.register(t, f.authenticator, "Laptop")
+
+		req := f.passkeyLogin(t, f.authenticator)
+		_, _, err := f.authService.Login(ctx, &auth.LoginRequest{Passkey: req})
+		require.NoError(t, err)
+
+		_, _, err = f.authService.Login(ctx, &auth.LoginRequest{Passkey: req})
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+}
+
+func TestAuthService_SecurityKeyLogin(t *testing.T) {
+	ctx := context.Background()
+	const password = "SecurePass123!"
+
+	// setup gives the user a password and makes a security key their only second factor
+	setup := func(t *testing.T) *webAuthnFixture {
+		f := newWebAuthnFixture(t)
+
+		hash, err := security.NewPasswordHasher().HashPassword(password)
+		require.NoError(t, err)
+		f.user.PasswordHash = hash
+		require.NoError(t, f.users.Update(ctx, f.user))
+
+		mfaRepo := new(MockMFARepository)
+		mfaRepo.On("GetSettings", mock.Anything, mock.Anything).Return(&mfa.Settings{
+			UserID:        f.user.ID,
+			Enabled:       true,
+			Methods:       []mfa.Method{mfa.MethodWebAuthn},
+			PrimaryMethod: mfa.MethodWebAuthn,
+		}, nil)
+		mfaRepo.On("LogAudit", mock.Anything, mock.Anything).Return(nil)
+		f.service.SetMFARepository(mfaRepo)
+		f.authService.SetMFAService(services.NewMFAService(mfaRepo, f.users, nil, nil, nil, nil, nil, nil))
+
+		f.register(t, f.authenticator, "YubiKey")
+		return f
+	}
+
+	// answer runs the security key step of a password sign-in
+	answer := func(t *testing.T, f *webAuthnFixture) *webauthn.FinishLoginRequest {
+		options, err := f.authService.BeginSecurityKeyChallenge(ctx, &auth.LoginRequest{Email: f.user.Email, Password: password})
+		require.NoError(t, err)
+		require.Len(t, options.PublicKey.AllowCredentials, 1)
+
+		response, err := f.authenticator.Login(options.PublicKey)
+		require.NoError(t, err)
+		return &webauthn.FinishLoginRequest{CeremonyID: options.CeremonyID, Credential: *response}
+	}
+
+	login := func(f *webAuthnFixture, assertion *webauthn.FinishLoginRequest) (*auth.TokenPair, error) {
+		req := &auth.LoginRequest{Email: f.user.Email, Password: password}
+		if assertion != nil {
+			req.MFAMethod = mfa.MethodWebAuthn
+			req.MFAAssertion = assertion
+		}
+		tokenPair, _, err := f.authService.Login(ctx, req)
+		return tokenPair, err
+	}
+
+	t.Run("signs in with a password and a security key", func(t *testing.T) {
+		f := setup(t)
+
+		_, err := login(f, nil)
+		require.ErrorIs(t, err, auth.ErrMFARequired)
+
+		tokenPair, err := login(f, answer(t, f))
+		require.NoError(t, err)
+		assert.NotEmpty(t, tokenPair.AccessToken)
+	})
+
+	t.Run("only starts the challenge for the right password", func(t *testing.T) {
+		f := setup(t)
+
+		_, err := f.authService.BeginSecurityKeyChallenge(ctx, &auth.LoginRequest{Email: f.user.Email, Password: "WrongPass123!"})
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+
+	t.Run("answers are single-use", func(t *testing.T) {
+		f := setup(t)
+		assertion := answer(t, f)
+
+		_, err := login(f, assertion)
+		require.NoError(t, err)
+
+		_, err = login(f, assertion)
+		assert.ErrorIs(t, err, auth.ErrInvalidMFACode)
+	})
+
+	t.Run("refuses a challenge started for another account", func(t *testing.T) {
+		f := setup(t)
+
+		other := &user.User{ID: uuid.New(), Email: "other@example.com", Username: "other", Status: user.StatusActive}
+		require.NoError(t, f.users.Create(ctx, other))
+		otherKey := webauthntest.NewAuthenticator("localhost", webAuthnOrigin)
+		registration, err := f.service.BeginRegistration(ctx, other.ID, "Other key")
+		require.NoError(t, err)
+		created, err := otherKey.Register(registration.PublicKey)
+		require.NoError(t, err)
+		_, err = f.service.FinishRegistration(ctx, other.ID, &webauthn.FinishRegistrationRequest{CeremonyID: registration.CeremonyID, Credential: *created})
+		require.NoError(t, err)
+
+		options, err := f.service.BeginChallenge(ctx, other.ID)
+		require.NoError(t, err)
+		response, err := otherKey.Login(options.PublicKey)
+		require.NoError(t, err)
+
+		_, err = login(f, &webauthn.FinishLoginRequest{CeremonyID: options.CeremonyID, Credential: *response})
+		assert.ErrorIs(t, err, auth.ErrInvalidMFACode)
+	})
+}
@@ -1,3 +1,20 @@
+-- Move the request log table created by 004 aside, keeping its rows; a
+-- table that already has the event audit log shape is left alone
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'audit_logs' AND column_name = 'request_path'
+    ) THEN
+        ALTER TABLE audit_logs RENAME TO request_logs;
+        ALTER INDEX idx_audit_logs_user_id RENAME TO idx_request_logs_user_id;
+        ALTER INDEX idx_audit_logs_action RENAME TO idx_request_logs_action;
+        ALTER INDEX idx_audit_logs_resource_type_id RENAME TO idx_request_logs_resource_type_id;
+        ALTER INDEX idx_audit_logs_created_at RENAME TO idx_request_logs_created_at;
+        ALTER INDEX idx_audit_logs_request_id RENAME TO idx_request_logs_request_id;
+    END IF;
+END $$;
+
 -- Create audit_logs table for comprehensive audit logging
 CREATE TABLE audit_logs (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
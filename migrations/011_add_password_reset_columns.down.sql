@@ -0,0 +1,5 @@
+-- Remove password reset and rotation tracking from users
+ALTER TABLE users
+    DROP COLUMN IF EXISTS password_changed_at,
+    DROP COLUMN IF EXISTS password_reset_expires_at,
+    DROP COLUMN IF EXISTS password_reset_token_hash;
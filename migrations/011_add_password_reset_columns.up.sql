@@ -0,0 +1,5 @@
+-- Add password reset and rotation tracking to users
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS password_reset_token_hash VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS password_reset_expires_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP;
@@ -0,0 +1,4 @@
+-- Drop OAuth / OpenID Connect provider tables
+DROP TABLE IF EXISTS oauth_consents;
+DROP TABLE IF EXISTS oauth_authorization_codes;
+DROP TABLE IF EXISTS oauth_clients;
@@ -0,0 +1,2 @@
+-- Drop federated identities table
+DROP TABLE IF EXISTS federated_identities;
@@ -0,0 +1,14 @@
+-- Create federated identities table linking users to upstream OpenID Connect accounts
+CREATE TABLE IF NOT EXISTS federated_identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider_id VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    linked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMP,
+    UNIQUE (provider_id, subject)
+);
+
+-- Create indexes
+CREATE INDEX idx_federated_identities_user_id ON federated_identities(user_id);
@@ -0,0 +1,10 @@
+-- Restore the users reference on audit actors, dropping entries that cannot satisfy it
+DROP INDEX IF EXISTS idx_audit_logs_actor_type;
+UPDATE audit_logs SET actor_id = NULL WHERE actor_type = 'service_account';
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS actor_type;
+ALTER TABLE audit_logs ADD CONSTRAINT audit_logs_actor_id_fkey
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL;
+
+-- Drop service account tables
+DROP TABLE IF EXISTS service_account_roles;
+DROP TABLE IF EXISTS service_accounts;
@@ -0,0 +1,32 @@
+-- Create service accounts table; only a hash of each secret is stored
+CREATE TABLE IF NOT EXISTS service_accounts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT,
+    client_id VARCHAR(64) NOT NULL UNIQUE,
+    secret_hash VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'disabled')),
+    created_by UUID NOT NULL,
+    last_authenticated_at TIMESTAMP,
+    secret_rotated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create service_account_roles junction table
+CREATE TABLE IF NOT EXISTS service_account_roles (
+    service_account_id UUID NOT NULL REFERENCES service_accounts(id) ON DELETE CASCADE,
+    role VARCHAR(100) NOT NULL,
+    granted_by UUID NOT NULL,
+    granted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (service_account_id, role)
+);
+
+-- Audit entries record whether the actor was a user or a service account.
+-- Service accounts are not users, so actor_id can no longer reference users.
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS actor_type VARCHAR(20);
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_actor_id_fkey;
+UPDATE audit_logs SET actor_type = 'user' WHERE actor_id IS NOT NULL AND actor_type IS NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_type ON audit_logs (actor_type) WHERE actor_type IS NOT NULL;
+
+COMMENT ON COLUMN audit_logs.actor_type IS 'Kind of principal that performed the action: user or service_account';
@@ -0,0 +1,31 @@
+-- Create WebAuthn credentials table; only public keys are stored
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    credential_id BYTEA NOT NULL UNIQUE,
+    public_key BYTEA NOT NULL,
+    algorithm INTEGER NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    aaguid BYTEA,
+    transports TEXT[] NOT NULL DEFAULT '{}',
+    backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create pending ceremonies table; each row is deleted when its challenge is answered
+CREATE TABLE IF NOT EXISTS webauthn_ceremonies (
+    id UUID PRIMARY KEY,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('registration', 'authentication')),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    challenge BYTEA NOT NULL,
+    credential_name VARCHAR(100),
+    user_verification VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+CREATE INDEX idx_webauthn_ceremonies_expires_at ON webauthn_ceremonies(expires_at);
@@ -0,0 +1,2 @@
+-- Drop sign-in links table
+DROP TABLE IF EXISTS magic_links;
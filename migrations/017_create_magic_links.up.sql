@@ -0,0 +1,13 @@
+-- Create sign-in links table; only hashes of the link token and browser nonce are stored
+CREATE TABLE IF NOT EXISTS magic_links (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    nonce_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_magic_links_user_id ON magic_links(user_id);
+CREATE INDEX idx_magic_links_expires_at ON magic_links(expires_at);
@@ -0,0 +1,3 @@
+-- Drop token store tables
+DROP TABLE IF EXISTS token_families;
+DROP TABLE IF EXISTS revoked_tokens;
@@ -0,0 +1,26 @@
+-- Create revoked tokens table; rows are only needed until the token would have expired
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Create refresh token families table
+CREATE TABLE IF NOT EXISTS token_families (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id VARCHAR(255) NOT NULL DEFAULT '',
+    current_jti VARCHAR(64) NOT NULL,
+    client_id VARCHAR(64) NOT NULL DEFAULT '',
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    rotated_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+-- Create indexes
+CREATE INDEX idx_revoked_tokens_user_id ON revoked_tokens(user_id);
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+CREATE INDEX idx_token_families_user_id ON token_families(user_id);
+CREATE INDEX idx_token_families_expires_at ON token_families(expires_at);
@@ -0,0 +1,2 @@
+-- Drop sign-in history table
+DROP TABLE IF EXISTS login_events;
@@ -0,0 +1,2 @@
+-- Drop trusted devices table
+DROP TABLE IF EXISTS trusted_devices;
@@ -0,0 +1,3 @@
+-- Drop SAML tables
+DROP TABLE IF EXISTS saml_used_assertions;
+DROP TABLE IF EXISTS saml_connections;
@@ -0,0 +1,27 @@
+-- Create SAML connections table; each organization has at most one identity provider
+CREATE TABLE IF NOT EXISTS saml_connections (
+    id UUID PRIMARY KEY,
+    organization_id UUID NOT NULL UNIQUE,
+    idp_entity_id VARCHAR(1024) NOT NULL,
+    sso_url TEXT NOT NULL,
+    certificates TEXT[] NOT NULL,
+    metadata TEXT NOT NULL,
+    attribute_mapping JSONB NOT NULL DEFAULT '{}',
+    role_mapping JSONB NOT NULL DEFAULT '{}',
+    default_roles TEXT[] NOT NULL DEFAULT '{}',
+    allow_signup BOOLEAN NOT NULL DEFAULT false,
+    allow_idp_initiated BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create used assertions table so that bearer assertions are accepted only once
+CREATE TABLE IF NOT EXISTS saml_used_assertions (
+    issuer VARCHAR(1024) NOT NULL,
+    assertion_id VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (issuer, assertion_id)
+);
+
+-- Create indexes
+CREATE INDEX idx_saml_used_assertions_expires_at ON saml_used_assertions(expires_at);
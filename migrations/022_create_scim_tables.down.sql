@@ -0,0 +1,5 @@
+-- Drop SCIM tables
+DROP TABLE IF EXISTS scim_group_members;
+DROP TABLE IF EXISTS scim_groups;
+DROP TABLE IF EXISTS scim_accounts;
+DROP TABLE IF EXISTS scim_tokens;
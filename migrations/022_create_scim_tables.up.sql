@@ -0,0 +1,47 @@
+-- Create SCIM tokens table; identity providers provision an organization's users with these
+CREATE TABLE IF NOT EXISTS scim_tokens (
+    id UUID PRIMARY KEY,
+    organization_id UUID NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create SCIM accounts table linking users to the organization that provisioned them
+CREATE TABLE IF NOT EXISTS scim_accounts (
+    organization_id UUID NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_name VARCHAR(255) NOT NULL,
+    external_id VARCHAR(255) NOT NULL DEFAULT '',
+    deprovisioned_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+-- Create SCIM groups table; role_id is the RBAC role granted to members
+CREATE TABLE IF NOT EXISTS scim_groups (
+    id UUID PRIMARY KEY,
+    organization_id UUID NOT NULL,
+    role_id UUID,
+    display_name VARCHAR(255) NOT NULL,
+    external_id VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS scim_group_members (
+    group_id UUID NOT NULL REFERENCES scim_groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+
+-- Create indexes
+CREATE INDEX idx_scim_tokens_organization_id ON scim_tokens(organization_id);
+CREATE UNIQUE INDEX idx_scim_accounts_user_name ON scim_accounts(organization_id, LOWER(user_name));
+CREATE UNIQUE INDEX idx_scim_groups_display_name ON scim_groups(organization_id, LOWER(display_name));
+CREATE INDEX idx_scim_group_members_user_id ON scim_group_members(user_id);
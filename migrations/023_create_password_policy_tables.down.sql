@@ -0,0 +1,3 @@
+-- Drop password policy tables
+DROP TABLE IF EXISTS password_expiry_warnings;
+DROP TABLE IF EXISTS password_history;
@@ -0,0 +1,17 @@
+-- Create password history table; holds hashes of passwords accounts no longer use
+CREATE TABLE IF NOT EXISTS password_history (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create password expiry warnings table; one row per account, naming the password it was warned about
+CREATE TABLE IF NOT EXISTS password_expiry_warnings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    password_set_at TIMESTAMP NOT NULL,
+    warned_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_password_history_user_id ON password_history(user_id, created_at DESC);
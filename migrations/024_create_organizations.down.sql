@@ -0,0 +1,6 @@
+-- Drop organization tables
+ALTER TABLE token_families DROP COLUMN IF EXISTS organization_id;
+DROP INDEX IF EXISTS idx_subscriptions_organization_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS organization_id;
+DROP TABLE IF EXISTS organization_memberships;
+DROP TABLE IF EXISTS organizations;
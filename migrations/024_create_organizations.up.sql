@@ -0,0 +1,30 @@
+-- Create organizations table
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(63) NOT NULL UNIQUE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create organization memberships table; role is the member's role within the organization
+CREATE TABLE IF NOT EXISTS organization_memberships (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL,
+    added_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+-- Subscriptions may be owned by an organization instead of a single user
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+
+-- Token families remember the organization their tokens are issued for
+ALTER TABLE token_families ADD COLUMN IF NOT EXISTS organization_id UUID;
+
+-- Create indexes
+CREATE INDEX idx_organization_memberships_user_id ON organization_memberships(user_id);
+CREATE INDEX idx_subscriptions_organization_id ON subscriptions(organization_id) WHERE organization_id IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Drop organization invitations table
+DROP TABLE IF EXISTS organization_invitations;
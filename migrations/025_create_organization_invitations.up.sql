@@ -0,0 +1,19 @@
+-- Create organization invitations table; role is pre-assigned to whoever accepts
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id UUID PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    accepted_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes; an address has at most one pending invitation per organization
+CREATE UNIQUE INDEX idx_organization_invitations_pending_email ON organization_invitations(organization_id, LOWER(email)) WHERE status = 'pending';
+CREATE INDEX idx_organization_invitations_organization_id ON organization_invitations(organization_id, created_at DESC);
@@ -0,0 +1,3 @@
+-- Drop subscription plan type and team member limit columns
+ALTER TABLE subscription_plans DROP COLUMN IF EXISTS max_team_members;
+ALTER TABLE subscription_plans DROP COLUMN IF EXISTS plan_type;
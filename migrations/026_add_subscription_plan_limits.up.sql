@@ -0,0 +1,3 @@
+-- Plans name the type per-plan policies are keyed by, and how many members an organization on the plan may have
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS plan_type VARCHAR(20) NOT NULL DEFAULT 'custom';
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS max_team_members INT;
@@ -0,0 +1,5 @@
+-- Drop MFA tables
+DROP TABLE IF EXISTS mfa_audit_logs;
+DROP TABLE IF EXISTS mfa_challenges;
+DROP TABLE IF EXISTS mfa_backup_codes;
+DROP TABLE IF EXISTS mfa_settings;
\ No newline at end of file
@@ -0,0 +1,59 @@
+-- Create MFA settings table; one row per account that has set up a second factor
+CREATE TABLE IF NOT EXISTS mfa_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT false,
+    methods TEXT[] NOT NULL DEFAULT '{}',
+    primary_method VARCHAR(20) NOT NULL DEFAULT '',
+    totp_secret VARCHAR(255) NOT NULL DEFAULT '',
+    phone_number VARCHAR(20) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Carry over TOTP set up before MFA had tables of its own
+INSERT INTO mfa_settings (user_id, enabled, methods, primary_method, totp_secret)
+SELECT id, true, ARRAY['totp'], 'totp', mfa_secret
+FROM users
+WHERE mfa_enabled AND mfa_secret IS NOT NULL AND mfa_secret <> ''
+ON CONFLICT (user_id) DO NOTHING;
+
+-- Create MFA backup codes table
+CREATE TABLE IF NOT EXISTS mfa_backup_codes (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code VARCHAR(64) NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT false,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, code)
+);
+
+-- Create MFA challenges table; codes sent by SMS or email
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    method VARCHAR(20) NOT NULL,
+    code VARCHAR(64) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create MFA audit log table
+CREATE TABLE IF NOT EXISTS mfa_audit_logs (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action VARCHAR(50) NOT NULL,
+    method VARCHAR(20) NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_mfa_challenges_user_id ON mfa_challenges(user_id);
+CREATE INDEX idx_mfa_challenges_expires_at ON mfa_challenges(expires_at);
+CREATE INDEX idx_mfa_audit_logs_user_created ON mfa_audit_logs(user_id, created_at DESC);
@@ -0,0 +1,2 @@
+-- Drop the per-user password history index
+DROP INDEX IF EXISTS idx_password_history_user_created;
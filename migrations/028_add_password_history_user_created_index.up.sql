@@ -0,0 +1,3 @@
+-- 023 could not replace the single-column index 007 created under the same
+-- name; add the per-user, newest-first index password history lookups use
+CREATE INDEX IF NOT EXISTS idx_password_history_user_created ON password_history(user_id, created_at DESC);
@@ -0,0 +1,2 @@
+-- Drop the introspection permission of OAuth clients
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS can_introspect;
@@ -0,0 +1,3 @@
+-- Resource servers allowed to introspect tokens issued to any client; other
+-- clients may only introspect their own tokens
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS can_introspect BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,126 @@
+package security
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"strings"
+)
+
+//go:embed common_password_hashes.txt
+var commonPasswordHashes string
+
+// commonPasswords is the built-in corpus, parsed once. It is looked up by range
+// like a remote corpus, so both compare hashes the same way.
+var commonPasswords = NewKAnonymitySource(newHashRanges(commonPasswordHashes))
+
+// BreachSource reports how often a password appears in known breaches. Sources
+// only ever see the password's SHA-1 hash.
+type BreachSource interface {
+	// Occurrences returns how many times the password with the given SHA-1 hash
+	// appears in the corpus, or zero if it does not
+	Occurrences(ctx context.Context, hash [sha1.Size]byte) (int, error)
+}
+
+// RangeClient fetches a k-anonymity range: every breached hash that starts with
+// the given five hex characters, keyed by the remaining 35 in upper case
+type RangeClient interface {
+	Range(ctx context.Context, prefix string) (map[string]int, error)
+}
+
+// KAnonymitySource is a BreachSource that asks a remote service for the range
+// of a hash's prefix, so the full hash never leaves the process
+type KAnonymitySource struct {
+	client RangeClient
+}
+
+// NewKAnonymitySource creates a breach source backed by a range client
+func NewKAnonymitySource(client RangeClient) *KAnonymitySource {
+	return &KAnonymitySource{client: client}
+}
+
+// Occurrences looks the hash's suffix up in the range of its prefix
+func (s *KAnonymitySource) Occurrences(ctx context.Context, hash [sha1.Size]byte) (int, error) {
+	encoded := strings.ToUpper(hex.EncodeToString(hash[:]))
+
+	suffixes, err := s.client.Range(ctx, encoded[:5])
+	if err != nil {
+		return 0, err
+	}
+
+	return suffixes[encoded[5:]], nil
+}
+
+// hashRanges is a RangeClient serving ranges from SHA-1 hashes held in memory
+type hashRanges map[string]map[string]int
+
+// newHashRanges parses one upper-case hex SHA-1 hash per line; blank lines,
+// # comments and anything else that is not a hash are skipped. The list carries no counts, so each hash occurs once.
+func newHashRanges(list string) hashRanges {
+	ranges := make(hashRanges)
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || len(line) != 2*sha1.Size {
+			continue
+		}
+		prefix, suffix := line[:5], line[5:]
+		if ranges[prefix] == nil {
+			ranges[prefix] = make(map[string]int)
+		}
+		ranges[prefix][suffix] = 1
+	}
+	return ranges
+}
+
+// Range returns the suffixes of the hashes starting with prefix
+func (r hashRanges) Range(ctx context.Context, prefix string) (map[string]int, error) {
+	return r[prefix], nil
+}
+
+// BreachChecker checks passwords against breach corpora
+type BreachChecker struct {
+	sources []BreachSource
+}
+
+// NewBreachChecker creates a checker that consults the sources in order.
+// Without sources it checks a small built-in list of common passwords.
+func NewBreachChecker(sources ...BreachSource) *BreachChecker {
+	if len(sources) == 0 {
+		sources = []BreachSource{commonPasswords}
+	}
+	return &BreachChecker{sources: sources}
+}
+
+// Count returns how many times the password appears in breaches, according to
+// the first source that knows it. It fails only if no source found the password
+// and at least one could not be consulted.
+func (b *BreachChecker) Count(ctx context.Context, password string) (int, error) {
+	// Breach corpora are published as SHA-1 hashes; this is a lookup key, not storage
+	hash := sha1.Sum([]byte(password))
+
+	// A failing source does not hide a hit in the others
+	var sourceErr error
+	for _, source := range b.sources {
+		count, err := source.Occurrences(ctx, hash)
+		if err != nil {
+			if sourceErr == nil {
+				sourceErr = err
+			}
+			continue
+		}
+		if count > 0 {
+			return count, nil
+		}
+	}
+
+	return 0, sourceErr
+}
+
+// IsBreached checks if the password appears in any breach
+func (b *BreachChecker) IsBreached(password string) bool {
+	count, err := b.Count(context.Background(), password)
+	return err == nil && count > 0
+}
@@ -0,0 +1,105 @@
+package security
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// corpusSource is an in-memory breach corpus keyed by SHA-1 hash
+type corpusSource map[[sha1.Size]byte]int
+
+func (c corpusSource) Occurrences(ctx context.Context, hash [sha1.Size]byte) (int, error) {
+	return c[hash], nil
+}
+
+type failingSource struct{}
+
+func (failingSource) Occurrences(ctx context.Context, hash [sha1.Size]byte) (int, error) {
+	return 0, errors.New("corpus unavailable")
+}
+
+// rangeClient serves k-anonymity ranges from a corpus and records the prefixes asked for
+type rangeClient struct {
+	corpus   corpusSource
+	prefixes []string
+}
+
+func (c *rangeClient) Range(ctx context.Context, prefix string) (map[string]int, error) {
+	c.prefixes = append(c.prefixes, prefix)
+
+	suffixes := make(map[string]int)
+	for hash, count := range c.corpus {
+		encoded := strings.ToUpper(hex.EncodeToString(hash[:]))
+		if strings.HasPrefix(encoded, prefix) {
+			suffixes[encoded[5:]] = count
+		}
+	}
+	return suffixes, nil
+}
+
+func TestBreachChecker_Count(t *testing.T) {
+	ctx := context.Background()
+	corpus := corpusSource{
+		sha1.Sum([]byte("password")): 9659365,
+		sha1.Sum([]byte("123456")):   37359195,
+	}
+
+	t.Run("reports occurrences of exact matches only", func(t *testing.T) {
+		checker := NewBreachChecker(corpus)
+
+		count, err := checker.Count(ctx, "password")
+		require.NoError(t, err)
+		assert.Equal(t, 9659365, count)
+		assert.True(t, checker.IsBreached("123456"))
+
+		assert.False(t, checker.IsBreached("Password"))
+		assert.False(t, checker.IsBreached("mypassword123456"))
+	})
+
+	t.Run("without sources common passwords are breached", func(t *testing.T) {
+		checker := NewBreachChecker()
+
+		assert.True(t, checker.IsBreached("password"))
+		assert.True(t, checker.IsBreached("Password123"))
+		assert.False(t, checker.IsBreached("Un1qu3P@ssw0rd!"))
+	})
+
+	t.Run("a failing source does not hide hits in others", func(t *testing.T) {
+		checker := NewBreachChecker(failingSource{}, corpus)
+
+		count, err := checker.Count(ctx, "password")
+		require.NoError(t, err)
+		assert.Equal(t, 9659365, count)
+
+		_, err = checker.Count(ctx, "Un1qu3P@ssw0rd!")
+		assert.Error(t, err)
+		assert.False(t, checker.IsBreached("Un1qu3P@ssw0rd!"))
+	})
+
+	t.Run("k-anonymity source sends only the hash prefix", func(t *testing.T) {
+		client := &rangeClient{corpus: corpus}
+		checker := NewBreachChecker(NewKAnonymitySource(client))
+
+		count, err := checker.Count(ctx, "123456")
+		require.NoError(t, err)
+		assert.Equal(t, 37359195, count)
+
+		hash := sha1.Sum([]byte("123456"))
+		assert.Equal(t, []string{strings.ToUpper(hex.EncodeToString(hash[:]))[:5]}, client.prefixes)
+	})
+	t.Run("the built-in list holds only hashes", func(t *testing.T) {
+		for _, line := range strings.Split(commonPasswordHashes, "\n") {
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			assert.Regexp(t, "^[0-9A-F]{40}$", line)
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher hashes passwords and verifies them against stored hashes
+type Hasher interface {
+	// HashPassword hashes a plain text password
+	HashPassword(password string) (string, error)
+
+	// VerifyPassword checks if the password matches the hash
+	VerifyPassword(password, encodedHash string) bool
+
+	// NeedsRehash checks if the hash should be replaced with a fresh one
+	NeedsRehash(encodedHash string) bool
+}
+
+// HashAlgorithm is a Hasher for one algorithm that recognises its own hashes
+type HashAlgorithm interface {
+	Hasher
+
+	// Name returns the algorithm identifier, as used in PHC strings
+	Name() string
+
+	// Matches checks if the hash was produced by this algorithm
+	Matches(encodedHash string) bool
+
+	// Parameters describes the cost settings the hash was produced with
+	Parameters(encodedHash string) string
+}
+
+// HashInfo describes how a stored password hash was produced
+type HashInfo struct {
+	Algorithm  string
+	Parameters string
+	Current    bool
+}
+
+// UnknownAlgorithm names hashes no registered algorithm recognises
+const UnknownAlgorithm = "unknown"
+
+// HasherRegistry verifies hashes with whichever registered algorithm produced them
+// and hashes new passwords with the preferred one. Hashes produced by any other
+// algorithm, or by the preferred one with outdated parameters, need a rehash.
+type HasherRegistry struct {
+	preferred  HashAlgorithm
+	algorithms []HashAlgorithm
+}
+
+// NewHasherRegistry creates a registry that hashes with preferred and still
+// verifies hashes produced by the legacy algorithms
+func NewHasherRegistry(preferred HashAlgorithm, legacy ...HashAlgorithm) *HasherRegistry {
+	r := &HasherRegistry{preferred: preferred}
+	r.Register(preferred)
+	for _, algorithm := range legacy {
+		r.Register(algorithm)
+	}
+	return r
+}
+
+// Register adds an algorithm whose hashes the registry can verify
+func (r *HasherRegistry) Register(algorithm HashAlgorithm) {
+	r.algorithms = append(r.algorithms, algorithm)
+}
+
+// Preferred returns the algorithm new hashes are produced with
+func (r *HasherRegistry) Preferred() HashAlgorithm {
+	return r.preferred
+}
+
+// HashPassword hashes a password with the preferred algorithm
+func (r *HasherRegistry) HashPassword(password string) (string, error) {
+	return r.preferred.HashPassword(password)
+}
+
+// VerifyPassword checks the password with the algorithm that produced the hash
+func (r *HasherRegistry) VerifyPassword(password, encodedHash string) bool {
+	algorithm := r.lookup(encodedHash)
+	if algorithm == nil {
+		return false
+	}
+	return algorithm.VerifyPassword(password, encodedHash)
+}
+
+// NeedsRehash checks if the hash was not produced by the preferred algorithm
+// with its current parameters
+func (r *HasherRegistry) NeedsRehash(encodedHash string) bool {
+	algorithm := r.lookup(encodedHash)
+	if algorithm != r.preferred {
+		return true
+	}
+	return algorithm.NeedsRehash(encodedHash)
+}
+
+// Describe reports the algorithm and parameters a hash was produced with
+func (r *HasherRegistry) Describe(encodedHash string) HashInfo {
+	algorithm := r.lookup(encodedHash)
+	if algorithm == nil {
+		return HashInfo{Algorithm: UnknownAlgorithm}
+	}
+	return HashInfo{
+		Algorithm:  algorithm.Name(),
+		Parameters: algorithm.Parameters(encodedHash),
+		Current:    algorithm == r.preferred && !algorithm.NeedsRehash(encodedHash),
+	}
+}
+
+func (r *HasherRegistry) lookup(encodedHash string) HashAlgorithm {
+	for _, algorithm := range r.algorithms {
+		if algorithm.Matches(encodedHash) {
+			return algorithm
+		}
+	}
+	return nil
+}
+
+// BcryptHasher handles bcrypt hashes in modular crypt format ($2a$, $2b$ or $2y$)
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher creates a bcrypt hasher that produces hashes of the given cost
+func NewBcryptHasher(cost int) *BcryptHasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &BcryptHasher{cost: cost}
+}
+
+// Name returns the algorithm identifier
+func (h *BcryptHasher) Name() string {
+	return "bcrypt"
+}
+
+// Matches checks if the hash is a bcrypt hash
+func (h *BcryptHasher) Matches(encodedHash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(encodedHash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// HashPassword generates a bcrypt hash from the given password
+func (h *BcryptHasher) HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// VerifyPassword checks if the provided password matches the hash
+func (h *BcryptHasher) VerifyPassword(password, encodedHash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password)) == nil
+}
+
+// NeedsRehash checks if the hash is cheaper than the configured cost
+func (h *BcryptHasher) NeedsRehash(encodedHash string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	return err != nil || cost < h.cost
+}
+
+// Parameters returns the hash's cost factor
+func (h *BcryptHasher) Parameters(encodedHash string) string {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("cost=%d", cost)
+}
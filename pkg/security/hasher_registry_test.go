@@ -0,0 +1,61 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasherRegistry(t *testing.T) {
+	argon := NewPasswordHasher()
+	bcryptHasher := NewBcryptHasher(4)
+	registry := NewHasherRegistry(argon, bcryptHasher)
+
+	argonHash, err := registry.HashPassword("Secret123")
+	require.NoError(t, err)
+	bcryptHash, err := bcryptHasher.HashPassword("Secret123")
+	require.NoError(t, err)
+
+	t.Run("hashes with the preferred algorithm", func(t *testing.T) {
+		assert.True(t, argon.Matches(argonHash))
+		assert.False(t, registry.NeedsRehash(argonHash))
+	})
+
+	t.Run("verifies with the algorithm that produced the hash", func(t *testing.T) {
+		assert.True(t, registry.VerifyPassword("Secret123", argonHash))
+		assert.True(t, registry.VerifyPassword("Secret123", bcryptHash))
+		assert.False(t, registry.VerifyPassword("Wrong123", argonHash))
+		assert.False(t, registry.VerifyPassword("Wrong123", bcryptHash))
+	})
+
+	t.Run("legacy and outdated hashes need a rehash", func(t *testing.T) {
+		assert.True(t, registry.NeedsRehash(bcryptHash))
+
+		// Same algorithm, weaker parameters
+		weak, err := NewArgon2Hasher().Hash("Secret123")
+		require.NoError(t, err)
+		assert.True(t, registry.VerifyPassword("Secret123", weak))
+		assert.True(t, registry.NeedsRehash(weak))
+	})
+
+	t.Run("unknown hashes never verify", func(t *testing.T) {
+		assert.False(t, registry.VerifyPassword("Secret123", "$md5$abc"))
+		assert.False(t, registry.VerifyPassword("Secret123", ""))
+		assert.True(t, registry.NeedsRehash("$md5$abc"))
+	})
+
+	t.Run("describes algorithm and cost", func(t *testing.T) {
+		assert.Equal(t, HashInfo{Algorithm: "argon2id", Parameters: "m=65536,t=3,p=2", Current: true}, registry.Describe(argonHash))
+		assert.Equal(t, HashInfo{Algorithm: "bcrypt", Parameters: "cost=4"}, registry.Describe(bcryptHash))
+		assert.Equal(t, HashInfo{Algorithm: UnknownAlgorithm}, registry.Describe("plaintext"))
+	})
+}
+
+func TestBcryptHasher_NeedsRehash(t *testing.T) {
+	hash, err := NewBcryptHasher(4).HashPassword("Secret123")
+	require.NoError(t, err)
+
+	assert.False(t, NewBcryptHasher(4).NeedsRehash(hash))
+	assert.True(t, NewBcryptHasher(5).NeedsRehash(hash))
+}
@@ -126,3 +126,22 @@ func (h *PasswordHasher) NeedsRehash(encodedHash string) bool {
 
 	return false
 }
+
+// Name returns the algorithm identifier
+func (h *PasswordHasher) Name() string {
+	return "argon2id"
+}
+
+// Matches checks if the hash is an Argon2id hash
+func (h *PasswordHasher) Matches(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$argon2id$")
+}
+
+// Parameters returns the memory, iteration and parallelism settings of the hash
+func (h *PasswordHasher) Parameters(encodedHash string) string {
+	vals := strings.Split(encodedHash, "$")
+	if len(vals) != 6 {
+		return ""
+	}
+	return vals[3]
+}
@@ -1,6 +1,7 @@
 package security
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
@@ -55,6 +56,7 @@ type ValidationResult struct {
 	Suggestions []string
 	Score       *PasswordScore
 	Entropy     float64
+	BreachCount int // Times the password appears in known breaches
 }
 
 type PasswordScore struct {
@@ -80,7 +82,13 @@ func NewPasswordValidator(policy *PasswordPolicy) *PasswordValidator {
 	}
 }
 
-func (v *PasswordValidator) Validate(password, username, email string) (*ValidationResult, error) {
+// SetBreachChecker sets the checker that rejects passwords seen in breaches
+func (v *PasswordValidator) SetBreachChecker(checker *BreachChecker) {
+	v.breachChecker = checker
+}
+
+// Validate checks a password against the policy. ctx bounds the breach corpus lookups.
+func (v *PasswordValidator) Validate(ctx context.Context, password, username, email string) (*ValidationResult, error) {
 	result := &ValidationResult{
 		IsValid:     true,
 		Errors:      []string{},
@@ -157,9 +165,14 @@ func (v *PasswordValidator) Validate(password, username, email string) (*Validat
 		}
 	}
 
-	if v.policy.ProhibitCommonWords && v.breachChecker.IsBreached(password) {
-		result.IsValid = false
-		result.Errors = append(result.Errors, "password is too common")
+	if v.policy.ProhibitCommonWords {
+		// An unreachable corpus must not stop everyone from choosing a password
+		count, err := v.breachChecker.Count(ctx, password)
+		if err == nil && count > 0 {
+			result.BreachCount = count
+			result.IsValid = false
+			result.Errors = append(result.Errors, "password is too common: it has appeared in data breaches")
+		}
 	}
 
 	result.Entropy = v.entropyCalc.Calculate(password)
@@ -344,49 +357,6 @@ func (p *PasswordStrengthChecker) GetScore(password string) int {
 	return score
 }
 
-type BreachChecker struct {
-	commonPasswords map[string]bool
-}
-
-func NewBreachChecker() *BreachChecker {
-	commonPasswords := map[string]bool{
-		"password":    true,
-		"password1":   true,
-		"password123": true,
-		"123456":      true,
-		"12345678":    true,
-		"qwerty":      true,
-		"qwerty123":   true,
-		"admin":       true,
-		"letmein":     true,
-		"welcome":     true,
-		"monkey":      true,
-		"dragon":      true,
-		"master":      true,
-		"1234567890":  true,
-	}
-
-	return &BreachChecker{
-		commonPasswords: commonPasswords,
-	}
-}
-
-func (b *BreachChecker) IsBreached(password string) bool {
-	lowerPassword := strings.ToLower(password)
-
-	if b.commonPasswords[lowerPassword] {
-		return true
-	}
-
-	for common := range b.commonPasswords {
-		if strings.Contains(lowerPassword, common) {
-			return true
-		}
-	}
-
-	return false
-}
-
 type Argon2Hasher struct {
 	time    uint32
 	memory  uint32
@@ -454,8 +424,10 @@ func (h *Argon2Hasher) Verify(password, encodedHash string) (bool, error) {
 	return subtle.ConstantTimeCompare(hash, hash2) == 1, nil
 }
 
+// PasswordHistory checks new passwords against the hashes of previous ones
 type PasswordHistory struct {
 	maxCount int
+	hasher   Hasher
 }
 
 func NewPasswordHistory(maxCount int) *PasswordHistory {
@@ -464,7 +436,33 @@ func NewPasswordHistory(maxCount int) *PasswordHistory {
 	}
 }
 
+// SetHasher sets the hasher that verifies previous hashes, so hashes of any
+// algorithm it knows are checked. Without it only Argon2id hashes are.
+func (ph *PasswordHistory) SetHasher(hasher Hasher) {
+	ph.hasher = hasher
+}
+
+// MaxCount returns how many previous passwords are checked
+func (ph *PasswordHistory) MaxCount() int {
+	return ph.maxCount
+}
+
+// IsPasswordUsed checks the password against the newest maxCount of the
+// previous hashes, which are ordered newest first
 func (ph *PasswordHistory) IsPasswordUsed(password string, previousHashes []string) (bool, error) {
+	if ph.maxCount > 0 && len(previousHashes) > ph.maxCount {
+		previousHashes = previousHashes[:ph.maxCount]
+	}
+
+	if ph.hasher != nil {
+		for _, hash := range previousHashes {
+			if ph.hasher.VerifyPassword(password, hash) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
 	hasher := NewArgon2Hasher()
 
 	for _, hash := range previousHashes {
@@ -1,6 +1,8 @@
 package security
 
 import (
+	"context"
+	"crypto/sha1"
 	"strings"
 	"testing"
 
@@ -61,7 +63,7 @@ func TestPasswordValidator_ValidateLength(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			validator := NewPasswordValidator(tt.policy)
-			result, err := validator.Validate(tt.password, "", "")
+			result, err := validator.Validate(context.Background(), tt.password, "", "")
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -139,7 +141,7 @@ func TestPasswordValidator_ValidateComplexity(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			validator := NewPasswordValidator(tt.policy)
-			result, err := validator.Validate(tt.password, "", "")
+			result, err := validator.Validate(context.Background(), tt.password, "", "")
 
 			if tt.wantErr {
 				assert.False(t, result.IsValid)
@@ -195,7 +197,7 @@ func TestPasswordValidator_ProhibitSequential(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			validator := NewPasswordValidator(tt.policy)
-			result, err := validator.Validate(tt.password, "", "")
+			result, err := validator.Validate(context.Background(), tt.password, "", "")
 
 			if tt.wantErr {
 				assert.False(t, result.IsValid)
@@ -241,7 +243,7 @@ func TestPasswordValidator_ProhibitRepeating(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			validator := NewPasswordValidator(tt.policy)
-			result, err := validator.Validate(tt.password, "", "")
+			result, err := validator.Validate(context.Background(), tt.password, "", "")
 
 			if tt.wantErr {
 				assert.False(t, result.IsValid)
@@ -305,7 +307,7 @@ func TestPasswordValidator_ProhibitUserInfo(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			validator := NewPasswordValidator(tt.policy)
-			result, err := validator.Validate(tt.password, tt.username, tt.email)
+			result, err := validator.Validate(context.Background(), tt.password, tt.username, tt.email)
 
 			if tt.wantErr {
 				assert.False(t, result.IsValid)
@@ -320,32 +322,45 @@ func TestPasswordValidator_ProhibitUserInfo(t *testing.T) {
 }
 
 func TestPasswordValidator_ProhibitCommonWords(t *testing.T) {
+	checker := NewBreachChecker(corpusSource{
+		sha1.Sum([]byte("Password123!@#")): 1520,
+	})
+
 	tests := []struct {
-		name     string
-		password string
-		policy   *PasswordPolicy
-		wantErr  bool
-		errMsg   string
+		name        string
+		password    string
+		policy      *PasswordPolicy
+		wantErr     bool
+		errMsg      string
+		breachCount int
 	}{
 		{
-			name:     "contains common password",
+			name:     "breached password",
 			password: "Password123!@#",
 			policy: &PasswordPolicy{
 				MinLength:           12,
 				ProhibitCommonWords: true,
 			},
-			wantErr: true,
-			errMsg:  "password is too common",
+			wantErr:     true,
+			errMsg:      "password is too common",
+			breachCount: 1520,
 		},
 		{
-			name:     "contains qwerty pattern",
+			name:     "merely contains a common word",
 			password: "Qwerty123456!@#",
 			policy: &PasswordPolicy{
 				MinLength:           12,
 				ProhibitCommonWords: true,
 			},
-			wantErr: true,
-			errMsg:  "password is too common",
+			wantErr: false,
+		},
+		{
+			name:     "breached password allowed by policy",
+			password: "Password123!@#",
+			policy: &PasswordPolicy{
+				MinLength: 12,
+			},
+			wantErr: false,
 		},
 		{
 			name:     "unique password",
@@ -361,8 +376,10 @@ func TestPasswordValidator_ProhibitCommonWords(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			validator := NewPasswordValidator(tt.policy)
-			result, err := validator.Validate(tt.password, "", "")
+			validator.SetBreachChecker(checker)
+			result, err := validator.Validate(context.Background(), tt.password, "", "")
 
+			assert.Equal(t, tt.breachCount, result.BreachCount)
 			if tt.wantErr {
 				assert.False(t, result.IsValid)
 				assert.Contains(t, strings.Join(result.Errors, " "), tt.errMsg)
@@ -505,7 +522,7 @@ func TestPasswordValidator_CompleteValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := validator.Validate(tt.password, tt.username, tt.email)
+			result, err := validator.Validate(context.Background(), tt.password, tt.username, tt.email)
 
 			if tt.wantErr {
 				assert.False(t, result.IsValid)
@@ -599,26 +616,3 @@ func TestPasswordHistory_Check(t *testing.T) {
 		assert.False(t, used, "New password should not be in history")
 	})
 }
-
-func TestBreachChecker_CheckPassword(t *testing.T) {
-	t.Run("check breached passwords", func(t *testing.T) {
-		checker := NewBreachChecker()
-
-		breachedPasswords := []string{
-			"password",
-			"123456",
-			"password123",
-			"qwerty",
-			"admin",
-		}
-
-		for _, pwd := range breachedPasswords {
-			breached := checker.IsBreached(pwd)
-			assert.True(t, breached, "Password %s should be marked as breached", pwd)
-		}
-
-		safePassword := "Un1qu3P@ssw0rd!XyZ#2024"
-		breached := checker.IsBreached(safePassword)
-		assert.False(t, breached, "Safe password should not be marked as breached")
-	})
-}